@@ -0,0 +1,185 @@
+package gemini
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+
+	"github.com/ba0gu0/gemini-go-proxy/pkg/client"
+	"github.com/ba0gu0/gemini-go-proxy/pkg/models"
+)
+
+// ErrEmptyReply 上游没有返回任何候选内容
+var ErrEmptyReply = errors.New("model returned no candidates")
+
+// ChatOptions 多轮对话选项
+type ChatOptions struct {
+	SystemInstruction string                         // 系统指令
+	GenerationConfig  *models.GeminiGenerationConfig // 生成参数（温度、最大输出token等）
+	Tools             []models.GeminiTool            // 可供模型调用的工具
+	History           []models.GeminiContent         // 初始对话历史
+}
+
+// Chat 基于 GeminiClient 的多轮对话，自动维护对话历史，可在多个goroutine中使用
+type Chat struct {
+	client  *client.GeminiClient
+	model   string
+	options ChatOptions
+
+	mu      sync.Mutex
+	history []models.GeminiContent
+	usage   models.GeminiUsageMetadata
+}
+
+// NewChat 创建使用指定模型的多轮对话，opts为nil时使用默认选项
+func NewChat(geminiClient *client.GeminiClient, model string, opts *ChatOptions) *Chat {
+	chat := &Chat{client: geminiClient, model: model}
+	if opts != nil {
+		chat.options = *opts
+		chat.history = append(chat.history, opts.History...)
+	}
+	return chat
+}
+
+// NewChat 使用代理的客户端创建多轮对话
+func (gp *GeminiProxy) NewChat(model string, opts *ChatOptions) *Chat {
+	return NewChat(gp.client, model, opts)
+}
+
+// Send 发送一条用户消息并返回模型回复的文本，成功后用户消息和回复都追加到对话历史
+func (c *Chat) Send(ctx context.Context, text string) (string, error) {
+	message := userMessage(text)
+	resp, err := c.client.SendRequest(ctx, c.model, c.request(message))
+	if err != nil {
+		return "", err
+	}
+	if len(resp.Candidates) == 0 {
+		return "", ErrEmptyReply
+	}
+
+	reply := resp.Candidates[0].Content
+	c.record(message, reply, resp.UsageMetadata)
+	return contentText(reply), nil
+}
+
+// Stream 发送一条用户消息并流式接收回复，每收到一段文本调用一次onText；
+// 返回完整的回复文本，流正常结束后才追加到对话历史，onText返回错误时中止请求
+func (c *Chat) Stream(ctx context.Context, text string, onText func(string) error) (string, error) {
+	message := userMessage(text)
+	var (
+		builder strings.Builder
+		parts   []models.GeminiPart
+		usage   *models.GeminiUsageMetadata
+	)
+	err := c.client.SendStreamRequest(ctx, c.model, c.request(message), func(chunk *models.GeminiStreamChunk) error {
+		if chunk.UsageMetadata != nil {
+			usage = chunk.UsageMetadata
+		}
+		// 请求了多个候选时只取第一个候选，其他候选的块跳过
+		candidate := chunk.Candidate(0)
+		if candidate == nil {
+			return nil
+		}
+		for _, part := range candidate.Content.Parts {
+			if part.Text == "" {
+				parts = append(parts, part)
+				continue
+			}
+			builder.WriteString(part.Text)
+			if onText != nil {
+				if err := onText(part.Text); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	if builder.Len() == 0 && len(parts) == 0 {
+		return "", ErrEmptyReply
+	}
+
+	if builder.Len() > 0 {
+		parts = append([]models.GeminiPart{{Text: builder.String()}}, parts...)
+	}
+	c.record(message, models.GeminiContent{Role: "model", Parts: parts}, usage)
+	return builder.String(), nil
+}
+
+// History 返回当前对话历史的副本
+func (c *Chat) History() []models.GeminiContent {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]models.GeminiContent(nil), c.history...)
+}
+
+// Usage 返回本次对话累计的token用量
+func (c *Chat) Usage() models.GeminiUsageMetadata {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.usage
+}
+
+// Reset 清空对话历史（保留初始选项中的历史）和用量统计
+func (c *Chat) Reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.history = append([]models.GeminiContent(nil), c.options.History...)
+	c.usage = models.GeminiUsageMetadata{}
+}
+
+// request 以当前历史加上新消息构造请求
+func (c *Chat) request(message models.GeminiContent) *models.GeminiRequest {
+	c.mu.Lock()
+	contents := make([]models.GeminiContent, 0, len(c.history)+1)
+	contents = append(contents, c.history...)
+	c.mu.Unlock()
+
+	req := &models.GeminiRequest{
+		Contents: append(contents, message),
+		Tools:    c.options.Tools,
+	}
+	if c.options.GenerationConfig != nil {
+		generationConfig := *c.options.GenerationConfig
+		req.GenerationConfig = &generationConfig
+	}
+	if c.options.SystemInstruction != "" {
+		req.SystemInstruction = &models.GeminiSystemInstruction{
+			Parts: []models.GeminiPart{{Text: c.options.SystemInstruction}},
+		}
+	}
+	return req
+}
+
+// record 追加一轮对话并累计用量
+func (c *Chat) record(message, reply models.GeminiContent, usage *models.GeminiUsageMetadata) {
+	if reply.Role == "" {
+		reply.Role = "model"
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.history = append(c.history, message, reply)
+	if usage != nil {
+		c.usage.PromptTokenCount += usage.PromptTokenCount
+		c.usage.CandidatesTokenCount += usage.CandidatesTokenCount
+		c.usage.TotalTokenCount += usage.TotalTokenCount
+	}
+}
+
+// userMessage 构造用户消息
+func userMessage(text string) models.GeminiContent {
+	return models.GeminiContent{Role: "user", Parts: []models.GeminiPart{{Text: text}}}
+}
+
+// contentText 拼接内容中的文本
+func contentText(content models.GeminiContent) string {
+	var builder strings.Builder
+	for _, part := range content.Parts {
+		builder.WriteString(part.Text)
+	}
+	return builder.String()
+}
@@ -3,56 +3,84 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"strings"
 	"syscall"
 	"time"
 
 	gemini "github.com/ba0gu0/gemini-go-proxy"
+	"github.com/ba0gu0/gemini-go-proxy/pkg/audit"
+	"github.com/ba0gu0/gemini-go-proxy/pkg/auth"
+	"github.com/ba0gu0/gemini-go-proxy/pkg/client"
 	"github.com/ba0gu0/gemini-go-proxy/pkg/config"
+	"github.com/ba0gu0/gemini-go-proxy/pkg/deploy"
+	"github.com/sirupsen/logrus"
 )
 
 func main() {
 	var cfg *config.Config
 	var err error
 	var configFile string
+	var remoteSource *config.RemoteSource
 	
 	// 检查命令行参数
-	if len(os.Args) < 2 {
+	args, profile := parseProfileFlag(os.Args[1:])
+	if len(args) >= 3 && args[0] == "config" && args[1] == "backups" && args[2] == "prune" {
+		pruneBackups(args[3:], profile)
+		return
+	}
+	if len(args) >= 1 && args[0] == "shard" {
+		shardConfig(args[1:], profile)
+		return
+	}
+	if len(args) >= 2 && args[0] == "audit" && args[1] == "verify" {
+		verifyAuditLog(args[2:], profile)
+		return
+	}
+	if len(args) >= 2 && args[0] == "generate" && args[1] == "deploy" {
+		generateDeploy(args[2:])
+		return
+	}
+	if len(args) >= 2 && args[0] == "token" && args[1] == "export" {
+		exportToken(args[2:], profile)
+		return
+	}
+	if len(args) >= 2 && args[0] == "proxies" && args[1] == "test" {
+		testProxies(args[2:], profile)
+		return
+	}
+	if len(args) < 1 {
 		// 默认模式：不使用配置文件
 		cfg = createDefaultConfig()
 		configFile = "config.json"
 		fmt.Println("=== Gemini Proxy - Default Mode ===")
 		fmt.Println("No config file specified, using default settings...")
 	} else {
-		configFile = os.Args[1]
+		configFile = args[0]
 		if configFile == "--help" || configFile == "-h" {
 			printUsage()
 			os.Exit(0)
 		}
 		
-		// 检查配置文件是否存在
-		if _, err := os.Stat(configFile); os.IsNotExist(err) {
-			log.Fatalf("Config file not found: %s", configFile)
-		}
-		
-		// 从配置文件加载配置
-		cfg, err = config.LoadConfig(configFile)
-		if err != nil {
-			log.Fatalf("Failed to load config: %v", err)
-		}
-		
-		// 填充缺失的默认值
-		if cfg.FillDefaults() {
-			fmt.Println("Some configuration values were missing, filled with defaults...")
-			// 保存更新后的配置文件
-			if err := cfg.SaveConfig(configFile); err != nil {
-				fmt.Printf("Warning: Failed to save updated config to %s: %v\n", configFile, err)
-			} else {
-				fmt.Printf("Updated configuration saved to: %s\n", configFile)
+		if config.IsRemoteConfig(configFile) {
+			// 远程配置：GEMINI_CONFIG_KEY 为解密密钥，不写回本地文件
+			fetchCtx, fetchCancel := context.WithTimeout(context.Background(), 30*time.Second)
+			cfg, remoteSource, err = config.LoadRemoteConfig(fetchCtx, configFile, os.Getenv("GEMINI_CONFIG_KEY"))
+			fetchCancel()
+			if err != nil {
+				log.Fatalf("Failed to load remote config: %v", err)
 			}
+			cfg.FillDefaults()
+			fmt.Printf("Loaded remote config from: %s\n", configFile)
+			configFile = ""
+		} else {
+			cfg = loadLocalConfig(configFile, profile)
 		}
 	}
 
@@ -64,6 +92,10 @@ func main() {
 	// 创建Gemini代理实例
 	proxy := gemini.NewGeminiProxy(cfg)
 	proxy.SetConfigFile(configFile)
+	proxy.SetConfigProfile(profile)
+	if remoteSource != nil {
+		proxy.SetRemoteConfig(remoteSource, 0)
+	}
 
 	// 创建上下文
 	ctx, cancel := context.WithCancel(context.Background())
@@ -76,10 +108,15 @@ func main() {
 		fmt.Printf("Location: %s\n", cfg.Location)
 	}
 	
-	// 初始化OAuth认证
+	// 初始化OAuth认证，前置路由模式不需要认证
 	var initErr error
-	fmt.Println("Initializing Google OAuth authentication...")
-	initErr = proxy.InitializeWithGoogleAuth(ctx)
+	if cfg.Router != nil {
+		fmt.Printf("Router mode: distributing requests across %d upstream instance(s)\n", len(cfg.Router.Upstreams))
+		initErr = proxy.InitializeRouter()
+	} else {
+		fmt.Println("Initializing Google OAuth authentication...")
+		initErr = proxy.InitializeWithGoogleAuth(ctx)
+	}
 	
 	if initErr != nil {
 		log.Fatalf("Failed to initialize: %v", initErr)
@@ -87,7 +124,11 @@ func main() {
 	
 	fmt.Printf("\nServer will start on: %s\n", proxy.GetServerURL())
 	fmt.Printf("API Key: %s\n", cfg.APIKeys[0])
-	if cfg.TokenFile != "" {
+	if cfg.Router != nil {
+		for _, upstream := range cfg.Router.Upstreams {
+			fmt.Printf("Upstream: %s (weight %d)\n", upstream.URL, max(upstream.Weight, 1))
+		}
+	} else if cfg.TokenFile != "" {
 		fmt.Printf("Token Content: %s...\n", cfg.TokenFile[:min(20, len(cfg.TokenFile))])
 	} else {
 		fmt.Println("Token Content: (will be saved after OAuth)")
@@ -96,6 +137,9 @@ func main() {
 	fmt.Println("OpenAI Compatible:")
 	fmt.Println("  GET  /v1/models              - List models (OpenAI format)")
 	fmt.Println("  POST /v1/chat/completions    - Chat completions (OpenAI format)")
+	fmt.Println("  POST /v1/chat/completions:parallel - Fan out one prompt to multiple models")
+	fmt.Println("  POST /v1/async/chat/completions - Submit async chat completion (returns job ID)")
+	fmt.Println("  GET  /v1/async/{id}          - Poll async job status and result")
 	fmt.Println("\nGemini Native (v1beta standard):")
 	fmt.Println("  GET  /v1beta/models          - List models (Gemini format)")
 	fmt.Println("  POST /v1beta/models/{model}:generateContent      - Generate content")
@@ -106,15 +150,41 @@ func main() {
 	fmt.Println("  POST /gemini/v1/models/{model}/streamGenerateContent - Stream generate")
 	fmt.Println("\nVertex AI:")
 	fmt.Println("  POST /vertex/v1/projects/{project}/locations/{location}/publishers/google/models/{model}:generateContent - Vertex AI generate")
+	fmt.Println("  GET/POST /vertex/v1/projects/{project}/locations/{location}/tuningJobs[/{job}] - Tuning jobs passthrough")
+	fmt.Println("  GET  /vertex/v1/projects/{project}/locations/{location}/operations/{operation} - Long-running operation status")
+	fmt.Println("\nUtilities:")
+	fmt.Println("  POST /utils/tokenize         - Token count and approximate token boundaries")
+	if cfg.Router != nil {
+		fmt.Println("\nCluster (router mode):")
+		fmt.Println("  POST /cluster/register       - Instance registration and heartbeat (X-Cluster-Secret)")
+		fmt.Println("  GET  /admin/cluster          - Fleet status (admin key)")
+		fmt.Println("  POST /admin/cluster/drain    - Drain or restore an instance (admin key)")
+	}
 	fmt.Println("\nOther:")
 	fmt.Println("  GET  /health                 - Health check")
 	fmt.Println("  OPTIONS *                    - CORS preflight")
+	if cfg.AdminListen != "" {
+		fmt.Printf("\nAdmin listener: http://%s (/health, /ready, /admin/*)\n", cfg.AdminListen)
+	}
 	fmt.Println()
 
 	// 监听系统信号
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 
+	// SIGHUP 重新加载配置文件
+	if configFile != "" {
+		hupChan := make(chan os.Signal, 1)
+		signal.Notify(hupChan, syscall.SIGHUP)
+		go func() {
+			for range hupChan {
+				if err := proxy.ReloadConfig(); err != nil {
+					log.Printf("Config reload failed: %v", err)
+				}
+			}
+		}()
+	}
+
 	// 启动服务器
 	errChan := make(chan error, 1)
 	go func() {
@@ -125,15 +195,22 @@ func main() {
 	// 等待信号或错误
 	select {
 	case <-sigChan:
-		fmt.Println("\nReceived shutdown signal, stopping server...")
-		cancel()
-		
-		// 等待服务器优雅关闭
-		shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 5*time.Second)
+		fmt.Printf("\nReceived shutdown signal, draining in-flight requests (up to %s, signal again to force)...\n", cfg.GetShutdownTimeout())
+
+		// 等待进行中的请求完成，再次收到信号时立即退出
+		go func() {
+			<-sigChan
+			fmt.Println("Forced shutdown.")
+			os.Exit(1)
+		}()
+
+		shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), cfg.GetShutdownTimeout())
 		defer shutdownCancel()
-		
-		<-shutdownCtx.Done()
-		proxy.Stop()
+		if err := proxy.Shutdown(shutdownCtx); err != nil {
+			fmt.Printf("Shutdown incomplete: %v\n", err)
+		}
+		cancel()
+		<-errChan
 		fmt.Println("Server stopped.")
 		
 	case serverErr := <-errChan:
@@ -156,6 +233,260 @@ func createDefaultConfig() *config.Config {
 	return cfg
 }
 
+// parseProfileFlag 从参数中取出 --profile，未指定时使用 GEMINI_PROFILE 环境变量
+func parseProfileFlag(args []string) ([]string, string) {
+	profile := os.Getenv("GEMINI_PROFILE")
+	var rest []string
+	for i := 0; i < len(args); i++ {
+		switch {
+		case args[i] == "--profile" && i+1 < len(args):
+			profile = args[i+1]
+			i++
+		case strings.HasPrefix(args[i], "--profile="):
+			profile = strings.TrimPrefix(args[i], "--profile=")
+		default:
+			rest = append(rest, args[i])
+		}
+	}
+	return rest, profile
+}
+
+// loadLocalConfig 加载本地配置文件（可选叠加profile），补全默认值后写回
+func loadLocalConfig(configFile, profile string) *config.Config {
+	// 检查配置文件是否存在
+	if _, err := os.Stat(configFile); os.IsNotExist(err) {
+		log.Fatalf("Config file not found: %s", configFile)
+	}
+
+	// 从配置文件加载配置
+	cfg, err := config.LoadConfigProfile(configFile, profile)
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+
+	// 旧版本配置升级前保留原文件备份
+	if cfg.ConfigVersion < config.CurrentConfigVersion {
+		backup := config.BackupFileName(configFile, time.Now())
+		if data, err := os.ReadFile(configFile); err == nil && os.WriteFile(backup, data, 0600) == nil {
+			fmt.Printf("Upgrading config to version %d, original saved to: %s\n", config.CurrentConfigVersion, backup)
+		}
+	}
+
+	// 填充缺失的默认值
+	if cfg.FillDefaults() {
+		fmt.Println("Some configuration values were missing, filled with defaults...")
+		// 保存更新后的配置文件
+		if err := cfg.SaveConfig(configFile); err != nil {
+			fmt.Printf("Warning: Failed to save updated config to %s: %v\n", configFile, err)
+		} else {
+			fmt.Printf("Updated configuration saved to: %s\n", configFile)
+		}
+	}
+	if profile != "" {
+		fmt.Printf("Using config profile: %s\n", profile)
+	}
+	return cfg
+}
+
+// pruneBackups 按配置的保留策略清理配置文件备份
+func pruneBackups(args []string, profile string) {
+	configFile := "config.json"
+	if len(args) > 0 {
+		configFile = args[0]
+	}
+
+	cfg, err := config.LoadConfigProfile(configFile, profile)
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+	removed, err := config.PruneConfigBackups(configFile, cfg.ConfigBackups)
+	if err != nil {
+		log.Fatalf("Failed to prune config backups: %v", err)
+	}
+	for _, backup := range removed {
+		fmt.Printf("Removed %s\n", backup)
+	}
+	fmt.Printf("Pruned %d config backup(s)\n", len(removed))
+}
+
+// shardConfig 将多账号/多代理配置拆分为多个实例配置和前置路由配置
+func shardConfig(args []string, profile string) {
+	flags := flag.NewFlagSet("shard", flag.ExitOnError)
+	instances := flags.Int("instances", 0, "number of instances (default: max of accounts and proxies)")
+	basePort := flags.Int("base-port", 0, "port of the first instance (default: router port + 1)")
+	outDir := flags.String("out", ".", "directory to write the generated configs to")
+	flags.Parse(args)
+
+	configFile := "config.json"
+	if flags.NArg() > 0 {
+		configFile = flags.Arg(0)
+	}
+
+	cfg, err := config.LoadConfigProfile(configFile, profile)
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+	shards, router, err := config.Shard(cfg, config.ShardOptions{Instances: *instances, BasePort: *basePort})
+	if err != nil {
+		log.Fatalf("Failed to shard config: %v", err)
+	}
+
+	if err := os.MkdirAll(*outDir, 0755); err != nil {
+		log.Fatalf("Failed to create output directory: %v", err)
+	}
+	for i, shard := range shards {
+		shardFile := filepath.Join(*outDir, fmt.Sprintf("instance-%d.json", i+1))
+		if err := shard.SaveConfig(shardFile); err != nil {
+			log.Fatalf("Failed to write %s: %v", shardFile, err)
+		}
+		fmt.Printf("Instance %d (port %d): %s\n", i+1, shard.Port, shardFile)
+	}
+	routerFile := filepath.Join(*outDir, "router.json")
+	if err := router.SaveConfig(routerFile); err != nil {
+		log.Fatalf("Failed to write %s: %v", routerFile, err)
+	}
+	fmt.Printf("Router (port %d): %s\n", router.Port, routerFile)
+}
+
+// verifyAuditLog 校验审计日志的哈希链和检查点签名，签名密钥取自配置文件或 GEMINI_AUDIT_KEY
+func verifyAuditLog(args []string, profile string) {
+	if len(args) < 1 {
+		log.Fatalf("Usage: audit verify <audit-log> [config-file]")
+	}
+
+	key := os.Getenv("GEMINI_AUDIT_KEY")
+	if len(args) > 1 {
+		cfg, err := config.LoadConfigProfile(args[1], profile)
+		if err != nil {
+			log.Fatalf("Failed to load config: %v", err)
+		}
+		if cfg.AuditLog != nil && cfg.AuditLog.CheckpointKey != "" {
+			key = cfg.AuditLog.CheckpointKey
+		}
+	}
+	if key == "" {
+		fmt.Println("Warning: no checkpoint key given, checkpoint signatures are not verified")
+	}
+
+	file, err := os.Open(args[0])
+	if err != nil {
+		log.Fatalf("Failed to open audit log: %v", err)
+	}
+	defer file.Close()
+	records, err := audit.Verify(file, []byte(key))
+	if err != nil {
+		log.Fatalf("%v (%d record(s) verified before the failure)", err, records)
+	}
+	fmt.Printf("Audit log OK: %d record(s) verified\n", records)
+}
+
+// exportToken 将配置中保存的OAuth token导出为gemini-cli可直接使用的凭据文件
+func exportToken(args []string, profile string) {
+	flags := flag.NewFlagSet("token export", flag.ExitOnError)
+	format := flags.String("format", auth.CredentialsGeminiCLI, "output format (only gemini-cli is supported)")
+	out := flags.String("out", auth.GeminiCLICredentialsPath(), "file to write, - for stdout")
+	account := flags.Int("account", 1, "account to export when the config holds several tokens")
+	force := flags.Bool("force", false, "overwrite an existing credentials file")
+	flags.Parse(args)
+
+	if *format != auth.CredentialsGeminiCLI {
+		log.Fatalf("Unsupported export format: %s", *format)
+	}
+	configFile := "config.json"
+	if flags.NArg() > 0 {
+		configFile = flags.Arg(0)
+	}
+
+	cfg, err := config.LoadConfigProfile(configFile, profile)
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+	tokens := cfg.AccountTokens()
+	if len(tokens) == 0 {
+		log.Fatalf("No OAuth token stored in %s, run the proxy once to complete the OAuth flow", configFile)
+	}
+	if *account < 1 || *account > len(tokens) {
+		log.Fatalf("Account %d does not exist, %s holds %d token(s)", *account, configFile, len(tokens))
+	}
+	data, err := auth.ExportGeminiCLICredentials(tokens[*account-1])
+	if err != nil {
+		log.Fatalf("Failed to export token: %v", err)
+	}
+
+	if *out == "-" {
+		fmt.Println(string(data))
+		return
+	}
+	if _, err := os.Stat(*out); err == nil && !*force {
+		log.Fatalf("%s already exists, use --force to overwrite it", *out)
+	}
+	if err := os.MkdirAll(filepath.Dir(*out), 0700); err != nil {
+		log.Fatalf("Failed to create directory: %v", err)
+	}
+	if err := os.WriteFile(*out, data, 0600); err != nil {
+		log.Fatalf("Failed to write %s: %v", *out, err)
+	}
+	fmt.Printf("Exported account %d to %s\n", *account, *out)
+}
+
+// testProxies 测试配置中每个代理到上游端点的连通性并输出表格，有代理不可用时以状态码1退出
+func testProxies(args []string, profile string) {
+	configFile := "config.json"
+	if len(args) > 0 {
+		configFile = args[0]
+	}
+
+	cfg, err := config.LoadConfigProfile(configFile, profile)
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+	if len(cfg.ProxyURLs) == 0 {
+		fmt.Printf("No proxy_urls configured in %s\n", configFile)
+		return
+	}
+
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	geminiClient := client.NewGeminiClient(cfg, nil, logger)
+	if client.WriteProxyReport(os.Stdout, geminiClient.TestProxies(context.Background())) > 0 {
+		os.Exit(1)
+	}
+}
+
+// generateDeploy 生成容器镜像和托管平台配置文件
+func generateDeploy(args []string) {
+	flags := flag.NewFlagSet("generate deploy", flag.ExitOnError)
+	target := flags.String("target", deploy.TargetDocker, "deploy target: "+strings.Join(deploy.Targets(), ", "))
+	outDir := flags.String("out", "deploy", "directory to write the generated files to")
+	app := flags.String("app", "", "app/service name (default gemini-proxy)")
+	port := flags.Int("port", 0, "container port passed via PORT (default 8080)")
+	version := flags.String("version", "", "gemini-go-proxy version to build (default latest)")
+	region := flags.String("region", "", "fly.io/Cloud Run region")
+	image := flags.String("image", "", "Cloud Run container image")
+	force := flags.Bool("force", false, "overwrite existing files")
+	flags.Parse(args)
+
+	files, err := deploy.Generate(*target, deploy.Options{
+		App:     *app,
+		Port:    *port,
+		Version: *version,
+		Region:  *region,
+		Image:   *image,
+	})
+	if err != nil {
+		log.Fatalf("Failed to generate deploy files: %v", err)
+	}
+	written, err := deploy.Write(*outDir, files, *force)
+	if err != nil {
+		log.Fatalf("%v (use --force to overwrite)", err)
+	}
+	for _, path := range written {
+		fmt.Printf("Wrote %s\n", path)
+	}
+	fmt.Println("\nSet GEMINI_API_KEYS and GEMINI_TOKEN_FILE (the token_file value from a local config) on the platform,")
+	fmt.Println("or point GEMINI_CONFIG at a config file or https:// URL.")
+}
+
 func min(a, b int) int {
 	if a < b {
 		return a
@@ -168,10 +499,18 @@ func printUsage() {
 	fmt.Println("====================================")
 	fmt.Println()
 	fmt.Println("Usage:")
-	fmt.Printf("  %s [config-file]\n", os.Args[0])
+	fmt.Printf("  %s [--profile name] [config-file]\n", os.Args[0])
+	fmt.Printf("  %s config backups prune [config-file]\n", os.Args[0])
+	fmt.Printf("  %s shard [--instances N] [--base-port P] [--out dir] [config-file]\n", os.Args[0])
+	fmt.Printf("  %s audit verify <audit-log> [config-file]\n", os.Args[0])
+	fmt.Printf("  %s generate deploy [--target docker|fly|railway|cloudrun] [--out dir] [--app name] [--force]\n", os.Args[0])
+	fmt.Printf("  %s token export --format gemini-cli [--out file|-] [--account N] [--force] [config-file]\n", os.Args[0])
+	fmt.Printf("  %s proxies test [config-file]\n", os.Args[0])
 	fmt.Println()
 	fmt.Println("Arguments:")
-	fmt.Println("  config-file    Path to JSON configuration file (optional)")
+	fmt.Println("  config-file    Path to JSON configuration file, or an https:// URL (optional)")
+	fmt.Println("                 Remote configs are refreshed via ETag; set GEMINI_CONFIG_KEY to decrypt them")
+	fmt.Println("  --profile      Apply the named entry of the config's \"profiles\" section (or GEMINI_PROFILE)")
 	fmt.Println()
 	fmt.Println("Default Mode (no config file):")
 	fmt.Printf("  %s\n", os.Args[0])
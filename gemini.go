@@ -4,17 +4,30 @@ import (
 	"context"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
+	"net"
 	"net/http"
 	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
+	"unicode"
 
+	"github.com/ba0gu0/gemini-go-proxy/pkg/audit"
 	"github.com/ba0gu0/gemini-go-proxy/pkg/auth"
 	"github.com/ba0gu0/gemini-go-proxy/pkg/client"
+	"github.com/ba0gu0/gemini-go-proxy/pkg/cluster"
 	"github.com/ba0gu0/gemini-go-proxy/pkg/config"
+	"github.com/ba0gu0/gemini-go-proxy/pkg/diagnostics"
 	"github.com/ba0gu0/gemini-go-proxy/pkg/handler"
+	"github.com/ba0gu0/gemini-go-proxy/pkg/jobs"
 	"github.com/ba0gu0/gemini-go-proxy/pkg/models"
+	"github.com/ba0gu0/gemini-go-proxy/pkg/store"
+	"github.com/ba0gu0/gemini-go-proxy/pkg/tunnel"
 	"github.com/sirupsen/logrus"
 	"golang.org/x/oauth2"
 )
@@ -23,15 +36,50 @@ import (
 type GeminiProxy struct {
 	client     *client.GeminiClient
 	server     *handler.Server
+	jobs       *jobs.Queue
+	scheduler  *jobs.Scheduler
+	auditLog   *audit.Logger     // 未启用审计日志时为nil
+	requestLog *store.Store      // 未启用请求记录时为nil
+	tunnel     *tunnel.Tunnel    // OAuth授权期间的临时隧道，授权完成后关闭
+	transport  http.RoundTripper // 自定义传输层
 	config     *config.Config
 	configFile string
 	logger     *logrus.Logger
+
+	// 客户端创建前添加的拦截器，创建客户端时注册
+	interceptors []Interceptor
+
+	// 热加载时使用的配置profile
+	configProfile string
+	reloadMu      sync.Mutex
+
+	// 远程配置源及刷新间隔，未使用远程配置时为nil
+	remoteConfig  *config.RemoteSource
+	remoteRefresh time.Duration
+
+	// 前置路由模式的路由器，普通模式为nil
+	cluster *cluster.Router
+
+	// 运行中的HTTP服务器，Shutdown时用于排空连接
+	mu             sync.Mutex
+	httpServer     *http.Server
+	adminServer    *http.Server
+	stopBackground context.CancelFunc // 停止任务队列、调度器等后台协程
+	cancelRequests context.CancelFunc // 取消仍在进行的请求
+	shutdownOnce   sync.Once
+	shutdownDone   chan struct{}
+	shutdownErr    error
 }
 
 // Config 别名，保持向后兼容
 type Config = config.Config
 type GoogleAuthConfig = config.GoogleAuthConfig
 type APIMode = config.APIMode
+type ResponseFilter = config.ResponseFilter
+type Pipeline = config.Pipeline
+type PipelineVersion = config.PipelineVersion
+type Interceptor = client.Interceptor
+type InterceptorFuncs = client.InterceptorFuncs
 
 // API模式常量
 const (
@@ -58,8 +106,9 @@ func NewGeminiProxy(cfg *Config) *GeminiProxy {
 	}
 
 	return &GeminiProxy{
-		config: cfg,
-		logger: logger,
+		config:       cfg,
+		logger:       logger,
+		shutdownDone: make(chan struct{}),
 	}
 }
 
@@ -86,8 +135,21 @@ func (gp *GeminiProxy) SetConfigFile(configFile string) {
 	gp.configFile = configFile
 }
 
+// SetConfigProfile 设置热加载配置文件时叠加的profile
+func (gp *GeminiProxy) SetConfigProfile(profile string) {
+	gp.configProfile = profile
+}
+
 // backupConfigIfNeeded 如果现有配置文件包含token_file和project_id字段则备份
 func (gp *GeminiProxy) backupConfigIfNeeded() error {
+	// 仅内存保存token时不创建可能包含token的备份
+	if gp.config.EphemeralTokens {
+		return nil
+	}
+	if gp.config.ConfigBackups != nil && gp.config.ConfigBackups.Disabled {
+		return nil
+	}
+
 	// 检查配置文件是否存在
 	if _, err := os.Stat(gp.configFile); os.IsNotExist(err) {
 		return nil // 文件不存在，无需备份
@@ -107,12 +169,20 @@ func (gp *GeminiProxy) backupConfigIfNeeded() error {
 
 	// 如果现有配置包含token_file和project_id字段，则备份
 	if existingConfig.TokenFile != "" && existingConfig.ProjectID != "" {
-		timestamp := time.Now().Format("20060102_150405")
-		backupFile := fmt.Sprintf("%s.%s.bak", gp.configFile, timestamp)
+		backupFile := config.BackupFileName(gp.configFile, time.Now())
 		if err := ioutil.WriteFile(backupFile, data, 0644); err != nil {
 			return fmt.Errorf("failed to create backup file: %w", err)
 		}
 		gp.logger.Infof("Existing config with token and project_id backed up to: %s", backupFile)
+
+		// 按保留策略清理旧备份
+		removed, err := config.PruneConfigBackups(gp.configFile, gp.config.ConfigBackups)
+		if err != nil {
+			return err
+		}
+		if len(removed) > 0 {
+			gp.logger.Infof("Pruned %d old config backup(s)", len(removed))
+		}
 	}
 
 	return nil
@@ -132,6 +202,10 @@ func (gp *GeminiProxy) SaveTokenToConfig(googleAuth *auth.GoogleAuth) error {
 
 	// 更新配置
 	gp.config.TokenFile = tokenBase64
+	if gp.config.EphemeralTokens {
+		gp.logger.Info("Token kept in memory only (ephemeral_tokens enabled)")
+		return nil
+	}
 
 	// 如果有配置文件路径，保存配置
 	if gp.configFile != "" {
@@ -139,7 +213,7 @@ func (gp *GeminiProxy) SaveTokenToConfig(googleAuth *auth.GoogleAuth) error {
 		if err := gp.backupConfigIfNeeded(); err != nil {
 			gp.logger.Warnf("Failed to backup existing config: %v", err)
 		}
-		
+
 		if err := gp.config.SaveConfig(gp.configFile); err != nil {
 			return fmt.Errorf("failed to save config file: %w", err)
 		}
@@ -149,6 +223,21 @@ func (gp *GeminiProxy) SaveTokenToConfig(googleAuth *auth.GoogleAuth) error {
 	return nil
 }
 
+// saveAccountTokens 保存管理接口修改后的账号token
+func (gp *GeminiProxy) saveAccountTokens() error {
+	if gp.config.EphemeralTokens || gp.configFile == "" {
+		return nil
+	}
+	if err := gp.backupConfigIfNeeded(); err != nil {
+		gp.logger.Warnf("Failed to backup existing config: %v", err)
+	}
+	if err := gp.config.SaveConfig(gp.configFile); err != nil {
+		return fmt.Errorf("failed to save config file: %w", err)
+	}
+	gp.logger.Infof("OAuth accounts saved to config file: %s", gp.configFile)
+	return nil
+}
+
 // SaveTokenAndClientIDToConfig 保存Google client ID和token到配置文件（OAuth成功后调用）
 func (gp *GeminiProxy) SaveTokenAndClientIDToConfig(clientID string, token interface{}) error {
 	// 将token转换为base64
@@ -166,6 +255,10 @@ func (gp *GeminiProxy) SaveTokenAndClientIDToConfig(clientID string, token inter
 	// 更新配置，使用Google的实际client ID
 	// ClientID is now hardcoded in auth package
 	gp.config.TokenFile = tokenBase64
+	if gp.config.EphemeralTokens {
+		gp.logger.Info("Token kept in memory only (ephemeral_tokens enabled)")
+		return nil
+	}
 
 	// 如果有配置文件路径，保存配置
 	if gp.configFile != "" {
@@ -173,7 +266,7 @@ func (gp *GeminiProxy) SaveTokenAndClientIDToConfig(clientID string, token inter
 		if err := gp.backupConfigIfNeeded(); err != nil {
 			gp.logger.Warnf("Failed to backup existing config: %v", err)
 		}
-		
+
 		if err := gp.config.SaveConfig(gp.configFile); err != nil {
 			return fmt.Errorf("failed to save config file: %w", err)
 		}
@@ -202,6 +295,13 @@ func (gp *GeminiProxy) InitializeWithCredentials(ctx context.Context, authConfig
 
 	gp.logger.Info("Initializing Gemini proxy with provided credentials")
 
+	// 解析 env:/file:/exec: 密钥引用，不修改调用方的配置
+	resolved := *authConfig
+	if err := resolved.ResolveSecrets(); err != nil {
+		return fmt.Errorf("failed to resolve credentials: %w", err)
+	}
+	authConfig = &resolved
+
 	// 创建Google认证
 	googleAuth := auth.NewGoogleAuth(&models.GoogleAuthConfig{
 		CredentialsPath:      authConfig.CredentialsFile,
@@ -218,51 +318,84 @@ func (gp *GeminiProxy) InitializeWithCredentials(ctx context.Context, authConfig
 	// Google认证已配置完成
 
 	// 创建Gemini客户端
-	gp.client = client.NewGeminiClient(gp.config, googleAuth, gp.logger)
+	if err := gp.setupClient(googleAuth); err != nil {
+		return err
+	}
 
 	// 创建服务器
-	serverConfig := &handler.ServerConfig{
-		Host:         gp.config.Host,
-		Port:         gp.config.Port,
-		ReadTimeout:  300 * time.Second,
-		WriteTimeout: 300 * time.Second,
-		EnableCORS:   gp.config.EnableCORS,
-		APIKeys:      gp.config.APIKeys, // 传递客户端API密钥
-	}
+	gp.server = handler.NewServer(gp.client, gp.newServerConfig(), gp.logger)
 
-	gp.server = handler.NewServer(gp.client, serverConfig, gp.logger)
+	// 创建任务队列
+	if err := gp.setupJobQueue(); err != nil {
+		return err
+	}
 
 	gp.logger.Info("Gemini proxy initialized successfully with credentials")
 	return nil
 }
 
+// InitializeRouter 以前置路由模式初始化，不需要Google认证，请求被分发到配置的下游代理实例
+func (gp *GeminiProxy) InitializeRouter() error {
+	router, err := cluster.NewRouter(gp.config.Router, gp.logger)
+	if err != nil {
+		return fmt.Errorf("failed to create router: %w", err)
+	}
+
+	gp.cluster = router
+	gp.server = handler.NewClusterServer(router, gp.newServerConfig(), gp.logger)
+	gp.logger.Infof("Gemini proxy initialized in router mode with %d upstream(s)", len(gp.config.Router.Upstreams))
+	return nil
+}
+
 // InitializeWithGoogleAuth 使用Google OAuth初始化（本地运行模式）
 func (gp *GeminiProxy) InitializeWithGoogleAuth(ctx context.Context) error {
 	gp.logger.Info("Initializing Gemini proxy with Google OAuth authentication")
 
-	// 创建默认的Google认证配置
+	// 创建默认的Google认证配置，token_file和oauth_tokens中的所有账号组成轮询池
+	tokens := gp.config.AccountTokens()
 	googleAuth := auth.NewGoogleAuth(&models.GoogleAuthConfig{
-		RedirectURL: gp.config.GetRedirectURL(),
-		ProjectID:   gp.config.ProjectID,
-		Location:    gp.config.Location,
-		OAuthTokens: []string{gp.config.TokenFile},
+		RedirectURL:     gp.config.GetRedirectURL(),
+		ProjectID:       gp.config.ProjectID,
+		Location:        gp.config.Location,
+		OAuthTokens:     tokens,
+		CredentialFiles: gp.config.CredentialFiles,
+		AccountDailyCap: gp.config.AccountDailyCap,
+		QuotaTimezone:   gp.config.QuotaTimezone,
 	}, gp.logger)
 
 	// 设置token接收回调，在OAuth成功后保存配置
 	googleAuth.SetOnTokenReceived(func(clientID string, token *oauth2.Token, googleAuth *auth.GoogleAuth) error {
+		defer gp.closeOAuthTunnel(oauthTunnelGracePeriod)
 		return gp.SaveTokenClientIDAndProjectID(clientID, token, googleAuth)
 	})
 
+	// 通过管理接口增删账号后同步oauth_tokens，重启后账号池保持一致
+	googleAuth.SetOnAccountAdded(func(tokenBase64 string) error {
+		gp.config.OAuthTokens = append(gp.config.OAuthTokens, tokenBase64)
+		return gp.saveAccountTokens()
+	})
+	googleAuth.SetOnAccountRemoved(func(tokenBase64 string) error {
+		gp.config.OAuthTokens = slices.DeleteFunc(gp.config.OAuthTokens, func(token string) bool {
+			return strings.TrimSpace(token) == tokenBase64
+		})
+		gp.config.TokenFile = strings.Join(slices.DeleteFunc(strings.FieldsFunc(gp.config.TokenFile, func(r rune) bool {
+			return r == ',' || unicode.IsSpace(r)
+		}), func(token string) bool {
+			return token == tokenBase64
+		}), ",")
+		return gp.saveAccountTokens()
+	})
+
 	// 立即设置客户端和服务器，包括OAuth回调路由
 	if err := gp.setupClientAndServer(googleAuth); err != nil {
 		return err
 	}
 
-	// 检查是否有token_file字段
-	if gp.config.TokenFile != "" {
+	// 检查是否有token_file、oauth_tokens或credential_files字段
+	if len(tokens) > 0 || len(gp.config.CredentialFiles) > 0 {
 		gp.logger.Info("Found existing token content, attempting to load...")
 		if initErr := googleAuth.Initialize(ctx); initErr == nil {
-			gp.logger.Info("Successfully loaded existing token")
+			gp.logger.Infof("Successfully loaded existing token (%d account(s))", googleAuth.AccountCount())
 			// Token加载成功，检查是否需要发现项目ID
 			return gp.handleProjectIDDiscovery(googleAuth)
 		} else {
@@ -272,6 +405,16 @@ func (gp *GeminiProxy) InitializeWithGoogleAuth(ctx context.Context) error {
 
 	// token_file不存在或无效，需要进行OAuth认证
 	fmt.Println("\n=== Google OAuth Authentication Required ===")
+	if path := auth.GeminiCLICredentialsPath(); path != "" && len(gp.config.CredentialFiles) == 0 {
+		if _, err := os.Stat(path); err == nil {
+			fmt.Printf("Found gemini-cli credentials at %s; add \"credential_files\": [\"gemini-cli\"] to the config to use them instead.\n\n", path)
+		}
+	}
+	if gp.config.OAuthTunnel != "" {
+		if err := gp.startOAuthTunnel(ctx, googleAuth); err != nil {
+			return err
+		}
+	}
 	authURL := googleAuth.GenerateAuthURL()
 	fmt.Printf("Please visit the following URL to authorize the application:\n\n")
 	fmt.Printf("    %s\n\n", authURL)
@@ -282,6 +425,54 @@ func (gp *GeminiProxy) InitializeWithGoogleAuth(ctx context.Context) error {
 	return nil
 }
 
+// oauthTunnelGracePeriod 收到token后保留隧道的时间，确保授权成功页面能通过隧道返回给浏览器
+const oauthTunnelGracePeriod = 3 * time.Second
+
+// startOAuthTunnel 为本地服务建立临时公网隧道，并将OAuth回调地址指向隧道
+func (gp *GeminiProxy) startOAuthTunnel(ctx context.Context, googleAuth *auth.GoogleAuth) error {
+	host := gp.config.Host
+	if host == "" || host == "0.0.0.0" || host == "::" {
+		host = "127.0.0.1"
+	}
+	localURL := "http://" + net.JoinHostPort(host, strconv.Itoa(gp.config.Port))
+
+	fmt.Printf("Starting %s tunnel for the OAuth callback...\n", gp.config.OAuthTunnel)
+	t, err := tunnel.Start(ctx, gp.config.OAuthTunnel, localURL, gp.logger)
+	if err != nil {
+		return fmt.Errorf("failed to start OAuth tunnel: %w", err)
+	}
+	if err := googleAuth.SetRedirectBaseURL(t.URL); err != nil {
+		t.Close()
+		return err
+	}
+
+	gp.mu.Lock()
+	gp.tunnel = t
+	gp.mu.Unlock()
+	fmt.Printf("OAuth callback will be received via %s (closed after authorization)\n\n", t.URL)
+	return nil
+}
+
+// closeOAuthTunnel 在delay之后关闭OAuth临时隧道，delay不大于0时立即关闭
+func (gp *GeminiProxy) closeOAuthTunnel(delay time.Duration) {
+	gp.mu.Lock()
+	t := gp.tunnel
+	gp.tunnel = nil
+	gp.mu.Unlock()
+	if t == nil {
+		return
+	}
+	if delay <= 0 {
+		t.Close()
+		return
+	}
+
+	time.AfterFunc(delay, func() {
+		t.Close()
+		gp.logger.Info("OAuth tunnel closed")
+	})
+}
+
 // handleProjectIDDiscovery 处理项目ID发现逻辑
 func (gp *GeminiProxy) handleProjectIDDiscovery(googleAuth *auth.GoogleAuth) error {
 	// 如果已有项目ID，跳过发现过程
@@ -308,7 +499,7 @@ func (gp *GeminiProxy) handleProjectIDDiscovery(googleAuth *auth.GoogleAuth) err
 			if backupErr := gp.backupConfigIfNeeded(); backupErr != nil {
 				gp.logger.Warnf("Failed to backup existing config: %v", backupErr)
 			}
-			
+
 			if saveErr := gp.config.SaveConfig(gp.configFile); saveErr != nil {
 				gp.logger.WithError(saveErr).Error("Failed to save config file with blank project_id")
 			} else {
@@ -337,13 +528,13 @@ func (gp *GeminiProxy) handleProjectIDDiscovery(googleAuth *auth.GoogleAuth) err
 
 	// 保存发现的项目ID
 	if projectID != "" {
-		gp.config.ProjectID = projectID
+		gp.SetProjectID(projectID)
 		if gp.configFile != "" {
 			// 检查现有配置文件是否需要备份
 			if err := gp.backupConfigIfNeeded(); err != nil {
 				gp.logger.Warnf("Failed to backup existing config: %v", err)
 			}
-			
+
 			if err := gp.config.SaveConfig(gp.configFile); err != nil {
 				return fmt.Errorf("failed to save project ID to config file: %w", err)
 			}
@@ -356,30 +547,18 @@ func (gp *GeminiProxy) handleProjectIDDiscovery(googleAuth *auth.GoogleAuth) err
 
 // setupClientAndServer 设置客户端和服务器
 func (gp *GeminiProxy) setupClientAndServer(googleAuth *auth.GoogleAuth) error {
-	// 创建客户端配置
-	clientConfig := &config.Config{
-		APIMode:        config.APIMode(gp.config.APIMode),
-		ProjectID:      gp.config.ProjectID,
-		Location:       gp.config.Location,
-		TimeoutSeconds: gp.config.TimeoutSeconds,
-		MaxRetries:     gp.config.MaxRetries,
-		UserAgent:      gp.config.UserAgent,
-	}
-
 	// 创建Gemini客户端
-	gp.client = client.NewGeminiClient(clientConfig, googleAuth, gp.logger)
+	if err := gp.setupClient(googleAuth); err != nil {
+		return err
+	}
 
 	// 创建服务器
-	serverConfig := &handler.ServerConfig{
-		Host:         gp.config.Host,
-		Port:         gp.config.Port,
-		ReadTimeout:  300 * time.Second,
-		WriteTimeout: 300 * time.Second,
-		EnableCORS:   gp.config.EnableCORS,
-		APIKeys:      gp.config.APIKeys,
-	}
+	gp.server = handler.NewServer(gp.client, gp.newServerConfig(), gp.logger)
 
-	gp.server = handler.NewServer(gp.client, serverConfig, gp.logger)
+	// 创建任务队列
+	if err := gp.setupJobQueue(); err != nil {
+		return err
+	}
 
 	// 设置OAuth处理器
 	gp.server.SetOAuthHandler(googleAuth)
@@ -388,6 +567,84 @@ func (gp *GeminiProxy) setupClientAndServer(googleAuth *auth.GoogleAuth) error {
 	return nil
 }
 
+// setupClient 创建Gemini客户端并应用自定义传输层和拦截器，各初始化方式共用
+func (gp *GeminiProxy) setupClient(googleAuth *auth.GoogleAuth) error {
+	clientConfig, err := gp.newClientConfig()
+	if err != nil {
+		return err
+	}
+	gp.client = client.NewGeminiClient(clientConfig, googleAuth, gp.logger)
+	if gp.transport != nil {
+		gp.client.SetTransport(gp.transport)
+	}
+	for _, interceptor := range gp.interceptors {
+		gp.client.AddInterceptor(interceptor)
+	}
+	return nil
+}
+
+// newClientConfig 根据代理配置创建客户端配置：深拷贝完整配置，新增的配置项无需逐个传递，
+// 客户端运行时切换API模式、location等不会修改代理自身的配置，之后修改代理配置也不会与客户端并发读写
+func (gp *GeminiProxy) newClientConfig() (*config.Config, error) {
+	return gp.config.Clone()
+}
+
+// newServerConfig 根据代理配置创建服务器配置
+func (gp *GeminiProxy) newServerConfig() *handler.ServerConfig {
+	return &handler.ServerConfig{
+		Host:                 gp.config.Host,
+		Port:                 gp.config.Port,
+		ReadTimeout:          300 * time.Second,
+		WriteTimeout:         300 * time.Second,
+		EnableCORS:           gp.config.EnableCORS,
+		APIKeys:              gp.config.APIKeys, // 传递客户端API密钥
+		AdminAPIKeys:         gp.config.AdminAPIKeys,
+		StreamMetadataEvent:  gp.config.StreamMetadataEvent,
+		NativeReverseProxy:   gp.config.NativeReverseProxy,
+		TrustedProxies:       gp.config.TrustedProxies,
+		HMACKeys:             gp.config.HMACKeys,
+		HMACReplayWindow:     time.Duration(gp.config.HMACReplayWindowSeconds) * time.Second,
+		HMACMaxBodyBytes:     gp.config.HMACMaxBodyBytes,
+		KeyResponseLanguages: gp.config.KeyResponseLanguages,
+		KeyRateLimits:        gp.config.KeyRateLimits,
+		DisabledRoutes:       gp.config.DisabledRoutes,
+		ResponseHeaders:      gp.config.ResponseHeaders,
+		StreamResumeBuffer:   gp.config.StreamResumeBuffer,
+		StreamResumeTTL:      time.Duration(gp.config.StreamResumeTTLSeconds) * time.Second,
+		ModelMappings:        gp.config.ModelMappings,
+	}
+}
+
+// setupJobQueue 创建后台任务队列，配置了存储文件时会加载上次未完成的任务
+func (gp *GeminiProxy) setupJobQueue() error {
+	queue, err := jobs.NewQueue(gp.config.JobStoreFile, gp.config.JobWorkers, gp.logger)
+	if err != nil {
+		return fmt.Errorf("failed to initialize job queue: %w", err)
+	}
+	gp.jobs = queue
+	if retention := gp.config.Retention; retention != nil {
+		queue.SetRetention(time.Duration(retention.JobsHours) * time.Hour)
+	}
+
+	// 注册提示词任务并加载定时任务
+	webhooks := jobs.NewWebhookSender(gp.config.WebhookSecret, gp.config.WebhookMaxRetries, gp.logger)
+	queue.Register(jobs.KindPrompt, jobs.PromptHandler(gp.client, webhooks, gp.logger))
+	gp.server.SetJobQueue(queue, webhooks.Restricted(gp.config.WebhookAllowedHosts))
+	gp.scheduler = jobs.NewScheduler(queue, gp.logger)
+	for _, job := range gp.config.ScheduledJobs {
+		payload := &jobs.PromptPayload{
+			Name:    job.Name,
+			Model:   job.Model,
+			Prompt:  job.Prompt,
+			Webhook: job.Webhook,
+		}
+		if err := gp.scheduler.Add(job.Name, job.Schedule, jobs.KindPrompt, payload); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // InitializeWithDirectTokens 使用token base64内容初始化
 func (gp *GeminiProxy) InitializeWithDirectTokens(googleAuth *auth.GoogleAuth) error {
 	if gp.config.TokenFile == "" {
@@ -397,19 +654,17 @@ func (gp *GeminiProxy) InitializeWithDirectTokens(googleAuth *auth.GoogleAuth) e
 	gp.logger.Info("Initializing Gemini proxy with token content")
 
 	// 创建Gemini客户端
-	gp.client = client.NewGeminiClient(gp.config, googleAuth, gp.logger)
+	if err := gp.setupClient(googleAuth); err != nil {
+		return err
+	}
 
 	// 创建服务器
-	serverConfig := &handler.ServerConfig{
-		Host:         gp.config.Host,
-		Port:         gp.config.Port,
-		ReadTimeout:  300 * time.Second,
-		WriteTimeout: 300 * time.Second,
-		EnableCORS:   gp.config.EnableCORS,
-		APIKeys:      gp.config.APIKeys, // 传递客户端API密钥
-	}
+	gp.server = handler.NewServer(gp.client, gp.newServerConfig(), gp.logger)
 
-	gp.server = handler.NewServer(gp.client, serverConfig, gp.logger)
+	// 创建任务队列
+	if err := gp.setupJobQueue(); err != nil {
+		return err
+	}
 
 	gp.logger.Info("Gemini proxy initialized successfully with direct tokens")
 	return nil
@@ -421,17 +676,100 @@ func (gp *GeminiProxy) Start(ctx context.Context) error {
 		return fmt.Errorf("proxy not initialized")
 	}
 
+	// 启动自检，fail_fast时检查失败直接返回错误
+	if err := gp.runStartupChecks(ctx); err != nil {
+		return err
+	}
+	if err := gp.checkProxiesOnStartup(ctx); err != nil {
+		return err
+	}
+
+	// 审计日志无法打开时拒绝启动，避免在没有审计记录的情况下提供服务
+	if err := gp.openAuditLog(); err != nil {
+		return err
+	}
+	if gp.config.RequestLogFile != "" {
+		requestLog, err := store.Open(gp.config.RequestLogFile)
+		if err != nil {
+			return err
+		}
+		gp.requestLog = requestLog
+		gp.server.SetRequestLog(requestLog)
+		gp.logger.Infof("Recording requests to %s", gp.config.RequestLogFile)
+	}
+
 	gp.logger.Infof("Starting Gemini proxy server on %s:%d", gp.config.Host, gp.config.Port)
 
 	// 获取路由器
 	router := gp.server.GetRouter()
 
+	// 后台协程在关闭时先于进程退出停止；请求上下文独立于ctx，排空超时后才取消
+	ctx, stopBackground := context.WithCancel(ctx)
+	requestCtx, cancelRequests := context.WithCancel(context.Background())
+
 	// 创建HTTP服务器
 	server := &http.Server{
 		Addr:         fmt.Sprintf("%s:%d", gp.config.Host, gp.config.Port),
 		Handler:      router,
 		ReadTimeout:  300 * time.Second,
 		WriteTimeout: 300 * time.Second,
+		BaseContext:  func(net.Listener) context.Context { return requestCtx },
+	}
+
+	gp.mu.Lock()
+	gp.httpServer = server
+	gp.stopBackground = stopBackground
+	gp.cancelRequests = cancelRequests
+	gp.mu.Unlock()
+
+	// 预热完成后才报告就绪
+	if gp.config.WarmupOnStart && gp.client != nil {
+		gp.server.SetReady(false)
+		go gp.warmup(ctx)
+	}
+
+	// 启动任务队列，恢复重启前未完成的任务
+	if gp.jobs != nil {
+		gp.jobs.Start(ctx)
+	}
+	if gp.scheduler != nil && gp.scheduler.Len() > 0 {
+		gp.logger.Infof("Starting %d scheduled job(s)", gp.scheduler.Len())
+		gp.scheduler.Start(ctx)
+	}
+
+	// 前置路由模式启动下游健康检查
+	if gp.cluster != nil {
+		gp.cluster.Start(ctx)
+	}
+
+	// 向前置路由注册本实例
+	if err := gp.startClusterAgent(ctx); err != nil {
+		gp.logger.WithError(err).Warn("Cluster registration disabled")
+	}
+
+	// 定期探测代理池，剔除连续失败的代理
+	if gp.config.ProxyHealthCheck != nil && gp.client != nil {
+		go gp.client.RunProxyHealthChecks(ctx)
+	}
+
+	// 按保留策略定期清理落盘数据
+	if gp.config.Retention != nil {
+		go gp.purgeExpiredData(ctx)
+	}
+
+	// 长时间运行时检测资源泄漏
+	if detector := gp.config.LeakDetector; detector != nil {
+		diagnostics.NewLeakDetector(time.Duration(detector.IntervalSeconds)*time.Second, detector.Window, gp.logger).Start(ctx)
+	}
+
+	// 监视本地配置文件变化
+	if gp.config.WatchConfig && gp.configFile != "" {
+		go gp.watchConfigFile(ctx)
+	}
+
+	// 定期刷新远程配置
+	if gp.remoteConfig != nil {
+		go gp.watchRemoteConfig(ctx)
 	}
 
 	// 在goroutine中启动服务器
@@ -440,6 +778,25 @@ func (gp *GeminiProxy) Start(ctx context.Context) error {
 		errChan <- server.ListenAndServe()
 	}()
 
+	// 管理接口使用独立监听，不与推理流量竞争连接
+	if gp.config.AdminListen != "" {
+		adminServer := &http.Server{
+			Addr:         gp.config.AdminListen,
+			Handler:      gp.server.AdminHandler(),
+			ReadTimeout:  10 * time.Second,
+			WriteTimeout: 10 * time.Second,
+		}
+		gp.mu.Lock()
+		gp.adminServer = adminServer
+		gp.mu.Unlock()
+		gp.logger.Infof("Starting admin listener on %s", gp.config.AdminListen)
+		go func() {
+			if err := adminServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				errChan <- fmt.Errorf("admin listener: %w", err)
+			}
+		}()
+	}
+
 	// 获取OAuth致命错误通道（如果存在）
 	var fatalErrorChan <-chan error
 	if oauthHandler := gp.server.GetOAuthHandler(); oauthHandler != nil {
@@ -451,35 +808,444 @@ func (gp *GeminiProxy) Start(ctx context.Context) error {
 	// 等待上下文取消、服务器错误或OAuth致命错误
 	select {
 	case <-ctx.Done():
-		gp.logger.Info("Shutting down Gemini proxy server...")
-		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), gp.config.GetShutdownTimeout())
 		defer cancel()
-		return server.Shutdown(shutdownCtx)
+		return gp.Shutdown(shutdownCtx)
 	case err := <-errChan:
 		if err != http.ErrServerClosed {
+			gp.closeServers()
 			return fmt.Errorf("server failed to start: %w", err)
 		}
-		return nil
+		// 由Shutdown关闭，等待排空完成后返回
+		<-gp.shutdownDone
+		return gp.shutdownErr
 	case fatalErr := <-fatalErrorChan:
 		if fatalErr != nil {
 			gp.logger.WithError(fatalErr).Error("Received fatal error from OAuth handler")
+			gp.closeServers()
 			return fatalErr
 		}
 	}
 	return nil
 }
 
-// Stop 停止代理服务器
-func (gp *GeminiProxy) Stop() error {
+// Shutdown 优雅关闭代理：/ready 先返回503，停止接收新连接并等待进行中的请求（含流式响应）完成，
+// 随后停止后台任务并持久化任务队列状态。ctx结束时仍未完成的请求会被取消并强制关闭连接。
+// 重复调用返回首次关闭的结果
+func (gp *GeminiProxy) Shutdown(ctx context.Context) error {
+	gp.shutdownOnce.Do(func() {
+		gp.shutdownErr = gp.shutdown(ctx)
+		close(gp.shutdownDone)
+	})
+	return gp.shutdownErr
+}
+
+// shutdown 执行实际的关闭流程
+func (gp *GeminiProxy) shutdown(ctx context.Context) error {
+	gp.mu.Lock()
+	server, adminServer := gp.httpServer, gp.adminServer
+	stopBackground, cancelRequests := gp.stopBackground, gp.cancelRequests
+	gp.mu.Unlock()
+
+	gp.logger.Info("Shutting down Gemini proxy server, draining in-flight requests...")
+	if gp.server != nil {
+		gp.server.SetDraining(true)
+	}
+
+	var errs []error
+	if server != nil {
+		err := server.Shutdown(ctx)
+		cancelRequests()
+		if err != nil {
+			gp.logger.WithError(err).Warn("Drain deadline reached, cancelled remaining requests")
+			server.Close()
+			errs = append(errs, fmt.Errorf("drain in-flight requests: %w", err))
+		}
+	}
+	// 管理接口在排空期间保持可用，便于观察 /ready 状态
+	if adminServer != nil {
+		if err := adminServer.Shutdown(ctx); err != nil {
+			adminServer.Close()
+		}
+	}
+
+	if stopBackground != nil {
+		stopBackground()
+	}
+	gp.closeOAuthTunnel(0)
+	// 请求排空后写入最终检查点
+	if gp.auditLog != nil {
+		if err := gp.auditLog.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("close audit log: %w", err))
+		}
+	}
+	if gp.requestLog != nil {
+		if err := gp.requestLog.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("close request log: %w", err))
+		}
+	}
+	if gp.jobs != nil {
+		// 排空超时后仍给任务队列留出写入状态的时间
+		waitCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+		defer cancel()
+		if err := gp.jobs.Wait(waitCtx); err != nil {
+			errs = append(errs, fmt.Errorf("persist job queue: %w", err))
+		}
+	}
+
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
 	gp.logger.Info("Gemini proxy stopped")
 	return nil
 }
 
+// closeServers 启动失败时立即关闭已启动的监听和后台协程
+func (gp *GeminiProxy) closeServers() {
+	gp.mu.Lock()
+	defer gp.mu.Unlock()
+	if gp.adminServer != nil {
+		gp.adminServer.Close()
+	}
+	if gp.httpServer != nil {
+		gp.httpServer.Close()
+	}
+	if gp.stopBackground != nil {
+		gp.stopBackground()
+	}
+	if gp.cancelRequests != nil {
+		gp.cancelRequests()
+	}
+	if gp.auditLog != nil {
+		gp.auditLog.Close()
+	}
+	if gp.requestLog != nil {
+		gp.requestLog.Close()
+	}
+	if gp.tunnel != nil {
+		gp.tunnel.Close()
+		gp.tunnel = nil
+	}
+}
+
+// purgeExpiredData 按保留策略定期清理过期的任务和审计记录，启动时先执行一次
+func (gp *GeminiProxy) purgeExpiredData(ctx context.Context) {
+	retention := gp.config.Retention
+	interval := time.Duration(retention.PurgeIntervalMinutes) * time.Minute
+	if interval <= 0 {
+		interval = time.Hour
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if gp.jobs != nil {
+			if removed, err := gp.jobs.Purge(); err != nil {
+				gp.logger.WithError(err).Warn("Failed to purge expired jobs")
+			} else if removed > 0 {
+				gp.logger.Infof("Purged %d expired job(s)", removed)
+			}
+		}
+		if gp.auditLog != nil && retention.AuditLogDays > 0 {
+			before := time.Now().AddDate(0, 0, -retention.AuditLogDays)
+			if _, err := gp.auditLog.Purge(before); err != nil {
+				gp.logger.WithError(err).Warn("Failed to purge expired audit log records")
+			}
+		}
+		if gp.requestLog != nil && retention.RequestLogDays > 0 {
+			before := time.Now().AddDate(0, 0, -retention.RequestLogDays)
+			if removed, err := gp.requestLog.Purge(before); err != nil {
+				gp.logger.WithError(err).Warn("Failed to purge expired request records")
+			} else if removed > 0 {
+				gp.logger.Infof("Purged %d expired request record(s)", removed)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// openAuditLog 按配置打开请求审计日志并交给服务器记录
+func (gp *GeminiProxy) openAuditLog() error {
+	cfg := gp.config.AuditLog
+	if cfg == nil || cfg.Path == "" {
+		return nil
+	}
+
+	auditLog, err := audit.Open(cfg.Path, audit.Options{
+		HashChain:          cfg.HashChain,
+		CheckpointEvery:    cfg.CheckpointEvery,
+		CheckpointInterval: time.Duration(cfg.CheckpointIntervalSeconds) * time.Second,
+		CheckpointKey:      []byte(cfg.CheckpointKey),
+	}, gp.logger)
+	if err != nil {
+		return err
+	}
+	gp.auditLog = auditLog
+	gp.server.SetAuditLog(auditLog)
+	gp.logger.Infof("Writing audit log to %s (hash chain: %v)", cfg.Path, cfg.HashChain)
+	return nil
+}
+
+// runStartupChecks 执行配置的启动自检，仅在fail_fast时返回错误
+func (gp *GeminiProxy) runStartupChecks(ctx context.Context) error {
+	checks := gp.config.StartupChecks
+	if checks == nil || gp.client == nil {
+		return nil
+	}
+
+	timeout := 30 * time.Second
+	if checks.TimeoutSeconds > 0 {
+		timeout = time.Duration(checks.TimeoutSeconds) * time.Second
+	}
+	checkCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	if _, err := gp.client.RunStartupChecks(checkCtx, checks); err != nil {
+		if checks.FailFast {
+			return fmt.Errorf("startup checks failed: %w", err)
+		}
+		gp.logger.WithError(err).Warn("Startup checks failed, continuing because fail_fast is disabled")
+	}
+	return nil
+}
+
+// checkProxiesOnStartup 测试每个代理的连通性并输出报告，策略为fail且有代理不可用时返回错误
+func (gp *GeminiProxy) checkProxiesOnStartup(ctx context.Context) error {
+	policy := gp.config.ProxyStartupCheck
+	if policy == config.ProxyStartupCheckOff || gp.client == nil || len(gp.config.ProxyURLs) == 0 {
+		return nil
+	}
+
+	var report strings.Builder
+	dead := client.WriteProxyReport(&report, gp.client.TestProxies(ctx))
+	if dead == 0 {
+		gp.logger.Infof("Proxy connectivity report:\n%s", report.String())
+		return nil
+	}
+	if policy == config.ProxyStartupCheckFail {
+		gp.logger.Errorf("Proxy connectivity report:\n%s", report.String())
+		return fmt.Errorf("%d of %d proxies are unreachable", dead, len(gp.config.ProxyURLs))
+	}
+	gp.logger.Warnf("Proxy connectivity report:\n%s", report.String())
+	return nil
+}
+
+// warmup 预热客户端并在完成后标记服务就绪，预热失败不阻止服务
+func (gp *GeminiProxy) warmup(ctx context.Context) {
+	warmupCtx, cancel := context.WithTimeout(ctx, 60*time.Second)
+	defer cancel()
+
+	if err := gp.client.Warmup(warmupCtx); err != nil {
+		gp.logger.WithError(err).Warn("Warmup completed with errors")
+	}
+	gp.server.SetReady(true)
+	gp.logger.Info("Gemini proxy is ready")
+}
+
+// startClusterAgent 配置了cluster时定期向前置路由注册并上报账号与健康状态，被排空时标记为未就绪
+func (gp *GeminiProxy) startClusterAgent(ctx context.Context) error {
+	join := gp.config.Cluster
+	if join == nil || gp.client == nil {
+		return nil
+	}
+
+	advertised := *join
+	if advertised.AdvertiseURL == "" {
+		advertised.AdvertiseURL = gp.GetServerURL()
+	}
+	apiKey := ""
+	if len(gp.config.APIKeys) > 0 {
+		apiKey = gp.config.APIKeys[0]
+	}
+
+	agent, err := cluster.NewAgent(advertised, apiKey, gp.clusterReport, func(draining bool) {
+		gp.server.SetReady(!draining)
+	}, gp.logger)
+	if err != nil {
+		return err
+	}
+	gp.logger.Infof("Registering with cluster router %s as %s", advertised.RouterURL, advertised.AdvertiseURL)
+	agent.Start(ctx)
+	return nil
+}
+
+// clusterReport 收集上报给前置路由的实例状态
+func (gp *GeminiProxy) clusterReport(ctx context.Context) cluster.InstanceReport {
+	healthCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	accounts := len(gp.config.AccountTokens())
+	return cluster.InstanceReport{
+		Healthy:  gp.client.CheckToken(healthCtx) == nil,
+		Ready:    gp.server.IsReady(),
+		Accounts: accounts,
+		Details: map[string]any{
+			"api_mode":         gp.config.APIMode,
+			"request_payloads": gp.client.PayloadStats(),
+		},
+	}
+}
+
+// watchRemoteConfig 按刷新间隔拉取远程配置，变化时按与配置文件热加载相同的规则应用
+func (gp *GeminiProxy) watchRemoteConfig(ctx context.Context) {
+	interval := gp.remoteRefresh
+	if interval <= 0 {
+		interval = config.DefaultRemoteRefreshInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		remote, changed, err := gp.remoteConfig.Fetch(ctx)
+		if err != nil {
+			gp.logger.WithError(err).Warn("Failed to refresh remote config")
+			continue
+		}
+		if !changed {
+			continue
+		}
+
+		// 与配置文件热加载使用相同的校验和锁
+		gp.reloadMu.Lock()
+		err = gp.applyConfigLocked(remote, "Remote config")
+		gp.reloadMu.Unlock()
+		if err != nil {
+			gp.logger.WithError(err).Warn("Failed to apply remote config")
+		}
+	}
+}
+
+// configWatchInterval 检查配置文件修改时间的间隔
+const configWatchInterval = 2 * time.Second
+
+// watchConfigFile 定期检查配置文件修改时间，变化时热加载
+func (gp *GeminiProxy) watchConfigFile(ctx context.Context) {
+	var lastModified time.Time
+	if info, err := os.Stat(gp.configFile); err == nil {
+		lastModified = info.ModTime()
+	}
+	gp.logger.Infof("Watching config file for changes: %s", gp.configFile)
+
+	ticker := time.NewTicker(configWatchInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		info, err := os.Stat(gp.configFile)
+		if err != nil || !info.ModTime().After(lastModified) {
+			continue
+		}
+		lastModified = info.ModTime()
+
+		if err := gp.ReloadConfig(); err != nil {
+			gp.logger.WithError(err).Warn("Failed to reload config file, keeping current settings")
+		}
+	}
+}
+
+// ReloadConfig 重新读取配置文件，运行时应用API密钥、代理列表、日志级别和系统提示词
+// 其余配置的变化需要重启后生效；配置文件无效时保持当前配置并返回错误
+func (gp *GeminiProxy) ReloadConfig() error {
+	if gp.configFile == "" {
+		return fmt.Errorf("no config file to reload")
+	}
+
+	gp.reloadMu.Lock()
+	defer gp.reloadMu.Unlock()
+
+	cfg, err := config.LoadConfigProfile(gp.configFile, gp.configProfile)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	return gp.applyConfigLocked(cfg, "Config file")
+}
+
+// applyConfigLocked 校验并应用可热加载的配置项，source 用于日志；调用方需持有 reloadMu
+func (gp *GeminiProxy) applyConfigLocked(cfg *config.Config, source string) error {
+	// 先校验再应用，避免只应用了一部分配置
+	if _, err := logrus.ParseLevel(cfg.LogLevel); err != nil {
+		return fmt.Errorf("invalid log_level %q: %w", cfg.LogLevel, err)
+	}
+	// 清空密钥会关闭认证，热加载时不允许
+	if len(cfg.APIKeys) == 0 && len(gp.config.APIKeys) > 0 {
+		return fmt.Errorf("refusing to clear api_keys on reload, restart to disable authentication")
+	}
+
+	var applied []string
+	if !slices.Equal(cfg.ProxyURLs, gp.config.ProxyURLs) {
+		if gp.client != nil {
+			if err := gp.client.SetProxyList(cfg.ProxyURLs); err != nil {
+				return fmt.Errorf("failed to apply proxy_urls: %w", err)
+			}
+		}
+		gp.config.ProxyURLs = cfg.ProxyURLs
+		applied = append(applied, "proxy_urls")
+	}
+
+	if !slices.Equal(cfg.APIKeys, gp.config.APIKeys) || !slices.Equal(cfg.AdminAPIKeys, gp.config.AdminAPIKeys) {
+		gp.config.APIKeys = cfg.APIKeys
+		gp.config.AdminAPIKeys = cfg.AdminAPIKeys
+		if gp.server != nil {
+			gp.server.SetAPIKeys(cfg.APIKeys, cfg.AdminAPIKeys)
+		}
+		applied = append(applied, "api_keys")
+	}
+
+	if cfg.LogLevel != gp.config.LogLevel {
+		gp.SetLogLevel(cfg.LogLevel)
+		applied = append(applied, "log_level")
+	}
+
+	if cfg.SystemPromptFile != gp.config.SystemPromptFile || cfg.SystemPromptMode != gp.config.SystemPromptMode {
+		gp.config.SystemPromptFile = cfg.SystemPromptFile
+		gp.config.SystemPromptMode = cfg.SystemPromptMode
+		if gp.client != nil {
+			gp.client.SetSystemPrompt(cfg.SystemPromptFile, cfg.SystemPromptMode)
+		}
+		applied = append(applied, "system_prompt")
+	}
+
+	if len(applied) == 0 {
+		gp.logger.Infof("%s reloaded, no runtime settings changed; other settings apply after restart", source)
+		return nil
+	}
+	gp.logger.Infof("%s reloaded, applied: %s; other settings apply after restart", source, strings.Join(applied, ", "))
+	return nil
+}
+
+// Stop 停止代理服务器，按 shutdown_timeout_seconds 等待进行中的请求完成
+func (gp *GeminiProxy) Stop() error {
+	ctx, cancel := context.WithTimeout(context.Background(), gp.config.GetShutdownTimeout())
+	defer cancel()
+	return gp.Shutdown(ctx)
+}
+
 // GetClient 获取Gemini客户端（用于直接API调用）
 func (gp *GeminiProxy) GetClient() *client.GeminiClient {
 	return gp.client
 }
 
+// GetJobQueue 获取后台任务队列，可用于注册自定义任务类型
+func (gp *GeminiProxy) GetJobQueue() *jobs.Queue {
+	return gp.jobs
+}
+
 // Health 健康检查
 func (gp *GeminiProxy) Health(ctx context.Context) error {
 	if gp.client == nil {
@@ -488,6 +1254,23 @@ func (gp *GeminiProxy) Health(ctx context.Context) error {
 	return gp.client.Health(ctx)
 }
 
+// SetTransport 设置自定义传输层（企业认证、链路追踪、录制回放等），替代内部构造的传输层
+func (gp *GeminiProxy) SetTransport(transport http.RoundTripper) {
+	gp.transport = transport
+	if gp.client != nil {
+		gp.client.SetTransport(transport)
+	}
+}
+
+// AddInterceptor 添加请求/响应拦截器（内容过滤、脱敏、自定义日志等），按添加顺序调用，
+// 原生路由的反向代理和嵌入请求同样调用拦截器
+func (gp *GeminiProxy) AddInterceptor(interceptor Interceptor) {
+	gp.interceptors = append(gp.interceptors, interceptor)
+	if gp.client != nil {
+		gp.client.AddInterceptor(interceptor)
+	}
+}
+
 // SetProxy 设置HTTP代理
 func (gp *GeminiProxy) SetProxy(proxyURL string) error {
 	if gp.client == nil {
@@ -601,19 +1384,36 @@ func (gp *GeminiProxy) SetClientID(clientID string) {
 	gp.config.ClientID = clientID
 }
 
-// SetProjectID 设置项目ID
+// SetProjectID 设置项目ID，初始化后调用时同步更新客户端
 func (gp *GeminiProxy) SetProjectID(projectID string) {
 	gp.config.ProjectID = projectID
+	if gp.client != nil {
+		gp.client.SetProjectID(projectID)
+	}
 }
 
-// SetLocation 设置位置
+// SetLocation 设置位置，初始化后调用时同步更新客户端
 func (gp *GeminiProxy) SetLocation(location string) {
 	gp.config.Location = location
+	if gp.client != nil {
+		gp.client.SetLocation(location)
+	}
 }
 
-// SetAPIMode 设置API模式
+// SetAPIMode 设置API模式，初始化后调用时同步切换客户端
 func (gp *GeminiProxy) SetAPIMode(mode APIMode) {
 	gp.config.APIMode = config.APIMode(mode)
+	if gp.client == nil {
+		return
+	}
+	switch gp.config.APIMode {
+	case config.VertexAI:
+		gp.client.UseVertexAI("")
+	case config.AIStudio:
+		gp.client.UseAIStudio()
+	default:
+		gp.client.UseCodeAssist()
+	}
 }
 
 // SetRedirectURL 设置重定向URL
@@ -626,19 +1426,36 @@ func (gp *GeminiProxy) SetAPIKeys(apiKeys []string) {
 	gp.config.APIKeys = apiKeys
 }
 
+// SetAdminAPIKeys 设置管理员API密钥列表（可使用调试等管理功能）
+func (gp *GeminiProxy) SetAdminAPIKeys(apiKeys []string) {
+	gp.config.AdminAPIKeys = apiKeys
+}
+
 // AddAPIKey 添加API密钥
 func (gp *GeminiProxy) AddAPIKey(apiKey string) {
 	gp.config.APIKeys = append(gp.config.APIKeys, apiKey)
 }
 
-// SetProxyURLs 设置代理URL列表
+// SetProxyURLs 设置代理URL列表，初始化后调用时同步更新客户端
 func (gp *GeminiProxy) SetProxyURLs(proxyURLs []string) {
 	gp.config.ProxyURLs = proxyURLs
+	gp.applyClientProxyURLs()
 }
 
-// AddProxyURL 添加代理URL
+// AddProxyURL 添加代理URL，初始化后调用时同步更新客户端
 func (gp *GeminiProxy) AddProxyURL(proxyURL string) {
 	gp.config.ProxyURLs = append(gp.config.ProxyURLs, proxyURL)
+	gp.applyClientProxyURLs()
+}
+
+// applyClientProxyURLs 把代理URL列表应用到已创建的客户端
+func (gp *GeminiProxy) applyClientProxyURLs() {
+	if gp.client == nil {
+		return
+	}
+	if err := gp.client.SetProxyList(slices.Clone(gp.config.ProxyURLs)); err != nil {
+		gp.logger.WithError(err).Warn("Failed to apply proxy URLs to client")
+	}
 }
 
 // SetTimeout 设置超时时间（秒）
@@ -646,6 +1463,54 @@ func (gp *GeminiProxy) SetTimeout(seconds int) {
 	gp.config.TimeoutSeconds = seconds
 }
 
+// SetStageTimeouts 设置建立连接、首字节和流式总时长的超时时间（秒），0表示不单独限制
+func (gp *GeminiProxy) SetStageTimeouts(connect, firstByte, stream int) {
+	gp.config.ConnectTimeoutSeconds = connect
+	gp.config.FirstByteTimeoutSeconds = firstByte
+	gp.config.StreamTimeoutSeconds = stream
+}
+
+// SetTTFTDeadline 设置流式响应首token截止时间（毫秒），超时后换用其他账号/代理重试，0表示不重试
+func (gp *GeminiProxy) SetTTFTDeadline(ms int) {
+	gp.config.TTFTDeadlineMs = ms
+}
+
+// SetStreamIdleTimeout 设置流式响应的空闲超时（秒），连续无数据超过该时间时中止流，0表示不限制
+func (gp *GeminiProxy) SetStreamIdleTimeout(seconds int) {
+	gp.config.StreamIdleTimeoutSeconds = seconds
+}
+
+// SetConfigBackups 设置配置文件备份保留策略
+func (gp *GeminiProxy) SetConfigBackups(policy *config.ConfigBackups) {
+	gp.config.ConfigBackups = policy
+}
+
+// SetEphemeralTokens 设置是否仅在内存中保存OAuth token，不写入配置文件和备份
+func (gp *GeminiProxy) SetEphemeralTokens(enable bool) {
+	gp.config.EphemeralTokens = enable
+}
+
+// SetVertexEndpoint 设置模型对应的Vertex AI专用端点（端点ID或完整资源名），初始化后调用时同步更新客户端
+func (gp *GeminiProxy) SetVertexEndpoint(model, endpoint string) {
+	if gp.config.VertexEndpoints == nil {
+		gp.config.VertexEndpoints = make(map[string]string)
+	}
+	gp.config.VertexEndpoints[model] = endpoint
+	if gp.client != nil {
+		gp.client.SetVertexEndpoint(model, endpoint)
+	}
+}
+
+// SetVertexRequestType 设置Vertex AI请求类型（"dedicated" 或 "shared"）
+func (gp *GeminiProxy) SetVertexRequestType(requestType string) {
+	gp.config.VertexRequestType = requestType
+}
+
+// SetQuotaProjectID 设置Vertex AI计费/配额项目
+func (gp *GeminiProxy) SetQuotaProjectID(projectID string) {
+	gp.config.QuotaProjectID = projectID
+}
+
 // SetMaxRetries 设置最大重试次数
 func (gp *GeminiProxy) SetMaxRetries(retries int) {
 	gp.config.MaxRetries = retries
@@ -670,14 +1535,134 @@ func (gp *GeminiProxy) SetEnableCORS(enable bool) {
 	gp.config.EnableCORS = enable
 }
 
-// SetSystemPromptFile 设置系统提示词文件路径
+// SetStartupChecks 设置启动自检
+func (gp *GeminiProxy) SetStartupChecks(checks *config.StartupChecks) {
+	gp.config.StartupChecks = checks
+}
+
+// SetLeakDetector 设置资源泄漏检测，nil表示关闭
+func (gp *GeminiProxy) SetLeakDetector(detector *config.LeakDetector) {
+	gp.config.LeakDetector = detector
+}
+
+// SetWarmupOnStart 设置是否在启动时预热
+func (gp *GeminiProxy) SetWarmupOnStart(enable bool) {
+	gp.config.WarmupOnStart = enable
+}
+
+// SetTrustedProxies 设置可信代理CIDR列表
+func (gp *GeminiProxy) SetTrustedProxies(cidrs []string) {
+	gp.config.TrustedProxies = cidrs
+}
+
+// SetHMACKeys 设置HMAC请求签名密钥（密钥ID -> 共享密钥）及重放窗口秒数
+func (gp *GeminiProxy) SetHMACKeys(keys map[string]string, replayWindowSeconds int) {
+	gp.config.HMACKeys = keys
+	gp.config.HMACReplayWindowSeconds = replayWindowSeconds
+}
+
+// SetHMACMaxBodyBytes 设置校验签名时读取的请求体上限，0表示使用默认的32MiB
+func (gp *GeminiProxy) SetHMACMaxBodyBytes(limit int64) {
+	gp.config.HMACMaxBodyBytes = limit
+}
+
+// SetAdminListen 设置独立的管理监听地址
+func (gp *GeminiProxy) SetAdminListen(addr string) {
+	gp.config.AdminListen = addr
+}
+
+// SetClusterJoin 设置向前置路由注册本实例的配置
+func (gp *GeminiProxy) SetClusterJoin(join *config.ClusterJoin) {
+	gp.config.Cluster = join
+}
+
+// SetRemoteConfig 设置远程配置源，启动后按interval刷新（<=0时使用默认5分钟）
+func (gp *GeminiProxy) SetRemoteConfig(source *config.RemoteSource, interval time.Duration) {
+	gp.remoteConfig = source
+	gp.remoteRefresh = interval
+}
+
+// SetNativeReverseProxy 设置原生Gemini路由是否使用反向代理转发
+func (gp *GeminiProxy) SetNativeReverseProxy(enable bool) {
+	gp.config.NativeReverseProxy = enable
+}
+
+// SetStreamMetadataEvent 设置流式响应结束时是否发送 event: metadata 事件
+func (gp *GeminiProxy) SetStreamMetadataEvent(enable bool) {
+	gp.config.StreamMetadataEvent = enable
+}
+
+// SetSystemPromptFile 设置系统提示词文件路径，初始化后调用时同步更新客户端
 func (gp *GeminiProxy) SetSystemPromptFile(filePath string) {
 	gp.config.SystemPromptFile = filePath
+	if gp.client != nil {
+		gp.client.SetSystemPrompt(gp.config.SystemPromptFile, gp.config.SystemPromptMode)
+	}
 }
 
-// SetSystemPromptMode 设置系统提示词模式
+// SetSystemPromptMode 设置系统提示词模式，初始化后调用时同步更新客户端
 func (gp *GeminiProxy) SetSystemPromptMode(mode string) {
 	gp.config.SystemPromptMode = mode
+	if gp.client != nil {
+		gp.client.SetSystemPrompt(gp.config.SystemPromptFile, gp.config.SystemPromptMode)
+	}
+}
+
+// SetResponseLanguage 设置全局回复语言，keyLanguages按API密钥覆盖全局设置
+func (gp *GeminiProxy) SetResponseLanguage(language string, keyLanguages map[string]string) {
+	gp.config.ResponseLanguage = language
+	gp.config.KeyResponseLanguages = keyLanguages
+}
+
+// SetResponseFilter 设置生成内容屏蔽词过滤，filter为nil时关闭
+func (gp *GeminiProxy) SetResponseFilter(filter *ResponseFilter) {
+	gp.config.ResponseFilter = filter
+}
+
+// SetParallelModels 设置并行请求默认使用的模型列表
+func (gp *GeminiProxy) SetParallelModels(models []string) {
+	gp.config.ParallelModels = models
+}
+
+// SetBestOfScorer 设置best-of模式的评分方式（"heuristic" 或 "judge"）
+func (gp *GeminiProxy) SetBestOfScorer(scorer string) {
+	gp.config.BestOfScorer = scorer
+}
+
+// SetStructuredOutputValidation 设置是否校验结构化输出以及校验失败时是否自动修复
+func (gp *GeminiProxy) SetStructuredOutputValidation(validate, repair bool) {
+	gp.config.ValidateStructuredOutput = validate
+	gp.config.RepairStructuredOutput = repair
+}
+
+// SetCoalesceRequests 设置是否合并并发的相同非流式请求
+func (gp *GeminiProxy) SetCoalesceRequests(enable bool) {
+	gp.config.CoalesceRequests = enable
+}
+
+// SetContinuationMaxRounds 设置输出被截断时自动续写的最大轮数（0表示禁用）
+func (gp *GeminiProxy) SetContinuationMaxRounds(rounds int) {
+	gp.config.ContinuationMaxRounds = rounds
+}
+
+// SetJobStoreFile 设置任务持久化文件
+func (gp *GeminiProxy) SetJobStoreFile(path string) {
+	gp.config.JobStoreFile = path
+}
+
+// SetWebhookSecret 设置webhook签名密钥
+func (gp *GeminiProxy) SetWebhookSecret(secret string) {
+	gp.config.WebhookSecret = secret
+}
+
+// SetWebhookAllowedHosts 设置异步请求的webhook可投递的内网主机，其他地址必须是公网https地址，需在初始化之前调用
+func (gp *GeminiProxy) SetWebhookAllowedHosts(hosts []string) {
+	gp.config.WebhookAllowedHosts = hosts
+}
+
+// AddScheduledJob 添加定时提示词任务，需在初始化之前调用
+func (gp *GeminiProxy) AddScheduledJob(job config.ScheduledJob) {
+	gp.config.ScheduledJobs = append(gp.config.ScheduledJobs, job)
 }
 
 // SaveConfig 保存当前配置到指定文件
@@ -705,4 +1690,4 @@ func GetGoogleOAuthToken(ctx context.Context, authConfig *GoogleAuthConfig) (*au
 	}, logger)
 
 	return googleAuth, nil
-}
\ No newline at end of file
+}
@@ -0,0 +1,299 @@
+// Package audit 提供追加写入的请求审计日志，可选哈希链和签名检查点，用于发现事后篡改
+package audit
+
+import (
+	"bufio"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// 审计记录类型
+const (
+	EventRequest    = "request"
+	EventCheckpoint = "checkpoint"
+)
+
+// ErrTampered 审计日志校验失败，记录被修改、删除或重排
+var ErrTampered = errors.New("audit log verification failed")
+
+// Entry 一条审计记录，每行一个JSON对象
+type Entry struct {
+	Seq        int64     `json:"seq"`
+	Time       time.Time `json:"time"`
+	Event      string    `json:"event"`
+	Key        string    `json:"key,omitempty"` // 调用方标识：API密钥指纹或 hmac:<密钥ID>
+	ClientIP   string    `json:"client_ip,omitempty"`
+	Method     string    `json:"method,omitempty"`
+	Path       string    `json:"path,omitempty"`
+	Status     int       `json:"status,omitempty"`
+	DurationMs int64     `json:"duration_ms,omitempty"`
+	// 检查点：记录总数和对链头的签名
+	Records   int64  `json:"records,omitempty"`
+	Signature string `json:"signature,omitempty"`
+	// 哈希链：上一条记录的哈希和本条记录的哈希
+	PrevHash string `json:"prev_hash,omitempty"`
+	Hash     string `json:"hash,omitempty"`
+}
+
+// Options 审计日志选项
+type Options struct {
+	HashChain          bool          // 每条记录包含上一条记录的哈希
+	CheckpointEvery    int           // 每写入多少条记录输出一次检查点，0表示不按数量输出
+	CheckpointInterval time.Duration // 定期输出检查点，0表示不定期输出
+	CheckpointKey      []byte        // 检查点HMAC签名密钥，为空时检查点不签名
+}
+
+// Logger 追加写入的审计日志
+type Logger struct {
+	mu     sync.Mutex
+	file   *os.File
+	opts   Options
+	logger *logrus.Logger
+
+	seq              int64
+	lastHash         string
+	sinceCheckpoint  int
+	closed           bool
+	stop             chan struct{}
+	stopOnce         sync.Once
+	checkpointerDone chan struct{}
+}
+
+// Open 以追加方式打开审计日志，已有记录时从最后一条记录继续编号和哈希链
+func Open(path string, opts Options, logger *logrus.Logger) (*Logger, error) {
+	if logger == nil {
+		logger = logrus.New()
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit log: %w", err)
+	}
+
+	l := &Logger{file: file, opts: opts, logger: logger}
+	if last, err := lastEntry(file); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to read audit log %s: %w", path, err)
+	} else if last != nil {
+		l.seq = last.Seq
+		l.lastHash = last.Hash
+	}
+
+	if opts.CheckpointInterval > 0 {
+		l.stop = make(chan struct{})
+		l.checkpointerDone = make(chan struct{})
+		go l.checkpointLoop()
+	}
+	return l, nil
+}
+
+// lastEntry 读取文件中的最后一条记录，文件为空时返回nil
+func lastEntry(file *os.File) (*Entry, error) {
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	var last *Entry
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var entry Entry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, fmt.Errorf("invalid record: %w", err)
+		}
+		last = &entry
+	}
+	return last, scanner.Err()
+}
+
+// Log 写入一条请求记录
+func (l *Logger) Log(entry Entry) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if entry.Event == "" {
+		entry.Event = EventRequest
+	}
+	if _, err := l.writeLocked(entry); err != nil {
+		return err
+	}
+	l.sinceCheckpoint++
+	if l.opts.CheckpointEvery > 0 && l.sinceCheckpoint >= l.opts.CheckpointEvery {
+		return l.checkpointLocked()
+	}
+	return nil
+}
+
+// Checkpoint 写入检查点，自上次检查点以来没有新记录时跳过
+func (l *Logger) Checkpoint() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.sinceCheckpoint == 0 {
+		return nil
+	}
+	return l.checkpointLocked()
+}
+
+// checkpointLocked 写入对当前链头签名的检查点，并输出到应用日志作为文件之外的副本
+func (l *Logger) checkpointLocked() error {
+	checkpoint := Entry{Event: EventCheckpoint, Records: l.seq}
+	if len(l.opts.CheckpointKey) > 0 {
+		checkpoint.Signature = sign(l.opts.CheckpointKey, l.seq, l.lastHash)
+	}
+	checkpoint, err := l.writeLocked(checkpoint)
+	if err != nil {
+		return err
+	}
+	l.sinceCheckpoint = 0
+
+	l.logger.WithFields(logrus.Fields{
+		"seq":       checkpoint.Seq,
+		"records":   checkpoint.Records,
+		"head_hash": checkpoint.PrevHash,
+		"hash":      checkpoint.Hash,
+	}).Info("Audit log checkpoint")
+	return nil
+}
+
+// writeLocked 为记录编号、计算哈希链并追加写入，返回实际写入的记录，调用方需持有mu
+func (l *Logger) writeLocked(entry Entry) (Entry, error) {
+	if l.closed {
+		return entry, fmt.Errorf("audit log is closed")
+	}
+
+	entry.Seq = l.seq + 1
+	if entry.Time.IsZero() {
+		entry.Time = time.Now().UTC()
+	}
+	if l.opts.HashChain {
+		entry.PrevHash = l.lastHash
+		hash, err := entryHash(entry)
+		if err != nil {
+			return entry, err
+		}
+		entry.Hash = hash
+	}
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return entry, fmt.Errorf("failed to marshal audit record: %w", err)
+	}
+	if _, err := l.file.Write(append(data, '\n')); err != nil {
+		return entry, fmt.Errorf("failed to write audit record: %w", err)
+	}
+	l.seq = entry.Seq
+	l.lastHash = entry.Hash
+	return entry, nil
+}
+
+// checkpointLoop 定期输出检查点
+func (l *Logger) checkpointLoop() {
+	defer close(l.checkpointerDone)
+	ticker := time.NewTicker(l.opts.CheckpointInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-l.stop:
+			return
+		case <-ticker.C:
+			if err := l.Checkpoint(); err != nil {
+				l.logger.WithError(err).Error("Failed to write audit log checkpoint")
+			}
+		}
+	}
+}
+
+// Close 写入最终检查点并关闭文件，重复调用是安全的
+func (l *Logger) Close() error {
+	if l.stop != nil {
+		l.stopOnce.Do(func() { close(l.stop) })
+		<-l.checkpointerDone
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.closed {
+		return nil
+	}
+	var errs []error
+	if l.sinceCheckpoint > 0 && (l.opts.CheckpointEvery > 0 || l.opts.CheckpointInterval > 0) {
+		errs = append(errs, l.checkpointLocked())
+	}
+	l.closed = true
+	errs = append(errs, l.file.Sync(), l.file.Close())
+	return errors.Join(errs...)
+}
+
+// entryHash 计算记录的哈希：SHA-256(不含hash字段的JSON)
+func entryHash(entry Entry) (string, error) {
+	entry.Hash = ""
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal audit record: %w", err)
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// sign 计算检查点签名：HMAC-SHA256(key, "<记录总数>:<链头哈希>")
+func sign(key []byte, records int64, headHash string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(strconv.FormatInt(records, 10) + ":" + headHash))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify 校验审计日志：编号连续、哈希链完整，提供key时检查点签名必须有效
+// 第一条记录作为哈希链的起点（按保留期限清理后文件不再从第1条开始）
+// 返回校验通过的记录数；校验失败时返回包装了ErrTampered的错误，指出第一条异常记录
+func Verify(r io.Reader, key []byte) (int64, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	var seq, verified int64
+	var lastHash string
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var entry Entry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return verified, fmt.Errorf("%w: line %d is not a valid record: %v", ErrTampered, line, err)
+		}
+		if seq > 0 && entry.Seq != seq+1 {
+			return verified, fmt.Errorf("%w: line %d has seq %d, expected %d", ErrTampered, line, entry.Seq, seq+1)
+		}
+		if entry.Hash != "" || lastHash != "" {
+			if verified > 0 && entry.PrevHash != lastHash {
+				return verified, fmt.Errorf("%w: record %d does not link to the previous record", ErrTampered, entry.Seq)
+			}
+			if hash, err := entryHash(entry); err != nil || hash != entry.Hash {
+				return verified, fmt.Errorf("%w: record %d hash mismatch", ErrTampered, entry.Seq)
+			}
+		}
+		if entry.Event == EventCheckpoint && len(key) > 0 {
+			expected := sign(key, entry.Records, entry.PrevHash)
+			if !hmac.Equal([]byte(entry.Signature), []byte(expected)) {
+				return verified, fmt.Errorf("%w: checkpoint %d has an invalid signature", ErrTampered, entry.Seq)
+			}
+		}
+		seq = entry.Seq
+		lastHash = entry.Hash
+		verified++
+	}
+	if err := scanner.Err(); err != nil {
+		return verified, fmt.Errorf("failed to read audit log: %w", err)
+	}
+	return verified, nil
+}
@@ -0,0 +1,163 @@
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testKey = []byte("checkpoint-secret")
+
+func writeTestLog(t *testing.T, path string, records int) {
+	t.Helper()
+	logger, _ := test.NewNullLogger()
+	l, err := Open(path, Options{HashChain: true, CheckpointEvery: 2, CheckpointKey: testKey}, logger)
+	require.NoError(t, err)
+	for i := 0; i < records; i++ {
+		require.NoError(t, l.Log(Entry{Key: "key:abc", Method: "POST", Path: "/v1/chat/completions", Status: 200}))
+	}
+	require.NoError(t, l.Close())
+}
+
+func readLines(t *testing.T, path string) []string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	return strings.Split(strings.TrimSpace(string(data)), "\n")
+}
+
+func TestLogger_HashChainAndCheckpoints(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	writeTestLog(t, path, 3)
+
+	// 3条请求记录、第2条之后和关闭时各一个检查点
+	lines := readLines(t, path)
+	require.Len(t, lines, 5)
+	var checkpoint Entry
+	require.NoError(t, json.Unmarshal([]byte(lines[2]), &checkpoint))
+	assert.Equal(t, EventCheckpoint, checkpoint.Event)
+	assert.EqualValues(t, 2, checkpoint.Records)
+	assert.NotEmpty(t, checkpoint.Signature)
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	records, err := Verify(bytes.NewReader(data), testKey)
+	require.NoError(t, err)
+	assert.EqualValues(t, 5, records)
+
+	// 重新打开后继续编号和哈希链
+	writeTestLog(t, path, 1)
+	data, err = os.ReadFile(path)
+	require.NoError(t, err)
+	records, err = Verify(bytes.NewReader(data), testKey)
+	require.NoError(t, err)
+	assert.EqualValues(t, 7, records)
+}
+
+func TestVerify_DetectsTampering(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	writeTestLog(t, path, 3)
+	lines := readLines(t, path)
+
+	verify := func(lines []string, key []byte) error {
+		_, err := Verify(strings.NewReader(strings.Join(lines, "\n")), key)
+		return err
+	}
+
+	// 修改记录内容
+	modified := append([]string(nil), lines...)
+	modified[1] = strings.Replace(modified[1], `"status":200`, `"status":403`, 1)
+	assert.ErrorIs(t, verify(modified, testKey), ErrTampered)
+
+	// 删除记录
+	deleted := append(append([]string(nil), lines[:1]...), lines[2:]...)
+	assert.ErrorIs(t, verify(deleted, testKey), ErrTampered)
+
+	// 重写整条链后检查点签名无法伪造
+	assert.ErrorIs(t, verify(lines, []byte("wrong-key")), ErrTampered)
+
+	assert.NoError(t, verify(lines, testKey))
+}
+
+func TestLogger_Checkpoint(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	logger, hook := test.NewNullLogger()
+	l, err := Open(path, Options{HashChain: true}, logger)
+	require.NoError(t, err)
+
+	// 没有新记录时不输出检查点
+	require.NoError(t, l.Checkpoint())
+	require.NoError(t, l.Log(Entry{Path: "/v1/embeddings"}))
+	require.NoError(t, l.Checkpoint())
+	require.NoError(t, l.Checkpoint())
+	require.NoError(t, l.Close())
+
+	assert.Len(t, readLines(t, path), 2)
+	entry := hook.LastEntry()
+	require.NotNil(t, entry)
+	assert.Equal(t, logrus.InfoLevel, entry.Level)
+	assert.NotEmpty(t, entry.Data["head_hash"])
+
+	assert.Error(t, l.Log(Entry{}))
+	assert.NoError(t, l.Close())
+}
+
+func TestLogger_Purge(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	logger, _ := test.NewNullLogger()
+	l, err := Open(path, Options{HashChain: true, CheckpointKey: testKey}, logger)
+	require.NoError(t, err)
+
+	old := time.Now().Add(-48 * time.Hour)
+	require.NoError(t, l.Log(Entry{Time: old, Path: "/v1/chat/completions"}))
+	require.NoError(t, l.Log(Entry{Time: old, Path: "/v1/chat/completions"}))
+	require.NoError(t, l.Log(Entry{Path: "/v1/embeddings"}))
+
+	removed, err := l.Purge(time.Now().Add(-24 * time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, 2, removed)
+	require.NoError(t, l.Log(Entry{Path: "/v1/models"}))
+	require.NoError(t, l.Close())
+
+	// 剩余记录从第3条开始，仍能通过校验
+	lines := readLines(t, path)
+	require.Len(t, lines, 3)
+	assert.Contains(t, lines[1], `"event":"purge"`)
+	records, err := Verify(strings.NewReader(strings.Join(lines, "\n")), testKey)
+	require.NoError(t, err)
+	assert.EqualValues(t, 3, records)
+}
+
+func TestLogger_Erase(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	logger, _ := test.NewNullLogger()
+	l, err := Open(path, Options{HashChain: true, CheckpointEvery: 2, CheckpointKey: testKey}, logger)
+	require.NoError(t, err)
+
+	require.NoError(t, l.Log(Entry{Key: "key:other", ClientIP: "10.0.0.1"}))
+	require.NoError(t, l.Log(Entry{Key: "key:target", ClientIP: "10.0.0.2"}))
+	require.NoError(t, l.Log(Entry{Key: "key:target", ClientIP: "10.0.0.2"}))
+
+	erased, err := l.Erase("key:target")
+	require.NoError(t, err)
+	assert.Equal(t, 2, erased)
+	require.NoError(t, l.Log(Entry{Key: "key:other"}))
+	require.NoError(t, l.Close())
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), "key:target")
+	assert.NotContains(t, string(data), "10.0.0.2")
+	assert.Contains(t, string(data), `"event":"erasure"`)
+	_, err = Verify(bytes.NewReader(data), testKey)
+	assert.NoError(t, err)
+}
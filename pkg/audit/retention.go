@@ -0,0 +1,183 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// 保留策略产生的记录类型
+const (
+	EventPurge   = "purge"   // 按保留期限删除了旧记录，Records为删除的数量
+	EventErasure = "erasure" // 按调用方删除请求匿名化了记录，Records为匿名化的数量
+)
+
+// erasedKey 匿名化后记录中的调用方标识
+const erasedKey = "erased"
+
+// Purge 删除早于before的记录，保留的第一条记录成为哈希链的起点，并追加一条purge记录
+// 返回删除的记录数
+func (l *Logger) Purge(before time.Time) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	entries, err := l.readAllLocked()
+	if err != nil {
+		return 0, err
+	}
+	kept := entries[:0]
+	for _, entry := range entries {
+		if !entry.Time.Before(before) {
+			kept = append(kept, entry)
+		}
+	}
+	removed := len(entries) - len(kept)
+	if removed == 0 {
+		return 0, nil
+	}
+
+	if err := l.rewriteLocked(kept); err != nil {
+		return 0, err
+	}
+	if _, err := l.writeLocked(Entry{Event: EventPurge, Records: int64(removed)}); err != nil {
+		return removed, err
+	}
+	l.sinceCheckpoint++
+	l.logger.Infof("Purged %d audit log record(s) older than %s", removed, before.Format(time.RFC3339))
+	return removed, nil
+}
+
+// Erase 匿名化指定调用方的记录（清除调用方标识和客户端IP），从第一条被修改的记录起重新计算哈希链
+// 并重新签名之后的检查点，随后追加一条erasure记录和新的检查点。返回匿名化的记录数
+// 注意：被修改位置之后、此前输出到应用日志的检查点不再与文件一致
+func (l *Logger) Erase(key string) (int, error) {
+	if key == "" {
+		return 0, nil
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	entries, err := l.readAllLocked()
+	if err != nil {
+		return 0, err
+	}
+	erased := 0
+	first := -1
+	for i := range entries {
+		if entries[i].Key != key {
+			continue
+		}
+		entries[i].Key = erasedKey
+		entries[i].ClientIP = ""
+		erased++
+		if first < 0 {
+			first = i
+		}
+	}
+	if erased == 0 {
+		return 0, nil
+	}
+
+	if l.opts.HashChain {
+		for i := first; i < len(entries); i++ {
+			if i > 0 {
+				entries[i].PrevHash = entries[i-1].Hash
+			}
+			if entries[i].Event == EventCheckpoint && len(l.opts.CheckpointKey) > 0 {
+				entries[i].Signature = sign(l.opts.CheckpointKey, entries[i].Records, entries[i].PrevHash)
+			}
+			hash, err := entryHash(entries[i])
+			if err != nil {
+				return 0, err
+			}
+			entries[i].Hash = hash
+		}
+	}
+
+	if err := l.rewriteLocked(entries); err != nil {
+		return 0, err
+	}
+	if _, err := l.writeLocked(Entry{Event: EventErasure, Records: int64(erased)}); err != nil {
+		return erased, err
+	}
+	l.sinceCheckpoint++
+	if err := l.checkpointLocked(); err != nil {
+		return erased, err
+	}
+	return erased, nil
+}
+
+// readAllLocked 读取文件中的全部记录，调用方需持有mu
+func (l *Logger) readAllLocked() ([]Entry, error) {
+	if l.closed {
+		return nil, fmt.Errorf("audit log is closed")
+	}
+	if _, err := l.file.Seek(0, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("failed to read audit log: %w", err)
+	}
+
+	var entries []Entry
+	scanner := bufio.NewScanner(l.file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var entry Entry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, fmt.Errorf("invalid audit record: %w", err)
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read audit log: %w", err)
+	}
+	return entries, nil
+}
+
+// rewriteLocked 用entries替换文件内容（先写临时文件再重命名）并重新打开文件，调用方需持有mu
+func (l *Logger) rewriteLocked(entries []Entry) error {
+	path := l.file.Name()
+	tmpPath := path + ".tmp"
+	tmp, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to rewrite audit log: %w", err)
+	}
+	writer := bufio.NewWriter(tmp)
+	for _, entry := range entries {
+		data, err := json.Marshal(entry)
+		if err != nil {
+			tmp.Close()
+			return fmt.Errorf("failed to marshal audit record: %w", err)
+		}
+		writer.Write(append(data, '\n'))
+	}
+	if err := writer.Flush(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to rewrite audit log: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to rewrite audit log: %w", err)
+	}
+	tmp.Close()
+
+	if err := os.Rename(tmpPath, path); err != nil {
+		return fmt.Errorf("failed to replace audit log: %w", err)
+	}
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_APPEND, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to reopen audit log: %w", err)
+	}
+	l.file.Close()
+	l.file = file
+
+	if len(entries) > 0 {
+		l.lastHash = entries[len(entries)-1].Hash
+	}
+	return nil
+}
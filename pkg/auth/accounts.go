@@ -0,0 +1,238 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// accountFlowTTL 管理接口发起的添加账号授权的有效期
+const accountFlowTTL = 10 * time.Minute
+
+var (
+	// ErrAccountNotFound 账号不存在或已被移除
+	ErrAccountNotFound = errors.New("account not found")
+	// ErrLastAccount 不能移除最后一个可用账号
+	ErrLastAccount = errors.New("cannot remove the last available account")
+)
+
+// 账号状态
+const (
+	AccountStatusActive      = "active"
+	AccountStatusCooldown    = "cooldown"
+	AccountStatusRevoked     = "revoked"
+	AccountStatusQuarantined = "quarantined"
+	AccountStatusRemoved     = "removed"
+)
+
+// AccountInfo 账号池中一个账号的状态，ID为账号在池中的序号
+type AccountInfo struct {
+	ID            int    `json:"id"`
+	Status        string `json:"status"`
+	Reason        string `json:"reason,omitempty"`         // 隔离原因
+	CooldownUntil string `json:"cooldown_until,omitempty"` // 冷却结束时间
+	RequestsToday int    `json:"requests_today"`
+}
+
+// accountFlows 等待回调的添加账号授权
+type accountFlows struct {
+	mu     sync.Mutex
+	states map[string]time.Time
+}
+
+// start 生成新的授权state
+func (f *accountFlows) start() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	state := hex.EncodeToString(buf)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	now := time.Now()
+	if f.states == nil {
+		f.states = make(map[string]time.Time)
+	}
+	for pending, expiry := range f.states {
+		if now.After(expiry) {
+			delete(f.states, pending)
+		}
+	}
+	f.states[state] = now.Add(accountFlowTTL)
+	return state, nil
+}
+
+// consume 校验并消耗授权state，state只能使用一次
+func (f *accountFlows) consume(state string) bool {
+	if state == "" {
+		return false
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	expiry, ok := f.states[state]
+	delete(f.states, state)
+	return ok && time.Now().Before(expiry)
+}
+
+// Accounts 返回账号池中全部账号的状态，包括已移除的账号
+func (g *GoogleAuth) Accounts() []AccountInfo {
+	g.pool.mu.Lock()
+	defer g.pool.mu.Unlock()
+
+	now := time.Now()
+	day := g.pool.quotaDayLocked(now)
+	accounts := make([]AccountInfo, len(g.pool.accounts))
+	for i, account := range g.pool.accounts {
+		g.pool.cappedLocked(account, day)
+		info := AccountInfo{ID: i, Status: AccountStatusActive, RequestsToday: account.requestsToday}
+		switch {
+		case account.removed:
+			info.Status = AccountStatusRemoved
+		case account.revoked:
+			info.Status = AccountStatusRevoked
+		case account.quarantined != "":
+			info.Status = AccountStatusQuarantined
+			info.Reason = account.quarantined
+		case now.Before(account.cooldownUntil):
+			info.Status = AccountStatusCooldown
+			info.CooldownUntil = account.cooldownUntil.Format(time.RFC3339)
+		}
+		accounts[i] = info
+	}
+	return accounts
+}
+
+// AddAccount 将Base64编码的OAuth token加入账号池，立即参与轮询，返回账号ID
+func (g *GoogleAuth) AddAccount(tokenBase64 string) (int, error) {
+	token, err := parseTokenBase64(tokenBase64)
+	if err != nil {
+		return -1, err
+	}
+	return g.addAccount(token, tokenBase64)
+}
+
+// StartAddAccountFlow 生成添加账号的授权地址，在浏览器中完成授权后回调将新账号加入账号池
+func (g *GoogleAuth) StartAddAccountFlow() (string, error) {
+	state, err := g.accountFlows.start()
+	if err != nil {
+		return "", fmt.Errorf("failed to generate oauth state: %w", err)
+	}
+	// 强制显示同意页面，确保获得刷新令牌
+	return g.oauthConfig.AuthCodeURL(state, oauth2.AccessTypeOffline, oauth2.ApprovalForce), nil
+}
+
+// addAccount 将token加入账号池；尚未完成首次授权或正在等待重新授权时，新账号同时恢复服务
+func (g *GoogleAuth) addAccount(token *oauth2.Token, tokenBase64 string) (int, error) {
+	source := g.oauthConfig.TokenSource(context.Background(), token)
+
+	g.pool.mu.Lock()
+	if len(g.pool.accounts) == 0 && g.initialized && g.tokenSource != nil {
+		// 没有账号池时只使用主账号，加入新账号前先把主账号放入账号池
+		g.pool.accounts = append(g.pool.accounts, &tokenAccount{source: g.tokenSource})
+	}
+	g.pool.accounts = append(g.pool.accounts, &tokenAccount{source: source, tokenBase64: tokenBase64})
+	id := len(g.pool.accounts) - 1
+	available := g.pool.availableLocked()
+	if !g.initialized {
+		g.currentTokens = token
+		g.tokenSource = source
+		g.initialized = true
+	}
+	g.pool.mu.Unlock()
+
+	g.clearReauth()
+	g.logger.Infof("OAuth account %d added to the pool (%d account(s) available)", id, available)
+
+	if g.onAccountAdded != nil {
+		if err := g.onAccountAdded(tokenBase64); err != nil {
+			return id, fmt.Errorf("account added but not saved: %w", err)
+		}
+	}
+	return id, nil
+}
+
+// RemoveAccount 将账号移出账号池：不再分配新请求，已开始的请求不受影响；不能移除最后一个可用账号
+func (g *GoogleAuth) RemoveAccount(id int) error {
+	g.pool.mu.Lock()
+	if id < 0 || id >= len(g.pool.accounts) || g.pool.accounts[id].removed {
+		g.pool.mu.Unlock()
+		return ErrAccountNotFound
+	}
+	account := g.pool.accounts[id]
+	if account.available() && g.pool.availableLocked() == 1 {
+		g.pool.mu.Unlock()
+		return ErrLastAccount
+	}
+	account.removed = true
+	remaining := g.pool.availableLocked()
+
+	// 主账号用于项目发现和健康检查，改用第一个可用账号
+	for _, candidate := range g.pool.accounts {
+		if candidate.available() {
+			g.tokenSource = candidate.source
+			break
+		}
+	}
+	g.pool.mu.Unlock()
+
+	g.logger.Warnf("OAuth account %d removed from the pool (%d account(s) left)", id, remaining)
+
+	if g.onAccountRemoved != nil && account.tokenBase64 != "" {
+		if err := g.onAccountRemoved(account.tokenBase64); err != nil {
+			return fmt.Errorf("account removed but not saved: %w", err)
+		}
+	}
+	return nil
+}
+
+// SetOnAccountAdded 设置通过管理接口或授权回调添加账号后的回调，用于保存配置
+func (g *GoogleAuth) SetOnAccountAdded(callback func(tokenBase64 string) error) {
+	g.onAccountAdded = callback
+}
+
+// SetOnAccountRemoved 设置通过管理接口移除账号后的回调，参数为加载该账号的Base64 token
+func (g *GoogleAuth) SetOnAccountRemoved(callback func(tokenBase64 string) error) {
+	g.onAccountRemoved = callback
+}
+
+// encodeToken 将token编码为Base64 JSON，与配置文件中的格式相同
+func encodeToken(token *oauth2.Token) (string, error) {
+	data, err := json.Marshal(token)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal OAuth token: %w", err)
+	}
+	return base64.StdEncoding.EncodeToString(data), nil
+}
+
+// handleAddedAccount 将管理接口发起的授权获得的token加入账号池并返回结果页面
+func (g *GoogleAuth) handleAddedAccount(w http.ResponseWriter, token *oauth2.Token) {
+	w.Header().Set("Content-Type", "application/json")
+
+	tokenBase64, err := encodeToken(token)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]any{"status": "error", "error": err.Error()})
+		return
+	}
+	id, err := g.addAccount(token, tokenBase64)
+	if err != nil {
+		g.logger.WithError(err).Error("Failed to save added OAuth account")
+	}
+
+	json.NewEncoder(w).Encode(map[string]any{
+		"status":     "success",
+		"message":    "OAuth account added to the pool",
+		"account_id": id,
+		"note":       "You can now close this browser tab.",
+	})
+}
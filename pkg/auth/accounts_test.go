@@ -0,0 +1,118 @@
+package auth
+
+import (
+	"context"
+	"net/url"
+	"sync"
+	"testing"
+
+	"github.com/ba0gu0/gemini-go-proxy/pkg/models"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGoogleAuth_AddRemoveAccount(t *testing.T) {
+	auth := NewGoogleAuth(&models.GoogleAuthConfig{OAuthTokens: []string{encodeTestToken(t, "a")}}, logrus.New())
+	require.NoError(t, auth.Initialize(context.Background()))
+
+	var added, removed []string
+	auth.SetOnAccountAdded(func(tokenBase64 string) error {
+		added = append(added, tokenBase64)
+		return nil
+	})
+	auth.SetOnAccountRemoved(func(tokenBase64 string) error {
+		removed = append(removed, tokenBase64)
+		return nil
+	})
+
+	_, err := auth.AddAccount("invalid")
+	assert.Error(t, err)
+
+	tokenB := encodeTestToken(t, "b")
+	id, err := auth.AddAccount(tokenB)
+	require.NoError(t, err)
+	assert.Equal(t, 1, id)
+	assert.Equal(t, []string{tokenB}, added)
+	assert.Equal(t, 2, auth.AccountCount())
+
+	next := func() string {
+		token, _, err := auth.NextToken()
+		require.NoError(t, err)
+		return token.AccessToken
+	}
+	assert.Equal(t, []string{"a", "b"}, []string{next(), next()})
+
+	// 移除后不再分配，序号保持不变
+	require.NoError(t, auth.RemoveAccount(0))
+	assert.Len(t, removed, 1)
+	assert.Equal(t, []string{"b", "b"}, []string{next(), next()})
+	token, err := auth.GetToken()
+	require.NoError(t, err)
+	assert.Equal(t, "b", token.AccessToken)
+
+	accounts := auth.Accounts()
+	require.Len(t, accounts, 2)
+	assert.Equal(t, AccountStatusRemoved, accounts[0].Status)
+	assert.Equal(t, AccountStatusActive, accounts[1].Status)
+
+	assert.ErrorIs(t, auth.RemoveAccount(0), ErrAccountNotFound)
+	assert.ErrorIs(t, auth.RemoveAccount(5), ErrAccountNotFound)
+	assert.ErrorIs(t, auth.RemoveAccount(1), ErrLastAccount)
+}
+
+func TestGoogleAuth_AddAccountRestoresReauth(t *testing.T) {
+	auth := NewGoogleAuth(nil, logrus.New())
+	assert.Equal(t, AuthStatusAuthRequired, auth.Status().Status)
+
+	_, err := auth.AddAccount(encodeTestToken(t, "a"))
+	require.NoError(t, err)
+	assert.Equal(t, AuthStatusOK, auth.Status().Status)
+	token, _, err := auth.NextToken()
+	require.NoError(t, err)
+	assert.Equal(t, "a", token.AccessToken)
+}
+
+func TestGoogleAuth_StartAddAccountFlow(t *testing.T) {
+	auth := NewGoogleAuth(nil, logrus.New())
+
+	authURL, err := auth.StartAddAccountFlow()
+	require.NoError(t, err)
+	parsed, err := url.Parse(authURL)
+	require.NoError(t, err)
+	state := parsed.Query().Get("state")
+	require.NotEmpty(t, state)
+	assert.Equal(t, "consent", parsed.Query().Get("prompt"))
+
+	// state只能使用一次
+	assert.True(t, auth.accountFlows.consume(state))
+	assert.False(t, auth.accountFlows.consume(state))
+	assert.False(t, auth.accountFlows.consume(""))
+}
+
+func TestGoogleAuth_AddAccountConcurrent(t *testing.T) {
+	auth := NewGoogleAuth(nil, logrus.New())
+
+	// 运行时添加账号与读取认证状态并发进行，配合 -race 检查数据竞争
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			_, err := auth.AddAccount(encodeTestToken(t, "a"))
+			assert.NoError(t, err)
+		}()
+		go func() {
+			defer wg.Done()
+			auth.IsInitialized()
+			auth.IsAuthComplete()
+			auth.GetTokenAsBase64()
+			auth.GetToken()
+			auth.Health(context.Background())
+		}()
+	}
+	wg.Wait()
+
+	assert.True(t, auth.IsInitialized())
+	assert.Equal(t, 4, auth.AccountCount())
+}
@@ -37,11 +37,14 @@ const (
 
 // GoogleAuth Google OAuth2认证管理器 (兼容gemini-core.js)
 type GoogleAuth struct {
-	redirectURL string
-	tokens      []string // Base64编码的token列表
-	tokenSource oauth2.TokenSource
-	logger      *logrus.Logger
-	initialized bool
+	redirectURL     string
+	tokens          []string // Base64编码的token列表
+	credentialFiles []string // gemini-cli / gcloud ADC 凭据文件
+	tokenSource     oauth2.TokenSource
+	pool            accountPool // 多账号轮询池
+	reauth          reauthState // 刷新令牌失效后的重新授权状态
+	logger          *logrus.Logger
+	initialized     bool
 	// OAuth2相关
 	oauthConfig   *oauth2.Config
 	currentTokens *oauth2.Token
@@ -55,6 +58,13 @@ type GoogleAuth struct {
 	tokenBase64 string // Token Base64编码内容
 	// 回调函数，在获取到token时保存配置
 	onTokenReceived func(clientID string, token *oauth2.Token, googleAuth *GoogleAuth) error
+	// 账号被隔离时的通知回调
+	onAccountQuarantined func(index int, reason string)
+	// 通过管理接口添加或移除账号时的回调，用于保存配置
+	onAccountAdded   func(tokenBase64 string) error
+	onAccountRemoved func(tokenBase64 string) error
+	// 管理接口发起的添加账号授权，state -> 过期时间
+	accountFlows accountFlows
 	// 错误通道，用于通知严重错误
 	fatalErrorChan chan error
 }
@@ -67,12 +77,13 @@ func NewGoogleAuth(authConfig *models.GoogleAuthConfig, logger *logrus.Logger) *
 
 	// 提取配置
 	var redirectURL string
-	var tokens []string
+	var tokens, credentialFiles []string
 	var projectID, location, tokenBase64 string
 
 	if authConfig != nil {
 		redirectURL = authConfig.RedirectURL
 		tokens = authConfig.OAuthTokens
+		credentialFiles = authConfig.CredentialFiles
 		projectID = authConfig.ProjectID
 		location = authConfig.Location
 	}
@@ -83,15 +94,28 @@ func NewGoogleAuth(authConfig *models.GoogleAuthConfig, logger *logrus.Logger) *
 	}
 
 	auth := &GoogleAuth{
-		redirectURL:    redirectURL,
-		tokens:         tokens,
-		projectID:      projectID,
-		location:       location,
-		tokenBase64:    tokenBase64,
-		logger:         logger,
-		authComplete:   make(chan bool, 1),
-		fatalErrorChan: make(chan error, 1),
+		redirectURL:     redirectURL,
+		tokens:          tokens,
+		credentialFiles: credentialFiles,
+		projectID:       projectID,
+		location:        location,
+		tokenBase64:     tokenBase64,
+		logger:          logger,
+		authComplete:    make(chan bool, 1),
+		fatalErrorChan:  make(chan error, 1),
+	}
+
+	// 每日请求上限和配额重置时区
+	var quotaTimezone string
+	if authConfig != nil {
+		auth.pool.dailyCap = authConfig.AccountDailyCap
+		quotaTimezone = authConfig.QuotaTimezone
+	}
+	quotaLocation, err := loadQuotaLocation(quotaTimezone)
+	if err != nil {
+		logger.WithError(err).Warnf("Using %s for daily quota resets", DefaultQuotaTimezone)
 	}
+	auth.pool.quotaLocation = quotaLocation
 
 	// 生成与ClientID绑定的动态路径
 	auth.generateCallbackPath(OAuthClientID)
@@ -172,34 +196,66 @@ func (g *GoogleAuth) GetClientBinding() string {
 
 // Initialize 初始化OAuth2认证
 func (g *GoogleAuth) Initialize(ctx context.Context) error {
-	if g.initialized {
+	if g.IsInitialized() {
 		return nil
 	}
 
 	g.logger.Debug("Initializing OAuth2 authentication...")
 
-	// 优先尝试从配置中加载OAuth2 tokens
-	if len(g.tokens) > 0 {
-		for _, tokenBase64 := range g.tokens {
-			if err := g.loadTokenFromBase64(tokenBase64); err == nil {
-				g.logger.Info("Successfully loaded OAuth2 token from base64")
-				break
-			} else {
-				g.logger.WithError(err).Debug("Failed to load token from base64, trying next")
-			}
+	// 优先尝试从配置中加载OAuth2 tokens，所有有效token组成账号池，第一个作为主账号
+	primary := g.currentToken()
+	var accounts []*tokenAccount
+	for i, tokenBase64 := range g.tokens {
+		token, err := parseTokenBase64(tokenBase64)
+		if err != nil {
+			g.logger.WithError(err).Debugf("Failed to load token %d from base64, skipping", i)
+			continue
 		}
+		if primary == nil {
+			primary = token
+		}
+		accounts = append(accounts, &tokenAccount{source: g.oauthConfig.TokenSource(ctx, token), tokenBase64: tokenBase64})
+	}
+	if len(accounts) > 0 {
+		g.logger.Infof("Successfully loaded %d OAuth2 token(s) from base64", len(accounts))
+	}
+
+	// 直接使用gemini-cli或gcloud已保存的凭据，无需重新授权
+	for _, path := range g.credentialFiles {
+		source, token, err := g.loadCredentialsFile(ctx, path)
+		if err != nil {
+			g.logger.WithError(err).Warn("Skipping credentials file")
+			continue
+		}
+		if primary == nil {
+			primary = token
+		}
+		accounts = append(accounts, &tokenAccount{source: source})
+		g.logger.Infof("Loaded OAuth2 credentials from %s", resolveCredentialsPath(path))
 	}
 
 	// 如果没有有效token，需要启动OAuth流程
-	if g.currentTokens == nil {
+	if primary == nil {
 		g.logger.Warn("No valid OAuth2 token found, OAuth flow required")
 		return fmt.Errorf("OAuth2 authentication required, please call StartOAuthFlow")
 	}
 
 	// 创建token source
-	g.tokenSource = g.oauthConfig.TokenSource(ctx, g.currentTokens)
-
+	var source oauth2.TokenSource
+	if len(accounts) > 0 {
+		source = accounts[0].source
+	} else {
+		source = g.oauthConfig.TokenSource(ctx, primary)
+	}
+	// 认证状态与账号池共用同一把锁，运行时通过管理接口添加账号时不会产生数据竞争
+	g.pool.mu.Lock()
+	g.currentTokens = primary
+	g.tokenSource = source
+	g.pool.accounts = accounts
+	g.pool.next = 0
 	g.initialized = true
+	g.pool.mu.Unlock()
+
 	g.logger.Info("OAuth2 authentication initialized successfully")
 
 	return nil
@@ -207,23 +263,43 @@ func (g *GoogleAuth) Initialize(ctx context.Context) error {
 
 // loadTokenFromBase64 从 Base64编码的token文件内容加载OAuth2 token
 func (g *GoogleAuth) loadTokenFromBase64(tokenBase64 string) error {
+	token, err := parseTokenBase64(tokenBase64)
+	if err != nil {
+		return err
+	}
+
+	g.setCurrentToken(token)
+	g.logger.Debug("Successfully loaded OAuth2 token from base64")
+	return nil
+}
+
+// parseTokenBase64 解析Base64编码的OAuth2 token
+func parseTokenBase64(tokenBase64 string) (*oauth2.Token, error) {
 	decoded, err := base64.StdEncoding.DecodeString(tokenBase64)
 	if err != nil {
-		return fmt.Errorf("failed to decode base64 token: %w", err)
+		return nil, fmt.Errorf("failed to decode base64 token: %w", err)
 	}
 
 	var token oauth2.Token
 	if err := json.Unmarshal(decoded, &token); err != nil {
-		return fmt.Errorf("failed to parse OAuth2 token: %w", err)
+		return nil, fmt.Errorf("failed to parse OAuth2 token: %w", err)
 	}
 
 	// 验证token是否有效
 	if token.AccessToken == "" {
-		return fmt.Errorf("invalid token: missing access_token")
+		return nil, fmt.Errorf("invalid token: missing access_token")
 	}
+	return &token, nil
+}
 
-	g.currentTokens = &token
-	g.logger.Debug("Successfully loaded OAuth2 token from base64")
+// SetRedirectBaseURL 更换OAuth回调的基础地址（如临时隧道的公网地址），回调路径不变
+func (g *GoogleAuth) SetRedirectBaseURL(baseURL string) error {
+	redirectURL := g.buildDynamicRedirectURL(baseURL)
+	if redirectURL == "" {
+		return fmt.Errorf("invalid redirect base URL: %s", baseURL)
+	}
+	g.redirectURL = baseURL
+	g.oauthConfig.RedirectURL = redirectURL
 	return nil
 }
 
@@ -244,6 +320,7 @@ func (g *GoogleAuth) GenerateAuthURL() string {
 func (g *GoogleAuth) RegisterCallbackHandler(mux *http.ServeMux) {
 	g.logger.Infof("Registering OAuth callback handler at path: %s", g.callbackPath)
 	mux.HandleFunc(g.callbackPath, g.handleOAuthCallback)
+	mux.HandleFunc("/oauth/status", g.handleOAuthStatus)
 
 	// 添加通用OAuth路径处理，用于调试
 	mux.HandleFunc("/oauth/", g.handleOAuthDebug)
@@ -335,7 +412,16 @@ func (g *GoogleAuth) handleOAuthCallback(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	g.currentTokens = token
+	// 管理接口发起的授权：新账号加入账号池，不替换主账号
+	if g.accountFlows.consume(r.URL.Query().Get("state")) {
+		g.handleAddedAccount(w, token)
+		return
+	}
+
+	g.setCurrentToken(token)
+	if g.ReauthRequired() {
+		g.restoreWithToken(token)
+	}
 	g.logger.WithFields(map[string]any{
 		"client_id":  OAuthClientID,
 		"expires_at": token.Expiry.Format(time.RFC3339),
@@ -400,12 +486,23 @@ func (g *GoogleAuth) WaitForAuth(timeout time.Duration) error {
 
 // GetToken 获取访问token
 func (g *GoogleAuth) GetToken() (*oauth2.Token, error) {
-	if !g.initialized {
+	if !g.IsInitialized() {
 		return nil, fmt.Errorf("authentication not initialized")
 	}
+	if g.ReauthRequired() {
+		return nil, ErrReauthRequired
+	}
+
+	g.pool.mu.Lock()
+	source := g.tokenSource
+	g.pool.mu.Unlock()
 
-	token, err := g.tokenSource.Token()
+	token, err := source.Token()
 	if err != nil {
+		if isTokenRevoked(err) {
+			g.requireReauth(err)
+			return nil, fmt.Errorf("%w: %v", ErrReauthRequired, err)
+		}
 		return nil, fmt.Errorf("failed to get token: %w", err)
 	}
 
@@ -414,11 +511,12 @@ func (g *GoogleAuth) GetToken() (*oauth2.Token, error) {
 
 // GetTokenAsBase64 获取当前token的base64编码
 func (g *GoogleAuth) GetTokenAsBase64() (string, error) {
-	if g.currentTokens == nil {
+	token := g.currentToken()
+	if token == nil {
 		return "", fmt.Errorf("no OAuth2 token available")
 	}
 
-	tokenJSON, err := json.Marshal(g.currentTokens)
+	tokenJSON, err := json.Marshal(token)
 	if err != nil {
 		return "", fmt.Errorf("failed to marshal token: %w", err)
 	}
@@ -428,17 +526,34 @@ func (g *GoogleAuth) GetTokenAsBase64() (string, error) {
 
 // IsAuthComplete 检查认证是否完成
 func (g *GoogleAuth) IsAuthComplete() bool {
-	return g.currentTokens != nil && g.currentTokens.Valid()
+	token := g.currentToken()
+	return token != nil && token.Valid()
 }
 
 // IsInitialized 检查是否已初始化
 func (g *GoogleAuth) IsInitialized() bool {
+	g.pool.mu.Lock()
+	defer g.pool.mu.Unlock()
 	return g.initialized
 }
 
+// currentToken 返回主账号的token；initialized、currentTokens、tokenSource 可能在运行时添加账号时修改，都由pool.mu保护
+func (g *GoogleAuth) currentToken() *oauth2.Token {
+	g.pool.mu.Lock()
+	defer g.pool.mu.Unlock()
+	return g.currentTokens
+}
+
+// setCurrentToken 设置主账号的token
+func (g *GoogleAuth) setCurrentToken(token *oauth2.Token) {
+	g.pool.mu.Lock()
+	defer g.pool.mu.Unlock()
+	g.currentTokens = token
+}
+
 // Health 健康检查
 func (g *GoogleAuth) Health(ctx context.Context) error {
-	if !g.initialized {
+	if !g.IsInitialized() {
 		return fmt.Errorf("authentication not initialized")
 	}
 
@@ -482,7 +597,7 @@ func (g *GoogleAuth) GetFatalErrorChan() <-chan error {
 
 // DiscoverProjectID 尝试发现Google Cloud项目ID (按照gemini-core.js实现)
 func (g *GoogleAuth) DiscoverProjectID(ctx context.Context) (string, error) {
-	if g.currentTokens == nil || !g.currentTokens.Valid() {
+	if !g.IsAuthComplete() {
 		return "", fmt.Errorf("no valid OAuth token available for project discovery")
 	}
 
@@ -515,7 +630,7 @@ func (g *GoogleAuth) DiscoverProjectID(ctx context.Context) (string, error) {
 
 // callCodeAssistAPI 调用Code Assist API
 func (g *GoogleAuth) callCodeAssistAPI(ctx context.Context, method string, body map[string]interface{}) (string, error) {
-	client := g.oauthConfig.Client(ctx, g.currentTokens)
+	client := g.oauthConfig.Client(ctx, g.currentToken())
 
 	url := fmt.Sprintf("%s/%s:%s", CodeAssistEndpoint, CodeAssistAPIVersion, method)
 
@@ -601,7 +716,7 @@ func (g *GoogleAuth) onboardUser(ctx context.Context) (string, error) {
 
 // callOnboardAPI 调用onboardUser API
 func (g *GoogleAuth) callOnboardAPI(ctx context.Context, body map[string]interface{}) (string, error) {
-	client := g.oauthConfig.Client(ctx, g.currentTokens)
+	client := g.oauthConfig.Client(ctx, g.currentToken())
 
 	url := fmt.Sprintf("%s/%s:onboardUser", CodeAssistEndpoint, CodeAssistAPIVersion)
 
@@ -0,0 +1,148 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// 凭据文件的简写，分别指向gemini-cli和gcloud应用默认凭据（ADC）的默认位置
+const (
+	CredentialsGeminiCLI = "gemini-cli"
+	CredentialsADC       = "adc"
+)
+
+// externalCredentials gemini-cli的oauth_creds.json和gcloud ADC（authorized_user）文件中的字段
+type externalCredentials struct {
+	// gcloud ADC
+	Type         string `json:"type,omitempty"`
+	ClientID     string `json:"client_id,omitempty"`
+	ClientSecret string `json:"client_secret,omitempty"`
+	// 两种格式共有
+	RefreshToken string `json:"refresh_token"`
+	// gemini-cli
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	Scope       string `json:"scope,omitempty"`
+	ExpiryDate  int64  `json:"expiry_date"` // 毫秒时间戳
+}
+
+// GeminiCLICredentialsPath 返回gemini-cli保存OAuth凭据的默认位置 ~/.gemini/oauth_creds.json
+func GeminiCLICredentialsPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".gemini", "oauth_creds.json")
+}
+
+// ADCPath 返回应用默认凭据的位置：优先 GOOGLE_APPLICATION_CREDENTIALS，
+// 否则为 gcloud auth application-default login 写入的默认文件
+func ADCPath() string {
+	if path := os.Getenv("GOOGLE_APPLICATION_CREDENTIALS"); path != "" {
+		return path
+	}
+	if runtime.GOOS == "windows" {
+		return filepath.Join(os.Getenv("APPDATA"), "gcloud", "application_default_credentials.json")
+	}
+	if dir := os.Getenv("CLOUDSDK_CONFIG"); dir != "" {
+		return filepath.Join(dir, "application_default_credentials.json")
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".config", "gcloud", "application_default_credentials.json")
+}
+
+// resolveCredentialsPath 展开凭据文件简写和 ~ 前缀
+func resolveCredentialsPath(path string) string {
+	switch path {
+	case CredentialsGeminiCLI:
+		return GeminiCLICredentialsPath()
+	case CredentialsADC:
+		return ADCPath()
+	}
+	if len(path) > 1 && path[0] == '~' && (path[1] == '/' || path[1] == '\\') {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, path[2:])
+		}
+	}
+	return path
+}
+
+// loadCredentialsFile 读取gemini-cli或gcloud ADC凭据文件并创建token source
+// gemini-cli与本代理使用相同的OAuth客户端；ADC使用文件中的客户端刷新token
+func (g *GoogleAuth) loadCredentialsFile(ctx context.Context, path string) (oauth2.TokenSource, *oauth2.Token, error) {
+	resolved := resolveCredentialsPath(path)
+	data, err := os.ReadFile(resolved)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read credentials file %s: %w", resolved, err)
+	}
+
+	var creds externalCredentials
+	if err := json.Unmarshal(data, &creds); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse credentials file %s: %w", resolved, err)
+	}
+	if creds.RefreshToken == "" {
+		if creds.Type != "" && creds.Type != "authorized_user" {
+			return nil, nil, fmt.Errorf("unsupported credentials type %q in %s, only user credentials are supported", creds.Type, resolved)
+		}
+		return nil, nil, fmt.Errorf("credentials file %s has no refresh_token", resolved)
+	}
+
+	token := &oauth2.Token{
+		AccessToken:  creds.AccessToken,
+		TokenType:    creds.TokenType,
+		RefreshToken: creds.RefreshToken,
+	}
+	if creds.ExpiryDate > 0 {
+		token.Expiry = time.UnixMilli(creds.ExpiryDate)
+	}
+
+	oauthConfig := g.oauthConfig
+	if creds.Type == "authorized_user" {
+		if creds.ClientID == "" || creds.ClientSecret == "" {
+			return nil, nil, fmt.Errorf("credentials file %s is missing client_id or client_secret", resolved)
+		}
+		oauthConfig = &oauth2.Config{
+			ClientID:     creds.ClientID,
+			ClientSecret: creds.ClientSecret,
+			Scopes:       []string{CloudScope},
+			Endpoint:     g.oauthConfig.Endpoint,
+		}
+	}
+	return oauthConfig.TokenSource(ctx, token), token, nil
+}
+
+// ExportGeminiCLICredentials 将配置中Base64编码的token转换为gemini-cli的oauth_creds.json格式
+// 两者使用相同的OAuth客户端，导出的refresh_token可以直接被gemini-cli刷新
+func ExportGeminiCLICredentials(tokenBase64 string) ([]byte, error) {
+	token, err := parseTokenBase64(tokenBase64)
+	if err != nil {
+		return nil, err
+	}
+	if token.RefreshToken == "" {
+		return nil, fmt.Errorf("token has no refresh_token, gemini-cli would not be able to refresh it")
+	}
+
+	creds := externalCredentials{
+		AccessToken:  token.AccessToken,
+		RefreshToken: token.RefreshToken,
+		TokenType:    token.TokenType,
+		Scope:        CloudScope,
+	}
+	if creds.TokenType == "" {
+		creds.TokenType = "Bearer"
+	}
+	if !token.Expiry.IsZero() {
+		creds.ExpiryDate = token.Expiry.UnixMilli()
+	}
+	return json.MarshalIndent(creds, "", "  ")
+}
@@ -0,0 +1,184 @@
+package auth
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"golang.org/x/oauth2"
+)
+
+// DefaultAccountCooldown 账号被限流且上游未返回Retry-After时的冷却时间
+const DefaultAccountCooldown = time.Minute
+
+// tokenAccount 账号池中的一个OAuth账号
+type tokenAccount struct {
+	source        oauth2.TokenSource
+	cooldownUntil time.Time
+	revoked       bool   // 刷新令牌已失效，不再分配
+	removed       bool   // 已通过管理接口移出账号池，不再分配
+	quarantined   string // 账号被封禁或标记滥用的原因，非空时不再分配
+	tokenBase64   string // 加载账号使用的Base64 token，凭据文件加载的账号为空
+	quotaDay      string // requestsToday对应的配额日期
+	requestsToday int
+}
+
+// available 账号是否可以分配
+func (a *tokenAccount) available() bool {
+	return !a.revoked && !a.removed && a.quarantined == ""
+}
+
+// accountPool 多账号轮询池，跳过冷却中和已达到每日请求上限的账号
+type accountPool struct {
+	mu       sync.Mutex
+	accounts []*tokenAccount
+	next     int
+
+	dailyCap      int            // 每个账号每天的请求上限，0表示不限制
+	quotaLocation *time.Location // 每日配额重置的时区
+}
+
+// AccountCount 返回账号池中可用的账号数量
+func (g *GoogleAuth) AccountCount() int {
+	g.pool.mu.Lock()
+	defer g.pool.mu.Unlock()
+	return g.pool.availableLocked()
+}
+
+// availableLocked 统计可分配的账号数量，调用方需持有pool.mu
+func (p *accountPool) availableLocked() int {
+	count := 0
+	for _, account := range p.accounts {
+		if account.available() {
+			count++
+		}
+	}
+	return count
+}
+
+// NextToken 按轮询顺序获取下一个未冷却账号的访问token，返回的账号序号用于上报限流
+// 所有账号都在冷却时使用最早恢复的账号；没有账号池时退回GetToken，序号为-1
+// 刷新令牌失效或被隔离的账号移出轮询，全部不可用时返回ErrReauthRequired
+// 达到每日请求上限的账号在配额重置前不再分配，全部达到上限时返回ErrDailyQuotaExhausted
+func (g *GoogleAuth) NextToken() (*oauth2.Token, int, error) {
+	if !g.IsInitialized() {
+		return nil, -1, fmt.Errorf("authentication not initialized")
+	}
+	if g.ReauthRequired() {
+		return nil, -1, ErrReauthRequired
+	}
+
+	g.pool.mu.Lock()
+	count := len(g.pool.accounts)
+	if count == 0 {
+		g.pool.mu.Unlock()
+		token, err := g.GetToken()
+		return token, -1, err
+	}
+
+	now := time.Now()
+	day := g.pool.quotaDayLocked(now)
+	index, capped := -1, 0
+	usable := make([]bool, count)
+	for i, account := range g.pool.accounts {
+		if !account.available() {
+			continue
+		}
+		if g.pool.cappedLocked(account, day) {
+			capped++
+			continue
+		}
+		usable[i] = true
+	}
+	for i := 0; i < count; i++ {
+		candidate := (g.pool.next + i) % count
+		if usable[candidate] && !now.Before(g.pool.accounts[candidate].cooldownUntil) {
+			index = candidate
+			break
+		}
+	}
+	if index < 0 {
+		for i, account := range g.pool.accounts {
+			if !usable[i] {
+				continue
+			}
+			if index < 0 || account.cooldownUntil.Before(g.pool.accounts[index].cooldownUntil) {
+				index = i
+			}
+		}
+	}
+	if index < 0 {
+		resetAt := g.pool.quotaResetAt(now)
+		g.pool.mu.Unlock()
+		if capped > 0 {
+			return nil, -1, fmt.Errorf("%w (cap %d requests/day), resets at %s", ErrDailyQuotaExhausted, g.pool.dailyCap, resetAt.Format(time.RFC3339))
+		}
+		return nil, -1, ErrReauthRequired
+	}
+	g.pool.next = (index + 1) % count
+	account := g.pool.accounts[index]
+	account.requestsToday++
+	if g.pool.dailyCap > 0 && account.requestsToday == g.pool.dailyCap {
+		g.logger.Warnf("OAuth account %d reached its daily cap of %d requests, paused until %s", index, g.pool.dailyCap, g.pool.quotaResetAt(now).Format(time.RFC3339))
+	}
+	g.pool.mu.Unlock()
+
+	token, err := account.source.Token()
+	if err != nil {
+		if isTokenRevoked(err) {
+			g.revokeAccount(account, err)
+			if g.ReauthRequired() {
+				return nil, index, fmt.Errorf("%w: %v", ErrReauthRequired, err)
+			}
+		}
+		return nil, index, fmt.Errorf("failed to get token for account %d: %w", index, err)
+	}
+	return token, index, nil
+}
+
+// CooldownAccount 将账号置为冷却，期间不再分配；duration<=0时使用DefaultAccountCooldown
+func (g *GoogleAuth) CooldownAccount(index int, duration time.Duration) {
+	if duration <= 0 {
+		duration = DefaultAccountCooldown
+	}
+
+	g.pool.mu.Lock()
+	defer g.pool.mu.Unlock()
+	if index < 0 || index >= len(g.pool.accounts) {
+		return
+	}
+	g.pool.accounts[index].cooldownUntil = time.Now().Add(duration)
+	g.logger.Warnf("OAuth account %d rate limited, cooling down for %s", index, duration)
+}
+
+// QuarantineAccount 上游判定账号被封禁或滥用时隔离该账号，不再分配请求，避免继续请求加重封禁
+// 隔离后通知运维；所有账号都被隔离时进入重新授权状态，通过 /oauth/status 授权新账号后恢复
+func (g *GoogleAuth) QuarantineAccount(index int, reason string) {
+	g.pool.mu.Lock()
+	if index < 0 || index >= len(g.pool.accounts) || g.pool.accounts[index].quarantined != "" {
+		g.pool.mu.Unlock()
+		return
+	}
+	g.pool.accounts[index].quarantined = reason
+	remaining := g.pool.availableLocked()
+	g.pool.mu.Unlock()
+
+	g.logger.WithFields(logrus.Fields{
+		"account":   index,
+		"reason":    reason,
+		"remaining": remaining,
+	}).Errorf("ALERT: OAuth account %d quarantined after the upstream reported it as blocked; check the account in Google Cloud Console", index)
+
+	if g.onAccountQuarantined != nil {
+		g.onAccountQuarantined(index, reason)
+	}
+	if remaining == 0 {
+		g.requireReauth(fmt.Errorf("all OAuth accounts quarantined: %s", reason))
+	}
+}
+
+// SetOnAccountQuarantined 设置账号被隔离时的通知回调，用于接入告警
+func (g *GoogleAuth) SetOnAccountQuarantined(callback func(index int, reason string)) {
+	g.onAccountQuarantined = callback
+}
@@ -0,0 +1,57 @@
+package auth
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/ba0gu0/gemini-go-proxy/pkg/models"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/oauth2"
+)
+
+func encodeTestToken(t *testing.T, accessToken string) string {
+	t.Helper()
+	data, err := json.Marshal(&oauth2.Token{AccessToken: accessToken, TokenType: "Bearer", Expiry: time.Now().Add(time.Hour)})
+	require.NoError(t, err)
+	return base64.StdEncoding.EncodeToString(data)
+}
+
+func TestGoogleAuth_AccountPoolRotation(t *testing.T) {
+	auth := NewGoogleAuth(&models.GoogleAuthConfig{OAuthTokens: []string{
+		encodeTestToken(t, "a"),
+		"invalid",
+		encodeTestToken(t, "b"),
+		encodeTestToken(t, "c"),
+	}}, logrus.New())
+	require.NoError(t, auth.Initialize(context.Background()))
+	assert.Equal(t, 3, auth.AccountCount())
+
+	// 主账号为第一个有效token
+	token, err := auth.GetToken()
+	require.NoError(t, err)
+	assert.Equal(t, "a", token.AccessToken)
+
+	next := func() string {
+		token, _, err := auth.NextToken()
+		require.NoError(t, err)
+		return token.AccessToken
+	}
+	assert.Equal(t, []string{"a", "b", "c", "a"}, []string{next(), next(), next(), next()})
+
+	// 冷却中的账号被跳过
+	auth.CooldownAccount(1, time.Hour)
+	assert.Equal(t, []string{"c", "a", "c"}, []string{next(), next(), next()})
+
+	// 全部冷却时使用最早恢复的账号
+	auth.CooldownAccount(0, 3*time.Hour)
+	auth.CooldownAccount(2, 2*time.Hour)
+	token, index, err := auth.NextToken()
+	require.NoError(t, err)
+	assert.Equal(t, "b", token.AccessToken)
+	assert.Equal(t, 1, index)
+}
@@ -0,0 +1,70 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"time"
+	_ "time/tzdata" // 无系统时区数据库时仍能加载配额重置时区
+)
+
+// DefaultQuotaTimezone Google每日配额按太平洋时间零点重置
+const DefaultQuotaTimezone = "America/Los_Angeles"
+
+// ErrDailyQuotaExhausted 所有账号都已达到每日请求上限
+var ErrDailyQuotaExhausted = errors.New("daily request cap reached for all OAuth accounts")
+
+// loadQuotaLocation 加载配额重置时区，为空或无效时使用太平洋时间
+func loadQuotaLocation(name string) (*time.Location, error) {
+	if name == "" {
+		name = DefaultQuotaTimezone
+	}
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		fallback, _ := time.LoadLocation(DefaultQuotaTimezone)
+		return fallback, fmt.Errorf("invalid quota timezone %q: %w", name, err)
+	}
+	return location, nil
+}
+
+// quotaDayLocked 返回配额时区下的当前日期，调用方需持有pool.mu
+func (p *accountPool) quotaDayLocked(now time.Time) string {
+	return now.In(p.quotaLocation).Format(time.DateOnly)
+}
+
+// cappedLocked 账号今天是否已达到每日请求上限，跨过配额日期时重置计数，调用方需持有pool.mu
+func (p *accountPool) cappedLocked(account *tokenAccount, day string) bool {
+	if account.quotaDay != day {
+		account.quotaDay = day
+		account.requestsToday = 0
+	}
+	return p.dailyCap > 0 && account.requestsToday >= p.dailyCap
+}
+
+// quotaResetAt 下一次配额重置时间（配额时区的次日零点）
+func (p *accountPool) quotaResetAt(now time.Time) time.Time {
+	local := now.In(p.quotaLocation)
+	return time.Date(local.Year(), local.Month(), local.Day()+1, 0, 0, 0, 0, p.quotaLocation)
+}
+
+// DailyQuotaExhausted 所有可用账号是否都已达到每日请求上限，返回配额重置时间
+func (g *GoogleAuth) DailyQuotaExhausted() (bool, time.Time) {
+	g.pool.mu.Lock()
+	defer g.pool.mu.Unlock()
+	if g.pool.dailyCap <= 0 {
+		return false, time.Time{}
+	}
+
+	now := time.Now()
+	day := g.pool.quotaDayLocked(now)
+	exhausted := false
+	for _, account := range g.pool.accounts {
+		if !account.available() {
+			continue
+		}
+		if !g.pool.cappedLocked(account, day) {
+			return false, time.Time{}
+		}
+		exhausted = true
+	}
+	return exhausted, g.pool.quotaResetAt(now)
+}
@@ -0,0 +1,64 @@
+package auth
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ba0gu0/gemini-go-proxy/pkg/models"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGoogleAuth_AccountDailyCap(t *testing.T) {
+	auth := NewGoogleAuth(&models.GoogleAuthConfig{
+		OAuthTokens:     []string{encodeTestToken(t, "a"), encodeTestToken(t, "b")},
+		AccountDailyCap: 2,
+	}, logrus.New())
+	require.NoError(t, auth.Initialize(context.Background()))
+
+	next := func() string {
+		token, _, err := auth.NextToken()
+		require.NoError(t, err)
+		return token.AccessToken
+	}
+	assert.Equal(t, []string{"a", "b", "a"}, []string{next(), next(), next()})
+	exhausted, _ := auth.DailyQuotaExhausted()
+	assert.False(t, exhausted)
+
+	// 账号a达到上限后只使用账号b
+	assert.Equal(t, "b", next())
+	_, _, err := auth.NextToken()
+	assert.ErrorIs(t, err, ErrDailyQuotaExhausted)
+	assert.False(t, auth.ReauthRequired())
+
+	exhausted, resetAt := auth.DailyQuotaExhausted()
+	assert.True(t, exhausted)
+	assert.True(t, resetAt.After(time.Now()))
+	assert.LessOrEqual(t, time.Until(resetAt), 24*time.Hour)
+
+	status := auth.Status()
+	assert.Equal(t, 2, status.DailyCap)
+	assert.Equal(t, []int{2, 2}, status.RequestsToday)
+
+	// 跨过配额日期后计数重置
+	auth.pool.mu.Lock()
+	auth.pool.accounts[1].quotaDay = "2000-01-01"
+	auth.pool.mu.Unlock()
+	assert.Equal(t, "b", next())
+}
+
+func TestLoadQuotaLocation(t *testing.T) {
+	location, err := loadQuotaLocation("")
+	require.NoError(t, err)
+	assert.Equal(t, DefaultQuotaTimezone, location.String())
+
+	location, err = loadQuotaLocation("UTC")
+	require.NoError(t, err)
+	assert.Equal(t, "UTC", location.String())
+
+	location, err = loadQuotaLocation("Not/AZone")
+	assert.Error(t, err)
+	assert.Equal(t, DefaultQuotaTimezone, location.String())
+}
@@ -0,0 +1,179 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// ErrReauthRequired 刷新令牌已被撤销或过期（或所有账号都被隔离），需要重新完成OAuth授权
+var ErrReauthRequired = errors.New("oauth re-authorization required")
+
+// 认证状态
+const (
+	AuthStatusOK             = "ok"
+	AuthStatusAuthRequired   = "auth_required"   // 尚未完成首次授权
+	AuthStatusReauthRequired = "reauth_required" // 刷新令牌失效，等待重新授权
+)
+
+// reauthState 刷新令牌失效后的重新授权状态
+type reauthState struct {
+	mu       sync.Mutex
+	required bool
+	reason   string
+	since    time.Time
+}
+
+// AuthStatus OAuth认证状态，由 /oauth/status 返回
+type AuthStatus struct {
+	Status      string `json:"status"`
+	Reason      string `json:"reason,omitempty"`
+	Since       string `json:"since,omitempty"`
+	AuthURL     string `json:"auth_url,omitempty"` // 需要授权时的授权地址
+	Accounts    int    `json:"accounts"`
+	Unavailable int    `json:"unavailable,omitempty"` // 刷新令牌失效或被隔离的账号数
+	// 每日请求上限，未配置时为空
+	DailyCap      int    `json:"daily_cap,omitempty"`
+	RequestsToday []int  `json:"requests_today,omitempty"` // 各账号今天的请求数
+	QuotaResetAt  string `json:"quota_reset_at,omitempty"`
+}
+
+// isTokenRevoked 刷新token时Google返回invalid_grant，表示刷新令牌已被撤销或过期
+func isTokenRevoked(err error) bool {
+	var retrieveErr *oauth2.RetrieveError
+	if !errors.As(err, &retrieveErr) {
+		return false
+	}
+	return retrieveErr.ErrorCode == "invalid_grant" || strings.Contains(string(retrieveErr.Body), "invalid_grant")
+}
+
+// ReauthRequired 是否正在等待重新授权
+func (g *GoogleAuth) ReauthRequired() bool {
+	g.reauth.mu.Lock()
+	defer g.reauth.mu.Unlock()
+	return g.reauth.required
+}
+
+// requireReauth 进入重新授权状态，授权完成前获取token直接返回ErrReauthRequired
+func (g *GoogleAuth) requireReauth(err error) {
+	g.reauth.mu.Lock()
+	if g.reauth.required {
+		g.reauth.mu.Unlock()
+		return
+	}
+	g.reauth.required = true
+	g.reauth.reason = err.Error()
+	g.reauth.since = time.Now()
+	g.reauth.mu.Unlock()
+
+	g.logger.WithError(err).Errorf("No usable OAuth account left, requests will fail with 503 until re-authorized at: %s", g.reauthURL())
+}
+
+// revokeAccount 将刷新令牌失效的账号移出轮询，所有账号都失效时进入重新授权状态
+func (g *GoogleAuth) revokeAccount(account *tokenAccount, err error) {
+	g.pool.mu.Lock()
+	account.revoked = true
+	remaining := g.pool.availableLocked()
+	g.pool.mu.Unlock()
+
+	if remaining > 0 {
+		g.logger.WithError(err).Warnf("OAuth account refresh token revoked, removed from rotation (%d account(s) left)", remaining)
+		return
+	}
+	g.requireReauth(err)
+}
+
+// reauthURL 重新授权地址，强制显示同意页面以获得新的刷新令牌
+func (g *GoogleAuth) reauthURL() string {
+	return g.oauthConfig.AuthCodeURL("", oauth2.AccessTypeOffline, oauth2.ApprovalForce)
+}
+
+// Status 返回当前认证状态，需要授权时包含授权地址
+func (g *GoogleAuth) Status() AuthStatus {
+	status := AuthStatus{Status: AuthStatusOK}
+
+	g.pool.mu.Lock()
+	status.Accounts = g.pool.availableLocked()
+	status.Unavailable = len(g.pool.accounts) - status.Accounts
+	if g.pool.dailyCap > 0 {
+		now := time.Now()
+		day := g.pool.quotaDayLocked(now)
+		status.DailyCap = g.pool.dailyCap
+		status.QuotaResetAt = g.pool.quotaResetAt(now).Format(time.RFC3339)
+		status.RequestsToday = make([]int, len(g.pool.accounts))
+		for i, account := range g.pool.accounts {
+			g.pool.cappedLocked(account, day)
+			status.RequestsToday[i] = account.requestsToday
+		}
+	}
+	if status.Accounts == 0 && len(g.pool.accounts) == 0 && g.initialized {
+		status.Accounts = 1
+	}
+	initialized := g.initialized
+	g.pool.mu.Unlock()
+
+	g.reauth.mu.Lock()
+	defer g.reauth.mu.Unlock()
+	switch {
+	case g.reauth.required:
+		status.Status = AuthStatusReauthRequired
+		status.Reason = g.reauth.reason
+		status.Since = g.reauth.since.Format(time.RFC3339)
+		status.Accounts = 0
+		status.AuthURL = g.reauthURL()
+	case !initialized:
+		status.Status = AuthStatusAuthRequired
+		status.AuthURL = g.reauthURL()
+	}
+	return status
+}
+
+// handleOAuthStatus 返回认证状态，等待授权时提供授权地址
+func (g *GoogleAuth) handleOAuthStatus(w http.ResponseWriter, r *http.Request) {
+	status := g.Status()
+	w.Header().Set("Content-Type", "application/json")
+	if status.Status != AuthStatusOK {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(status)
+}
+
+// restoreWithToken 使用授权回调获得的token恢复账号池，退出重新授权状态，无需重启
+func (g *GoogleAuth) restoreWithToken(token *oauth2.Token) {
+	source := g.oauthConfig.TokenSource(context.Background(), token)
+
+	g.pool.mu.Lock()
+	accounts := []*tokenAccount{{source: source}}
+	for _, account := range g.pool.accounts {
+		if account.available() {
+			accounts = append(accounts, account)
+		}
+	}
+	g.pool.accounts = accounts
+	g.pool.next = 0
+	g.tokenSource = source
+	g.initialized = true
+	g.pool.mu.Unlock()
+
+	g.clearReauth()
+}
+
+// clearReauth 退出重新授权状态
+func (g *GoogleAuth) clearReauth() {
+	g.reauth.mu.Lock()
+	wasRequired := g.reauth.required
+	g.reauth.required = false
+	g.reauth.reason = ""
+	g.reauth.since = time.Time{}
+	g.reauth.mu.Unlock()
+
+	if wasRequired {
+		g.logger.Info("OAuth re-authorization completed, resuming requests")
+	}
+}
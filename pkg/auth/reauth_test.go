@@ -0,0 +1,94 @@
+package auth
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ba0gu0/gemini-go-proxy/pkg/models"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/oauth2"
+)
+
+func encodeExpiredToken(t *testing.T, accessToken string) string {
+	t.Helper()
+	data, err := json.Marshal(&oauth2.Token{
+		AccessToken:  accessToken,
+		RefreshToken: "refresh-" + accessToken,
+		TokenType:    "Bearer",
+		Expiry:       time.Now().Add(-time.Hour),
+	})
+	require.NoError(t, err)
+	return base64.StdEncoding.EncodeToString(data)
+}
+
+func TestGoogleAuth_ReauthOnRevokedRefreshToken(t *testing.T) {
+	tokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error":"invalid_grant","error_description":"Token has been expired or revoked."}`))
+	}))
+	defer tokenServer.Close()
+
+	auth := NewGoogleAuth(&models.GoogleAuthConfig{OAuthTokens: []string{
+		encodeExpiredToken(t, "a"),
+		encodeTestToken(t, "b"),
+	}}, logrus.New())
+	auth.oauthConfig.Endpoint.TokenURL = tokenServer.URL
+	require.NoError(t, auth.Initialize(context.Background()))
+	assert.Equal(t, AuthStatusOK, auth.Status().Status)
+
+	// 第一个账号刷新失败后移出轮询，其余账号继续可用
+	_, _, err := auth.NextToken()
+	require.Error(t, err)
+	assert.False(t, auth.ReauthRequired())
+	assert.Equal(t, 1, auth.AccountCount())
+
+	token, _, err := auth.NextToken()
+	require.NoError(t, err)
+	assert.Equal(t, "b", token.AccessToken)
+
+	// 主账号失效时进入重新授权状态
+	_, err = auth.GetToken()
+	assert.True(t, errors.Is(err, ErrReauthRequired))
+	assert.True(t, auth.ReauthRequired())
+
+	_, _, err = auth.NextToken()
+	assert.ErrorIs(t, err, ErrReauthRequired)
+
+	status := auth.Status()
+	assert.Equal(t, AuthStatusReauthRequired, status.Status)
+	assert.Contains(t, status.Reason, "invalid_grant")
+	assert.Contains(t, status.AuthURL, "prompt=consent")
+
+	// 重新授权后无需重启即可恢复
+	auth.restoreWithToken(&oauth2.Token{AccessToken: "new", TokenType: "Bearer", Expiry: time.Now().Add(time.Hour)})
+	assert.False(t, auth.ReauthRequired())
+	assert.Equal(t, AuthStatusOK, auth.Status().Status)
+
+	token, err = auth.GetToken()
+	require.NoError(t, err)
+	assert.Equal(t, "new", token.AccessToken)
+}
+
+func TestGoogleAuth_HandleOAuthStatus(t *testing.T) {
+	auth := NewGoogleAuth(nil, logrus.New())
+	mux := http.NewServeMux()
+	auth.RegisterCallbackHandler(mux)
+
+	recorder := httptest.NewRecorder()
+	mux.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/oauth/status", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, recorder.Code)
+
+	var status AuthStatus
+	require.NoError(t, json.NewDecoder(recorder.Body).Decode(&status))
+	assert.Equal(t, AuthStatusAuthRequired, status.Status)
+	assert.NotEmpty(t, status.AuthURL)
+}
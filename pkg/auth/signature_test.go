@@ -0,0 +1,85 @@
+package auth
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSignatureVerifier(t *testing.T) {
+	verifier := NewSignatureVerifier(map[string]string{"svc": "secret"}, time.Minute, 0)
+
+	// 每个子测试使用不同的查询参数，避免同一秒内签名相同被判定为重放
+	newSigned := func(query string) *http.Request {
+		req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions?"+query, strings.NewReader(`{"model":"m"}`))
+		require.NoError(t, SignRequest(req, "svc", "secret"))
+		return req
+	}
+
+	t.Run("valid signature restores body", func(t *testing.T) {
+		req := newSigned("case=1")
+		keyID, err := verifier.Verify(httptest.NewRecorder(), req)
+		require.NoError(t, err)
+		assert.Equal(t, "svc", keyID)
+
+		body, _ := io.ReadAll(req.Body)
+		assert.Equal(t, `{"model":"m"}`, string(body))
+	})
+
+	t.Run("replayed signature rejected", func(t *testing.T) {
+		req := newSigned("case=2")
+		replay := req.Clone(req.Context())
+		replay.Body = io.NopCloser(strings.NewReader(`{"model":"m"}`))
+
+		_, err := verifier.Verify(httptest.NewRecorder(), req)
+		require.NoError(t, err)
+		_, err = verifier.Verify(httptest.NewRecorder(), replay)
+		assert.ErrorContains(t, err, "already used")
+	})
+
+	t.Run("tampered body rejected", func(t *testing.T) {
+		req := newSigned("case=3")
+		req.Body = io.NopCloser(strings.NewReader(`{"model":"other"}`))
+		_, err := verifier.Verify(httptest.NewRecorder(), req)
+		assert.ErrorContains(t, err, "mismatch")
+	})
+
+	t.Run("stale timestamp rejected", func(t *testing.T) {
+		req := newSigned("case=4")
+		stale := strconv.FormatInt(time.Now().Add(-2*time.Minute).Unix(), 10)
+		req.Header.Set(SignatureTimestampHeader, stale)
+		req.Header.Set(SignatureHeader, RequestSignature("secret", stale, req.Method, req.URL.RequestURI(), []byte(`{"model":"m"}`)))
+		_, err := verifier.Verify(httptest.NewRecorder(), req)
+		assert.ErrorContains(t, err, "replay window")
+	})
+
+	t.Run("unknown key rejected", func(t *testing.T) {
+		req := newSigned("case=5")
+		req.Header.Set(SignatureKeyIDHeader, "other")
+		_, err := verifier.Verify(httptest.NewRecorder(), req)
+		assert.Error(t, err)
+	})
+}
+
+func TestSignatureVerifier_BodyLimit(t *testing.T) {
+	verifier := NewSignatureVerifier(map[string]string{"svc": "secret"}, time.Minute, 16)
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(`{"model":"m"}`))
+	require.NoError(t, SignRequest(req, "svc", "secret"))
+	_, err := verifier.Verify(httptest.NewRecorder(), req)
+	require.NoError(t, err)
+
+	// 超过上限时不读完请求体即拒绝
+	req = httptest.NewRequest(http.MethodPost, "/v1/chat/completions?large=1", strings.NewReader(`{"model":"a-much-longer-model-name"}`))
+	require.NoError(t, SignRequest(req, "svc", "secret"))
+	_, err = verifier.Verify(httptest.NewRecorder(), req)
+	var tooLarge *http.MaxBytesError
+	assert.ErrorAs(t, err, &tooLarge)
+}
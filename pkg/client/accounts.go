@@ -0,0 +1,107 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// accountKey 请求上下文中记录所用OAuth账号序号的键
+type accountKey struct{}
+
+// setAuthorization 从账号池轮询选择账号设置认证头，并在请求上下文中记录账号序号
+// 配置了请求间隔时等待到该账号的下一个发送时间
+func (c *GeminiClient) setAuthorization(req *http.Request) (*http.Request, error) {
+	token, account, err := c.auth.NextToken()
+	if err != nil {
+		return nil, err
+	}
+	if err := c.pacer.wait(req.Context(), account); err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token.AccessToken)
+	if account >= 0 {
+		req = req.WithContext(context.WithValue(req.Context(), accountKey{}, account))
+	}
+	return req, nil
+}
+
+// banPatterns 上游403响应中表示账号被封禁或标记滥用的关键字（不区分大小写）
+var banPatterns = []string{
+	"CONSUMER_SUSPENDED",
+	"has been suspended",
+	"account has been disabled",
+	"account is disabled",
+	"USER_DISABLED",
+	"terms of service",
+	"abusive",
+}
+
+// maxBanCheckBytes 检查封禁关键字时最多读取的响应体大小
+const maxBanCheckBytes = 64 << 10
+
+// cooldownAccount 上游返回429时将发起请求的账号置为冷却，返回账号被封禁时隔离该账号
+// 返回是否还有其他账号可以重试
+func (c *GeminiClient) cooldownAccount(req *http.Request, resp *http.Response) bool {
+	if c.auth == nil {
+		return false
+	}
+	account, ok := req.Context().Value(accountKey{}).(int)
+	if !ok {
+		return false
+	}
+
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests:
+		c.auth.CooldownAccount(account, retryAfter(resp.Header))
+		return c.auth.AccountCount() > 1
+	case http.StatusForbidden:
+		reason := c.banReason(resp)
+		if reason == "" {
+			return false
+		}
+		c.auth.QuarantineAccount(account, reason)
+		return c.auth.AccountCount() > 0
+	}
+	return false
+}
+
+// banReason 检查403响应体是否包含封禁关键字，返回匹配的关键字；响应体读取后恢复，调用方仍可读取
+func (c *GeminiClient) banReason(resp *http.Response) string {
+	peek, err := io.ReadAll(io.LimitReader(resp.Body, maxBanCheckBytes))
+	resp.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(peek), resp.Body), resp.Body}
+	if err != nil {
+		return ""
+	}
+
+	body := strings.ToLower(string(peek))
+	for _, pattern := range slices.Concat(banPatterns, c.config.QuarantinePatterns) {
+		if pattern != "" && strings.Contains(body, strings.ToLower(pattern)) {
+			return pattern
+		}
+	}
+	return ""
+}
+
+// retryAfter 解析Retry-After头（秒数或HTTP日期），无法解析时返回0
+func retryAfter(header http.Header) time.Duration {
+	value := header.Get("Retry-After")
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		return time.Until(at)
+	}
+	return 0
+}
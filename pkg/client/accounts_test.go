@@ -0,0 +1,111 @@
+package client
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ba0gu0/gemini-go-proxy/pkg/auth"
+	"github.com/ba0gu0/gemini-go-proxy/pkg/config"
+	"github.com/ba0gu0/gemini-go-proxy/pkg/models"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/oauth2"
+)
+
+func TestGeminiClient_AccountRotationAndCooldown(t *testing.T) {
+	var tokens []string
+	for _, access := range []string{"a", "b"} {
+		data, err := json.Marshal(&oauth2.Token{AccessToken: access, TokenType: "Bearer", Expiry: time.Now().Add(time.Hour)})
+		require.NoError(t, err)
+		tokens = append(tokens, base64.StdEncoding.EncodeToString(data))
+	}
+	googleAuth := auth.NewGoogleAuth(&models.GoogleAuthConfig{OAuthTokens: tokens}, logrus.New())
+	require.NoError(t, googleAuth.Initialize(context.Background()))
+
+	cfg := config.DefaultConfig()
+	cfg.APIMode = config.AIStudio
+	client := NewGeminiClient(cfg, googleAuth, logrus.New())
+
+	// 账号a被限流，之后的请求都使用账号b
+	var used []string
+	client.client.Transport = roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		authorization := strings.TrimPrefix(req.Header.Get("Authorization"), "Bearer ")
+		used = append(used, authorization)
+		if authorization == "a" {
+			return &http.Response{StatusCode: http.StatusTooManyRequests, Header: http.Header{"Retry-After": []string{"3600"}}, Body: io.NopCloser(strings.NewReader(`{}`))}, nil
+		}
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(`{"candidates":[]}`))}, nil
+	})
+
+	for i := 0; i < 3; i++ {
+		_, err := client.SendRequestRaw(context.Background(), "gemini-2.5-flash", &models.GeminiRequest{})
+		require.NoError(t, err)
+	}
+	assert.Equal(t, []string{"a", "b", "b", "b"}, used)
+}
+
+func TestRetryAfter(t *testing.T) {
+	assert.Equal(t, 30*time.Second, retryAfter(http.Header{"Retry-After": []string{"30"}}))
+	assert.Equal(t, time.Duration(0), retryAfter(http.Header{}))
+	assert.Equal(t, time.Duration(0), retryAfter(http.Header{"Retry-After": []string{"soon"}}))
+
+	at := time.Now().Add(time.Minute).UTC().Format(http.TimeFormat)
+	assert.InDelta(t, float64(time.Minute), float64(retryAfter(http.Header{"Retry-After": []string{at}})), float64(2*time.Second))
+}
+
+func TestGeminiClient_QuarantinesBannedAccount(t *testing.T) {
+	var tokens []string
+	for _, access := range []string{"a", "b"} {
+		data, err := json.Marshal(&oauth2.Token{AccessToken: access, TokenType: "Bearer", Expiry: time.Now().Add(time.Hour)})
+		require.NoError(t, err)
+		tokens = append(tokens, base64.StdEncoding.EncodeToString(data))
+	}
+	googleAuth := auth.NewGoogleAuth(&models.GoogleAuthConfig{OAuthTokens: tokens}, logrus.New())
+	require.NoError(t, googleAuth.Initialize(context.Background()))
+
+	quarantined := make(map[int]string)
+	googleAuth.SetOnAccountQuarantined(func(index int, reason string) {
+		quarantined[index] = reason
+	})
+
+	cfg := config.DefaultConfig()
+	cfg.APIMode = config.AIStudio
+	client := NewGeminiClient(cfg, googleAuth, logrus.New())
+
+	// 账号a被封禁后隔离，之后的请求都使用账号b；普通403不隔离
+	var used []string
+	client.client.Transport = roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		authorization := strings.TrimPrefix(req.Header.Get("Authorization"), "Bearer ")
+		used = append(used, authorization)
+		if authorization == "a" {
+			return &http.Response{StatusCode: http.StatusForbidden, Body: io.NopCloser(strings.NewReader(`{"error":{"code":403,"status":"PERMISSION_DENIED","details":[{"reason":"CONSUMER_SUSPENDED"}]}}`))}, nil
+		}
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(`{"candidates":[]}`))}, nil
+	})
+
+	for i := 0; i < 3; i++ {
+		_, err := client.SendRequestRaw(context.Background(), "gemini-2.5-flash", &models.GeminiRequest{})
+		require.NoError(t, err)
+	}
+	assert.Equal(t, []string{"a", "b", "b", "b"}, used)
+	assert.Equal(t, map[int]string{0: "CONSUMER_SUSPENDED"}, quarantined)
+	assert.Equal(t, 1, googleAuth.AccountCount())
+
+	// 非封禁的403保留响应体，账号不受影响
+	resp := &http.Response{StatusCode: http.StatusForbidden, Body: io.NopCloser(strings.NewReader(`{"error":"PERMISSION_DENIED"}`))}
+	assert.Empty(t, client.banReason(resp))
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, `{"error":"PERMISSION_DENIED"}`, string(body))
+
+	// 最后一个账号也被隔离时进入重新授权状态
+	googleAuth.QuarantineAccount(1, "abusive")
+	assert.True(t, googleAuth.ReauthRequired())
+}
@@ -0,0 +1,261 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"mime"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/ba0gu0/gemini-go-proxy/pkg/models"
+)
+
+var (
+	// ErrInvalidAudioRequest 语音合成或转写请求参数无效
+	ErrInvalidAudioRequest = errors.New("invalid audio request")
+	// ErrNoAudio 上游没有返回音频
+	ErrNoAudio = errors.New("model returned no audio")
+)
+
+const (
+	// defaultSpeechModel OpenAI语音合成模型名对应的默认Gemini TTS模型
+	defaultSpeechModel = "gemini-2.5-flash-preview-tts"
+	// defaultTranscriptionModel OpenAI语音转写模型名对应的默认Gemini模型
+	defaultTranscriptionModel = "gemini-2.5-flash"
+	// defaultSpeechVoice 未指定音色时使用的Gemini预置音色
+	defaultSpeechVoice = "Kore"
+	// defaultSpeechSampleRate 上游PCM音频未声明采样率时使用的采样率
+	defaultSpeechSampleRate = 24000
+	// maxInlineAudioSize 以内联数据发送的音频上限，更大的文件需要先通过Files API上传
+	maxInlineAudioSize = 20 << 20
+)
+
+// speechModels OpenAI语音合成模型名到Gemini TTS模型的映射，其他模型名原样使用
+var speechModels = map[string]string{
+	"tts-1":           defaultSpeechModel,
+	"tts-1-hd":        "gemini-2.5-pro-preview-tts",
+	"gpt-4o-mini-tts": defaultSpeechModel,
+}
+
+// transcriptionModels 映射为 defaultTranscriptionModel 的OpenAI语音转写模型名
+var transcriptionModels = map[string]bool{
+	"whisper-1":              true,
+	"gpt-4o-transcribe":      true,
+	"gpt-4o-mini-transcribe": true,
+}
+
+// speechVoices OpenAI音色到音色相近的Gemini预置音色的映射，其他音色名（如 Puck）原样使用
+var speechVoices = map[string]string{
+	"alloy":   "Kore",
+	"ash":     "Charon",
+	"ballad":  "Sulafat",
+	"coral":   "Aoede",
+	"echo":    "Puck",
+	"fable":   "Fenrir",
+	"onyx":    "Orus",
+	"nova":    "Leda",
+	"sage":    "Achird",
+	"shimmer": "Zephyr",
+	"verse":   "Enceladus",
+}
+
+// audioMimeTypes 按扩展名识别上游支持的音频格式（标准库的MIME表不包含大多数音频类型）
+var audioMimeTypes = map[string]string{
+	".aac":  "audio/aac",
+	".aif":  "audio/aiff",
+	".aiff": "audio/aiff",
+	".flac": "audio/flac",
+	".m4a":  "audio/mp4",
+	".mp3":  "audio/mp3",
+	".mp4":  "audio/mp4",
+	".mpeg": "audio/mpeg",
+	".mpga": "audio/mpeg",
+	".oga":  "audio/ogg",
+	".ogg":  "audio/ogg",
+	".opus": "audio/ogg",
+	".wav":  "audio/wav",
+	".webm": "audio/webm",
+}
+
+// transcriptionPrompt 语音转写的指令
+const transcriptionPrompt = "Generate a verbatim transcript of the speech in this audio. " +
+	"Output only the transcript text, without timestamps, speaker labels or any commentary."
+
+// SynthesizeSpeech 按OpenAI语音合成请求调用Gemini TTS模型，返回音频数据和对应的Content-Type；
+// 上游返回16位单声道PCM，wav 格式会加上WAV文件头
+func (c *GeminiClient) SynthesizeSpeech(ctx context.Context, req *models.OpenAISpeechRequest) ([]byte, string, error) {
+	if strings.TrimSpace(req.Input) == "" {
+		return nil, "", fmt.Errorf("%w: input is required", ErrInvalidAudioRequest)
+	}
+	format := req.ResponseFormat
+	if format == "" {
+		format = "wav"
+	}
+	if format != "wav" && format != "pcm" {
+		return nil, "", fmt.Errorf("%w: unsupported response_format %q, only wav and pcm are supported", ErrInvalidAudioRequest, format)
+	}
+
+	modelID := req.Model
+	if mapped, ok := speechModels[modelID]; ok {
+		modelID = mapped
+	}
+
+	// TTS模型没有单独的风格参数，朗读要求以自然语言写在文本之前
+	text := req.Input
+	if req.Instructions != "" {
+		text = req.Instructions + ":\n" + req.Input
+	}
+	geminiReq := &models.GeminiRequest{
+		Contents: []models.GeminiContent{{Role: "user", Parts: []models.GeminiPart{{Text: text}}}},
+		Extra: map[string]any{
+			"responseModalities": []string{"AUDIO"},
+			"speechConfig": map[string]any{
+				"voiceConfig": map[string]any{
+					"prebuiltVoiceConfig": map[string]any{"voiceName": speechVoice(req.Voice)},
+				},
+			},
+		},
+	}
+
+	resp, err := c.SendRequest(ctx, modelID, geminiReq)
+	if err != nil {
+		return nil, "", err
+	}
+
+	pcm, sampleRate, err := responseAudio(resp)
+	if err != nil {
+		return nil, "", err
+	}
+	if format == "pcm" {
+		return pcm, "audio/pcm", nil
+	}
+	return pcmToWAV(pcm, sampleRate), "audio/wav", nil
+}
+
+// TranscribeAudio 以内联音频和转写指令调用模型，返回转写文本
+func (c *GeminiClient) TranscribeAudio(ctx context.Context, req *models.OpenAITranscriptionRequest) (string, error) {
+	if len(req.File) == 0 {
+		return "", fmt.Errorf("%w: file is required", ErrInvalidAudioRequest)
+	}
+	if len(req.File) > maxInlineAudioSize {
+		return "", fmt.Errorf("%w: file exceeds %d MB", ErrInvalidAudioRequest, maxInlineAudioSize>>20)
+	}
+	mimeType := audioMimeType(req.Filename, req.MimeType)
+	if mimeType == "" {
+		return "", fmt.Errorf("%w: unsupported audio file %q", ErrInvalidAudioRequest, req.Filename)
+	}
+
+	modelID := req.Model
+	if transcriptionModels[modelID] {
+		modelID = defaultTranscriptionModel
+	}
+
+	prompt := transcriptionPrompt
+	if req.Language != "" {
+		prompt += fmt.Sprintf(" The speech is in the language with ISO-639-1 code %q.", req.Language)
+	}
+	if req.Prompt != "" {
+		prompt += "\n\nContext and spelling hints (do not include them in the transcript):\n" + req.Prompt
+	}
+
+	geminiReq := &models.GeminiRequest{
+		Contents: []models.GeminiContent{{Role: "user", Parts: []models.GeminiPart{
+			{InlineData: &models.GeminiInlineData{MimeType: mimeType, Data: base64.StdEncoding.EncodeToString(req.File)}},
+			{Text: prompt},
+		}}},
+	}
+	if req.Temperature != nil {
+		geminiReq.GenerationConfig = &models.GeminiGenerationConfig{Temperature: req.Temperature}
+	}
+
+	resp, err := c.SendRequest(ctx, modelID, geminiReq)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(responseText(resp)), nil
+}
+
+// speechVoice 将OpenAI音色映射为Gemini预置音色
+func speechVoice(voice string) string {
+	if voice == "" {
+		return defaultSpeechVoice
+	}
+	if mapped, ok := speechVoices[strings.ToLower(voice)]; ok {
+		return mapped
+	}
+	return voice
+}
+
+// audioMimeType 按文件扩展名确定音频的MIME类型，无法识别时使用表单声明的音频/视频类型，都不可用时返回空字符串
+func audioMimeType(filename, declared string) string {
+	if mimeType, ok := audioMimeTypes[strings.ToLower(path.Ext(filename))]; ok {
+		return mimeType
+	}
+	declared = strings.SplitN(declared, ";", 2)[0]
+	if strings.HasPrefix(declared, "audio/") || strings.HasPrefix(declared, "video/") {
+		return declared
+	}
+	return ""
+}
+
+// responseAudio 取出响应中的PCM音频及其采样率
+func responseAudio(resp *models.GeminiResponse) ([]byte, int, error) {
+	if resp == nil || len(resp.Candidates) == 0 {
+		return nil, 0, ErrNoAudio
+	}
+
+	var pcm bytes.Buffer
+	sampleRate := defaultSpeechSampleRate
+	for _, part := range resp.Candidates[0].Content.Parts {
+		if part.InlineData == nil || !strings.HasPrefix(part.InlineData.MimeType, "audio/") {
+			continue
+		}
+		data, err := base64.StdEncoding.DecodeString(part.InlineData.Data)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to decode audio: %w", err)
+		}
+		pcm.Write(data)
+
+		// 如 audio/L16;codec=pcm;rate=24000
+		if _, params, err := mime.ParseMediaType(part.InlineData.MimeType); err == nil {
+			if rate, err := strconv.Atoi(params["rate"]); err == nil && rate > 0 {
+				sampleRate = rate
+			}
+		}
+	}
+	if pcm.Len() == 0 {
+		return nil, 0, ErrNoAudio
+	}
+	return pcm.Bytes(), sampleRate, nil
+}
+
+// pcmToWAV 为16位单声道PCM数据加上WAV文件头
+func pcmToWAV(pcm []byte, sampleRate int) []byte {
+	const (
+		channels      = 1
+		bitsPerSample = 16
+	)
+	blockAlign := channels * bitsPerSample / 8
+
+	var buf bytes.Buffer
+	buf.Grow(44 + len(pcm))
+	buf.WriteString("RIFF")
+	binary.Write(&buf, binary.LittleEndian, uint32(36+len(pcm)))
+	buf.WriteString("WAVEfmt ")
+	binary.Write(&buf, binary.LittleEndian, uint32(16)) // fmt块大小
+	binary.Write(&buf, binary.LittleEndian, uint16(1))  // PCM
+	binary.Write(&buf, binary.LittleEndian, uint16(channels))
+	binary.Write(&buf, binary.LittleEndian, uint32(sampleRate))
+	binary.Write(&buf, binary.LittleEndian, uint32(sampleRate*blockAlign))
+	binary.Write(&buf, binary.LittleEndian, uint16(blockAlign))
+	binary.Write(&buf, binary.LittleEndian, uint16(bitsPerSample))
+	buf.WriteString("data")
+	binary.Write(&buf, binary.LittleEndian, uint32(len(pcm)))
+	buf.Write(pcm)
+	return buf.Bytes()
+}
@@ -0,0 +1,54 @@
+package client
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"testing"
+
+	"github.com/ba0gu0/gemini-go-proxy/pkg/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPCMToWAV(t *testing.T) {
+	pcm := []byte{1, 2, 3, 4}
+	wav := pcmToWAV(pcm, 24000)
+
+	require.Len(t, wav, 44+len(pcm))
+	assert.Equal(t, "RIFF", string(wav[0:4]))
+	assert.Equal(t, uint32(36+len(pcm)), binary.LittleEndian.Uint32(wav[4:8]))
+	assert.Equal(t, "WAVEfmt ", string(wav[8:16]))
+	assert.Equal(t, uint32(24000), binary.LittleEndian.Uint32(wav[24:28]))
+	assert.Equal(t, uint32(48000), binary.LittleEndian.Uint32(wav[28:32]))
+	assert.Equal(t, "data", string(wav[36:40]))
+	assert.Equal(t, uint32(len(pcm)), binary.LittleEndian.Uint32(wav[40:44]))
+	assert.Equal(t, pcm, wav[44:])
+}
+
+func TestAudioMimeType(t *testing.T) {
+	assert.Equal(t, "audio/mp3", audioMimeType("speech.MP3", "application/octet-stream"))
+	assert.Equal(t, "audio/ogg", audioMimeType("voice.opus", ""))
+	assert.Equal(t, "audio/x-custom", audioMimeType("recording", "audio/x-custom; codecs=1"))
+	assert.Empty(t, audioMimeType("notes.txt", "text/plain"))
+}
+
+func TestSpeechVoice(t *testing.T) {
+	assert.Equal(t, defaultSpeechVoice, speechVoice(""))
+	assert.Equal(t, "Puck", speechVoice("Echo"))
+	assert.Equal(t, "Charon", speechVoice("Charon"))
+}
+
+func TestResponseAudio(t *testing.T) {
+	resp := &models.GeminiResponse{Candidates: []models.GeminiCandidate{{Content: models.GeminiContent{Parts: []models.GeminiPart{
+		{InlineData: &models.GeminiInlineData{MimeType: "audio/L16;codec=pcm;rate=16000", Data: base64.StdEncoding.EncodeToString([]byte{1, 2})}},
+		{InlineData: &models.GeminiInlineData{MimeType: "audio/L16;codec=pcm;rate=16000", Data: base64.StdEncoding.EncodeToString([]byte{3, 4})}},
+	}}}}}
+
+	pcm, sampleRate, err := responseAudio(resp)
+	require.NoError(t, err)
+	assert.Equal(t, []byte{1, 2, 3, 4}, pcm)
+	assert.Equal(t, 16000, sampleRate)
+
+	_, _, err = responseAudio(&models.GeminiResponse{Candidates: []models.GeminiCandidate{{Content: models.GeminiContent{Parts: []models.GeminiPart{{Text: "hi"}}}}}})
+	assert.ErrorIs(t, err, ErrNoAudio)
+}
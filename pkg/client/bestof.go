@@ -0,0 +1,179 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/ba0gu0/gemini-go-proxy/pkg/models"
+)
+
+const (
+	// best-of评分方式
+	BestOfScorerHeuristic = "heuristic"
+	BestOfScorerJudge     = "judge"
+
+	// DefaultBestOfMaxN 默认允许的最大候选数量
+	DefaultBestOfMaxN = 4
+
+	// DefaultBestOfJudgePrompt 默认评审提示词
+	DefaultBestOfJudgePrompt = "You are an impartial judge. Several candidate answers to the same conversation are listed below. " +
+		"Pick the candidate that is the most correct, complete and helpful. " +
+		"Reply with only the number of the best candidate and nothing else."
+)
+
+var judgeIndexPattern = regexp.MustCompile(`\d+`)
+
+// sendOpenAIBestOfRequest 发起N次独立请求，对候选结果评分后返回最佳结果
+func (c *GeminiClient) sendOpenAIBestOfRequest(ctx context.Context, req *models.OpenAIRequest) (*models.OpenAIResponse, error) {
+	n := *req.BestOf
+	maxN := c.config.BestOfMaxN
+	if maxN <= 0 {
+		maxN = DefaultBestOfMaxN
+	}
+	if n > maxN {
+		c.logger.Warnf("best_of %d exceeds limit of %d, adjusting.", n, maxN)
+		n = maxN
+	}
+
+	responses := make([]*models.OpenAIResponse, n)
+	candidates := make([]models.OpenAIBestOfCandidate, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			candidateReq := cloneOpenAIRequest(req)
+			candidateReq.BestOf = nil
+			candidateReq.Stream = false
+
+			candidates[i].Index = i
+			resp, err := c.SendOpenAIRequest(ctx, candidateReq)
+			if err != nil {
+				candidates[i].Error = err.Error()
+				candidates[i].Score = -1
+				return
+			}
+			responses[i] = resp
+			if len(resp.Choices) > 0 && resp.Choices[0].Message != nil {
+				candidates[i].Content = resp.Choices[0].Message.Content
+				candidates[i].FinishReason = resp.Choices[0].FinishReason
+			}
+			candidates[i].Score = scoreBestOfCandidate(&candidates[i])
+		}(i)
+	}
+	wg.Wait()
+
+	best := -1
+	for i := range candidates {
+		if responses[i] != nil && (best < 0 || candidates[i].Score > candidates[best].Score) {
+			best = i
+		}
+	}
+	if best < 0 {
+		return nil, fmt.Errorf("all %d best-of candidates failed: %s", n, candidates[0].Error)
+	}
+
+	// 评审模式：由模型从成功的候选中选出最佳结果，失败时保留启发式结果
+	scorer := req.BestOfScorer
+	if scorer == "" {
+		scorer = c.config.BestOfScorer
+	}
+	if strings.ToLower(scorer) == BestOfScorerJudge {
+		if judged, err := c.judgeBestOfCandidates(ctx, req, candidates, responses); err != nil {
+			c.logger.Warnf("Best-of judge failed, falling back to heuristic: %v", err)
+		} else {
+			best = judged
+		}
+	}
+
+	candidates[best].Selected = true
+	c.logger.Infof("Best-of request completed: %s, selected candidate %d of %d", req.Model, best, n)
+
+	// 汇总所有候选的token使用量
+	winner := responses[best]
+	usage := &models.OpenAIUsage{}
+	for _, resp := range responses {
+		if resp != nil && resp.Usage != nil {
+			usage.PromptTokens += resp.Usage.PromptTokens
+			usage.CompletionTokens += resp.Usage.CompletionTokens
+			usage.TotalTokens += resp.Usage.TotalTokens
+		}
+	}
+	winner.Usage = usage
+	winner.BestOfCandidates = candidates
+
+	return winner, nil
+}
+
+// scoreBestOfCandidate 启发式评分：正常结束的候选优先，其次内容越长得分越高
+func scoreBestOfCandidate(candidate *models.OpenAIBestOfCandidate) float64 {
+	if candidate.Error != "" || strings.TrimSpace(candidate.Content) == "" {
+		return 0
+	}
+
+	score := float64(utf8.RuneCountInString(candidate.Content))
+	if candidate.FinishReason == nil || *candidate.FinishReason != "stop" {
+		score *= 0.5
+	}
+	return score
+}
+
+// judgeBestOfCandidates 使用评审提示词让模型选出最佳候选，返回候选下标
+func (c *GeminiClient) judgeBestOfCandidates(ctx context.Context, req *models.OpenAIRequest, candidates []models.OpenAIBestOfCandidate, responses []*models.OpenAIResponse) (int, error) {
+	judgePrompt := c.config.BestOfJudgePrompt
+	if judgePrompt == "" {
+		judgePrompt = DefaultBestOfJudgePrompt
+	}
+
+	var builder strings.Builder
+	builder.WriteString("Conversation:\n")
+	for _, msg := range req.Messages {
+		if strings.ToLower(msg.Role) == "system" {
+			continue
+		}
+		fmt.Fprintf(&builder, "[%s]: %s\n", msg.Role, msg.Content)
+	}
+	for i, candidate := range candidates {
+		if responses[i] == nil {
+			continue
+		}
+		fmt.Fprintf(&builder, "\nCandidate %d:\n%s\n", i, candidate.Content)
+	}
+
+	judgeModel := c.config.BestOfJudgeModel
+	if judgeModel == "" {
+		judgeModel = req.Model
+	}
+
+	judgeReq := &models.OpenAIRequest{
+		Model: judgeModel,
+		Messages: []models.OpenAIMessage{
+			{Role: "system", Content: judgePrompt},
+			{Role: "user", Content: builder.String()},
+		},
+	}
+
+	resp, err := c.SendOpenAIRequest(ctx, judgeReq)
+	if err != nil {
+		return 0, err
+	}
+	if len(resp.Choices) == 0 || resp.Choices[0].Message == nil {
+		return 0, fmt.Errorf("judge returned no choices")
+	}
+
+	match := judgeIndexPattern.FindString(resp.Choices[0].Message.Content)
+	if match == "" {
+		return 0, fmt.Errorf("judge reply does not contain a candidate number: %q", resp.Choices[0].Message.Content)
+	}
+	index, _ := strconv.Atoi(match)
+	if index < 0 || index >= len(candidates) || responses[index] == nil {
+		return 0, fmt.Errorf("judge selected invalid candidate %d", index)
+	}
+	return index, nil
+}
@@ -0,0 +1,34 @@
+package client
+
+import (
+	"testing"
+
+	"github.com/ba0gu0/gemini-go-proxy/pkg/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScoreBestOfCandidate(t *testing.T) {
+	stop := "stop"
+	length := "length"
+
+	complete := &models.OpenAIBestOfCandidate{Content: "a complete answer", FinishReason: &stop}
+	truncated := &models.OpenAIBestOfCandidate{Content: "a complete answer", FinishReason: &length}
+	failed := &models.OpenAIBestOfCandidate{Error: "request failed"}
+	empty := &models.OpenAIBestOfCandidate{Content: "   ", FinishReason: &stop}
+
+	assert.Greater(t, scoreBestOfCandidate(complete), scoreBestOfCandidate(truncated))
+	assert.Equal(t, float64(0), scoreBestOfCandidate(failed))
+	assert.Equal(t, float64(0), scoreBestOfCandidate(empty))
+}
+
+func TestCloneOpenAIRequest(t *testing.T) {
+	temp := float32(0.5)
+	req := &models.OpenAIRequest{Model: "gemini-pro", Temperature: &temp}
+
+	clone := cloneOpenAIRequest(req)
+	*clone.Temperature = 1.5
+	clone.Model = "gemini-2.5-pro"
+
+	assert.Equal(t, float32(0.5), *req.Temperature)
+	assert.Equal(t, "gemini-pro", req.Model)
+}
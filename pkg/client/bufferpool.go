@@ -0,0 +1,95 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"sync"
+)
+
+// maxPooledBufferSize 超过该大小的缓冲区不放回池中，避免长期占用内存
+const maxPooledBufferSize = 1 << 20
+
+// bufferPool 请求序列化缓冲区池
+var bufferPool = sync.Pool{
+	New: func() any {
+		return new(bytes.Buffer)
+	},
+}
+
+// getBuffer 从池中获取空缓冲区
+func getBuffer() *bytes.Buffer {
+	buf := bufferPool.Get().(*bytes.Buffer)
+	buf.Reset()
+	return buf
+}
+
+// putBuffer 归还缓冲区
+func putBuffer(buf *bytes.Buffer) {
+	if buf == nil || buf.Cap() > maxPooledBufferSize {
+		return
+	}
+	bufferPool.Put(buf)
+}
+
+// byteBudget 限制同时缓冲的请求体总字节数
+type byteBudget struct {
+	mu      sync.Mutex
+	max     int64
+	used    int64
+	waiters chan struct{} // 有字节释放时关闭
+}
+
+// newByteBudget 创建字节预算，max<=0 时返回nil表示不限制
+func newByteBudget(max int64) *byteBudget {
+	if max <= 0 {
+		return nil
+	}
+	return &byteBudget{max: max, waiters: make(chan struct{})}
+}
+
+// acquire 申请n字节，预算不足时等待释放；单个超过上限的请求在无其他占用时放行
+func (b *byteBudget) acquire(ctx context.Context, n int64) error {
+	if b == nil {
+		return nil
+	}
+
+	for {
+		b.mu.Lock()
+		if b.used == 0 || b.used+n <= b.max {
+			b.used += n
+			b.mu.Unlock()
+			return nil
+		}
+		wait := b.waiters
+		b.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-wait:
+		}
+	}
+}
+
+// release 释放n字节并唤醒等待者
+func (b *byteBudget) release(n int64) {
+	if b == nil {
+		return
+	}
+
+	b.mu.Lock()
+	b.used -= n
+	close(b.waiters)
+	b.waiters = make(chan struct{})
+	b.mu.Unlock()
+}
+
+// inUse 当前占用的字节数
+func (b *byteBudget) inUse() int64 {
+	if b == nil {
+		return 0
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.used
+}
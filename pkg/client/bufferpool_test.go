@@ -0,0 +1,65 @@
+package client
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ba0gu0/gemini-go-proxy/pkg/config"
+	"github.com/ba0gu0/gemini-go-proxy/pkg/models"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestByteBudget(t *testing.T) {
+	assert.Nil(t, newByteBudget(0))
+
+	budget := newByteBudget(100)
+	require.NoError(t, budget.acquire(context.Background(), 60))
+	require.NoError(t, budget.acquire(context.Background(), 40))
+	assert.Equal(t, int64(100), budget.inUse())
+
+	// 预算不足时等待，超时返回错误
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, budget.acquire(ctx, 10), context.DeadlineExceeded)
+
+	// 释放后等待者继续
+	done := make(chan error, 1)
+	go func() { done <- budget.acquire(context.Background(), 50) }()
+	budget.release(60)
+	require.NoError(t, <-done)
+	assert.Equal(t, int64(90), budget.inUse())
+
+	// 超过上限的单个请求在空闲时放行
+	budget.release(90)
+	require.NoError(t, budget.acquire(context.Background(), 500))
+	budget.release(500)
+	assert.Zero(t, budget.inUse())
+}
+
+func TestGeminiClient_PayloadReleasesBudget(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.MaxBufferedBytes = 1 << 20
+	client := NewGeminiClient(cfg, nil, logrus.New())
+	client.client.Transport = roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		assert.NotZero(t, client.bufferBudget.inUse())
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(`{"response":{}}`))}, nil
+	})
+
+	req := &models.GeminiRequest{Contents: []models.GeminiContent{{Role: "user", Parts: []models.GeminiPart{{Text: "hello"}}}}}
+	_, err := client.SendRequest(context.Background(), "gemini-2.5-flash", req)
+	require.NoError(t, err)
+	assert.Zero(t, client.bufferBudget.inUse())
+
+	// 流式响应在关闭响应体时释放
+	resp, err := client.SendStreamRequestRaw(context.Background(), "gemini-2.5-flash", req)
+	require.NoError(t, err)
+	assert.NotZero(t, client.bufferBudget.inUse())
+	resp.Body.Close()
+	assert.Zero(t, client.bufferBudget.inUse())
+}
@@ -0,0 +1,153 @@
+package client
+
+import (
+	"container/list"
+	"context"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ba0gu0/gemini-go-proxy/pkg/config"
+	"github.com/ba0gu0/gemini-go-proxy/pkg/models"
+)
+
+// 响应缓存默认最多保存的条目数
+const defaultResponseCacheEntries = 1000
+
+// CacheControl 单个请求的缓存指令，取自请求的 Cache-Control 头部
+type CacheControl struct {
+	NoCache bool // 不使用缓存的响应，但结果仍写入缓存
+	NoStore bool // 既不读取也不写入缓存
+}
+
+// ParseCacheControl 解析 Cache-Control 头部中的 no-cache 和 no-store 指令
+func ParseCacheControl(header string) CacheControl {
+	var cc CacheControl
+	for _, directive := range strings.Split(header, ",") {
+		switch strings.ToLower(strings.TrimSpace(directive)) {
+		case "no-cache", "max-age=0":
+			cc.NoCache = true
+		case "no-store":
+			cc.NoStore = true
+		}
+	}
+	return cc
+}
+
+type cacheControlKey struct{}
+
+// WithCacheControl 返回携带请求缓存指令的上下文
+func WithCacheControl(ctx context.Context, cc CacheControl) context.Context {
+	return context.WithValue(ctx, cacheControlKey{}, cc)
+}
+
+// cacheControl 获取上下文中的缓存指令
+func cacheControl(ctx context.Context) CacheControl {
+	cc, _ := ctx.Value(cacheControlKey{}).(CacheControl)
+	return cc
+}
+
+// responseCache 按请求内容缓存非流式响应，超过TTL或条目上限时淘汰
+type responseCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	max     int
+	entries map[string]*list.Element
+	order   *list.List // 最近使用的条目在前
+	hits    atomic.Int64
+}
+
+// cachedResponse 缓存条目
+type cachedResponse struct {
+	key     string
+	resp    *models.GeminiResponse
+	expires time.Time
+}
+
+// newResponseCache 根据配置创建响应缓存，未配置或TTL无效时返回nil
+func newResponseCache(cfg *config.ResponseCache) *responseCache {
+	if cfg == nil || cfg.TTLSeconds <= 0 {
+		return nil
+	}
+	max := cfg.MaxEntries
+	if max <= 0 {
+		max = defaultResponseCacheEntries
+	}
+	return &responseCache{
+		ttl:     time.Duration(cfg.TTLSeconds) * time.Second,
+		max:     max,
+		entries: make(map[string]*list.Element),
+		order:   list.New(),
+	}
+}
+
+// get 获取未过期的缓存响应副本
+func (c *responseCache) get(key string) (*models.GeminiResponse, bool) {
+	c.mu.Lock()
+	elem, ok := c.entries[key]
+	if !ok {
+		c.mu.Unlock()
+		return nil, false
+	}
+	entry := elem.Value.(*cachedResponse)
+	if time.Now().After(entry.expires) {
+		c.order.Remove(elem)
+		delete(c.entries, key)
+		c.mu.Unlock()
+		return nil, false
+	}
+	c.order.MoveToFront(elem)
+	c.mu.Unlock()
+
+	resp, err := cloneGeminiResponse(entry.resp)
+	if err != nil {
+		return nil, false
+	}
+	c.hits.Add(1)
+	return resp, true
+}
+
+// put 保存响应副本，超过条目上限时淘汰最久未使用的条目
+func (c *responseCache) put(key string, resp *models.GeminiResponse) {
+	clone, err := cloneGeminiResponse(resp)
+	if err != nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry := &cachedResponse{key: key, resp: clone, expires: time.Now().Add(c.ttl)}
+	if elem, ok := c.entries[key]; ok {
+		elem.Value = entry
+		c.order.MoveToFront(elem)
+		return
+	}
+	c.entries[key] = c.order.PushFront(entry)
+	for c.order.Len() > c.max {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cachedResponse).key)
+	}
+}
+
+// cacheable 只缓存正常结束的响应，被安全策略拦截或截断的结果可能随重试改变
+func cacheable(resp *models.GeminiResponse) bool {
+	if resp == nil || len(resp.Candidates) == 0 {
+		return false
+	}
+	for _, candidate := range resp.Candidates {
+		if candidate.FinishReason != "" && candidate.FinishReason != "STOP" {
+			return false
+		}
+	}
+	return true
+}
+
+// CachedResponses 返回由响应缓存直接返回（未请求上游）的请求数
+func (c *GeminiClient) CachedResponses() int64 {
+	if c.cache == nil {
+		return 0
+	}
+	return c.cache.hits.Load()
+}
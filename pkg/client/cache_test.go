@@ -0,0 +1,103 @@
+package client
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/ba0gu0/gemini-go-proxy/pkg/config"
+	"github.com/ba0gu0/gemini-go-proxy/pkg/models"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGeminiClient_ResponseCache(t *testing.T) {
+	var calls atomic.Int32
+	finishReason := "STOP"
+	cfg := config.DefaultConfig()
+	cfg.ResponseCache = &config.ResponseCache{TTLSeconds: 60, MaxEntries: 2}
+	client := NewGeminiClient(cfg, nil, logrus.New())
+	client.client.Transport = roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		calls.Add(1)
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       io.NopCloser(strings.NewReader(`{"response":{"candidates":[{"content":{"parts":[{"text":"cached"}]},"finishReason":"` + finishReason + `"}]}}`)),
+		}, nil
+	})
+
+	send := func(ctx context.Context, prompt string) *models.GeminiResponse {
+		resp, err := client.SendRequest(ctx, "gemini-2.5-flash", &models.GeminiRequest{
+			Contents: []models.GeminiContent{{Role: "user", Parts: []models.GeminiPart{{Text: prompt}}}},
+		})
+		require.NoError(t, err)
+		return resp
+	}
+
+	first := send(context.Background(), "one")
+	second := send(context.Background(), "one")
+	assert.Equal(t, int32(1), calls.Load())
+	assert.Equal(t, int64(1), client.CachedResponses())
+	assert.Equal(t, "cached", second.Candidates[0].Content.Parts[0].Text)
+	assert.NotSame(t, first, second)
+
+	// no-cache 跳过缓存但更新缓存，no-store 不读也不写
+	send(WithCacheControl(context.Background(), CacheControl{NoCache: true}), "one")
+	assert.Equal(t, int32(2), calls.Load())
+	send(WithCacheControl(context.Background(), CacheControl{NoStore: true}), "two")
+	send(context.Background(), "two")
+	assert.Equal(t, int32(4), calls.Load())
+
+	// 超过条目上限时淘汰最久未使用的条目
+	send(context.Background(), "three")
+	send(context.Background(), "one")
+	assert.Equal(t, int32(6), calls.Load())
+
+	// 非正常结束的响应不缓存
+	finishReason = "SAFETY"
+	send(context.Background(), "four")
+	send(context.Background(), "four")
+	assert.Equal(t, int32(8), calls.Load())
+}
+
+func TestParseCacheControl(t *testing.T) {
+	assert.Equal(t, CacheControl{NoCache: true}, ParseCacheControl("no-cache"))
+	assert.Equal(t, CacheControl{NoCache: true, NoStore: true}, ParseCacheControl("No-Store, max-age=0"))
+	assert.Equal(t, CacheControl{}, ParseCacheControl("max-age=60"))
+}
+
+func TestGeminiClient_ResponseCacheKeyedByLanguage(t *testing.T) {
+	var calls atomic.Int32
+	cfg := config.DefaultConfig()
+	cfg.ResponseCache = &config.ResponseCache{TTLSeconds: 60, MaxEntries: 10}
+	client := NewGeminiClient(cfg, nil, logrus.New())
+	client.client.Transport = roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		calls.Add(1)
+		body, _ := io.ReadAll(req.Body)
+		text := "english"
+		if strings.Contains(string(body), "zh-CN") {
+			text = "chinese"
+		}
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       io.NopCloser(strings.NewReader(`{"response":{"candidates":[{"content":{"parts":[{"text":"` + text + `"}]},"finishReason":"STOP"}]}}`)),
+		}, nil
+	})
+
+	send := func(ctx context.Context) string {
+		resp, err := client.SendRequest(ctx, "gemini-2.5-flash", &models.GeminiRequest{
+			Contents: []models.GeminiContent{{Role: "user", Parts: []models.GeminiPart{{Text: "hello"}}}},
+		})
+		require.NoError(t, err)
+		return resp.Candidates[0].Content.Parts[0].Text
+	}
+
+	// 不同API密钥强制的回复语言不共用缓存
+	assert.Equal(t, "chinese", send(WithResponseLanguage(context.Background(), "zh-CN")))
+	assert.Equal(t, "english", send(WithResponseLanguage(context.Background(), "en-US")))
+	assert.Equal(t, "chinese", send(WithResponseLanguage(context.Background(), "zh-CN")))
+	assert.Equal(t, int32(2), calls.Load())
+}
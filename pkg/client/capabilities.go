@@ -0,0 +1,140 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/ba0gu0/gemini-go-proxy/pkg/config"
+	"github.com/ba0gu0/gemini-go-proxy/pkg/models"
+)
+
+// ErrUnsupportedCapability 请求使用了模型不支持的功能或超出模型的上下文长度
+var ErrUnsupportedCapability = errors.New("unsupported by model")
+
+// builtinCapabilities 内置模型能力表，按模型名前缀匹配，最长前缀优先
+var builtinCapabilities = map[string]config.ModelCapabilities{
+	"gemini-2.5":            {Vision: true, Tools: true, JSONMode: true, Thinking: true, MaxInputTokens: 1048576, MaxOutputTokens: 65536},
+	"gemini-2.0":            {Vision: true, Tools: true, JSONMode: true, MaxInputTokens: 1048576, MaxOutputTokens: 8192},
+	"gemini-1.5-pro":        {Vision: true, Tools: true, JSONMode: true, MaxInputTokens: 2097152, MaxOutputTokens: 8192},
+	"gemini-1.5":            {Vision: true, Tools: true, JSONMode: true, MaxInputTokens: 1048576, MaxOutputTokens: 8192},
+	"gemini-1.0-pro":        {Tools: true, MaxInputTokens: 30720, MaxOutputTokens: 2048},
+	"gemini-pro":            {Tools: true, MaxInputTokens: 30720, MaxOutputTokens: 2048},
+	"gemini-pro-vision":     {Vision: true, MaxInputTokens: 12288, MaxOutputTokens: 4096},
+	"gemini-1.0-pro-vision": {Vision: true, MaxInputTokens: 12288, MaxOutputTokens: 4096},
+}
+
+// SetModelCapabilities 设置覆盖或补充内置能力表的模型能力（模型名前缀 -> 能力）
+func (c *FormatConverter) SetModelCapabilities(overrides map[string]config.ModelCapabilities) {
+	c.capabilities = overrides
+}
+
+// LookupCapabilities 查找模型的能力，未知模型（如调优模型、实验模型）返回false
+func (c *FormatConverter) LookupCapabilities(modelID string) (config.ModelCapabilities, bool) {
+	name := baseModelName(modelID)
+	var (
+		best    config.ModelCapabilities
+		bestLen = -1
+	)
+	// 配置的能力与内置能力前缀相同时优先
+	for _, table := range []map[string]config.ModelCapabilities{builtinCapabilities, c.capabilities} {
+		for prefix, capabilities := range table {
+			if strings.HasPrefix(name, prefix) && len(prefix) >= bestLen {
+				best, bestLen = capabilities, len(prefix)
+			}
+		}
+	}
+	return best, bestLen >= 0
+}
+
+// ModelCapabilities 查找模型的能力（内置能力表和配置的 model_capabilities），未知模型返回false
+func (c *GeminiClient) ModelCapabilities(modelID string) (config.ModelCapabilities, bool) {
+	return c.converter.LookupCapabilities(modelID)
+}
+
+// CheckCapabilities 检查请求是否使用了模型不支持的功能，未知模型不做检查
+func (c *FormatConverter) CheckCapabilities(req *models.GeminiRequest, modelID string) error {
+	capabilities, ok := c.LookupCapabilities(modelID)
+	if !ok {
+		return nil
+	}
+	name := baseModelName(modelID)
+
+	if !capabilities.Vision && hasMediaParts(req) {
+		return fmt.Errorf("%w: %s does not accept image, audio, video or file inputs", ErrUnsupportedCapability, name)
+	}
+	if !capabilities.Tools && (len(req.Tools) > 0 || req.ToolConfig != nil) {
+		return fmt.Errorf("%w: %s does not support tools or function calling", ErrUnsupportedCapability, name)
+	}
+	if !capabilities.JSONMode && req.GenerationConfig != nil &&
+		(req.GenerationConfig.ResponseMimeType == "application/json" || req.GenerationConfig.ResponseSchema != nil) {
+		return fmt.Errorf("%w: %s does not support JSON mode or response schemas", ErrUnsupportedCapability, name)
+	}
+	if !capabilities.Thinking && hasThinkingConfig(req.Extra) {
+		return fmt.Errorf("%w: %s does not support thinkingConfig", ErrUnsupportedCapability, name)
+	}
+	if capabilities.MaxInputTokens > 0 {
+		if tokens := estimatePromptTokens(req); tokens > capabilities.MaxInputTokens {
+			return fmt.Errorf("%w: prompt of about %d tokens exceeds the %d token context window of %s",
+				ErrUnsupportedCapability, tokens, capabilities.MaxInputTokens, name)
+		}
+	}
+	return nil
+}
+
+// OutputTokenBudget 计算请求可用的输出token数：模型的最大输出token数，
+// 且不超过上下文窗口（max_input_tokens）减去估算的提示词token数，至少为1；未知模型或未配置输出上限时返回false
+func (c *FormatConverter) OutputTokenBudget(req *models.GeminiRequest, modelID string) (int, bool) {
+	capabilities, ok := c.LookupCapabilities(modelID)
+	if !ok || capabilities.MaxOutputTokens <= 0 {
+		return 0, false
+	}
+	budget := capabilities.MaxOutputTokens
+	if capabilities.MaxInputTokens > 0 {
+		budget = min(budget, capabilities.MaxInputTokens-estimatePromptTokens(req))
+	}
+	return max(budget, 1), true
+}
+
+// hasMediaParts 请求中是否包含内联数据或文件引用
+func hasMediaParts(req *models.GeminiRequest) bool {
+	for _, content := range req.Contents {
+		for _, part := range content.Parts {
+			if part.InlineData != nil || part.FileData != nil {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// hasThinkingConfig 扩展字段中是否设置了thinkingConfig（顶层或generationConfig内）
+func hasThinkingConfig(extra map[string]any) bool {
+	for _, key := range []string{"thinkingConfig", "thinking_config"} {
+		if _, ok := extra[key]; ok {
+			return true
+		}
+	}
+	for _, key := range []string{"generationConfig", "generation_config"} {
+		if nested, ok := extra[key].(map[string]any); ok && hasThinkingConfig(nested) {
+			return true
+		}
+	}
+	return false
+}
+
+// estimatePromptTokens 使用本地近似分词估算系统指令和对话内容的token数
+func estimatePromptTokens(req *models.GeminiRequest) int {
+	tokens := 0
+	if req.SystemInstruction != nil {
+		for _, part := range req.SystemInstruction.Parts {
+			tokens += EstimateTokenCount(part.Text)
+		}
+	}
+	for _, content := range req.Contents {
+		for _, part := range content.Parts {
+			tokens += EstimateTokenCount(part.Text)
+		}
+	}
+	return tokens
+}
@@ -0,0 +1,105 @@
+package client
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ba0gu0/gemini-go-proxy/pkg/config"
+	"github.com/ba0gu0/gemini-go-proxy/pkg/models"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormatConverter_LookupCapabilities(t *testing.T) {
+	converter := NewFormatConverter(logrus.New())
+
+	capabilities, ok := converter.LookupCapabilities("models/gemini-2.5-flash")
+	require.True(t, ok)
+	assert.True(t, capabilities.Thinking)
+
+	// 最长前缀优先
+	capabilities, ok = converter.LookupCapabilities("gemini-pro-vision")
+	require.True(t, ok)
+	assert.True(t, capabilities.Vision)
+	assert.False(t, capabilities.Tools)
+
+	_, ok = converter.LookupCapabilities("tunedModels/my-model")
+	assert.False(t, ok)
+
+	// 配置覆盖内置能力
+	converter.SetModelCapabilities(map[string]config.ModelCapabilities{"gemini-2.5-flash-lite": {Tools: true}})
+	capabilities, ok = converter.LookupCapabilities("gemini-2.5-flash-lite-preview")
+	require.True(t, ok)
+	assert.False(t, capabilities.Thinking)
+}
+
+func TestFormatConverter_CheckCapabilities(t *testing.T) {
+	converter := NewFormatConverter(logrus.New())
+	text := func(s string) []models.GeminiContent {
+		return []models.GeminiContent{{Role: "user", Parts: []models.GeminiPart{{Text: s}}}}
+	}
+
+	image := &models.GeminiRequest{Contents: []models.GeminiContent{{Role: "user", Parts: []models.GeminiPart{
+		{InlineData: &models.GeminiInlineData{MimeType: "image/png", Data: "AA=="}},
+	}}}}
+	assert.NoError(t, converter.CheckCapabilities(image, "gemini-2.5-flash"))
+	assert.ErrorIs(t, converter.CheckCapabilities(image, "gemini-pro"), ErrUnsupportedCapability)
+
+	tools := &models.GeminiRequest{Contents: text("hi"), Tools: []models.GeminiTool{{}}}
+	assert.ErrorIs(t, converter.CheckCapabilities(tools, "gemini-pro-vision"), ErrUnsupportedCapability)
+
+	jsonMode := &models.GeminiRequest{Contents: text("hi"), GenerationConfig: &models.GeminiGenerationConfig{ResponseMimeType: "application/json"}}
+	assert.ErrorIs(t, converter.CheckCapabilities(jsonMode, "gemini-1.0-pro"), ErrUnsupportedCapability)
+	assert.NoError(t, converter.CheckCapabilities(jsonMode, "gemini-1.5-flash"))
+
+	thinking := &models.GeminiRequest{Contents: text("hi"), Extra: map[string]any{"generationConfig": map[string]any{"thinkingConfig": map[string]any{"thinkingBudget": 0}}}}
+	assert.ErrorIs(t, converter.CheckCapabilities(thinking, "gemini-2.0-flash"), ErrUnsupportedCapability)
+	assert.NoError(t, converter.CheckCapabilities(thinking, "gemini-2.5-pro"))
+
+	long := &models.GeminiRequest{Contents: text(strings.Repeat("word ", 40000))}
+	err := converter.CheckCapabilities(long, "gemini-pro")
+	assert.ErrorIs(t, err, ErrUnsupportedCapability)
+	assert.Contains(t, err.Error(), "context window")
+
+	// 未知模型不做检查
+	assert.NoError(t, converter.CheckCapabilities(image, "gemini-exp-1206"))
+}
+
+func TestFormatConverter_OutputTokenBudget(t *testing.T) {
+	converter := NewFormatConverter(logrus.New())
+	converter.SetModelCapabilities(map[string]config.ModelCapabilities{"small-model": {MaxInputTokens: 1000, MaxOutputTokens: 800}})
+	request := func(prompt string, maxTokens *int) *models.GeminiRequest {
+		return &models.GeminiRequest{
+			Contents:         []models.GeminiContent{{Role: "user", Parts: []models.GeminiPart{{Text: prompt}}}},
+			GenerationConfig: &models.GeminiGenerationConfig{MaxOutputTokens: maxTokens},
+		}
+	}
+
+	// 短提示词使用模型的最大输出token数
+	req := request("Hello", nil)
+	converter.ValidateAndFixRequest(req, "gemini-2.5-flash")
+	require.NotNil(t, req.GenerationConfig.MaxOutputTokens)
+	assert.Equal(t, 65536, *req.GenerationConfig.MaxOutputTokens)
+
+	// 长提示词只保留上下文窗口的剩余部分
+	long := strings.Repeat("word ", 600)
+	budget, ok := converter.OutputTokenBudget(request(long, nil), "small-model")
+	require.True(t, ok)
+	assert.Equal(t, 1000-EstimateTokenCount(long), budget)
+	assert.Less(t, budget, 800)
+
+	// 超出预算的显式设置被下调，预算内的保持不变
+	tooMany, fine := 100000, 100
+	req = request(long, &tooMany)
+	converter.ValidateAndFixRequest(req, "small-model")
+	assert.Equal(t, budget, *req.GenerationConfig.MaxOutputTokens)
+	req = request(long, &fine)
+	converter.ValidateAndFixRequest(req, "small-model")
+	assert.Equal(t, 100, *req.GenerationConfig.MaxOutputTokens)
+
+	// 未知模型不设置默认值
+	req = request("Hello", nil)
+	converter.ValidateAndFixRequest(req, "tunedModels/my-model")
+	assert.Nil(t, req.GenerationConfig.MaxOutputTokens)
+}
@@ -2,16 +2,18 @@ package client
 
 import (
 	"bufio"
-	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"math/rand"
 	"net/http"
 	"net/url"
+	"slices"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/ba0gu0/gemini-go-proxy/pkg/auth"
@@ -36,15 +38,34 @@ const (
 	DefaultUserAgent = "gemini-go-proxy/1.0.0"
 )
 
-// GeminiClient Gemini API客户端
+// GeminiClient Gemini API客户端，可在多个goroutine中并发使用，代理和API模式支持运行时切换
 type GeminiClient struct {
-	config     *config.Config // 使用 config.Config
-	auth       *auth.GoogleAuth
-	converter  *FormatConverter
-	client     *http.Client
-	logger     *logrus.Logger
-	proxyURLs  []string // 代理URL列表
-	randSource *rand.Rand // 随机数生成器
+	config        *config.Config // 使用 config.Config
+	auth          *auth.GoogleAuth
+	converter     *FormatConverter
+	client        *http.Client
+	logger        *logrus.Logger
+	proxyURLs     []string          // 代理URL列表
+	randSource    *rand.Rand        // 随机数生成器
+	currentProxy  string            // 当前默认使用的代理URL，重试时按请求换用其他代理
+	transports    transportPool     // 按代理缓存的传输层
+	proxyHealth   *proxyHealth      // 代理健康状态，连续失败的代理暂时不参与轮换
+	baseTransport http.RoundTripper // 自定义传输层，为空时使用内部构造的传输层
+	models        modelsCache
+	payloadStats  payloadStats
+	bufferBudget  *byteBudget          // 同时缓冲的请求体字节上限
+	coalescer     coalescer            // 合并并发的相同非流式请求
+	cache         *responseCache       // 非流式响应缓存，未配置时为nil
+	ttftStats     ttftStats            // 流式请求首token耗时统计
+	modelVersions sync.Map             // 模型ID -> 最近一次上游返回的modelVersion，用于发现上游静默更换模型
+	filter        *contentFilter       // 生成内容屏蔽词过滤，未配置时为nil
+	pipelines     map[string]*pipeline // 命名流水线，流水线名 -> 流水线
+	promptMu      sync.RWMutex         // 保护系统提示词配置，支持运行时更新
+	modeMu        sync.RWMutex         // 保护API模式、location、项目ID和Vertex AI专用端点，支持运行时切换
+	proxyMu       sync.RWMutex         // 保护代理列表、当前代理和随机数生成器
+	pacer         *accountPacer        // 同一账号上游请求间隔，未配置时为nil
+	interceptors  []Interceptor        // 请求/响应拦截器，按添加顺序调用
+	interceptorMu sync.RWMutex         // 保护拦截器列表，支持运行时添加
 }
 
 // NewGeminiClient 创建新的Gemini客户端
@@ -63,54 +84,141 @@ func NewGeminiClient(cfg *config.Config, googleAuth *auth.GoogleAuth, logger *lo
 	randSource := rand.New(rand.NewSource(time.Now().UnixNano()))
 
 	geminiClient := &GeminiClient{
-		config:     cfg,
-		auth:       googleAuth,
-		converter:  NewFormatConverterWithMode(string(cfg.APIMode) == "code_assist", logger),
-		client:     client,
-		logger:     logger,
-		proxyURLs:  make([]string, len(cfg.ProxyURLs)),
-		randSource: randSource,
+		config:       cfg,
+		auth:         googleAuth,
+		converter:    NewFormatConverterWithMode(string(cfg.APIMode) == "code_assist", logger),
+		client:       client,
+		logger:       logger,
+		proxyURLs:    make([]string, len(cfg.ProxyURLs)),
+		randSource:   randSource,
+		proxyHealth:  newProxyHealth(cfg.ProxyHealthCheck),
+		bufferBudget: newByteBudget(cfg.MaxBufferedBytes),
+		pacer:        newAccountPacer(cfg.Pacing),
+		cache:        newResponseCache(cfg.ResponseCache),
+		pipelines:    newPipelines(cfg.Pipelines, logger),
 	}
 
 	// 复制代理URL列表
 	copy(geminiClient.proxyURLs, cfg.ProxyURLs)
+	geminiClient.converter.SetModelCapabilities(cfg.ModelCapabilities)
+	// 每个请求按上下文选择代理对应的传输层，切换代理不影响进行中的请求
+	client.Transport = proxyRoundTripper{c: geminiClient}
+
+	// 使用配置中引用的自定义传输层
+	if cfg.Transport != "" {
+		if transport := lookupTransport(cfg.Transport); transport != nil {
+			geminiClient.SetTransport(transport)
+		} else {
+			logger.Warnf("Transport %q is not registered, using default transport", cfg.Transport)
+		}
+	}
 
 	// 如果配置了代理，初始化随机代理
 	if len(geminiClient.proxyURLs) > 0 {
 		geminiClient.setRandomProxy()
 	}
 
+	// 加载生成内容过滤器，配置无效时不启用
+	filter, err := newContentFilter(cfg.ResponseFilter)
+	if err != nil {
+		logger.Errorf("Response filter disabled: %v", err)
+	}
+	geminiClient.filter = filter
+
 	return geminiClient
 }
 
-// 构建API URL
-func (c *GeminiClient) buildAPIURL(modelID, action string) string {
+// 构建API URL，上下文要求数据区域时Vertex AI请求使用对应的location；
+// 模型资源名中的location不合法时返回 ErrInvalidLocation
+func (c *GeminiClient) buildAPIURL(ctx context.Context, modelID, action string) (string, error) {
 	var baseURL string
 	
-	if c.config.APIMode == config.CodeAssist {
+	if c.apiMode() == config.CodeAssist {
 		// Code Assist API
 		baseURL = CodeAssistEndpoint
-		return fmt.Sprintf("%s/%s:%s", baseURL, CodeAssistVersion, action)
+		return fmt.Sprintf("%s/%s:%s", baseURL, CodeAssistVersion, action), nil
 	}
 	
 	// 检查是否使用Vertex AI
-	if c.config.APIMode == config.VertexAI {
+	if c.apiMode() == config.VertexAI {
 		// Vertex AI format
-		projectID := c.auth.GetProjectID()
-		location := c.config.Location
-		baseURL = fmt.Sprintf(VertexAPIEndpoint, location)
-		return fmt.Sprintf("%s/%s/projects/%s/locations/%s/publishers/google/models/%s:%s",
-			baseURL, VertexAPIVersion, projectID, location, modelID, action)
+		resourcePath, location, err := c.vertexModelPath(ctx, modelID)
+		if err != nil {
+			return "", err
+		}
+		apiURL, err := vertexBaseURL(location)
+		if err != nil {
+			return "", err
+		}
+		apiURL.Path = fmt.Sprintf("/%s/%s:%s", VertexAPIVersion, resourcePath, action)
+		return apiURL.String(), nil
 	}
 	
 	// Google AI Studio format
 	baseURL = DefaultAPIEndpoint
 	apiVersion := DefaultAPIVersion
-	return fmt.Sprintf("%s/%s/models/%s:%s", baseURL, apiVersion, modelID, action)
+	return fmt.Sprintf("%s/%s/%s:%s", baseURL, apiVersion, aiStudioModelPath(modelID), action), nil
+}
+
+// aiStudioModelPath 构建AI Studio模型资源路径，支持 models/ 和 tunedModels/ 前缀
+func aiStudioModelPath(modelID string) string {
+	if strings.HasPrefix(modelID, "models/") || strings.HasPrefix(modelID, "tunedModels/") {
+		return modelID
+	}
+	return "models/" + modelID
+}
+
+// vertexModelPath 构建Vertex AI模型资源路径并返回对应的location
+// 支持完整的 projects/.../models/... 资源名、publishers/ 和 endpoints/ 前缀、配置的专用端点和裸模型ID；
+// 资源名来自客户端，其中的location不合法时返回 ErrInvalidLocation
+func (c *GeminiClient) vertexModelPath(ctx context.Context, modelID string) (string, string, error) {
+	// 配置了专用端点的模型路由到对应端点
+	if endpoint := c.vertexEndpoint(modelID); endpoint != "" {
+		if !strings.HasPrefix(endpoint, "projects/") {
+			endpoint = "endpoints/" + strings.TrimPrefix(endpoint, "endpoints/")
+		}
+		modelID = endpoint
+	}
+
+	location := c.location()
+	if region, ok := dataRegionLocation(ctx); ok {
+		location = region
+	}
+	if strings.HasPrefix(modelID, "projects/") {
+		// 完整资源名中的location优先
+		segments := strings.Split(modelID, "/")
+		for i := 0; i+1 < len(segments); i++ {
+			if segments[i] == "locations" {
+				location = segments[i+1]
+				break
+			}
+		}
+		if err := ValidateLocation(location); err != nil {
+			return "", "", err
+		}
+		return modelID, location, nil
+	}
+
+	prefix := fmt.Sprintf("projects/%s/locations/%s", c.auth.GetProjectID(), location)
+	if strings.HasPrefix(modelID, "publishers/") || strings.HasPrefix(modelID, "endpoints/") {
+		return prefix + "/" + modelID, location, nil
+	}
+	return fmt.Sprintf("%s/publishers/google/models/%s", prefix, strings.TrimPrefix(modelID, "models/")), location, nil
+}
+
+// baseModelName 去掉资源路径前缀，返回模型名称
+func baseModelName(modelID string) string {
+	if index := strings.LastIndex(modelID, "/"); index >= 0 {
+		return modelID[index+1:]
+	}
+	return modelID
 }
 
 // 创建HTTP请求
 func (c *GeminiClient) createRequest(ctx context.Context, method, url string, body io.Reader) (*http.Request, error) {
+	if err := checkDataRegion(ctx, url); err != nil {
+		return nil, err
+	}
 	req, err := http.NewRequestWithContext(ctx, method, url, body)
 	if err != nil {
 		return nil, err
@@ -120,68 +228,237 @@ func (c *GeminiClient) createRequest(ctx context.Context, method, url string, bo
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("User-Agent", c.config.UserAgent)
 
-	// 设置认证
+	// Vertex AI流量类别和配额项目头部
+	if c.apiMode() == config.VertexAI {
+		c.applyVertexHeaders(ctx, req)
+	}
+
+	// 设置认证，多账号时按请求轮询
 	if c.auth != nil && c.auth.IsInitialized() {
-		token, err := c.auth.GetToken()
-		if err != nil {
+		if req, err = c.setAuthorization(req); err != nil {
 			return nil, fmt.Errorf("failed to get auth token: %w", err)
 		}
-		req.Header.Set("Authorization", "Bearer "+token.AccessToken)
 	}
 
 	return req, nil
 }
 
-// SendRequest 发送请求到Gemini API (原生格式)
+// SendRequest 发送请求到Gemini API (原生格式)，modelID 为流水线名时按流水线处理
 func (c *GeminiClient) SendRequest(ctx context.Context, modelID string, req *models.GeminiRequest) (*models.GeminiResponse, error) {
-	return c.sendRequestWithRetry(ctx, modelID, req, false)
+	if p := c.lookupPipeline(modelID); p != nil {
+		var resp *models.GeminiResponse
+		err := c.runPipeline(ctx, p, req, func(ctx context.Context, modelID string, req *models.GeminiRequest) (delivered bool, err error) {
+			resp, err = c.sendCachedRequest(ctx, modelID, req)
+			return false, err
+		})
+		return resp, err
+	}
+	return c.sendCachedRequest(ctx, modelID, req)
+}
+
+// sendCachedRequest 发送请求，按配置合并并发的相同请求和使用响应缓存
+func (c *GeminiClient) sendCachedRequest(ctx context.Context, modelID string, req *models.GeminiRequest) (*models.GeminiResponse, error) {
+	if !c.config.CoalesceRequests && c.cache == nil {
+		return c.sendRequest(ctx, modelID, req)
+	}
+	key, ok := coalesceKey(modelID, req)
+	if !ok {
+		return c.sendRequest(ctx, modelID, req)
+	}
+	// 不同数据区域的请求不能共用上游响应
+	if location, ok := dataRegionLocation(ctx); ok {
+		key = location + "/" + key
+	}
+	// 流水线版本可能使用不同的内容过滤，与直接调用模型的请求分开
+	if p := pipelineFromContext(ctx); p != nil {
+		key = "pipeline:" + p.pipeline + "@" + p.name + "/" + key
+	}
+	// 回复语言和Vertex AI请求类型可按API密钥设置，在发送前才应用到请求，需要计入键
+	if language := c.responseLanguage(ctx); language != "" {
+		key = "language:" + language + "/" + key
+	}
+	if c.apiMode() == config.VertexAI {
+		if requestType := c.vertexRequestType(ctx); requestType != "" {
+			key = "request-type:" + requestType + "/" + key
+		}
+	}
+
+	// TTL内的相同请求直接返回缓存的响应
+	cc := cacheControl(ctx)
+	if c.cache != nil && !cc.NoCache && !cc.NoStore {
+		if resp, ok := c.cache.get(key); ok {
+			return resp, nil
+		}
+	}
+
+	// 并发的相同请求只向上游发送一次
+	var resp *models.GeminiResponse
+	var err error
+	if c.config.CoalesceRequests {
+		resp, err = c.coalescer.do(ctx, key, func() (*models.GeminiResponse, error) {
+			return c.sendRequest(ctx, modelID, req)
+		})
+	} else {
+		resp, err = c.sendRequest(ctx, modelID, req)
+	}
+	if err == nil && c.cache != nil && !cc.NoStore && cacheable(resp) {
+		c.cache.put(key, resp)
+	}
+	return resp, err
+}
+
+// sendRequest 发送请求，按配置进行续写和结构化输出校验
+func (c *GeminiClient) sendRequest(ctx context.Context, modelID string, req *models.GeminiRequest) (*models.GeminiResponse, error) {
+	validate := c.needsStructuredOutputValidation(req)
+	continuation := c.config.ContinuationMaxRounds > 0
+	if !validate && !continuation {
+		return c.sendRequestWithRetry(ctx, modelID, req, false)
+	}
+
+	// 保留原始请求副本，用于可能的续写和修复请求
+	original := cloneGeminiRequest(req)
+	resp, err := c.sendRequestWithRetry(ctx, modelID, req, false)
+	if err != nil {
+		return nil, err
+	}
+
+	if continuation {
+		resp = c.continueTruncatedResponse(ctx, modelID, original, resp)
+	}
+
+	if validate {
+		return c.validateStructuredOutput(ctx, modelID, original, resp)
+	}
+	return resp, nil
 }
 
-// sendRequestWithRetry 发送请求，支持代理轮换重试
+// SendRequestRaw 发送请求并返回未解析为类型的响应体，供需要类型未覆盖字段的调用方使用，modelID 为流水线名时按流水线处理。
+// 响应体不是上游的原始字节：已按配置应用内容过滤和拦截器的 OnResponse，只改写被修改的字段，
+// 其他字段（包括未建模的字段）保留；不进行续写和结构化输出校验。
+// Code Assist模式下返回的是包含 response 字段的包装结构。调用方的 req 不会被修改
+func (c *GeminiClient) SendRequestRaw(ctx context.Context, modelID string, req *models.GeminiRequest) (json.RawMessage, error) {
+	req = cloneGeminiRequest(req)
+	if p := c.lookupPipeline(modelID); p != nil {
+		var body json.RawMessage
+		err := c.runPipeline(ctx, p, req, func(ctx context.Context, modelID string, req *models.GeminiRequest) (delivered bool, err error) {
+			body, err = c.doRequestWithRetry(ctx, modelID, req, false)
+			return false, err
+		})
+		return body, err
+	}
+	body, err := c.doRequestWithRetry(ctx, modelID, req, false)
+	if err != nil {
+		return nil, err
+	}
+	return json.RawMessage(body), nil
+}
+
+// sendRequestWithRetry 发送请求并解析响应，支持代理轮换重试
 func (c *GeminiClient) sendRequestWithRetry(ctx context.Context, modelID string, req *models.GeminiRequest, isStream bool) (*models.GeminiResponse, error) {
-	// 验证并修正请求参数
+	body, err := c.doRequestWithRetry(ctx, modelID, req, isStream)
+	if err != nil {
+		return nil, err
+	}
+
+	// 对于流式请求，直接返回占位响应
+	if isStream {
+		return &models.GeminiResponse{}, nil
+	}
+
+	// 解析响应
+	var geminiResp models.GeminiResponse
+
+	if c.apiMode() == config.CodeAssist {
+		// Code Assist API响应格式: { response: { candidates: [...] } }
+		var codeAssistResp models.CodeAssistResponse
+		if err := json.Unmarshal(body, &codeAssistResp); err != nil {
+			return nil, fmt.Errorf("failed to decode Code Assist response: %w", err)
+		}
+		if codeAssistResp.Response != nil {
+			geminiResp = *codeAssistResp.Response
+		}
+	} else {
+		// 标准Gemini API响应格式
+		if err := json.Unmarshal(body, &geminiResp); err != nil {
+			return nil, fmt.Errorf("failed to decode response: %w", err)
+		}
+	}
+
+	c.recordModelVersion(ctx, modelID, geminiResp.ModelVersion)
+
+	// 用量已在 doRequestWithRetry 中记录
+	if geminiResp.UsageMetadata != nil {
+		c.logger.Infof("Gemini API request completed: %s, tokens: %d/%d",
+			modelID, geminiResp.UsageMetadata.PromptTokenCount, geminiResp.UsageMetadata.CandidatesTokenCount)
+	}
+
+	return &geminiResp, nil
+}
+
+// doRequestWithRetry 发送请求并返回原始响应体，支持代理轮换重试
+func (c *GeminiClient) doRequestWithRetry(ctx context.Context, modelID string, req *models.GeminiRequest, isStream bool) ([]byte, error) {
+	// 非流式请求的总时长（含重试）受 timeout_seconds 限制
+	if !isStream {
+		var cancel context.CancelFunc
+		ctx, cancel = withRequestTimeout(ctx, c.config.GetTimeout())
+		defer cancel()
+	}
+
+	// 验证并修正请求参数，拒绝模型不支持的功能
 	c.converter.ValidateAndFixRequest(req, modelID)
+	if err := c.converter.CheckCapabilities(req, modelID); err != nil {
+		return nil, err
+	}
 
-	// 从文件应用系统提示
-	if err := c._applySystemPromptFromFile(req); err != nil {
-		c.logger.Warnf("Failed to apply system prompt from file: %v", err)
-		// 不中断流程，继续执行
+	// 从文件应用系统提示，流水线自带系统提示词时不再应用
+	if !pipelineFromContext(ctx).hasSystemPrompt() {
+		if err := c._applySystemPromptFromFile(req); err != nil {
+			c.logger.Warnf("Failed to apply system prompt from file: %v", err)
+			// 不中断流程，继续执行
+		}
+	}
+
+	// 追加回复语言指令
+	c.applyResponseLanguage(ctx, req)
+
+	if err := c.interceptRequest(ctx, modelID, req); err != nil {
+		return nil, err
 	}
 
 	// 构建请求体 - Code Assist API需要特殊包装
-	var reqBody []byte
-	var err error
-	
-	if c.config.APIMode == config.CodeAssist {
+	var body any = req
+	if c.apiMode() == config.CodeAssist {
 		// Code Assist API格式: { model, project, request }
-		codeAssistReq := &models.CodeAssistRequest{
-			Model:   modelID,
-			Project: c.config.ProjectID,
+		body = &models.CodeAssistRequest{
+			Model:   strings.TrimPrefix(modelID, "models/"),
+			Project: c.projectID(),
 			Request: req,
 		}
-		reqBody, err = json.Marshal(codeAssistReq)
-	} else {
-		// 标准Gemini API格式
-		reqBody, err = json.Marshal(req)
 	}
-	
+
+	payload, err := c.newRequestPayload(ctx, body, estimateRequestSize(req))
 	if err != nil {
-		return nil, fmt.Errorf("failed to marshal request: %w", err)
+		return nil, err
 	}
+	defer payload.release()
 
 	// 构建URL
-	var apiURL string
+	action := "generateContent"
 	if isStream {
-		apiURL = c.buildAPIURL(modelID, "streamGenerateContent")
-		if c.config.APIMode == config.CodeAssist || c.config.APIMode == config.AIStudio {
+		action = "streamGenerateContent"
+	}
+	apiURL, err := c.buildAPIURL(ctx, modelID, action)
+	if err != nil {
+		return nil, err
+	}
+	if isStream {
+		if c.apiMode() == config.CodeAssist || c.apiMode() == config.AIStudio {
 			parsedURL, _ := url.Parse(apiURL)
 			query := parsedURL.Query()
 			query.Set("alt", "sse")
 			parsedURL.RawQuery = query.Encode()
 			apiURL = parsedURL.String()
 		}
-	} else {
-		apiURL = c.buildAPIURL(modelID, "generateContent")
 	}
 
 	// 最大重试次数（包括代理轮换）
@@ -189,33 +466,42 @@ func (c *GeminiClient) sendRequestWithRetry(ctx context.Context, modelID string,
 	if maxRetries <= 0 {
 		maxRetries = 3
 	}
+	// 流式发送的请求体无法重放
+	if !payload.retryable() {
+		maxRetries = 1
+	}
 
-	var lastErr error
+	var (
+		lastErr  error
+		proxyURL string
+	)
 	for attempt := 0; attempt < maxRetries; attempt++ {
-		// 如果不是第一次尝试且有多个代理，轮换代理
-		if attempt > 0 && len(c.proxyURLs) > 1 {
-			if rotateErr := c.RotateProxy(); rotateErr != nil {
-				c.logger.Warnf("Failed to rotate proxy: %v", rotateErr)
-			}
-		}
+		// 如果不是第一次尝试且有多个代理，本次尝试换用其他代理
+		proxyURL = c.attemptProxy(attempt, proxyURL)
 
-		// 创建HTTP请求
-		httpReq, err := c.createRequest(ctx, "POST", apiURL, bytes.NewBuffer(reqBody))
+		// 创建HTTP请求，每次尝试单独计算首字节超时
+		attemptCtx, attemptCancel := context.WithCancel(withProxy(ctx, proxyURL))
+		defer attemptCancel()
+		httpReq, err := c.createRequest(attemptCtx, "POST", apiURL, payload.reader())
 		if err != nil {
 			lastErr = err
 			continue
 		}
 
 		c.logger.Debugf("Sending Gemini API request: %s (attempt %d/%d)", modelID, attempt+1, maxRetries)
+		c.recordTrace(httpReq.Context(), modelID, attempt)
 
 		// 发送请求
-		resp, err := c.client.Do(httpReq)
+		requestStart := time.Now()
+		resp, err := c.doWithFirstByteTimeout(httpReq, attemptCancel)
+		c.recordUpstreamLatency(ctx, time.Since(requestStart))
+		c.recordProxyResult(proxyURL, time.Since(requestStart), err)
 		if err != nil {
 			c.logger.Warnf("Request attempt %d failed: %v", attempt+1, err)
 			lastErr = fmt.Errorf("request failed: %w", err)
 			
 			// 如果是网络错误且有多个代理，继续尝试下一个代理
-			if len(c.proxyURLs) > 1 && c.isNetworkError(err) {
+			if c.proxyCount() > 1 && c.isNetworkError(err) {
 				continue
 			}
 			return nil, lastErr
@@ -223,51 +509,51 @@ func (c *GeminiClient) sendRequestWithRetry(ctx context.Context, modelID string,
 
 		// 对于流式请求，直接返回响应
 		if isStream {
-			return &models.GeminiResponse{}, nil // 占位响应，实际数据通过resp.Body流式读取
+			return nil, nil // 占位响应，实际数据通过resp.Body流式读取
 		}
 
 		defer resp.Body.Close()
 
 		if resp.StatusCode != http.StatusOK {
+			// 限流的账号进入冷却、被封禁的账号被隔离，由下一次尝试换用其他账号
+			nextAccount := c.cooldownAccount(httpReq, resp)
 			body, _ := io.ReadAll(resp.Body)
 			lastErr = fmt.Errorf("API request failed with status %d: %s", resp.StatusCode, string(body))
-			
+
+			if nextAccount {
+				c.logger.Warnf("Received status %d, trying next account", resp.StatusCode)
+				continue
+			}
+
 			// 对于某些错误代码，尝试轮换代理
-			if (resp.StatusCode == 429 || resp.StatusCode >= 500) && len(c.proxyURLs) > 1 {
+			if (resp.StatusCode == 429 || resp.StatusCode >= 500) && c.proxyCount() > 1 {
 				c.logger.Warnf("Received status %d, trying next proxy", resp.StatusCode)
 				continue
 			}
 			return nil, lastErr
 		}
 
-		// 解析响应
-		var geminiResp models.GeminiResponse
-		
-		if c.config.APIMode == config.CodeAssist {
-			// Code Assist API响应格式: { response: { candidates: [...] } }
-			var codeAssistResp models.CodeAssistResponse
-			if err := json.NewDecoder(resp.Body).Decode(&codeAssistResp); err != nil {
-				lastErr = fmt.Errorf("failed to decode Code Assist response: %w", err)
-				continue
-			}
-			if codeAssistResp.Response != nil {
-				geminiResp = *codeAssistResp.Response
-			}
-		} else {
-			// 标准Gemini API响应格式
-			if err := json.NewDecoder(resp.Body).Decode(&geminiResp); err != nil {
-				lastErr = fmt.Errorf("failed to decode response: %w", err)
-				continue
-			}
+		// 读取响应，响应不完整时重试
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			lastErr = fmt.Errorf("failed to read response: %w", err)
+			continue
 		}
-
-		// 记录使用统计
-		if geminiResp.UsageMetadata != nil {
-			c.logger.Infof("Gemini API request completed: %s, tokens: %d/%d",
-				modelID, geminiResp.UsageMetadata.PromptTokenCount, geminiResp.UsageMetadata.CandidatesTokenCount)
+		if !json.Valid(body) {
+			lastErr = fmt.Errorf("failed to decode response: invalid JSON")
+			continue
+		}
+		c.recordResponseUsage(ctx, body)
+		if err := c.checkPromptBlocked(body); err != nil {
+			return nil, err
 		}
 
-		return &geminiResp, nil
+		if filter := c.responseFilter(ctx); filter != nil {
+			if body, err = filter.filterResponseBody(body); err != nil {
+				return nil, err
+			}
+		}
+		return c.interceptResponseBody(ctx, modelID, body)
 	}
 
 	return nil, fmt.Errorf("request failed after %d attempts: %w", maxRetries, lastErr)
@@ -300,10 +586,33 @@ func (c *GeminiClient) isNetworkError(err error) bool {
 	return false
 }
 
-// SendStreamRequest 发送流式请求到Gemini API (原生格式)
+// SendStreamRequest 发送流式请求到Gemini API (原生格式)，modelID 为流水线名时按流水线处理，
+// 已经回调过数据块的流失败时不再换用候补模型
 func (c *GeminiClient) SendStreamRequest(ctx context.Context, modelID string, req *models.GeminiRequest, callback func(*models.GeminiStreamChunk) error) error {
-	// 发送Gemini流式请求
-	resp, err := c.SendStreamRequestRaw(ctx, modelID, req)
+	if p := c.lookupPipeline(modelID); p != nil {
+		return c.runPipeline(ctx, p, req, func(ctx context.Context, modelID string, req *models.GeminiRequest) (delivered bool, err error) {
+			err = c.sendStream(ctx, modelID, req, func(chunk *models.GeminiStreamChunk) error {
+				delivered = true
+				return callback(chunk)
+			})
+			return delivered, err
+		})
+	}
+	return c.sendStream(ctx, modelID, req, callback)
+}
+
+// sendStream 发送流式请求，按配置自动续写被截断的输出
+func (c *GeminiClient) sendStream(ctx context.Context, modelID string, req *models.GeminiRequest, callback func(*models.GeminiStreamChunk) error) error {
+	if c.config.ContinuationMaxRounds > 0 {
+		return c.sendStreamRequestWithContinuation(ctx, modelID, req, callback)
+	}
+	return c.sendStreamRequest(ctx, modelID, req, callback)
+}
+
+// sendStreamRequest 发送单次流式请求并逐块回调
+func (c *GeminiClient) sendStreamRequest(ctx context.Context, modelID string, req *models.GeminiRequest, callback func(*models.GeminiStreamChunk) error) error {
+	// 发送Gemini流式请求，续写由 sendStreamRequestWithContinuation 处理
+	resp, err := c.sendStreamRequestRaw(ctx, modelID, req)
 	if err != nil {
 		return err
 	}
@@ -329,7 +638,7 @@ func (c *GeminiClient) SendStreamRequest(ctx context.Context, modelID string, re
 				var chunk models.GeminiStreamChunk
 				
 				// 检查是否为Code Assist API格式 { response: {...} }
-				if c.config.APIMode == config.CodeAssist {
+				if c.apiMode() == config.CodeAssist {
 					var codeAssistChunk models.CodeAssistStreamChunk
 					if err := json.Unmarshal([]byte(data), &codeAssistChunk); err != nil {
 						c.logger.Warnf("Failed to parse Code Assist stream chunk: %v", err)
@@ -346,6 +655,8 @@ func (c *GeminiClient) SendStreamRequest(ctx context.Context, modelID string, re
 					}
 				}
 				
+				c.recordModelVersion(ctx, modelID, chunk.ModelVersion)
+
 				if err := callback(&chunk); err != nil {
 					return fmt.Errorf("callback error: %w", err)
 				}
@@ -361,37 +672,64 @@ func (c *GeminiClient) SendStreamRequest(ctx context.Context, modelID string, re
 	return nil
 }
 
-// SendStreamRequestRaw 发送原始流式请求，返回http.Response
+// SendStreamRequestRaw 发送原始流式请求，返回http.Response，modelID 为流水线名时按流水线处理；
+// 配置了 continuation_max_rounds 时被截断的流自动续写，续写的流接在同一个响应体中
 func (c *GeminiClient) SendStreamRequestRaw(ctx context.Context, modelID string, req *models.GeminiRequest) (*http.Response, error) {
-	// 验证并修正请求参数
+	if p := c.lookupPipeline(modelID); p != nil {
+		var resp *http.Response
+		err := c.runPipeline(ctx, p, req, func(ctx context.Context, modelID string, req *models.GeminiRequest) (delivered bool, err error) {
+			resp, err = c.sendStreamRaw(ctx, modelID, req)
+			return false, err
+		})
+		return resp, err
+	}
+	return c.sendStreamRaw(ctx, modelID, req)
+}
+
+// sendStreamRequestRaw 发送原始流式请求，首token超时时换用其他账号/代理重试
+func (c *GeminiClient) sendStreamRequestRaw(ctx context.Context, modelID string, req *models.GeminiRequest) (*http.Response, error) {
+	// 验证并修正请求参数，拒绝模型不支持的功能
 	c.converter.ValidateAndFixRequest(req, modelID)
+	if err := c.converter.CheckCapabilities(req, modelID); err != nil {
+		return nil, err
+	}
+
+	// 从文件应用系统提示，流水线自带系统提示词时不再应用
+	if !pipelineFromContext(ctx).hasSystemPrompt() {
+		if err := c._applySystemPromptFromFile(req); err != nil {
+			c.logger.Warnf("Failed to apply system prompt from file: %v", err)
+			// 不中断流程，继续执行
+		}
+	}
+
+	// 追加回复语言指令
+	c.applyResponseLanguage(ctx, req)
 
-	// 从文件应用系统提示
-	if err := c._applySystemPromptFromFile(req); err != nil {
-		c.logger.Warnf("Failed to apply system prompt from file: %v", err)
-		// 不中断流程，继续执行
+	if err := c.interceptRequest(ctx, modelID, req); err != nil {
+		return nil, err
 	}
 
 	// 构建请求体
-	var reqBody []byte
-	var err error
-	if c.config.APIMode == config.CodeAssist {
-		codeAssistReq := &models.CodeAssistRequest{
-			Model:   modelID,
-			Project: c.config.ProjectID,
+	var body any = req
+	if c.apiMode() == config.CodeAssist {
+		body = &models.CodeAssistRequest{
+			Model:   strings.TrimPrefix(modelID, "models/"),
+			Project: c.projectID(),
 			Request: req,
 		}
-		reqBody, err = json.Marshal(codeAssistReq)
-	} else {
-		reqBody, err = json.Marshal(req)
 	}
+	payload, err := c.newRequestPayload(ctx, body, estimateRequestSize(req))
 	if err != nil {
-		return nil, fmt.Errorf("failed to marshal request: %w", err)
+		return nil, err
 	}
 
 	// 构建URL
-	apiURL := c.buildAPIURL(modelID, "streamGenerateContent")
-	if c.config.APIMode == config.CodeAssist || c.config.APIMode == config.AIStudio {
+	apiURL, err := c.buildAPIURL(ctx, modelID, "streamGenerateContent")
+	if err != nil {
+		payload.release()
+		return nil, err
+	}
+	if c.apiMode() == config.CodeAssist || c.apiMode() == config.AIStudio {
 		parsedURL, _ := url.Parse(apiURL)
 		query := parsedURL.Query()
 		query.Set("alt", "sse")
@@ -399,31 +737,107 @@ func (c *GeminiClient) SendStreamRequestRaw(ctx context.Context, modelID string,
 		apiURL = parsedURL.String()
 	}
 
-	// 创建HTTP请求
-	httpReq, err := c.createRequest(ctx, "POST", apiURL, bytes.NewBuffer(reqBody))
+	// 配置了首token截止时间时，超时未返回数据的请求换用其他账号/代理重试
+	attempts := 1
+	if c.config.GetTTFTDeadline() > 0 && payload.retryable() {
+		attempts = c.config.MaxRetries
+		if attempts <= 0 {
+			attempts = 3
+		}
+	}
+
+	var (
+		lastErr  error
+		proxyURL string
+	)
+	for attempt := 0; attempt < attempts; attempt++ {
+		if attempt > 0 {
+			c.ttftStats.retried()
+		}
+		proxyURL = c.attemptProxy(attempt, proxyURL)
+
+		resp, err := c.sendStreamAttempt(withProxy(ctx, proxyURL), modelID, apiURL, payload, attempt)
+		if errors.Is(err, ErrTTFTDeadline) && ctx.Err() == nil {
+			c.logger.Warnf("Stream attempt %d/%d for %s: %v", attempt+1, attempts, modelID, err)
+			lastErr = err
+			continue
+		}
+		if err != nil {
+			payload.release()
+			return nil, err
+		}
+		return resp, nil
+	}
+
+	payload.release()
+	return nil, fmt.Errorf("stream request failed after %d attempts: %w", attempts, lastErr)
+}
+
+// sendStreamAttempt 发送一次流式请求，成功时响应体关闭后释放请求体缓冲区
+func (c *GeminiClient) sendStreamAttempt(ctx context.Context, modelID, apiURL string, payload *requestPayload, attempt int) (*http.Response, error) {
+	// 创建HTTP请求，流式响应的总时长受 stream_timeout_seconds 限制
+	streamCtx, cancel := withRequestTimeout(ctx, c.config.GetStreamTimeout())
+	httpReq, err := c.createRequest(streamCtx, "POST", apiURL, payload.reader())
 	if err != nil {
+		cancel()
 		return nil, err
 	}
 
 	c.logger.Debugf("Sending Gemini streaming API request: %s", modelID)
+	c.recordTrace(httpReq.Context(), modelID, attempt)
 
 	// 发送请求
-	resp, err := c.client.Do(httpReq)
+	proxyURL := c.requestProxy(ctx)
+	requestStart := time.Now()
+	resp, err := c.doWithFirstByteTimeout(httpReq, cancel)
+	c.recordUpstreamLatency(ctx, time.Since(requestStart))
+	c.recordProxyResult(proxyURL, time.Since(requestStart), err)
 	if err != nil {
+		cancel()
 		return nil, fmt.Errorf("stream request failed: %w", err)
 	}
 
 	if resp.StatusCode != http.StatusOK {
+		c.cooldownAccount(httpReq, resp)
 		body, _ := io.ReadAll(resp.Body)
 		resp.Body.Close()
+		cancel()
 		return nil, fmt.Errorf("stream API request failed with status %d: %s", resp.StatusCode, string(body))
 	}
 
+	body := c.withFirstTokenRecorder(ctx, c.withIdleTimeout(resp.Body, cancel), modelID, requestStart)
+	body = c.withUsageRecorder(ctx, body)
+	if deadline := c.config.GetTTFTDeadline(); deadline > 0 {
+		if body, err = awaitFirstToken(body, deadline, cancel); err != nil {
+			cancel()
+			return nil, err
+		}
+	}
+	if body, err = c.awaitPromptFeedback(body); err != nil {
+		cancel()
+		return nil, err
+	}
+
+	if filter := c.responseFilter(ctx); filter != nil {
+		body = filter.sseReader(body, c.apiMode() == config.CodeAssist)
+	}
+	body = c.interceptSSEReader(ctx, modelID, body)
+
+	// 流结束后再释放请求体缓冲区和请求上下文
+	resp.Body = &releaseReadCloser{ReadCloser: body, release: func() {
+		cancel()
+		payload.release()
+	}}
 	return resp, nil
 }
 
 // SendOpenAIRequest 发送OpenAI格式的请求
 func (c *GeminiClient) SendOpenAIRequest(ctx context.Context, req *models.OpenAIRequest) (*models.OpenAIResponse, error) {
+	// best-of模式：生成多个候选并选出最佳结果
+	if req.BestOf != nil && *req.BestOf > 1 {
+		return c.sendOpenAIBestOfRequest(ctx, req)
+	}
+
 	// 转换为Gemini格式
 	geminiReq, err := c.converter.OpenAIToGeminiRequest(req)
 	if err != nil {
@@ -454,67 +868,125 @@ func (c *GeminiClient) SendOpenAIStreamRequest(ctx context.Context, req *models.
 	}
 
 	requestID := c.converter.GenerateRequestID()
-	roleSent := false // 标记是否已发送role
+	state := &StreamState{} // 记录是否已发送role和已发送的函数调用数量
+	var usage *models.GeminiUsageMetadata
 
 	// 发送Gemini流式请求
-	return c.SendStreamRequest(ctx, req.Model, geminiReq, func(chunk *models.GeminiStreamChunk) error {
+	err = c.SendStreamRequest(ctx, req.Model, geminiReq, func(chunk *models.GeminiStreamChunk) error {
+		// Gemini的usageMetadata是累计值，保留最后一次
+		if chunk.UsageMetadata != nil {
+			usage = chunk.UsageMetadata
+		}
 		// 转换为OpenAI流式格式
-		openaiChunk, err := c.converter.GeminiStreamToOpenAI(chunk, req.Model, requestID, &roleSent)
+		openaiChunk, err := c.converter.GeminiStreamToOpenAI(chunk, req.Model, requestID, state)
 		if err != nil {
 			return fmt.Errorf("failed to convert stream chunk: %w", err)
 		}
 		
 		return callback(openaiChunk)
 	})
-}
-
-// ListModels 获取模型列表 (OpenAI格式)
-func (c *GeminiClient) ListModels(ctx context.Context) (*models.OpenAIModelsResponse, error) {
-	// 构建URL
-	var apiURL string
-	if c.config.APIMode == config.CodeAssist {
-		apiURL = fmt.Sprintf("%s/%s/models", CodeAssistEndpoint, CodeAssistVersion)
-	} else if c.config.APIMode == config.VertexAI {
-		// Vertex AI不提供模型列表API，返回预定义列表
-		return c.converter.GenerateModelsList(), nil
-	} else {
-		apiURL = fmt.Sprintf("%s/%s/models", DefaultAPIEndpoint, DefaultAPIVersion)
+	if err != nil {
+		return err
 	}
 
-	// 创建HTTP请求
-	httpReq, err := c.createRequest(ctx, "GET", apiURL, nil)
-	if err != nil {
-		return nil, err
+	// 按 stream_options.include_usage 在最后发送用量块
+	if req.StreamOptions != nil && req.StreamOptions.IncludeUsage {
+		return callback(c.converter.StreamUsageChunk(usage, req.Model, requestID))
 	}
+	return nil
+}
 
-	c.logger.Debug("Fetching Gemini models list")
+// DefaultParallelMaxModels 单个并行请求默认允许的最大模型数
+const DefaultParallelMaxModels = 4
 
-	// 发送请求
-	resp, err := c.client.Do(httpReq)
-	if err != nil {
-		return nil, fmt.Errorf("models request failed: %w", err)
+// ErrTooManyParallelModels 并行请求的模型数超过 parallel_max_models
+var ErrTooManyParallelModels = errors.New("too many models for parallel request")
+
+// SendOpenAIParallelRequest 将同一请求并行发送到多个模型，返回所有模型的结果；
+// 模型列表去重后超过 parallel_max_models 时返回 ErrTooManyParallelModels
+func (c *GeminiClient) SendOpenAIParallelRequest(ctx context.Context, req *models.OpenAIRequest, modelIDs []string) (*models.OpenAIParallelResponse, error) {
+	if req == nil {
+		return nil, fmt.Errorf("request cannot be nil")
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		// 如果API不支持模型列表，返回默认列表
-		if resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusMethodNotAllowed {
-			c.logger.Debug("Models API not available, using default list")
-			return c.converter.GenerateModelsList(), nil
+	// 未指定模型时依次使用配置中的默认模型列表和逗号分隔的model字段
+	if len(modelIDs) == 0 {
+		modelIDs = c.config.ParallelModels
+	}
+	if len(modelIDs) == 0 && req.Model != "" {
+		modelIDs = strings.Split(req.Model, ",")
+	}
+
+	// 去重并过滤空模型名
+	seen := make(map[string]bool, len(modelIDs))
+	targets := make([]string, 0, len(modelIDs))
+	for _, modelID := range modelIDs {
+		modelID = strings.TrimSpace(modelID)
+		if modelID == "" || seen[modelID] {
+			continue
 		}
-		
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("models API request failed with status %d: %s", resp.StatusCode, string(body))
+		seen[modelID] = true
+		targets = append(targets, modelID)
 	}
 
-	// 解析响应
-	var geminiModels models.GeminiModelsResponse
-	if err := json.NewDecoder(resp.Body).Decode(&geminiModels); err != nil {
-		return nil, fmt.Errorf("failed to decode models response: %w", err)
+	if len(targets) == 0 {
+		return nil, fmt.Errorf("no models specified for parallel request")
+	}
+	// 每个模型都会发起上游请求并占用共享的账号配额，限制单个请求的扇出
+	maxModels := c.config.ParallelMaxModels
+	if maxModels <= 0 {
+		maxModels = DefaultParallelMaxModels
+	}
+	if len(targets) > maxModels {
+		return nil, fmt.Errorf("%w: %d models requested, at most %d allowed", ErrTooManyParallelModels, len(targets), maxModels)
 	}
 
-	// 转换为OpenAI格式
-	return c.converter.ConvertGeminiModels(&geminiModels), nil
+	results := make([]models.OpenAIParallelResult, len(targets))
+	var wg sync.WaitGroup
+	for i, modelID := range targets {
+		wg.Add(1)
+		go func(i int, modelID string) {
+			defer wg.Done()
+
+			// 每个模型使用独立的请求副本，避免并发修改
+			modelReq := cloneOpenAIRequest(req)
+			modelReq.Model = modelID
+			modelReq.Stream = false
+
+			result := models.OpenAIParallelResult{Model: modelID}
+			resp, err := c.SendOpenAIRequest(ctx, modelReq)
+			if err != nil {
+				c.logger.Warnf("Parallel request to %s failed: %v", modelID, err)
+				result.Error = &models.ErrorDetail{Type: "api_error", Message: err.Error()}
+			} else {
+				result.Response = resp
+			}
+			results[i] = result
+		}(i, modelID)
+	}
+	wg.Wait()
+
+	c.logger.Infof("Parallel request completed for %d models", len(targets))
+
+	return &models.OpenAIParallelResponse{
+		Object:  "chat.completion.parallel",
+		Created: time.Now().Unix(),
+		Results: results,
+	}, nil
+}
+
+// cloneOpenAIRequest 复制OpenAI请求，并复制会被修正逻辑原地修改的指针字段
+func cloneOpenAIRequest(req *models.OpenAIRequest) *models.OpenAIRequest {
+	clone := *req
+	if req.Temperature != nil {
+		temperature := *req.Temperature
+		clone.Temperature = &temperature
+	}
+	if req.TopP != nil {
+		topP := *req.TopP
+		clone.TopP = &topP
+	}
+	return &clone
 }
 
 // Health 健康检查
@@ -538,59 +1010,83 @@ func (c *GeminiClient) Health(ctx context.Context) error {
 	return err
 }
 
-// setRandomProxy 设置随机代理（内部方法）
-func (c *GeminiClient) setRandomProxy() error {
+// proxies 返回代理列表和当前代理的快照，代理列表只会被整体替换，返回的切片可以直接读取
+func (c *GeminiClient) proxies() ([]string, string) {
+	c.proxyMu.RLock()
+	defer c.proxyMu.RUnlock()
+	return c.proxyURLs, c.currentProxy
+}
+
+// proxyCount 返回代理数量
+func (c *GeminiClient) proxyCount() int {
+	proxyURLs, _ := c.proxies()
+	return len(proxyURLs)
+}
+
+// selectProxy 从未被剔除的代理中随机选择一个，有其他可用代理时不选择exclude；调用方需持有proxyMu写锁
+func (c *GeminiClient) selectProxy(exclude string) string {
 	if len(c.proxyURLs) == 0 {
-		c.client.Transport = nil
-		return nil
+		return ""
 	}
-
-	// 随机选择一个代理
-	proxyURL := c.proxyURLs[c.randSource.Intn(len(c.proxyURLs))]
-	
-	proxy, err := url.Parse(proxyURL)
-	if err != nil {
-		c.logger.Warnf("Invalid proxy URL: %s, error: %v", proxyURL, err)
-		return fmt.Errorf("invalid proxy URL: %w", err)
+	candidates := c.proxyHealth.available(c.proxyURLs)
+	if len(candidates) > 1 && exclude != "" {
+		candidates = slices.DeleteFunc(slices.Clone(candidates), func(proxyURL string) bool { return proxyURL == exclude })
 	}
+	return candidates[c.randSource.Intn(len(candidates))]
+}
 
-	transport := &http.Transport{
-		Proxy: http.ProxyURL(proxy),
+// attemptProxy 返回一次上游请求尝试使用的代理：首次尝试使用当前代理，重试时换用其他代理
+func (c *GeminiClient) attemptProxy(attempt int, previous string) string {
+	c.proxyMu.Lock()
+	defer c.proxyMu.Unlock()
+	if attempt == 0 || len(c.proxyURLs) <= 1 {
+		return c.currentProxy
 	}
+	return c.selectProxy(previous)
+}
 
-	c.client.Transport = transport
-	c.logger.Debugf("Random proxy set to: %s", proxyURL)
+// setRandomProxy 设置随机代理（内部方法），只切换默认代理，已有的连接保持可用
+func (c *GeminiClient) setRandomProxy() error {
+	c.proxyMu.Lock()
+	c.currentProxy = c.selectProxy(c.currentProxy)
+	current := c.currentProxy
+	c.proxyMu.Unlock()
+	if current != "" {
+		c.logger.Debugf("Random proxy set to: %s", redactProxyURL(current))
+	}
 	return nil
 }
 
+// setProxies 替换代理列表和当前代理，并释放已移除的代理的连接
+func (c *GeminiClient) setProxies(proxyURLs []string, current string) {
+	c.proxyMu.Lock()
+	c.proxyURLs = proxyURLs
+	c.currentProxy = current
+	c.proxyMu.Unlock()
+	c.pruneTransports(proxyURLs)
+}
+
 // SetProxy 设置单个代理
 func (c *GeminiClient) SetProxy(proxyURL string) error {
 	if proxyURL == "" {
-		c.client.Transport = nil
-		c.proxyURLs = nil
+		c.setProxies(nil, "")
 		return nil
 	}
 
-	proxy, err := url.Parse(proxyURL)
+	proxy, err := parseProxyURL(proxyURL)
 	if err != nil {
-		return fmt.Errorf("invalid proxy URL: %w", err)
-	}
-
-	transport := &http.Transport{
-		Proxy: http.ProxyURL(proxy),
+		return err
 	}
 
-	c.client.Transport = transport
-	c.proxyURLs = []string{proxyURL} // 更新为单个代理
-	c.logger.Infof("Proxy set to: %s", proxyURL)
+	c.setProxies([]string{proxyURL}, proxyURL) // 更新为单个代理
+	c.logger.Infof("Proxy set to: %s", proxy.Redacted())
 	return nil
 }
 
 // SetProxyList 设置代理列表，启用自动轮换
 func (c *GeminiClient) SetProxyList(proxyURLs []string) error {
 	if len(proxyURLs) == 0 {
-		c.client.Transport = nil
-		c.proxyURLs = nil
+		c.setProxies(nil, "")
 		c.logger.Info("Proxy list cleared")
 		return nil
 	}
@@ -598,8 +1094,8 @@ func (c *GeminiClient) SetProxyList(proxyURLs []string) error {
 	// 验证所有代理URL
 	validProxies := make([]string, 0, len(proxyURLs))
 	for _, proxyURL := range proxyURLs {
-		if _, err := url.Parse(proxyURL); err != nil {
-			c.logger.Warnf("Invalid proxy URL: %s, skipping", proxyURL)
+		if _, err := parseProxyURL(proxyURL); err != nil {
+			c.logger.Warnf("%v, skipping", err)
 			continue
 		}
 		validProxies = append(validProxies, proxyURL)
@@ -609,16 +1105,19 @@ func (c *GeminiClient) SetProxyList(proxyURLs []string) error {
 		return fmt.Errorf("no valid proxy URLs provided")
 	}
 
+	// 替换列表的同时选出随机代理，并发请求不会短暂地直连
+	c.proxyMu.Lock()
 	c.proxyURLs = validProxies
+	c.currentProxy = c.selectProxy("")
+	c.proxyMu.Unlock()
+	c.pruneTransports(validProxies)
 	c.logger.Infof("Proxy list set with %d proxies", len(validProxies))
-	
-	// 立即设置一个随机代理
-	return c.setRandomProxy()
+	return nil
 }
 
 // RotateProxy 手动轮换到下一个随机代理
 func (c *GeminiClient) RotateProxy() error {
-	if len(c.proxyURLs) <= 1 {
+	if proxyURLs, _ := c.proxies(); len(proxyURLs) <= 1 {
 		c.logger.Debug("No proxy rotation needed (single or no proxy)")
 		return nil
 	}
@@ -629,28 +1128,108 @@ func (c *GeminiClient) RotateProxy() error {
 
 // UseCodeAssist 启用Code Assist模式
 func (c *GeminiClient) UseCodeAssist() {
+	c.modeMu.Lock()
 	c.config.APIMode = config.CodeAssist
+	c.modeMu.Unlock()
 	c.logger.Info("Code Assist mode enabled")
 }
 
 // UseVertexAI 启用Vertex AI模式
 func (c *GeminiClient) UseVertexAI(location string) {
+	c.modeMu.Lock()
 	c.config.APIMode = config.VertexAI
 	if location != "" {
 		c.config.Location = location
 	}
-	c.logger.Infof("Vertex AI mode enabled with location: %s", c.config.Location)
+	location = c.config.Location
+	c.modeMu.Unlock()
+	c.logger.Infof("Vertex AI mode enabled with location: %s", location)
+}
+
+// UseAIStudio 启用AI Studio模式
+func (c *GeminiClient) UseAIStudio() {
+	c.modeMu.Lock()
+	c.config.APIMode = config.AIStudio
+	c.modeMu.Unlock()
+	c.logger.Info("AI Studio mode enabled")
+}
+
+// SetLocation 运行时更新Vertex AI location，对之后的请求生效
+func (c *GeminiClient) SetLocation(location string) {
+	c.modeMu.Lock()
+	defer c.modeMu.Unlock()
+	c.config.Location = location
+}
+
+// SetProjectID 运行时更新项目ID（如授权后自动发现的项目），对之后的请求生效
+func (c *GeminiClient) SetProjectID(projectID string) {
+	c.modeMu.Lock()
+	defer c.modeMu.Unlock()
+	c.config.ProjectID = projectID
+}
+
+// SetVertexEndpoint 运行时设置模型对应的Vertex AI专用端点，endpoint为空时取消
+func (c *GeminiClient) SetVertexEndpoint(model, endpoint string) {
+	c.modeMu.Lock()
+	defer c.modeMu.Unlock()
+	if endpoint == "" {
+		delete(c.config.VertexEndpoints, model)
+		return
+	}
+	if c.config.VertexEndpoints == nil {
+		c.config.VertexEndpoints = make(map[string]string)
+	}
+	c.config.VertexEndpoints[model] = endpoint
+}
+
+// projectID 返回当前的项目ID
+func (c *GeminiClient) projectID() string {
+	c.modeMu.RLock()
+	defer c.modeMu.RUnlock()
+	return c.config.ProjectID
+}
+
+// vertexEndpoint 返回模型配置的Vertex AI专用端点，未配置时为空
+func (c *GeminiClient) vertexEndpoint(model string) string {
+	c.modeMu.RLock()
+	defer c.modeMu.RUnlock()
+	return c.config.VertexEndpoints[model]
+}
+
+// apiMode 返回当前的API模式，可与 UseCodeAssist/UseVertexAI 并发调用
+func (c *GeminiClient) apiMode() config.APIMode {
+	c.modeMu.RLock()
+	defer c.modeMu.RUnlock()
+	return c.config.APIMode
+}
+
+// location 返回当前的Vertex AI location
+func (c *GeminiClient) location() string {
+	c.modeMu.RLock()
+	defer c.modeMu.RUnlock()
+	return c.config.Location
+}
+
+// SetSystemPrompt 运行时更新系统提示词文件和模式，对之后的请求生效
+func (c *GeminiClient) SetSystemPrompt(filePath, mode string) {
+	c.promptMu.Lock()
+	defer c.promptMu.Unlock()
+	c.config.SystemPromptFile = filePath
+	c.config.SystemPromptMode = mode
 }
 
 // 从文件加载并应用系统提示
 func (c *GeminiClient) _applySystemPromptFromFile(req *models.GeminiRequest) error {
-	if c.config.SystemPromptFile == "" {
+	c.promptMu.RLock()
+	filePath, mode := c.config.SystemPromptFile, strings.ToLower(c.config.SystemPromptMode)
+	c.promptMu.RUnlock()
+	if filePath == "" {
 		return nil
 	}
 
-	content, err := ioutil.ReadFile(c.config.SystemPromptFile)
+	content, err := ioutil.ReadFile(filePath)
 	if err != nil {
-		return fmt.Errorf("failed to read system prompt file %s: %w", c.config.SystemPromptFile, err)
+		return fmt.Errorf("failed to read system prompt file %s: %w", filePath, err)
 	}
 
 	filePromptText := string(content)
@@ -664,13 +1243,12 @@ func (c *GeminiClient) _applySystemPromptFromFile(req *models.GeminiRequest) err
 		req.SystemInstruction = &models.GeminiSystemInstruction{Parts: []models.GeminiPart{}}
 	}
 
-	mode := strings.ToLower(c.config.SystemPromptMode)
 	if mode == "append" {
 		req.SystemInstruction.Parts = append(req.SystemInstruction.Parts, newPart)
 	} else { // 默认为 "overwrite"
 		req.SystemInstruction.Parts = []models.GeminiPart{newPart}
 	}
 
-	c.logger.Infof("Applied system prompt from %s (mode: %s)", c.config.SystemPromptFile, mode)
+	c.logger.Infof("Applied system prompt from %s (mode: %s)", filePath, mode)
 	return nil
 }
\ No newline at end of file
@@ -3,9 +3,15 @@ package client
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -59,7 +65,7 @@ func TestGeminiClient_BuildAPIURL(t *testing.T) {
 	
 	// Test AI Studio mode
 	cfg.APIMode = config.AIStudio
-	url := client.buildAPIURL("gemini-pro", "generateContent")
+	url, _ := client.buildAPIURL(context.Background(), "gemini-pro", "generateContent")
 	expected := "https://generativelanguage.googleapis.com/v1beta/models/gemini-pro:generateContent"
 	assert.Equal(t, expected, url)
 	
@@ -72,13 +78,13 @@ func TestGeminiClient_BuildAPIURL(t *testing.T) {
 	googleAuth := auth.NewGoogleAuth(authConfig, logger)
 	client.auth = googleAuth
 	
-	url = client.buildAPIURL("gemini-pro", "generateContent")
+	url, _ = client.buildAPIURL(context.Background(), "gemini-pro", "generateContent")
 	expected = "https://us-central1-aiplatform.googleapis.com/v1/projects/test-project/locations/us-central1/publishers/google/models/gemini-pro:generateContent"
 	assert.Equal(t, expected, url)
 	
 	// Test Code Assist mode
 	cfg.APIMode = config.CodeAssist
-	url = client.buildAPIURL("gemini-pro", "generateContent")
+	url, _ = client.buildAPIURL(context.Background(), "gemini-pro", "generateContent")
 	expected = "https://cloudcode-pa.googleapis.com/v1internal:generateContent"
 	assert.Equal(t, expected, url)
 }
@@ -155,7 +161,7 @@ func TestGeminiClient_SetProxy(t *testing.T) {
 	err = client.SetProxy("")
 	assert.NoError(t, err)
 	assert.Nil(t, client.proxyURLs)
-	assert.Nil(t, client.client.Transport)
+	assert.Empty(t, client.currentProxy)
 	
 	// Test invalid proxy URL
 	err = client.SetProxy("not-a-valid-url")
@@ -193,8 +199,8 @@ func TestGeminiClient_SetProxyList(t *testing.T) {
 	
 	err = client.SetProxyList(mixedList)
 	assert.NoError(t, err)
-	// Implementation might not filter invalid URLs at set time
-	assert.Equal(t, mixedList, client.proxyURLs)
+	// Invalid URLs are skipped at set time
+	assert.Equal(t, []string{mixedList[0], mixedList[2]}, client.proxyURLs)
 	
 	// Test with all invalid URLs
 	err = client.SetProxyList([]string{"invalid1", "invalid2"})
@@ -354,6 +360,28 @@ func TestGeminiClient_ApplySystemPromptFromFile(t *testing.T) {
 	assert.Contains(t, err.Error(), "failed to read system prompt file")
 }
 
+func TestGeminiClient_SetSystemPrompt(t *testing.T) {
+	dir := t.TempDir()
+	first := filepath.Join(dir, "first.txt")
+	second := filepath.Join(dir, "second.txt")
+	require.NoError(t, os.WriteFile(first, []byte("first prompt"), 0600))
+	require.NoError(t, os.WriteFile(second, []byte("second prompt"), 0600))
+
+	cfg := config.DefaultConfig()
+	cfg.SystemPromptFile = first
+	client := NewGeminiClient(cfg, nil, nil)
+
+	req := &models.GeminiRequest{}
+	require.NoError(t, client._applySystemPromptFromFile(req))
+	assert.Equal(t, "first prompt", req.SystemInstruction.Parts[0].Text)
+
+	// 运行时切换文件和模式
+	client.SetSystemPrompt(second, "append")
+	require.NoError(t, client._applySystemPromptFromFile(req))
+	require.Len(t, req.SystemInstruction.Parts, 2)
+	assert.Equal(t, "second prompt", req.SystemInstruction.Parts[1].Text)
+}
+
 func TestConstants(t *testing.T) {
 	assert.Equal(t, "https://generativelanguage.googleapis.com", DefaultAPIEndpoint)
 	assert.Equal(t, "v1beta", DefaultAPIVersion)
@@ -362,4 +390,393 @@ func TestConstants(t *testing.T) {
 	assert.Equal(t, "https://cloudcode-pa.googleapis.com", CodeAssistEndpoint)
 	assert.Equal(t, "v1internal", CodeAssistVersion)
 	assert.Equal(t, "gemini-go-proxy/1.0.0", DefaultUserAgent)
-}
\ No newline at end of file
+}
+func TestGeminiClient_SendOpenAIParallelRequest_NoModels(t *testing.T) {
+	cfg := config.DefaultConfig()
+	client := NewGeminiClient(cfg, nil, nil)
+
+	req := &models.OpenAIRequest{
+		Messages: []models.OpenAIMessage{{Role: "user", Content: "Hello"}},
+	}
+
+	_, err := client.SendOpenAIParallelRequest(context.Background(), req, []string{" ", ""})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "no models specified")
+
+	_, err = client.SendOpenAIParallelRequest(context.Background(), nil, nil)
+	assert.Error(t, err)
+}
+
+func TestGeminiClient_SendOpenAIParallelRequest_MaxModels(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.APIMode = config.AIStudio
+	cfg.ParallelMaxModels = 2
+	client := NewGeminiClient(cfg, nil, nil)
+	var mu sync.Mutex
+	calls := 0
+	client.client.Transport = roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		mu.Lock()
+		calls++
+		mu.Unlock()
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(`{"candidates":[{"content":{"parts":[{"text":"hi"}]}}]}`))}, nil
+	})
+
+	req := &models.OpenAIRequest{
+		Messages: []models.OpenAIMessage{{Role: "user", Content: "Hello"}},
+	}
+
+	// 超过上限时整个请求被拒绝，不发起任何上游请求
+	_, err := client.SendOpenAIParallelRequest(context.Background(), req, []string{"m1", "m2", "m3"})
+	assert.ErrorIs(t, err, ErrTooManyParallelModels)
+	assert.Zero(t, calls)
+
+	// 重复的模型只计一次
+	resp, err := client.SendOpenAIParallelRequest(context.Background(), req, []string{"m1", "m2", "m1", " m2 "})
+	require.NoError(t, err)
+	assert.Len(t, resp.Results, 2)
+	assert.Equal(t, 2, calls)
+}
+
+func TestGeminiClient_BuildAPIURL_ModelPaths(t *testing.T) {
+	cfg := config.DefaultConfig()
+	logger := logrus.New()
+	client := NewGeminiClient(cfg, auth.NewGoogleAuth(&models.GoogleAuthConfig{ProjectID: "test-project"}, logger), logger)
+
+	// AI Studio调优模型
+	cfg.APIMode = config.AIStudio
+	assert.Equal(t, "https://generativelanguage.googleapis.com/v1beta/tunedModels/my-model:generateContent",
+		mustBuildAPIURL(t, client, context.Background(), "tunedModels/my-model", "generateContent"))
+	assert.Equal(t, "https://generativelanguage.googleapis.com/v1beta/models/gemini-pro:generateContent",
+		mustBuildAPIURL(t, client, context.Background(), "models/gemini-pro", "generateContent"))
+
+	// Vertex AI完整资源名，location取自资源名
+	cfg.APIMode = config.VertexAI
+	cfg.Location = "us-central1"
+	assert.Equal(t, "https://europe-west4-aiplatform.googleapis.com/v1/projects/p1/locations/europe-west4/models/123:generateContent",
+		mustBuildAPIURL(t, client, context.Background(), "projects/p1/locations/europe-west4/models/123", "generateContent"))
+	assert.Equal(t, "https://us-central1-aiplatform.googleapis.com/v1/projects/test-project/locations/us-central1/publishers/anthropic/models/claude:generateContent",
+		mustBuildAPIURL(t, client, context.Background(), "publishers/anthropic/models/claude", "generateContent"))
+	assert.Equal(t, "https://us-central1-aiplatform.googleapis.com/v1/projects/test-project/locations/us-central1/publishers/google/models/gemini-pro:generateContent",
+		mustBuildAPIURL(t, client, context.Background(), "models/gemini-pro", "generateContent"))
+
+	assert.Equal(t, "gemini-pro", baseModelName("tunedModels/gemini-pro"))
+	assert.Equal(t, "gemini-pro", baseModelName("gemini-pro"))
+}
+
+// mustBuildAPIURL 构建API URL，出错时测试失败
+func mustBuildAPIURL(t *testing.T, client *GeminiClient, ctx context.Context, modelID, action string) string {
+	t.Helper()
+	apiURL, err := client.buildAPIURL(ctx, modelID, action)
+	require.NoError(t, err)
+	return apiURL
+}
+
+func TestGeminiClient_BuildAPIURL_InvalidLocation(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.APIMode = config.VertexAI
+	cfg.Location = "us-central1"
+	logger := logrus.New()
+	client := NewGeminiClient(cfg, auth.NewGoogleAuth(&models.GoogleAuthConfig{ProjectID: "test-project"}, logger), logger)
+
+	// 资源名中的location来自客户端，会成为上游主机名的一部分
+	for _, modelID := range []string{
+		"projects/p/locations/evil.example#/models/x",
+		"projects/p/locations/evil.example/models/x",
+		"projects/p/locations/a@b/models/x",
+	} {
+		_, err := client.buildAPIURL(context.Background(), modelID, "generateContent")
+		assert.ErrorIs(t, err, ErrInvalidLocation, modelID)
+	}
+
+	var requested bool
+	client.client.Transport = roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		requested = true
+		return nil, errors.New("unexpected request")
+	})
+	req := &models.GeminiRequest{Contents: []models.GeminiContent{{Role: "user", Parts: []models.GeminiPart{{Text: "hi"}}}}}
+	_, err := client.SendRequest(context.Background(), "projects/p/locations/evil.example#/models/x", req)
+	assert.ErrorIs(t, err, ErrInvalidLocation)
+	_, err = client.SendPredictRequest(context.Background(), "projects/p/locations/evil.example#/models/x", json.RawMessage(`{}`))
+	assert.ErrorIs(t, err, ErrInvalidLocation)
+	assert.False(t, requested)
+}
+
+func TestGeminiClient_BuildAPIURL_VertexEndpoints(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.APIMode = config.VertexAI
+	cfg.Location = "us-central1"
+	cfg.VertexEndpoints = map[string]string{
+		"my-model":    "1234567890",
+		"other-model": "projects/p2/locations/asia-east1/endpoints/987",
+	}
+	logger := logrus.New()
+	client := NewGeminiClient(cfg, auth.NewGoogleAuth(&models.GoogleAuthConfig{ProjectID: "test-project"}, logger), logger)
+
+	assert.Equal(t, "https://us-central1-aiplatform.googleapis.com/v1/projects/test-project/locations/us-central1/endpoints/1234567890:generateContent",
+		mustBuildAPIURL(t, client, context.Background(), "my-model", "generateContent"))
+	assert.Equal(t, "https://asia-east1-aiplatform.googleapis.com/v1/projects/p2/locations/asia-east1/endpoints/987:streamGenerateContent",
+		mustBuildAPIURL(t, client, context.Background(), "other-model", "streamGenerateContent"))
+}
+
+func TestGeminiClient_SetVertexEndpointConcurrent(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.APIMode = config.VertexAI
+	cfg.Location = "us-central1"
+	logger := logrus.New()
+	client := NewGeminiClient(cfg, auth.NewGoogleAuth(&models.GoogleAuthConfig{ProjectID: "test-project"}, logger), logger)
+
+	// 运行时设置端点与请求并发，-race 下不应报告数据竞争
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			client.SetVertexEndpoint("model-"+strconv.Itoa(i), "1234567890")
+		}(i)
+		go func() {
+			defer wg.Done()
+			_, _, err := client.vertexModelPath(context.Background(), "model-0")
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, "https://us-central1-aiplatform.googleapis.com/v1/projects/test-project/locations/us-central1/endpoints/1234567890:generateContent",
+		mustBuildAPIURL(t, client, context.Background(), "model-0", "generateContent"))
+	client.SetVertexEndpoint("model-0", "")
+	assert.Equal(t, "https://us-central1-aiplatform.googleapis.com/v1/projects/test-project/locations/us-central1/publishers/google/models/model-0:generateContent",
+		mustBuildAPIURL(t, client, context.Background(), "model-0", "generateContent"))
+}
+
+func TestGeminiClient_CreateRequest_VertexHeaders(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.APIMode = config.VertexAI
+	cfg.VertexRequestType = "dedicated"
+	cfg.QuotaProjectID = "billing-project"
+	client := NewGeminiClient(cfg, nil, logrus.New())
+
+	req, err := client.createRequest(context.Background(), "POST", "https://example.com", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "dedicated", req.Header.Get(VertexRequestTypeHeader))
+	assert.Equal(t, "billing-project", req.Header.Get(QuotaProjectHeader))
+
+	// 单次请求覆盖
+	ctx := WithVertexRequestType(context.Background(), "Shared")
+	req, err = client.createRequest(ctx, "POST", "https://example.com", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "shared", req.Header.Get(VertexRequestTypeHeader))
+
+	// 无效值被忽略
+	ctx = WithVertexRequestType(context.Background(), "bogus")
+	req, err = client.createRequest(ctx, "POST", "https://example.com", nil)
+	require.NoError(t, err)
+	assert.Empty(t, req.Header.Get(VertexRequestTypeHeader))
+
+	// 非Vertex模式不设置
+	client.config.APIMode = config.AIStudio
+	req, err = client.createRequest(context.Background(), "POST", "https://example.com", nil)
+	require.NoError(t, err)
+	assert.Empty(t, req.Header.Get(QuotaProjectHeader))
+}
+
+func TestGeminiClient_ForwardVertexRequest_InvalidPath(t *testing.T) {
+	client := NewGeminiClient(config.DefaultConfig(), nil, logrus.New())
+
+	for _, path := range []string{"", "tuningJobs", "projects/p1/tuningJobs/1", "models/gemini-pro/locations/x/y"} {
+		_, err := client.ForwardVertexRequest(context.Background(), "GET", path, "", nil)
+		assert.Error(t, err, path)
+	}
+}
+
+func TestGeminiClient_ForwardVertexRequest_InvalidLocation(t *testing.T) {
+	client := NewGeminiClient(config.DefaultConfig(), nil, logrus.New())
+	var requested []string
+	client.client.Transport = roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		requested = append(requested, req.URL.String())
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader("{}"))}, nil
+	})
+
+	// location会成为上游主机名的一部分，不能把认证信息发往其他主机
+	for _, location := range []string{"evil.example#", "evil.example/x", "US-CENTRAL1", "a@b", ""} {
+		_, err := client.ForwardVertexRequest(context.Background(), "POST", "projects/p/locations/"+location+"/tuningJobs", "", nil)
+		assert.ErrorIs(t, err, ErrInvalidLocation, location)
+	}
+	assert.Empty(t, requested)
+
+	resp, err := client.ForwardVertexRequest(context.Background(), "GET", "projects/p/locations/europe-west4/tuningJobs/1", "a=b", nil)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, []string{"https://europe-west4-aiplatform.googleapis.com/v1/projects/p/locations/europe-west4/tuningJobs/1?a=b"}, requested)
+}
+
+func TestGeminiClient_RunStartupChecks(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.ProjectID = ""
+	client := NewGeminiClient(cfg, nil, logrus.New())
+
+	results, err := client.RunStartupChecks(context.Background(), nil)
+	assert.NoError(t, err)
+	assert.Empty(t, results)
+
+	results, err = client.RunStartupChecks(context.Background(), &config.StartupChecks{Token: true, Project: true})
+	require.Error(t, err)
+	require.Len(t, results, 2)
+	assert.Equal(t, "token", results[0].Name)
+	assert.Error(t, results[0].Err)
+	assert.Equal(t, "project", results[1].Name)
+	assert.Contains(t, results[1].Err.Error(), "project_id is not configured")
+}
+
+// roundTripFunc 测试用的RoundTripper
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestGeminiClient_SendRequestRaw(t *testing.T) {
+	upstream := `{"response":{"candidates":[{"content":{"parts":[{"text":"hi"}]},"groundingMetadata":{"webSearchQueries":["q"]}}]},"traceId":"abc"}`
+
+	cfg := config.DefaultConfig()
+	client := NewGeminiClient(cfg, nil, logrus.New())
+	client.client.Transport = roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": []string{"application/json"}},
+			Body:       io.NopCloser(strings.NewReader(upstream)),
+		}, nil
+	})
+
+	req := &models.GeminiRequest{Contents: []models.GeminiContent{{Role: "user", Parts: []models.GeminiPart{{Text: "hello"}}}}}
+	raw, err := client.SendRequestRaw(context.Background(), "gemini-2.5-flash", req)
+	require.NoError(t, err)
+	assert.JSONEq(t, upstream, string(raw))
+
+	// 解析后的响应保持不变
+	resp, err := client.SendRequest(context.Background(), "gemini-2.5-flash", req)
+	require.NoError(t, err)
+	assert.Equal(t, "hi", resp.Candidates[0].Content.Parts[0].Text)
+}
+
+func TestGeminiClient_ConcurrentUse(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.APIMode = config.AIStudio
+	googleAuth := auth.NewGoogleAuth(&models.GoogleAuthConfig{ProjectID: "test-project"}, logrus.New())
+	client := NewGeminiClient(cfg, googleAuth, logrus.New())
+	client.SetTransport(roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       io.NopCloser(strings.NewReader(`{"candidates":[{"content":{"parts":[{"text":"hi"}]}}]}`)),
+		}, nil
+	}))
+	proxies := []string{"http://proxy1.example.com:8080", "http://proxy2.example.com:8080", "http://proxy3.example.com:8080"}
+	require.NoError(t, client.SetProxyList(proxies))
+
+	// 请求与代理切换、模式切换并发进行，配合 -race 检查数据竞争
+	req := &models.GeminiRequest{Contents: []models.GeminiContent{{Role: "user", Parts: []models.GeminiPart{{Text: "hello"}}}}}
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				_, err := client.SendRequestRaw(context.Background(), "gemini-2.5-flash", req)
+				assert.NoError(t, err)
+			}
+		}()
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				switch (i + j) % 4 {
+				case 0:
+					assert.NoError(t, client.RotateProxy())
+				case 1:
+					assert.NoError(t, client.SetProxyList(proxies[:2+j%2]))
+				case 2:
+					client.UseVertexAI("us-central1")
+				default:
+					client.ProxyHealth()
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestGeminiClient_SetTransport(t *testing.T) {
+	var calls int
+	custom := roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		calls++
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(`{}`))}, nil
+	})
+
+	// 通过配置引用已注册的传输层
+	RegisterTransport("test-recorder", custom)
+	defer RegisterTransport("test-recorder", nil)
+
+	cfg := config.DefaultConfig()
+	cfg.Transport = "test-recorder"
+	client := NewGeminiClient(cfg, nil, logrus.New())
+
+	_, err := client.SendRequestRaw(context.Background(), "gemini-2.5-flash", &models.GeminiRequest{})
+	require.NoError(t, err)
+	assert.Equal(t, 1, calls)
+
+	// 自定义传输层不会被代理设置覆盖
+	require.NoError(t, client.SetProxy("http://proxy.example.com:8080"))
+	_, isStd := currentTransport(t, client).(*http.Transport)
+	assert.False(t, isStd)
+
+	// 标准Transport会复制后注入代理
+	base := &http.Transport{MaxIdleConns: 7}
+	client.SetTransport(base)
+	transport, ok := currentTransport(t, client).(*http.Transport)
+	require.True(t, ok)
+	assert.NotSame(t, base, transport)
+	assert.Equal(t, 7, transport.MaxIdleConns)
+	assert.NotNil(t, transport.Proxy)
+
+	// 恢复默认
+	require.NoError(t, client.SetProxy(""))
+	client.SetTransport(nil)
+	assert.Same(t, http.DefaultTransport, currentTransport(t, client))
+}
+
+// currentTransport 返回客户端当前代理对应的传输层
+func currentTransport(t *testing.T, c *GeminiClient) http.RoundTripper {
+	transport, err := c.pooledTransport(c.currentProxy)
+	require.NoError(t, err)
+	return transport
+}
+
+func TestGeminiClient_TransportPerProxy(t *testing.T) {
+	client := NewGeminiClient(config.DefaultConfig(), nil, logrus.New())
+	proxies := []string{"http://proxy1.example.com:8080", "http://proxy2.example.com:8080"}
+	require.NoError(t, client.SetProxyList(proxies))
+
+	// 同一代理复用传输层，轮换代理不重建传输层
+	first, err := client.pooledTransport(proxies[0])
+	require.NoError(t, err)
+	require.NoError(t, client.RotateProxy())
+	again, err := client.pooledTransport(proxies[0])
+	require.NoError(t, err)
+	assert.Same(t, first, again)
+	second, err := client.pooledTransport(proxies[1])
+	require.NoError(t, err)
+	assert.NotSame(t, first, second)
+
+	// 请求上下文中指定的代理优先于当前代理
+	ctx := withProxy(context.Background(), proxies[1])
+	assert.Equal(t, proxies[1], client.requestProxy(ctx))
+	assert.Equal(t, client.currentProxy, client.requestProxy(context.Background()))
+
+	// 重试时换用其他代理
+	assert.Equal(t, proxies[1], client.attemptProxy(1, proxies[0]))
+
+	// 移出代理列表的代理的传输层被释放
+	require.NoError(t, client.SetProxyList(proxies[1:]))
+	client.transports.mu.Lock()
+	_, kept := client.transports.transports[proxies[0]]
+	client.transports.mu.Unlock()
+	assert.False(t, kept)
+}
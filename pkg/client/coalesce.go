@@ -0,0 +1,102 @@
+package client
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"sync"
+	"sync/atomic"
+
+	"github.com/ba0gu0/gemini-go-proxy/pkg/models"
+)
+
+// coalescer 合并并发的相同非流式请求，只向上游发送一次
+type coalescer struct {
+	mu        sync.Mutex
+	calls     map[string]*coalescedCall
+	coalesced atomic.Int64 // 被合并（未单独请求上游）的请求数
+}
+
+// coalescedCall 进行中的上游请求
+type coalescedCall struct {
+	done chan struct{}
+	resp *models.GeminiResponse
+	err  error
+}
+
+// coalesceKey 根据模型和请求内容计算合并键
+func coalesceKey(modelID string, req *models.GeminiRequest) (string, bool) {
+	data, err := json.Marshal(req)
+	if err != nil {
+		return "", false
+	}
+	hash := sha256.New()
+	hash.Write([]byte(modelID))
+	hash.Write([]byte{0})
+	hash.Write(data)
+	return hex.EncodeToString(hash.Sum(nil)), true
+}
+
+// do 执行或等待相同键的请求；等待方获得响应的独立副本
+func (g *coalescer) do(ctx context.Context, key string, fn func() (*models.GeminiResponse, error)) (*models.GeminiResponse, error) {
+	g.mu.Lock()
+	if g.calls == nil {
+		g.calls = make(map[string]*coalescedCall)
+	}
+	if call, ok := g.calls[key]; ok {
+		g.mu.Unlock()
+		return g.wait(ctx, call, fn)
+	}
+
+	call := &coalescedCall{done: make(chan struct{})}
+	g.calls[key] = call
+	g.mu.Unlock()
+
+	call.resp, call.err = fn()
+
+	g.mu.Lock()
+	delete(g.calls, key)
+	g.mu.Unlock()
+	close(call.done)
+
+	return call.resp, call.err
+}
+
+// wait 等待进行中的请求完成；发起方被取消时由等待方自行请求
+func (g *coalescer) wait(ctx context.Context, call *coalescedCall, fn func() (*models.GeminiResponse, error)) (*models.GeminiResponse, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-call.done:
+	}
+
+	if call.err != nil {
+		if errors.Is(call.err, context.Canceled) || errors.Is(call.err, context.DeadlineExceeded) {
+			return fn()
+		}
+		return nil, call.err
+	}
+
+	g.coalesced.Add(1)
+	return cloneGeminiResponse(call.resp)
+}
+
+// cloneGeminiResponse 深拷贝响应，避免多个调用方共享可变数据
+func cloneGeminiResponse(resp *models.GeminiResponse) (*models.GeminiResponse, error) {
+	data, err := json.Marshal(resp)
+	if err != nil {
+		return nil, err
+	}
+	var clone models.GeminiResponse
+	if err := json.Unmarshal(data, &clone); err != nil {
+		return nil, err
+	}
+	return &clone, nil
+}
+
+// CoalescedRequests 返回因合并而未单独请求上游的请求数
+func (c *GeminiClient) CoalescedRequests() int64 {
+	return c.coalescer.coalesced.Load()
+}
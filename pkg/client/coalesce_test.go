@@ -0,0 +1,74 @@
+package client
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ba0gu0/gemini-go-proxy/pkg/config"
+	"github.com/ba0gu0/gemini-go-proxy/pkg/models"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGeminiClient_CoalesceRequests(t *testing.T) {
+	var calls atomic.Int32
+	started := make(chan struct{})
+	release := make(chan struct{})
+
+	cfg := config.DefaultConfig()
+	cfg.CoalesceRequests = true
+	client := NewGeminiClient(cfg, nil, logrus.New())
+	client.client.Transport = roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		if calls.Add(1) == 1 {
+			close(started)
+		}
+		<-release
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       io.NopCloser(strings.NewReader(`{"response":{"candidates":[{"content":{"parts":[{"text":"shared"}]}}]}}`)),
+		}, nil
+	})
+
+	newReq := func() *models.GeminiRequest {
+		return &models.GeminiRequest{Contents: []models.GeminiContent{{Role: "user", Parts: []models.GeminiPart{{Text: "same prompt"}}}}}
+	}
+
+	var wg sync.WaitGroup
+	results := make([]*models.GeminiResponse, 3)
+	send := func(i int) {
+		defer wg.Done()
+		resp, err := client.SendRequest(context.Background(), "gemini-2.5-flash", newReq())
+		require.NoError(t, err)
+		results[i] = resp
+	}
+
+	wg.Add(3)
+	go send(0)
+	<-started
+	go send(1)
+	go send(2)
+	// 等待后两个请求加入进行中的调用
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	assert.Equal(t, int32(1), calls.Load())
+	assert.Equal(t, int64(2), client.CoalescedRequests())
+	for _, resp := range results {
+		assert.Equal(t, "shared", resp.Candidates[0].Content.Parts[0].Text)
+	}
+	// 每个调用方获得独立的响应副本
+	assert.NotSame(t, results[1], results[2])
+
+	// 请求完成后不再合并
+	_, err := client.SendRequest(context.Background(), "gemini-2.5-flash", newReq())
+	require.NoError(t, err)
+	assert.Equal(t, int32(2), calls.Load())
+}
@@ -0,0 +1,254 @@
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/ba0gu0/gemini-go-proxy/pkg/models"
+)
+
+// continuationPrompt 续写提示词
+const continuationPrompt = "Continue exactly where your previous reply stopped. Do not repeat any text that was already written."
+
+// isMaxTokensFinish 判断结束原因是否为达到最大输出token
+func isMaxTokensFinish(finishReason string) bool {
+	return strings.EqualFold(finishReason, "MAX_TOKENS")
+}
+
+// multipleCandidates 请求是否要求多个候选，多个候选无法用同一个续写请求分别续写
+func multipleCandidates(req *models.GeminiRequest) bool {
+	return req.GenerationConfig != nil && req.GenerationConfig.CandidateCount != nil && *req.GenerationConfig.CandidateCount > 1
+}
+
+// buildContinuationRequest 在原始对话后追加已生成的内容和续写指令
+func buildContinuationRequest(original *models.GeminiRequest, generated string) *models.GeminiRequest {
+	followUp := cloneGeminiRequest(original)
+	followUp.Contents = append(followUp.Contents,
+		models.GeminiContent{Role: "model", Parts: []models.GeminiPart{{Text: generated}}},
+		models.GeminiContent{Role: "user", Parts: []models.GeminiPart{{Text: continuationPrompt}}},
+	)
+	return followUp
+}
+
+// continueTruncatedResponse 响应因MAX_TOKENS截断时自动续写，并将各段拼接为一个响应：
+// 续写的文本接在最后一个文本部分之后，函数调用、内联数据等其他部分保留
+func (c *GeminiClient) continueTruncatedResponse(ctx context.Context, modelID string, original *models.GeminiRequest, resp *models.GeminiResponse) *models.GeminiResponse {
+	if len(resp.Candidates) != 1 || !isMaxTokensFinish(resp.Candidates[0].FinishReason) {
+		return resp
+	}
+
+	var text, continued strings.Builder
+	var extraParts []models.GeminiPart
+	text.WriteString(responseText(resp))
+
+	rounds := 0
+	for rounds < c.config.ContinuationMaxRounds && isMaxTokensFinish(resp.Candidates[0].FinishReason) {
+		rounds++
+		c.logger.Debugf("Response truncated by MAX_TOKENS, continuing (round %d/%d)", rounds, c.config.ContinuationMaxRounds)
+
+		next, err := c.sendRequestWithRetry(ctx, modelID, buildContinuationRequest(original, text.String()), false)
+		if err != nil {
+			// 续写失败时返回已有的部分结果
+			c.logger.Warnf("Continuation request failed, returning partial response: %v", err)
+			break
+		}
+		if len(next.Candidates) == 0 {
+			break
+		}
+
+		text.WriteString(responseText(next))
+		continued.WriteString(responseText(next))
+		for _, part := range next.Candidates[0].Content.Parts {
+			if part.Text == "" {
+				extraParts = append(extraParts, part)
+			}
+		}
+		resp.Candidates[0].FinishReason = next.Candidates[0].FinishReason
+		if next.UsageMetadata != nil {
+			if resp.UsageMetadata == nil {
+				resp.UsageMetadata = &models.GeminiUsageMetadata{}
+			}
+			resp.UsageMetadata.PromptTokenCount += next.UsageMetadata.PromptTokenCount
+			resp.UsageMetadata.CandidatesTokenCount += next.UsageMetadata.CandidatesTokenCount
+			resp.UsageMetadata.TotalTokenCount += next.UsageMetadata.TotalTokenCount
+		}
+	}
+
+	resp.Candidates[0].Content.Parts = appendText(resp.Candidates[0].Content.Parts, continued.String())
+	resp.Candidates[0].Content.Parts = append(resp.Candidates[0].Content.Parts, extraParts...)
+	c.logger.Infof("Stitched %d continuation rounds for %s", rounds, modelID)
+	return resp
+}
+
+// appendText 将文本接在最后一个文本部分之后，没有文本部分时追加一个新部分
+func appendText(parts []models.GeminiPart, text string) []models.GeminiPart {
+	if text == "" {
+		return parts
+	}
+	for i := len(parts) - 1; i >= 0; i-- {
+		if parts[i].Text != "" {
+			parts[i].Text += text
+			return parts
+		}
+	}
+	return append(parts, models.GeminiPart{Text: text})
+}
+
+// sendStreamRequestWithContinuation 流式请求因MAX_TOKENS截断时透明地发起续写请求，对调用方保持为一个连续的流
+func (c *GeminiClient) sendStreamRequestWithContinuation(ctx context.Context, modelID string, req *models.GeminiRequest, callback func(*models.GeminiStreamChunk) error) error {
+	if multipleCandidates(req) {
+		return c.sendStreamRequest(ctx, modelID, req, callback)
+	}
+
+	original := cloneGeminiRequest(req)
+	var text strings.Builder
+
+	current := req
+	for round := 0; ; round++ {
+		canContinue := round < c.config.ContinuationMaxRounds
+		truncated := false
+
+		err := c.sendStreamRequest(ctx, modelID, current, func(chunk *models.GeminiStreamChunk) error {
+			if candidate := chunk.Candidate(0); candidate != nil {
+				for _, part := range candidate.Content.Parts {
+					text.WriteString(part.Text)
+				}
+				// 隐藏截断的结束原因，续写内容将接在同一个流中
+				if canContinue && isMaxTokensFinish(candidate.FinishReason) {
+					truncated = true
+					candidate.FinishReason = ""
+				}
+			}
+			return callback(chunk)
+		})
+		if err != nil {
+			return err
+		}
+		if !truncated {
+			return nil
+		}
+
+		c.logger.Debugf("Stream truncated by MAX_TOKENS, continuing (round %d/%d)", round+1, c.config.ContinuationMaxRounds)
+		current = buildContinuationRequest(original, text.String())
+	}
+}
+
+// sendStreamRaw 发送原始流式请求，按配置自动续写被截断的输出
+func (c *GeminiClient) sendStreamRaw(ctx context.Context, modelID string, req *models.GeminiRequest) (*http.Response, error) {
+	if c.config.ContinuationMaxRounds <= 0 || multipleCandidates(req) {
+		return c.sendStreamRequestRaw(ctx, modelID, req)
+	}
+
+	original := cloneGeminiRequest(req)
+	resp, err := c.sendStreamRequestRaw(ctx, modelID, req)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = &continuationSSEReader{c: c, ctx: ctx, modelID: modelID, original: original, src: resp.Body, reader: bufio.NewReader(resp.Body)}
+	resp.ContentLength = -1
+	resp.Header.Del("Content-Length")
+	return resp, nil
+}
+
+// continuationSSEReader 原始流因MAX_TOKENS截断时隐藏截断的结束原因，并在流结束后接上续写请求的流，
+// 对调用方保持为一个连续的流；data 行的其他内容原样返回
+type continuationSSEReader struct {
+	c         *GeminiClient
+	ctx       context.Context
+	modelID   string
+	original  *models.GeminiRequest
+	src       io.ReadCloser
+	reader    *bufio.Reader
+	text      strings.Builder
+	rounds    int
+	truncated bool
+	pending   []byte
+	err       error
+}
+
+// Read 读取数据，当前流被截断且结束时发起续写请求
+func (r *continuationSSEReader) Read(p []byte) (int, error) {
+	for len(r.pending) == 0 {
+		if r.err != nil {
+			return 0, r.err
+		}
+
+		line, err := r.reader.ReadBytes('\n')
+		if len(line) > 0 {
+			r.pending = r.observe(line)
+		}
+		if err == io.EOF && r.truncated {
+			err = r.continueStream()
+		}
+		if err != nil {
+			r.err = err
+		}
+	}
+
+	n := copy(p, r.pending)
+	r.pending = r.pending[n:]
+	return n, nil
+}
+
+// observe 记录 data 行中已生成的文本，可以续写时去掉 MAX_TOKENS 结束原因
+func (r *continuationSSEReader) observe(line []byte) []byte {
+	data, ok := bytes.CutPrefix(line, []byte("data: "))
+	if !ok {
+		return line
+	}
+	trimmed := bytes.TrimRight(data, "\r\n")
+	var chunk models.GeminiStreamChunk
+	if err := json.Unmarshal(unwrapCodeAssistPayload(trimmed), &chunk); err != nil {
+		return line
+	}
+	candidate := chunk.Candidate(0)
+	if candidate == nil {
+		return line
+	}
+	for _, part := range candidate.Content.Parts {
+		r.text.WriteString(part.Text)
+	}
+	if r.rounds >= r.c.config.ContinuationMaxRounds || !isMaxTokensFinish(candidate.FinishReason) {
+		return line
+	}
+
+	hidden, err := interceptPayload(trimmed, func(chunk *models.GeminiStreamChunk) error {
+		if candidate := chunk.Candidate(0); candidate != nil {
+			candidate.FinishReason = ""
+		}
+		return nil
+	})
+	if err != nil {
+		return line
+	}
+	r.truncated = true
+	return append(append([]byte("data: "), hidden...), data[len(trimmed):]...)
+}
+
+// continueStream 关闭被截断的流，发送续写请求并改为读取续写的流
+func (r *continuationSSEReader) continueStream() error {
+	r.src.Close()
+	r.rounds++
+	r.truncated = false
+	r.c.logger.Debugf("Stream truncated by MAX_TOKENS, continuing (round %d/%d)", r.rounds, r.c.config.ContinuationMaxRounds)
+
+	resp, err := r.c.sendStreamRequestRaw(r.ctx, r.modelID, buildContinuationRequest(r.original, r.text.String()))
+	if err != nil {
+		r.src = io.NopCloser(bytes.NewReader(nil))
+		return fmt.Errorf("continuation request failed: %w", err)
+	}
+	r.src = resp.Body
+	r.reader = bufio.NewReader(resp.Body)
+	return nil
+}
+
+// Close 关闭当前的上游响应体
+func (r *continuationSSEReader) Close() error {
+	return r.src.Close()
+}
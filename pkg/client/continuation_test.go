@@ -0,0 +1,126 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/ba0gu0/gemini-go-proxy/pkg/config"
+	"github.com/ba0gu0/gemini-go-proxy/pkg/models"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuildContinuationRequest(t *testing.T) {
+	original := &models.GeminiRequest{
+		Contents: []models.GeminiContent{
+			{Role: "user", Parts: []models.GeminiPart{{Text: "Write a long story"}}},
+		},
+	}
+
+	followUp := buildContinuationRequest(original, "Once upon a time")
+
+	assert.Len(t, original.Contents, 1)
+	assert.Len(t, followUp.Contents, 3)
+	assert.Equal(t, "model", followUp.Contents[1].Role)
+	assert.Equal(t, "Once upon a time", followUp.Contents[1].Parts[0].Text)
+	assert.Equal(t, "user", followUp.Contents[2].Role)
+	assert.Equal(t, continuationPrompt, followUp.Contents[2].Parts[0].Text)
+}
+
+func TestIsMaxTokensFinish(t *testing.T) {
+	assert.True(t, isMaxTokensFinish("MAX_TOKENS"))
+	assert.True(t, isMaxTokensFinish("max_tokens"))
+	assert.False(t, isMaxTokensFinish("STOP"))
+	assert.False(t, isMaxTokensFinish(""))
+}
+
+func TestGeminiClient_ContinuationKeepsNonTextParts(t *testing.T) {
+	responses := []string{
+		`{"candidates":[{"content":{"role":"model","parts":[{"text":"Hello"},{"functionCall":{"name":"lookup","args":{"q":"x"}}},{"inlineData":{"mimeType":"image/png","data":"AAAA"}}]},"finishReason":"MAX_TOKENS"}]}`,
+		`{"candidates":[{"content":{"role":"model","parts":[{"text":" world"}]},"finishReason":"STOP"}]}`,
+	}
+
+	cfg := config.DefaultConfig()
+	cfg.APIMode = config.AIStudio
+	cfg.ContinuationMaxRounds = 1
+	client := NewGeminiClient(cfg, nil, logrus.New())
+	calls := 0
+	client.client.Transport = roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		body := responses[calls]
+		calls++
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": []string{"application/json"}},
+			Body:       io.NopCloser(strings.NewReader(body)),
+		}, nil
+	})
+
+	req := &models.GeminiRequest{Contents: []models.GeminiContent{{Role: "user", Parts: []models.GeminiPart{{Text: "hi"}}}}}
+	resp, err := client.SendRequest(context.Background(), "gemini-2.5-flash", req)
+	require.NoError(t, err)
+	assert.Equal(t, 2, calls)
+
+	parts := resp.Candidates[0].Content.Parts
+	require.Len(t, parts, 3)
+	assert.Equal(t, "Hello world", parts[0].Text)
+	require.NotNil(t, parts[1].FunctionCall)
+	assert.Equal(t, "lookup", parts[1].FunctionCall.Name)
+	require.NotNil(t, parts[2].InlineData)
+	assert.Equal(t, "image/png", parts[2].InlineData.MimeType)
+	assert.Equal(t, "STOP", resp.Candidates[0].FinishReason)
+}
+
+func TestGeminiClient_RawStreamContinuation(t *testing.T) {
+	streams := []string{
+		"data: {\"candidates\":[{\"content\":{\"parts\":[{\"text\":\"Hello\"}]}}]}\r\n\r\n" +
+			"data: {\"candidates\":[{\"content\":{\"parts\":[{\"text\":\",\"}]},\"finishReason\":\"MAX_TOKENS\"}],\"modelVersion\":\"v1\"}\r\n\r\n",
+		"data: {\"candidates\":[{\"content\":{\"parts\":[{\"text\":\" world\"}]},\"finishReason\":\"STOP\"}]}\r\n\r\n",
+	}
+
+	cfg := config.DefaultConfig()
+	cfg.APIMode = config.AIStudio
+	cfg.ContinuationMaxRounds = 1
+	client := NewGeminiClient(cfg, nil, logrus.New())
+	var sent []models.GeminiRequest
+	client.client.Transport = roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		var body models.GeminiRequest
+		require.NoError(t, json.NewDecoder(req.Body).Decode(&body))
+		sent = append(sent, body)
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": []string{"text/event-stream"}},
+			Body:       io.NopCloser(strings.NewReader(streams[len(sent)-1])),
+		}, nil
+	})
+
+	req := &models.GeminiRequest{Contents: []models.GeminiContent{{Role: "user", Parts: []models.GeminiPart{{Text: "hi"}}}}}
+	resp, err := client.SendStreamRequestRaw(context.Background(), "gemini-2.5-flash", req)
+	require.NoError(t, err)
+	data, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	// 截断的结束原因被隐藏，续写的流接在同一个响应体中，其他字段原样保留
+	assert.Equal(t, "data: {\"candidates\":[{\"content\":{\"parts\":[{\"text\":\"Hello\"}]}}]}\r\n\r\n"+
+		"data: {\"candidates\":[{\"content\":{\"parts\":[{\"text\":\",\"}]}}],\"modelVersion\":\"v1\"}\r\n\r\n"+
+		streams[1], string(data))
+	require.Len(t, sent, 2)
+	assert.Equal(t, "Hello,", sent[1].Contents[1].Parts[0].Text)
+	assert.Equal(t, continuationPrompt, sent[1].Contents[2].Parts[0].Text)
+
+	// 达到最大轮数后保留 MAX_TOKENS
+	sent = nil
+	streams = []string{streams[0], streams[0]}
+	resp, err = client.SendStreamRequestRaw(context.Background(), "gemini-2.5-flash", req)
+	require.NoError(t, err)
+	data, err = io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Len(t, sent, 2)
+	assert.Equal(t, 1, strings.Count(string(data), "MAX_TOKENS"))
+}
@@ -6,6 +6,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/ba0gu0/gemini-go-proxy/pkg/config"
 	"github.com/ba0gu0/gemini-go-proxy/pkg/models"
 	"github.com/sirupsen/logrus"
 )
@@ -14,6 +15,7 @@ import (
 type FormatConverter struct {
 	useCodeAssist bool
 	logger        *logrus.Logger
+	capabilities  map[string]config.ModelCapabilities // 配置的模型能力，覆盖内置能力表
 }
 
 func NewFormatConverter(logger *logrus.Logger) *FormatConverter {
@@ -61,20 +63,46 @@ func (c *FormatConverter) OpenAIToGeminiRequest(req *models.OpenAIRequest) (*mod
 
 	// 2. 处理对话消息
 	var conversationContents []models.GeminiContent
+	toolNames := make(map[string]string) // tool_call_id -> 函数名，用于tool消息找回函数名
 	for _, msg := range nonSystemMessages {
 		var role string
+		parts, err := messageParts(msg)
+		if err != nil {
+			return nil, err
+		}
 		switch strings.ToLower(msg.Role) {
 		case "user":
 			role = "user"
 		case "assistant":
 			role = "model" // Gemini使用"model"而不是"assistant"
+			if len(msg.ToolCalls) > 0 {
+				callParts, err := toolCallParts(msg.ToolCalls)
+				if err != nil {
+					return nil, err
+				}
+				for _, call := range msg.ToolCalls {
+					toolNames[call.ID] = call.Function.Name
+				}
+				if msg.Content == "" {
+					parts = nil
+				}
+				parts = append(parts, callParts...)
+			}
+		case "tool", "function":
+			// 函数执行结果以user角色的functionResponse回传
+			role = "user"
+			name := toolNames[msg.ToolCallID]
+			if name == "" {
+				name = msg.Name
+			}
+			parts = []models.GeminiPart{toolResponsePart(name, msg.Content)}
 		default:
 			c.logger.Warnf("Ignoring message with unsupported role: %s", msg.Role)
 			continue
 		}
 		conversationContents = append(conversationContents, models.GeminiContent{
 			Role:  role,
-			Parts: []models.GeminiPart{{Text: msg.Content}},
+			Parts: parts,
 		})
 	}
 
@@ -84,12 +112,30 @@ func (c *FormatConverter) OpenAIToGeminiRequest(req *models.OpenAIRequest) (*mod
 	// 4. 设置生成配置
 	// 注意：Code Assist模式在某些情况下不支持GenerationConfig，但流式请求可能需要
 	geminiReq.GenerationConfig = &models.GeminiGenerationConfig{
-		Temperature:     req.Temperature,
-		TopP:            req.TopP,
-		MaxOutputTokens: req.MaxTokens,
-		StopSequences:   req.Stop,
+		Temperature:      req.Temperature,
+		TopP:             req.TopP,
+		MaxOutputTokens:  req.MaxTokens,
+		StopSequences:    req.Stop,
+		CandidateCount:   req.N,
+		Seed:             req.Seed,
+		PresencePenalty:  req.PresencePenalty,
+		FrequencyPenalty: req.FrequencyPenalty,
+	}
+	if err := applyResponseFormat(geminiReq.GenerationConfig, req.ResponseFormat); err != nil {
+		return nil, err
 	}
 
+	// 5. 工具定义和调用模式
+	geminiReq.Tools = convertOpenAITools(req.Tools)
+	toolConfig, err := convertToolChoice(req.ToolChoice)
+	if err != nil {
+		return nil, err
+	}
+	geminiReq.ToolConfig = toolConfig
+
+	// 6. extra_body/google 扩展字段在序列化时原样合并
+	geminiReq.Extra = req.ExtraFields()
+
 	return geminiReq, nil
 }
 
@@ -105,9 +151,14 @@ func (c *FormatConverter) mergeConsecutiveMessages(contents []models.GeminiConte
 	for i := 1; i < len(contents); i++ {
 		next := contents[i]
 		if current.Role == next.Role {
-			// 合并文本内容
-			if len(current.Parts) > 0 && len(next.Parts) > 0 {
-				current.Parts[0].Text += "\n" + next.Parts[0].Text
+			if isTextOnly(current) && isTextOnly(next) {
+				// 合并文本内容
+				if len(current.Parts) > 0 && len(next.Parts) > 0 {
+					current.Parts[0].Text += "\n" + next.Parts[0].Text
+				}
+			} else {
+				// 包含函数调用、结果或图片时保留全部部分（并行调用的多个结果需在同一条消息中）
+				current.Parts = append(append([]models.GeminiPart(nil), current.Parts...), next.Parts...)
 			}
 		} else {
 			merged = append(merged, current)
@@ -124,93 +175,157 @@ func (c *FormatConverter) GeminiToOpenAIResponse(geminiResp *models.GeminiRespon
 		return nil, fmt.Errorf("Gemini response cannot be nil")
 	}
 
-	var content string
-	var finishReason *string
-
+	// 每个候选对应一个choice（请求n>1时有多个），没有候选时返回一个空choice
+	choices := []models.OpenAIChoice{{Index: 0, Message: &models.OpenAIMessage{Role: "assistant"}}}
 	if len(geminiResp.Candidates) > 0 {
-		candidate := geminiResp.Candidates[0]
+		choices = make([]models.OpenAIChoice, len(geminiResp.Candidates))
+	}
+	for i, candidate := range geminiResp.Candidates {
 		var textParts []string
 		for _, part := range candidate.Content.Parts {
 			if part.Text != "" {
 				textParts = append(textParts, part.Text)
 			}
 		}
-		content = strings.Join(textParts, "")
+		toolCalls := openAIToolCalls(candidate.Content.Parts, 0, false)
 
+		var finishReason *string
 		if candidate.FinishReason != "" {
 			reason := c.convertFinishReason(candidate.FinishReason)
+			if len(toolCalls) > 0 && reason == "stop" {
+				reason = "tool_calls"
+			}
 			finishReason = &reason
 		}
+		choices[i] = models.OpenAIChoice{
+			Index: i,
+			Message: &models.OpenAIMessage{
+				Role:      "assistant",
+				Content:   strings.Join(textParts, ""),
+				ToolCalls: toolCalls,
+			},
+			FinishReason: finishReason,
+		}
 	}
 
 	response := &models.OpenAIResponse{
-		ID:      c.GenerateRequestID(),
-		Object:  "chat.completion",
-		Created: time.Now().Unix(),
-		Model:   model,
-		Choices: []models.OpenAIChoice{
-			{
-				Index: 0,
-				Message: &models.OpenAIMessage{
-					Role:    "assistant",
-					Content: content,
-				},
-				FinishReason: finishReason,
-			},
-		},
+		ID:                c.GenerateRequestID(),
+		Object:            "chat.completion",
+		Created:           time.Now().Unix(),
+		Model:             model,
+		Choices:           choices,
+		SystemFingerprint: geminiResp.ModelVersion,
 	}
 
 	if geminiResp.UsageMetadata != nil {
-		response.Usage = &models.OpenAIUsage{
-			PromptTokens:     geminiResp.UsageMetadata.PromptTokenCount,
-			CompletionTokens: geminiResp.UsageMetadata.CandidatesTokenCount,
-			TotalTokens:      geminiResp.UsageMetadata.TotalTokenCount,
-		}
+		response.Usage = openAIUsage(geminiResp.UsageMetadata)
 	}
 
 	return response, nil
 }
 
-// GeminiStreamToOpenAI 将Gemini流式块转换为OpenAI流式块
-func (c *FormatConverter) GeminiStreamToOpenAI(chunk *models.GeminiStreamChunk, model string, requestID string, roleSent *bool) (*models.OpenAIStreamChunk, error) {
-	if chunk == nil {
-		return nil, fmt.Errorf("stream chunk cannot be nil")
+// openAIUsage 将Gemini用量转换为OpenAI格式
+func openAIUsage(usage *models.GeminiUsageMetadata) *models.OpenAIUsage {
+	return &models.OpenAIUsage{
+		PromptTokens:     usage.PromptTokenCount,
+		CompletionTokens: usage.CandidatesTokenCount,
+		TotalTokens:      usage.TotalTokenCount,
 	}
+}
 
-	openaiChunk := &models.OpenAIStreamChunk{
+// StreamUsageChunk 生成 stream_options.include_usage 要求的最后一个块：choices为空，只包含用量
+func (c *FormatConverter) StreamUsageChunk(usage *models.GeminiUsageMetadata, model string, requestID string) *models.OpenAIStreamChunk {
+	if usage == nil {
+		usage = &models.GeminiUsageMetadata{}
+	}
+	return &models.OpenAIStreamChunk{
 		ID:      requestID,
 		Object:  "chat.completion.chunk",
 		Created: time.Now().Unix(),
 		Model:   model,
+		Choices: []models.OpenAIChoice{},
+		Usage:   openAIUsage(usage),
 	}
+}
 
-	var content string
-	var finishReason *string
+// StreamState OpenAI流式转换在多个块之间保持的状态
+type StreamState struct {
+	RoleSent  bool // 是否已发送role
+	ToolCalls int  // 已发送的函数调用数量，作为后续tool_calls增量的起始index
 
-	if len(chunk.Candidates) > 0 {
-		candidate := chunk.Candidates[0]
+	others map[int]*StreamState // 其他候选（n>1）各自的状态
+}
+
+// choice 返回第index个候选的状态，第一个候选使用StreamState本身
+func (s *StreamState) choice(index int) *StreamState {
+	if index == 0 {
+		return s
+	}
+	if s.others == nil {
+		s.others = make(map[int]*StreamState)
+	}
+	state, ok := s.others[index]
+	if !ok {
+		state = &StreamState{}
+		s.others[index] = state
+	}
+	return state
+}
+
+// GeminiStreamToOpenAI 将Gemini流式块转换为OpenAI流式块
+func (c *FormatConverter) GeminiStreamToOpenAI(chunk *models.GeminiStreamChunk, model string, requestID string, state *StreamState) (*models.OpenAIStreamChunk, error) {
+	if chunk == nil {
+		return nil, fmt.Errorf("stream chunk cannot be nil")
+	}
+
+	openaiChunk := &models.OpenAIStreamChunk{
+		ID:                requestID,
+		Object:            "chat.completion.chunk",
+		Created:           time.Now().Unix(),
+		Model:             model,
+		SystemFingerprint: chunk.ModelVersion,
+	}
+
+	// 没有候选的块（如只有用量信息）输出一个空增量
+	candidates := chunk.Candidates
+	if len(candidates) == 0 {
+		candidates = []models.GeminiStreamCandidate{{}}
+	}
+	for i, candidate := range candidates {
+		// 多个候选（n>1）按候选的index区分choice，上游省略index时按顺序编号
+		index := candidate.Index
+		if index == 0 {
+			index = i
+		}
+		choiceState := state.choice(index)
+
+		var content string
 		for _, part := range candidate.Content.Parts {
 			content += part.Text
 		}
+		toolCalls := openAIToolCalls(candidate.Content.Parts, choiceState.ToolCalls, true)
+		choiceState.ToolCalls += len(toolCalls)
+
+		var finishReason *string
 		if candidate.FinishReason != "" {
 			reason := c.convertFinishReason(candidate.FinishReason)
+			if choiceState.ToolCalls > 0 && reason == "stop" {
+				reason = "tool_calls"
+			}
 			finishReason = &reason
 		}
-	}
 
-	// 只有在第一次发送时才包含role
-	delta := &models.OpenAIMessage{Content: content}
-	if !*roleSent {
-		delta.Role = "assistant"
-		*roleSent = true
-	}
-
-	openaiChunk.Choices = []models.OpenAIChoice{
-		{
-			Index: 0,
-			Delta: delta,
+		// 只有在第一次发送时才包含role
+		delta := &models.OpenAIMessage{Content: content, ToolCalls: toolCalls}
+		if !choiceState.RoleSent {
+			delta.Role = "assistant"
+			choiceState.RoleSent = true
+		}
+		openaiChunk.Choices = append(openaiChunk.Choices, models.OpenAIChoice{
+			Index:        index,
+			Delta:        delta,
 			FinishReason: finishReason,
-		},
+		})
 	}
 
 	return openaiChunk, nil
@@ -230,22 +345,9 @@ func (c *FormatConverter) convertFinishReason(geminiReason string) string {
 	}
 }
 
-// GenerateModelsList 生成默认的模型列表
+// GenerateModelsList 生成默认的模型列表，上游不提供模型列表时使用
 func (c *FormatConverter) GenerateModelsList() *models.OpenAIModelsResponse {
-	now := time.Now().Unix()
-	defaultModels := []string{
-		"gemini-2.5-pro", "gemini-2.5-flash", "gemini-1.5-pro", "gemini-1.5-flash", "gemini-pro", "gemini-pro-vision",
-	}
-	data := make([]models.OpenAIModel, len(defaultModels))
-	for i, modelID := range defaultModels {
-		data[i] = models.OpenAIModel{
-			ID:      modelID,
-			Object:  "model",
-			Created: now,
-			OwnedBy: "google",
-		}
-	}
-	return &models.OpenAIModelsResponse{Object: "list", Data: data}
+	return c.ConvertGeminiModels(c.defaultModels())
 }
 
 // ConvertGeminiModels 将Gemini原生模型列表转换为OpenAI格式
@@ -309,20 +411,15 @@ func (c *FormatConverter) ValidateAndFixRequest(req *models.GeminiRequest, model
 
 	config := req.GenerationConfig
 
-	// 模型特定的最大输出token限制
-	modelLimits := map[string]int{
-		"gemini-pro": 2048, "gemini-pro-vision": 2048,
-		"gemini-1.5-pro": 8192, "gemini-1.5-flash": 8192,
-		"gemini-2.5-pro": 8192, "gemini-2.5-flash": 8192,
-	}
-	if limit, exists := modelLimits[modelID]; exists {
-		if config.MaxOutputTokens == nil || *config.MaxOutputTokens > limit {
-			c.logger.Warnf("MaxOutputTokens for %s exceeds limit of %d, adjusting.", modelID, limit)
-			config.MaxOutputTokens = &limit
+	// 按模型能力和提示词长度计算输出token预算，未指定时使用预算，超出预算时下调
+	// 未知模型不设置默认值，由上游使用模型自身的上限
+	if budget, ok := c.OutputTokenBudget(req, modelID); ok {
+		if config.MaxOutputTokens == nil {
+			config.MaxOutputTokens = &budget
+		} else if *config.MaxOutputTokens > budget {
+			c.logger.Warnf("MaxOutputTokens %d for %s exceeds the available budget of %d, adjusting.", *config.MaxOutputTokens, modelID, budget)
+			config.MaxOutputTokens = &budget
 		}
-	} else if config.MaxOutputTokens == nil {
-		defaultTokens := 2048
-		config.MaxOutputTokens = &defaultTokens
 	}
 
 	// 验证并修正 temperature
@@ -347,6 +444,23 @@ func (c *FormatConverter) ValidateAndFixRequest(req *models.GeminiRequest, model
 	if config.TopK != nil && *config.TopK < 1 {
 		*config.TopK = 1
 	}
+
+	// 候选数量至少为1
+	if config.CandidateCount != nil && *config.CandidateCount < 1 {
+		config.CandidateCount = nil
+	}
+
+	// 验证并修正重复惩罚
+	for _, penalty := range []*float32{config.PresencePenalty, config.FrequencyPenalty} {
+		if penalty == nil {
+			continue
+		}
+		if *penalty < -2.0 {
+			*penalty = -2.0
+		} else if *penalty > 2.0 {
+			*penalty = 2.0
+		}
+	}
 }
 
 // GenerateRequestID 生成唯一的请求ID
@@ -0,0 +1,83 @@
+package client
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/ba0gu0/gemini-go-proxy/pkg/models"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpenAIToGeminiRequest_GenerationConfigExtras(t *testing.T) {
+	converter := NewFormatConverter(logrus.New())
+	var req models.OpenAIRequest
+	require.NoError(t, json.Unmarshal([]byte(`{
+		"model": "gemini-2.5-flash",
+		"messages": [{"role": "user", "content": "Hi"}],
+		"n": 2,
+		"seed": 42,
+		"presence_penalty": 0.5,
+		"frequency_penalty": -0.5
+	}`), &req))
+
+	geminiReq, err := converter.OpenAIToGeminiRequest(&req)
+	require.NoError(t, err)
+	data, err := json.Marshal(geminiReq.GenerationConfig)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"candidateCount":2,"seed":42,"presencePenalty":0.5,"frequencyPenalty":-0.5}`, string(data))
+
+	// 原生请求中的字段原样保留
+	var native models.GeminiRequest
+	require.NoError(t, json.Unmarshal([]byte(`{"contents":[],"generationConfig":{"responseLogprobs":true,"logprobs":3,"seed":7}}`), &native))
+	data, err = json.Marshal(native.GenerationConfig)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"responseLogprobs":true,"logprobs":3,"seed":7}`, string(data))
+}
+
+func TestGeminiToOpenAIResponse_MultipleCandidates(t *testing.T) {
+	converter := NewFormatConverter(logrus.New())
+	resp, err := converter.GeminiToOpenAIResponse(&models.GeminiResponse{Candidates: []models.GeminiCandidate{
+		{Content: models.GeminiContent{Parts: []models.GeminiPart{{Text: "first"}}}, FinishReason: "STOP"},
+		{Content: models.GeminiContent{Parts: []models.GeminiPart{{Text: "second"}}}, FinishReason: "MAX_TOKENS", Index: 1},
+	}}, "gemini-2.5-flash")
+	require.NoError(t, err)
+	require.Len(t, resp.Choices, 2)
+	assert.Equal(t, 1, resp.Choices[1].Index)
+	assert.Equal(t, "second", resp.Choices[1].Message.Content)
+	assert.Equal(t, "length", *resp.Choices[1].FinishReason)
+
+	// 没有候选时仍返回一个空choice
+	resp, err = converter.GeminiToOpenAIResponse(&models.GeminiResponse{}, "gemini-2.5-flash")
+	require.NoError(t, err)
+	require.Len(t, resp.Choices, 1)
+	assert.Nil(t, resp.Choices[0].FinishReason)
+}
+
+func TestGeminiStreamToOpenAI_MultipleCandidates(t *testing.T) {
+	converter := NewFormatConverter(logrus.New())
+	state := &StreamState{}
+
+	chunk := func(candidates ...models.GeminiStreamCandidate) *models.OpenAIStreamChunk {
+		out, err := converter.GeminiStreamToOpenAI(&models.GeminiStreamChunk{Candidates: candidates}, "gemini-2.5-flash", "chatcmpl-1", state)
+		require.NoError(t, err)
+		return out
+	}
+	text := func(index int, text string) models.GeminiStreamCandidate {
+		return models.GeminiStreamCandidate{Index: index, Content: models.GeminiContent{Parts: []models.GeminiPart{{Text: text}}}}
+	}
+
+	first := chunk(text(0, "a"), text(1, "b"))
+	require.Len(t, first.Choices, 2)
+	assert.Equal(t, "assistant", first.Choices[0].Delta.Role)
+	assert.Equal(t, 1, first.Choices[1].Index)
+	assert.Equal(t, "assistant", first.Choices[1].Delta.Role)
+
+	// 每个候选只发送一次role
+	second := chunk(text(1, "c"))
+	require.Len(t, second.Choices, 1)
+	assert.Equal(t, 1, second.Choices[0].Index)
+	assert.Empty(t, second.Choices[0].Delta.Role)
+	assert.Equal(t, "c", second.Choices[0].Delta.Content)
+}
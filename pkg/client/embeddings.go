@@ -0,0 +1,371 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/ba0gu0/gemini-go-proxy/pkg/config"
+	"github.com/ba0gu0/gemini-go-proxy/pkg/models"
+)
+
+var (
+	// ErrEmbeddingsUnsupported Code Assist API不提供嵌入接口
+	ErrEmbeddingsUnsupported = errors.New("embeddings are not supported in code_assist mode")
+	// ErrInvalidEmbeddingRequest 嵌入请求参数无效
+	ErrInvalidEmbeddingRequest = errors.New("invalid embedding request")
+)
+
+const (
+	// aiStudioEmbeddingBatchSize batchEmbedContents单次请求的最大条数
+	aiStudioEmbeddingBatchSize = 100
+	// vertexEmbeddingBatchSize Vertex AI predict单次请求的最大实例数（gemini-embedding系列模型每次只接受一条）
+	vertexEmbeddingBatchSize = 250
+)
+
+// vertexEmbeddingInstance Vertex AI文本嵌入predict请求实例
+type vertexEmbeddingInstance struct {
+	Content  string `json:"content"`
+	TaskType string `json:"task_type,omitempty"`
+	Title    string `json:"title,omitempty"`
+}
+
+// vertexEmbeddingResponse Vertex AI文本嵌入predict响应
+type vertexEmbeddingResponse struct {
+	Predictions []struct {
+		Embeddings struct {
+			Values     []float32 `json:"values"`
+			Statistics struct {
+				TokenCount float64 `json:"token_count"`
+			} `json:"statistics"`
+		} `json:"embeddings"`
+	} `json:"predictions"`
+}
+
+// SendEmbeddingRequest 批量生成嵌入向量，超过上游单次限制时分批发送
+// 返回的第二个值为上游统计的输入token数，上游未提供时为0
+func (c *GeminiClient) SendEmbeddingRequest(ctx context.Context, modelID string, req *models.GeminiBatchEmbedContentsRequest) (*models.GeminiBatchEmbedContentsResponse, int, error) {
+	if c.apiMode() == config.CodeAssist {
+		return nil, 0, ErrEmbeddingsUnsupported
+	}
+	req, err := c.interceptEmbeddingRequest(ctx, modelID, req)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	batchSize := aiStudioEmbeddingBatchSize
+	if c.apiMode() == config.VertexAI {
+		batchSize = vertexEmbeddingBatchSize
+		if strings.Contains(modelID, "gemini-embedding") {
+			batchSize = 1
+		}
+	}
+
+	resp := &models.GeminiBatchEmbedContentsResponse{Embeddings: make([]models.GeminiContentEmbedding, 0, len(req.Requests))}
+	tokens := 0
+	for start := 0; start < len(req.Requests); start += batchSize {
+		end := min(start+batchSize, len(req.Requests))
+		var embeddings []models.GeminiContentEmbedding
+		var batchTokens int
+		var err error
+		if c.apiMode() == config.VertexAI {
+			embeddings, batchTokens, err = c.vertexEmbed(ctx, modelID, req.Requests[start:end])
+		} else {
+			embeddings, err = c.aiStudioEmbed(ctx, modelID, req.Requests[start:end])
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+		if len(embeddings) != end-start {
+			return nil, 0, fmt.Errorf("embedding API returned %d embeddings for %d inputs", len(embeddings), end-start)
+		}
+		resp.Embeddings = append(resp.Embeddings, embeddings...)
+		tokens += batchTokens
+	}
+
+	// 计入请求的token用量，上游未返回时使用本地近似分词统计
+	charged := tokens
+	if charged == 0 {
+		for _, request := range req.Requests {
+			charged += len(ApproximateTokenize(contentText(request.Content)))
+		}
+	}
+	c.recordUsage(ctx, &models.GeminiUsageMetadata{PromptTokenCount: charged, TotalTokenCount: charged})
+	return resp, tokens, nil
+}
+
+// aiStudioEmbed 调用AI Studio batchEmbedContents接口
+func (c *GeminiClient) aiStudioEmbed(ctx context.Context, modelID string, requests []models.GeminiEmbedContentRequest) ([]models.GeminiContentEmbedding, error) {
+	batch := models.GeminiBatchEmbedContentsRequest{Requests: make([]models.GeminiEmbedContentRequest, len(requests))}
+	for i, request := range requests {
+		request.Model = aiStudioModelPath(modelID)
+		batch.Requests[i] = request
+	}
+
+	apiURL, err := c.buildAPIURL(ctx, modelID, "batchEmbedContents")
+	if err != nil {
+		return nil, err
+	}
+	var resp models.GeminiBatchEmbedContentsResponse
+	if err := c.postJSON(ctx, "embedding", apiURL, &batch, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Embeddings, nil
+}
+
+// vertexEmbed 调用Vertex AI predict接口，返回嵌入向量和输入token数
+func (c *GeminiClient) vertexEmbed(ctx context.Context, modelID string, requests []models.GeminiEmbedContentRequest) ([]models.GeminiContentEmbedding, int, error) {
+	body := struct {
+		Instances  []vertexEmbeddingInstance `json:"instances"`
+		Parameters map[string]any            `json:"parameters,omitempty"`
+	}{Instances: make([]vertexEmbeddingInstance, len(requests))}
+	for i, request := range requests {
+		body.Instances[i] = vertexEmbeddingInstance{
+			Content:  contentText(request.Content),
+			TaskType: request.TaskType,
+			Title:    request.Title,
+		}
+		if request.OutputDimensionality != nil {
+			body.Parameters = map[string]any{"outputDimensionality": *request.OutputDimensionality}
+		}
+	}
+
+	apiURL, err := c.buildAPIURL(ctx, modelID, "predict")
+	if err != nil {
+		return nil, 0, err
+	}
+	var resp vertexEmbeddingResponse
+	if err := c.postJSON(ctx, "embedding", apiURL, &body, &resp); err != nil {
+		return nil, 0, err
+	}
+
+	embeddings := make([]models.GeminiContentEmbedding, len(resp.Predictions))
+	tokens := 0
+	for i, prediction := range resp.Predictions {
+		embeddings[i] = models.GeminiContentEmbedding{Values: prediction.Embeddings.Values}
+		tokens += int(prediction.Embeddings.Statistics.TokenCount)
+	}
+	return embeddings, tokens, nil
+}
+
+// postJSON 发送非生成类的JSON请求（嵌入、predict）并解析响应，kind 用于日志和错误信息
+func (c *GeminiClient) postJSON(ctx context.Context, kind, apiURL string, body, out any) error {
+	reqBody, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s request: %w", kind, err)
+	}
+
+	ctx, cancel := withRequestTimeout(ctx, c.config.GetTimeout())
+	defer cancel()
+
+	httpReq, err := c.createRequest(ctx, "POST", apiURL, bytes.NewReader(reqBody))
+	if err != nil {
+		return err
+	}
+
+	c.logger.Debugf("Sending %s request: %s", kind, apiURL)
+
+	resp, err := c.client.Do(httpReq)
+	if err != nil {
+		return fmt.Errorf("%s request failed: %w", kind, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		c.cooldownAccount(httpReq, resp)
+		respBody, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("%s API request failed with status %d: %s", kind, resp.StatusCode, string(respBody))
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode %s response: %w", kind, err)
+	}
+	return nil
+}
+
+// SendOpenAIEmbeddingRequest 发送OpenAI格式的嵌入请求
+func (c *GeminiClient) SendOpenAIEmbeddingRequest(ctx context.Context, req *models.OpenAIEmbeddingRequest) (*models.OpenAIEmbeddingResponse, error) {
+	inputs, err := embeddingInputs(req.Input)
+	if err != nil {
+		return nil, err
+	}
+	if req.EncodingFormat != "" && req.EncodingFormat != "float" && req.EncodingFormat != "base64" {
+		return nil, fmt.Errorf("%w: unsupported encoding_format %q", ErrInvalidEmbeddingRequest, req.EncodingFormat)
+	}
+
+	batch := &models.GeminiBatchEmbedContentsRequest{Requests: make([]models.GeminiEmbedContentRequest, len(inputs))}
+	for i, input := range inputs {
+		batch.Requests[i] = models.GeminiEmbedContentRequest{
+			Content:              models.GeminiContent{Parts: []models.GeminiPart{{Text: input}}},
+			OutputDimensionality: req.Dimensions,
+		}
+	}
+
+	geminiResp, tokens, err := c.SendEmbeddingRequest(ctx, req.Model, batch)
+	if err != nil {
+		return nil, err
+	}
+
+	// 上游未返回用量时使用本地近似分词统计
+	if tokens == 0 {
+		for _, input := range inputs {
+			tokens += len(ApproximateTokenize(input))
+		}
+	}
+
+	resp := &models.OpenAIEmbeddingResponse{
+		Object: "list",
+		Data:   make([]models.OpenAIEmbedding, len(geminiResp.Embeddings)),
+		Model:  req.Model,
+		Usage:  models.OpenAIEmbeddingUsage{PromptTokens: tokens, TotalTokens: tokens},
+	}
+	for i, embedding := range geminiResp.Embeddings {
+		var vector any = embedding.Values
+		if req.EncodingFormat == "base64" {
+			vector = encodeEmbeddingBase64(embedding.Values)
+		}
+		resp.Data[i] = models.OpenAIEmbedding{Object: "embedding", Index: i, Embedding: vector}
+	}
+	return resp, nil
+}
+
+// 库模式批量嵌入的默认分组大小和并发数
+const (
+	defaultEmbedBatchSize   = aiStudioEmbeddingBatchSize
+	defaultEmbedConcurrency = 4
+)
+
+// EmbedOptions 批量嵌入选项
+type EmbedOptions struct {
+	TaskType             string // 任务类型，如 RETRIEVAL_DOCUMENT、RETRIEVAL_QUERY、SEMANTIC_SIMILARITY
+	OutputDimensionality int    // 输出向量维度，0表示使用模型默认维度
+	BatchSize            int    // 每组发送的文本数，默认100
+	Concurrency          int    // 同时发送的组数，默认4
+}
+
+// Embed 为一组文本生成嵌入向量，返回的向量与输入一一对应
+func (c *GeminiClient) Embed(ctx context.Context, modelID string, texts []string) ([][]float32, error) {
+	return c.EmbedBatch(ctx, modelID, texts, nil)
+}
+
+// EmbedBatch 将大量文本按BatchSize分组并发生成嵌入向量，返回的向量保持输入顺序；
+// 任一组失败时取消其余请求并返回错误，opts为nil时使用默认选项
+func (c *GeminiClient) EmbedBatch(ctx context.Context, modelID string, texts []string, opts *EmbedOptions) ([][]float32, error) {
+	if len(texts) == 0 {
+		return nil, fmt.Errorf("%w: texts must not be empty", ErrInvalidEmbeddingRequest)
+	}
+	var options EmbedOptions
+	if opts != nil {
+		options = *opts
+	}
+	if options.BatchSize <= 0 {
+		options.BatchSize = defaultEmbedBatchSize
+	}
+	if options.Concurrency <= 0 {
+		options.Concurrency = defaultEmbedConcurrency
+	}
+	var dimensions *int
+	if options.OutputDimensionality > 0 {
+		dimensions = &options.OutputDimensionality
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	vectors := make([][]float32, len(texts))
+	slots := make(chan struct{}, options.Concurrency)
+	var (
+		wg       sync.WaitGroup
+		errOnce  sync.Once
+		firstErr error
+	)
+	for start := 0; start < len(texts); start += options.BatchSize {
+		end := min(start+options.BatchSize, len(texts))
+		batch := &models.GeminiBatchEmbedContentsRequest{Requests: make([]models.GeminiEmbedContentRequest, end-start)}
+		for i, text := range texts[start:end] {
+			batch.Requests[i] = models.GeminiEmbedContentRequest{
+				Content:              models.GeminiContent{Parts: []models.GeminiPart{{Text: text}}},
+				TaskType:             options.TaskType,
+				OutputDimensionality: dimensions,
+			}
+		}
+
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		wg.Add(1)
+		go func(start int) {
+			defer wg.Done()
+			defer func() { <-slots }()
+			resp, _, err := c.SendEmbeddingRequest(ctx, modelID, batch)
+			if err != nil {
+				errOnce.Do(func() {
+					firstErr = err
+					cancel()
+				})
+				return
+			}
+			for i, embedding := range resp.Embeddings {
+				vectors[start+i] = embedding.Values
+			}
+		}(start)
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return vectors, nil
+}
+
+// embeddingInputs 解析OpenAI嵌入请求的input字段（字符串或字符串数组）
+func embeddingInputs(raw json.RawMessage) ([]string, error) {
+	var single string
+	if err := json.Unmarshal(raw, &single); err == nil {
+		return []string{single}, nil
+	}
+	var inputs []string
+	if err := json.Unmarshal(raw, &inputs); err != nil {
+		return nil, fmt.Errorf("%w: input must be a string or an array of strings", ErrInvalidEmbeddingRequest)
+	}
+	if len(inputs) == 0 {
+		return nil, fmt.Errorf("%w: input must not be empty", ErrInvalidEmbeddingRequest)
+	}
+	return inputs, nil
+}
+
+// encodeEmbeddingBase64 按OpenAI格式将向量编码为小端float32的base64字符串
+func encodeEmbeddingBase64(values []float32) string {
+	data := make([]byte, 4*len(values))
+	for i, value := range values {
+		binary.LittleEndian.PutUint32(data[4*i:], math.Float32bits(value))
+	}
+	return base64.StdEncoding.EncodeToString(data)
+}
+
+// contentText 拼接内容中的全部文本
+func contentText(content models.GeminiContent) string {
+	var texts []string
+	for _, part := range content.Parts {
+		if part.Text != "" {
+			texts = append(texts, part.Text)
+		}
+	}
+	return strings.Join(texts, "\n")
+}
@@ -0,0 +1,145 @@
+package client
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/ba0gu0/gemini-go-proxy/pkg/auth"
+	"github.com/ba0gu0/gemini-go-proxy/pkg/config"
+	"github.com/ba0gu0/gemini-go-proxy/pkg/models"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGeminiClient_SendOpenAIEmbeddingRequest_AIStudio(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.APIMode = config.AIStudio
+	client := NewGeminiClient(cfg, nil, logrus.New())
+
+	var batches []int
+	client.client.Transport = roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		assert.True(t, strings.HasSuffix(req.URL.Path, "/models/text-embedding-004:batchEmbedContents"))
+
+		var batch models.GeminiBatchEmbedContentsRequest
+		require.NoError(t, json.NewDecoder(req.Body).Decode(&batch))
+		batches = append(batches, len(batch.Requests))
+		assert.Equal(t, "models/text-embedding-004", batch.Requests[0].Model)
+		assert.Equal(t, 8, *batch.Requests[0].OutputDimensionality)
+
+		embeddings := make([]string, len(batch.Requests))
+		for i := range embeddings {
+			embeddings[i] = `{"values":[1,0.5]}`
+		}
+		body := fmt.Sprintf(`{"embeddings":[%s]}`, strings.Join(embeddings, ","))
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(body))}, nil
+	})
+
+	inputs := make([]string, aiStudioEmbeddingBatchSize+1)
+	for i := range inputs {
+		inputs[i] = "hi"
+	}
+	input, err := json.Marshal(inputs)
+	require.NoError(t, err)
+
+	dimensions := 8
+	resp, err := client.SendOpenAIEmbeddingRequest(context.Background(), &models.OpenAIEmbeddingRequest{
+		Model:      "text-embedding-004",
+		Input:      input,
+		Dimensions: &dimensions,
+	})
+	require.NoError(t, err)
+
+	// 超过单次上限时分批发送
+	assert.Equal(t, []int{aiStudioEmbeddingBatchSize, 1}, batches)
+	assert.Equal(t, "list", resp.Object)
+	require.Len(t, resp.Data, len(inputs))
+	assert.Equal(t, len(inputs)-1, resp.Data[len(inputs)-1].Index)
+	assert.Equal(t, []float32{1, 0.5}, resp.Data[0].Embedding)
+	assert.Equal(t, len(inputs), resp.Usage.PromptTokens)
+}
+
+func TestGeminiClient_SendOpenAIEmbeddingRequest_VertexBase64(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.APIMode = config.VertexAI
+	cfg.Location = "us-central1"
+	client := NewGeminiClient(cfg, auth.NewGoogleAuth(&models.GoogleAuthConfig{ProjectID: "test-project"}, logrus.New()), logrus.New())
+
+	client.client.Transport = roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		assert.True(t, strings.HasSuffix(req.URL.Path, "/publishers/google/models/text-embedding-005:predict"))
+		body := `{"predictions":[{"embeddings":{"values":[1],"statistics":{"token_count":3}}}]}`
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(body))}, nil
+	})
+
+	resp, err := client.SendOpenAIEmbeddingRequest(context.Background(), &models.OpenAIEmbeddingRequest{
+		Model:          "text-embedding-005",
+		Input:          json.RawMessage(`"hello"`),
+		EncodingFormat: "base64",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 3, resp.Usage.TotalTokens)
+	assert.Equal(t, base64.StdEncoding.EncodeToString([]byte{0, 0, 0x80, 0x3f}), resp.Data[0].Embedding)
+}
+
+func TestGeminiClient_SendEmbeddingRequest_Errors(t *testing.T) {
+	client := NewGeminiClient(config.DefaultConfig(), nil, logrus.New())
+	_, _, err := client.SendEmbeddingRequest(context.Background(), "text-embedding-004", &models.GeminiBatchEmbedContentsRequest{})
+	assert.ErrorIs(t, err, ErrEmbeddingsUnsupported)
+
+	for _, input := range []string{`[]`, `[1,2]`, `{}`} {
+		_, err = client.SendOpenAIEmbeddingRequest(context.Background(), &models.OpenAIEmbeddingRequest{Model: "m", Input: json.RawMessage(input)})
+		assert.ErrorIs(t, err, ErrInvalidEmbeddingRequest, input)
+	}
+	_, err = client.SendOpenAIEmbeddingRequest(context.Background(), &models.OpenAIEmbeddingRequest{Model: "m", Input: json.RawMessage(`"x"`), EncodingFormat: "int8"})
+	assert.ErrorIs(t, err, ErrInvalidEmbeddingRequest)
+}
+
+func TestGeminiClient_EmbedBatch(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.APIMode = config.AIStudio
+	client := NewGeminiClient(cfg, nil, logrus.New())
+
+	// 每个向量的值为输入文本，便于检查顺序
+	client.client.Transport = roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		var batch models.GeminiBatchEmbedContentsRequest
+		require.NoError(t, json.NewDecoder(req.Body).Decode(&batch))
+		embeddings := make([]string, len(batch.Requests))
+		for i, request := range batch.Requests {
+			assert.Equal(t, "RETRIEVAL_DOCUMENT", request.TaskType)
+			embeddings[i] = fmt.Sprintf(`{"values":[%s]}`, request.Content.Parts[0].Text)
+		}
+		body := fmt.Sprintf(`{"embeddings":[%s]}`, strings.Join(embeddings, ","))
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(body))}, nil
+	})
+
+	texts := make([]string, 25)
+	for i := range texts {
+		texts[i] = fmt.Sprint(i)
+	}
+	vectors, err := client.EmbedBatch(context.Background(), "text-embedding-004", texts, &EmbedOptions{
+		TaskType:    "RETRIEVAL_DOCUMENT",
+		BatchSize:   4,
+		Concurrency: 3,
+	})
+	require.NoError(t, err)
+	require.Len(t, vectors, len(texts))
+	for i, vector := range vectors {
+		assert.Equal(t, []float32{float32(i)}, vector)
+	}
+
+	_, err = client.Embed(context.Background(), "text-embedding-004", nil)
+	assert.ErrorIs(t, err, ErrInvalidEmbeddingRequest)
+
+	// 任一组失败时返回错误
+	client.client.Transport = roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusBadRequest, Body: io.NopCloser(strings.NewReader(`{}`))}, nil
+	})
+	_, err = client.Embed(context.Background(), "text-embedding-004", texts)
+	assert.Error(t, err)
+}
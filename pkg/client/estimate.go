@@ -0,0 +1,146 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/ba0gu0/gemini-go-proxy/pkg/models"
+)
+
+// EstimateRequest 估算请求的提示词token数和剩余上下文，并按发送时的处理流程列出代理将做的参数调整，不访问上游
+func (c *GeminiClient) EstimateRequest(ctx context.Context, modelID string, req *models.GeminiRequest) (*models.EstimateResponse, error) {
+	// 在副本上执行发送前的处理，不修改调用方的请求（生成参数中的指针字段也需要复制）
+	planned := cloneGeminiRequest(req)
+	if req.GenerationConfig != nil {
+		generationConfig, err := cloneGenerationConfig(req.GenerationConfig)
+		if err != nil {
+			return nil, err
+		}
+		planned.GenerationConfig = generationConfig
+	}
+	result := &models.EstimateResponse{Model: modelID, Adjustments: []models.ParameterAdjustment{}}
+
+	before := generationConfigFields(planned.GenerationConfig)
+	c.converter.ValidateAndFixRequest(planned, modelID)
+	result.Adjustments = append(result.Adjustments, diffGenerationConfig(before, generationConfigFields(planned.GenerationConfig))...)
+	if err := c.converter.CheckCapabilities(planned, modelID); err != nil {
+		result.Error = err.Error()
+	}
+
+	systemBefore := systemInstructionTokens(planned)
+	if err := c._applySystemPromptFromFile(planned); err != nil {
+		c.logger.Warnf("Failed to apply system prompt from file: %v", err)
+	}
+	if tokens := systemInstructionTokens(planned); tokens != systemBefore {
+		result.Adjustments = append(result.Adjustments, models.ParameterAdjustment{
+			Parameter: "systemInstruction", From: systemBefore, To: tokens, Reason: "system_prompt_file applied",
+		})
+		systemBefore = tokens
+	}
+	c.applyResponseLanguage(ctx, planned)
+	if tokens := systemInstructionTokens(planned); tokens != systemBefore {
+		result.Adjustments = append(result.Adjustments, models.ParameterAdjustment{
+			Parameter: "systemInstruction", From: systemBefore, To: tokens, Reason: "response language directive appended",
+		})
+	}
+
+	result.SystemTokens = systemInstructionTokens(planned)
+	result.PromptTokens = estimatePromptTokens(planned)
+	if capabilities, ok := c.converter.LookupCapabilities(modelID); ok && capabilities.MaxInputTokens > 0 {
+		remaining := capabilities.MaxInputTokens - result.PromptTokens
+		result.ContextWindow = capabilities.MaxInputTokens
+		result.RemainingContext = &remaining
+	}
+	if planned.GenerationConfig != nil {
+		result.MaxOutputTokens = planned.GenerationConfig.MaxOutputTokens
+	} else if budget, ok := c.converter.OutputTokenBudget(planned, modelID); ok {
+		result.MaxOutputTokens = &budget
+	}
+	return result, nil
+}
+
+// EstimateOpenAIRequest 将OpenAI格式请求转换为Gemini格式后估算
+func (c *GeminiClient) EstimateOpenAIRequest(ctx context.Context, req *models.OpenAIRequest) (*models.EstimateResponse, error) {
+	geminiReq, err := c.converter.OpenAIToGeminiRequest(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert request: %w", err)
+	}
+	return c.EstimateRequest(ctx, req.Model, geminiReq)
+}
+
+// cloneGenerationConfig 通过序列化深拷贝生成参数
+func cloneGenerationConfig(config *models.GeminiGenerationConfig) (*models.GeminiGenerationConfig, error) {
+	data, err := json.Marshal(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to copy generation config: %w", err)
+	}
+	var clone models.GeminiGenerationConfig
+	if err := json.Unmarshal(data, &clone); err != nil {
+		return nil, fmt.Errorf("failed to copy generation config: %w", err)
+	}
+	return &clone, nil
+}
+
+// generationConfigFields 以JSON字段名展开生成参数
+func generationConfigFields(config *models.GeminiGenerationConfig) map[string]any {
+	fields := make(map[string]any)
+	if config == nil {
+		return fields
+	}
+	data, _ := json.Marshal(config)
+	json.Unmarshal(data, &fields)
+	return fields
+}
+
+// diffGenerationConfig 比较处理前后的生成参数，按参数名排序返回调整项
+func diffGenerationConfig(before, after map[string]any) []models.ParameterAdjustment {
+	names := make(map[string]bool)
+	for name := range before {
+		names[name] = true
+	}
+	for name := range after {
+		names[name] = true
+	}
+	sorted := make([]string, 0, len(names))
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+
+	var adjustments []models.ParameterAdjustment
+	for _, name := range sorted {
+		from, had := before[name]
+		to, has := after[name]
+		if reflect.DeepEqual(from, to) {
+			continue
+		}
+		reason := "clamped to the supported range"
+		switch {
+		case !had:
+			reason = "defaulted by the proxy"
+		case !has:
+			reason = "removed as invalid"
+		case name == "maxOutputTokens":
+			reason = "exceeds the model's output budget"
+		}
+		adjustments = append(adjustments, models.ParameterAdjustment{
+			Parameter: "generationConfig." + name, From: from, To: to, Reason: reason,
+		})
+	}
+	return adjustments
+}
+
+// systemInstructionTokens 估算系统指令的token数
+func systemInstructionTokens(req *models.GeminiRequest) int {
+	if req.SystemInstruction == nil {
+		return 0
+	}
+	tokens := 0
+	for _, part := range req.SystemInstruction.Parts {
+		tokens += EstimateTokenCount(part.Text)
+	}
+	return tokens
+}
@@ -0,0 +1,284 @@
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/ba0gu0/gemini-go-proxy/pkg/config"
+)
+
+// ErrResponseBlocked 生成内容命中屏蔽词且配置为中止响应
+var ErrResponseBlocked = errors.New("response blocked by content filter")
+
+// contentFilter 按屏蔽词列表检查生成文本
+type contentFilter struct {
+	pattern *regexp.Regexp
+	maxLen  int // 最长屏蔽词的字符数，流式扫描时保留 maxLen-1 个字符等待后续数据
+	abort   bool
+}
+
+// newContentFilter 根据配置创建内容过滤器，未配置屏蔽词时返回nil
+func newContentFilter(cfg *config.ResponseFilter) (*contentFilter, error) {
+	if cfg == nil {
+		return nil, nil
+	}
+
+	words := append([]string(nil), cfg.Blocklist...)
+	if cfg.BlocklistFile != "" {
+		data, err := os.ReadFile(cfg.BlocklistFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read blocklist file %s: %w", cfg.BlocklistFile, err)
+		}
+		for _, line := range strings.Split(string(data), "\n") {
+			if line = strings.TrimSpace(line); line != "" && !strings.HasPrefix(line, "#") {
+				words = append(words, line)
+			}
+		}
+	}
+
+	var quoted []string
+	maxLen := 0
+	for _, word := range words {
+		if word = strings.TrimSpace(word); word == "" {
+			continue
+		}
+		quoted = append(quoted, regexp.QuoteMeta(word))
+		maxLen = max(maxLen, utf8.RuneCountInString(word))
+	}
+	if len(quoted) == 0 {
+		return nil, nil
+	}
+
+	// 较长的词优先匹配，避免只屏蔽了较短的前缀
+	sort.Slice(quoted, func(i, j int) bool { return len(quoted[i]) > len(quoted[j]) })
+	pattern, err := regexp.Compile("(?i)" + strings.Join(quoted, "|"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid blocklist: %w", err)
+	}
+
+	switch cfg.Action {
+	case "", "mask", "abort":
+	default:
+		return nil, fmt.Errorf("unknown response filter action: %s", cfg.Action)
+	}
+	return &contentFilter{pattern: pattern, maxLen: maxLen, abort: cfg.Action == "abort"}, nil
+}
+
+// apply 检查文本，命中时按配置替换为等长的*或返回ErrResponseBlocked
+func (f *contentFilter) apply(text string) (string, error) {
+	if !f.pattern.MatchString(text) {
+		return text, nil
+	}
+	if f.abort {
+		return "", ErrResponseBlocked
+	}
+	return f.pattern.ReplaceAllStringFunc(text, func(match string) string {
+		return strings.Repeat("*", utf8.RuneCountInString(match))
+	}), nil
+}
+
+// textScanner 按候选分别扫描流式文本，保留末尾可能被截断的屏蔽词片段
+type textScanner struct {
+	filter *contentFilter
+	held   map[int]string
+}
+
+// newTextScanner 创建流式扫描器
+func (f *contentFilter) newTextScanner() *textScanner {
+	return &textScanner{filter: f, held: make(map[int]string)}
+}
+
+// push 扫描候选的一段文本，返回可以立即输出的部分；final为true时输出全部剩余文本
+func (s *textScanner) push(index int, text string, final bool) (string, error) {
+	filtered, err := s.filter.apply(s.held[index] + text)
+	if err != nil {
+		return "", err
+	}
+	if final {
+		delete(s.held, index)
+		return filtered, nil
+	}
+
+	// 保留末尾 maxLen-1 个字符，跨块的屏蔽词在下一块到达后一起检查
+	cut := len(filtered)
+	for i := 0; i < s.filter.maxLen-1 && cut > 0; i++ {
+		_, size := utf8.DecodeLastRuneInString(filtered[:cut])
+		cut -= size
+	}
+	s.held[index] = filtered[cut:]
+	return filtered[:cut], nil
+}
+
+// filterPayload 过滤响应JSON（兼容Code Assist的 response 包装）中候选的文本部分
+// 带有finishReason的候选输出全部剩余文本；内容未改变时原样返回
+func (s *textScanner) filterPayload(data []byte, final bool) ([]byte, error) {
+	var payload map[string]any
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return data, nil
+	}
+	root := payload
+	if inner, ok := payload["response"].(map[string]any); ok {
+		root = inner
+	}
+	candidates, _ := root["candidates"].([]any)
+
+	changed := false
+	for position, item := range candidates {
+		candidate, ok := item.(map[string]any)
+		if !ok {
+			continue
+		}
+		index := position
+		if value, ok := candidate["index"].(float64); ok {
+			index = int(value)
+		}
+		finished := final || candidate["finishReason"] != nil
+
+		content, _ := candidate["content"].(map[string]any)
+		parts, _ := content["parts"].([]any)
+		last := -1
+		for i, part := range parts {
+			if p, ok := part.(map[string]any); ok && p["text"] != nil {
+				last = i
+			}
+		}
+
+		for i, part := range parts {
+			p, ok := part.(map[string]any)
+			if !ok {
+				continue
+			}
+			text, ok := p["text"].(string)
+			if !ok {
+				continue
+			}
+			filtered, err := s.push(index, text, finished && i == last)
+			if err != nil {
+				return nil, err
+			}
+			if filtered != text {
+				p["text"] = filtered
+				changed = true
+			}
+		}
+
+		// 结束的候选没有文本部分时，补一个部分输出剩余文本
+		if finished && last < 0 && s.held[index] != "" {
+			if content == nil {
+				content = map[string]any{"role": "model"}
+				candidate["content"] = content
+			}
+			content["parts"] = append(parts, map[string]any{"text": s.held[index]})
+			delete(s.held, index)
+			changed = true
+		}
+	}
+
+	if !changed {
+		return data, nil
+	}
+	return json.Marshal(payload)
+}
+
+// pending 返回尚未输出的文本，用于流结束时补发
+func (s *textScanner) pending(codeAssist bool) []byte {
+	if len(s.held) == 0 {
+		return nil
+	}
+	indexes := make([]int, 0, len(s.held))
+	for index := range s.held {
+		indexes = append(indexes, index)
+	}
+	sort.Ints(indexes)
+
+	var candidates []any
+	for _, index := range indexes {
+		if text := s.held[index]; text != "" {
+			candidates = append(candidates, map[string]any{
+				"index":   index,
+				"content": map[string]any{"role": "model", "parts": []any{map[string]any{"text": text}}},
+			})
+		}
+		delete(s.held, index)
+	}
+	if len(candidates) == 0 {
+		return nil
+	}
+
+	var payload any = map[string]any{"candidates": candidates}
+	if codeAssist {
+		payload = map[string]any{"response": payload}
+	}
+	data, _ := json.Marshal(payload)
+	return data
+}
+
+// filterSSEReader 逐行过滤SSE流中 data 行的文本
+type filterSSEReader struct {
+	src        io.ReadCloser
+	reader     *bufio.Reader
+	scanner    *textScanner
+	codeAssist bool // 补发的事件是否需要 response 包装
+	pending    []byte
+	err        error
+}
+
+// sseReader 包装流式响应体，过滤其中的生成文本
+func (f *contentFilter) sseReader(src io.ReadCloser, codeAssist bool) io.ReadCloser {
+	return &filterSSEReader{src: src, reader: bufio.NewReader(src), scanner: f.newTextScanner(), codeAssist: codeAssist}
+}
+
+// Read 读取过滤后的数据
+func (r *filterSSEReader) Read(p []byte) (int, error) {
+	for len(r.pending) == 0 {
+		if r.err != nil {
+			return 0, r.err
+		}
+
+		line, err := r.reader.ReadBytes('\n')
+		if len(line) > 0 {
+			if data, ok := bytes.CutPrefix(line, []byte("data: ")); ok {
+				trimmed := bytes.TrimRight(data, "\r\n")
+				filtered, filterErr := r.scanner.filterPayload(trimmed, false)
+				if filterErr != nil {
+					r.err = filterErr
+					continue
+				}
+				line = append(append([]byte("data: "), filtered...), line[len("data: ")+len(trimmed):]...)
+			}
+			r.pending = line
+		}
+		if err != nil {
+			// 流结束时补发仍保留的文本
+			if err == io.EOF {
+				if data := r.scanner.pending(r.codeAssist); data != nil {
+					r.pending = append(r.pending, []byte("data: "+string(data)+"\r\n\r\n")...)
+				}
+			}
+			r.err = err
+		}
+	}
+
+	n := copy(p, r.pending)
+	r.pending = r.pending[n:]
+	return n, nil
+}
+
+// Close 关闭底层响应体
+func (r *filterSSEReader) Close() error {
+	return r.src.Close()
+}
+
+// filterResponseBody 过滤非流式响应中的生成文本
+func (f *contentFilter) filterResponseBody(body []byte) ([]byte, error) {
+	return f.newTextScanner().filterPayload(body, true)
+}
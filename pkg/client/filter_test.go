@@ -0,0 +1,100 @@
+package client
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ba0gu0/gemini-go-proxy/pkg/config"
+	"github.com/ba0gu0/gemini-go-proxy/pkg/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewContentFilter(t *testing.T) {
+	filter, err := newContentFilter(nil)
+	require.NoError(t, err)
+	assert.Nil(t, filter)
+
+	filter, err = newContentFilter(&config.ResponseFilter{Blocklist: []string{" ", ""}})
+	require.NoError(t, err)
+	assert.Nil(t, filter)
+
+	_, err = newContentFilter(&config.ResponseFilter{Blocklist: []string{"x"}, Action: "drop"})
+	assert.Error(t, err)
+
+	_, err = newContentFilter(&config.ResponseFilter{BlocklistFile: "/non/existent/blocklist.txt"})
+	assert.Error(t, err)
+
+	path := filepath.Join(t.TempDir(), "blocklist.txt")
+	require.NoError(t, os.WriteFile(path, []byte("# comment\ndarn\n\n混蛋\n"), 0600))
+	filter, err = newContentFilter(&config.ResponseFilter{Blocklist: []string{"heck"}, BlocklistFile: path})
+	require.NoError(t, err)
+
+	masked, err := filter.apply("Darn it, what the HECK, 你这个混蛋")
+	require.NoError(t, err)
+	assert.Equal(t, "**** it, what the ****, 你这个**", masked)
+	assert.Equal(t, 4, filter.maxLen)
+}
+
+func TestContentFilter_ResponseBody(t *testing.T) {
+	filter, err := newContentFilter(&config.ResponseFilter{Blocklist: []string{"secret"}})
+	require.NoError(t, err)
+
+	// Code Assist包装的响应，未知字段保留
+	body := `{"response":{"candidates":[{"content":{"parts":[{"text":"the SECRET word"}]},"finishReason":"STOP","groundingMetadata":{"x":1}}]},"traceId":"t"}`
+	filtered, err := filter.filterResponseBody([]byte(body))
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"response":{"candidates":[{"content":{"parts":[{"text":"the ****** word"}]},"finishReason":"STOP","groundingMetadata":{"x":1}}]},"traceId":"t"}`, string(filtered))
+
+	// 未命中时原样返回
+	clean := `{"candidates":[{"content":{"parts":[{"text":"hello"}]}}]}`
+	filtered, err = filter.filterResponseBody([]byte(clean))
+	require.NoError(t, err)
+	assert.Equal(t, clean, string(filtered))
+
+	abort, err := newContentFilter(&config.ResponseFilter{Blocklist: []string{"secret"}, Action: "abort"})
+	require.NoError(t, err)
+	_, err = abort.filterResponseBody([]byte(body))
+	assert.ErrorIs(t, err, ErrResponseBlocked)
+}
+
+func TestContentFilter_SSEReaderSplitWords(t *testing.T) {
+	filter, err := newContentFilter(&config.ResponseFilter{Blocklist: []string{"forbidden"}})
+	require.NoError(t, err)
+
+	// 屏蔽词跨越两个块，最后一块没有finishReason，剩余文本在流结束时补发
+	stream := "data: {\"candidates\":[{\"content\":{\"parts\":[{\"text\":\"this is forb\"}]}}]}\r\n\r\n" +
+		"data: {\"candidates\":[{\"content\":{\"parts\":[{\"text\":\"idden text\"}]}}]}\r\n\r\n"
+	reader := filter.sseReader(io.NopCloser(strings.NewReader(stream)), false)
+	data, err := io.ReadAll(reader)
+	require.NoError(t, err)
+
+	var text strings.Builder
+	for _, line := range strings.Split(string(data), "\n") {
+		if payload, ok := strings.CutPrefix(strings.TrimSpace(line), "data: "); ok {
+			var chunk models.GeminiStreamChunk
+			require.NoError(t, json.Unmarshal([]byte(payload), &chunk))
+			for _, part := range chunk.Candidates[0].Content.Parts {
+				text.WriteString(part.Text)
+			}
+		}
+	}
+	assert.Equal(t, "this is ********* text", text.String())
+	assert.NotContains(t, string(data), "forb")
+
+	// 结束块输出全部剩余文本
+	stream = "data: {\"candidates\":[{\"content\":{\"parts\":[{\"text\":\"a forbidd\"}]}}]}\n\n" +
+		"data: {\"candidates\":[{\"content\":{\"parts\":[{\"text\":\"en b\"}]},\"finishReason\":\"STOP\"}]}\n\n"
+	data, err = io.ReadAll(filter.sseReader(io.NopCloser(strings.NewReader(stream)), false))
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"text":" ********* b"`)
+
+	abort, err := newContentFilter(&config.ResponseFilter{Blocklist: []string{"forbidden"}, Action: "abort"})
+	require.NoError(t, err)
+	_, err = io.ReadAll(abort.sseReader(io.NopCloser(strings.NewReader(stream)), false))
+	assert.ErrorIs(t, err, ErrResponseBlocked)
+}
@@ -0,0 +1,52 @@
+package client
+
+import (
+	"net/http"
+	"strings"
+)
+
+// hopByHopHeaders 仅对单跳连接有效的头部 (RFC 9110 7.6.1)，不能转发
+var hopByHopHeaders = []string{
+	"Connection",
+	"Proxy-Connection",
+	"Keep-Alive",
+	"Proxy-Authenticate",
+	"Proxy-Authorization",
+	"Te",
+	"Trailer",
+	"Transfer-Encoding",
+	"Upgrade",
+}
+
+// clientIdentifyingHeaders 代理凭据和可识别客户端的头部，不转发到上游
+var clientIdentifyingHeaders = []string{
+	"Authorization",
+	"X-API-Key",
+	"X-Goog-Api-Key",
+	"Cookie",
+	"Forwarded",
+	"X-Forwarded-For",
+	"X-Forwarded-Host",
+	"X-Forwarded-Proto",
+	"X-Real-Ip",
+	"Origin",
+	"Referer",
+	"X-Proxy-Debug",
+}
+
+// sanitizeUpstreamHeaders 去除逐跳头部（包括Connection中列出的头部）和客户端标识头部
+func sanitizeUpstreamHeaders(header http.Header) {
+	for _, value := range header.Values("Connection") {
+		for _, name := range strings.Split(value, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				header.Del(name)
+			}
+		}
+	}
+	for _, name := range hopByHopHeaders {
+		header.Del(name)
+	}
+	for _, name := range clientIdentifyingHeaders {
+		header.Del(name)
+	}
+}
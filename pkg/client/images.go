@@ -0,0 +1,231 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ba0gu0/gemini-go-proxy/pkg/config"
+	"github.com/ba0gu0/gemini-go-proxy/pkg/models"
+)
+
+var (
+	// ErrPredictUnsupported Code Assist API不提供predict接口（Imagen模型）
+	ErrPredictUnsupported = errors.New("predict is not supported in code_assist mode")
+	// ErrInvalidImageRequest 图像生成请求参数无效
+	ErrInvalidImageRequest = errors.New("invalid image request")
+	// ErrNoImages 上游没有返回图像（通常是提示词或生成结果被安全过滤）
+	ErrNoImages = errors.New("model returned no images")
+)
+
+// maxImageCount 单次请求最多生成的图像数（Imagen sampleCount上限）
+const maxImageCount = 4
+
+// imageAspectRatios 上游支持的宽高比
+var imageAspectRatios = []struct {
+	name  string
+	ratio float64
+}{
+	{"1:1", 1},
+	{"3:4", 3.0 / 4},
+	{"4:3", 4.0 / 3},
+	{"9:16", 9.0 / 16},
+	{"16:9", 16.0 / 9},
+}
+
+// imagenPredictResponse Imagen predict响应
+type imagenPredictResponse struct {
+	Predictions []struct {
+		BytesBase64Encoded string `json:"bytesBase64Encoded"`
+		MimeType           string `json:"mimeType"`
+		Prompt             string `json:"prompt"` // 启用提示词改写时的改写结果
+		RAIFilteredReason  string `json:"raiFilteredReason"`
+	} `json:"predictions"`
+}
+
+// generatedImage 生成的单张图像
+type generatedImage struct {
+	data          string // base64编码
+	mimeType      string
+	revisedPrompt string
+}
+
+// GenerateImages 按OpenAI图像生成请求生成图像：imagen 系列模型调用 predict 接口，
+// 其他模型（如 gemini-2.5-flash-image）以 responseModalities 请求图像输出
+func (c *GeminiClient) GenerateImages(ctx context.Context, req *models.OpenAIImageRequest) (*models.OpenAIImageResponse, error) {
+	if strings.TrimSpace(req.Prompt) == "" {
+		return nil, fmt.Errorf("%w: prompt is required", ErrInvalidImageRequest)
+	}
+	n := 1
+	if req.N != nil {
+		n = *req.N
+	}
+	if n < 1 || n > maxImageCount {
+		return nil, fmt.Errorf("%w: n must be between 1 and %d", ErrInvalidImageRequest, maxImageCount)
+	}
+	if req.ResponseFormat != "" && req.ResponseFormat != "b64_json" && req.ResponseFormat != "url" {
+		return nil, fmt.Errorf("%w: unsupported response_format %q", ErrInvalidImageRequest, req.ResponseFormat)
+	}
+	aspectRatio, err := imageAspectRatio(req.Size)
+	if err != nil {
+		return nil, err
+	}
+
+	var images []generatedImage
+	if isImagenModel(req.Model) {
+		images, err = c.imagenGenerate(ctx, req.Model, req.Prompt, n, aspectRatio)
+	} else {
+		images, err = c.geminiGenerateImages(ctx, req.Model, req.Prompt, n, aspectRatio)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &models.OpenAIImageResponse{Created: time.Now().Unix(), Data: make([]models.OpenAIImage, len(images))}
+	for i, image := range images {
+		resp.Data[i].RevisedPrompt = image.revisedPrompt
+		if req.ResponseFormat == "url" {
+			resp.Data[i].URL = fmt.Sprintf("data:%s;base64,%s", image.mimeType, image.data)
+		} else {
+			resp.Data[i].B64JSON = image.data
+		}
+	}
+	return resp, nil
+}
+
+// SendPredictRequest 将原生predict请求体原样发送到模型的 predict 接口并返回原始响应
+func (c *GeminiClient) SendPredictRequest(ctx context.Context, modelID string, body json.RawMessage) (json.RawMessage, error) {
+	if c.apiMode() == config.CodeAssist {
+		return nil, ErrPredictUnsupported
+	}
+
+	apiURL, err := c.buildAPIURL(ctx, modelID, "predict")
+	if err != nil {
+		return nil, err
+	}
+	var resp json.RawMessage
+	if err := c.postJSON(ctx, "predict", apiURL, body, &resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// imagenGenerate 调用Imagen模型的 predict 接口
+func (c *GeminiClient) imagenGenerate(ctx context.Context, modelID, prompt string, n int, aspectRatio string) ([]generatedImage, error) {
+	if c.apiMode() == config.CodeAssist {
+		return nil, ErrPredictUnsupported
+	}
+
+	parameters := map[string]any{"sampleCount": n}
+	if aspectRatio != "" {
+		parameters["aspectRatio"] = aspectRatio
+	}
+	body := map[string]any{
+		"instances":  []map[string]string{{"prompt": prompt}},
+		"parameters": parameters,
+	}
+
+	apiURL, err := c.buildAPIURL(ctx, modelID, "predict")
+	if err != nil {
+		return nil, err
+	}
+	var resp imagenPredictResponse
+	if err := c.postJSON(ctx, "predict", apiURL, body, &resp); err != nil {
+		return nil, err
+	}
+
+	var images []generatedImage
+	var filtered string
+	for _, prediction := range resp.Predictions {
+		if prediction.BytesBase64Encoded == "" {
+			if prediction.RAIFilteredReason != "" {
+				filtered = prediction.RAIFilteredReason
+			}
+			continue
+		}
+		mimeType := prediction.MimeType
+		if mimeType == "" {
+			mimeType = "image/png"
+		}
+		images = append(images, generatedImage{
+			data:          prediction.BytesBase64Encoded,
+			mimeType:      mimeType,
+			revisedPrompt: prediction.Prompt,
+		})
+	}
+	if len(images) == 0 {
+		if filtered != "" {
+			return nil, fmt.Errorf("%w: %s", ErrNoImages, filtered)
+		}
+		return nil, ErrNoImages
+	}
+	return images, nil
+}
+
+// geminiGenerateImages 以图像输出模式调用生成接口，每张图像发送一次请求
+func (c *GeminiClient) geminiGenerateImages(ctx context.Context, modelID, prompt string, n int, aspectRatio string) ([]generatedImage, error) {
+	extra := map[string]any{"responseModalities": []string{"TEXT", "IMAGE"}}
+	if aspectRatio != "" {
+		extra["generationConfig"] = map[string]any{"imageConfig": map[string]any{"aspectRatio": aspectRatio}}
+	}
+
+	var images []generatedImage
+	for len(images) < n {
+		req := &models.GeminiRequest{
+			Contents: []models.GeminiContent{{Role: "user", Parts: []models.GeminiPart{{Text: prompt}}}},
+			Extra:    extra,
+		}
+		resp, err := c.SendRequest(ctx, modelID, req)
+		if err != nil {
+			return nil, err
+		}
+
+		found := false
+		for _, candidate := range resp.Candidates {
+			for _, part := range candidate.Content.Parts {
+				if part.InlineData == nil || !strings.HasPrefix(part.InlineData.MimeType, "image/") {
+					continue
+				}
+				images = append(images, generatedImage{data: part.InlineData.Data, mimeType: part.InlineData.MimeType})
+				found = true
+			}
+		}
+		if !found {
+			return nil, ErrNoImages
+		}
+	}
+	return images[:n], nil
+}
+
+// isImagenModel 判断是否为使用 predict 接口的Imagen模型
+func isImagenModel(modelID string) bool {
+	return strings.HasPrefix(modelID[strings.LastIndex(modelID, "/")+1:], "imagen")
+}
+
+// imageAspectRatio 将OpenAI的 size（如 "1792x1024"）映射为最接近的上游宽高比，空值和 "auto" 使用模型默认
+func imageAspectRatio(size string) (string, error) {
+	if size == "" || size == "auto" {
+		return "", nil
+	}
+	width, height, ok := strings.Cut(size, "x")
+	w, errW := strconv.Atoi(width)
+	h, errH := strconv.Atoi(height)
+	if !ok || errW != nil || errH != nil || w <= 0 || h <= 0 {
+		return "", fmt.Errorf("%w: invalid size %q", ErrInvalidImageRequest, size)
+	}
+
+	target := math.Log(float64(w) / float64(h))
+	best := imageAspectRatios[0].name
+	bestDistance := math.Inf(1)
+	for _, candidate := range imageAspectRatios {
+		if distance := math.Abs(math.Log(candidate.ratio) - target); distance < bestDistance {
+			best, bestDistance = candidate.name, distance
+		}
+	}
+	return best, nil
+}
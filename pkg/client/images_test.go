@@ -0,0 +1,93 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/ba0gu0/gemini-go-proxy/pkg/config"
+	"github.com/ba0gu0/gemini-go-proxy/pkg/models"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestImageAspectRatio(t *testing.T) {
+	tests := map[string]string{
+		"":          "",
+		"auto":      "",
+		"1024x1024": "1:1",
+		"1792x1024": "16:9",
+		"1024x1792": "9:16",
+		"1536x1024": "4:3",
+		"768x1024":  "3:4",
+	}
+	for size, want := range tests {
+		got, err := imageAspectRatio(size)
+		require.NoError(t, err, size)
+		assert.Equal(t, want, got, size)
+	}
+
+	for _, size := range []string{"large", "0x100", "100x", "x100"} {
+		_, err := imageAspectRatio(size)
+		assert.ErrorIs(t, err, ErrInvalidImageRequest, size)
+	}
+}
+
+func TestGeminiClient_GenerateImages_Imagen(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.APIMode = config.AIStudio
+	client := NewGeminiClient(cfg, nil, logrus.New())
+
+	client.client.Transport = roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		assert.True(t, strings.HasSuffix(req.URL.Path, "/models/imagen-3.0-generate-002:predict"))
+		var predict struct {
+			Instances  []map[string]string `json:"instances"`
+			Parameters map[string]any      `json:"parameters"`
+		}
+		require.NoError(t, json.NewDecoder(req.Body).Decode(&predict))
+		assert.Equal(t, "a cat", predict.Instances[0]["prompt"])
+		assert.Equal(t, map[string]any{"sampleCount": float64(2), "aspectRatio": "16:9"}, predict.Parameters)
+
+		// 被过滤的图像只有 raiFilteredReason
+		body := `{"predictions":[{"bytesBase64Encoded":"aW1n","mimeType":"image/png"},{"raiFilteredReason":"filtered"}]}`
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(body))}, nil
+	})
+
+	n := 2
+	resp, err := client.GenerateImages(context.Background(), &models.OpenAIImageRequest{
+		Model:          "imagen-3.0-generate-002",
+		Prompt:         "a cat",
+		N:              &n,
+		Size:           "1792x1024",
+		ResponseFormat: "url",
+	})
+	require.NoError(t, err)
+	require.Len(t, resp.Data, 1)
+	assert.Equal(t, "data:image/png;base64,aW1n", resp.Data[0].URL)
+	assert.Empty(t, resp.Data[0].B64JSON)
+}
+
+func TestGeminiClient_GenerateImages_Errors(t *testing.T) {
+	client := NewGeminiClient(config.DefaultConfig(), nil, logrus.New())
+	ctx := context.Background()
+
+	_, err := client.GenerateImages(ctx, &models.OpenAIImageRequest{Model: "imagen-3.0-generate-002", Prompt: "x"})
+	assert.ErrorIs(t, err, ErrPredictUnsupported)
+	_, err = client.SendPredictRequest(ctx, "imagen-3.0-generate-002", json.RawMessage(`{}`))
+	assert.ErrorIs(t, err, ErrPredictUnsupported)
+
+	n := 5
+	for _, req := range []models.OpenAIImageRequest{
+		{Model: "m"},
+		{Model: "m", Prompt: "x", N: &n},
+		{Model: "m", Prompt: "x", ResponseFormat: "png"},
+		{Model: "m", Prompt: "x", Size: "big"},
+	} {
+		_, err = client.GenerateImages(ctx, &req)
+		assert.ErrorIs(t, err, ErrInvalidImageRequest)
+	}
+}
@@ -0,0 +1,294 @@
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"slices"
+
+	"github.com/ba0gu0/gemini-go-proxy/pkg/models"
+)
+
+// ErrRequestRejected 请求被拦截器拒绝
+var ErrRequestRejected = errors.New("request rejected by interceptor")
+
+// Interceptor 请求/响应拦截器，用于内容过滤、脱敏、自定义日志等。
+// 各方法可以原地修改参数；OnRequest 返回错误时拒绝请求，
+// OnResponse/OnStreamChunk 返回错误时按内容过滤中止响应处理。
+// 原生路由的反向代理同样调用拦截器；嵌入请求以各条输入作为 Contents 调用 OnRequest，不调用响应方法
+type Interceptor interface {
+	// OnRequest 在请求发往上游前调用，此时已应用系统提示词和回复语言
+	OnRequest(ctx context.Context, modelID string, req *models.GeminiRequest) error
+	// OnResponse 在收到非流式响应后调用
+	OnResponse(ctx context.Context, modelID string, resp *models.GeminiResponse) error
+	// OnStreamChunk 在流式响应的每个数据块发给调用方前调用
+	OnStreamChunk(ctx context.Context, modelID string, chunk *models.GeminiStreamChunk) error
+}
+
+// InterceptorFuncs 以函数实现 Interceptor，未设置的函数不做处理
+type InterceptorFuncs struct {
+	Request     func(ctx context.Context, modelID string, req *models.GeminiRequest) error
+	Response    func(ctx context.Context, modelID string, resp *models.GeminiResponse) error
+	StreamChunk func(ctx context.Context, modelID string, chunk *models.GeminiStreamChunk) error
+}
+
+// OnRequest 调用 Request
+func (f InterceptorFuncs) OnRequest(ctx context.Context, modelID string, req *models.GeminiRequest) error {
+	if f.Request == nil {
+		return nil
+	}
+	return f.Request(ctx, modelID, req)
+}
+
+// OnResponse 调用 Response
+func (f InterceptorFuncs) OnResponse(ctx context.Context, modelID string, resp *models.GeminiResponse) error {
+	if f.Response == nil {
+		return nil
+	}
+	return f.Response(ctx, modelID, resp)
+}
+
+// OnStreamChunk 调用 StreamChunk
+func (f InterceptorFuncs) OnStreamChunk(ctx context.Context, modelID string, chunk *models.GeminiStreamChunk) error {
+	if f.StreamChunk == nil {
+		return nil
+	}
+	return f.StreamChunk(ctx, modelID, chunk)
+}
+
+// AddInterceptor 添加拦截器，按添加顺序调用；可在运行时添加，对之后的请求生效
+func (c *GeminiClient) AddInterceptor(interceptor Interceptor) {
+	c.interceptorMu.Lock()
+	defer c.interceptorMu.Unlock()
+	c.interceptors = append(c.interceptors[:len(c.interceptors):len(c.interceptors)], interceptor)
+}
+
+// interceptorList 返回当前的拦截器列表
+func (c *GeminiClient) interceptorList() []Interceptor {
+	c.interceptorMu.RLock()
+	defer c.interceptorMu.RUnlock()
+	return c.interceptors
+}
+
+// interceptRequest 依次调用拦截器的 OnRequest
+func (c *GeminiClient) interceptRequest(ctx context.Context, modelID string, req *models.GeminiRequest) error {
+	for _, interceptor := range c.interceptorList() {
+		if err := interceptor.OnRequest(ctx, modelID, req); err != nil {
+			return fmt.Errorf("%w: %v", ErrRequestRejected, err)
+		}
+	}
+	return nil
+}
+
+// interceptRequestBody 对请求JSON调用拦截器的 OnRequest，只替换被修改的字段，保留未建模的字段
+func (c *GeminiClient) interceptRequestBody(ctx context.Context, modelID string, body []byte) ([]byte, error) {
+	if len(c.interceptorList()) == 0 {
+		return body, nil
+	}
+	return interceptPayload(body, func(req *models.GeminiRequest) error {
+		return c.interceptRequest(ctx, modelID, req)
+	})
+}
+
+// interceptEmbeddingRequest 以各条输入作为 Contents 调用拦截器的 OnRequest，返回应用修改后的请求副本
+func (c *GeminiClient) interceptEmbeddingRequest(ctx context.Context, modelID string, req *models.GeminiBatchEmbedContentsRequest) (*models.GeminiBatchEmbedContentsRequest, error) {
+	if len(c.interceptorList()) == 0 {
+		return req, nil
+	}
+
+	contents := &models.GeminiRequest{Contents: make([]models.GeminiContent, len(req.Requests))}
+	for i, request := range req.Requests {
+		contents.Contents[i] = models.GeminiContent{Role: request.Content.Role, Parts: slices.Clone(request.Content.Parts)}
+	}
+	if err := c.interceptRequest(ctx, modelID, contents); err != nil {
+		return nil, err
+	}
+	if len(contents.Contents) != len(req.Requests) {
+		return nil, fmt.Errorf("%w: interceptor changed the number of embedding inputs", ErrRequestRejected)
+	}
+
+	intercepted := &models.GeminiBatchEmbedContentsRequest{Requests: slices.Clone(req.Requests)}
+	for i := range intercepted.Requests {
+		intercepted.Requests[i].Content = contents.Contents[i]
+	}
+	return intercepted, nil
+}
+
+// interceptResponseBody 对非流式响应体（兼容Code Assist的 response 包装）调用拦截器的 OnResponse
+func (c *GeminiClient) interceptResponseBody(ctx context.Context, modelID string, body []byte) ([]byte, error) {
+	interceptors := c.interceptorList()
+	if len(interceptors) == 0 {
+		return body, nil
+	}
+	return interceptPayload(body, func(resp *models.GeminiResponse) error {
+		for _, interceptor := range interceptors {
+			if err := interceptor.OnResponse(ctx, modelID, resp); err != nil {
+				return fmt.Errorf("%w: %v", ErrResponseBlocked, err)
+			}
+		}
+		return nil
+	})
+}
+
+// interceptSSEReader 包装流式响应体，对每个 data 行调用拦截器的 OnStreamChunk，未添加拦截器时原样返回
+func (c *GeminiClient) interceptSSEReader(ctx context.Context, modelID string, src io.ReadCloser) io.ReadCloser {
+	interceptors := c.interceptorList()
+	if len(interceptors) == 0 {
+		return src
+	}
+	return &interceptSSEReader{src: src, reader: bufio.NewReader(src), intercept: func(data []byte) ([]byte, error) {
+		return interceptPayload(data, func(chunk *models.GeminiStreamChunk) error {
+			for _, interceptor := range interceptors {
+				if err := interceptor.OnStreamChunk(ctx, modelID, chunk); err != nil {
+					return fmt.Errorf("%w: %v", ErrResponseBlocked, err)
+				}
+			}
+			return nil
+		})
+	}}
+}
+
+// interceptSSEReader 逐行处理SSE流中的 data 行
+type interceptSSEReader struct {
+	src       io.ReadCloser
+	reader    *bufio.Reader
+	intercept func(data []byte) ([]byte, error)
+	pending   []byte
+	err       error
+}
+
+// Read 读取拦截器处理后的数据
+func (r *interceptSSEReader) Read(p []byte) (int, error) {
+	for len(r.pending) == 0 {
+		if r.err != nil {
+			return 0, r.err
+		}
+
+		line, err := r.reader.ReadBytes('\n')
+		if len(line) > 0 {
+			if data, ok := bytes.CutPrefix(line, []byte("data: ")); ok {
+				trimmed := bytes.TrimRight(data, "\r\n")
+				intercepted, interceptErr := r.intercept(trimmed)
+				if interceptErr != nil {
+					r.err = interceptErr
+					continue
+				}
+				line = append(append([]byte("data: "), intercepted...), line[len("data: ")+len(trimmed):]...)
+			}
+			r.pending = line
+		}
+		if err != nil {
+			r.err = err
+		}
+	}
+
+	n := copy(p, r.pending)
+	r.pending = r.pending[n:]
+	return n, nil
+}
+
+// Close 关闭底层响应体
+func (r *interceptSSEReader) Close() error {
+	return r.src.Close()
+}
+
+// interceptPayload 将响应JSON（兼容Code Assist的 response 包装）解析为T后调用hook；
+// 内容未改变时原样返回，改变时只替换被修改的字段，保留T中没有建模的字段
+func interceptPayload[T any](data []byte, hook func(*T) error) ([]byte, error) {
+	var payload map[string]any
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return data, nil
+	}
+	root := payload
+	inner, wrapped := payload["response"].(map[string]any)
+	if wrapped {
+		root = inner
+	}
+
+	rootJSON, err := json.Marshal(root)
+	if err != nil {
+		return data, nil
+	}
+	var typed T
+	if err := json.Unmarshal(rootJSON, &typed); err != nil {
+		return data, nil
+	}
+	before, err := toGenericJSON(&typed)
+	if err != nil {
+		return data, nil
+	}
+	if err := hook(&typed); err != nil {
+		return nil, err
+	}
+	after, err := toGenericJSON(&typed)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal intercepted payload: %w", err)
+	}
+	if reflect.DeepEqual(before, after) {
+		return data, nil
+	}
+
+	merged := mergeJSONChanges(root, before, after)
+	if wrapped {
+		payload["response"] = merged
+		return json.Marshal(payload)
+	}
+	return json.Marshal(merged)
+}
+
+// toGenericJSON 将值转换为 map[string]any / []any 表示
+func toGenericJSON(v any) (any, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var generic any
+	err = json.Unmarshal(data, &generic)
+	return generic, err
+}
+
+// mergeJSONChanges 将before到after的修改应用到original上：对象按字段、等长数组按元素递归合并，
+// 未修改的部分保留original中的值（包括未建模的字段）
+func mergeJSONChanges(original, before, after any) any {
+	if reflect.DeepEqual(before, after) {
+		return original
+	}
+
+	switch afterValue := after.(type) {
+	case map[string]any:
+		originalMap, ok1 := original.(map[string]any)
+		beforeMap, ok2 := before.(map[string]any)
+		if !ok1 || !ok2 {
+			return after
+		}
+		for key, value := range afterValue {
+			if originalValue, ok := originalMap[key]; ok {
+				originalMap[key] = mergeJSONChanges(originalValue, beforeMap[key], value)
+			} else {
+				originalMap[key] = value
+			}
+		}
+		for key := range beforeMap {
+			if _, ok := afterValue[key]; !ok {
+				delete(originalMap, key)
+			}
+		}
+		return originalMap
+	case []any:
+		originalSlice, ok1 := original.([]any)
+		beforeSlice, ok2 := before.([]any)
+		if !ok1 || !ok2 || len(originalSlice) != len(afterValue) || len(beforeSlice) != len(afterValue) {
+			return after
+		}
+		for i := range afterValue {
+			originalSlice[i] = mergeJSONChanges(originalSlice[i], beforeSlice[i], afterValue[i])
+		}
+		return originalSlice
+	}
+	return after
+}
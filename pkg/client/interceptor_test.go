@@ -0,0 +1,199 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ba0gu0/gemini-go-proxy/pkg/config"
+	"github.com/ba0gu0/gemini-go-proxy/pkg/models"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// redactInterceptor 将请求和响应中的邮箱地址替换为 [email]
+func redactInterceptor() InterceptorFuncs {
+	redact := func(parts []models.GeminiPart) {
+		for i := range parts {
+			parts[i].Text = strings.ReplaceAll(parts[i].Text, "bob@example.com", "[email]")
+		}
+	}
+	return InterceptorFuncs{
+		Request: func(ctx context.Context, modelID string, req *models.GeminiRequest) error {
+			for i := range req.Contents {
+				redact(req.Contents[i].Parts)
+			}
+			return nil
+		},
+		Response: func(ctx context.Context, modelID string, resp *models.GeminiResponse) error {
+			for i := range resp.Candidates {
+				redact(resp.Candidates[i].Content.Parts)
+			}
+			return nil
+		},
+		StreamChunk: func(ctx context.Context, modelID string, chunk *models.GeminiStreamChunk) error {
+			for i := range chunk.Candidates {
+				redact(chunk.Candidates[i].Content.Parts)
+			}
+			return nil
+		},
+	}
+}
+
+func TestGeminiClient_Interceptor(t *testing.T) {
+	upstream := `{"response":{"candidates":[{"content":{"parts":[{"text":"mail bob@example.com"}]},"groundingMetadata":{"webSearchQueries":["q"]}}]},"traceId":"abc"}`
+
+	var sent string
+	client := NewGeminiClient(config.DefaultConfig(), nil, logrus.New())
+	client.client.Transport = roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		body, _ := io.ReadAll(req.Body)
+		sent = string(body)
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": []string{"application/json"}},
+			Body:       io.NopCloser(strings.NewReader(upstream)),
+		}, nil
+	})
+	client.AddInterceptor(redactInterceptor())
+
+	req := &models.GeminiRequest{Contents: []models.GeminiContent{{Role: "user", Parts: []models.GeminiPart{{Text: "write to bob@example.com"}}}}}
+	raw, err := client.SendRequestRaw(context.Background(), "gemini-2.5-flash", req)
+	require.NoError(t, err)
+	assert.Contains(t, sent, "write to [email]")
+	assert.NotContains(t, sent, "bob@example.com")
+	// 只替换被修改的字段，未建模的字段保留
+	assert.JSONEq(t, strings.ReplaceAll(upstream, "bob@example.com", "[email]"), string(raw))
+
+	// 拦截器拒绝请求时不发往上游
+	sent = ""
+	client.AddInterceptor(InterceptorFuncs{Request: func(ctx context.Context, modelID string, req *models.GeminiRequest) error {
+		return errors.New("contains secrets")
+	}})
+	_, err = client.SendRequestRaw(context.Background(), "gemini-2.5-flash", req)
+	assert.ErrorIs(t, err, ErrRequestRejected)
+	assert.Contains(t, err.Error(), "contains secrets")
+	assert.Empty(t, sent)
+}
+
+func TestGeminiClient_InterceptorStream(t *testing.T) {
+	stream := "data: {\"candidates\":[{\"content\":{\"parts\":[{\"text\":\"bob@example.com\"}]}}]}\r\n\r\n" +
+		"data: {\"candidates\":[{\"content\":{\"parts\":[{\"text\":\" done\"}]},\"finishReason\":\"STOP\"}]}\r\n\r\n"
+
+	cfg := config.DefaultConfig()
+	cfg.APIMode = config.AIStudio
+	client := NewGeminiClient(cfg, nil, logrus.New())
+	client.client.Transport = roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": []string{"text/event-stream"}},
+			Body:       io.NopCloser(strings.NewReader(stream)),
+		}, nil
+	})
+	client.AddInterceptor(redactInterceptor())
+
+	req := &models.GeminiRequest{Contents: []models.GeminiContent{{Role: "user", Parts: []models.GeminiPart{{Text: "hi"}}}}}
+	var text strings.Builder
+	err := client.SendStreamRequest(context.Background(), "gemini-2.5-flash", req, func(chunk *models.GeminiStreamChunk) error {
+		for _, candidate := range chunk.Candidates {
+			for _, part := range candidate.Content.Parts {
+				text.WriteString(part.Text)
+			}
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "[email] done", text.String())
+
+	// 数据块拦截器返回错误时中止流
+	client.AddInterceptor(InterceptorFuncs{StreamChunk: func(ctx context.Context, modelID string, chunk *models.GeminiStreamChunk) error {
+		return errors.New("policy violation")
+	}})
+	err = client.SendStreamRequest(context.Background(), "gemini-2.5-flash", req, func(chunk *models.GeminiStreamChunk) error { return nil })
+	assert.ErrorIs(t, err, ErrResponseBlocked)
+}
+
+func TestNativeReverseProxy_Interceptor(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.APIMode = config.AIStudio
+	client := NewGeminiClient(cfg, nil, logrus.New())
+	var sent string
+	client.client.Transport = roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		body, _ := io.ReadAll(req.Body)
+		sent = string(body)
+		if strings.HasSuffix(req.URL.Path, ":streamGenerateContent") {
+			stream := "data: {\"candidates\":[{\"content\":{\"parts\":[{\"text\":\"bob@example.com\"}]}}]}\n\n"
+			return &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: io.NopCloser(strings.NewReader(stream)), Request: req}, nil
+		}
+		resp := `{"candidates":[{"content":{"parts":[{"text":"mail bob@example.com"}]}}],"modelVersion":"x"}`
+		return &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: io.NopCloser(strings.NewReader(resp)), Request: req}, nil
+	})
+	client.AddInterceptor(redactInterceptor())
+
+	proxy := client.NativeReverseProxy()
+	serve := func(stream bool) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/v1beta/models/gemini-2.5-flash:generateContent",
+			strings.NewReader(`{"contents":[{"role":"user","parts":[{"text":"write to bob@example.com"}]}],"cachedContent":"c1"}`))
+		req = req.WithContext(WithNativeRoute(context.Background(), "gemini-2.5-flash", stream))
+		rec := httptest.NewRecorder()
+		proxy.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := serve(false)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	// 请求和响应都经过拦截器，未建模的字段保留
+	assert.JSONEq(t, `{"contents":[{"role":"user","parts":[{"text":"write to [email]"}]}],"cachedContent":"c1"}`, sent)
+	assert.JSONEq(t, `{"candidates":[{"content":{"parts":[{"text":"mail [email]"}]}}],"modelVersion":"x"}`, rec.Body.String())
+
+	rec = serve(true)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), "[email]")
+	assert.NotContains(t, rec.Body.String(), "bob@example.com")
+
+	// 拦截器拒绝请求时不发往上游
+	sent = ""
+	client.AddInterceptor(InterceptorFuncs{Request: func(ctx context.Context, modelID string, req *models.GeminiRequest) error {
+		return errors.New("contains secrets")
+	}})
+	rec = serve(false)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), "contains secrets")
+	assert.Empty(t, sent)
+}
+
+func TestGeminiClient_EmbeddingInterceptor(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.APIMode = config.AIStudio
+	client := NewGeminiClient(cfg, nil, logrus.New())
+	var sent models.GeminiBatchEmbedContentsRequest
+	client.client.Transport = roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		require.NoError(t, json.NewDecoder(req.Body).Decode(&sent))
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(`{"embeddings":[{"values":[1]},{"values":[2]}]}`))}, nil
+	})
+	client.AddInterceptor(redactInterceptor())
+
+	req := &models.GeminiBatchEmbedContentsRequest{Requests: []models.GeminiEmbedContentRequest{
+		{Content: models.GeminiContent{Parts: []models.GeminiPart{{Text: "bob@example.com"}}}, TaskType: "RETRIEVAL_QUERY"},
+		{Content: models.GeminiContent{Parts: []models.GeminiPart{{Text: "hello"}}}},
+	}}
+	_, _, err := client.SendEmbeddingRequest(context.Background(), "text-embedding-004", req)
+	require.NoError(t, err)
+	require.Len(t, sent.Requests, 2)
+	assert.Equal(t, "[email]", sent.Requests[0].Content.Parts[0].Text)
+	assert.Equal(t, "RETRIEVAL_QUERY", sent.Requests[0].TaskType)
+	assert.Equal(t, "hello", sent.Requests[1].Content.Parts[0].Text)
+	// 调用方的请求不被修改
+	assert.Equal(t, "bob@example.com", req.Requests[0].Content.Parts[0].Text)
+
+	client.AddInterceptor(InterceptorFuncs{Request: func(ctx context.Context, modelID string, req *models.GeminiRequest) error {
+		return errors.New("contains secrets")
+	}})
+	_, _, err = client.SendEmbeddingRequest(context.Background(), "text-embedding-004", req)
+	assert.ErrorIs(t, err, ErrRequestRejected)
+}
@@ -0,0 +1,65 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/ba0gu0/gemini-go-proxy/pkg/models"
+)
+
+// languageNames 常用语言标签对应的名称，使指令对模型更明确
+var languageNames = map[string]string{
+	"zh-cn": "Simplified Chinese",
+	"zh-tw": "Traditional Chinese",
+	"en-us": "American English",
+	"en-gb": "British English",
+	"ja-jp": "Japanese",
+	"ko-kr": "Korean",
+	"fr-fr": "French",
+	"de-de": "German",
+	"es-es": "Spanish",
+	"ru-ru": "Russian",
+}
+
+type responseLanguageKey struct{}
+
+// WithResponseLanguage 返回指定回复语言的上下文，优先于全局 response_language 配置
+func WithResponseLanguage(ctx context.Context, language string) context.Context {
+	return context.WithValue(ctx, responseLanguageKey{}, language)
+}
+
+// responseLanguage 获取请求应使用的回复语言，上下文中的设置优先
+func (c *GeminiClient) responseLanguage(ctx context.Context) string {
+	if language, ok := ctx.Value(responseLanguageKey{}).(string); ok && language != "" {
+		return language
+	}
+	return c.config.ResponseLanguage
+}
+
+// languageDirective 生成回复语言指令
+func languageDirective(language string) string {
+	name := language
+	if known, ok := languageNames[strings.ToLower(language)]; ok {
+		name = fmt.Sprintf("%s (%s)", known, language)
+	}
+	return fmt.Sprintf("Always respond in %s, regardless of the language used in the conversation or in earlier instructions.", name)
+}
+
+// applyResponseLanguage 在系统指令末尾追加回复语言指令（已追加时不重复添加）
+func (c *GeminiClient) applyResponseLanguage(ctx context.Context, req *models.GeminiRequest) {
+	language := c.responseLanguage(ctx)
+	if language == "" {
+		return
+	}
+
+	directive := languageDirective(language)
+	if req.SystemInstruction == nil {
+		req.SystemInstruction = &models.GeminiSystemInstruction{}
+	}
+	parts := req.SystemInstruction.Parts
+	if len(parts) > 0 && parts[len(parts)-1].Text == directive {
+		return
+	}
+	req.SystemInstruction.Parts = append(parts, models.GeminiPart{Text: directive})
+}
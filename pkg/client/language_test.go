@@ -0,0 +1,36 @@
+package client
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ba0gu0/gemini-go-proxy/pkg/config"
+	"github.com/ba0gu0/gemini-go-proxy/pkg/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGeminiClient_ApplyResponseLanguage(t *testing.T) {
+	cfg := config.DefaultConfig()
+	client := NewGeminiClient(cfg, nil, nil)
+
+	// 未配置时不修改请求
+	req := &models.GeminiRequest{}
+	client.applyResponseLanguage(context.Background(), req)
+	assert.Nil(t, req.SystemInstruction)
+
+	// 全局设置追加在已有系统指令之后，重复应用时不重复追加
+	cfg.ResponseLanguage = "zh-CN"
+	req.SystemInstruction = &models.GeminiSystemInstruction{Parts: []models.GeminiPart{{Text: "You are helpful."}}}
+	client.applyResponseLanguage(context.Background(), req)
+	client.applyResponseLanguage(context.Background(), req)
+	require.Len(t, req.SystemInstruction.Parts, 2)
+	assert.Equal(t, "You are helpful.", req.SystemInstruction.Parts[0].Text)
+	assert.Contains(t, req.SystemInstruction.Parts[1].Text, "Simplified Chinese (zh-CN)")
+
+	// 上下文中的设置优先于全局设置
+	req = &models.GeminiRequest{}
+	client.applyResponseLanguage(WithResponseLanguage(context.Background(), "pt-BR"), req)
+	require.Len(t, req.SystemInstruction.Parts, 1)
+	assert.Contains(t, req.SystemInstruction.Parts[0].Text, "Always respond in pt-BR")
+}
@@ -0,0 +1,285 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ba0gu0/gemini-go-proxy/pkg/config"
+	"github.com/ba0gu0/gemini-go-proxy/pkg/models"
+)
+
+// modelsPageSize 分页获取模型列表时每页的数量
+const modelsPageSize = 1000
+
+// ErrModelNotFound 模型不在模型目录中
+var ErrModelNotFound = errors.New("model not found")
+
+// defaultModelIDs 上游不提供模型列表时使用的默认模型
+var defaultModelIDs = []string{
+	"gemini-2.5-pro", "gemini-2.5-flash", "gemini-1.5-pro", "gemini-1.5-flash", "gemini-pro", "gemini-pro-vision",
+}
+
+// defaultSupportedMethods 无法从上游获得支持的方法时，生成模型默认支持的方法
+var defaultSupportedMethods = []string{"generateContent", "countTokens"}
+
+// modelsCache 按API模式缓存的模型目录
+type modelsCache struct {
+	mu      sync.Mutex
+	entries map[config.APIMode]*modelsCacheEntry
+}
+
+// modelsCacheEntry 一个API模式的模型目录
+type modelsCacheEntry struct {
+	catalog  *models.GeminiModelsResponse
+	openai   *models.OpenAIModelsResponse
+	cachedAt time.Time
+}
+
+// get 返回未过期的缓存
+func (m *modelsCache) get(mode config.APIMode, ttl time.Duration) *modelsCacheEntry {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	entry := m.entries[mode]
+	if entry == nil || time.Since(entry.cachedAt) > ttl {
+		return nil
+	}
+	return entry
+}
+
+// set 更新缓存
+func (m *modelsCache) set(mode config.APIMode, entry *modelsCacheEntry) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.entries == nil {
+		m.entries = make(map[config.APIMode]*modelsCacheEntry)
+	}
+	entry.cachedAt = time.Now()
+	m.entries[mode] = entry
+}
+
+// ListModels 获取模型列表 (OpenAI格式)，结果按API模式缓存 models_cache_ttl_seconds
+func (c *GeminiClient) ListModels(ctx context.Context) (*models.OpenAIModelsResponse, error) {
+	entry, err := c.modelCatalog(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return entry.openai, nil
+}
+
+// ListGeminiModels 获取模型列表 (Gemini原生格式)，包含token上限和支持的方法，与 ListModels 共用缓存
+func (c *GeminiClient) ListGeminiModels(ctx context.Context) (*models.GeminiModelsResponse, error) {
+	entry, err := c.modelCatalog(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return entry.catalog, nil
+}
+
+// GetGeminiModel 获取单个模型的信息 (Gemini原生格式)：优先使用模型目录，
+// 流水线返回稳定版本主模型的信息，目录之外的已知模型按内置能力表生成
+func (c *GeminiClient) GetGeminiModel(ctx context.Context, modelID string) (*models.GeminiModel, error) {
+	modelID = strings.TrimPrefix(modelID, "models/")
+	target := c.pipelineModel(modelID)
+
+	catalog, err := c.ListGeminiModels(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var model models.GeminiModel
+	found := false
+	for _, candidate := range catalog.Models {
+		if strings.TrimPrefix(candidate.Name, "models/") == target {
+			model, found = candidate, true
+			break
+		}
+	}
+	if !found {
+		if _, ok := c.converter.LookupCapabilities(target); !ok {
+			return nil, fmt.Errorf("%w: models/%s", ErrModelNotFound, modelID)
+		}
+		model = c.converter.catalogModel(target, defaultSupportedMethods)
+	}
+
+	if target != modelID {
+		// 流水线以自己的名字出现，客户端之后使用同一名字调用
+		model.Name = "models/" + modelID
+		model.DisplayName = modelID
+	}
+	return &model, nil
+}
+
+// modelCatalog 返回当前API模式的模型目录，缓存过期时从上游重新获取
+func (c *GeminiClient) modelCatalog(ctx context.Context) (*modelsCacheEntry, error) {
+	mode := c.apiMode()
+	if cached := c.models.get(mode, c.config.GetModelsCacheTTL()); cached != nil {
+		return cached, nil
+	}
+
+	catalog, err := c.fetchModels(ctx, mode)
+	if err != nil {
+		return nil, err
+	}
+	entry := &modelsCacheEntry{catalog: catalog, openai: c.converter.ConvertGeminiModels(catalog)}
+	c.models.set(mode, entry)
+	return entry, nil
+}
+
+// fetchModels 从上游获取模型目录，上游不提供模型列表时返回默认目录
+func (c *GeminiClient) fetchModels(ctx context.Context, mode config.APIMode) (*models.GeminiModelsResponse, error) {
+	ctx, cancel := withRequestTimeout(ctx, c.config.GetTimeout())
+	defer cancel()
+
+	switch mode {
+	case config.VertexAI:
+		return c.fetchVertexModels(ctx)
+	case config.CodeAssist:
+		return c.fetchModelPages(ctx, fmt.Sprintf("%s/%s/models", CodeAssistEndpoint, CodeAssistVersion))
+	default:
+		return c.fetchModelPages(ctx, fmt.Sprintf("%s/%s/models", DefaultAPIEndpoint, DefaultAPIVersion))
+	}
+}
+
+// fetchModelPages 分页获取AI Studio格式的模型列表
+func (c *GeminiClient) fetchModelPages(ctx context.Context, apiURL string) (*models.GeminiModelsResponse, error) {
+	catalog := &models.GeminiModelsResponse{}
+	pageToken := ""
+	for {
+		var page models.GeminiModelsResponse
+		found, err := c.getModelsPage(ctx, apiURL, pageToken, &page)
+		if err != nil {
+			return nil, err
+		}
+		if !found {
+			c.logger.Debug("Models API not available, using default list")
+			return c.converter.defaultModels(), nil
+		}
+		catalog.Models = append(catalog.Models, page.Models...)
+		if page.NextPageToken == "" {
+			return catalog, nil
+		}
+		pageToken = page.NextPageToken
+	}
+}
+
+// vertexPublisherModel Vertex AI发布方模型
+type vertexPublisherModel struct {
+	Name      string `json:"name"` // publishers/google/models/gemini-2.5-flash
+	VersionID string `json:"versionId"`
+}
+
+// vertexPublisherModelsResponse Vertex AI发布方模型列表
+type vertexPublisherModelsResponse struct {
+	PublisherModels []vertexPublisherModel `json:"publisherModels"`
+	NextPageToken   string                 `json:"nextPageToken"`
+}
+
+// fetchVertexModels 分页获取Vertex AI上Google发布的Gemini和嵌入模型，
+// 发布方模型不含token上限，按内置能力表补充
+func (c *GeminiClient) fetchVertexModels(ctx context.Context) (*models.GeminiModelsResponse, error) {
+	// 模型列表不含请求数据，不受数据驻留限制
+	ctx = WithDataRegion(ctx, "")
+	apiURL := fmt.Sprintf(VertexAPIEndpoint, c.location()) + "/v1beta1/publishers/google/models"
+
+	catalog := &models.GeminiModelsResponse{}
+	pageToken := ""
+	for {
+		var page vertexPublisherModelsResponse
+		found, err := c.getModelsPage(ctx, apiURL, pageToken, &page)
+		if err != nil {
+			return nil, err
+		}
+		if !found {
+			c.logger.Debug("Vertex AI publisher models API not available, using default list")
+			return c.converter.defaultModels(), nil
+		}
+		for _, publisherModel := range page.PublisherModels {
+			if model, ok := c.converter.vertexModel(publisherModel); ok {
+				catalog.Models = append(catalog.Models, model)
+			}
+		}
+		if page.NextPageToken == "" {
+			return catalog, nil
+		}
+		pageToken = page.NextPageToken
+	}
+}
+
+// getModelsPage 获取一页模型列表并解析到out，上游不支持模型列表接口时返回false
+func (c *GeminiClient) getModelsPage(ctx context.Context, apiURL, pageToken string, out any) (bool, error) {
+	query := url.Values{"pageSize": {fmt.Sprint(modelsPageSize)}}
+	if pageToken != "" {
+		query.Set("pageToken", pageToken)
+	}
+	httpReq, err := c.createRequest(ctx, "GET", apiURL+"?"+query.Encode(), nil)
+	if err != nil {
+		return false, err
+	}
+
+	c.logger.Debug("Fetching Gemini models list")
+	resp, err := c.client.Do(httpReq)
+	if err != nil {
+		return false, fmt.Errorf("models request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		if resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusMethodNotAllowed {
+			return false, nil
+		}
+		body, _ := io.ReadAll(resp.Body)
+		return false, fmt.Errorf("models API request failed with status %d: %s", resp.StatusCode, string(body))
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return false, fmt.Errorf("failed to decode models response: %w", err)
+	}
+	return true, nil
+}
+
+// defaultModels 生成默认模型目录，token上限取自内置能力表
+func (c *FormatConverter) defaultModels() *models.GeminiModelsResponse {
+	catalog := &models.GeminiModelsResponse{Models: make([]models.GeminiModel, len(defaultModelIDs))}
+	for i, modelID := range defaultModelIDs {
+		catalog.Models[i] = c.catalogModel(modelID, defaultSupportedMethods)
+	}
+	return catalog
+}
+
+// vertexModel 将Vertex AI发布方模型转换为原生模型资源，只保留可以通过代理调用的Gemini和嵌入模型
+func (c *FormatConverter) vertexModel(publisherModel vertexPublisherModel) (models.GeminiModel, bool) {
+	modelID := publisherModel.Name[strings.LastIndex(publisherModel.Name, "/")+1:]
+	var model models.GeminiModel
+	switch {
+	case strings.Contains(modelID, "embedding"):
+		model = c.catalogModel(modelID, []string{"embedContent"})
+	case strings.HasPrefix(modelID, "gemini"):
+		model = c.catalogModel(modelID, defaultSupportedMethods)
+	default:
+		return models.GeminiModel{}, false
+	}
+	model.Version = publisherModel.VersionID
+	return model, true
+}
+
+// catalogModel 生成模型资源
+func (c *FormatConverter) catalogModel(modelID string, methods []string) models.GeminiModel {
+	model := models.GeminiModel{
+		Name:             "models/" + modelID,
+		BaseModelId:      modelID,
+		DisplayName:      modelID,
+		SupportedMethods: methods,
+	}
+	if capabilities, ok := c.LookupCapabilities(modelID); ok {
+		model.InputTokenLimit = capabilities.MaxInputTokens
+		model.OutputTokenLimit = capabilities.MaxOutputTokens
+	}
+	return model
+}
@@ -0,0 +1,160 @@
+package client
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ba0gu0/gemini-go-proxy/pkg/config"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// modelsTransport 按请求路径和pageToken返回预设的模型列表页
+func modelsTransport(pages map[string]string, requests *[]string) roundTripFunc {
+	return func(req *http.Request) (*http.Response, error) {
+		*requests = append(*requests, req.URL.Host+req.URL.Path+"?"+req.URL.Query().Get("pageToken"))
+		body, ok := pages[req.URL.Path+"?"+req.URL.Query().Get("pageToken")]
+		status := http.StatusOK
+		if !ok {
+			status, body = http.StatusNotFound, `{}`
+		}
+		return &http.Response{
+			StatusCode: status,
+			Header:     http.Header{"Content-Type": []string{"application/json"}},
+			Body:       io.NopCloser(strings.NewReader(body)),
+		}, nil
+	}
+}
+
+func TestGeminiClient_ListModels_AIStudioPages(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.APIMode = config.AIStudio
+	client := NewGeminiClient(cfg, nil, logrus.New())
+	var requests []string
+	client.client.Transport = modelsTransport(map[string]string{
+		"/v1beta/models?": `{"models": [{"name": "models/gemini-2.5-pro", "inputTokenLimit": 1048576, "outputTokenLimit": 65536,
+			"supportedGenerationMethods": ["generateContent", "countTokens"]}], "nextPageToken": "p2"}`,
+		"/v1beta/models?p2": `{"models": [{"name": "models/text-embedding-004", "inputTokenLimit": 2048, "outputTokenLimit": 1,
+			"supportedGenerationMethods": ["embedContent"]}]}`,
+	}, &requests)
+
+	catalog, err := client.ListGeminiModels(context.Background())
+	require.NoError(t, err)
+	require.Len(t, catalog.Models, 2)
+	assert.Equal(t, 2048, catalog.Models[1].InputTokenLimit)
+	assert.Equal(t, []string{"embedContent"}, catalog.Models[1].SupportedMethods)
+	assert.Len(t, requests, 2)
+
+	list, err := client.ListModels(context.Background())
+	require.NoError(t, err)
+	require.Len(t, list.Data, 2)
+	assert.Equal(t, "gemini-2.5-pro", list.Data[0].ID)
+	assert.Len(t, requests, 2, "OpenAI and native lists share the cache")
+}
+
+func TestGeminiClient_ListModels_Vertex(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.APIMode = config.VertexAI
+	cfg.Location = "europe-west4"
+	client := NewGeminiClient(cfg, nil, logrus.New())
+	var requests []string
+	client.client.Transport = modelsTransport(map[string]string{
+		"/v1beta1/publishers/google/models?": `{"publisherModels": [
+			{"name": "publishers/google/models/gemini-2.5-flash", "versionId": "001"},
+			{"name": "publishers/google/models/imagen-4.0-generate-001"},
+			{"name": "publishers/google/models/text-embedding-005"}]}`,
+	}, &requests)
+
+	catalog, err := client.ListGeminiModels(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []string{"europe-west4-aiplatform.googleapis.com/v1beta1/publishers/google/models?"}, requests)
+	require.Len(t, catalog.Models, 2)
+	assert.Equal(t, "models/gemini-2.5-flash", catalog.Models[0].Name)
+	assert.Equal(t, "001", catalog.Models[0].Version)
+	assert.Equal(t, 1048576, catalog.Models[0].InputTokenLimit)
+	assert.Equal(t, 65536, catalog.Models[0].OutputTokenLimit)
+	assert.Equal(t, []string{"embedContent"}, catalog.Models[1].SupportedMethods)
+}
+
+func TestGeminiClient_ListModels_CachedPerMode(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.APIMode = config.AIStudio
+	client := NewGeminiClient(cfg, nil, logrus.New())
+	var requests []string
+	client.client.Transport = modelsTransport(map[string]string{
+		"/v1beta/models?": `{"models": [{"name": "models/gemini-2.5-flash"}]}`,
+	}, &requests)
+
+	studio, err := client.ListModels(context.Background())
+	require.NoError(t, err)
+	require.Len(t, studio.Data, 1)
+	assert.Equal(t, "gemini-2.5-flash", studio.Data[0].ID)
+	assert.Len(t, requests, 1)
+
+	// 切换模式后使用该模式自己的目录，Code Assist没有模型列表接口时使用默认目录
+	client.UseCodeAssist()
+	first, err := client.ListModels(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "gemini-2.5-pro", first.Data[0].ID)
+	second, err := client.ListModels(context.Background())
+	require.NoError(t, err)
+	assert.Same(t, first, second)
+	assert.Len(t, requests, 2)
+
+	// 过期后重新获取
+	client.models.entries[config.CodeAssist].cachedAt = time.Now().Add(-2 * cfg.GetModelsCacheTTL())
+	third, err := client.ListModels(context.Background())
+	require.NoError(t, err)
+	assert.NotSame(t, first, third)
+	assert.Len(t, requests, 3)
+}
+
+func TestFormatConverter_DefaultModels(t *testing.T) {
+	converter := NewFormatConverter(logrus.New())
+	catalog := converter.defaultModels()
+	require.Len(t, catalog.Models, len(defaultModelIDs))
+	assert.Equal(t, "models/gemini-2.5-pro", catalog.Models[0].Name)
+	assert.Equal(t, 65536, catalog.Models[0].OutputTokenLimit)
+	assert.Equal(t, defaultSupportedMethods, catalog.Models[0].SupportedMethods)
+
+	list := converter.GenerateModelsList()
+	require.Len(t, list.Data, len(defaultModelIDs))
+	assert.Equal(t, "google", list.Data[0].OwnedBy)
+}
+
+func TestGeminiClient_GetGeminiModel(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.APIMode = config.AIStudio
+	cfg.Pipelines = map[string]config.Pipeline{
+		"support-bot": {PipelineVersion: config.PipelineVersion{Model: "gemini-2.5-flash"}},
+	}
+	client := NewGeminiClient(cfg, nil, logrus.New())
+	var requests []string
+	client.client.Transport = modelsTransport(map[string]string{
+		"/v1beta/models?": `{"models": [{"name": "models/gemini-2.5-flash", "displayName": "Gemini 2.5 Flash", "inputTokenLimit": 1048576}]}`,
+	}, &requests)
+
+	model, err := client.GetGeminiModel(context.Background(), "models/gemini-2.5-flash")
+	require.NoError(t, err)
+	assert.Equal(t, "Gemini 2.5 Flash", model.DisplayName)
+
+	// 流水线使用自己的名字，元数据来自稳定版本的主模型
+	model, err = client.GetGeminiModel(context.Background(), "support-bot")
+	require.NoError(t, err)
+	assert.Equal(t, "models/support-bot", model.Name)
+	assert.Equal(t, 1048576, model.InputTokenLimit)
+
+	// 目录之外的已知模型按能力表生成
+	model, err = client.GetGeminiModel(context.Background(), "gemini-1.5-pro-002")
+	require.NoError(t, err)
+	assert.Equal(t, 2097152, model.InputTokenLimit)
+
+	_, err = client.GetGeminiModel(context.Background(), "no-such-model")
+	assert.ErrorIs(t, err, ErrModelNotFound)
+	assert.Len(t, requests, 1)
+}
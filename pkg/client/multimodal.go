@@ -0,0 +1,123 @@
+package client
+
+import (
+	"encoding/base64"
+	"fmt"
+	"mime"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/ba0gu0/gemini-go-proxy/pkg/models"
+)
+
+// defaultImageMimeType 无法从地址推断类型时使用的图片MIME类型
+const defaultImageMimeType = "image/jpeg"
+
+// messageParts 将OpenAI消息内容转换为Gemini部分，content为数组时保留文本和图片的顺序
+func messageParts(msg models.OpenAIMessage) ([]models.GeminiPart, error) {
+	if len(msg.ContentParts) == 0 {
+		return []models.GeminiPart{{Text: msg.Content}}, nil
+	}
+
+	parts := make([]models.GeminiPart, 0, len(msg.ContentParts))
+	for _, part := range msg.ContentParts {
+		switch part.Type {
+		case "text":
+			parts = append(parts, models.GeminiPart{Text: part.Text})
+		case "image_url":
+			if part.ImageURL == nil || part.ImageURL.URL == "" {
+				return nil, fmt.Errorf("image_url content part requires a url")
+			}
+			imagePart, err := imageURLPart(part.ImageURL.URL)
+			if err != nil {
+				return nil, err
+			}
+			parts = append(parts, imagePart)
+		case "file":
+			fileContent, err := filePart(part.File)
+			if err != nil {
+				return nil, err
+			}
+			parts = append(parts, fileContent)
+		default:
+			return nil, fmt.Errorf("unsupported content part type: %s", part.Type)
+		}
+	}
+	return parts, nil
+}
+
+// imageURLPart 将图片地址转换为Gemini部分：data URL转换为内联数据，远程地址转换为文件引用
+func imageURLPart(imageURL string) (models.GeminiPart, error) {
+	if strings.HasPrefix(imageURL, "data:") {
+		inline, err := parseDataURL(imageURL)
+		if err != nil {
+			return models.GeminiPart{}, err
+		}
+		return models.GeminiPart{InlineData: inline}, nil
+	}
+
+	parsed, err := url.Parse(imageURL)
+	if err != nil {
+		return models.GeminiPart{}, fmt.Errorf("invalid image url: %w", err)
+	}
+	switch parsed.Scheme {
+	case "http", "https", "gs":
+	default:
+		return models.GeminiPart{}, fmt.Errorf("unsupported image url scheme: %q", parsed.Scheme)
+	}
+
+	mimeType := defaultImageMimeType
+	if guessed := mime.TypeByExtension(path.Ext(parsed.Path)); guessed != "" {
+		mimeType = strings.SplitN(guessed, ";", 2)[0]
+	}
+	return models.GeminiPart{FileData: &models.GeminiFileData{MimeType: mimeType, FileURI: imageURL}}, nil
+}
+
+// filePart 将文件内容部分转换为Gemini部分：file_data转换为内联数据，
+// file_id（通过 /upload/v1beta/files 上传后得到的 files/... 名称或文件URI）转换为文件引用
+func filePart(file *models.OpenAIFile) (models.GeminiPart, error) {
+	switch {
+	case file == nil || (file.FileID == "" && file.FileData == ""):
+		return models.GeminiPart{}, fmt.Errorf("file content part requires file_id or file_data")
+	case file.FileData != "":
+		inline, err := parseDataURL(file.FileData)
+		if err != nil {
+			return models.GeminiPart{}, err
+		}
+		return models.GeminiPart{InlineData: inline}, nil
+	}
+
+	fileURI := file.FileID
+	if strings.HasPrefix(fileURI, "files/") {
+		fileURI = fmt.Sprintf("%s/%s/%s", DefaultAPIEndpoint, DefaultAPIVersion, fileURI)
+	} else if parsed, err := url.Parse(fileURI); err != nil || (parsed.Scheme != "https" && parsed.Scheme != "gs") {
+		return models.GeminiPart{}, fmt.Errorf("unsupported file_id %q: expected files/... or a file URI", file.FileID)
+	}
+
+	// 已上传文件的MIME类型由上游记录，只有能从文件名推断时才填写
+	var mimeType string
+	if guessed := mime.TypeByExtension(path.Ext(file.Filename)); guessed != "" {
+		mimeType = strings.SplitN(guessed, ";", 2)[0]
+	}
+	return models.GeminiPart{FileData: &models.GeminiFileData{MimeType: mimeType, FileURI: fileURI}}, nil
+}
+
+// parseDataURL 解析 data:<mime>;base64,<data> 格式的地址
+func parseDataURL(dataURL string) (*models.GeminiInlineData, error) {
+	header, data, ok := strings.Cut(strings.TrimPrefix(dataURL, "data:"), ",")
+	if !ok {
+		return nil, fmt.Errorf("invalid data url")
+	}
+	mimeType, encoding, _ := strings.Cut(header, ";")
+	if encoding != "base64" {
+		return nil, fmt.Errorf("data url must be base64 encoded")
+	}
+	if mimeType == "" {
+		mimeType = defaultImageMimeType
+	}
+	if _, err := base64.StdEncoding.DecodeString(data); err != nil {
+		return nil, fmt.Errorf("invalid base64 data in data url: %w", err)
+	}
+	return &models.GeminiInlineData{MimeType: mimeType, Data: data}, nil
+}
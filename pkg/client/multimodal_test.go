@@ -0,0 +1,74 @@
+package client
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/ba0gu0/gemini-go-proxy/pkg/models"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpenAIToGeminiRequest_Images(t *testing.T) {
+	var req models.OpenAIRequest
+	require.NoError(t, json.Unmarshal([]byte(`{"model":"gemini-2.5-flash","messages":[
+		{"role":"user","content":[
+			{"type":"text","text":"Compare these"},
+			{"type":"image_url","image_url":{"url":"data:image/png;base64,iVBORw0KGgo="}},
+			{"type":"image_url","image_url":{"url":"https://example.com/photo.webp?size=large"}}
+		]},
+		{"role":"user","content":"thanks"}
+	]}`), &req))
+
+	converter := NewFormatConverter(logrus.New())
+	geminiReq, err := converter.OpenAIToGeminiRequest(&req)
+	require.NoError(t, err)
+
+	// 包含图片的消息不与相邻文本消息合并为单个文本
+	require.Len(t, geminiReq.Contents, 1)
+	parts := geminiReq.Contents[0].Parts
+	require.Len(t, parts, 4)
+	assert.Equal(t, "Compare these", parts[0].Text)
+	assert.Equal(t, &models.GeminiInlineData{MimeType: "image/png", Data: "iVBORw0KGgo="}, parts[1].InlineData)
+	assert.Equal(t, &models.GeminiFileData{MimeType: "image/webp", FileURI: "https://example.com/photo.webp?size=large"}, parts[2].FileData)
+	assert.Equal(t, "thanks", parts[3].Text)
+
+	assert.Greater(t, estimateRequestSize(geminiReq), estimateRequestSize(&models.GeminiRequest{Contents: []models.GeminiContent{{Parts: parts[:1]}}}))
+}
+
+func TestImageURLPart_Errors(t *testing.T) {
+	for _, imageURL := range []string{
+		"data:image/png;base64",
+		"data:image/png,rawdata",
+		"data:image/png;base64,!!!",
+		"file:///etc/passwd",
+	} {
+		_, err := imageURLPart(imageURL)
+		assert.Error(t, err, imageURL)
+	}
+
+	_, err := messageParts(models.OpenAIMessage{ContentParts: []models.OpenAIContentPart{{Type: "input_audio"}}})
+	assert.Error(t, err)
+	_, err = messageParts(models.OpenAIMessage{ContentParts: []models.OpenAIContentPart{{Type: "image_url"}}})
+	assert.Error(t, err)
+}
+
+func TestFilePart(t *testing.T) {
+	part, err := filePart(&models.OpenAIFile{FileID: "files/abc123", Filename: "report.pdf"})
+	require.NoError(t, err)
+	assert.Equal(t, &models.GeminiFileData{MimeType: "application/pdf", FileURI: DefaultAPIEndpoint + "/v1beta/files/abc123"}, part.FileData)
+
+	part, err = filePart(&models.OpenAIFile{FileID: "gs://bucket/video.mp4"})
+	require.NoError(t, err)
+	assert.Equal(t, &models.GeminiFileData{FileURI: "gs://bucket/video.mp4"}, part.FileData)
+
+	part, err = filePart(&models.OpenAIFile{FileData: "data:application/pdf;base64,JVBERi0="})
+	require.NoError(t, err)
+	assert.Equal(t, &models.GeminiInlineData{MimeType: "application/pdf", Data: "JVBERi0="}, part.InlineData)
+
+	for _, file := range []*models.OpenAIFile{nil, {}, {FileID: "file-abc"}, {FileID: "http://example.com/a.pdf"}} {
+		_, err := filePart(file)
+		assert.Error(t, err)
+	}
+}
@@ -0,0 +1,66 @@
+package client
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/ba0gu0/gemini-go-proxy/pkg/config"
+)
+
+// accountPacer 同一账号的连续上游请求之间插入[min, max]内的随机间隔，平滑突发请求
+type accountPacer struct {
+	mu   sync.Mutex
+	min  time.Duration
+	max  time.Duration
+	next map[int]time.Time // 账号序号（无账号池时为-1）-> 下一次允许发送的时间
+	rand *rand.Rand
+}
+
+// newAccountPacer 创建请求节奏控制器，未配置或间隔为0时返回nil
+func newAccountPacer(pacing *config.Pacing) *accountPacer {
+	if pacing == nil || (pacing.MinDelayMs <= 0 && pacing.MaxDelayMs <= 0) {
+		return nil
+	}
+	minDelay := time.Duration(max(pacing.MinDelayMs, 0)) * time.Millisecond
+	maxDelay := max(time.Duration(pacing.MaxDelayMs)*time.Millisecond, minDelay)
+	return &accountPacer{
+		min:  minDelay,
+		max:  maxDelay,
+		next: make(map[int]time.Time),
+		rand: rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// wait 为账号预约下一个发送时间并等待到该时间，ctx取消时返回错误
+// 预约在锁内完成，并发请求按到达顺序依次间隔发送
+func (p *accountPacer) wait(ctx context.Context, account int) error {
+	if p == nil {
+		return nil
+	}
+
+	p.mu.Lock()
+	now := time.Now()
+	at := now
+	if next := p.next[account]; next.After(now) {
+		at = next
+	}
+	delay := p.min
+	if p.max > p.min {
+		delay += time.Duration(p.rand.Int63n(int64(p.max - p.min)))
+	}
+	p.next[account] = at.Add(delay)
+	p.mu.Unlock()
+
+	if wait := at.Sub(now); wait > 0 {
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+package client
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ba0gu0/gemini-go-proxy/pkg/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAccountPacer(t *testing.T) {
+	assert.Nil(t, newAccountPacer(nil))
+	assert.Nil(t, newAccountPacer(&config.Pacing{}))
+
+	pacer := newAccountPacer(&config.Pacing{MinDelayMs: 50, MaxDelayMs: 80})
+	require.NotNil(t, pacer)
+
+	// 第一个请求立即发送，同一账号的下一个请求至少间隔最小间隔
+	start := time.Now()
+	require.NoError(t, pacer.wait(context.Background(), 0))
+	assert.Less(t, time.Since(start), 20*time.Millisecond)
+	require.NoError(t, pacer.wait(context.Background(), 0))
+	elapsed := time.Since(start)
+	assert.GreaterOrEqual(t, elapsed, 50*time.Millisecond)
+	assert.Less(t, elapsed, 200*time.Millisecond)
+
+	// 其他账号不受影响
+	start = time.Now()
+	require.NoError(t, pacer.wait(context.Background(), 1))
+	assert.Less(t, time.Since(start), 20*time.Millisecond)
+
+	// 等待期间取消请求
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.ErrorIs(t, pacer.wait(ctx, 0), context.Canceled)
+}
+
+func TestAccountPacer_FixedDelay(t *testing.T) {
+	pacer := newAccountPacer(&config.Pacing{MinDelayMs: 30})
+	require.NotNil(t, pacer)
+	assert.Equal(t, pacer.min, pacer.max)
+}
@@ -0,0 +1,69 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/ba0gu0/gemini-go-proxy/pkg/config"
+)
+
+// ErrPassthroughUnsupported 透传的是AI Studio接口，其他API模式下不可用
+var ErrPassthroughUnsupported = errors.New("gemini API passthrough is only available in ai_studio mode")
+
+// ForwardGeminiRequest 注入认证后将请求原样转发到AI Studio接口的 /v1beta 资源路径
+// （如 cachedContents、files、tunedModels），resourcePath 为 /v1beta/ 之后的部分。
+// 请求体、查询参数和非敏感头部保持不变，客户端凭据（key 参数和认证头部）被去除；调用方负责关闭响应体。
+// 非AI Studio模式返回 ErrPassthroughUnsupported
+func (c *GeminiClient) ForwardGeminiRequest(r *http.Request, resourcePath string) (*http.Response, error) {
+	return c.forwardGeminiRequest(r, DefaultAPIVersion+"/"+strings.TrimPrefix(resourcePath, "/"))
+}
+
+// ForwardGeminiUpload 与 ForwardGeminiRequest 相同，但转发到 /upload/v1beta 上传路径（Files API的文件上传，
+// 包括可续传上传的开始请求和后续分块）；上游返回的 X-Goog-Upload-URL 指向上游，需要调用方改写为代理地址
+func (c *GeminiClient) ForwardGeminiUpload(r *http.Request, resourcePath string) (*http.Response, error) {
+	return c.forwardGeminiRequest(r, "upload/"+DefaultAPIVersion+"/"+strings.TrimPrefix(resourcePath, "/"))
+}
+
+// forwardGeminiRequest 转发请求到AI Studio接口的 apiPath（如 v1beta/cachedContents）
+func (c *GeminiClient) forwardGeminiRequest(r *http.Request, apiPath string) (*http.Response, error) {
+	if c.apiMode() != config.AIStudio {
+		return nil, ErrPassthroughUnsupported
+	}
+
+	apiURL := DefaultAPIEndpoint + "/" + apiPath
+	query := r.URL.Query()
+	query.Del("key")
+	if encoded := query.Encode(); encoded != "" {
+		apiURL += "?" + encoded
+	}
+
+	var body io.Reader
+	if r.ContentLength != 0 {
+		body = r.Body
+	}
+	httpReq, err := c.createRequest(r.Context(), r.Method, apiURL, body)
+	if err != nil {
+		return nil, err
+	}
+	httpReq.ContentLength = r.ContentLength
+
+	// 保留客户端的其他头部（如上传使用的 Content-Type 和 X-Goog-Upload-*），认证和User-Agent由代理设置
+	header := r.Header.Clone()
+	sanitizeUpstreamHeaders(header)
+	header.Del("User-Agent")
+	for name, values := range header {
+		httpReq.Header[name] = values
+	}
+
+	c.logger.Debugf("Forwarding Gemini API request: %s %s", r.Method, apiPath)
+
+	resp, err := c.client.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("gemini passthrough request failed: %w", err)
+	}
+	c.cooldownAccount(httpReq, resp)
+	return resp, nil
+}
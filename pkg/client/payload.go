@@ -0,0 +1,194 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/ba0gu0/gemini-go-proxy/pkg/models"
+)
+
+// DefaultStreamBodyThreshold 默认的请求体流式发送阈值，超过后不再完整缓冲且禁用重试
+const DefaultStreamBodyThreshold = 8 << 20
+
+// payloadSizeBuckets 请求体大小统计的分桶上限
+var payloadSizeBuckets = []int64{64 << 10, 1 << 20, 8 << 20, 32 << 20}
+
+// requestPayload 上游请求体，小请求完整缓冲以便重试，大请求在发送时流式编码
+type requestPayload struct {
+	buffered []byte
+	value    any   // 需要流式编码的请求对象
+	size     int64 // 缓冲时为实际大小，流式时为估算大小
+
+	buf      *bytes.Buffer // 池化的缓冲区
+	budget   *byteBudget
+	reserved int64
+	once     sync.Once
+}
+
+// release 归还缓冲区和字节预算，请求完成后调用，可重复调用
+func (p *requestPayload) release() {
+	p.once.Do(func() {
+		p.budget.release(p.reserved)
+		putBuffer(p.buf)
+		p.buf = nil
+		p.buffered = nil
+	})
+}
+
+// reader 返回一次发送使用的请求体
+func (p *requestPayload) reader() io.Reader {
+	if p.value == nil {
+		return bytes.NewReader(p.buffered)
+	}
+
+	// 流式编码：传输层读取失败或关闭请求体时编码协程随之退出
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(json.NewEncoder(pw).Encode(p.value))
+	}()
+	return pr
+}
+
+// retryable 请求体是否可以重放
+func (p *requestPayload) retryable() bool {
+	return p.value == nil
+}
+
+// newRequestPayload 根据估算大小决定缓冲或流式发送请求体
+func (c *GeminiClient) newRequestPayload(ctx context.Context, body any, estimated int64) (*requestPayload, error) {
+	threshold := c.config.StreamBodyThresholdBytes
+	if threshold == 0 {
+		threshold = DefaultStreamBodyThreshold
+	}
+
+	var payload *requestPayload
+	if threshold > 0 && estimated > threshold {
+		c.logger.Infof("Request payload ~%d bytes exceeds %d, streaming body without retries", estimated, threshold)
+		payload = &requestPayload{value: body, size: estimated}
+	} else {
+		buf := getBuffer()
+		if err := json.NewEncoder(buf).Encode(body); err != nil {
+			putBuffer(buf)
+			return nil, fmt.Errorf("failed to marshal request: %w", err)
+		}
+		size := int64(buf.Len())
+
+		// 限制同时缓冲的字节数
+		if err := c.bufferBudget.acquire(ctx, size); err != nil {
+			putBuffer(buf)
+			return nil, fmt.Errorf("waiting for request buffer budget: %w", err)
+		}
+		payload = &requestPayload{
+			buffered: buf.Bytes(),
+			size:     size,
+			buf:      buf,
+			budget:   c.bufferBudget,
+			reserved: size,
+		}
+	}
+
+	c.payloadStats.record(payload.size, !payload.retryable())
+	if trace := RequestTraceFromContext(ctx); trace != nil {
+		trace.mu.Lock()
+		trace.RequestBytes = payload.size
+		trace.mu.Unlock()
+	}
+	return payload, nil
+}
+
+// releaseReadCloser 关闭响应体时释放请求体资源
+type releaseReadCloser struct {
+	io.ReadCloser
+	release func()
+}
+
+// Close 关闭响应体并释放资源
+func (r *releaseReadCloser) Close() error {
+	err := r.ReadCloser.Close()
+	r.release()
+	return err
+}
+
+// estimateRequestSize 估算请求序列化后的大小，用于在编码前决定是否流式发送
+func estimateRequestSize(req *models.GeminiRequest) int64 {
+	if req == nil {
+		return 0
+	}
+
+	var size int64 = 256
+	for _, content := range req.Contents {
+		size += 64
+		for _, part := range content.Parts {
+			size += int64(len(part.Text)) + 16
+			if part.InlineData != nil {
+				size += int64(len(part.InlineData.Data)) + 64
+			}
+			if part.FileData != nil {
+				size += int64(len(part.FileData.FileURI)) + 64
+			}
+		}
+	}
+	if req.SystemInstruction != nil {
+		for _, part := range req.SystemInstruction.Parts {
+			size += int64(len(part.Text)) + 16
+		}
+	}
+	return size
+}
+
+// PayloadSizeStats 请求体大小统计
+type PayloadSizeStats struct {
+	Count    int64   `json:"count"`
+	Streamed int64   `json:"streamed"`    // 超过阈值流式发送的请求数
+	Total    int64   `json:"total_bytes"` // 请求体总字节数
+	Max      int64   `json:"max_bytes"`
+	Buckets  []int64 `json:"buckets"` // 按 ≤64KiB、≤1MiB、≤8MiB、≤32MiB、更大 分桶的请求数
+}
+
+// payloadStats 请求体大小统计收集器
+type payloadStats struct {
+	mu    sync.Mutex
+	stats PayloadSizeStats
+}
+
+// record 记录一次请求体大小
+func (p *payloadStats) record(size int64, streamed bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.stats.Buckets == nil {
+		p.stats.Buckets = make([]int64, len(payloadSizeBuckets)+1)
+	}
+
+	p.stats.Count++
+	p.stats.Total += size
+	if size > p.stats.Max {
+		p.stats.Max = size
+	}
+	if streamed {
+		p.stats.Streamed++
+	}
+
+	bucket := len(payloadSizeBuckets)
+	for i, limit := range payloadSizeBuckets {
+		if size <= limit {
+			bucket = i
+			break
+		}
+	}
+	p.stats.Buckets[bucket]++
+}
+
+// PayloadStats 获取请求体大小统计快照
+func (c *GeminiClient) PayloadStats() PayloadSizeStats {
+	c.payloadStats.mu.Lock()
+	defer c.payloadStats.mu.Unlock()
+
+	snapshot := c.payloadStats.stats
+	snapshot.Buckets = append([]int64(nil), c.payloadStats.stats.Buckets...)
+	return snapshot
+}
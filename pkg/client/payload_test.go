@@ -0,0 +1,64 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/ba0gu0/gemini-go-proxy/pkg/config"
+	"github.com/ba0gu0/gemini-go-proxy/pkg/models"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGeminiClient_LargePayloadStreamedWithoutRetry(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.APIMode = config.AIStudio
+	cfg.StreamBodyThresholdBytes = 1024
+	cfg.MaxRetries = 3
+	client := NewGeminiClient(cfg, nil, logrus.New())
+
+	var attempts int
+	var received []int
+	client.client.Transport = roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		attempts++
+		body, _ := io.ReadAll(req.Body)
+		received = append(received, len(body))
+		assert.Zero(t, req.ContentLength) // 长度未知，分块发送
+		return nil, errors.New("connection reset")
+	})
+
+	req := &models.GeminiRequest{Contents: []models.GeminiContent{{Role: "user", Parts: []models.GeminiPart{{Text: strings.Repeat("x", 4096)}}}}}
+	_, err := client.SendRequest(context.Background(), "gemini-2.5-flash", req)
+	require.Error(t, err)
+
+	// 流式请求体不可重放，只发送一次
+	assert.Equal(t, 1, attempts)
+	assert.Greater(t, received[0], 4096)
+
+	stats := client.PayloadStats()
+	assert.Equal(t, int64(1), stats.Count)
+	assert.Equal(t, int64(1), stats.Streamed)
+}
+
+func TestGeminiClient_PayloadStats(t *testing.T) {
+	client := NewGeminiClient(config.DefaultConfig(), nil, logrus.New())
+
+	client.payloadStats.record(100, false)
+	client.payloadStats.record(2<<20, false)
+	client.payloadStats.record(64<<20, true)
+
+	stats := client.PayloadStats()
+	assert.Equal(t, int64(3), stats.Count)
+	assert.Equal(t, int64(1), stats.Streamed)
+	assert.Equal(t, int64(64<<20), stats.Max)
+	assert.Equal(t, []int64{1, 0, 1, 0, 1}, stats.Buckets)
+
+	// 快照与内部状态互不影响
+	stats.Buckets[0] = 99
+	assert.Equal(t, int64(1), client.PayloadStats().Buckets[0])
+}
@@ -0,0 +1,369 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"math/rand/v2"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/ba0gu0/gemini-go-proxy/pkg/config"
+	"github.com/ba0gu0/gemini-go-proxy/pkg/models"
+	"github.com/sirupsen/logrus"
+)
+
+// defaultPipelineVersion 流水线顶层字段定义的版本名
+const defaultPipelineVersion = "default"
+
+var (
+	// ErrPipelineNotFound 流水线不存在
+	ErrPipelineNotFound = errors.New("pipeline not found")
+	// ErrInvalidPipeline 流水线版本或流量分配无效
+	ErrInvalidPipeline = errors.New("invalid pipeline")
+	// ErrPipelineVersionInUse 版本正在接收流量，不能修改
+	ErrPipelineVersionInUse = errors.New("pipeline version is serving traffic")
+)
+
+// PipelineStatus 流水线的版本和流量分配
+type PipelineStatus struct {
+	Name     string                            `json:"name"`
+	Stable   string                            `json:"stable"`
+	Rollout  map[string]int                    `json:"rollout,omitempty"`
+	Versions map[string]config.PipelineVersion `json:"versions"`
+}
+
+// pipeline 已加载的命名流水线，版本和流量分配可在运行时通过管理接口修改
+type pipeline struct {
+	name string
+
+	mu       sync.RWMutex
+	versions map[string]*pipelineVersion
+	stable   string
+	rollout  map[string]int
+}
+
+// pipelineVersion 流水线的一个版本
+type pipelineVersion struct {
+	pipeline string
+	name     string
+	config   config.PipelineVersion
+	filter   *contentFilter // 版本专用的内容过滤器，未配置时为nil
+}
+
+// pipelineContextKey 请求上下文中当前流水线版本的键
+type pipelineContextKey struct{}
+
+// newPipelines 加载流水线配置，无效的流水线被忽略
+func newPipelines(cfg map[string]config.Pipeline, logger *logrus.Logger) map[string]*pipeline {
+	pipelines := make(map[string]*pipeline, len(cfg))
+	for name, pipelineConfig := range cfg {
+		p, err := newPipeline(name, pipelineConfig, cfg)
+		if err != nil {
+			logger.Errorf("Pipeline %q ignored: %v", name, err)
+			continue
+		}
+		pipelines[name] = p
+	}
+	return pipelines
+}
+
+// newPipeline 校验并加载一条流水线的全部版本
+func newPipeline(name string, cfg config.Pipeline, all map[string]config.Pipeline) (*pipeline, error) {
+	versionConfigs := maps.Clone(cfg.Versions)
+	if versionConfigs == nil {
+		versionConfigs = make(map[string]config.PipelineVersion)
+	}
+	if cfg.Model != "" {
+		if _, ok := versionConfigs[defaultPipelineVersion]; ok {
+			return nil, fmt.Errorf("version %q is defined by the top-level fields", defaultPipelineVersion)
+		}
+		versionConfigs[defaultPipelineVersion] = cfg.PipelineVersion
+	}
+
+	p := &pipeline{name: name, versions: make(map[string]*pipelineVersion, len(versionConfigs))}
+	for version, versionConfig := range versionConfigs {
+		v, err := newPipelineVersion(name, version, versionConfig, all)
+		if err != nil {
+			return nil, err
+		}
+		p.versions[version] = v
+	}
+
+	stable := cfg.Stable
+	if stable == "" {
+		switch {
+		case cfg.Model != "":
+			stable = defaultPipelineVersion
+		case len(versionConfigs) == 1:
+			stable = slices.Collect(maps.Keys(versionConfigs))[0]
+		default:
+			return nil, fmt.Errorf("%w: stable version is required", ErrInvalidPipeline)
+		}
+	}
+	if err := p.setRollout(stable, cfg.Rollout); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// newPipelineVersion 校验并加载一个版本：必须指定模型，不能引用其他流水线
+func newPipelineVersion(pipelineName, version string, cfg config.PipelineVersion, all map[string]config.Pipeline) (*pipelineVersion, error) {
+	if version == "" {
+		return nil, fmt.Errorf("%w: version name is required", ErrInvalidPipeline)
+	}
+	if cfg.Model == "" {
+		return nil, fmt.Errorf("%w: version %q: model is required", ErrInvalidPipeline, version)
+	}
+	for _, model := range append([]string{cfg.Model}, cfg.Fallbacks...) {
+		if _, ok := all[model]; ok {
+			return nil, fmt.Errorf("%w: version %q references pipeline %q", ErrInvalidPipeline, version, model)
+		}
+	}
+
+	filter, err := newContentFilter(cfg.ResponseFilter)
+	if err != nil {
+		return nil, fmt.Errorf("%w: version %q: response filter: %v", ErrInvalidPipeline, version, err)
+	}
+	return &pipelineVersion{pipeline: pipelineName, name: version, config: cfg, filter: filter}, nil
+}
+
+// setRollout 校验并替换稳定版本和灰度流量分配，调用方不能持有锁
+func (p *pipeline) setRollout(stable string, rollout map[string]int) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if _, ok := p.versions[stable]; !ok {
+		return fmt.Errorf("%w: unknown stable version %q", ErrInvalidPipeline, stable)
+	}
+	total := 0
+	for version, percent := range rollout {
+		if _, ok := p.versions[version]; !ok {
+			return fmt.Errorf("%w: unknown rollout version %q", ErrInvalidPipeline, version)
+		}
+		if percent < 0 || percent > 100 {
+			return fmt.Errorf("%w: rollout percentage of %q must be between 0 and 100", ErrInvalidPipeline, version)
+		}
+		total += percent
+	}
+	if total > 100 {
+		return fmt.Errorf("%w: rollout percentages add up to %d", ErrInvalidPipeline, total)
+	}
+
+	p.stable = stable
+	p.rollout = maps.Clone(rollout)
+	return nil
+}
+
+// pick 按流量分配选择本次请求使用的版本
+func (p *pipeline) pick() *pipelineVersion {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if len(p.rollout) > 0 {
+		n := rand.IntN(100)
+		for _, version := range slices.Sorted(maps.Keys(p.rollout)) {
+			if n < p.rollout[version] {
+				return p.versions[version]
+			}
+			n -= p.rollout[version]
+		}
+	}
+	return p.versions[p.stable]
+}
+
+// stableVersion 返回稳定版本
+func (p *pipeline) stableVersion() *pipelineVersion {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.versions[p.stable]
+}
+
+// status 返回流水线当前的版本和流量分配
+func (p *pipeline) status() PipelineStatus {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	status := PipelineStatus{
+		Name:     p.name,
+		Stable:   p.stable,
+		Rollout:  maps.Clone(p.rollout),
+		Versions: make(map[string]config.PipelineVersion, len(p.versions)),
+	}
+	for name, version := range p.versions {
+		status.Versions[name] = version.config
+	}
+	return status
+}
+
+// pipelineFromContext 返回请求所属的流水线版本，不是流水线请求时返回nil
+func pipelineFromContext(ctx context.Context) *pipelineVersion {
+	v, _ := ctx.Value(pipelineContextKey{}).(*pipelineVersion)
+	return v
+}
+
+// hasSystemPrompt 判断流水线版本是否自带系统提示词
+func (v *pipelineVersion) hasSystemPrompt() bool {
+	return v != nil && v.config.SystemPrompt != ""
+}
+
+// modelIDs 返回按顺序尝试的模型：主模型和候补模型
+func (v *pipelineVersion) modelIDs() []string {
+	return append([]string{v.config.Model}, v.config.Fallbacks...)
+}
+
+// apply 返回应用了版本默认参数和系统提示词的请求副本
+func (v *pipelineVersion) apply(req *models.GeminiRequest) *models.GeminiRequest {
+	req = cloneGeminiRequest(req)
+
+	cfg := v.config
+	if cfg.Temperature != nil || cfg.TopP != nil || cfg.TopK != nil || cfg.MaxOutputTokens != nil {
+		if req.GenerationConfig == nil {
+			req.GenerationConfig = &models.GeminiGenerationConfig{}
+		}
+		generationConfig := req.GenerationConfig
+		if generationConfig.Temperature == nil {
+			generationConfig.Temperature = cfg.Temperature
+		}
+		if generationConfig.TopP == nil {
+			generationConfig.TopP = cfg.TopP
+		}
+		if generationConfig.TopK == nil {
+			generationConfig.TopK = cfg.TopK
+		}
+		if generationConfig.MaxOutputTokens == nil {
+			generationConfig.MaxOutputTokens = cfg.MaxOutputTokens
+		}
+	}
+
+	if cfg.SystemPrompt != "" {
+		part := models.GeminiPart{Text: cfg.SystemPrompt}
+		if strings.ToLower(cfg.SystemPromptMode) == "append" && req.SystemInstruction != nil {
+			req.SystemInstruction.Parts = append(req.SystemInstruction.Parts, part)
+		} else {
+			req.SystemInstruction = &models.GeminiSystemInstruction{Parts: []models.GeminiPart{part}}
+		}
+	}
+	return req
+}
+
+// lookupPipeline 按客户端使用的模型名查找流水线
+func (c *GeminiClient) lookupPipeline(modelID string) *pipeline {
+	return c.pipelines[modelID]
+}
+
+// pipelineModel 将流水线名解析为稳定版本的主模型，其他模型名原样返回
+func (c *GeminiClient) pipelineModel(modelID string) string {
+	if p := c.lookupPipeline(modelID); p != nil {
+		return p.stableVersion().config.Model
+	}
+	return modelID
+}
+
+// responseFilter 返回请求使用的内容过滤器，流水线版本配置了过滤时优先于全局配置
+func (c *GeminiClient) responseFilter(ctx context.Context) *contentFilter {
+	if v := pipelineFromContext(ctx); v != nil && v.filter != nil {
+		return v.filter
+	}
+	return c.filter
+}
+
+// runPipeline 选择流水线版本并改写请求，依次用主模型和候补模型调用send，直到成功；
+// send 返回 delivered=true（流式响应已向调用方输出内容）或错误不适合换用其他模型时不再尝试
+func (c *GeminiClient) runPipeline(ctx context.Context, p *pipeline, req *models.GeminiRequest, send func(ctx context.Context, modelID string, req *models.GeminiRequest) (delivered bool, err error)) error {
+	v := p.pick()
+	c.logger.Debugf("Pipeline %s: using version %s", p.name, v.name)
+	ctx = context.WithValue(ctx, pipelineContextKey{}, v)
+	req = v.apply(req)
+
+	var err error
+	for i, modelID := range v.modelIDs() {
+		if i > 0 {
+			c.logger.Warnf("Pipeline %s@%s: falling back to %s after error: %v", p.name, v.name, modelID, err)
+		}
+		var delivered bool
+		delivered, err = send(ctx, modelID, cloneGeminiRequest(req))
+		if err == nil || delivered || !canFallback(ctx, err) {
+			return err
+		}
+	}
+	return err
+}
+
+// canFallback 判断失败的请求能否换用候补模型重试，请求本身的问题和客户端取消不重试
+func canFallback(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	return !errors.Is(err, ErrUnsupportedCapability) &&
+		!errors.Is(err, ErrPromptBlocked) &&
+		!errors.Is(err, ErrResponseBlocked) &&
+		!errors.Is(err, ErrRequestRejected)
+}
+
+// Pipelines 返回全部流水线的版本和流量分配，按名称排序
+func (c *GeminiClient) Pipelines() []PipelineStatus {
+	statuses := make([]PipelineStatus, 0, len(c.pipelines))
+	for _, name := range slices.Sorted(maps.Keys(c.pipelines)) {
+		statuses = append(statuses, c.pipelines[name].status())
+	}
+	return statuses
+}
+
+// PutPipelineVersion 新增或替换流水线的版本，正在接收流量的版本（稳定版本或灰度版本）不能替换
+func (c *GeminiClient) PutPipelineVersion(name, version string, cfg config.PipelineVersion) (PipelineStatus, error) {
+	p := c.lookupPipeline(name)
+	if p == nil {
+		return PipelineStatus{}, ErrPipelineNotFound
+	}
+	v, err := newPipelineVersion(name, version, cfg, c.config.Pipelines)
+	if err != nil {
+		return PipelineStatus{}, err
+	}
+
+	p.mu.Lock()
+	if _, ok := p.rollout[version]; ok || version == p.stable {
+		p.mu.Unlock()
+		return PipelineStatus{}, fmt.Errorf("%w: %q", ErrPipelineVersionInUse, version)
+	}
+	p.versions[version] = v
+	p.mu.Unlock()
+
+	c.logger.Infof("Pipeline %s: version %s saved (model %s)", name, version, cfg.Model)
+	return p.status(), nil
+}
+
+// SetPipelineRollout 设置流水线的稳定版本和灰度流量分配，stable 为空时保持当前稳定版本
+func (c *GeminiClient) SetPipelineRollout(name, stable string, rollout map[string]int) (PipelineStatus, error) {
+	p := c.lookupPipeline(name)
+	if p == nil {
+		return PipelineStatus{}, ErrPipelineNotFound
+	}
+	if stable == "" {
+		stable = p.stableVersion().name
+	}
+	if err := p.setRollout(stable, rollout); err != nil {
+		return PipelineStatus{}, err
+	}
+
+	c.logger.Infof("Pipeline %s: stable version %s, rollout %v", name, stable, rollout)
+	return p.status(), nil
+}
+
+// RollbackPipeline 立即停止灰度，全部流量回到稳定版本
+func (c *GeminiClient) RollbackPipeline(name string) (PipelineStatus, error) {
+	p := c.lookupPipeline(name)
+	if p == nil {
+		return PipelineStatus{}, ErrPipelineNotFound
+	}
+
+	p.mu.Lock()
+	rollout := p.rollout
+	p.rollout = nil
+	p.mu.Unlock()
+
+	c.logger.Warnf("Pipeline %s: rolled back to stable version, rollout %v stopped", name, rollout)
+	return p.status(), nil
+}
@@ -0,0 +1,179 @@
+package client
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/ba0gu0/gemini-go-proxy/pkg/config"
+	"github.com/ba0gu0/gemini-go-proxy/pkg/models"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewPipelines(t *testing.T) {
+	pipelines := newPipelines(map[string]config.Pipeline{
+		"support-bot": {PipelineVersion: config.PipelineVersion{Model: "gemini-2.5-flash"}},
+		"no-model":    {PipelineVersion: config.PipelineVersion{SystemPrompt: "x"}},
+		"nested":      {PipelineVersion: config.PipelineVersion{Model: "gemini-2.5-pro", Fallbacks: []string{"support-bot"}}},
+		"versioned": {
+			Versions: map[string]config.PipelineVersion{"v1": {Model: "gemini-2.5-flash"}, "v2": {Model: "gemini-2.5-pro"}},
+			Stable:   "v1",
+			Rollout:  map[string]int{"v2": 10},
+		},
+		"no-stable":   {Versions: map[string]config.PipelineVersion{"v1": {Model: "a"}, "v2": {Model: "b"}}},
+		"bad-rollout": {PipelineVersion: config.PipelineVersion{Model: "a"}, Rollout: map[string]int{"v9": 10}},
+	}, logrus.New())
+
+	assert.Len(t, pipelines, 2)
+	assert.Equal(t, "default", pipelines["support-bot"].stable)
+	assert.Equal(t, map[string]int{"v2": 10}, pipelines["versioned"].rollout)
+}
+
+func TestPipeline_Pick(t *testing.T) {
+	p, err := newPipeline("bot", config.Pipeline{
+		PipelineVersion: config.PipelineVersion{Model: "gemini-2.5-flash"},
+		Versions:        map[string]config.PipelineVersion{"v2": {Model: "gemini-2.5-pro"}},
+	}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "default", p.pick().name)
+
+	require.NoError(t, p.setRollout("default", map[string]int{"v2": 100}))
+	assert.Equal(t, "v2", p.pick().name)
+
+	// 约一半的请求使用灰度版本
+	require.NoError(t, p.setRollout("default", map[string]int{"v2": 50}))
+	counts := map[string]int{}
+	for range 1000 {
+		counts[p.pick().name]++
+	}
+	assert.InDelta(t, 500, counts["v2"], 100)
+
+	assert.ErrorIs(t, p.setRollout("default", map[string]int{"v2": 101}), ErrInvalidPipeline)
+	assert.ErrorIs(t, p.setRollout("v3", nil), ErrInvalidPipeline)
+}
+
+func TestPipeline_Apply(t *testing.T) {
+	temperature := float32(0.2)
+	maxTokens := 256
+	p := &pipelineVersion{config: config.PipelineVersion{
+		Model:           "gemini-2.5-flash",
+		SystemPrompt:    "You are a support agent.",
+		Temperature:     &temperature,
+		MaxOutputTokens: &maxTokens,
+	}}
+
+	clientTemperature := float32(0.9)
+	req := &models.GeminiRequest{
+		SystemInstruction: &models.GeminiSystemInstruction{Parts: []models.GeminiPart{{Text: "client"}}},
+		GenerationConfig:  &models.GeminiGenerationConfig{Temperature: &clientTemperature},
+	}
+	applied := p.apply(req)
+
+	// 请求已设置的参数优先，未设置的使用流水线默认值
+	assert.Equal(t, float32(0.9), *applied.GenerationConfig.Temperature)
+	assert.Equal(t, 256, *applied.GenerationConfig.MaxOutputTokens)
+	assert.Equal(t, []models.GeminiPart{{Text: "You are a support agent."}}, applied.SystemInstruction.Parts)
+	// 原请求不被修改
+	assert.Nil(t, req.GenerationConfig.MaxOutputTokens)
+	assert.Equal(t, "client", req.SystemInstruction.Parts[0].Text)
+
+	p.config.SystemPromptMode = "append"
+	applied = p.apply(req)
+	assert.Equal(t, []models.GeminiPart{{Text: "client"}, {Text: "You are a support agent."}}, applied.SystemInstruction.Parts)
+}
+
+func TestGeminiClient_SendRequest_PipelineFallback(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.APIMode = config.AIStudio
+	cfg.MaxRetries = 1
+	cfg.Pipelines = map[string]config.Pipeline{
+		"support-bot": {PipelineVersion: config.PipelineVersion{Model: "gemini-2.5-pro", Fallbacks: []string{"gemini-2.5-flash"}}},
+	}
+	client := NewGeminiClient(cfg, nil, logrus.New())
+
+	var paths []string
+	client.client.Transport = roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		paths = append(paths, req.URL.Path)
+		if strings.Contains(req.URL.Path, "gemini-2.5-pro") {
+			return &http.Response{StatusCode: http.StatusServiceUnavailable, Body: io.NopCloser(strings.NewReader(`{}`))}, nil
+		}
+		body := `{"candidates":[{"content":{"role":"model","parts":[{"text":"hi"}]},"finishReason":"STOP"}]}`
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(body))}, nil
+	})
+
+	resp, err := client.SendRequest(context.Background(), "support-bot", &models.GeminiRequest{
+		Contents: []models.GeminiContent{{Role: "user", Parts: []models.GeminiPart{{Text: "hello"}}}},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "hi", resp.Candidates[0].Content.Parts[0].Text)
+	assert.Equal(t, []string{
+		"/v1beta/models/gemini-2.5-pro:generateContent",
+		"/v1beta/models/gemini-2.5-flash:generateContent",
+	}, paths)
+}
+
+func TestGeminiClient_SendRequestRaw_PipelineKeepsRequest(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.APIMode = config.AIStudio
+	cfg.ResponseLanguage = "French"
+	cfg.Pipelines = map[string]config.Pipeline{
+		"support-bot": {PipelineVersion: config.PipelineVersion{Model: "gemini-2.5-flash", SystemPrompt: "Be brief."}},
+	}
+	client := NewGeminiClient(cfg, nil, logrus.New())
+	client.client.Transport = roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		body := `{"candidates":[{"content":{"role":"model","parts":[{"text":"hi"}]},"finishReason":"STOP"}]}`
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(body))}, nil
+	})
+	client.AddInterceptor(InterceptorFuncs{Request: func(ctx context.Context, modelID string, req *models.GeminiRequest) error {
+		req.Contents[0].Parts[0].Text = "rewritten"
+		return nil
+	}})
+
+	req := &models.GeminiRequest{Contents: []models.GeminiContent{{Role: "user", Parts: []models.GeminiPart{{Text: "hello"}}}}}
+	for _, modelID := range []string{"support-bot", "gemini-2.5-flash"} {
+		_, err := client.SendRequestRaw(context.Background(), modelID, req)
+		require.NoError(t, err)
+		// 系统提示词、回复语言和拦截器的修改不会写回调用方的请求
+		assert.Nil(t, req.SystemInstruction, modelID)
+		assert.Equal(t, "hello", req.Contents[0].Parts[0].Text, modelID)
+	}
+}
+
+func TestGeminiClient_PipelineAdmin(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Pipelines = map[string]config.Pipeline{
+		"support-bot": {PipelineVersion: config.PipelineVersion{Model: "gemini-2.5-flash"}},
+	}
+	client := NewGeminiClient(cfg, nil, logrus.New())
+
+	_, err := client.PutPipelineVersion("missing", "v2", config.PipelineVersion{Model: "gemini-2.5-pro"})
+	assert.ErrorIs(t, err, ErrPipelineNotFound)
+	_, err = client.PutPipelineVersion("support-bot", "v2", config.PipelineVersion{Model: "support-bot"})
+	assert.ErrorIs(t, err, ErrInvalidPipeline)
+	_, err = client.PutPipelineVersion("support-bot", "default", config.PipelineVersion{Model: "gemini-2.5-pro"})
+	assert.ErrorIs(t, err, ErrPipelineVersionInUse)
+
+	status, err := client.PutPipelineVersion("support-bot", "v2", config.PipelineVersion{Model: "gemini-2.5-pro"})
+	require.NoError(t, err)
+	assert.Len(t, status.Versions, 2)
+
+	status, err = client.SetPipelineRollout("support-bot", "", map[string]int{"v2": 20})
+	require.NoError(t, err)
+	assert.Equal(t, "default", status.Stable)
+	assert.Equal(t, map[string]int{"v2": 20}, status.Rollout)
+
+	status, err = client.RollbackPipeline("support-bot")
+	require.NoError(t, err)
+	assert.Empty(t, status.Rollout)
+	assert.Equal(t, "gemini-2.5-flash", client.pipelineModel("support-bot"))
+
+	// 提升为稳定版本
+	_, err = client.SetPipelineRollout("support-bot", "v2", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "gemini-2.5-pro", client.pipelineModel("support-bot"))
+	assert.Equal(t, []string{"support-bot"}, []string{client.Pipelines()[0].Name})
+}
@@ -0,0 +1,70 @@
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/ba0gu0/gemini-go-proxy/pkg/models"
+)
+
+// ErrPromptBlocked 提示词被上游拦截（promptFeedback.blockReason），且配置为返回错误
+var ErrPromptBlocked = errors.New("prompt blocked by upstream")
+
+// promptBlockError 解析响应JSON（兼容Code Assist的 response 包装）中的 promptFeedback，
+// 提示词被拦截时返回包装了ErrPromptBlocked的错误，否则返回nil
+func promptBlockError(data []byte) error {
+	var payload struct {
+		PromptFeedback *models.GeminiPromptFeedback `json:"promptFeedback"`
+	}
+	if err := json.Unmarshal(unwrapCodeAssistPayload(data), &payload); err != nil || !payload.PromptFeedback.Blocked() {
+		return nil
+	}
+
+	feedback := payload.PromptFeedback
+	if feedback.BlockReasonMessage != "" {
+		return fmt.Errorf("%w: %s (%s)", ErrPromptBlocked, feedback.BlockReason, feedback.BlockReasonMessage)
+	}
+	return fmt.Errorf("%w: %s", ErrPromptBlocked, feedback.BlockReason)
+}
+
+// checkPromptBlocked 配置了 prompt_blocked_as_error 时检查非流式响应体，提示词被拦截时返回错误
+func (c *GeminiClient) checkPromptBlocked(body []byte) error {
+	if !c.config.PromptBlockedAsError {
+		return nil
+	}
+	return promptBlockError(body)
+}
+
+// awaitPromptFeedback 配置了 prompt_blocked_as_error 时预读流式响应的第一个数据事件，
+// 提示词被拦截时关闭响应体并返回错误；已读取的数据在返回的响应体中保留
+func (c *GeminiClient) awaitPromptFeedback(body io.ReadCloser) (io.ReadCloser, error) {
+	if !c.config.PromptBlockedAsError {
+		return body, nil
+	}
+
+	reader := bufio.NewReader(body)
+	var consumed []byte
+	for {
+		line, err := reader.ReadBytes('\n')
+		consumed = append(consumed, line...)
+		if data, ok := bytes.CutPrefix(line, []byte("data: ")); ok {
+			if blockErr := promptBlockError(bytes.TrimSpace(data)); blockErr != nil {
+				body.Close()
+				return nil, blockErr
+			}
+			break
+		}
+		// 读取错误（包括空响应）留给调用方读取时处理
+		if err != nil {
+			break
+		}
+	}
+	return &bufferedReadCloser{
+		Reader: bufio.NewReader(io.MultiReader(bytes.NewReader(consumed), reader)),
+		Closer: body,
+	}, nil
+}
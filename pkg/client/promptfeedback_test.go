@@ -0,0 +1,44 @@
+package client
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/ba0gu0/gemini-go-proxy/pkg/config"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPromptBlockError(t *testing.T) {
+	assert.NoError(t, promptBlockError([]byte(`{"candidates":[{"content":{"parts":[{"text":"hi"}]}}]}`)))
+	assert.NoError(t, promptBlockError([]byte(`{"promptFeedback":{"blockReason":"BLOCK_REASON_UNSPECIFIED"}}`)))
+	assert.NoError(t, promptBlockError([]byte(`not json`)))
+
+	err := promptBlockError([]byte(`{"promptFeedback":{"blockReason":"SAFETY"}}`))
+	assert.ErrorIs(t, err, ErrPromptBlocked)
+	assert.Contains(t, err.Error(), "SAFETY")
+
+	// Code Assist 的 response 包装
+	err = promptBlockError([]byte(`{"response":{"promptFeedback":{"blockReason":"OTHER","blockReasonMessage":"unsupported"}}}`))
+	assert.ErrorIs(t, err, ErrPromptBlocked)
+	assert.Contains(t, err.Error(), "OTHER (unsupported)")
+}
+
+func TestGeminiClient_AwaitPromptFeedback(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.PromptBlockedAsError = true
+	client := NewGeminiClient(cfg, nil, logrus.New())
+
+	_, err := client.awaitPromptFeedback(io.NopCloser(strings.NewReader("data: {\"promptFeedback\":{\"blockReason\":\"SAFETY\"}}\r\n\r\n")))
+	assert.ErrorIs(t, err, ErrPromptBlocked)
+
+	// 未被拦截时已预读的数据原样保留
+	stream := ": keep-alive\n\ndata: {\"candidates\":[]}\r\n\r\ndata: {\"usageMetadata\":{}}\n\n"
+	body, err := client.awaitPromptFeedback(io.NopCloser(strings.NewReader(stream)))
+	require.NoError(t, err)
+	data, err := io.ReadAll(body)
+	require.NoError(t, err)
+	assert.Equal(t, stream, string(data))
+}
@@ -0,0 +1,64 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// ProxyTestResult 单个代理的连通性测试结果，URL中的密码已隐藏
+type ProxyTestResult struct {
+	URL     string
+	Latency time.Duration
+	Err     error
+}
+
+// Reachable 代理是否可用
+func (r ProxyTestResult) Reachable() bool {
+	return r.Err == nil
+}
+
+// TestProxies 并发测试每个代理到上游端点的连通性，按配置顺序返回结果；
+// 配置了 proxy_health_check.url 时使用该地址，否则使用当前模式的上游端点。不影响代理的健康状态
+func (c *GeminiClient) TestProxies(ctx context.Context) []ProxyTestResult {
+	probeURL := c.upstreamEndpoint()
+	if cfg := c.config.ProxyHealthCheck; cfg != nil && cfg.URL != "" {
+		probeURL = cfg.URL
+	}
+
+	proxyURLs, _ := c.proxies()
+	results := make([]ProxyTestResult, len(proxyURLs))
+	var wg sync.WaitGroup
+	for i, proxyURL := range proxyURLs {
+		wg.Add(1)
+		go func(i int, proxyURL string) {
+			defer wg.Done()
+			start := time.Now()
+			err := c.probeProxyURL(ctx, proxyURL, probeURL)
+			results[i] = ProxyTestResult{URL: redactProxyURL(proxyURL), Latency: time.Since(start), Err: err}
+		}(i, proxyURL)
+	}
+	wg.Wait()
+	return results
+}
+
+// WriteProxyReport 以表格输出代理连通性测试结果，返回不可用的代理数
+func WriteProxyReport(w io.Writer, results []ProxyTestResult) int {
+	table := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(table, "PROXY\tSTATUS\tLATENCY\tERROR")
+	dead := 0
+	for _, result := range results {
+		if result.Reachable() {
+			fmt.Fprintf(table, "%s\tOK\t%v\t\n", result.URL, result.Latency.Round(time.Millisecond))
+			continue
+		}
+		dead++
+		fmt.Fprintf(table, "%s\tDEAD\t-\t%v\n", result.URL, result.Err)
+	}
+	table.Flush()
+	fmt.Fprintf(w, "%d/%d proxies reachable\n", len(results)-dead, len(results))
+	return dead
+}
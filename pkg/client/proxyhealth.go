@@ -0,0 +1,265 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/ba0gu0/gemini-go-proxy/pkg/config"
+)
+
+const (
+	defaultProxyProbeURL         = "https://www.gstatic.com/generate_204"
+	defaultProxyProbeInterval    = 60 * time.Second
+	defaultProxyProbeTimeout     = 10 * time.Second
+	defaultProxyFailureThreshold = 3
+	defaultProxyEvictDuration    = 5 * time.Minute
+)
+
+// ProxyStatus 单个代理的健康状态，URL中的密码已隐藏
+type ProxyStatus struct {
+	URL                 string     `json:"url"`
+	Healthy             bool       `json:"healthy"`
+	Requests            int64      `json:"requests"`
+	Failures            int64      `json:"failures"`
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	LatencyMs           int64      `json:"latency_ms,omitempty"`
+	LastError           string     `json:"last_error,omitempty"`
+	EvictedUntil        *time.Time `json:"evicted_until,omitempty"`
+}
+
+// proxyStats 单个代理的请求和探测统计
+type proxyStats struct {
+	requests     int64
+	failures     int64
+	consecutive  int
+	latency      time.Duration
+	lastError    string
+	evictedUntil time.Time
+}
+
+// proxyHealth 跟踪代理的连续失败次数，失败达到阈值的代理在一段时间内不参与轮换
+type proxyHealth struct {
+	mu        sync.Mutex
+	threshold int
+	evictFor  time.Duration
+	stats     map[string]*proxyStats
+	now       func() time.Time
+}
+
+// newProxyHealth 按配置创建代理健康跟踪器，未配置时使用默认阈值
+func newProxyHealth(cfg *config.ProxyHealthCheck) *proxyHealth {
+	health := &proxyHealth{
+		threshold: defaultProxyFailureThreshold,
+		evictFor:  defaultProxyEvictDuration,
+		stats:     make(map[string]*proxyStats),
+		now:       time.Now,
+	}
+	if cfg != nil {
+		if cfg.FailureThreshold > 0 {
+			health.threshold = cfg.FailureThreshold
+		}
+		if cfg.EvictSeconds > 0 {
+			health.evictFor = time.Duration(cfg.EvictSeconds) * time.Second
+		}
+	}
+	return health
+}
+
+// statsFor 返回代理的统计，调用方需持有锁
+func (h *proxyHealth) statsFor(proxyURL string) *proxyStats {
+	stats, ok := h.stats[proxyURL]
+	if !ok {
+		stats = &proxyStats{}
+		h.stats[proxyURL] = stats
+	}
+	return stats
+}
+
+// recordSuccess 记录一次成功（收到任意HTTP响应即视为代理可用），被剔除的代理立即恢复，返回代理是否因此恢复
+func (h *proxyHealth) recordSuccess(proxyURL string, latency time.Duration) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	stats := h.statsFor(proxyURL)
+	stats.requests++
+	stats.consecutive = 0
+	stats.latency = latency
+	stats.lastError = ""
+	restored := stats.evictedUntil.After(h.now())
+	stats.evictedUntil = time.Time{}
+	return restored
+}
+
+// recordFailure 记录一次网络失败，连续失败达到阈值时剔除代理，返回代理是否因此被剔除
+func (h *proxyHealth) recordFailure(proxyURL string, err error) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	stats := h.statsFor(proxyURL)
+	stats.requests++
+	stats.failures++
+	stats.consecutive++
+	if err != nil {
+		stats.lastError = err.Error()
+	}
+	now := h.now()
+	if stats.consecutive < h.threshold || stats.evictedUntil.After(now) {
+		return false
+	}
+	stats.evictedUntil = now.Add(h.evictFor)
+	return true
+}
+
+// available 返回未被剔除的代理，全部被剔除时返回全部代理，避免没有代理可用
+func (h *proxyHealth) available(proxyURLs []string) []string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	now := h.now()
+	healthy := make([]string, 0, len(proxyURLs))
+	for _, proxyURL := range proxyURLs {
+		if stats, ok := h.stats[proxyURL]; ok && stats.evictedUntil.After(now) {
+			continue
+		}
+		healthy = append(healthy, proxyURL)
+	}
+	if len(healthy) == 0 {
+		return proxyURLs
+	}
+	return healthy
+}
+
+// snapshot 返回代理列表的健康状态
+func (h *proxyHealth) snapshot(proxyURLs []string) []ProxyStatus {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	now := h.now()
+	statuses := make([]ProxyStatus, 0, len(proxyURLs))
+	for _, proxyURL := range proxyURLs {
+		status := ProxyStatus{URL: redactProxyURL(proxyURL), Healthy: true}
+		if stats, ok := h.stats[proxyURL]; ok {
+			status.Requests = stats.requests
+			status.Failures = stats.failures
+			status.ConsecutiveFailures = stats.consecutive
+			status.LatencyMs = stats.latency.Milliseconds()
+			status.LastError = stats.lastError
+			if stats.evictedUntil.After(now) {
+				evictedUntil := stats.evictedUntil
+				status.Healthy = false
+				status.EvictedUntil = &evictedUntil
+			}
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
+
+// ProxyHealth 返回代理池中每个代理的健康状态
+func (c *GeminiClient) ProxyHealth() []ProxyStatus {
+	proxyURLs, _ := c.proxies()
+	return c.proxyHealth.snapshot(proxyURLs)
+}
+
+// recordProxyResult 根据一次上游请求的结果更新所用代理的健康状态，非网络错误不计为代理失败
+func (c *GeminiClient) recordProxyResult(proxyURL string, latency time.Duration, err error) {
+	if proxyURL == "" {
+		return
+	}
+	switch {
+	case err == nil:
+		if c.proxyHealth.recordSuccess(proxyURL, latency) {
+			c.logger.Infof("Proxy %s recovered, returning it to rotation", redactProxyURL(proxyURL))
+		}
+	case c.isNetworkError(err):
+		if c.proxyHealth.recordFailure(proxyURL, err) {
+			c.logger.Warnf("Proxy %s failed %d times in a row, removing it from rotation for %s",
+				redactProxyURL(proxyURL), c.proxyHealth.threshold, c.proxyHealth.evictFor)
+		}
+	}
+}
+
+// RunProxyHealthChecks 定期探测代理池中的每个代理，直到ctx取消；
+// 未配置 proxy_health_check 或探测间隔为负数时直接返回
+func (c *GeminiClient) RunProxyHealthChecks(ctx context.Context) {
+	cfg := c.config.ProxyHealthCheck
+	if cfg == nil || cfg.IntervalSeconds < 0 {
+		return
+	}
+	interval := time.Duration(cfg.IntervalSeconds) * time.Second
+	if interval <= 0 {
+		interval = defaultProxyProbeInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		c.CheckProxies(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// CheckProxies 并发探测代理池中的每个代理并更新健康状态，返回探测后的状态
+func (c *GeminiClient) CheckProxies(ctx context.Context) []ProxyStatus {
+	proxyURLs, _ := c.proxies()
+	var wg sync.WaitGroup
+	for _, proxyURL := range proxyURLs {
+		wg.Add(1)
+		go func(proxyURL string) {
+			defer wg.Done()
+			start := time.Now()
+			err := c.probeProxy(ctx, proxyURL)
+			if ctx.Err() != nil {
+				return
+			}
+			if err != nil {
+				c.logger.Debugf("Proxy probe via %s failed: %v", redactProxyURL(proxyURL), err)
+				// 探测失败都计为代理失败，不区分错误类型
+				if c.proxyHealth.recordFailure(proxyURL, err) {
+					c.logger.Warnf("Proxy %s failed %d health checks in a row, removing it from rotation for %s",
+						redactProxyURL(proxyURL), c.proxyHealth.threshold, c.proxyHealth.evictFor)
+				}
+				return
+			}
+			c.recordProxyResult(proxyURL, time.Since(start), nil)
+		}(proxyURL)
+	}
+	wg.Wait()
+	return c.proxyHealth.snapshot(proxyURLs)
+}
+
+// probeProxy 通过代理请求探测地址，收到任意HTTP响应即视为代理可用
+func (c *GeminiClient) probeProxy(ctx context.Context, proxyURL string) error {
+	probeURL := defaultProxyProbeURL
+	if cfg := c.config.ProxyHealthCheck; cfg != nil && cfg.URL != "" {
+		probeURL = cfg.URL
+	}
+	return c.probeProxyURL(ctx, proxyURL, probeURL)
+}
+
+// probeProxyURL 通过代理请求指定地址，超时时间取自 proxy_health_check
+func (c *GeminiClient) probeProxyURL(ctx context.Context, proxyURL, probeURL string) error {
+	timeout := defaultProxyProbeTimeout
+	if cfg := c.config.ProxyHealthCheck; cfg != nil && cfg.TimeoutSeconds > 0 {
+		timeout = time.Duration(cfg.TimeoutSeconds) * time.Second
+	}
+
+	transport, err := c.pooledTransport(proxyURL)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, probeURL, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := (&http.Client{Transport: transport}).Do(req)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
@@ -0,0 +1,112 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ba0gu0/gemini-go-proxy/pkg/config"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProxyHealth_EvictAndRestore(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	health := newProxyHealth(&config.ProxyHealthCheck{FailureThreshold: 2, EvictSeconds: 60})
+	health.now = func() time.Time { return now }
+	proxies := []string{"http://a:8080", "http://b:8080"}
+
+	assert.False(t, health.recordFailure(proxies[0], errors.New("connection refused")))
+	assert.Equal(t, proxies, health.available(proxies))
+	assert.True(t, health.recordFailure(proxies[0], errors.New("connection refused")))
+	assert.Equal(t, proxies[1:], health.available(proxies))
+
+	status := health.snapshot(proxies)
+	assert.False(t, status[0].Healthy)
+	assert.Equal(t, 2, status[0].ConsecutiveFailures)
+	assert.Equal(t, "connection refused", status[0].LastError)
+	require.NotNil(t, status[0].EvictedUntil)
+	assert.True(t, status[1].Healthy)
+
+	// 全部被剔除时退回使用全部代理
+	health.recordFailure(proxies[1], nil)
+	health.recordFailure(proxies[1], nil)
+	assert.Equal(t, proxies, health.available(proxies))
+
+	// 剔除到期后重新参与轮换，成功后立即恢复
+	now = now.Add(61 * time.Second)
+	assert.Equal(t, proxies, health.available(proxies))
+	now = now.Add(-61 * time.Second)
+	assert.True(t, health.recordSuccess(proxies[1], 10*time.Millisecond))
+	assert.Equal(t, proxies[1:], health.available(proxies))
+}
+
+func TestGeminiClient_CheckProxies(t *testing.T) {
+	// 作为HTTP代理直接返回204
+	live := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer live.Close()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	dead := "http://user:secret@" + listener.Addr().String()
+	listener.Close()
+
+	cfg := config.DefaultConfig()
+	cfg.ProxyURLs = []string{live.URL, dead}
+	cfg.ProxyHealthCheck = &config.ProxyHealthCheck{FailureThreshold: 1, TimeoutSeconds: 2, URL: "http://probe.invalid/generate_204"}
+	client := NewGeminiClient(cfg, nil, logrus.New())
+
+	statuses := client.CheckProxies(context.Background())
+	require.Len(t, statuses, 2)
+	assert.True(t, statuses[0].Healthy)
+	assert.Equal(t, int64(1), statuses[0].Requests)
+	assert.False(t, statuses[1].Healthy)
+	assert.NotContains(t, statuses[1].URL, "secret")
+	assert.NotEmpty(t, statuses[1].LastError)
+
+	// 被剔除的代理不再被选中
+	for i := 0; i < 20; i++ {
+		require.NoError(t, client.RotateProxy())
+		assert.Equal(t, live.URL, client.currentProxy)
+	}
+}
+
+func TestGeminiClient_TestProxies(t *testing.T) {
+	live := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer live.Close()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	dead := "http://user:secret@" + listener.Addr().String()
+	listener.Close()
+
+	cfg := config.DefaultConfig()
+	cfg.ProxyURLs = []string{live.URL, dead}
+	cfg.ProxyHealthCheck = &config.ProxyHealthCheck{FailureThreshold: 1, TimeoutSeconds: 2, URL: "http://probe.invalid/generate_204"}
+	client := NewGeminiClient(cfg, nil, logrus.New())
+
+	results := client.TestProxies(context.Background())
+	require.Len(t, results, 2)
+	assert.True(t, results[0].Reachable())
+	assert.False(t, results[1].Reachable())
+	assert.NotContains(t, results[1].URL, "secret")
+
+	// 测试结果不影响代理的健康状态
+	for _, status := range client.ProxyHealth() {
+		assert.True(t, status.Healthy)
+		assert.Zero(t, status.Requests)
+	}
+
+	var report strings.Builder
+	assert.Equal(t, 1, WriteProxyReport(&report, results))
+	assert.Contains(t, report.String(), "DEAD")
+	assert.Contains(t, report.String(), "1/2 proxies reachable")
+}
@@ -0,0 +1,66 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/ba0gu0/gemini-go-proxy/pkg/config"
+)
+
+// DataRegionHeader 客户端要求的数据驻留区域头部，如 "eu"
+const DataRegionHeader = "X-Data-Region"
+
+// ErrDataRegionUnavailable 请求的数据区域无法满足：未配置该区域、当前模式不支持或模型固定在其他区域
+var ErrDataRegionUnavailable = errors.New("data region unavailable")
+
+type dataRegionKey struct{}
+
+// WithDataRegion 返回要求在指定Vertex AI location处理请求的上下文，location由 ResolveDataRegion 得到
+func WithDataRegion(ctx context.Context, location string) context.Context {
+	return context.WithValue(ctx, dataRegionKey{}, location)
+}
+
+// dataRegionLocation 获取上下文中要求的Vertex AI location
+func dataRegionLocation(ctx context.Context) (string, bool) {
+	location, ok := ctx.Value(dataRegionKey{}).(string)
+	return location, ok && location != ""
+}
+
+// ResolveDataRegion 将客户端请求的数据区域映射为配置的Vertex AI location
+func (c *GeminiClient) ResolveDataRegion(region string) (string, error) {
+	if c.apiMode() != config.VertexAI {
+		return "", fmt.Errorf("%w: data region %q requires vertex_ai api mode", ErrDataRegionUnavailable, region)
+	}
+	if location, ok := c.config.DataRegions[strings.ToLower(region)]; ok && location != "" {
+		return location, nil
+	}
+
+	available := make([]string, 0, len(c.config.DataRegions))
+	for name := range c.config.DataRegions {
+		available = append(available, name)
+	}
+	slices.Sort(available)
+	return "", fmt.Errorf("%w: data region %q is not configured (available: %s)",
+		ErrDataRegionUnavailable, region, strings.Join(available, ", "))
+}
+
+// checkDataRegion 确认上游地址位于上下文要求的location，避免固定在其他区域的模型或资源绕过数据驻留要求
+func checkDataRegion(ctx context.Context, apiURL string) error {
+	location, ok := dataRegionLocation(ctx)
+	if !ok {
+		return nil
+	}
+	target, err := url.Parse(apiURL)
+	if err != nil {
+		return fmt.Errorf("invalid upstream URL %s: %w", apiURL, err)
+	}
+	expected, _ := url.Parse(fmt.Sprintf(VertexAPIEndpoint, location))
+	if target.Host != expected.Host {
+		return fmt.Errorf("%w: upstream %s is outside the requested location %s", ErrDataRegionUnavailable, target.Host, location)
+	}
+	return nil
+}
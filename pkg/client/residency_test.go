@@ -0,0 +1,58 @@
+package client
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ba0gu0/gemini-go-proxy/pkg/auth"
+	"github.com/ba0gu0/gemini-go-proxy/pkg/config"
+	"github.com/ba0gu0/gemini-go-proxy/pkg/models"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newDataRegionTestClient(mode config.APIMode) *GeminiClient {
+	cfg := config.DefaultConfig()
+	cfg.APIMode = mode
+	cfg.Location = "us-central1"
+	cfg.DataRegions = map[string]string{"eu": "europe-west4", "us": "us-central1"}
+	cfg.VertexEndpoints = map[string]string{"pinned-model": "projects/p2/locations/asia-east1/endpoints/987"}
+	logger := logrus.New()
+	return NewGeminiClient(cfg, auth.NewGoogleAuth(&models.GoogleAuthConfig{ProjectID: "test-project"}, logger), logger)
+}
+
+func TestGeminiClient_ResolveDataRegion(t *testing.T) {
+	client := newDataRegionTestClient(config.VertexAI)
+
+	location, err := client.ResolveDataRegion("EU")
+	require.NoError(t, err)
+	assert.Equal(t, "europe-west4", location)
+
+	_, err = client.ResolveDataRegion("apac")
+	assert.ErrorIs(t, err, ErrDataRegionUnavailable)
+	assert.Contains(t, err.Error(), "available: eu, us")
+
+	// 非Vertex AI模式无法选择区域
+	_, err = newDataRegionTestClient(config.CodeAssist).ResolveDataRegion("eu")
+	assert.ErrorIs(t, err, ErrDataRegionUnavailable)
+}
+
+func TestGeminiClient_DataRegionRouting(t *testing.T) {
+	client := newDataRegionTestClient(config.VertexAI)
+	ctx := WithDataRegion(context.Background(), "europe-west4")
+
+	apiURL := mustBuildAPIURL(t, client, ctx, "gemini-2.5-flash", "generateContent")
+	assert.Equal(t, "https://europe-west4-aiplatform.googleapis.com/v1/projects/test-project/locations/europe-west4/publishers/google/models/gemini-2.5-flash:generateContent", apiURL)
+	_, err := client.createRequest(ctx, "POST", apiURL, nil)
+	assert.NoError(t, err)
+
+	// 固定在其他区域的模型不能用于该数据区域
+	apiURL = mustBuildAPIURL(t, client, ctx, "pinned-model", "generateContent")
+	_, err = client.createRequest(ctx, "POST", apiURL, nil)
+	assert.ErrorIs(t, err, ErrDataRegionUnavailable)
+
+	// 未要求数据区域时不受限制
+	_, err = client.createRequest(context.Background(), "POST", apiURL, nil)
+	assert.NoError(t, err)
+}
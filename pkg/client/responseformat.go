@@ -0,0 +1,138 @@
+package client
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ba0gu0/gemini-go-proxy/pkg/models"
+)
+
+// unsupportedResponseSchemaKeys Gemini responseSchema（OpenAPI子集）不支持的JSON Schema关键字，
+// 比函数声明的 unsupportedSchemaKeys 更严格
+var unsupportedResponseSchemaKeys = map[string]bool{
+	"$schema":               true,
+	"$id":                   true,
+	"$defs":                 true,
+	"definitions":           true,
+	"additionalProperties":  true,
+	"strict":                true,
+	"const":                 true,
+	"examples":              true,
+	"default":               true,
+	"patternProperties":     true,
+	"unevaluatedProperties": true,
+}
+
+// maxSchemaRefDepth 展开$ref的最大深度，避免递归schema无限展开
+const maxSchemaRefDepth = 8
+
+// applyResponseFormat 将OpenAI response_format映射为Gemini responseMimeType/responseSchema
+func applyResponseFormat(config *models.GeminiGenerationConfig, format *models.OpenAIResponseFormat) error {
+	if format == nil {
+		return nil
+	}
+
+	switch format.Type {
+	case "", "text":
+		return nil
+	case "json_object":
+		config.ResponseMimeType = "application/json"
+		return nil
+	case "json_schema":
+		if format.JSONSchema == nil || format.JSONSchema.Schema == nil {
+			return fmt.Errorf("response_format.json_schema.schema is required for type json_schema")
+		}
+		config.ResponseMimeType = "application/json"
+		definitions := schemaDefinitions(format.JSONSchema.Schema)
+		schema, ok := convertJSONSchema(format.JSONSchema.Schema, definitions, 0).(map[string]any)
+		if !ok {
+			return fmt.Errorf("response_format.json_schema.schema must be an object")
+		}
+		if description := format.JSONSchema.Description; description != "" {
+			if _, exists := schema["description"]; !exists {
+				schema["description"] = description
+			}
+		}
+		config.ResponseSchema = schema
+		return nil
+	default:
+		return fmt.Errorf("unsupported response_format type: %s", format.Type)
+	}
+}
+
+// schemaDefinitions 收集 $defs 和 definitions 中的子schema，供$ref引用
+func schemaDefinitions(schema map[string]any) map[string]any {
+	definitions := make(map[string]any)
+	for _, key := range []string{"$defs", "definitions"} {
+		if defs, ok := schema[key].(map[string]any); ok {
+			for name, def := range defs {
+				definitions["#/"+key+"/"+name] = def
+			}
+		}
+	}
+	return definitions
+}
+
+// convertJSONSchema 将JSON Schema转换为Gemini支持的子集：展开本地$ref、
+// 去除不支持的关键字、将 ["string","null"] 形式的类型转换为 nullable，返回副本
+func convertJSONSchema(value any, definitions map[string]any, depth int) any {
+	switch v := value.(type) {
+	case map[string]any:
+		if ref, ok := v["$ref"].(string); ok {
+			if def, found := definitions[ref]; found && depth < maxSchemaRefDepth {
+				return convertJSONSchema(def, definitions, depth+1)
+			}
+			// 无法展开的引用退化为任意对象
+			return map[string]any{"type": "object"}
+		}
+
+		converted := make(map[string]any, len(v))
+		for key, field := range v {
+			if unsupportedResponseSchemaKeys[key] {
+				continue
+			}
+			switch key {
+			case "properties":
+				// properties的键是字段名，不能当作关键字过滤
+				if properties, ok := field.(map[string]any); ok {
+					convertedProperties := make(map[string]any, len(properties))
+					for name, property := range properties {
+						convertedProperties[name] = convertJSONSchema(property, definitions, depth)
+					}
+					converted[key] = convertedProperties
+					continue
+				}
+			case "type":
+				if types, ok := field.([]any); ok {
+					field = nullableType(types, converted)
+				}
+			}
+			converted[key] = convertJSONSchema(field, definitions, depth)
+		}
+		return converted
+	case []any:
+		converted := make([]any, len(v))
+		for i, item := range v {
+			converted[i] = convertJSONSchema(item, definitions, depth)
+		}
+		return converted
+	default:
+		return value
+	}
+}
+
+// nullableType 将类型数组中的 "null" 转换为 nullable，返回剩余的单一类型
+func nullableType(types []any, schema map[string]any) any {
+	var remaining []any
+	for _, t := range types {
+		if name, ok := t.(string); ok && strings.EqualFold(name, "null") {
+			schema["nullable"] = true
+			continue
+		}
+		remaining = append(remaining, t)
+	}
+	if len(remaining) == 1 {
+		return remaining[0]
+	}
+	return remaining
+}
@@ -0,0 +1,87 @@
+package client
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/ba0gu0/gemini-go-proxy/pkg/models"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpenAIToGeminiRequest_ResponseFormat(t *testing.T) {
+	converter := NewFormatConverter(logrus.New())
+	request := func(format string) *models.OpenAIRequest {
+		req := &models.OpenAIRequest{
+			Model:    "gemini-2.5-flash",
+			Messages: []models.OpenAIMessage{{Role: "user", Content: "Give me a person"}},
+		}
+		require.NoError(t, json.Unmarshal([]byte(`{"response_format":`+format+`}`), req))
+		return req
+	}
+
+	geminiReq, err := converter.OpenAIToGeminiRequest(request(`{"type":"json_object"}`))
+	require.NoError(t, err)
+	assert.Equal(t, "application/json", geminiReq.GenerationConfig.ResponseMimeType)
+	assert.Nil(t, geminiReq.GenerationConfig.ResponseSchema)
+
+	geminiReq, err = converter.OpenAIToGeminiRequest(request(`{"type":"text"}`))
+	require.NoError(t, err)
+	assert.Empty(t, geminiReq.GenerationConfig.ResponseMimeType)
+
+	geminiReq, err = converter.OpenAIToGeminiRequest(request(`{
+		"type": "json_schema",
+		"json_schema": {
+			"name": "person",
+			"description": "A person",
+			"strict": true,
+			"schema": {
+				"$schema": "https://json-schema.org/draft/2020-12/schema",
+				"type": "object",
+				"additionalProperties": false,
+				"properties": {
+					"name": {"type": "string"},
+					"nickname": {"type": ["string", "null"]},
+					"strict": {"type": "boolean"},
+					"address": {"$ref": "#/$defs/address"}
+				},
+				"required": ["name"],
+				"$defs": {"address": {"type": "object", "properties": {"city": {"type": "string"}}}}
+			}
+		}
+	}`))
+	require.NoError(t, err)
+	assert.Equal(t, "application/json", geminiReq.GenerationConfig.ResponseMimeType)
+	assert.Equal(t, map[string]any{
+		"type":        "object",
+		"description": "A person",
+		"required":    []any{"name"},
+		"properties": map[string]any{
+			"name":     map[string]any{"type": "string"},
+			"nickname": map[string]any{"type": "string", "nullable": true},
+			"strict":   map[string]any{"type": "boolean"},
+			"address":  map[string]any{"type": "object", "properties": map[string]any{"city": map[string]any{"type": "string"}}},
+		},
+	}, geminiReq.GenerationConfig.ResponseSchema)
+
+	_, err = converter.OpenAIToGeminiRequest(request(`{"type":"json_schema"}`))
+	assert.Error(t, err)
+	_, err = converter.OpenAIToGeminiRequest(request(`{"type":"xml"}`))
+	assert.Error(t, err)
+}
+
+func TestConvertJSONSchema_RecursiveRef(t *testing.T) {
+	schema := map[string]any{
+		"$ref": "#/definitions/node",
+		"definitions": map[string]any{
+			"node": map[string]any{
+				"type":       "object",
+				"properties": map[string]any{"child": map[string]any{"$ref": "#/definitions/node"}},
+			},
+		},
+	}
+	converted, ok := convertJSONSchema(schema, schemaDefinitions(schema), 0).(map[string]any)
+	require.True(t, ok)
+	assert.Equal(t, "object", converted["type"])
+}
@@ -0,0 +1,316 @@
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/ba0gu0/gemini-go-proxy/pkg/config"
+)
+
+type nativeRouteKey struct{}
+
+// nativeRouteErrorKey 改写请求时发现的错误，由传输层返回，不发出请求
+type nativeRouteErrorKey struct{}
+
+// nativeRoute 反向代理请求对应的模型和动作
+type nativeRoute struct {
+	model  string
+	stream bool
+}
+
+// WithNativeRoute 返回携带原生路由信息的上下文，供 NativeReverseProxy 改写请求
+func WithNativeRoute(ctx context.Context, model string, stream bool) context.Context {
+	return context.WithValue(ctx, nativeRouteKey{}, nativeRoute{model: model, stream: stream})
+}
+
+// NativeReverseProxy 创建原生Gemini路由的反向代理：请求体原样转发（Code Assist模式仅做外层包装），
+// 由代理注入认证并改写URL，相比完整解码/编码开销更低且保留未建模的字段
+func (c *GeminiClient) NativeReverseProxy() *httputil.ReverseProxy {
+	return &httputil.ReverseProxy{
+		Director:       c.directNativeRequest,
+		ModifyResponse: c.modifyNativeResponse,
+		Transport:      nativeTransport{c},
+		FlushInterval:  -1, // 流式响应立即刷新
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			code, status := http.StatusBadGateway, "api_error"
+			if errors.Is(err, ErrPromptBlocked) || errors.Is(err, ErrInvalidLocation) || errors.Is(err, ErrRequestRejected) {
+				code, status = http.StatusBadRequest, "invalid_request_error"
+			} else {
+				c.logger.Errorf("Native reverse proxy request failed: %v", err)
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(code)
+			json.NewEncoder(w).Encode(map[string]any{
+				"error": map[string]any{
+					"code":    code,
+					"message": err.Error(),
+					"status":  status,
+				},
+			})
+		},
+	}
+}
+
+// nativeTransport 使用客户端当前的传输层（包括代理和自定义传输层）
+type nativeTransport struct {
+	c *GeminiClient
+}
+
+// RoundTrip 发送请求
+func (t nativeTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err, ok := req.Context().Value(nativeRouteErrorKey{}).(error); ok {
+		return nil, err
+	}
+	if err := checkDataRegion(req.Context(), req.URL.String()); err != nil {
+		return nil, err
+	}
+	transport := t.c.client.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	resp, err := transport.RoundTrip(req)
+	if err == nil {
+		t.c.cooldownAccount(req, resp)
+	}
+	return resp, err
+}
+
+// directNativeRequest 改写请求URL、去除客户端凭据并注入上游认证
+func (c *GeminiClient) directNativeRequest(req *http.Request) {
+	route, _ := req.Context().Value(nativeRouteKey{}).(nativeRoute)
+
+	action := "generateContent"
+	if route.stream {
+		action = "streamGenerateContent"
+	}
+	apiURL, err := c.buildAPIURL(req.Context(), route.model, action)
+	if err != nil {
+		*req = *req.WithContext(context.WithValue(req.Context(), nativeRouteErrorKey{}, err))
+		return
+	}
+	target, err := url.Parse(apiURL)
+	if err != nil {
+		c.logger.Errorf("Invalid upstream URL %s: %v", apiURL, err)
+		return
+	}
+	if route.stream && (c.apiMode() == config.CodeAssist || c.apiMode() == config.AIStudio) {
+		query := target.Query()
+		query.Set("alt", "sse")
+		target.RawQuery = query.Encode()
+	}
+	req.URL = target
+	req.Host = target.Host
+
+	// 去除逐跳头部、客户端凭据和客户端标识
+	sanitizeUpstreamHeaders(req.Header)
+	req.Header["X-Forwarded-For"] = nil // 阻止ReverseProxy追加客户端地址
+	// 由传输层自行协商压缩并解压，响应体才能被改写和过滤
+	req.Header.Del("Accept-Encoding")
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", c.config.UserAgent)
+
+	if c.apiMode() == config.VertexAI {
+		c.applyVertexHeaders(req.Context(), req)
+	}
+	if c.auth != nil && c.auth.IsInitialized() {
+		if authed, err := c.setAuthorization(req); err != nil {
+			c.logger.Errorf("Failed to get auth token for reverse proxy: %v", err)
+		} else {
+			*req = *authed
+		}
+	}
+
+	// 添加了拦截器时先对请求体调用 OnRequest，Code Assist需要 { model, project, request } 外层包装，内部请求保持原样
+	codeAssist := c.apiMode() == config.CodeAssist
+	if req.Body != nil && (codeAssist || len(c.interceptorList()) > 0) {
+		raw, err := io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			c.logger.Errorf("Failed to read request body: %v", err)
+			raw = nil
+		}
+		if len(bytes.TrimSpace(raw)) == 0 {
+			raw = []byte("{}")
+		}
+		if raw, err = c.interceptRequestBody(req.Context(), route.model, raw); err != nil {
+			*req = *req.WithContext(context.WithValue(req.Context(), nativeRouteErrorKey{}, err))
+			return
+		}
+		if codeAssist {
+			raw, _ = json.Marshal(struct {
+				Model   string          `json:"model"`
+				Project string          `json:"project"`
+				Request json.RawMessage `json:"request"`
+			}{strings.TrimPrefix(route.model, "models/"), c.projectID(), raw})
+		}
+
+		req.Body = io.NopCloser(bytes.NewReader(raw))
+		req.ContentLength = int64(len(raw))
+		req.Header.Set("Content-Length", strconv.Itoa(len(raw)))
+	}
+
+	c.recordTrace(req.Context(), route.model, 0)
+}
+
+// modifyNativeResponse 记录token用量，Code Assist模式下去掉响应的 response 外层包装，过滤生成内容并调用拦截器；
+// 配置了 prompt_blocked_as_error 时提示词被拦截的响应改为错误
+func (c *GeminiClient) modifyNativeResponse(resp *http.Response) error {
+	if resp.StatusCode != http.StatusOK {
+		return nil
+	}
+	codeAssist := c.apiMode() == config.CodeAssist
+	filter := c.filter
+	checkBlocked := c.config.PromptBlockedAsError
+	// 压缩的响应体先解压再改写，无法解压时返回错误，不跳过过滤原样转发
+	if err := decodeResponseBody(resp); err != nil {
+		return err
+	}
+
+	ctx := resp.Request.Context()
+	route, _ := ctx.Value(nativeRouteKey{}).(nativeRoute)
+	if route.stream {
+		resp.Body = c.withUsageRecorder(ctx, resp.Body)
+		if codeAssist {
+			resp.Body = newCodeAssistSSEReader(resp.Body)
+		}
+		if checkBlocked {
+			body, err := c.awaitPromptFeedback(resp.Body)
+			if err != nil {
+				return err
+			}
+			resp.Body = body
+		}
+		if filter != nil {
+			resp.Body = filter.sseReader(resp.Body, false)
+		}
+		resp.Body = c.interceptSSEReader(ctx, route.model, resp.Body)
+		resp.ContentLength = -1
+		resp.Header.Del("Content-Length")
+		return nil
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return fmt.Errorf("failed to read upstream response: %w", err)
+	}
+	c.recordResponseUsage(ctx, body)
+
+	inner := body
+	if codeAssist {
+		inner = unwrapCodeAssistPayload(body)
+	}
+	if checkBlocked {
+		if err := promptBlockError(inner); err != nil {
+			return err
+		}
+	}
+	if filter != nil {
+		if inner, err = filter.filterResponseBody(inner); err != nil {
+			return err
+		}
+	}
+	if inner, err = c.interceptResponseBody(ctx, route.model, inner); err != nil {
+		return err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(inner))
+	resp.ContentLength = int64(len(inner))
+	resp.Header.Set("Content-Length", strconv.Itoa(len(inner)))
+	return nil
+}
+
+// decodeResponseBody 解压gzip编码的响应体，不支持的编码返回错误
+func decodeResponseBody(resp *http.Response) error {
+	switch encoding := resp.Header.Get("Content-Encoding"); encoding {
+	case "", "identity":
+		return nil
+	case "gzip":
+		reader, err := gzip.NewReader(resp.Body)
+		if err != nil {
+			return fmt.Errorf("failed to decompress upstream response: %w", err)
+		}
+		resp.Body = &gzipBody{Reader: reader, src: resp.Body}
+		resp.Header.Del("Content-Encoding")
+		resp.Header.Del("Content-Length")
+		resp.ContentLength = -1
+		resp.Uncompressed = true
+		return nil
+	default:
+		return fmt.Errorf("unsupported upstream Content-Encoding %q", encoding)
+	}
+}
+
+// gzipBody 解压后的响应体，关闭时同时关闭上游响应体
+type gzipBody struct {
+	*gzip.Reader
+	src io.ReadCloser
+}
+
+// Close 关闭解压器和上游响应体
+func (b *gzipBody) Close() error {
+	b.Reader.Close()
+	return b.src.Close()
+}
+
+// unwrapCodeAssistPayload 提取 {"response": ...} 中的内容，无法解析时原样返回
+func unwrapCodeAssistPayload(data []byte) []byte {
+	var wrapper struct {
+		Response json.RawMessage `json:"response"`
+	}
+	if err := json.Unmarshal(data, &wrapper); err != nil || len(wrapper.Response) == 0 {
+		return data
+	}
+	return wrapper.Response
+}
+
+// codeAssistSSEReader 逐行改写SSE流中的 data 行，去掉 response 外层包装
+type codeAssistSSEReader struct {
+	src     io.ReadCloser
+	reader  *bufio.Reader
+	pending []byte
+	err     error
+}
+
+// newCodeAssistSSEReader 创建SSE改写读取器
+func newCodeAssistSSEReader(src io.ReadCloser) *codeAssistSSEReader {
+	return &codeAssistSSEReader{src: src, reader: bufio.NewReader(src)}
+}
+
+// Read 读取改写后的数据
+func (r *codeAssistSSEReader) Read(p []byte) (int, error) {
+	for len(r.pending) == 0 {
+		if r.err != nil {
+			return 0, r.err
+		}
+
+		line, err := r.reader.ReadBytes('\n')
+		if len(line) > 0 {
+			if data, ok := bytes.CutPrefix(line, []byte("data: ")); ok {
+				trimmed := bytes.TrimRight(data, "\r\n")
+				line = append(append([]byte("data: "), unwrapCodeAssistPayload(trimmed)...), line[len("data: ")+len(trimmed):]...)
+			}
+			r.pending = line
+		}
+		r.err = err
+	}
+
+	n := copy(p, r.pending)
+	r.pending = r.pending[n:]
+	return n, nil
+}
+
+// Close 关闭上游响应体
+func (r *codeAssistSSEReader) Close() error {
+	return r.src.Close()
+}
@@ -0,0 +1,124 @@
+package client
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ba0gu0/gemini-go-proxy/pkg/config"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNativeReverseProxy_CodeAssist(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.ProjectID = "test-project"
+	client := NewGeminiClient(cfg, nil, logrus.New())
+
+	client.client.Transport = roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		assert.Empty(t, req.Header.Get("X-API-Key"))
+		assert.Empty(t, req.Header.Get("X-Forwarded-For"))
+		assert.Empty(t, req.Header.Get("Accept-Encoding"))
+
+		var wrapped map[string]json.RawMessage
+		require.NoError(t, json.NewDecoder(req.Body).Decode(&wrapped))
+		assert.JSONEq(t, `"test-project"`, string(wrapped["project"]))
+		assert.JSONEq(t, `"gemini-2.5-flash"`, string(wrapped["model"]))
+		// 内部请求保持原样，包括未建模的字段
+		assert.JSONEq(t, `{"contents":[],"cachedContent":"c1"}`, string(wrapped["request"]))
+
+		if strings.HasSuffix(req.URL.Path, ":streamGenerateContent") {
+			assert.Equal(t, "sse", req.URL.Query().Get("alt"))
+			body := "data: {\"response\":{\"candidates\":[]}}\r\n\r\ndata: {\"response\":{\"usageMetadata\":{}}}\n\n"
+			return &http.Response{StatusCode: http.StatusOK, Header: http.Header{"Content-Type": []string{"text/event-stream"}}, Body: io.NopCloser(strings.NewReader(body)), Request: req}, nil
+		}
+
+		assert.Equal(t, "/v1internal:generateContent", req.URL.Path)
+		body := `{"response":{"candidates":[],"modelVersion":"x"},"traceId":"t"}`
+		return &http.Response{StatusCode: http.StatusOK, Header: http.Header{"Content-Type": []string{"application/json"}}, Body: io.NopCloser(strings.NewReader(body)), Request: req}, nil
+	})
+
+	proxy := client.NativeReverseProxy()
+	serve := func(stream bool) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/v1beta/models/gemini-2.5-flash:generateContent?key=secret", strings.NewReader(`{"contents":[],"cachedContent":"c1"}`))
+		req.Header.Set("X-API-Key", "secret")
+		req.Header.Set("Accept-Encoding", "gzip")
+		req = req.WithContext(WithNativeRoute(context.Background(), "gemini-2.5-flash", stream))
+		rec := httptest.NewRecorder()
+		proxy.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := serve(false)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"candidates":[],"modelVersion":"x"}`, rec.Body.String())
+
+	rec = serve(true)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "data: {\"candidates\":[]}\r\n\r\ndata: {\"usageMetadata\":{}}\n\n", rec.Body.String())
+}
+
+func TestSanitizeUpstreamHeaders(t *testing.T) {
+	header := http.Header{}
+	header.Set("Connection", "keep-alive, X-Custom-Hop")
+	header.Set("X-Custom-Hop", "1")
+	header.Set("Keep-Alive", "timeout=5")
+	header.Set("Transfer-Encoding", "chunked")
+	header.Set("Authorization", "Bearer client-key")
+	header.Set("X-Real-IP", "10.0.0.1")
+	header.Set("Cookie", "session=1")
+	header.Set("Content-Type", "application/json")
+	header.Set("X-Goog-Api-Client", "genai-js")
+
+	sanitizeUpstreamHeaders(header)
+
+	assert.Equal(t, http.Header{
+		"Content-Type":      []string{"application/json"},
+		"X-Goog-Api-Client": []string{"genai-js"},
+	}, header)
+}
+
+func TestNativeReverseProxy_CompressedResponseFiltered(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.APIMode = config.AIStudio
+	cfg.ResponseFilter = &config.ResponseFilter{Blocklist: []string{"secret"}}
+	client := NewGeminiClient(cfg, nil, logrus.New())
+
+	encoding := "gzip"
+	client.client.Transport = roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		var body bytes.Buffer
+		writer := gzip.NewWriter(&body)
+		writer.Write([]byte(`{"candidates":[{"content":{"parts":[{"text":"the secret word"}]}}]}`))
+		writer.Close()
+		header := http.Header{"Content-Type": []string{"application/json"}, "Content-Encoding": []string{encoding}}
+		return &http.Response{StatusCode: http.StatusOK, Header: header, Body: io.NopCloser(&body), Request: req}, nil
+	})
+
+	proxy := client.NativeReverseProxy()
+	serve := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/v1beta/models/gemini-2.5-flash:generateContent", strings.NewReader(`{"contents":[]}`))
+		req = req.WithContext(WithNativeRoute(context.Background(), "gemini-2.5-flash", false))
+		rec := httptest.NewRecorder()
+		proxy.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// 压缩的响应解压后过滤
+	rec := serve()
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Empty(t, rec.Header().Get("Content-Encoding"))
+	assert.JSONEq(t, `{"candidates":[{"content":{"parts":[{"text":"the ****** word"}]}}]}`, rec.Body.String())
+
+	// 无法解压的编码不原样转发
+	encoding = "br"
+	rec = serve()
+	assert.Equal(t, http.StatusBadGateway, rec.Code)
+	assert.NotContains(t, rec.Body.String(), "secret word")
+}
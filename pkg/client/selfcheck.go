@@ -0,0 +1,157 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/ba0gu0/gemini-go-proxy/pkg/config"
+	"github.com/ba0gu0/gemini-go-proxy/pkg/models"
+)
+
+// DefaultStartupCheckModel 生成检查默认使用的模型
+const DefaultStartupCheckModel = "gemini-2.5-flash"
+
+// StartupCheckResult 单项启动检查结果
+type StartupCheckResult struct {
+	Name     string
+	Duration time.Duration
+	Err      error
+}
+
+// RunStartupChecks 按配置执行启动自检（token有效、项目可访问、一次低成本生成），返回每项结果和合并后的错误
+func (c *GeminiClient) RunStartupChecks(ctx context.Context, checks *config.StartupChecks) ([]StartupCheckResult, error) {
+	if checks == nil {
+		return nil, nil
+	}
+
+	var steps []struct {
+		name string
+		run  func(context.Context) error
+	}
+	add := func(name string, run func(context.Context) error) {
+		steps = append(steps, struct {
+			name string
+			run  func(context.Context) error
+		}{name, run})
+	}
+
+	if checks.Token {
+		add("token", c.checkToken)
+	}
+	if checks.Project {
+		add("project", c.checkProject)
+	}
+	if checks.Generation {
+		model := checks.GenerationModel
+		if model == "" {
+			model = DefaultStartupCheckModel
+		}
+		add("generation", func(ctx context.Context) error {
+			return c.checkGeneration(ctx, model)
+		})
+	}
+
+	var results []StartupCheckResult
+	var errs []error
+	for _, step := range steps {
+		start := time.Now()
+		err := step.run(ctx)
+		results = append(results, StartupCheckResult{Name: step.name, Duration: time.Since(start), Err: err})
+
+		if err != nil {
+			c.logger.Errorf("Startup check %s failed: %v", step.name, err)
+			errs = append(errs, fmt.Errorf("%s: %w", step.name, err))
+			continue
+		}
+		c.logger.Infof("Startup check %s passed (%v)", step.name, time.Since(start).Round(time.Millisecond))
+	}
+
+	return results, errors.Join(errs...)
+}
+
+// CheckToken 校验OAuth token可用，不发起生成请求，可用于周期性状态上报
+func (c *GeminiClient) CheckToken(ctx context.Context) error {
+	return c.checkToken(ctx)
+}
+
+// checkToken 校验OAuth token可用
+func (c *GeminiClient) checkToken(ctx context.Context) error {
+	if c.auth == nil {
+		return fmt.Errorf("auth not configured")
+	}
+	return c.auth.Health(ctx)
+}
+
+// checkProject 校验配置的项目可访问
+func (c *GeminiClient) checkProject(ctx context.Context) error {
+	var method, apiURL string
+	var body []byte
+
+	switch c.apiMode() {
+	case config.CodeAssist:
+		if c.projectID() == "" {
+			return fmt.Errorf("project_id is not configured")
+		}
+		method = "POST"
+		apiURL = fmt.Sprintf("%s/%s:loadCodeAssist", CodeAssistEndpoint, CodeAssistVersion)
+		body, _ = json.Marshal(map[string]any{
+			"cloudaicompanionProject": c.projectID(),
+			"metadata": map[string]any{
+				"pluginType":  "GEMINI",
+				"duetProject": c.projectID(),
+			},
+		})
+	case config.VertexAI:
+		if c.projectID() == "" {
+			return fmt.Errorf("project_id is not configured")
+		}
+		method = "GET"
+		apiURL = fmt.Sprintf(VertexAPIEndpoint+"/%s/projects/%s/locations/%s",
+			c.location(), VertexAPIVersion, c.projectID(), c.location())
+	default:
+		// AI Studio没有项目概念，检查模型列表接口可访问
+		method = "GET"
+		apiURL = fmt.Sprintf("%s/%s/models", DefaultAPIEndpoint, DefaultAPIVersion)
+	}
+
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	httpReq, err := c.createRequest(ctx, method, apiURL, reader)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.client.Do(httpReq)
+	if err != nil {
+		return fmt.Errorf("project request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("project check returned status %d: %s", resp.StatusCode, string(respBody))
+	}
+	return nil
+}
+
+// checkGeneration 执行一次最小输出的生成请求
+func (c *GeminiClient) checkGeneration(ctx context.Context, model string) error {
+	maxTokens := 1
+	req := &models.GeminiRequest{
+		Contents: []models.GeminiContent{
+			{Role: "user", Parts: []models.GeminiPart{{Text: "ping"}}},
+		},
+		GenerationConfig: &models.GeminiGenerationConfig{MaxOutputTokens: &maxTokens},
+	}
+
+	_, err := c.SendRequest(ctx, model, req)
+	return err
+}
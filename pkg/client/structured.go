@@ -0,0 +1,216 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/ba0gu0/gemini-go-proxy/pkg/models"
+)
+
+// structuredRepairPrompt 结构化输出修复提示词
+const structuredRepairPrompt = "Your previous reply is not valid JSON matching the required schema (%s). " +
+	"Fix this JSON to match the schema. Reply with only the corrected JSON and nothing else."
+
+// needsStructuredOutputValidation 判断请求是否开启了JSON模式且需要校验
+func (c *GeminiClient) needsStructuredOutputValidation(req *models.GeminiRequest) bool {
+	if !c.config.ValidateStructuredOutput || req == nil || req.GenerationConfig == nil {
+		return false
+	}
+	return req.GenerationConfig.ResponseSchema != nil ||
+		strings.EqualFold(req.GenerationConfig.ResponseMimeType, "application/json")
+}
+
+// validateStructuredOutput 校验响应是否为符合schema的JSON，必要时发起一次修复请求
+func (c *GeminiClient) validateStructuredOutput(ctx context.Context, modelID string, original *models.GeminiRequest, resp *models.GeminiResponse) (*models.GeminiResponse, error) {
+	text := responseText(resp)
+	validateErr := validateStructuredText(text, original.GenerationConfig.ResponseSchema)
+	if validateErr == nil {
+		return resp, nil
+	}
+
+	if !c.config.RepairStructuredOutput {
+		return nil, fmt.Errorf("structured output validation failed: %w", validateErr)
+	}
+
+	c.logger.Warnf("Structured output validation failed for %s, attempting repair: %v", modelID, validateErr)
+
+	// 在原始对话后追加模型输出和修复指令
+	repairReq := cloneGeminiRequest(original)
+	repairReq.Contents = append(repairReq.Contents,
+		models.GeminiContent{Role: "model", Parts: []models.GeminiPart{{Text: text}}},
+		models.GeminiContent{Role: "user", Parts: []models.GeminiPart{{Text: fmt.Sprintf(structuredRepairPrompt, validateErr)}}},
+	)
+
+	repaired, err := c.sendRequestWithRetry(ctx, modelID, repairReq, false)
+	if err != nil {
+		return nil, fmt.Errorf("structured output repair request failed: %w", err)
+	}
+
+	if err := validateStructuredText(responseText(repaired), original.GenerationConfig.ResponseSchema); err != nil {
+		return nil, fmt.Errorf("structured output validation failed after repair: %w", err)
+	}
+
+	c.logger.Infof("Structured output repaired successfully for %s", modelID)
+	return repaired, nil
+}
+
+// responseText 拼接第一个候选的全部文本
+func responseText(resp *models.GeminiResponse) string {
+	if resp == nil || len(resp.Candidates) == 0 {
+		return ""
+	}
+	var builder strings.Builder
+	for _, part := range resp.Candidates[0].Content.Parts {
+		builder.WriteString(part.Text)
+	}
+	return builder.String()
+}
+
+// validateStructuredText 解析JSON文本并按schema校验
+func validateStructuredText(text string, schema map[string]any) error {
+	text = stripCodeFence(text)
+	if text == "" {
+		return fmt.Errorf("output is empty")
+	}
+
+	var value any
+	if err := json.Unmarshal([]byte(text), &value); err != nil {
+		return fmt.Errorf("output is not valid JSON: %w", err)
+	}
+
+	if schema == nil {
+		return nil
+	}
+	return validateAgainstSchema(value, schema, "$")
+}
+
+// stripCodeFence 去除模型可能包裹在JSON外的Markdown代码块
+func stripCodeFence(text string) string {
+	text = strings.TrimSpace(text)
+	if !strings.HasPrefix(text, "```") {
+		return text
+	}
+	text = strings.TrimPrefix(text, "```")
+	if newline := strings.Index(text, "\n"); newline >= 0 {
+		text = text[newline+1:]
+	}
+	return strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(text), "```"))
+}
+
+// validateAgainstSchema 按Gemini schema子集(type/properties/required/items/enum/nullable)校验值
+func validateAgainstSchema(value any, schema map[string]any, path string) error {
+	if value == nil {
+		if nullable, _ := schema["nullable"].(bool); nullable {
+			return nil
+		}
+		if _, hasType := schema["type"]; hasType {
+			return fmt.Errorf("%s: value must not be null", path)
+		}
+		return nil
+	}
+
+	if enum, ok := schema["enum"].([]any); ok && len(enum) > 0 {
+		matched := false
+		for _, option := range enum {
+			if fmt.Sprint(option) == fmt.Sprint(value) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return fmt.Errorf("%s: value %v is not one of %v", path, value, enum)
+		}
+	}
+
+	schemaType, _ := schema["type"].(string)
+	switch strings.ToLower(schemaType) {
+	case "":
+		return nil
+	case "string":
+		if _, ok := value.(string); !ok {
+			return fmt.Errorf("%s: expected string", path)
+		}
+	case "number":
+		if _, ok := value.(float64); !ok {
+			return fmt.Errorf("%s: expected number", path)
+		}
+	case "integer":
+		number, ok := value.(float64)
+		if !ok || number != float64(int64(number)) {
+			return fmt.Errorf("%s: expected integer", path)
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return fmt.Errorf("%s: expected boolean", path)
+		}
+	case "array":
+		items, ok := value.([]any)
+		if !ok {
+			return fmt.Errorf("%s: expected array", path)
+		}
+		if itemSchema, ok := schema["items"].(map[string]any); ok {
+			for i, item := range items {
+				if err := validateAgainstSchema(item, itemSchema, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+					return err
+				}
+			}
+		}
+	case "object":
+		object, ok := value.(map[string]any)
+		if !ok {
+			return fmt.Errorf("%s: expected object", path)
+		}
+		if required, ok := schema["required"].([]any); ok {
+			for _, field := range required {
+				name := fmt.Sprint(field)
+				if _, exists := object[name]; !exists {
+					return fmt.Errorf("%s: missing required property %q", path, name)
+				}
+			}
+		}
+		if properties, ok := schema["properties"].(map[string]any); ok {
+			// 按字段名排序，保证错误信息稳定
+			names := make([]string, 0, len(properties))
+			for name := range properties {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			for _, name := range names {
+				propertySchema, ok := properties[name].(map[string]any)
+				fieldValue, exists := object[name]
+				if !ok || !exists {
+					continue
+				}
+				if err := validateAgainstSchema(fieldValue, propertySchema, path+"."+name); err != nil {
+					return err
+				}
+			}
+		}
+	default:
+		return fmt.Errorf("%s: unsupported schema type %q", path, schemaType)
+	}
+
+	return nil
+}
+
+// cloneGeminiRequest 复制Gemini请求，避免修改原始内容和系统指令
+func cloneGeminiRequest(req *models.GeminiRequest) *models.GeminiRequest {
+	clone := *req
+	clone.Contents = append([]models.GeminiContent(nil), req.Contents...)
+	for i := range clone.Contents {
+		clone.Contents[i].Parts = append([]models.GeminiPart(nil), clone.Contents[i].Parts...)
+	}
+	if req.SystemInstruction != nil {
+		clone.SystemInstruction = &models.GeminiSystemInstruction{
+			Parts: append([]models.GeminiPart(nil), req.SystemInstruction.Parts...),
+		}
+	}
+	if req.GenerationConfig != nil {
+		generationConfig := *req.GenerationConfig
+		clone.GenerationConfig = &generationConfig
+	}
+	return &clone
+}
@@ -0,0 +1,61 @@
+package client
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/ba0gu0/gemini-go-proxy/pkg/config"
+	"github.com/ba0gu0/gemini-go-proxy/pkg/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateStructuredText(t *testing.T) {
+	var schema map[string]any
+	err := json.Unmarshal([]byte(`{
+		"type": "OBJECT",
+		"properties": {
+			"name": {"type": "STRING"},
+			"age": {"type": "INTEGER"},
+			"tags": {"type": "ARRAY", "items": {"type": "STRING"}},
+			"level": {"type": "STRING", "enum": ["low", "high"]}
+		},
+		"required": ["name", "age"]
+	}`), &schema)
+	require.NoError(t, err)
+
+	assert.NoError(t, validateStructuredText(`{"name": "a", "age": 3, "tags": ["x"], "level": "low"}`, schema))
+	assert.NoError(t, validateStructuredText("```json\n{\"name\": \"a\", \"age\": 3}\n```", schema))
+
+	err = validateStructuredText(`{"name": "a"}`, schema)
+	assert.ErrorContains(t, err, `missing required property "age"`)
+
+	err = validateStructuredText(`{"name": "a", "age": 3.5}`, schema)
+	assert.ErrorContains(t, err, "$.age: expected integer")
+
+	err = validateStructuredText(`{"name": "a", "age": 3, "tags": [1]}`, schema)
+	assert.ErrorContains(t, err, "$.tags[0]: expected string")
+
+	err = validateStructuredText(`{"name": "a", "age": 3, "level": "mid"}`, schema)
+	assert.ErrorContains(t, err, "is not one of")
+
+	err = validateStructuredText(`not json`, schema)
+	assert.ErrorContains(t, err, "not valid JSON")
+
+	// 仅JSON模式，无schema
+	assert.NoError(t, validateStructuredText(`[1, 2]`, nil))
+}
+
+func TestNeedsStructuredOutputValidation(t *testing.T) {
+	cfg := config.DefaultConfig()
+	client := NewGeminiClient(cfg, nil, nil)
+
+	req := &models.GeminiRequest{
+		GenerationConfig: &models.GeminiGenerationConfig{ResponseMimeType: "application/json"},
+	}
+	assert.False(t, client.needsStructuredOutputValidation(req))
+
+	cfg.ValidateStructuredOutput = true
+	assert.True(t, client.needsStructuredOutputValidation(req))
+	assert.False(t, client.needsStructuredOutputValidation(&models.GeminiRequest{}))
+}
@@ -0,0 +1,101 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// applyConnectTimeout 为传输层设置建立连接和TLS握手的超时，已有自定义拨号器时保留拨号器
+func applyConnectTimeout(transport *http.Transport, timeout time.Duration) {
+	if timeout <= 0 {
+		return
+	}
+	if transport.DialContext == nil {
+		dialer := &net.Dialer{Timeout: timeout, KeepAlive: 30 * time.Second}
+		transport.DialContext = dialer.DialContext
+	}
+	transport.TLSHandshakeTimeout = timeout
+}
+
+// withRequestTimeout 创建限制总时长的请求上下文，total<=0时只可取消
+func withRequestTimeout(ctx context.Context, total time.Duration) (context.Context, context.CancelFunc) {
+	if total > 0 {
+		return context.WithTimeout(ctx, total)
+	}
+	return context.WithCancel(ctx)
+}
+
+// doWithFirstByteTimeout 发送请求，超过首字节超时仍未收到响应头时调用cancel中止请求
+// cancel必须取消req所使用的上下文
+func (c *GeminiClient) doWithFirstByteTimeout(req *http.Request, cancel context.CancelFunc) (*http.Response, error) {
+	timeout := c.config.GetFirstByteTimeout()
+	if timeout <= 0 {
+		return c.client.Do(req)
+	}
+
+	var expired atomic.Bool
+	timer := time.AfterFunc(timeout, func() {
+		expired.Store(true)
+		cancel()
+	})
+	resp, err := c.client.Do(req)
+	timer.Stop()
+
+	if expired.Load() {
+		if err == nil {
+			resp.Body.Close()
+		}
+		return nil, fmt.Errorf("no response headers within first byte timeout %s: %w", timeout, context.DeadlineExceeded)
+	}
+	return resp, err
+}
+
+// ErrStreamIdle 上游流在空闲超时时间内没有收到任何数据
+var ErrStreamIdle = errors.New("upstream stream idle timeout")
+
+// idleTimeoutReader 上游流超过空闲超时没有收到数据时取消请求
+type idleTimeoutReader struct {
+	io.ReadCloser
+	timeout time.Duration
+	timer   *time.Timer
+	expired atomic.Bool
+}
+
+// withIdleTimeout 为流式响应体设置空闲超时，cancel必须取消该响应所属请求的上下文
+func (c *GeminiClient) withIdleTimeout(body io.ReadCloser, cancel context.CancelFunc) io.ReadCloser {
+	timeout := c.config.GetStreamIdleTimeout()
+	if timeout <= 0 {
+		return body
+	}
+
+	r := &idleTimeoutReader{ReadCloser: body, timeout: timeout}
+	r.timer = time.AfterFunc(timeout, func() {
+		r.expired.Store(true)
+		cancel()
+	})
+	return r
+}
+
+// Read 读取数据，收到数据时重新计时
+func (r *idleTimeoutReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	if r.expired.Load() {
+		return n, fmt.Errorf("no data from upstream for %s: %w", r.timeout, ErrStreamIdle)
+	}
+	if n > 0 {
+		r.timer.Reset(r.timeout)
+	}
+	return n, err
+}
+
+// Close 停止计时并关闭响应体
+func (r *idleTimeoutReader) Close() error {
+	r.timer.Stop()
+	return r.ReadCloser.Close()
+}
@@ -0,0 +1,136 @@
+package client
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ba0gu0/gemini-go-proxy/pkg/config"
+	"github.com/ba0gu0/gemini-go-proxy/pkg/models"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// blockingTransport 在请求上下文取消前一直阻塞，并记录请求上下文是否带有截止时间
+func blockingTransport(deadline *bool) roundTripFunc {
+	return func(req *http.Request) (*http.Response, error) {
+		if deadline != nil {
+			_, *deadline = req.Context().Deadline()
+		}
+		<-req.Context().Done()
+		return nil, req.Context().Err()
+	}
+}
+
+func TestGeminiClient_FirstByteTimeout(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.MaxRetries = 1
+	cfg.FirstByteTimeoutSeconds = 1
+	client := NewGeminiClient(cfg, nil, logrus.New())
+
+	var hasDeadline bool
+	client.client.Transport = blockingTransport(&hasDeadline)
+
+	start := time.Now()
+	_, err := client.SendRequestRaw(context.Background(), "gemini-2.5-flash", &models.GeminiRequest{})
+	require.Error(t, err)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Contains(t, err.Error(), "first byte timeout")
+	assert.Less(t, time.Since(start), 5*time.Second)
+
+	// 非流式请求同时受timeout_seconds总时长限制
+	assert.True(t, hasDeadline)
+
+	_, err = client.SendStreamRequestRaw(context.Background(), "gemini-2.5-flash", &models.GeminiRequest{})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "first byte timeout")
+}
+
+func TestGeminiClient_StreamTimeout(t *testing.T) {
+	cfg := config.DefaultConfig()
+	client := NewGeminiClient(cfg, nil, logrus.New())
+
+	var streamCtx context.Context
+	client.client.Transport = roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		streamCtx = req.Context()
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(""))}, nil
+	})
+
+	// 未配置时流式请求没有总时长限制
+	resp, err := client.SendStreamRequestRaw(context.Background(), "gemini-2.5-flash", &models.GeminiRequest{})
+	require.NoError(t, err)
+	_, ok := streamCtx.Deadline()
+	assert.False(t, ok)
+
+	// 关闭响应体后释放请求上下文
+	resp.Body.Close()
+	assert.Error(t, streamCtx.Err())
+
+	cfg.StreamTimeoutSeconds = 60
+	resp, err = client.SendStreamRequestRaw(context.Background(), "gemini-2.5-flash", &models.GeminiRequest{})
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	deadline, ok := streamCtx.Deadline()
+	require.True(t, ok)
+	assert.WithinDuration(t, time.Now().Add(time.Minute), deadline, 5*time.Second)
+}
+
+func TestGeminiClient_ConnectTimeout(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.ConnectTimeoutSeconds = 3
+	client := NewGeminiClient(cfg, nil, logrus.New())
+
+	require.NoError(t, client.SetProxy(""))
+	transport, ok := currentTransport(t, client).(*http.Transport)
+	require.True(t, ok)
+	assert.NotNil(t, transport.DialContext)
+	assert.Equal(t, 3*time.Second, transport.TLSHandshakeTimeout)
+
+	require.NoError(t, client.SetProxy("http://proxy.example.com:8080"))
+	transport, ok = currentTransport(t, client).(*http.Transport)
+	require.True(t, ok)
+	assert.NotNil(t, transport.Proxy)
+	assert.Equal(t, 3*time.Second, transport.TLSHandshakeTimeout)
+}
+
+// stallingBody 返回一段数据后阻塞，直到请求上下文被取消
+type stallingBody struct {
+	ctx  context.Context
+	data io.Reader
+}
+
+func (b *stallingBody) Read(p []byte) (int, error) {
+	if n, err := b.data.Read(p); err != io.EOF {
+		return n, err
+	}
+	<-b.ctx.Done()
+	return 0, b.ctx.Err()
+}
+
+func (b *stallingBody) Close() error { return nil }
+
+func TestGeminiClient_StreamIdleTimeout(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.APIMode = config.AIStudio
+	cfg.StreamIdleTimeoutSeconds = 1
+	client := NewGeminiClient(cfg, nil, logrus.New())
+	client.client.Transport = roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		body := &stallingBody{ctx: req.Context(), data: strings.NewReader("data: {\"candidates\":[]}\n\n")}
+		return &http.Response{StatusCode: http.StatusOK, Body: body}, nil
+	})
+
+	var chunks int
+	start := time.Now()
+	err := client.SendStreamRequest(context.Background(), "gemini-2.5-flash", &models.GeminiRequest{}, func(*models.GeminiStreamChunk) error {
+		chunks++
+		return nil
+	})
+	require.Error(t, err)
+	assert.ErrorIs(t, err, ErrStreamIdle)
+	assert.Equal(t, 1, chunks)
+	assert.Less(t, time.Since(start), 5*time.Second)
+}
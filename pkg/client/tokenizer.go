@@ -0,0 +1,186 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"unicode"
+
+	"github.com/ba0gu0/gemini-go-proxy/pkg/config"
+	"github.com/ba0gu0/gemini-go-proxy/pkg/models"
+)
+
+// approxCharsPerToken 长单词按该长度切分为多个近似token
+const approxCharsPerToken = 4
+
+// CountTokens 调用上游countTokens接口统计token数量
+func (c *GeminiClient) CountTokens(ctx context.Context, modelID string, contents []models.GeminiContent) (int, error) {
+	resp, err := c.SendCountTokensRequest(ctx, modelID, &models.GeminiCountTokensRequest{Contents: contents})
+	if err != nil {
+		return 0, err
+	}
+	return resp.TotalTokens, nil
+}
+
+// SendCountTokensRequest 发送原生格式的countTokens请求
+// Code Assist接口只接受contents，generateContentRequest中的系统指令作为首条消息计入；流水线名按其主模型计数
+func (c *GeminiClient) SendCountTokensRequest(ctx context.Context, modelID string, req *models.GeminiCountTokensRequest) (*models.GeminiCountTokensResponse, error) {
+	modelID = c.pipelineModel(modelID)
+	reqBody, err := c.countTokensBody(modelID, req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal count tokens request: %w", err)
+	}
+
+	ctx, cancel := withRequestTimeout(ctx, c.config.GetTimeout())
+	defer cancel()
+
+	apiURL, err := c.buildAPIURL(ctx, modelID, "countTokens")
+	if err != nil {
+		return nil, err
+	}
+	httpReq, err := c.createRequest(ctx, "POST", apiURL, bytes.NewBuffer(reqBody))
+	if err != nil {
+		return nil, err
+	}
+
+	c.logger.Debugf("Sending countTokens request: %s", modelID)
+
+	resp, err := c.client.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("count tokens request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		c.cooldownAccount(httpReq, resp)
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("count tokens API request failed with status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var countResp models.GeminiCountTokensResponse
+	if err := json.NewDecoder(resp.Body).Decode(&countResp); err != nil {
+		return nil, fmt.Errorf("failed to decode count tokens response: %w", err)
+	}
+
+	return &countResp, nil
+}
+
+// countTokensBody 按API模式构建countTokens请求体
+func (c *GeminiClient) countTokensBody(modelID string, req *models.GeminiCountTokensRequest) ([]byte, error) {
+	generate := req.GenerateContentRequest
+	switch {
+	case c.apiMode() == config.CodeAssist:
+		contents := req.Contents
+		if generate != nil {
+			contents = generate.Contents
+			if generate.SystemInstruction != nil {
+				system := models.GeminiContent{Role: "user", Parts: generate.SystemInstruction.Parts}
+				contents = append([]models.GeminiContent{system}, contents...)
+			}
+		}
+		return json.Marshal(&models.CodeAssistCountTokensRequest{
+			Request: &models.CodeAssistCountTokensBody{
+				Model:    "models/" + strings.TrimPrefix(modelID, "models/"),
+				Contents: contents,
+			},
+		})
+	case generate == nil:
+		return json.Marshal(&models.GeminiCountTokensRequest{Contents: req.Contents})
+	case c.apiMode() == config.VertexAI:
+		// Vertex AI直接在请求顶层接受系统指令和工具定义
+		return json.Marshal(&models.GeminiRequest{
+			Contents:          generate.Contents,
+			SystemInstruction: generate.SystemInstruction,
+			Tools:             generate.Tools,
+		})
+	default:
+		// AI Studio的generateContentRequest需要填写模型
+		data, err := json.Marshal(generate)
+		if err != nil {
+			return nil, err
+		}
+		var fields map[string]any
+		if err := json.Unmarshal(data, &fields); err != nil {
+			return nil, err
+		}
+		fields["model"] = aiStudioModelPath(modelID)
+		return json.Marshal(map[string]any{"generateContentRequest": fields})
+	}
+}
+
+// Tokenize 返回文本的token数量和近似token边界，优先使用上游countTokens统计数量
+func (c *GeminiClient) Tokenize(ctx context.Context, modelID, text string) *models.TokenizeResponse {
+	spans := ApproximateTokenize(text)
+	result := &models.TokenizeResponse{
+		Model:       modelID,
+		TokenCount:  len(spans),
+		Approximate: true,
+		Tokens:      spans,
+	}
+
+	if text == "" {
+		return result
+	}
+
+	contents := []models.GeminiContent{{Role: "user", Parts: []models.GeminiPart{{Text: text}}}}
+	count, err := c.CountTokens(ctx, modelID, contents)
+	if err != nil {
+		c.logger.Debugf("countTokens unavailable, using approximate count: %v", err)
+		return result
+	}
+
+	result.TokenCount = count
+	result.Approximate = false
+	return result
+}
+
+// ApproximateTokenize 本地近似分词：空白分隔单词，标点和CJK字符单独成token，长单词按固定长度切分
+func ApproximateTokenize(text string) []models.TokenSpan {
+	runes := []rune(text)
+	spans := make([]models.TokenSpan, 0, len(runes)/approxCharsPerToken+1)
+
+	appendSpan := func(start, end int) {
+		spans = append(spans, models.TokenSpan{Text: string(runes[start:end]), Start: start, End: end})
+	}
+
+	i := 0
+	for i < len(runes) {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case isCJK(r) || unicode.IsPunct(r) || unicode.IsSymbol(r):
+			appendSpan(i, i+1)
+			i++
+		default:
+			// 连续的字母数字组成单词，按固定长度切分
+			start := i
+			for i < len(runes) && !unicode.IsSpace(runes[i]) && !isCJK(runes[i]) &&
+				!unicode.IsPunct(runes[i]) && !unicode.IsSymbol(runes[i]) {
+				i++
+			}
+			for pieceStart := start; pieceStart < i; pieceStart += approxCharsPerToken {
+				appendSpan(pieceStart, min(pieceStart+approxCharsPerToken, i))
+			}
+		}
+	}
+
+	return spans
+}
+
+// isCJK 判断是否为中日韩字符
+func isCJK(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul)
+}
+
+// EstimateTokenCount 估算文本的token数量
+func EstimateTokenCount(text string) int {
+	if strings.TrimSpace(text) == "" {
+		return 0
+	}
+	return len(ApproximateTokenize(text))
+}
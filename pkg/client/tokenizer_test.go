@@ -0,0 +1,67 @@
+package client
+
+import (
+	"testing"
+
+	"github.com/ba0gu0/gemini-go-proxy/pkg/config"
+	"github.com/ba0gu0/gemini-go-proxy/pkg/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestApproximateTokenize(t *testing.T) {
+	spans := ApproximateTokenize("Hello, world")
+	texts := make([]string, len(spans))
+	for i, span := range spans {
+		texts[i] = span.Text
+	}
+	assert.Equal(t, []string{"Hell", "o", ",", "worl", "d"}, texts)
+	assert.Equal(t, 0, spans[0].Start)
+	assert.Equal(t, 4, spans[0].End)
+	assert.Equal(t, 7, spans[3].Start)
+
+	// CJK字符单独成token，偏移按字符计算
+	spans = ApproximateTokenize("你好 go")
+	assert.Len(t, spans, 3)
+	assert.Equal(t, "好", spans[1].Text)
+	assert.Equal(t, 3, spans[2].Start)
+
+	assert.Empty(t, ApproximateTokenize("   "))
+	assert.Equal(t, 0, EstimateTokenCount(""))
+}
+
+func TestGeminiClient_CountTokensBody(t *testing.T) {
+	contents := []models.GeminiContent{{Role: "user", Parts: []models.GeminiPart{{Text: "hello"}}}}
+	generate := &models.GeminiRequest{
+		Contents:          contents,
+		SystemInstruction: &models.GeminiSystemInstruction{Parts: []models.GeminiPart{{Text: "be brief"}}},
+	}
+
+	cfg := config.DefaultConfig()
+	client := NewGeminiClient(cfg, nil, nil)
+
+	// Code Assist只接受contents，系统指令作为首条消息计入
+	body, err := client.countTokensBody("gemini-2.5-flash", &models.GeminiCountTokensRequest{GenerateContentRequest: generate})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"request":{"model":"models/gemini-2.5-flash","contents":[
+		{"role":"user","parts":[{"text":"be brief"}]},
+		{"role":"user","parts":[{"text":"hello"}]}
+	]}}`, string(body))
+
+	cfg.APIMode = config.AIStudio
+	body, err = client.countTokensBody("gemini-2.5-flash", &models.GeminiCountTokensRequest{Contents: contents})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"contents":[{"role":"user","parts":[{"text":"hello"}]}]}`, string(body))
+
+	body, err = client.countTokensBody("gemini-2.5-flash", &models.GeminiCountTokensRequest{GenerateContentRequest: generate})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"generateContentRequest":{"model":"models/gemini-2.5-flash",
+		"contents":[{"role":"user","parts":[{"text":"hello"}]}],
+		"system_instruction":{"parts":[{"text":"be brief"}]}
+	}}`, string(body))
+
+	cfg.APIMode = config.VertexAI
+	body, err = client.countTokensBody("gemini-2.5-flash", &models.GeminiCountTokensRequest{GenerateContentRequest: generate})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"contents":[{"role":"user","parts":[{"text":"hello"}]}],"system_instruction":{"parts":[{"text":"be brief"}]}}`, string(body))
+}
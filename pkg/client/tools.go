@@ -0,0 +1,162 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ba0gu0/gemini-go-proxy/pkg/models"
+)
+
+// unsupportedSchemaKeys Gemini函数声明不接受的JSON Schema关键字
+var unsupportedSchemaKeys = []string{"$schema", "additionalProperties", "strict"}
+
+// convertOpenAITools 将OpenAI工具定义转换为Gemini函数声明
+func convertOpenAITools(tools []models.OpenAITool) []models.GeminiTool {
+	var declarations []models.GeminiFunctionDeclaration
+	for _, tool := range tools {
+		if tool.Type != "" && tool.Type != "function" {
+			continue
+		}
+		declarations = append(declarations, models.GeminiFunctionDeclaration{
+			Name:        tool.Function.Name,
+			Description: tool.Function.Description,
+			Parameters:  cleanSchema(tool.Function.Parameters),
+		})
+	}
+	if len(declarations) == 0 {
+		return nil
+	}
+	return []models.GeminiTool{{FunctionDeclarations: declarations}}
+}
+
+// cleanSchema 递归去除Gemini不支持的Schema关键字，返回副本
+func cleanSchema(schema map[string]any) map[string]any {
+	if schema == nil {
+		return nil
+	}
+	cleaned := make(map[string]any, len(schema))
+	for key, value := range schema {
+		cleaned[key] = cleanSchemaValue(value)
+	}
+	for _, key := range unsupportedSchemaKeys {
+		delete(cleaned, key)
+	}
+	return cleaned
+}
+
+// cleanSchemaValue 清理Schema中嵌套的对象和数组
+func cleanSchemaValue(value any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		return cleanSchema(v)
+	case []any:
+		items := make([]any, len(v))
+		for i, item := range v {
+			items[i] = cleanSchemaValue(item)
+		}
+		return items
+	default:
+		return value
+	}
+}
+
+// convertToolChoice 将OpenAI tool_choice转换为Gemini工具调用配置
+func convertToolChoice(raw json.RawMessage) (*models.GeminiToolConfig, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil, nil
+	}
+
+	config := &models.GeminiFunctionCallingConfig{}
+	var choice string
+	if err := json.Unmarshal(raw, &choice); err == nil {
+		switch choice {
+		case "none":
+			config.Mode = "NONE"
+		case "auto":
+			config.Mode = "AUTO"
+		case "required", "any":
+			config.Mode = "ANY"
+		default:
+			return nil, fmt.Errorf("unsupported tool_choice: %s", choice)
+		}
+		return &models.GeminiToolConfig{FunctionCallingConfig: config}, nil
+	}
+
+	var named struct {
+		Function struct {
+			Name string `json:"name"`
+		} `json:"function"`
+	}
+	if err := json.Unmarshal(raw, &named); err != nil || named.Function.Name == "" {
+		return nil, fmt.Errorf("invalid tool_choice: %s", string(raw))
+	}
+	config.Mode = "ANY"
+	config.AllowedFunctionNames = []string{named.Function.Name}
+	return &models.GeminiToolConfig{FunctionCallingConfig: config}, nil
+}
+
+// toolCallParts 将assistant消息中的函数调用转换为functionCall部分
+func toolCallParts(calls []models.OpenAIToolCall) ([]models.GeminiPart, error) {
+	parts := make([]models.GeminiPart, 0, len(calls))
+	for _, call := range calls {
+		var args map[string]any
+		if arguments := strings.TrimSpace(call.Function.Arguments); arguments != "" {
+			if err := json.Unmarshal([]byte(arguments), &args); err != nil {
+				return nil, fmt.Errorf("invalid arguments for tool call %s: %w", call.Function.Name, err)
+			}
+		}
+		parts = append(parts, models.GeminiPart{FunctionCall: &models.GeminiFunctionCall{Name: call.Function.Name, Args: args}})
+	}
+	return parts, nil
+}
+
+// toolResponsePart 将tool消息转换为functionResponse部分，非JSON对象的结果包装为 {"content": ...}
+func toolResponsePart(name, content string) models.GeminiPart {
+	var response map[string]any
+	if err := json.Unmarshal([]byte(content), &response); err != nil || response == nil {
+		response = map[string]any{"content": content}
+	}
+	return models.GeminiPart{FunctionResponse: &models.GeminiFunctionResponse{Name: name, Response: response}}
+}
+
+// openAIToolCalls 将functionCall部分转换为OpenAI函数调用，stream为true时按offset起始编号填写index
+func openAIToolCalls(parts []models.GeminiPart, offset int, stream bool) []models.OpenAIToolCall {
+	var calls []models.OpenAIToolCall
+	for _, part := range parts {
+		if part.FunctionCall == nil {
+			continue
+		}
+		arguments, err := json.Marshal(part.FunctionCall.Args)
+		if err != nil || part.FunctionCall.Args == nil {
+			arguments = []byte("{}")
+		}
+
+		position := offset + len(calls)
+		call := models.OpenAIToolCall{
+			ID:   "call_" + strconv.FormatInt(time.Now().UnixNano(), 36) + "_" + strconv.Itoa(position),
+			Type: "function",
+			Function: models.OpenAIFunctionCall{
+				Name:      part.FunctionCall.Name,
+				Arguments: string(arguments),
+			},
+		}
+		if stream {
+			call.Index = &position
+		}
+		calls = append(calls, call)
+	}
+	return calls
+}
+
+// isTextOnly 判断内容是否只包含文本部分
+func isTextOnly(content models.GeminiContent) bool {
+	for _, part := range content.Parts {
+		if part.FunctionCall != nil || part.FunctionResponse != nil || part.InlineData != nil || part.FileData != nil {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,142 @@
+package client
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/ba0gu0/gemini-go-proxy/pkg/models"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpenAIToGeminiRequest_Tools(t *testing.T) {
+	converter := NewFormatConverter(logrus.New())
+
+	req := &models.OpenAIRequest{
+		Model: "gemini-2.5-flash",
+		Messages: []models.OpenAIMessage{
+			{Role: "user", Content: "What's the weather in Paris and Rome?"},
+			{Role: "assistant", ToolCalls: []models.OpenAIToolCall{
+				{ID: "call_1", Type: "function", Function: models.OpenAIFunctionCall{Name: "get_weather", Arguments: `{"city":"Paris"}`}},
+				{ID: "call_2", Type: "function", Function: models.OpenAIFunctionCall{Name: "get_weather", Arguments: `{"city":"Rome"}`}},
+			}},
+			{Role: "tool", ToolCallID: "call_1", Content: `{"temp":20}`},
+			{Role: "tool", ToolCallID: "call_2", Content: "sunny"},
+		},
+		Tools: []models.OpenAITool{{Type: "function", Function: models.OpenAIFunction{
+			Name:        "get_weather",
+			Description: "Get the weather",
+			Parameters: map[string]any{
+				"$schema":              "http://json-schema.org/draft-07/schema#",
+				"type":                 "object",
+				"additionalProperties": false,
+				"properties":           map[string]any{"city": map[string]any{"type": "string"}},
+			},
+		}}},
+		ToolChoice: json.RawMessage(`{"type":"function","function":{"name":"get_weather"}}`),
+	}
+
+	geminiReq, err := converter.OpenAIToGeminiRequest(req)
+	require.NoError(t, err)
+
+	require.Len(t, geminiReq.Tools, 1)
+	declaration := geminiReq.Tools[0].FunctionDeclarations[0]
+	assert.Equal(t, "get_weather", declaration.Name)
+	assert.NotContains(t, declaration.Parameters, "$schema")
+	assert.NotContains(t, declaration.Parameters, "additionalProperties")
+	assert.Equal(t, "ANY", geminiReq.ToolConfig.FunctionCallingConfig.Mode)
+	assert.Equal(t, []string{"get_weather"}, geminiReq.ToolConfig.FunctionCallingConfig.AllowedFunctionNames)
+
+	// 两个函数结果合并到同一条user消息中
+	require.Len(t, geminiReq.Contents, 3)
+	calls := geminiReq.Contents[1]
+	assert.Equal(t, "model", calls.Role)
+	require.Len(t, calls.Parts, 2)
+	assert.Equal(t, map[string]any{"city": "Rome"}, calls.Parts[1].FunctionCall.Args)
+
+	results := geminiReq.Contents[2]
+	assert.Equal(t, "user", results.Role)
+	require.Len(t, results.Parts, 2)
+	assert.Equal(t, "get_weather", results.Parts[0].FunctionResponse.Name)
+	assert.Equal(t, map[string]any{"temp": float64(20)}, results.Parts[0].FunctionResponse.Response)
+	assert.Equal(t, map[string]any{"content": "sunny"}, results.Parts[1].FunctionResponse.Response)
+
+	// 没有文本的部分不输出text字段
+	data, err := json.Marshal(calls.Parts[0])
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"functionCall":{"name":"get_weather","args":{"city":"Paris"}}}`, string(data))
+}
+
+func TestConvertToolChoice(t *testing.T) {
+	for choice, mode := range map[string]string{`"none"`: "NONE", `"auto"`: "AUTO", `"required"`: "ANY"} {
+		config, err := convertToolChoice(json.RawMessage(choice))
+		require.NoError(t, err)
+		assert.Equal(t, mode, config.FunctionCallingConfig.Mode)
+	}
+
+	config, err := convertToolChoice(nil)
+	require.NoError(t, err)
+	assert.Nil(t, config)
+
+	_, err = convertToolChoice(json.RawMessage(`"sometimes"`))
+	assert.Error(t, err)
+	_, err = convertToolChoice(json.RawMessage(`{"type":"function"}`))
+	assert.Error(t, err)
+}
+
+func TestOpenAIToGeminiRequest_InvalidToolArguments(t *testing.T) {
+	converter := NewFormatConverter(logrus.New())
+	_, err := converter.OpenAIToGeminiRequest(&models.OpenAIRequest{Messages: []models.OpenAIMessage{
+		{Role: "assistant", ToolCalls: []models.OpenAIToolCall{{ID: "call_1", Function: models.OpenAIFunctionCall{Name: "f", Arguments: "not json"}}}},
+	}})
+	assert.Error(t, err)
+}
+
+func TestGeminiToOpenAIResponse_ToolCalls(t *testing.T) {
+	converter := NewFormatConverter(logrus.New())
+	resp, err := converter.GeminiToOpenAIResponse(&models.GeminiResponse{Candidates: []models.GeminiCandidate{{
+		Content: models.GeminiContent{Role: "model", Parts: []models.GeminiPart{
+			{FunctionCall: &models.GeminiFunctionCall{Name: "get_weather", Args: map[string]any{"city": "Paris"}}},
+		}},
+		FinishReason: "STOP",
+	}}, ModelVersion: "gemini-2.5-flash-001"}, "gemini-2.5-flash")
+	require.NoError(t, err)
+	assert.Equal(t, "gemini-2.5-flash", resp.Model)
+	assert.Equal(t, "gemini-2.5-flash-001", resp.SystemFingerprint)
+
+	choice := resp.Choices[0]
+	assert.Equal(t, "tool_calls", *choice.FinishReason)
+	require.Len(t, choice.Message.ToolCalls, 1)
+	call := choice.Message.ToolCalls[0]
+	assert.Nil(t, call.Index)
+	assert.NotEmpty(t, call.ID)
+	assert.Equal(t, "function", call.Type)
+	assert.Equal(t, "get_weather", call.Function.Name)
+	assert.JSONEq(t, `{"city":"Paris"}`, call.Function.Arguments)
+}
+
+func TestGeminiStreamToOpenAI_ToolCallDeltas(t *testing.T) {
+	converter := NewFormatConverter(logrus.New())
+	state := &StreamState{}
+
+	chunk := func(parts []models.GeminiPart, finish string) *models.OpenAIStreamChunk {
+		out, err := converter.GeminiStreamToOpenAI(&models.GeminiStreamChunk{Candidates: []models.GeminiStreamCandidate{{
+			Content:      models.GeminiContent{Role: "model", Parts: parts},
+			FinishReason: finish,
+		}}}, "gemini-2.5-flash", "chatcmpl-1", state)
+		require.NoError(t, err)
+		return out
+	}
+
+	first := chunk([]models.GeminiPart{{FunctionCall: &models.GeminiFunctionCall{Name: "a"}}}, "")
+	assert.Equal(t, "assistant", first.Choices[0].Delta.Role)
+	require.Len(t, first.Choices[0].Delta.ToolCalls, 1)
+	assert.Equal(t, 0, *first.Choices[0].Delta.ToolCalls[0].Index)
+	assert.Equal(t, "{}", first.Choices[0].Delta.ToolCalls[0].Function.Arguments)
+
+	second := chunk([]models.GeminiPart{{FunctionCall: &models.GeminiFunctionCall{Name: "b", Args: map[string]any{"x": 1}}}}, "STOP")
+	require.Len(t, second.Choices[0].Delta.ToolCalls, 1)
+	assert.Equal(t, 1, *second.Choices[0].Delta.ToolCalls[0].Index)
+	assert.Equal(t, "tool_calls", *second.Choices[0].FinishReason)
+}
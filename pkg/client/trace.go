@@ -0,0 +1,196 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ba0gu0/gemini-go-proxy/pkg/config"
+	"github.com/ba0gu0/gemini-go-proxy/pkg/models"
+)
+
+// RequestTrace 记录单个代理请求的上游路由决策，用于调试回显
+type RequestTrace struct {
+	mu            sync.Mutex
+	APIMode       config.APIMode
+	Project       string
+	Account       string
+	Proxy         string
+	Model         string
+	UpstreamCalls int // 上游请求总次数（包括续写、修复等）
+	Retries       int // 重试次数
+	// 用量与延迟
+	Usage           models.GeminiUsageMetadata
+	UpstreamLatency time.Duration // 最近一次上游请求返回响应头的耗时
+	FirstToken      time.Duration // 流式请求从发出到收到第一个字节的耗时
+	ModelVersion    string        // 上游响应中的modelVersion（实际提供服务的模型版本）
+	RequestBytes    int64         // 最近一次上游请求体大小（流式发送时为估算值）
+}
+
+type requestTraceKey struct{}
+
+// WithRequestTrace 返回携带路由追踪记录的上下文
+func WithRequestTrace(ctx context.Context, trace *RequestTrace) context.Context {
+	return context.WithValue(ctx, requestTraceKey{}, trace)
+}
+
+// RequestTraceFromContext 从上下文获取路由追踪记录
+func RequestTraceFromContext(ctx context.Context) *RequestTrace {
+	trace, _ := ctx.Value(requestTraceKey{}).(*RequestTrace)
+	return trace
+}
+
+// String 以单行键值形式输出路由信息
+func (t *RequestTrace) String() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	proxy := t.Proxy
+	if proxy == "" {
+		proxy = "direct"
+	}
+	return fmt.Sprintf("mode=%s; project=%s; account=%s; proxy=%s; model=%s; upstream_calls=%d; retries=%d",
+		t.APIMode, t.Project, t.Account, proxy, t.Model, t.UpstreamCalls, t.Retries)
+}
+
+// Summary 输出不含项目、账号和代理等敏感信息的路由摘要，可返回给普通客户端
+func (t *RequestTrace) Summary() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return fmt.Sprintf("mode=%s; model=%s; upstream_calls=%d; retries=%d", t.APIMode, t.Model, t.UpstreamCalls, t.Retries)
+}
+
+// UsageSnapshot 获取已记录的token用量
+func (t *RequestTrace) UsageSnapshot() models.GeminiUsageMetadata {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.Usage
+}
+
+// UpstreamLatencySnapshot 获取最近一次上游请求的响应延迟
+func (t *RequestTrace) UpstreamLatencySnapshot() time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.UpstreamLatency
+}
+
+// FirstTokenSnapshot 获取流式请求的首token耗时，未记录时为0
+func (t *RequestTrace) FirstTokenSnapshot() time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.FirstToken
+}
+
+// ModelSnapshot 获取最近一次上游请求的模型，未请求上游时为空
+func (t *RequestTrace) ModelSnapshot() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.Model
+}
+
+// ModelVersionSnapshot 获取上游实际提供服务的模型版本，未返回时为空
+func (t *RequestTrace) ModelVersionSnapshot() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.ModelVersion
+}
+
+// Recorded 检查是否已记录上游请求
+func (t *RequestTrace) Recorded() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.UpstreamCalls > 0
+}
+
+// recordTrace 记录一次上游请求的路由信息（上下文中无追踪记录时忽略），ctx 使用上游请求的上下文以取得所用账号
+func (c *GeminiClient) recordTrace(ctx context.Context, modelID string, attempt int) {
+	trace := RequestTraceFromContext(ctx)
+	if trace == nil {
+		return
+	}
+
+	trace.mu.Lock()
+	defer trace.mu.Unlock()
+
+	trace.APIMode = c.apiMode()
+	trace.Project = c.projectID()
+	trace.Account = c.accountLabel(ctx)
+	trace.Proxy = redactProxyURL(c.requestProxy(ctx))
+	trace.Model = modelID
+	trace.UpstreamCalls++
+	if attempt > 0 {
+		trace.Retries++
+	}
+}
+
+// recordUsage 累加一次上游请求的token用量，一个代理请求包含多次上游请求（续写、并行、best-of等）时用量合计
+func (c *GeminiClient) recordUsage(ctx context.Context, usage *models.GeminiUsageMetadata) {
+	trace := RequestTraceFromContext(ctx)
+	if trace == nil || usage == nil {
+		return
+	}
+
+	trace.mu.Lock()
+	defer trace.mu.Unlock()
+	trace.Usage.PromptTokenCount += usage.PromptTokenCount
+	trace.Usage.CandidatesTokenCount += usage.CandidatesTokenCount
+	trace.Usage.TotalTokenCount += usage.TotalTokenCount
+}
+
+// recordUpstreamLatency 记录上游请求返回响应头的耗时
+func (c *GeminiClient) recordUpstreamLatency(ctx context.Context, latency time.Duration) {
+	trace := RequestTraceFromContext(ctx)
+	if trace == nil {
+		return
+	}
+
+	trace.mu.Lock()
+	defer trace.mu.Unlock()
+	trace.UpstreamLatency = latency
+}
+
+// recordFirstToken 记录流式请求的首token耗时
+func (c *GeminiClient) recordFirstToken(ctx context.Context, latency time.Duration) {
+	trace := RequestTraceFromContext(ctx)
+	if trace == nil {
+		return
+	}
+
+	trace.mu.Lock()
+	defer trace.mu.Unlock()
+	trace.FirstToken = latency
+}
+
+// recordModelVersion 记录上游返回的模型版本，同一模型的版本发生变化时输出警告
+func (c *GeminiClient) recordModelVersion(ctx context.Context, modelID, version string) {
+	if version == "" {
+		return
+	}
+	if previous, loaded := c.modelVersions.Swap(modelID, version); loaded && previous != version {
+		c.logger.Warnf("Upstream model version changed for %s: %s -> %s", modelID, previous, version)
+	} else if !loaded {
+		c.logger.Infof("Model %s served by version %s", modelID, version)
+	}
+
+	trace := RequestTraceFromContext(ctx)
+	if trace == nil {
+		return
+	}
+
+	trace.mu.Lock()
+	defer trace.mu.Unlock()
+	trace.ModelVersion = version
+}
+
+// accountLabel 返回请求使用的上游账号标识，ctx为已设置认证的上游请求的上下文；
+// 使用账号池时为 account-<账号ID>，否则为 default
+func (c *GeminiClient) accountLabel(ctx context.Context) string {
+	if c.auth == nil || !c.auth.IsInitialized() {
+		return "none"
+	}
+	if account, ok := ctx.Value(accountKey{}).(int); ok {
+		return fmt.Sprintf("account-%d", account)
+	}
+	return "default"
+}
@@ -0,0 +1,92 @@
+package client
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ba0gu0/gemini-go-proxy/pkg/auth"
+	"github.com/ba0gu0/gemini-go-proxy/pkg/config"
+	"github.com/ba0gu0/gemini-go-proxy/pkg/models"
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/oauth2"
+)
+
+func TestGeminiClient_RecordTrace(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.ProjectID = "test-project"
+	client := NewGeminiClient(cfg, nil, nil)
+
+	// 无追踪记录时忽略
+	client.recordTrace(context.Background(), "gemini-pro", 0)
+
+	trace := &RequestTrace{}
+	ctx := WithRequestTrace(context.Background(), trace)
+	assert.Same(t, trace, RequestTraceFromContext(ctx))
+	assert.False(t, trace.Recorded())
+
+	client.recordTrace(ctx, "gemini-pro", 0)
+	client.recordTrace(ctx, "gemini-pro", 1)
+
+	assert.True(t, trace.Recorded())
+	assert.Equal(t, 2, trace.UpstreamCalls)
+	assert.Equal(t, 1, trace.Retries)
+	assert.Equal(t, "mode=code_assist; project=test-project; account=none; proxy=direct; model=gemini-pro; upstream_calls=2; retries=1", trace.String())
+}
+
+func TestGeminiClient_RecordTraceAccount(t *testing.T) {
+	var tokens []string
+	for _, access := range []string{"a", "b"} {
+		data, err := json.Marshal(&oauth2.Token{AccessToken: access, TokenType: "Bearer", Expiry: time.Now().Add(time.Hour)})
+		require.NoError(t, err)
+		tokens = append(tokens, base64.StdEncoding.EncodeToString(data))
+	}
+	googleAuth := auth.NewGoogleAuth(&models.GoogleAuthConfig{OAuthTokens: tokens}, logrus.New())
+	require.NoError(t, googleAuth.Initialize(context.Background()))
+
+	cfg := config.DefaultConfig()
+	cfg.APIMode = config.AIStudio
+	client := NewGeminiClient(cfg, googleAuth, logrus.New())
+	client.client.Transport = roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(`{"candidates":[]}`))}, nil
+	})
+
+	// 账号池按请求轮询，追踪记录中的账号与实际使用的账号一致
+	var accounts []string
+	for i := 0; i < 2; i++ {
+		trace := &RequestTrace{}
+		_, err := client.SendRequestRaw(WithRequestTrace(context.Background(), trace), "gemini-2.5-flash", &models.GeminiRequest{})
+		require.NoError(t, err)
+		accounts = append(accounts, trace.Account)
+	}
+	assert.ElementsMatch(t, []string{"account-0", "account-1"}, accounts)
+}
+
+func TestGeminiClient_RecordModelVersion(t *testing.T) {
+	logger, hook := test.NewNullLogger()
+	client := NewGeminiClient(config.DefaultConfig(), nil, logger)
+
+	trace := &RequestTrace{}
+	ctx := WithRequestTrace(context.Background(), trace)
+
+	client.recordModelVersion(ctx, "gemini-pro", "")
+	assert.Empty(t, trace.ModelVersionSnapshot())
+
+	client.recordModelVersion(ctx, "gemini-pro", "gemini-pro-001")
+	client.recordModelVersion(ctx, "gemini-pro", "gemini-pro-001")
+	assert.Equal(t, "gemini-pro-001", trace.ModelVersionSnapshot())
+	assert.Equal(t, logrus.InfoLevel, hook.LastEntry().Level)
+
+	// 上游更换模型版本时输出警告
+	client.recordModelVersion(context.Background(), "gemini-pro", "gemini-pro-002")
+	assert.Equal(t, logrus.WarnLevel, hook.LastEntry().Level)
+	assert.Contains(t, hook.LastEntry().Message, "gemini-pro-001 -> gemini-pro-002")
+}
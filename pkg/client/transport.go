@@ -0,0 +1,166 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+)
+
+// transportRegistry 按名称注册的自定义传输层，供配置文件中的 transport 字段引用
+var transportRegistry = struct {
+	sync.RWMutex
+	transports map[string]http.RoundTripper
+}{transports: make(map[string]http.RoundTripper)}
+
+// RegisterTransport 注册命名的自定义传输层（如企业认证、链路追踪、录制回放），
+// 配置文件中 "transport": name 即可让客户端使用它
+func RegisterTransport(name string, transport http.RoundTripper) {
+	transportRegistry.Lock()
+	defer transportRegistry.Unlock()
+	if transport == nil {
+		delete(transportRegistry.transports, name)
+		return
+	}
+	transportRegistry.transports[name] = transport
+}
+
+// lookupTransport 查找已注册的传输层
+func lookupTransport(name string) http.RoundTripper {
+	transportRegistry.RLock()
+	defer transportRegistry.RUnlock()
+	return transportRegistry.transports[name]
+}
+
+// SetTransport 设置自定义传输层，替代内部构造的传输层，传入nil恢复默认
+func (c *GeminiClient) SetTransport(transport http.RoundTripper) {
+	c.transports.mu.Lock()
+	defer c.transports.mu.Unlock()
+	c.baseTransport = transport
+	c.transports.closeExcept(nil, transport)
+}
+
+// proxyContextKey 上下文中指定请求使用的代理
+type proxyContextKey struct{}
+
+// withProxy 指定请求使用的代理URL，空字符串表示直连
+func withProxy(ctx context.Context, proxyURL string) context.Context {
+	return context.WithValue(ctx, proxyContextKey{}, proxyURL)
+}
+
+// requestProxy 返回请求使用的代理：上下文中指定的代理，未指定时为当前代理
+func (c *GeminiClient) requestProxy(ctx context.Context) string {
+	if proxyURL, ok := ctx.Value(proxyContextKey{}).(string); ok {
+		return proxyURL
+	}
+	_, current := c.proxies()
+	return current
+}
+
+// transportPool 按代理URL缓存的传输层，同一代理的请求复用连接池
+type transportPool struct {
+	mu         sync.Mutex
+	transports map[string]http.RoundTripper // 代理URL（直连为空字符串）-> 传输层
+}
+
+// closeExcept 移除不在keep中的代理的传输层并关闭其空闲连接，keep为nil时全部移除；调用方需持有锁
+func (p *transportPool) closeExcept(keep []string, base http.RoundTripper) {
+	kept := make(map[string]bool, len(keep))
+	for _, proxyURL := range keep {
+		kept[proxyURL] = true
+	}
+	for proxyURL, transport := range p.transports {
+		if keep != nil && kept[proxyURL] {
+			continue
+		}
+		delete(p.transports, proxyURL)
+		// 自定义传输层和默认传输层不属于连接池，不关闭
+		if t, ok := transport.(*http.Transport); ok && transport != base && transport != http.DefaultTransport {
+			t.CloseIdleConnections()
+		}
+	}
+}
+
+// pruneTransports 代理列表变化后关闭已移除的代理的连接
+func (c *GeminiClient) pruneTransports(proxyURLs []string) {
+	c.transports.mu.Lock()
+	defer c.transports.mu.Unlock()
+	c.transports.closeExcept(append([]string{""}, proxyURLs...), c.baseTransport)
+}
+
+// pooledTransport 返回使用指定代理的传输层，首次使用时创建，之后复用其连接
+func (c *GeminiClient) pooledTransport(proxyURL string) (http.RoundTripper, error) {
+	c.transports.mu.Lock()
+	defer c.transports.mu.Unlock()
+	if transport, ok := c.transports.transports[proxyURL]; ok {
+		return transport, nil
+	}
+
+	var transport http.RoundTripper
+	if proxyURL == "" {
+		transport = c.transportFor(nil)
+	} else {
+		proxy, err := parseProxyURL(proxyURL)
+		if err != nil {
+			return nil, err
+		}
+		transport = c.transportFor(proxy)
+	}
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	if c.transports.transports == nil {
+		c.transports.transports = make(map[string]http.RoundTripper)
+	}
+	c.transports.transports[proxyURL] = transport
+	return transport, nil
+}
+
+// proxyRoundTripper 客户端的传输层，按请求选择对应代理的传输层，不同请求可以并发使用不同代理
+type proxyRoundTripper struct {
+	c *GeminiClient
+}
+
+// RoundTrip 使用请求上下文中指定的代理（未指定时为当前代理）发送请求
+func (t proxyRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	transport, err := t.c.pooledTransport(t.c.requestProxy(req.Context()))
+	if err != nil {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return nil, fmt.Errorf("proxy transport: %w", err)
+	}
+	return transport.RoundTrip(req)
+}
+
+// transportFor 基于自定义传输层（如有）构造使用指定代理的传输层
+func (c *GeminiClient) transportFor(proxy *url.URL) http.RoundTripper {
+	switch base := c.baseTransport.(type) {
+	case nil:
+		connectTimeout := c.config.GetConnectTimeout()
+		if proxy == nil && connectTimeout <= 0 {
+			return nil
+		}
+		// 复制默认传输层以保留连接复用、HTTP/2 和空闲连接设置
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		if proxy != nil {
+			transport.Proxy = http.ProxyURL(proxy)
+		}
+		applyConnectTimeout(transport, connectTimeout)
+		return transport
+	case *http.Transport:
+		// 标准传输层可以复制后设置代理
+		transport := base.Clone()
+		if proxy != nil {
+			transport.Proxy = http.ProxyURL(proxy)
+		}
+		applyConnectTimeout(transport, c.config.GetConnectTimeout())
+		return transport
+	default:
+		if proxy != nil {
+			c.logger.Warnf("Custom transport in use, proxy %s is not applied", proxy.Redacted())
+		}
+		return base
+	}
+}
@@ -0,0 +1,135 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrTTFTDeadline 流式响应在首token截止时间内没有返回任何数据
+var ErrTTFTDeadline = errors.New("time to first token deadline exceeded")
+
+// ttftBuckets 首token耗时分桶上限
+var ttftBuckets = []time.Duration{
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	2500 * time.Millisecond,
+	5 * time.Second,
+}
+
+// TTFTStats 流式请求首token耗时统计
+type TTFTStats struct {
+	Count   int64   `json:"count"`
+	TotalMs int64   `json:"total_ms"`
+	MaxMs   int64   `json:"max_ms"`
+	Retried int64   `json:"retried"` // 超过首token截止时间后重试的次数
+	Buckets []int64 `json:"buckets"` // 按 ≤250ms、≤500ms、≤1s、≤2.5s、≤5s、更长 分桶的请求数
+}
+
+// ttftStats 首token耗时统计收集器
+type ttftStats struct {
+	mu    sync.Mutex
+	stats TTFTStats
+}
+
+// record 记录一次首token耗时
+func (t *ttftStats) record(latency time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.stats.Buckets == nil {
+		t.stats.Buckets = make([]int64, len(ttftBuckets)+1)
+	}
+
+	ms := latency.Milliseconds()
+	t.stats.Count++
+	t.stats.TotalMs += ms
+	if ms > t.stats.MaxMs {
+		t.stats.MaxMs = ms
+	}
+
+	bucket := len(ttftBuckets)
+	for i, limit := range ttftBuckets {
+		if latency <= limit {
+			bucket = i
+			break
+		}
+	}
+	t.stats.Buckets[bucket]++
+}
+
+// retried 记录一次首token超时重试
+func (t *ttftStats) retried() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.stats.Retried++
+}
+
+// TTFTStats 获取首token耗时统计快照
+func (c *GeminiClient) TTFTStats() TTFTStats {
+	c.ttftStats.mu.Lock()
+	defer c.ttftStats.mu.Unlock()
+
+	snapshot := c.ttftStats.stats
+	snapshot.Buckets = append([]int64(nil), c.ttftStats.stats.Buckets...)
+	return snapshot
+}
+
+// firstTokenReader 收到第一个字节时记录首token耗时
+type firstTokenReader struct {
+	io.ReadCloser
+	once   sync.Once
+	record func()
+}
+
+// Read 读取数据，第一次读到数据时记录耗时
+func (r *firstTokenReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	if n > 0 {
+		r.once.Do(r.record)
+	}
+	return n, err
+}
+
+// withFirstTokenRecorder 包装流式响应体，记录从发出请求到收到第一个字节的耗时
+func (c *GeminiClient) withFirstTokenRecorder(ctx context.Context, body io.ReadCloser, modelID string, start time.Time) io.ReadCloser {
+	return &firstTokenReader{ReadCloser: body, record: func() {
+		latency := time.Since(start)
+		c.ttftStats.record(latency)
+		c.recordFirstToken(ctx, latency)
+		c.logger.Debugf("Time to first token for %s: %s", modelID, latency)
+	}}
+}
+
+// bufferedReadCloser 预读后的响应体
+type bufferedReadCloser struct {
+	*bufio.Reader
+	io.Closer
+}
+
+// awaitFirstToken 在截止时间内等待流式响应的第一个字节，超时时调用cancel中止请求
+// cancel必须取消该响应所属请求的上下文
+func awaitFirstToken(body io.ReadCloser, deadline time.Duration, cancel context.CancelFunc) (io.ReadCloser, error) {
+	reader := bufio.NewReader(body)
+
+	var expired atomic.Bool
+	timer := time.AfterFunc(deadline, func() {
+		expired.Store(true)
+		cancel()
+	})
+	// 其他读取错误（包括空响应）留给调用方读取时处理
+	reader.Peek(1)
+	timer.Stop()
+
+	if expired.Load() {
+		body.Close()
+		return nil, fmt.Errorf("no data within %s: %w", deadline, ErrTTFTDeadline)
+	}
+	return &bufferedReadCloser{Reader: reader, Closer: body}, nil
+}
@@ -0,0 +1,68 @@
+package client
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ba0gu0/gemini-go-proxy/pkg/config"
+	"github.com/ba0gu0/gemini-go-proxy/pkg/models"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGeminiClient_TTFTDeadlineRetry(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.APIMode = config.AIStudio
+	cfg.TTFTDeadlineMs = 100
+	client := NewGeminiClient(cfg, nil, logrus.New())
+
+	// 第一次请求不返回数据，第二次立即返回
+	var calls int
+	client.client.Transport = roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		calls++
+		if calls == 1 {
+			return &http.Response{StatusCode: http.StatusOK, Body: &stallingBody{ctx: req.Context(), data: strings.NewReader("")}}, nil
+		}
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader("data: {}\n\n"))}, nil
+	})
+
+	trace := &RequestTrace{}
+	resp, err := client.SendStreamRequestRaw(WithRequestTrace(context.Background(), trace), "gemini-2.5-flash", &models.GeminiRequest{})
+	require.NoError(t, err)
+	data, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	assert.Equal(t, "data: {}\n\n", string(data))
+	assert.Equal(t, 2, calls)
+	assert.Equal(t, 1, trace.Retries)
+	assert.Positive(t, trace.FirstTokenSnapshot())
+
+	stats := client.TTFTStats()
+	assert.Equal(t, int64(1), stats.Count)
+	assert.Equal(t, int64(1), stats.Retried)
+	assert.Len(t, stats.Buckets, len(ttftBuckets)+1)
+}
+
+func TestGeminiClient_TTFTDeadlineExhausted(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.APIMode = config.AIStudio
+	cfg.MaxRetries = 2
+	cfg.TTFTDeadlineMs = 50
+	client := NewGeminiClient(cfg, nil, logrus.New())
+	client.client.Transport = roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusOK, Body: &stallingBody{ctx: req.Context(), data: strings.NewReader("")}}, nil
+	})
+
+	start := time.Now()
+	_, err := client.SendStreamRequestRaw(context.Background(), "gemini-2.5-flash", &models.GeminiRequest{})
+	require.Error(t, err)
+	assert.ErrorIs(t, err, ErrTTFTDeadline)
+	assert.Less(t, time.Since(start), 2*time.Second)
+	assert.Equal(t, int64(0), client.TTFTStats().Count)
+}
@@ -0,0 +1,106 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+
+	"github.com/ba0gu0/gemini-go-proxy/pkg/models"
+)
+
+// maxUsageLineSize 统计用量时缓存的单行SSE数据上限，超过时跳过该行
+const maxUsageLineSize = 1 << 20
+
+// usageFromPayload 从响应JSON（兼容Code Assist的 response 包装）中提取 usageMetadata，没有时返回nil
+func usageFromPayload(data []byte) *models.GeminiUsageMetadata {
+	var payload struct {
+		UsageMetadata *models.GeminiUsageMetadata `json:"usageMetadata"`
+		Response      *struct {
+			UsageMetadata *models.GeminiUsageMetadata `json:"usageMetadata"`
+		} `json:"response"`
+	}
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return nil
+	}
+	if payload.Response != nil && payload.Response.UsageMetadata != nil {
+		return payload.Response.UsageMetadata
+	}
+	return payload.UsageMetadata
+}
+
+// recordResponseUsage 累加非流式响应体中的token用量
+func (c *GeminiClient) recordResponseUsage(ctx context.Context, body []byte) {
+	c.recordUsage(ctx, usageFromPayload(body))
+}
+
+// usageSSEReader 读取流式响应时统计 data 行中的token用量，数据原样返回。
+// 同一个流中的用量为累计值，每次只累加与上一次的差值
+type usageSSEReader struct {
+	io.ReadCloser
+	line   []byte
+	last   models.GeminiUsageMetadata
+	record func(delta *models.GeminiUsageMetadata)
+}
+
+// withUsageRecorder 包装流式响应体，把用量累加到请求的追踪记录
+func (c *GeminiClient) withUsageRecorder(ctx context.Context, body io.ReadCloser) io.ReadCloser {
+	if RequestTraceFromContext(ctx) == nil {
+		return body
+	}
+	return &usageSSEReader{ReadCloser: body, record: func(delta *models.GeminiUsageMetadata) {
+		c.recordUsage(ctx, delta)
+	}}
+}
+
+// Read 读取数据并检查其中完整的行
+func (r *usageSSEReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	data := p[:n]
+	for len(data) > 0 {
+		i := bytes.IndexByte(data, '\n')
+		if i < 0 {
+			r.appendLine(data)
+			break
+		}
+		r.appendLine(data[:i])
+		r.observe(r.line)
+		r.line = r.line[:0]
+		data = data[i+1:]
+	}
+	if err == io.EOF && len(r.line) > 0 {
+		r.observe(r.line)
+		r.line = r.line[:0]
+	}
+	return n, err
+}
+
+// appendLine 缓存不完整的行，超过上限的行不再统计
+func (r *usageSSEReader) appendLine(data []byte) {
+	if len(r.line)+len(data) <= maxUsageLineSize {
+		r.line = append(r.line, data...)
+	}
+}
+
+// observe 解析一行 data 中的用量并累加增量
+func (r *usageSSEReader) observe(line []byte) {
+	data, ok := bytes.CutPrefix(bytes.TrimRight(line, "\r"), []byte("data: "))
+	if !ok {
+		return
+	}
+	usage := usageFromPayload(data)
+	if usage == nil {
+		return
+	}
+	delta := models.GeminiUsageMetadata{
+		PromptTokenCount:     max(usage.PromptTokenCount-r.last.PromptTokenCount, 0),
+		CandidatesTokenCount: max(usage.CandidatesTokenCount-r.last.CandidatesTokenCount, 0),
+		TotalTokenCount:      max(usage.TotalTokenCount-r.last.TotalTokenCount, 0),
+	}
+	r.last = models.GeminiUsageMetadata{
+		PromptTokenCount:     max(usage.PromptTokenCount, r.last.PromptTokenCount),
+		CandidatesTokenCount: max(usage.CandidatesTokenCount, r.last.CandidatesTokenCount),
+		TotalTokenCount:      max(usage.TotalTokenCount, r.last.TotalTokenCount),
+	}
+	r.record(&delta)
+}
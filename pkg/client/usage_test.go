@@ -0,0 +1,99 @@
+package client
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/ba0gu0/gemini-go-proxy/pkg/config"
+	"github.com/ba0gu0/gemini-go-proxy/pkg/models"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUsageFromPayload(t *testing.T) {
+	assert.Equal(t, 7, usageFromPayload([]byte(`{"usageMetadata":{"totalTokenCount":7}}`)).TotalTokenCount)
+	assert.Equal(t, 9, usageFromPayload([]byte(`{"response":{"usageMetadata":{"totalTokenCount":9}},"traceId":"t"}`)).TotalTokenCount)
+	assert.Nil(t, usageFromPayload([]byte(`{"candidates":[]}`)))
+	assert.Nil(t, usageFromPayload([]byte(`not json`)))
+}
+
+func TestGeminiClient_UsageAddsUpAcrossCalls(t *testing.T) {
+	responses := []string{
+		`{"candidates":[{"content":{"parts":[{"text":"Hello"}]},"finishReason":"MAX_TOKENS"}],"usageMetadata":{"promptTokenCount":10,"candidatesTokenCount":5,"totalTokenCount":15}}`,
+		`{"candidates":[{"content":{"parts":[{"text":" world"}]},"finishReason":"STOP"}],"usageMetadata":{"promptTokenCount":20,"candidatesTokenCount":3,"totalTokenCount":23}}`,
+	}
+
+	cfg := config.DefaultConfig()
+	cfg.APIMode = config.AIStudio
+	cfg.ContinuationMaxRounds = 1
+	client := NewGeminiClient(cfg, nil, logrus.New())
+	calls := 0
+	client.client.Transport = roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		body := responses[calls]
+		calls++
+		return &http.Response{StatusCode: http.StatusOK, Header: http.Header{"Content-Type": []string{"application/json"}}, Body: io.NopCloser(strings.NewReader(body))}, nil
+	})
+
+	trace := &RequestTrace{}
+	ctx := WithRequestTrace(context.Background(), trace)
+	req := &models.GeminiRequest{Contents: []models.GeminiContent{{Role: "user", Parts: []models.GeminiPart{{Text: "hi"}}}}}
+	_, err := client.SendRequest(ctx, "gemini-2.5-flash", req)
+	require.NoError(t, err)
+
+	// 续写的上游请求同样计入用量
+	assert.Equal(t, models.GeminiUsageMetadata{PromptTokenCount: 30, CandidatesTokenCount: 8, TotalTokenCount: 38}, trace.UsageSnapshot())
+}
+
+func TestGeminiClient_UsageFromRawStream(t *testing.T) {
+	stream := "data: {\"response\":{\"candidates\":[],\"usageMetadata\":{\"promptTokenCount\":10,\"candidatesTokenCount\":2,\"totalTokenCount\":12}}}\r\n\r\n" +
+		"data: {\"response\":{\"candidates\":[],\"usageMetadata\":{\"promptTokenCount\":10,\"candidatesTokenCount\":6,\"totalTokenCount\":16}}}\r\n\r\n"
+
+	client := NewGeminiClient(config.DefaultConfig(), nil, logrus.New())
+	client.client.Transport = roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusOK, Header: http.Header{"Content-Type": []string{"text/event-stream"}}, Body: io.NopCloser(strings.NewReader(stream))}, nil
+	})
+
+	trace := &RequestTrace{}
+	ctx := WithRequestTrace(context.Background(), trace)
+	req := &models.GeminiRequest{Contents: []models.GeminiContent{{Role: "user", Parts: []models.GeminiPart{{Text: "hi"}}}}}
+	for i := 0; i < 2; i++ {
+		resp, err := client.SendStreamRequestRaw(ctx, "gemini-2.5-flash", req)
+		require.NoError(t, err)
+		_, err = io.Copy(io.Discard, iotest.OneByteReader(resp.Body))
+		require.NoError(t, err)
+		resp.Body.Close()
+	}
+
+	// 流中的用量为累计值，每个流只计最终值，多个流相加
+	assert.Equal(t, models.GeminiUsageMetadata{PromptTokenCount: 20, CandidatesTokenCount: 12, TotalTokenCount: 32}, trace.UsageSnapshot())
+}
+
+func TestNativeReverseProxy_RecordsUsage(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.APIMode = config.AIStudio
+	client := NewGeminiClient(cfg, nil, logrus.New())
+	client.client.Transport = roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		body := `{"candidates":[],"usageMetadata":{"promptTokenCount":4,"candidatesTokenCount":1,"totalTokenCount":5}}`
+		if strings.HasSuffix(req.URL.Path, ":streamGenerateContent") {
+			body = "data: {\"candidates\":[],\"usageMetadata\":{\"promptTokenCount\":4,\"candidatesTokenCount\":3,\"totalTokenCount\":7}}\n\n"
+		}
+		return &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: io.NopCloser(strings.NewReader(body)), Request: req}, nil
+	})
+
+	proxy := client.NativeReverseProxy()
+	trace := &RequestTrace{}
+	for _, stream := range []bool{false, true} {
+		req := httptest.NewRequest("POST", "/v1beta/models/gemini-2.5-flash:generateContent", strings.NewReader(`{"contents":[]}`))
+		req = req.WithContext(WithNativeRoute(WithRequestTrace(context.Background(), trace), "gemini-2.5-flash", stream))
+		rec := httptest.NewRecorder()
+		proxy.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusOK, rec.Code)
+	}
+	assert.Equal(t, 12, trace.UsageSnapshot().TotalTokenCount)
+}
@@ -0,0 +1,106 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+const (
+	// VertexRequestTypeHeader Vertex AI预配置吞吐量流量类别头部
+	VertexRequestTypeHeader = "X-Vertex-AI-LLM-Request-Type"
+	// QuotaProjectHeader 计费/配额项目头部
+	QuotaProjectHeader = "X-Goog-User-Project"
+)
+
+// 允许的Vertex AI请求类型
+const (
+	VertexRequestTypeDedicated = "dedicated"
+	VertexRequestTypeShared    = "shared"
+)
+
+// ErrInvalidLocation 客户端提供的Vertex AI location不合法
+var ErrInvalidLocation = errors.New("invalid Vertex AI location")
+
+// locationPattern Vertex AI location 只包含小写字母、数字和连字符，如 us-central1
+var locationPattern = regexp.MustCompile(`^[a-z0-9-]+$`)
+
+// ValidateLocation 校验Vertex AI location，location会成为上游主机名的一部分，不能交给客户端任意指定
+func ValidateLocation(location string) error {
+	if !locationPattern.MatchString(location) {
+		return fmt.Errorf("%w: %q", ErrInvalidLocation, location)
+	}
+	return nil
+}
+
+// vertexBaseURL 返回location对应的Vertex AI端点
+func vertexBaseURL(location string) (*url.URL, error) {
+	if err := ValidateLocation(location); err != nil {
+		return nil, err
+	}
+	return &url.URL{Scheme: "https", Host: location + "-aiplatform.googleapis.com"}, nil
+}
+
+type vertexRequestTypeKey struct{}
+
+// WithVertexRequestType 返回携带单次请求Vertex AI请求类型的上下文，覆盖配置中的默认值
+func WithVertexRequestType(ctx context.Context, requestType string) context.Context {
+	return context.WithValue(ctx, vertexRequestTypeKey{}, requestType)
+}
+
+// applyVertexHeaders 设置Vertex AI请求类型和配额项目头部
+func (c *GeminiClient) applyVertexHeaders(ctx context.Context, req *http.Request) {
+	if requestType := c.vertexRequestType(ctx); requestType != "" {
+		requestType = strings.ToLower(requestType)
+		if requestType == VertexRequestTypeDedicated || requestType == VertexRequestTypeShared {
+			req.Header.Set(VertexRequestTypeHeader, requestType)
+		} else {
+			c.logger.Warnf("Ignoring invalid Vertex AI request type: %s", requestType)
+		}
+	}
+
+	if c.config.QuotaProjectID != "" {
+		req.Header.Set(QuotaProjectHeader, c.config.QuotaProjectID)
+	}
+}
+
+// vertexRequestType 返回请求使用的Vertex AI请求类型，上下文中的覆盖值优先
+func (c *GeminiClient) vertexRequestType(ctx context.Context) string {
+	if override, ok := ctx.Value(vertexRequestTypeKey{}).(string); ok && override != "" {
+		return override
+	}
+	return c.config.VertexRequestType
+}
+
+// ForwardVertexRequest 注入认证后将请求原样转发到Vertex AI资源路径（如调优任务和长时间运行操作），
+// resourcePath 形如 projects/{project}/locations/{location}/tuningJobs/{job}，调用方负责关闭响应体
+func (c *GeminiClient) ForwardVertexRequest(ctx context.Context, method, resourcePath, rawQuery string, body io.Reader) (*http.Response, error) {
+	segments := strings.Split(strings.Trim(resourcePath, "/"), "/")
+	if len(segments) < 5 || segments[0] != "projects" || segments[2] != "locations" {
+		return nil, fmt.Errorf("invalid Vertex AI resource path: %s", resourcePath)
+	}
+	apiURL, err := vertexBaseURL(segments[3])
+	if err != nil {
+		return nil, err
+	}
+	apiURL.Path = "/v1/" + strings.Trim(resourcePath, "/")
+	apiURL.RawQuery = rawQuery
+
+	httpReq, err := c.createRequest(ctx, method, apiURL.String(), body)
+	if err != nil {
+		return nil, err
+	}
+
+	c.logger.Debugf("Forwarding Vertex AI request: %s %s", method, resourcePath)
+
+	resp, err := c.client.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("vertex request failed: %w", err)
+	}
+	return resp, nil
+}
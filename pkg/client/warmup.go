@@ -0,0 +1,67 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/ba0gu0/gemini-go-proxy/pkg/config"
+)
+
+// Warmup 预热客户端：校验/刷新token、预取模型列表并建立到上游的连接，
+// 避免首个用户请求承担全部冷启动延迟。各步骤独立执行，返回合并后的错误
+func (c *GeminiClient) Warmup(ctx context.Context) error {
+	start := time.Now()
+	var errs []error
+
+	// 校验token，过期时会自动刷新
+	if c.auth != nil && c.auth.IsInitialized() {
+		if _, err := c.auth.GetToken(); err != nil {
+			errs = append(errs, fmt.Errorf("token validation failed: %w", err))
+		}
+	} else {
+		errs = append(errs, fmt.Errorf("auth not initialized"))
+	}
+
+	// 预取模型列表
+	if _, err := c.ListModels(ctx); err != nil {
+		errs = append(errs, fmt.Errorf("model list prefetch failed: %w", err))
+	}
+
+	// 建立TLS连接放入连接池
+	if err := c.warmupConnection(ctx); err != nil {
+		errs = append(errs, fmt.Errorf("connection warmup failed: %w", err))
+	}
+
+	c.logger.Infof("Client warmup finished in %v", time.Since(start).Round(time.Millisecond))
+	return errors.Join(errs...)
+}
+
+// upstreamEndpoint 返回当前模式的上游端点
+func (c *GeminiClient) upstreamEndpoint() string {
+	switch c.apiMode() {
+	case config.CodeAssist:
+		return CodeAssistEndpoint
+	case config.VertexAI:
+		return fmt.Sprintf(VertexAPIEndpoint, c.location())
+	}
+	return DefaultAPIEndpoint
+}
+
+// warmupConnection 向当前模式的上游端点发送HEAD请求以建立连接
+func (c *GeminiClient) warmupConnection(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, "HEAD", c.upstreamEndpoint(), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("User-Agent", c.config.UserAgent)
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
@@ -0,0 +1,116 @@
+package cluster
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ba0gu0/gemini-go-proxy/pkg/config"
+	"github.com/sirupsen/logrus"
+)
+
+// RegisterPath 前置路由接受实例注册的路径
+const RegisterPath = "/cluster/register"
+
+// Agent 定期向前置路由注册本实例并上报状态
+type Agent struct {
+	join    config.ClusterJoin
+	apiKey  string
+	report  func(ctx context.Context) InstanceReport
+	onDrain func(draining bool)
+	client  *http.Client
+	logger  *logrus.Logger
+
+	draining bool
+}
+
+// NewAgent 创建注册代理，report用于收集上报状态，onDrain在排空状态变化时调用
+func NewAgent(join config.ClusterJoin, apiKey string, report func(ctx context.Context) InstanceReport, onDrain func(draining bool), logger *logrus.Logger) (*Agent, error) {
+	if join.RouterURL == "" || join.AdvertiseURL == "" {
+		return nil, fmt.Errorf("cluster join requires router_url and advertise_url")
+	}
+	if logger == nil {
+		logger = logrus.New()
+	}
+	return &Agent{
+		join:    join,
+		apiKey:  apiKey,
+		report:  report,
+		onDrain: onDrain,
+		client:  &http.Client{Timeout: 10 * time.Second},
+		logger:  logger,
+	}, nil
+}
+
+// Start 立即注册并按心跳间隔持续上报，直到ctx取消
+func (a *Agent) Start(ctx context.Context) {
+	interval := DefaultHealthCheckInterval
+	if a.join.IntervalSeconds > 0 {
+		interval = time.Duration(a.join.IntervalSeconds) * time.Second
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			if err := a.Heartbeat(ctx); err != nil && ctx.Err() == nil {
+				a.logger.WithError(err).Warn("Failed to report to cluster router")
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Heartbeat 向前置路由发送一次注册/心跳
+func (a *Agent) Heartbeat(ctx context.Context) error {
+	reg := Registration{
+		URL:             a.join.AdvertiseURL,
+		APIKey:          a.apiKey,
+		Weight:          a.join.Weight,
+		IntervalSeconds: a.join.IntervalSeconds,
+	}
+	if a.report != nil {
+		reg.Report = a.report(ctx)
+	}
+
+	body, err := json.Marshal(reg)
+	if err != nil {
+		return fmt.Errorf("failed to marshal registration: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(a.join.RouterURL, "/")+RegisterPath, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create registration request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(SecretHeader, a.join.Secret)
+
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to register with router: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("router rejected registration: status %d", resp.StatusCode)
+	}
+
+	var result RegistrationResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return fmt.Errorf("failed to decode registration response: %w", err)
+	}
+	if result.Draining != a.draining {
+		a.draining = result.Draining
+		a.logger.Infof("Cluster router set draining=%t", result.Draining)
+		if a.onDrain != nil {
+			a.onDrain(result.Draining)
+		}
+	}
+	return nil
+}
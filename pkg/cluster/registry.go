@@ -0,0 +1,115 @@
+package cluster
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ba0gu0/gemini-go-proxy/pkg/config"
+)
+
+// SecretHeader 实例注册请求携带共享密钥的请求头
+const SecretHeader = "X-Cluster-Secret"
+
+// staleHeartbeats 超过该数量的心跳间隔未上报时视为失联
+const staleHeartbeats = 3
+
+// InstanceReport 实例上报的账号与健康状态
+type InstanceReport struct {
+	Healthy  bool           `json:"healthy"`
+	Ready    bool           `json:"ready"`
+	Accounts int            `json:"accounts"`
+	Details  map[string]any `json:"details,omitempty"`
+}
+
+// Registration 实例注册/心跳请求
+type Registration struct {
+	URL             string         `json:"url"`
+	APIKey          string         `json:"api_key,omitempty"`
+	Weight          int            `json:"weight,omitempty"`
+	IntervalSeconds int            `json:"interval_seconds,omitempty"`
+	Report          InstanceReport `json:"report"`
+}
+
+// RegistrationResponse 注册响应，告知实例是否被要求排空
+type RegistrationResponse struct {
+	Draining bool `json:"draining"`
+}
+
+// CheckSecret 校验实例注册使用的共享密钥，未配置密钥时拒绝注册
+func (r *Router) CheckSecret(secret string) bool {
+	return r.secret != "" && subtle.ConstantTimeCompare([]byte(secret), []byte(r.secret)) == 1
+}
+
+// Register 注册或更新实例，并记录其上报的状态
+func (r *Router) Register(reg Registration) (*RegistrationResponse, error) {
+	url := strings.TrimSuffix(reg.URL, "/")
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	upstream := r.findLocked(url)
+	if upstream == nil {
+		var err error
+		upstream, err = r.newUpstream(config.RouterUpstream{URL: url, Weight: reg.Weight, APIKey: reg.APIKey})
+		if err != nil {
+			return nil, err
+		}
+		upstream.registered = true
+		r.upstreams = append(r.upstreams, upstream)
+		r.logger.Infof("Upstream %s registered", url)
+	} else if upstream.registered {
+		upstream.setAPIKey(reg.APIKey)
+		if reg.Weight > 0 {
+			upstream.Weight = reg.Weight
+		}
+	}
+
+	interval := r.interval
+	if reg.IntervalSeconds > 0 {
+		interval = time.Duration(reg.IntervalSeconds) * time.Second
+	}
+	report := reg.Report
+	upstream.report = &report
+	upstream.lastSeen = time.Now()
+	upstream.heartbeat = interval
+	upstream.healthy.Store(report.Healthy)
+
+	return &RegistrationResponse{Draining: upstream.draining.Load()}, nil
+}
+
+// Drain 设置实例是否排空，排空的实例不再接收新请求
+func (r *Router) Drain(url string, drain bool) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	upstream := r.findLocked(strings.TrimSuffix(url, "/"))
+	if upstream == nil {
+		return fmt.Errorf("upstream %s not found", url)
+	}
+	upstream.draining.Store(drain)
+	r.logger.Infof("Upstream %s draining=%t", url, drain)
+	return nil
+}
+
+// findLocked 按URL查找实例，调用方需持有r.mu
+func (r *Router) findLocked(url string) *Upstream {
+	for _, upstream := range r.upstreams {
+		if upstream.URL.String() == url {
+			return upstream
+		}
+	}
+	return nil
+}
+
+// expireLocked 将心跳超时的注册实例标记为不健康，调用方需持有r.mu
+func (r *Router) expireLocked(now time.Time) {
+	for _, upstream := range r.upstreams {
+		if upstream.registered && now.Sub(upstream.lastSeen) > staleHeartbeats*upstream.heartbeat {
+			if upstream.healthy.Swap(false) {
+				r.logger.Warnf("Upstream %s missed heartbeats, marking unhealthy", upstream.URL)
+			}
+		}
+	}
+}
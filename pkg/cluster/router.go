@@ -0,0 +1,260 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ba0gu0/gemini-go-proxy/pkg/config"
+	"github.com/sirupsen/logrus"
+)
+
+// DefaultHealthCheckInterval 下游健康检查默认间隔
+const DefaultHealthCheckInterval = 10 * time.Second
+
+// Upstream 下游代理实例
+type Upstream struct {
+	URL    *url.URL
+	Weight int // 由Router.mu保护
+
+	apiKey   atomic.Pointer[string] // 访问实例使用的密钥，注册实例可在心跳时更新
+	healthy  atomic.Bool
+	draining atomic.Bool
+	inflight atomic.Int64
+	current  int // 平滑加权轮询的当前权重，由Router.mu保护
+	proxy    *httputil.ReverseProxy
+
+	// 通过注册加入的实例，以下字段由Router.mu保护
+	registered bool
+	lastSeen   time.Time
+	heartbeat  time.Duration
+	report     *InstanceReport
+}
+
+// UpstreamStatus 下游实例状态
+type UpstreamStatus struct {
+	URL        string          `json:"url"`
+	Weight     int             `json:"weight"`
+	Healthy    bool            `json:"healthy"`
+	Draining   bool            `json:"draining"`
+	Inflight   int64           `json:"inflight"`
+	Registered bool            `json:"registered"`
+	LastSeen   *time.Time      `json:"last_seen,omitempty"`
+	Report     *InstanceReport `json:"report,omitempty"`
+}
+
+// Router 将请求按权重分发到健康的下游代理实例
+type Router struct {
+	mu        sync.Mutex
+	upstreams []*Upstream
+	interval  time.Duration
+	secret    string // 实例注册共享密钥
+	client    *http.Client
+	logger    *logrus.Logger
+}
+
+// NewRouter 根据路由配置创建路由器
+func NewRouter(cfg *config.RouterConfig, logger *logrus.Logger) (*Router, error) {
+	if cfg == nil || (len(cfg.Upstreams) == 0 && cfg.Secret == "") {
+		return nil, fmt.Errorf("router requires at least one upstream or a registration secret")
+	}
+	if logger == nil {
+		logger = logrus.New()
+	}
+
+	r := &Router{
+		interval: DefaultHealthCheckInterval,
+		secret:   cfg.Secret,
+		client:   &http.Client{Timeout: 5 * time.Second},
+		logger:   logger,
+	}
+	if cfg.HealthCheckSeconds > 0 {
+		r.interval = time.Duration(cfg.HealthCheckSeconds) * time.Second
+	}
+
+	for _, upstreamConfig := range cfg.Upstreams {
+		upstream, err := r.newUpstream(upstreamConfig)
+		if err != nil {
+			return nil, err
+		}
+		r.upstreams = append(r.upstreams, upstream)
+	}
+	return r, nil
+}
+
+// newUpstream 创建下游实例及其反向代理
+func (r *Router) newUpstream(cfg config.RouterUpstream) (*Upstream, error) {
+	target, err := url.Parse(strings.TrimSuffix(cfg.URL, "/"))
+	if err != nil || target.Scheme == "" || target.Host == "" {
+		return nil, fmt.Errorf("invalid upstream URL %q", cfg.URL)
+	}
+
+	upstream := &Upstream{URL: target, Weight: cfg.Weight}
+	if upstream.Weight <= 0 {
+		upstream.Weight = 1
+	}
+	upstream.setAPIKey(cfg.APIKey)
+	upstream.healthy.Store(true)
+	upstream.proxy = &httputil.ReverseProxy{
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			pr.SetURL(target)
+			pr.SetXForwarded()
+			rewriteCredentials(pr.Out, *upstream.apiKey.Load())
+		},
+		// 流式响应立即转发
+		FlushInterval: -1,
+		ErrorHandler: func(w http.ResponseWriter, req *http.Request, err error) {
+			if req.Context().Err() == nil {
+				upstream.healthy.Store(false)
+				r.logger.WithError(err).Warnf("Upstream %s failed, marking unhealthy", target)
+			}
+			writeError(w, http.StatusBadGateway, "Upstream proxy instance unavailable")
+		},
+	}
+	return upstream, nil
+}
+
+// setAPIKey 更新访问实例使用的密钥
+func (u *Upstream) setAPIKey(apiKey string) {
+	u.apiKey.Store(&apiKey)
+}
+
+// rewriteCredentials 去掉客户端凭据，改用访问下游实例的密钥
+func rewriteCredentials(req *http.Request, apiKey string) {
+	req.Header.Del("Authorization")
+	req.Header.Del("X-API-Key")
+	req.Header.Del("x-goog-api-key")
+	if query := req.URL.Query(); query.Has("key") {
+		query.Del("key")
+		req.URL.RawQuery = query.Encode()
+	}
+	if apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+apiKey)
+	}
+}
+
+// ServeHTTP 选择下游实例并转发请求
+func (r *Router) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	upstream := r.next()
+	if upstream == nil {
+		writeError(w, http.StatusServiceUnavailable, "No healthy upstream proxy instance")
+		return
+	}
+
+	upstream.inflight.Add(1)
+	defer upstream.inflight.Add(-1)
+	upstream.proxy.ServeHTTP(w, req)
+}
+
+// next 在健康实例中按平滑加权轮询选择下一个实例
+func (r *Router) next() *Upstream {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var selected *Upstream
+	total := 0
+	for _, upstream := range r.upstreams {
+		if !upstream.healthy.Load() || upstream.draining.Load() {
+			continue
+		}
+		upstream.current += upstream.Weight
+		total += upstream.Weight
+		if selected == nil || upstream.current > selected.current {
+			selected = upstream
+		}
+	}
+	if selected != nil {
+		selected.current -= total
+	}
+	return selected
+}
+
+// Start 启动下游健康检查，直到ctx取消
+func (r *Router) Start(ctx context.Context) {
+	go func() {
+		r.checkAll(ctx)
+		ticker := time.NewTicker(r.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				r.checkAll(ctx)
+			}
+		}
+	}()
+}
+
+// checkAll 检查静态配置实例的 /health 接口，注册实例依据心跳判断
+func (r *Router) checkAll(ctx context.Context) {
+	r.mu.Lock()
+	r.expireLocked(time.Now())
+	var upstreams []*Upstream
+	for _, upstream := range r.upstreams {
+		if !upstream.registered {
+			upstreams = append(upstreams, upstream)
+		}
+	}
+	r.mu.Unlock()
+
+	for _, upstream := range upstreams {
+		healthy := r.check(ctx, upstream)
+		if previous := upstream.healthy.Swap(healthy); previous != healthy {
+			r.logger.Infof("Upstream %s healthy=%t", upstream.URL, healthy)
+		}
+	}
+}
+
+// check 检查单个下游实例
+func (r *Router) check(ctx context.Context, upstream *Upstream) bool {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, upstream.URL.String()+"/health", nil)
+	if err != nil {
+		return false
+	}
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return false
+	}
+	resp.Body.Close()
+	return resp.StatusCode == http.StatusOK
+}
+
+// Status 返回所有下游实例的状态
+func (r *Router) Status() []UpstreamStatus {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	statuses := make([]UpstreamStatus, 0, len(r.upstreams))
+	for _, upstream := range r.upstreams {
+		status := UpstreamStatus{
+			URL:        upstream.URL.String(),
+			Weight:     upstream.Weight,
+			Healthy:    upstream.healthy.Load(),
+			Draining:   upstream.draining.Load(),
+			Inflight:   upstream.inflight.Load(),
+			Registered: upstream.registered,
+			Report:     upstream.report,
+		}
+		if !upstream.lastSeen.IsZero() {
+			lastSeen := upstream.lastSeen
+			status.LastSeen = &lastSeen
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
+
+// writeError 写入与代理服务器一致的JSON错误响应
+func writeError(w http.ResponseWriter, statusCode int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	fmt.Fprintf(w, `{"error":{"code":%d,"message":%q,"status":"upstream_error"},"status":"error","timestamp":%q}`+"\n",
+		statusCode, message, time.Now().Format(time.RFC3339))
+}
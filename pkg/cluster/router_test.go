@@ -0,0 +1,149 @@
+package cluster
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ba0gu0/gemini-go-proxy/pkg/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newInstance 创建模拟下游实例，返回实例名称并记录收到的Authorization头
+func newInstance(t *testing.T, name string, auth *string) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/health" {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		if auth != nil {
+			*auth = r.Header.Get("Authorization") + "|" + r.URL.RawQuery
+		}
+		io.WriteString(w, name)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func get(t *testing.T, handler http.Handler, target string) *httptest.ResponseRecorder {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+	return rec
+}
+
+func TestRouter_WeightedDistribution(t *testing.T) {
+	var auth string
+	a := newInstance(t, "a", &auth)
+	b := newInstance(t, "b", nil)
+
+	router, err := NewRouter(&config.RouterConfig{Upstreams: []config.RouterUpstream{
+		{URL: a.URL, Weight: 2, APIKey: "key-a"},
+		{URL: b.URL, Weight: 1},
+	}}, nil)
+	require.NoError(t, err)
+
+	counts := map[string]int{}
+	for i := 0; i < 6; i++ {
+		rec := get(t, router, "/v1/models?key=client&x=1")
+		require.Equal(t, http.StatusOK, rec.Code)
+		counts[rec.Body.String()]++
+	}
+	assert.Equal(t, map[string]int{"a": 4, "b": 2}, counts)
+	assert.Equal(t, "Bearer key-a|x=1", auth)
+}
+
+func TestRouter_UnhealthyUpstreamSkipped(t *testing.T) {
+	a := newInstance(t, "a", nil)
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+
+	router, err := NewRouter(&config.RouterConfig{Upstreams: []config.RouterUpstream{
+		{URL: a.URL},
+		{URL: down.URL},
+	}}, nil)
+	require.NoError(t, err)
+
+	router.checkAll(context.Background())
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, "a", get(t, router, "/x").Body.String())
+	}
+
+	status := router.Status()
+	require.Len(t, status, 2)
+	assert.True(t, status[0].Healthy)
+	assert.False(t, status[1].Healthy)
+
+	// 全部不可用时返回503
+	router.upstreams[0].healthy.Store(false)
+	assert.Equal(t, http.StatusServiceUnavailable, get(t, router, "/x").Code)
+}
+
+func TestNewRouter_Errors(t *testing.T) {
+	_, err := NewRouter(&config.RouterConfig{}, nil)
+	assert.Error(t, err)
+
+	_, err = NewRouter(&config.RouterConfig{Upstreams: []config.RouterUpstream{{URL: "not a url"}}}, nil)
+	assert.Error(t, err)
+}
+
+func TestRouter_RegistrationAndDrain(t *testing.T) {
+	instance := newInstance(t, "registered", nil)
+
+	router, err := NewRouter(&config.RouterConfig{Secret: "cluster-secret"}, nil)
+	require.NoError(t, err)
+	assert.False(t, router.CheckSecret("wrong"))
+	assert.True(t, router.CheckSecret("cluster-secret"))
+
+	// 没有实例时返回503
+	assert.Equal(t, http.StatusServiceUnavailable, get(t, router, "/x").Code)
+
+	routerServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var reg Registration
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&reg))
+		require.True(t, router.CheckSecret(r.Header.Get(SecretHeader)))
+		resp, err := router.Register(reg)
+		require.NoError(t, err)
+		json.NewEncoder(w).Encode(resp)
+	}))
+	defer routerServer.Close()
+
+	var drained []bool
+	agent, err := NewAgent(config.ClusterJoin{
+		RouterURL:    routerServer.URL,
+		AdvertiseURL: instance.URL,
+		Secret:       "cluster-secret",
+	}, "instance-key", func(ctx context.Context) InstanceReport {
+		return InstanceReport{Healthy: true, Ready: true, Accounts: 2}
+	}, func(draining bool) {
+		drained = append(drained, draining)
+	}, nil)
+	require.NoError(t, err)
+
+	require.NoError(t, agent.Heartbeat(context.Background()))
+	assert.Equal(t, "registered", get(t, router, "/x").Body.String())
+
+	status := router.Status()
+	require.Len(t, status, 1)
+	assert.True(t, status[0].Registered)
+	assert.Equal(t, 2, status[0].Report.Accounts)
+
+	// 排空后不再分发请求，心跳响应通知实例
+	require.NoError(t, router.Drain(instance.URL, true))
+	assert.Equal(t, http.StatusServiceUnavailable, get(t, router, "/x").Code)
+	require.NoError(t, agent.Heartbeat(context.Background()))
+	assert.Equal(t, []bool{true}, drained)
+
+	// 心跳超时的实例被标记为不健康
+	require.NoError(t, router.Drain(instance.URL, false))
+	router.expireLocked(time.Now().Add(time.Hour))
+	assert.False(t, router.Status()[0].Healthy)
+
+	assert.Error(t, router.Drain("http://unknown", true))
+}
@@ -0,0 +1,84 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DefaultBackupMaxCount 默认保留的配置备份数量
+const DefaultBackupMaxCount = 10
+
+// backupTimeLayout 备份文件名中的时间格式
+const backupTimeLayout = "20060102_150405"
+
+// BackupFileName 返回配置文件在指定时间的备份文件名
+func BackupFileName(configFile string, at time.Time) string {
+	return fmt.Sprintf("%s.%s.bak", configFile, at.Format(backupTimeLayout))
+}
+
+// ListConfigBackups 列出配置文件的备份，按时间从旧到新排序
+func ListConfigBackups(configFile string) ([]string, error) {
+	matches, err := filepath.Glob(configFile + ".*.bak")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list config backups: %w", err)
+	}
+
+	var backups []string
+	for _, match := range matches {
+		if _, ok := backupTime(configFile, match); ok {
+			backups = append(backups, match)
+		}
+	}
+	// 时间格式按字典序即按时间排序
+	sort.Strings(backups)
+	return backups, nil
+}
+
+// backupTime 从备份文件名解析备份时间
+func backupTime(configFile, backup string) (time.Time, bool) {
+	stamp := strings.TrimSuffix(strings.TrimPrefix(backup, configFile+"."), ".bak")
+	t, err := time.ParseInLocation(backupTimeLayout, stamp, time.Local)
+	return t, err == nil
+}
+
+// PruneConfigBackups 按保留策略删除多余或过期的备份，返回被删除的文件
+func PruneConfigBackups(configFile string, policy *ConfigBackups) ([]string, error) {
+	maxCount := DefaultBackupMaxCount
+	var maxAge time.Duration
+	if policy != nil {
+		if policy.MaxCount != 0 {
+			maxCount = policy.MaxCount
+		}
+		maxAge = time.Duration(policy.MaxAgeDays) * 24 * time.Hour
+	}
+
+	backups, err := ListConfigBackups(configFile)
+	if err != nil {
+		return nil, err
+	}
+
+	var removed []string
+	now := time.Now()
+	for i, backup := range backups {
+		// 从旧到新遍历，超出数量或超过保留时间的备份被删除
+		tooMany := maxCount > 0 && len(backups)-i > maxCount
+		tooOld := false
+		if maxAge > 0 {
+			if t, ok := backupTime(configFile, backup); ok && now.Sub(t) > maxAge {
+				tooOld = true
+			}
+		}
+		if !tooMany && !tooOld {
+			continue
+		}
+		if err := os.Remove(backup); err != nil {
+			return removed, fmt.Errorf("failed to remove config backup %s: %w", backup, err)
+		}
+		removed = append(removed, backup)
+	}
+	return removed, nil
+}
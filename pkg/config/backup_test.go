@@ -0,0 +1,39 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPruneConfigBackups(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), "config.json")
+	now := time.Now()
+	for _, age := range []time.Duration{1, 2, 3, 40 * 24} {
+		backup := BackupFileName(configFile, now.Add(-age*time.Hour))
+		require.NoError(t, os.WriteFile(backup, []byte("{}"), 0644))
+	}
+	// 不符合备份命名的文件不受影响
+	require.NoError(t, os.WriteFile(configFile+".manual.bak", []byte("{}"), 0644))
+
+	removed, err := PruneConfigBackups(configFile, &ConfigBackups{MaxCount: 2, MaxAgeDays: 30})
+	require.NoError(t, err)
+	assert.Len(t, removed, 2)
+
+	backups, err := ListConfigBackups(configFile)
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		BackupFileName(configFile, now.Add(-2*time.Hour)),
+		BackupFileName(configFile, now.Add(-1*time.Hour)),
+	}, backups)
+	assert.FileExists(t, configFile+".manual.bak")
+
+	// 负数表示不限数量
+	removed, err = PruneConfigBackups(configFile, &ConfigBackups{MaxCount: -1})
+	require.NoError(t, err)
+	assert.Empty(t, removed)
+}
@@ -9,6 +9,7 @@ import (
 	"os"
 	"strings"
 	"time"
+	"unicode"
 
 	"github.com/google/uuid"
 )
@@ -35,19 +36,174 @@ type GoogleAuthConfig struct {
 	CredentialsFile   string   `json:"credentials_file"`
 }
 
+// StartupChecks 启动自检配置
+type StartupChecks struct {
+	Token           bool   `json:"token"`                      // 检查OAuth token有效
+	Project         bool   `json:"project"`                    // 检查项目可访问
+	Generation      bool   `json:"generation"`                 // 执行一次低成本生成
+	GenerationModel string `json:"generation_model,omitempty"` // 生成检查使用的模型，默认gemini-2.5-flash
+	FailFast        bool   `json:"fail_fast"`                  // 检查失败时拒绝启动
+	TimeoutSeconds  int    `json:"timeout_seconds,omitempty"`  // 自检总超时，默认30秒
+}
+
+// LeakDetector 长时间运行（soak）时的资源泄漏检测配置
+type LeakDetector struct {
+	IntervalSeconds int `json:"interval_seconds,omitempty"` // 采样间隔，默认60秒
+	Window          int `json:"window,omitempty"`           // 连续增长多少次采样后告警，默认5
+}
+
+// Pacing 同一账号连续上游请求之间的随机间隔，平滑突发请求
+type Pacing struct {
+	MinDelayMs int `json:"min_delay_ms"`           // 最小间隔（毫秒）
+	MaxDelayMs int `json:"max_delay_ms,omitempty"` // 最大间隔（毫秒），不大于最小间隔时使用固定间隔
+}
+
+// ProxyHealthCheck 代理池健康检查配置，连续失败的代理暂时移出轮换
+type ProxyHealthCheck struct {
+	IntervalSeconds  int    `json:"interval_seconds,omitempty"`  // 主动探测间隔，默认60秒，负数表示只根据请求结果判断
+	TimeoutSeconds   int    `json:"timeout_seconds,omitempty"`   // 单次探测超时，默认10秒
+	FailureThreshold int    `json:"failure_threshold,omitempty"` // 连续失败多少次后移出轮换，默认3
+	EvictSeconds     int    `json:"evict_seconds,omitempty"`     // 移出轮换的时长，默认300秒，期间探测成功时提前恢复
+	URL              string `json:"url,omitempty"`               // 探测地址，默认 https://www.gstatic.com/generate_204
+}
+
+// 启动时代理连通性测试策略
+const (
+	ProxyStartupCheckWarn = "warn" // 有代理不可用时输出警告后继续启动
+	ProxyStartupCheckFail = "fail" // 有代理不可用时拒绝启动
+	ProxyStartupCheckOff  = "off"  // 不测试
+)
+
+// ResponseCache 非流式响应缓存配置
+type ResponseCache struct {
+	TTLSeconds int `json:"ttl_seconds"`           // 缓存有效期（秒），不大于0时不启用
+	MaxEntries int `json:"max_entries,omitempty"` // 最多缓存的响应数，默认1000
+}
+
+// RateLimit 单个API密钥的请求速率和token配额，0表示不限制
+type RateLimit struct {
+	RequestsPerMinute int `json:"requests_per_minute,omitempty"` // 每分钟请求数（滑动窗口）
+	TokensPerDay      int `json:"tokens_per_day,omitempty"`      // 每天（UTC）消耗的token总数
+}
+
+// ModelCapabilities 模型支持的功能和上下文限制，用于在请求发往上游前拒绝模型不支持的参数组合
+type ModelCapabilities struct {
+	Vision          bool `json:"vision"`                      // 图片、音视频、文件等多模态输入
+	Tools           bool `json:"tools"`                       // 函数调用和内置工具
+	JSONMode        bool `json:"json_mode"`                   // responseMimeType=application/json 和 responseSchema
+	Thinking        bool `json:"thinking"`                    // thinkingConfig
+	MaxInputTokens  int  `json:"max_input_tokens,omitempty"`  // 最大输入token数，0表示不检查
+	MaxOutputTokens int  `json:"max_output_tokens,omitempty"` // 最大输出token数
+}
+
+// AuditLog 请求审计日志配置
+type AuditLog struct {
+	Path                      string `json:"path"`                                  // 审计日志文件，按行追加JSON记录
+	HashChain                 bool   `json:"hash_chain,omitempty"`                  // 每条记录包含上一条记录的哈希，事后修改或删除记录可被发现
+	CheckpointEvery           int    `json:"checkpoint_every,omitempty"`            // 每写入多少条记录输出一次检查点
+	CheckpointIntervalSeconds int    `json:"checkpoint_interval_seconds,omitempty"` // 定期输出检查点的间隔（秒）
+	CheckpointKey             string `json:"checkpoint_key,omitempty"`              // 检查点HMAC签名密钥，为空时检查点不签名
+}
+
+// Retention 落盘数据的保留策略
+type Retention struct {
+	JobsHours            int `json:"jobs_hours,omitempty"`             // 已完成任务的保留时长（小时），默认24
+	AuditLogDays         int `json:"audit_log_days,omitempty"`         // 审计记录保留天数，0表示永久保留
+	RequestLogDays       int `json:"request_log_days,omitempty"`       // 请求记录保留天数，0表示永久保留
+	PurgeIntervalMinutes int `json:"purge_interval_minutes,omitempty"` // 定期清理的间隔（分钟），默认60
+}
+
+// ResponseFilter 生成内容屏蔽词过滤配置
+type ResponseFilter struct {
+	Blocklist     []string `json:"blocklist,omitempty"`      // 屏蔽词列表，不区分大小写
+	BlocklistFile string   `json:"blocklist_file,omitempty"` // 屏蔽词文件，每行一个，#开头的行为注释
+	Action        string   `json:"action,omitempty"`         // "mask"（默认，替换为等长的*）或 "abort"（中止响应）
+}
+
+// Pipeline 命名流水线：客户端以流水线名作为模型名调用，代理换用流水线的模型并应用其参数、系统提示词和过滤配置。
+// 顶层字段定义名为 "default" 的版本，versions 可定义更多版本，按 rollout 的百分比灰度分配流量
+type Pipeline struct {
+	PipelineVersion
+	Versions map[string]PipelineVersion `json:"versions,omitempty"` // 版本名 -> 版本配置
+	Stable   string                     `json:"stable,omitempty"`   // 接收其余流量的稳定版本，有顶层模型时默认 "default"
+	Rollout  map[string]int             `json:"rollout,omitempty"`  // 灰度版本 -> 流量百分比，合计不超过100
+}
+
+// PipelineVersion 流水线一个版本的配置
+type PipelineVersion struct {
+	Model            string          `json:"model,omitempty"`              // 实际使用的模型
+	Fallbacks        []string        `json:"fallbacks,omitempty"`          // 主模型请求失败时依次尝试的模型
+	SystemPrompt     string          `json:"system_prompt,omitempty"`      // 系统提示词，设置后不再应用 system_prompt_file
+	SystemPromptMode string          `json:"system_prompt_mode,omitempty"` // "overwrite"(默认) 或 "append"（追加到客户端的系统指令之后）
+	Temperature      *float32        `json:"temperature,omitempty"`        // 以下生成参数仅在请求未设置时使用
+	TopP             *float32        `json:"top_p,omitempty"`
+	TopK             *int            `json:"top_k,omitempty"`
+	MaxOutputTokens  *int            `json:"max_output_tokens,omitempty"`
+	ResponseFilter   *ResponseFilter `json:"response_filter,omitempty"` // 流水线专用的屏蔽词过滤，覆盖全局配置
+}
+
+// ScheduledJob 定时执行的提示词任务
+type ScheduledJob struct {
+	Name     string `json:"name"`
+	Schedule string `json:"schedule"` // cron表达式（分 时 日 月 周）、"@every 1h" 或 @hourly/@daily/@weekly/@monthly
+	Model    string `json:"model"`
+	Prompt   string `json:"prompt"`
+	Webhook  string `json:"webhook,omitempty"` // 接收生成结果的URL
+}
+
+// ConfigBackups 配置文件备份保留策略
+type ConfigBackups struct {
+	Disabled   bool `json:"disabled,omitempty"`     // 不再创建备份
+	MaxCount   int  `json:"max_count,omitempty"`    // 最多保留的备份数量，默认10，负数表示不限
+	MaxAgeDays int  `json:"max_age_days,omitempty"` // 备份最长保留天数，0表示不限
+}
+
+// RouterConfig 前置路由模式配置
+type RouterConfig struct {
+	Upstreams          []RouterUpstream `json:"upstreams"`
+	HealthCheckSeconds int              `json:"health_check_seconds,omitempty"` // 下游健康检查间隔，默认10秒
+	Secret             string           `json:"secret,omitempty"`               // 实例注册使用的共享密钥，为空时不接受注册
+}
+
+// ClusterJoin 向前置路由注册本实例的配置
+type ClusterJoin struct {
+	RouterURL       string `json:"router_url"`
+	AdvertiseURL    string `json:"advertise_url"` // 路由访问本实例使用的地址，默认 http://host:port
+	Secret          string `json:"secret"`
+	Weight          int    `json:"weight,omitempty"`
+	IntervalSeconds int    `json:"interval_seconds,omitempty"` // 心跳间隔，默认10秒
+}
+
+// RouterUpstream 下游代理实例
+type RouterUpstream struct {
+	URL    string `json:"url"`
+	Weight int    `json:"weight,omitempty"`  // 权重，默认1
+	APIKey string `json:"api_key,omitempty"` // 访问下游实例使用的API密钥
+}
+
 // Config Gemini代理服务配置 (简化后的结构)
 type Config struct {
+	// 配置文件结构版本，旧版本配置在加载时自动升级
+	ConfigVersion int `json:"config_version,omitempty"`
+
 	// 基本服务器配置
 	Host        string `json:"host"`
 	Port        int    `json:"port"`
 	ClientID    string `json:"client_id"` // 用于标识当前主机的唯一ID
 	RedirectURL string `json:"redirect_url"`
+	OAuthTunnel string `json:"oauth_tunnel,omitempty"` // OAuth授权期间临时建立公网隧道作为回调地址：cloudflared 或 ngrok
 
 	// 代理配置
 	ProxyURLs []string `json:"proxy_urls"`
+	Transport string   `json:"transport,omitempty"` // 通过 client.RegisterTransport 注册的自定义传输层名称
+	// 代理健康检查，未配置时只根据请求结果剔除连续失败的代理，不主动探测
+	ProxyHealthCheck *ProxyHealthCheck `json:"proxy_health_check,omitempty"`
+	// 启动时测试每个代理到上游端点的连通性并输出报告，取值见 ProxyStartupCheck* 常量，默认warn
+	ProxyStartupCheck string `json:"proxy_startup_check,omitempty"`
 
 	// API密钥配置
-	APIKeys []string `json:"api_keys"`
+	APIKeys      []string `json:"api_keys"`
+	AdminAPIKeys []string `json:"admin_api_keys,omitempty"` // 管理员密钥，可使用调试回显等管理功能
 
 	// Gemini API配置
 	APIMode        APIMode `json:"api_mode"`
@@ -57,18 +213,177 @@ type Config struct {
 	MaxRetries     int     `json:"max_retries"`
 	UserAgent      string  `json:"user_agent"`
 
+	// 分阶段超时（秒）：建立连接、收到响应头、流式响应总时长，0表示不单独限制
+	// 非流式请求的总时长由 timeout_seconds 限制
+	ConnectTimeoutSeconds   int `json:"connect_timeout_seconds,omitempty"`
+	FirstByteTimeoutSeconds int `json:"first_byte_timeout_seconds,omitempty"`
+	StreamTimeoutSeconds    int `json:"stream_timeout_seconds,omitempty"`
+	// 流式响应连续多少秒没有收到数据时中止，与总时长限制相互独立，0表示不限制
+	StreamIdleTimeoutSeconds int `json:"stream_idle_timeout_seconds,omitempty"`
+	// 流式响应超过该毫秒数仍未返回首个数据时换用其他账号/代理重试，0表示不重试
+	TTFTDeadlineMs int `json:"ttft_deadline_ms,omitempty"`
+
+	// 超过该大小的请求体流式发送且不重试，0使用默认值8MiB，负数表示始终缓冲
+	StreamBodyThresholdBytes int64 `json:"stream_body_threshold_bytes,omitempty"`
+	// 同时缓冲的请求体总字节上限，超出时新请求等待，0表示不限制
+	MaxBufferedBytes int64 `json:"max_buffered_bytes,omitempty"`
+
+	// 从上游获取的模型列表按API模式缓存的秒数，0使用默认值600秒
+	ModelsCacheTTLSeconds int `json:"models_cache_ttl_seconds,omitempty"`
+
+	// Vertex AI专用端点映射 (模型名 -> 端点ID或完整端点资源名)
+	VertexEndpoints map[string]string `json:"vertex_endpoints,omitempty"`
+	// Vertex AI预配置吞吐量和配额项目
+	VertexRequestType string `json:"vertex_request_type,omitempty"` // X-Vertex-AI-LLM-Request-Type: "dedicated" 或 "shared"
+	QuotaProjectID    string `json:"quota_project_id,omitempty"`    // X-Goog-User-Project 计费/配额项目
+	// 数据驻留区域映射 (X-Data-Region 请求头的区域名 -> Vertex AI location)，如 "eu" -> "europe-west4"
+	DataRegions map[string]string `json:"data_regions,omitempty"`
+
 	// OAuth2 Token Base64编码内容
 	TokenFile string `json:"token_file"`
+	// 多个账号的OAuth2 Token Base64编码内容
+	OAuthTokens []string `json:"oauth_tokens,omitempty"`
+	// 直接使用的外部凭据文件（gemini-cli的oauth_creds.json或gcloud应用默认凭据），"gemini-cli" 和 "adc" 表示默认位置
+	CredentialFiles []string `json:"credential_files,omitempty"`
+	// 每个账号每天的请求上限（如Code Assist免费层每天1000次），达到上限的账号在配额重置前暂停使用，0表示不限制
+	AccountDailyCap int `json:"account_daily_cap,omitempty"`
+	// 每日配额重置的时区，默认 America/Los_Angeles（Google配额按太平洋时间零点重置）
+	QuotaTimezone string `json:"quota_timezone,omitempty"`
+	// 同一账号连续上游请求之间的随机间隔，未配置时不限制
+	Pacing *Pacing `json:"pacing,omitempty"`
+	// 额外的封禁识别关键字，上游403响应包含其中之一时隔离对应账号（默认关键字始终生效）
+	QuarantinePatterns []string `json:"quarantine_patterns,omitempty"`
+	// 仅在内存中保存OAuth token，保存配置和备份时不写入token
+	EphemeralTokens bool `json:"ephemeral_tokens,omitempty"`
+	// 允许本地配置中的 exec: 密钥引用执行命令，默认关闭；远程配置始终只解析 env: 引用
+	AllowExecSecrets bool `json:"allow_exec_secrets,omitempty"`
+	// 配置文件备份保留策略
+	ConfigBackups *ConfigBackups `json:"config_backups,omitempty"`
+
+	// 前置路由模式配置，设置后本实例将请求分发到下游代理实例
+	Router *RouterConfig `json:"router,omitempty"`
+	// 向前置路由注册并定期上报状态
+	Cluster *ClusterJoin `json:"cluster,omitempty"`
 
 	// 日志配置
 	LogLevel string `json:"log_level"`
 
 	// 服务器配置
-	EnableCORS bool `json:"enable_cors"`
+	EnableCORS          bool `json:"enable_cors"`
+	WarmupOnStart       bool `json:"warmup_on_start,omitempty"`       // 启动时预热token、模型列表和上游连接，完成前/ready返回503
+	StreamMetadataEvent bool `json:"stream_metadata_event,omitempty"` // 流式响应结束时发送 event: metadata 事件
+	NativeReverseProxy  bool `json:"native_reverse_proxy,omitempty"`  // 原生Gemini路由使用反向代理直接转发请求体
+
+	// OpenAI流式响应的断线续传：每个流保留的最近事件数（0表示不启用）及结束后可续传的秒数（默认300秒）
+	StreamResumeBuffer     int `json:"stream_resume_buffer,omitempty"`
+	StreamResumeTTLSeconds int `json:"stream_resume_ttl_seconds,omitempty"`
+
+	// 独立的管理监听地址（如 127.0.0.1:9091），提供 /health、/ready 和 /admin 接口
+	AdminListen string `json:"admin_listen,omitempty"`
+
+	// 监视配置文件变化并热加载API密钥、代理列表、日志级别和系统提示词，其余配置需要重启后生效
+	WatchConfig bool `json:"watch_config,omitempty"`
+
+	// 优雅关闭时等待进行中请求（含流式响应）完成的最长秒数，0使用默认值30秒
+	ShutdownTimeoutSeconds int `json:"shutdown_timeout_seconds,omitempty"`
+
+	// 可信代理CIDR列表，仅信任来自这些地址的 X-Forwarded-For / X-Real-IP
+	TrustedProxies []string `json:"trusted_proxies,omitempty"`
+
+	// HMAC请求签名认证，供服务间调用方使用
+	HMACKeys                map[string]string `json:"hmac_keys,omitempty"`                  // 密钥ID -> 共享密钥
+	HMACReplayWindowSeconds int               `json:"hmac_replay_window_seconds,omitempty"` // 签名时间戳允许的偏差，默认300秒
+	HMACMaxBodyBytes        int64             `json:"hmac_max_body_bytes,omitempty"`        // 校验签名时读取的请求体上限，默认32MiB
+
+	// 启动自检配置
+	StartupChecks *StartupChecks `json:"startup_checks,omitempty"`
+
+	// 泄漏检测：定期采样goroutine数、打开的文件描述符和堆内存，持续增长时输出警告
+	LeakDetector *LeakDetector `json:"leak_detector,omitempty"`
 
 	// 系统提示词配置
 	SystemPromptFile string `json:"system_prompt_file"` // 系统提示词文件路径
 	SystemPromptMode string `json:"system_prompt_mode"` // "overwrite"(默认) 或 "append"
+
+	// 回复语言配置：在系统指令末尾追加语言指令，如 "zh-CN"、"en-US"
+	ResponseLanguage     string            `json:"response_language,omitempty"`      // 全局回复语言
+	KeyResponseLanguages map[string]string `json:"key_response_languages,omitempty"` // API密钥（HMAC为 "hmac:<密钥ID>"）-> 回复语言，优先于全局设置
+
+	// 按API密钥限流：API密钥（HMAC为 "hmac:<密钥ID>"）-> 限额，"*" 为未单独配置的密钥的默认限额
+	KeyRateLimits map[string]RateLimit `json:"key_rate_limits,omitempty"`
+
+	// 整体关闭的路由组：openai、gemini、vertex、models、async、tokenize，用于缩小暴露面或只提供一种API格式
+	DisabledRoutes []string `json:"disabled_routes,omitempty"`
+
+	// 添加到每个响应的静态头部（如HSTS、Cache-Control），头部名称 -> 值
+	ResponseHeaders map[string]string `json:"response_headers,omitempty"`
+
+	// OpenAI兼容接口的模型别名（客户端使用的模型名 -> 实际模型名或流水线名），如 "gpt-4o" -> "gemini-2.5-pro"
+	ModelMappings map[string]string `json:"model_mappings,omitempty"`
+
+	// 请求审计日志，可选哈希链和签名检查点
+	AuditLog *AuditLog `json:"audit_log,omitempty"`
+
+	// 请求记录文件，按请求保存调用方、模型、token用量、耗时和状态码，可通过 /admin/requests 和 /admin/usage 查询
+	RequestLogFile string `json:"request_log_file,omitempty"`
+
+	// 落盘数据（任务存储、审计日志、请求记录）的保留期限和定期清理
+	Retention *Retention `json:"retention,omitempty"`
+
+	// 生成内容过滤配置
+	ResponseFilter *ResponseFilter `json:"response_filter,omitempty"`
+	// 提示词被上游拦截（promptFeedback.blockReason）时返回400错误，而不是没有候选的200响应
+	PromptBlockedAsError bool `json:"prompt_blocked_as_error,omitempty"`
+
+	// 并行多模型请求配置
+	ParallelModels    []string `json:"parallel_models,omitempty"`     // 并行请求默认使用的模型列表
+	ParallelMaxModels int      `json:"parallel_max_models,omitempty"` // 单个并行请求允许的最大模型数（去重后），默认4
+
+	// Best-of-N采样配置
+	BestOfMaxN        int    `json:"best_of_max_n,omitempty"`        // 单个请求允许的最大候选数量，默认4
+	BestOfScorer      string `json:"best_of_scorer,omitempty"`       // "heuristic"(默认) 或 "judge"
+	BestOfJudgeModel  string `json:"best_of_judge_model,omitempty"`  // 评审模型，为空时使用请求的模型
+	BestOfJudgePrompt string `json:"best_of_judge_prompt,omitempty"` // 自定义评审提示词
+
+	// 结构化输出校验配置
+	ValidateStructuredOutput bool `json:"validate_structured_output,omitempty"` // 请求JSON模式时校验输出是否符合schema
+	RepairStructuredOutput   bool `json:"repair_structured_output,omitempty"`   // 校验失败时自动请求模型修复一次
+
+	// 输出续写配置
+	ContinuationMaxRounds int `json:"continuation_max_rounds,omitempty"` // 因MAX_TOKENS截断时自动续写的最大轮数，0表示禁用
+
+	// 合并并发的相同非流式请求，只向上游发送一次
+	CoalesceRequests bool `json:"coalesce_requests,omitempty"`
+	// 非流式响应缓存，TTL内的相同请求直接返回缓存结果
+	ResponseCache *ResponseCache `json:"response_cache,omitempty"`
+
+	// 命名流水线：流水线名（客户端使用的模型名）-> 模型、默认参数、系统提示词、过滤和候补模型
+	Pipelines map[string]Pipeline `json:"pipelines,omitempty"`
+
+	// 模型能力表：模型名前缀 -> 能力，覆盖或补充内置能力表，最长前缀优先
+	ModelCapabilities map[string]ModelCapabilities `json:"model_capabilities,omitempty"`
+
+	// 后台任务队列配置
+	JobStoreFile string `json:"job_store_file,omitempty"` // 任务持久化文件，为空时仅保存在内存中
+	JobWorkers   int    `json:"job_workers,omitempty"`    // 并发处理的任务数量，默认2
+
+	// 定时提示词任务
+	ScheduledJobs []ScheduledJob `json:"scheduled_jobs,omitempty"`
+
+	// 异步结果webhook配置
+	WebhookSecret     string `json:"webhook_secret,omitempty"`      // 签名密钥，设置后请求携带 X-Proxy-Webhook-Signature
+	WebhookMaxRetries int    `json:"webhook_max_retries,omitempty"` // 投递失败的最大重试次数，默认5，负数表示不重试
+	// 异步请求中调用方提交的webhook只投递到公网https地址，此处列出的主机（如内部接收服务）不受限制
+	WebhookAllowedHosts []string `json:"webhook_allowed_hosts,omitempty"`
+
+	// 配置分层：先加载include中的文件，再加载本文件，最后叠加选中的profile
+	Include  []string                   `json:"include,omitempty"`
+	Profiles map[string]json.RawMessage `json:"profiles,omitempty"`
+
+	// layered 分层加载时各字段的初始值，保存时只写回发生变化的字段
+	layered map[string]json.RawMessage
+	// secretRefs 已解析的密钥引用，保存时还原为引用
+	secretRefs map[string]secretRef
 }
 
 // GetTimeout 获取超时时间
@@ -79,6 +394,81 @@ func (c *Config) GetTimeout() time.Duration {
 	return time.Duration(c.TimeoutSeconds) * time.Second
 }
 
+// GetShutdownTimeout 获取优雅关闭时排空进行中请求的最长时间
+func (c *Config) GetShutdownTimeout() time.Duration {
+	if c.ShutdownTimeoutSeconds <= 0 {
+		return 30 * time.Second
+	}
+	return time.Duration(c.ShutdownTimeoutSeconds) * time.Second
+}
+
+// GetModelsCacheTTL 获取模型列表缓存的有效期
+func (c *Config) GetModelsCacheTTL() time.Duration {
+	if c.ModelsCacheTTLSeconds <= 0 {
+		return 10 * time.Minute
+	}
+	return time.Duration(c.ModelsCacheTTLSeconds) * time.Second
+}
+
+// GetConnectTimeout 获取建立连接（含TLS握手）的超时时间，0表示使用传输层默认值
+func (c *Config) GetConnectTimeout() time.Duration {
+	return secondsDuration(c.ConnectTimeoutSeconds)
+}
+
+// GetFirstByteTimeout 获取等待上游响应头的超时时间，0表示不单独限制
+func (c *Config) GetFirstByteTimeout() time.Duration {
+	return secondsDuration(c.FirstByteTimeoutSeconds)
+}
+
+// GetStreamTimeout 获取流式响应的总时长限制，0表示不限制
+func (c *Config) GetStreamTimeout() time.Duration {
+	return secondsDuration(c.StreamTimeoutSeconds)
+}
+
+// GetStreamIdleTimeout 获取流式响应的空闲超时时间，0表示不限制
+func (c *Config) GetStreamIdleTimeout() time.Duration {
+	return secondsDuration(c.StreamIdleTimeoutSeconds)
+}
+
+// GetTTFTDeadline 获取流式响应首token截止时间，0表示不限制
+func (c *Config) GetTTFTDeadline() time.Duration {
+	if c.TTFTDeadlineMs <= 0 {
+		return 0
+	}
+	return time.Duration(c.TTFTDeadlineMs) * time.Millisecond
+}
+
+// AccountTokens 返回所有账号的OAuth token：token_file（可用逗号或空白分隔多个）在前，
+// 随后是oauth_tokens，去除空值和重复项
+func (c *Config) AccountTokens() []string {
+	var tokens []string
+	seen := make(map[string]bool)
+	add := func(token string) {
+		if token != "" && !seen[token] {
+			seen[token] = true
+			tokens = append(tokens, token)
+		}
+	}
+
+	for _, token := range strings.FieldsFunc(c.TokenFile, func(r rune) bool {
+		return r == ',' || unicode.IsSpace(r)
+	}) {
+		add(token)
+	}
+	for _, token := range c.OAuthTokens {
+		add(strings.TrimSpace(token))
+	}
+	return tokens
+}
+
+// secondsDuration 将非正数秒视为未设置
+func secondsDuration(seconds int) time.Duration {
+	if seconds <= 0 {
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
+}
+
 // DefaultConfig 返回简化的默认配置
 func DefaultConfig() *Config {
 	return &Config{
@@ -104,34 +494,66 @@ func DefaultConfig() *Config {
 
 // LoadConfig 从配置文件加载配置
 func LoadConfig(configFile string) (*Config, error) {
-	config := DefaultConfig()
+	return LoadConfigProfile(configFile, "")
+}
 
-	// 如果配置文件存在，则加载
-	if configFile != "" && fileExists(configFile) {
-		data, err := ioutil.ReadFile(configFile)
-		if err != nil {
-			return nil, fmt.Errorf("failed to read config file: %w", err)
-		}
+// SaveConfig 保存配置到文件
+func (c *Config) SaveConfig(configFile string) error {
+	src := c
+	if c.EphemeralTokens {
+		src = c.withoutToken()
+	}
 
-		if err := json.Unmarshal(data, config); err != nil {
-			return nil, fmt.Errorf("failed to parse config file: %w", err)
-		}
+	// 密钥引用保持引用形式写回
+	out, err := src.withSecretRefs()
+	if err != nil {
+		return err
 	}
 
-	// 从环境变量覆盖
-	overrideFromEnv(config)
+	data, err := json.MarshalIndent(out, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal config: %w", err)
+	}
 
-	return config, nil
+	// 分层配置只写回变化的字段，避免把include和profile的内容展开到主文件
+	if out.layered != nil {
+		if data, err = out.layeredSaveData(configFile); err != nil {
+			return err
+		}
+		c.layered = out.layered
+	}
+
+	return ioutil.WriteFile(configFile, data, 0644)
 }
 
-// SaveConfig 保存配置到文件
-func (c *Config) SaveConfig(configFile string) error {
-	data, err := json.MarshalIndent(c, "", "  ")
+// Clone 返回配置的深拷贝，切片、map和指针字段不与原配置共享；不包含分层加载和密钥引用等保存用的内部状态
+func (c *Config) Clone() (*Config, error) {
+	data, err := json.Marshal(c)
 	if err != nil {
-		return fmt.Errorf("failed to marshal config: %w", err)
+		return nil, fmt.Errorf("failed to copy config: %w", err)
+	}
+	var clone Config
+	if err := json.Unmarshal(data, &clone); err != nil {
+		return nil, fmt.Errorf("failed to copy config: %w", err)
 	}
+	return &clone, nil
+}
 
-	return ioutil.WriteFile(configFile, data, 0644)
+// withoutToken 返回不含运行时token的副本：token_file为引用时保留引用，否则置空
+func (c *Config) withoutToken() *Config {
+	masked := *c
+	masked.TokenFile = ""
+	if ref, ok := c.secretRefs["token_file"]; ok {
+		masked.TokenFile = ref.resolved
+	}
+	return &masked
+}
+
+// FromEnv 返回应用了环境变量覆盖的默认配置，用于没有配置文件的容器部署
+func FromEnv() *Config {
+	config := DefaultConfig()
+	overrideFromEnv(config)
+	return config
 }
 
 // overrideFromEnv 从环境变量覆盖配置 (简化版本)
@@ -142,6 +564,9 @@ func overrideFromEnv(config *Config) {
 	if redirectURL := os.Getenv("GEMINI_REDIRECT_URL"); redirectURL != "" {
 		config.RedirectURL = redirectURL
 	}
+	if oauthTunnel := os.Getenv("GEMINI_OAUTH_TUNNEL"); oauthTunnel != "" {
+		config.OAuthTunnel = oauthTunnel
+	}
 	if proxyURLs := os.Getenv("GEMINI_PROXY_URLS"); proxyURLs != "" {
 		config.ProxyURLs = strings.Split(proxyURLs, ",")
 		for i, url := range config.ProxyURLs {
@@ -172,6 +597,12 @@ func overrideFromEnv(config *Config) {
 	if tokenFile := os.Getenv("GEMINI_TOKEN_FILE"); tokenFile != "" {
 		config.TokenFile = tokenFile
 	}
+	if ephemeral := os.Getenv("GEMINI_EPHEMERAL_TOKENS"); ephemeral == "true" || ephemeral == "1" {
+		config.EphemeralTokens = true
+	}
+	if allowExec := os.Getenv("GEMINI_ALLOW_EXEC_SECRETS"); allowExec == "true" || allowExec == "1" {
+		config.AllowExecSecrets = true
+	}
 }
 
 // fileExists 检查文件是否存在
@@ -214,6 +645,10 @@ func (c *Config) FillDefaults() bool {
 	changed := false
 	defaults := DefaultConfig()
 
+	if c.ConfigVersion < CurrentConfigVersion {
+		c.ConfigVersion = CurrentConfigVersion
+		changed = true
+	}
 	if c.Host == "" {
 		c.Host = defaults.Host
 		changed = true
@@ -27,6 +27,23 @@ func TestDefaultConfig(t *testing.T) {
 	assert.Equal(t, []string{}, config.APIKeys) // Empty initially
 }
 
+func TestConfig_Clone(t *testing.T) {
+	config := DefaultConfig()
+	config.ProxyURLs = []string{"http://proxy:8080"}
+	config.VertexEndpoints = map[string]string{"my-model": "123"}
+
+	clone, err := config.Clone()
+	require.NoError(t, err)
+	assert.Equal(t, config.ProxyURLs, clone.ProxyURLs)
+	assert.Equal(t, config.VertexEndpoints, clone.VertexEndpoints)
+
+	// 修改副本不影响原配置
+	clone.ProxyURLs[0] = "http://other:8080"
+	clone.VertexEndpoints["my-model"] = "456"
+	assert.Equal(t, "http://proxy:8080", config.ProxyURLs[0])
+	assert.Equal(t, "123", config.VertexEndpoints["my-model"])
+}
+
 func TestConfig_GetTimeout(t *testing.T) {
 	config := &Config{TimeoutSeconds: 60}
 	assert.Equal(t, 60*time.Second, config.GetTimeout())
@@ -254,4 +271,29 @@ func TestFileExists(t *testing.T) {
 	
 	// Test directory
 	assert.False(t, fileExists(tempDir))
-}
\ No newline at end of file
+}
+func TestConfig_SaveConfig_EphemeralTokens(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), "config.json")
+
+	cfg := DefaultConfig()
+	cfg.EphemeralTokens = true
+	cfg.TokenFile = "in-memory-token"
+	cfg.ProjectID = "project"
+	require.NoError(t, cfg.SaveConfig(configFile))
+
+	loaded, err := LoadConfig(configFile)
+	require.NoError(t, err)
+	assert.Empty(t, loaded.TokenFile)
+	assert.Equal(t, "project", loaded.ProjectID)
+	assert.True(t, loaded.EphemeralTokens)
+	assert.Equal(t, "in-memory-token", cfg.TokenFile)
+}
+
+func TestConfig_AccountTokens(t *testing.T) {
+	config := &Config{
+		TokenFile:   "tokenA, tokenB\ntokenC",
+		OAuthTokens: []string{"tokenB", " tokenD ", ""},
+	}
+	assert.Equal(t, []string{"tokenA", "tokenB", "tokenC", "tokenD"}, config.AccountTokens())
+	assert.Nil(t, (&Config{}).AccountTokens())
+}
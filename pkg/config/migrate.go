@@ -0,0 +1,148 @@
+package config
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+)
+
+// CurrentConfigVersion 当前配置文件结构版本，写入config_version字段
+const CurrentConfigVersion = 2
+
+// legacyFieldRenames 旧版本字段名到当前字段名的映射
+var legacyFieldRenames = map[string]string{
+	"token_path": "token_file",
+	"mode":       "api_mode",
+	"timeout":    "timeout_seconds",
+	"retries":    "max_retries",
+}
+
+// legacyListFields 旧版本的单值字段到当前列表字段的映射
+var legacyListFields = map[string]string{
+	"api_key":   "api_keys",
+	"proxy_url": "proxy_urls",
+}
+
+// migrateConfigData 将旧版本配置文件内容升级到当前结构，dir用于解析相对的token文件路径
+func migrateConfigData(data []byte, dir string) ([]byte, error) {
+	fields := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, fmt.Errorf("failed to parse config file: %w", err)
+	}
+
+	changed, err := migrateFields(fields, dir)
+	if err != nil || !changed {
+		return data, err
+	}
+
+	migrated, err := json.Marshal(fields)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal migrated config: %w", err)
+	}
+	return migrated, nil
+}
+
+// migrateFields 在字段映射上原地执行迁移，返回是否有字段被改写
+func migrateFields(fields map[string]json.RawMessage, dir string) (bool, error) {
+	version := 1
+	if raw, ok := fields["config_version"]; ok {
+		if err := json.Unmarshal(raw, &version); err != nil {
+			return false, fmt.Errorf("invalid config_version: %w", err)
+		}
+	}
+	if version > CurrentConfigVersion {
+		return false, fmt.Errorf("config_version %d is newer than supported version %d", version, CurrentConfigVersion)
+	}
+	if version == CurrentConfigVersion {
+		return false, nil
+	}
+
+	changed := false
+
+	// 字段改名：当前字段已存在时以当前字段为准
+	for legacy, current := range legacyFieldRenames {
+		if raw, ok := fields[legacy]; ok {
+			if _, exists := fields[current]; !exists {
+				fields[current] = raw
+			}
+			delete(fields, legacy)
+			changed = true
+		}
+	}
+
+	// 单值字段升级为列表
+	for legacy, current := range legacyListFields {
+		raw, ok := fields[legacy]
+		if !ok {
+			continue
+		}
+		if _, exists := fields[current]; !exists {
+			var value string
+			if err := json.Unmarshal(raw, &value); err != nil {
+				return false, fmt.Errorf("invalid legacy field %s: %w", legacy, err)
+			}
+			list := []string{}
+			if value != "" {
+				list = append(list, value)
+			}
+			fields[current], _ = json.Marshal(list)
+		}
+		delete(fields, legacy)
+		changed = true
+	}
+
+	// 旧版本的token_file保存的是token文件路径，升级为Base64编码内容
+	if raw, ok := fields["token_file"]; ok {
+		var tokenFile string
+		if err := json.Unmarshal(raw, &tokenFile); err != nil {
+			return false, fmt.Errorf("invalid token_file: %w", err)
+		}
+		content, err := legacyTokenContent(tokenFile, dir)
+		if err != nil {
+			return false, err
+		}
+		if content != tokenFile {
+			fields["token_file"], _ = json.Marshal(content)
+			changed = true
+		}
+	}
+
+	return changed, nil
+}
+
+// legacyTokenContent 当value是存在的token文件路径时返回文件的Base64编码内容，否则原样返回
+func legacyTokenContent(value, dir string) (string, error) {
+	if value == "" || IsSecretRef(value) || isBase64JSON(value) {
+		return value, nil
+	}
+
+	path := value
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(dir, path)
+	}
+	if !fileExists(path) {
+		return value, nil
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read legacy token file %s: %w", value, err)
+	}
+	content := strings.TrimSpace(string(data))
+	if isBase64JSON(content) {
+		return content, nil
+	}
+	if !json.Valid([]byte(content)) {
+		return "", fmt.Errorf("legacy token file %s does not contain a JSON token", value)
+	}
+	return base64.StdEncoding.EncodeToString([]byte(content)), nil
+}
+
+// isBase64JSON 判断字符串是否为Base64编码的JSON
+func isBase64JSON(value string) bool {
+	decoded, err := base64.StdEncoding.DecodeString(value)
+	return err == nil && json.Valid(decoded)
+}
@@ -0,0 +1,91 @@
+package config
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadConfig_MigratesLegacyFields(t *testing.T) {
+	dir := t.TempDir()
+	token := `{"access_token":"abc","refresh_token":"def"}`
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "token.json"), []byte(token), 0600))
+
+	configFile := filepath.Join(dir, "config.json")
+	require.NoError(t, os.WriteFile(configFile, []byte(`{
+		"host": "legacyhost",
+		"mode": "ai_studio",
+		"timeout": 60,
+		"api_key": "legacy-key",
+		"proxy_url": "http://proxy1",
+		"token_path": "token.json"
+	}`), 0644))
+
+	cfg, err := LoadConfig(configFile)
+	require.NoError(t, err)
+	assert.Equal(t, "legacyhost", cfg.Host)
+	assert.Equal(t, AIStudio, cfg.APIMode)
+	assert.Equal(t, 60, cfg.TimeoutSeconds)
+	assert.Equal(t, []string{"legacy-key"}, cfg.APIKeys)
+	assert.Equal(t, []string{"http://proxy1"}, cfg.ProxyURLs)
+	assert.Equal(t, base64.StdEncoding.EncodeToString([]byte(token)), cfg.TokenFile)
+
+	// 补全默认值时写入当前版本，保存后不再包含旧字段
+	assert.Equal(t, 0, cfg.ConfigVersion)
+	assert.True(t, cfg.FillDefaults())
+	assert.Equal(t, CurrentConfigVersion, cfg.ConfigVersion)
+	require.NoError(t, cfg.SaveConfig(configFile))
+
+	data, err := os.ReadFile(configFile)
+	require.NoError(t, err)
+	var saved map[string]any
+	require.NoError(t, json.Unmarshal(data, &saved))
+	assert.EqualValues(t, CurrentConfigVersion, saved["config_version"])
+	assert.NotContains(t, saved, "api_key")
+	assert.NotContains(t, saved, "token_path")
+}
+
+func TestMigrateFields(t *testing.T) {
+	t.Run("current fields take precedence", func(t *testing.T) {
+		fields := map[string]json.RawMessage{
+			"api_key":  json.RawMessage(`"old"`),
+			"api_keys": json.RawMessage(`["new"]`),
+		}
+		changed, err := migrateFields(fields, "")
+		require.NoError(t, err)
+		assert.True(t, changed)
+		assert.Equal(t, `["new"]`, string(fields["api_keys"]))
+		assert.NotContains(t, fields, "api_key")
+	})
+
+	t.Run("base64 token and secret refs are kept", func(t *testing.T) {
+		encoded := base64.StdEncoding.EncodeToString([]byte(`{"access_token":"abc"}`))
+		for _, value := range []string{encoded, "env:GEMINI_TOKEN", "missing-file.json"} {
+			raw, _ := json.Marshal(value)
+			fields := map[string]json.RawMessage{"token_file": raw}
+			changed, err := migrateFields(fields, t.TempDir())
+			require.NoError(t, err)
+			assert.False(t, changed, value)
+		}
+	})
+
+	t.Run("current version is untouched", func(t *testing.T) {
+		fields := map[string]json.RawMessage{
+			"config_version": json.RawMessage(`2`),
+			"api_key":        json.RawMessage(`"old"`),
+		}
+		changed, err := migrateFields(fields, "")
+		require.NoError(t, err)
+		assert.False(t, changed)
+	})
+
+	t.Run("newer version is rejected", func(t *testing.T) {
+		_, err := migrateFields(map[string]json.RawMessage{"config_version": json.RawMessage(`99`)}, "")
+		assert.Error(t, err)
+	})
+}
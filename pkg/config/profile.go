@@ -0,0 +1,157 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+)
+
+// maxIncludeDepth include嵌套的最大层数
+const maxIncludeDepth = 8
+
+// LoadConfigProfile 加载配置文件及其include的基础配置，并叠加指定的profile
+// 叠加规则与JSON解码一致：后加载的字段覆盖先加载的，数组整体替换，profiles等映射按键合并
+func LoadConfigProfile(configFile, profile string) (*Config, error) {
+	config := DefaultConfig()
+
+	// 如果配置文件存在，则加载
+	if configFile != "" && fileExists(configFile) {
+		includes, err := loadConfigLayer(config, configFile, nil)
+		if err != nil {
+			return nil, err
+		}
+		config.Include = includes
+
+		if profile != "" {
+			overlay, ok := config.Profiles[profile]
+			if !ok {
+				return nil, fmt.Errorf("profile %q not found in config", profile)
+			}
+			if err := json.Unmarshal(overlay, config); err != nil {
+				return nil, fmt.Errorf("failed to parse profile %q: %w", profile, err)
+			}
+		}
+	} else if profile != "" {
+		return nil, fmt.Errorf("profile %q requires a config file", profile)
+	}
+
+	// 从环境变量覆盖
+	overrideFromEnv(config)
+
+	// 初始值在解析密钥引用之前记录，保存时引用不会被视为变化
+	if len(config.Include) > 0 || profile != "" {
+		layered, err := config.fieldMap()
+		if err != nil {
+			return nil, err
+		}
+		config.layered = layered
+	}
+
+	if err := config.resolveSecrets(false); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// loadConfigLayer 先递归加载include的文件再解码本文件，返回本文件声明的include列表
+func loadConfigLayer(config *Config, path string, stack []string) ([]string, error) {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve config path %s: %w", path, err)
+	}
+	for _, parent := range stack {
+		if parent == absPath {
+			return nil, fmt.Errorf("config include cycle detected at %s", path)
+		}
+	}
+	if len(stack) >= maxIncludeDepth {
+		return nil, fmt.Errorf("config include depth exceeds %d", maxIncludeDepth)
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	// 旧版本字段在解码前升级到当前结构
+	if data, err = migrateConfigData(data, filepath.Dir(path)); err != nil {
+		return nil, err
+	}
+
+	var header struct {
+		Include []string `json:"include"`
+	}
+	if err := json.Unmarshal(data, &header); err != nil {
+		return nil, fmt.Errorf("failed to parse config file: %w", err)
+	}
+
+	for _, include := range header.Include {
+		if !filepath.IsAbs(include) {
+			include = filepath.Join(filepath.Dir(path), include)
+		}
+		if _, err := loadConfigLayer(config, include, append(stack, absPath)); err != nil {
+			return nil, fmt.Errorf("failed to load include %s: %w", include, err)
+		}
+	}
+
+	if err := json.Unmarshal(data, config); err != nil {
+		return nil, fmt.Errorf("failed to parse config file: %w", err)
+	}
+	return header.Include, nil
+}
+
+// fieldMap 将配置编码为字段名到JSON值的映射
+func (c *Config) fieldMap() (map[string]json.RawMessage, error) {
+	data, err := json.Marshal(c)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal config: %w", err)
+	}
+	fields := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, fmt.Errorf("failed to marshal config: %w", err)
+	}
+	return fields, nil
+}
+
+// layeredSaveData 在主文件原有内容上只更新加载后发生变化的字段
+func (c *Config) layeredSaveData(configFile string) ([]byte, error) {
+	current, err := c.fieldMap()
+	if err != nil {
+		return nil, err
+	}
+
+	existing := make(map[string]json.RawMessage)
+	if fileExists(configFile) {
+		data, err := ioutil.ReadFile(configFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read config file: %w", err)
+		}
+		if err := json.Unmarshal(data, &existing); err != nil {
+			return nil, fmt.Errorf("failed to parse config file: %w", err)
+		}
+		// 主文件中的旧字段名一并改写，避免升级后被忽略
+		if _, err := migrateFields(existing, filepath.Dir(configFile)); err != nil {
+			return nil, err
+		}
+	}
+
+	for key, value := range current {
+		if original, ok := c.layered[key]; !ok || !bytes.Equal(original, value) {
+			existing[key] = value
+		}
+	}
+	for key := range c.layered {
+		if _, ok := current[key]; !ok {
+			delete(existing, key)
+		}
+	}
+
+	data, err := json.MarshalIndent(existing, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal config: %w", err)
+	}
+	c.layered = current
+	return data, nil
+}
@@ -0,0 +1,66 @@
+package config
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeConfigFile(t *testing.T, path, content string) {
+	t.Helper()
+	require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+}
+
+func TestLoadConfigProfile(t *testing.T) {
+	dir := t.TempDir()
+	writeConfigFile(t, filepath.Join(dir, "base.json"), `{"port": 9000, "location": "europe-west4", "api_keys": ["base-key"]}`)
+	configFile := filepath.Join(dir, "config.json")
+	writeConfigFile(t, configFile, `{
+		"include": ["base.json"],
+		"host": "0.0.0.0",
+		"profiles": {
+			"prod": {"port": 443, "api_keys": ["prod-key"]}
+		}
+	}`)
+
+	cfg, err := LoadConfigProfile(configFile, "")
+	require.NoError(t, err)
+	assert.Equal(t, 9000, cfg.Port)
+	assert.Equal(t, "0.0.0.0", cfg.Host)
+	assert.Equal(t, "europe-west4", cfg.Location)
+
+	cfg, err = LoadConfigProfile(configFile, "prod")
+	require.NoError(t, err)
+	assert.Equal(t, 443, cfg.Port)
+	assert.Equal(t, []string{"prod-key"}, cfg.APIKeys)
+	assert.Equal(t, "europe-west4", cfg.Location)
+
+	_, err = LoadConfigProfile(configFile, "missing")
+	assert.Error(t, err)
+
+	// 保存时只写回变化的字段，include和profile的内容不会展开到主文件
+	cfg.TokenFile = "new-token"
+	require.NoError(t, cfg.SaveConfig(configFile))
+
+	data, err := os.ReadFile(configFile)
+	require.NoError(t, err)
+	var saved map[string]json.RawMessage
+	require.NoError(t, json.Unmarshal(data, &saved))
+	assert.JSONEq(t, `"new-token"`, string(saved["token_file"]))
+	assert.JSONEq(t, `["base.json"]`, string(saved["include"]))
+	assert.NotContains(t, saved, "port")
+	assert.NotContains(t, saved, "location")
+}
+
+func TestLoadConfigProfile_IncludeCycle(t *testing.T) {
+	dir := t.TempDir()
+	writeConfigFile(t, filepath.Join(dir, "a.json"), `{"include": ["b.json"]}`)
+	writeConfigFile(t, filepath.Join(dir, "b.json"), `{"include": ["a.json"]}`)
+
+	_, err := LoadConfigProfile(filepath.Join(dir, "a.json"), "")
+	assert.ErrorContains(t, err, "cycle")
+}
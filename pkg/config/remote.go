@@ -0,0 +1,173 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultRemoteRefreshInterval 远程配置默认刷新间隔
+const DefaultRemoteRefreshInterval = 5 * time.Minute
+
+// maxRemoteConfigSize 远程配置最大字节数
+const maxRemoteConfigSize = 4 << 20
+
+// RemoteSource 通过HTTPS获取的远程配置源，使用ETag避免重复下载
+type RemoteSource struct {
+	URL           string
+	DecryptionKey string       // 配置加密密钥，为空时表示明文配置
+	Client        *http.Client // 为空时使用默认客户端
+
+	mu   sync.Mutex
+	etag string
+}
+
+// IsRemoteConfig 检查配置路径是否为远程URL
+func IsRemoteConfig(path string) bool {
+	return strings.HasPrefix(path, "https://") || strings.HasPrefix(path, "http://")
+}
+
+// NewRemoteSource 创建远程配置源，只允许HTTPS地址
+func NewRemoteSource(rawURL, decryptionKey string) (*RemoteSource, error) {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid remote config URL: %w", err)
+	}
+	if parsed.Scheme != "https" {
+		return nil, fmt.Errorf("remote config URL must use https")
+	}
+	return &RemoteSource{
+		URL:           rawURL,
+		DecryptionKey: decryptionKey,
+		Client:        &http.Client{Timeout: 30 * time.Second},
+	}, nil
+}
+
+// LoadRemoteConfig 从HTTPS地址加载配置，返回配置和可用于后续刷新的配置源
+func LoadRemoteConfig(ctx context.Context, rawURL, decryptionKey string) (*Config, *RemoteSource, error) {
+	source, err := NewRemoteSource(rawURL, decryptionKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	config, _, err := source.Fetch(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	return config, source, nil
+}
+
+// Fetch 获取远程配置，服务端返回304时changed为false且配置为nil
+func (s *RemoteSource) Fetch(ctx context.Context) (config *Config, changed bool, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.URL, nil)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to create remote config request: %w", err)
+	}
+
+	s.mu.Lock()
+	etag := s.etag
+	s.mu.Unlock()
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+
+	httpClient := s.Client
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to fetch remote config: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified {
+		return nil, false, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, false, fmt.Errorf("failed to fetch remote config: status %d", resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxRemoteConfigSize+1))
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to read remote config: %w", err)
+	}
+	if len(data) > maxRemoteConfigSize {
+		return nil, false, fmt.Errorf("remote config exceeds %d bytes", maxRemoteConfigSize)
+	}
+
+	if s.DecryptionKey != "" {
+		if data, err = DecryptConfig(data, s.DecryptionKey); err != nil {
+			return nil, false, err
+		}
+	}
+
+	config = DefaultConfig()
+	if err := json.Unmarshal(data, config); err != nil {
+		return nil, false, fmt.Errorf("failed to parse remote config: %w", err)
+	}
+	overrideFromEnv(config)
+	if err := config.resolveSecrets(true); err != nil {
+		return nil, false, err
+	}
+
+	s.mu.Lock()
+	s.etag = resp.Header.Get("ETag")
+	s.mu.Unlock()
+	return config, true, nil
+}
+
+// configCipher 由密钥派生AES-256-GCM加密器
+func configCipher(key string) (cipher.AEAD, error) {
+	derived := sha256.Sum256([]byte(key))
+	block, err := aes.NewCipher(derived[:])
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+// EncryptConfig 使用AES-256-GCM加密配置，输出base64(nonce+密文)，用于发布远程配置
+func EncryptConfig(data []byte, key string) (string, error) {
+	gcm, err := configCipher(key)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	return base64.StdEncoding.EncodeToString(gcm.Seal(nonce, nonce, data, nil)), nil
+}
+
+// DecryptConfig 解密EncryptConfig生成的配置
+func DecryptConfig(data []byte, key string) ([]byte, error) {
+	sealed, err := base64.StdEncoding.DecodeString(string(bytes.TrimSpace(data)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode encrypted config: %w", err)
+	}
+	gcm, err := configCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return nil, fmt.Errorf("encrypted config is too short")
+	}
+	nonce, ciphertext := sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():]
+	plaintext, err := gcm.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt config: %w", err)
+	}
+	return plaintext, nil
+}
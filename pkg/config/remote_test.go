@@ -0,0 +1,79 @@
+package config
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRemoteSource_FetchWithETag(t *testing.T) {
+	encrypted, err := EncryptConfig([]byte(`{"port": 9090, "api_keys": ["remote-key"]}`), "passphrase")
+	require.NoError(t, err)
+
+	requests := 0
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Write([]byte(encrypted))
+	}))
+	defer server.Close()
+
+	source, err := NewRemoteSource(server.URL, "passphrase")
+	require.NoError(t, err)
+	source.Client = server.Client()
+
+	cfg, changed, err := source.Fetch(context.Background())
+	require.NoError(t, err)
+	assert.True(t, changed)
+	assert.Equal(t, 9090, cfg.Port)
+	assert.Equal(t, []string{"remote-key"}, cfg.APIKeys)
+	assert.Equal(t, "us-central1", cfg.Location)
+
+	cfg, changed, err = source.Fetch(context.Background())
+	require.NoError(t, err)
+	assert.False(t, changed)
+	assert.Nil(t, cfg)
+	assert.Equal(t, 2, requests)
+}
+
+func TestRemoteSource_Errors(t *testing.T) {
+	_, err := NewRemoteSource("http://example.com/config.json", "")
+	assert.Error(t, err)
+
+	encrypted, err := EncryptConfig([]byte(`{}`), "right")
+	require.NoError(t, err)
+	_, err = DecryptConfig([]byte(encrypted), "wrong")
+	assert.Error(t, err)
+}
+
+func TestRemoteSource_SecretRefs(t *testing.T) {
+	t.Setenv("GEMINI_TEST_REMOTE_KEY", "from-env")
+	body := `{"api_keys": ["env:GEMINI_TEST_REMOTE_KEY"]}`
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(body))
+	}))
+	defer server.Close()
+
+	source, err := NewRemoteSource(server.URL, "")
+	require.NoError(t, err)
+	source.Client = server.Client()
+
+	cfg, _, err := source.Fetch(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []string{"from-env"}, cfg.APIKeys)
+
+	// 远程配置不能执行命令或读取本地文件，即使声明了 allow_exec_secrets
+	for _, ref := range []string{"exec:echo pwned", "file:/etc/passwd"} {
+		body = `{"allow_exec_secrets": true, "api_keys": ["` + ref + `"]}`
+		_, _, err = source.Fetch(context.Background())
+		assert.ErrorContains(t, err, "only allows env:", ref)
+	}
+}
@@ -0,0 +1,198 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"time"
+)
+
+// secretExecTimeout exec:引用执行命令的超时时间
+const secretExecTimeout = 10 * time.Second
+
+// IsSecretRef 检查值是否为 env:、file: 或 exec: 形式的密钥引用
+func IsSecretRef(value string) bool {
+	return strings.HasPrefix(value, "env:") || strings.HasPrefix(value, "file:") || strings.HasPrefix(value, "exec:")
+}
+
+// ResolveSecret 解析密钥引用：env:NAME 读取环境变量，file:/path 读取文件，exec:command 执行命令取标准输出
+// 非引用值原样返回，结果会去掉首尾空白
+func ResolveSecret(value string) (string, error) {
+	switch {
+	case strings.HasPrefix(value, "env:"):
+		name := strings.TrimPrefix(value, "env:")
+		secret, ok := os.LookupEnv(name)
+		if !ok {
+			return "", fmt.Errorf("environment variable %s is not set", name)
+		}
+		return strings.TrimSpace(secret), nil
+	case strings.HasPrefix(value, "file:"):
+		data, err := ioutil.ReadFile(strings.TrimPrefix(value, "file:"))
+		if err != nil {
+			return "", fmt.Errorf("failed to read secret file: %w", err)
+		}
+		return strings.TrimSpace(string(data)), nil
+	case strings.HasPrefix(value, "exec:"):
+		ctx, cancel := context.WithTimeout(context.Background(), secretExecTimeout)
+		defer cancel()
+		output, err := exec.CommandContext(ctx, "sh", "-c", strings.TrimPrefix(value, "exec:")).Output()
+		if err != nil {
+			return "", fmt.Errorf("failed to run secret command: %w", err)
+		}
+		return strings.TrimSpace(string(output)), nil
+	}
+	return value, nil
+}
+
+// eachSecret 遍历所有可使用密钥引用的字段
+func (c *Config) eachSecret(fn func(name string, value *string) error) error {
+	if err := fn("token_file", &c.TokenFile); err != nil {
+		return err
+	}
+	if err := fn("webhook_secret", &c.WebhookSecret); err != nil {
+		return err
+	}
+	for i := range c.APIKeys {
+		if err := fn(fmt.Sprintf("api_keys[%d]", i), &c.APIKeys[i]); err != nil {
+			return err
+		}
+	}
+	for i := range c.AdminAPIKeys {
+		if err := fn(fmt.Sprintf("admin_api_keys[%d]", i), &c.AdminAPIKeys[i]); err != nil {
+			return err
+		}
+	}
+
+	if c.Router != nil {
+		if err := fn("router.secret", &c.Router.Secret); err != nil {
+			return err
+		}
+	}
+	if c.Cluster != nil {
+		if err := fn("cluster.secret", &c.Cluster.Secret); err != nil {
+			return err
+		}
+	}
+	if c.AuditLog != nil {
+		if err := fn("audit_log.checkpoint_key", &c.AuditLog.CheckpointKey); err != nil {
+			return err
+		}
+	}
+
+	keyIDs := make([]string, 0, len(c.HMACKeys))
+	for keyID := range c.HMACKeys {
+		keyIDs = append(keyIDs, keyID)
+	}
+	sort.Strings(keyIDs)
+	for _, keyID := range keyIDs {
+		value := c.HMACKeys[keyID]
+		if err := fn("hmac_keys."+keyID, &value); err != nil {
+			return err
+		}
+		c.HMACKeys[keyID] = value
+	}
+	return nil
+}
+
+// resolveSecrets 解析配置中的密钥引用，并记录引用以便保存时还原。
+// 远程配置只解析 env: 引用，拒绝 file: 和 exec:；本地配置的 exec: 引用需要开启 allow_exec_secrets
+func (c *Config) resolveSecrets(remote bool) error {
+	return c.eachSecret(func(name string, value *string) error {
+		if !IsSecretRef(*value) {
+			return nil
+		}
+		if remote && !strings.HasPrefix(*value, "env:") {
+			return fmt.Errorf("failed to resolve %s: remote config only allows env: secret references", name)
+		}
+		if strings.HasPrefix(*value, "exec:") && !c.AllowExecSecrets {
+			return fmt.Errorf("failed to resolve %s: exec: secret references require allow_exec_secrets", name)
+		}
+		resolved, err := ResolveSecret(*value)
+		if err != nil {
+			return fmt.Errorf("failed to resolve %s: %w", name, err)
+		}
+		if c.secretRefs == nil {
+			c.secretRefs = make(map[string]secretRef)
+		}
+		c.secretRefs[name] = secretRef{ref: *value, resolved: resolved}
+		*value = resolved
+		return nil
+	})
+}
+
+// secretRef 一个已解析的密钥引用
+type secretRef struct {
+	ref      string
+	resolved string
+}
+
+// withSecretRefs 返回把密钥还原为引用的副本，保存配置时避免写入明文
+// 值在运行时发生变化（如刷新后的token）时，file:引用会写回对应文件，env:和exec:引用保持不变
+func (c *Config) withSecretRefs() (*Config, error) {
+	if len(c.secretRefs) == 0 {
+		return c, nil
+	}
+
+	clone := *c
+	clone.APIKeys = append([]string(nil), c.APIKeys...)
+	clone.AdminAPIKeys = append([]string(nil), c.AdminAPIKeys...)
+	if c.Router != nil {
+		router := *c.Router
+		clone.Router = &router
+	}
+	if c.Cluster != nil {
+		join := *c.Cluster
+		clone.Cluster = &join
+	}
+	if c.AuditLog != nil {
+		auditLog := *c.AuditLog
+		clone.AuditLog = &auditLog
+	}
+	if c.HMACKeys != nil {
+		clone.HMACKeys = make(map[string]string, len(c.HMACKeys))
+		for keyID, secret := range c.HMACKeys {
+			clone.HMACKeys[keyID] = secret
+		}
+	}
+
+	err := clone.eachSecret(func(name string, value *string) error {
+		ref, ok := c.secretRefs[name]
+		if !ok {
+			return nil
+		}
+		if *value != ref.resolved && strings.HasPrefix(ref.ref, "file:") {
+			if err := ioutil.WriteFile(strings.TrimPrefix(ref.ref, "file:"), []byte(*value), 0600); err != nil {
+				return fmt.Errorf("failed to update secret file for %s: %w", name, err)
+			}
+			ref.resolved = *value
+			c.secretRefs[name] = ref
+		}
+		*value = ref.ref
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &clone, nil
+}
+
+// ResolveSecrets 解析Google认证配置中client_secret和凭据字段的密钥引用
+func (a *GoogleAuthConfig) ResolveSecrets() error {
+	fields := map[string]*string{
+		"client_secret":      &a.ClientSecret,
+		"credentials_json":   &a.CredentialsJSON,
+		"credentials_base64": &a.CredentialsBase64,
+	}
+	for name, value := range fields {
+		resolved, err := ResolveSecret(*value)
+		if err != nil {
+			return fmt.Errorf("failed to resolve %s: %w", name, err)
+		}
+		*value = resolved
+	}
+	return nil
+}
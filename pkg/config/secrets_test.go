@@ -0,0 +1,73 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolveSecret(t *testing.T) {
+	t.Setenv("GEMINI_TEST_SECRET", "from-env")
+	secretFile := filepath.Join(t.TempDir(), "secret")
+	require.NoError(t, os.WriteFile(secretFile, []byte("from-file\n"), 0600))
+
+	tests := map[string]string{
+		"plain":                  "plain",
+		"env:GEMINI_TEST_SECRET": "from-env",
+		"file:" + secretFile:     "from-file",
+		"exec:echo from-exec":    "from-exec",
+	}
+	for ref, expected := range tests {
+		value, err := ResolveSecret(ref)
+		require.NoError(t, err, ref)
+		assert.Equal(t, expected, value)
+	}
+
+	_, err := ResolveSecret("env:GEMINI_TEST_SECRET_MISSING")
+	assert.Error(t, err)
+}
+
+func TestLoadConfig_SecretRefs(t *testing.T) {
+	t.Setenv("GEMINI_TEST_API_KEY", "resolved-key")
+	dir := t.TempDir()
+	tokenFile := filepath.Join(dir, "token")
+	require.NoError(t, os.WriteFile(tokenFile, []byte("old-token"), 0600))
+
+	configFile := filepath.Join(dir, "config.json")
+	writeConfigFile(t, configFile, `{"api_keys": ["env:GEMINI_TEST_API_KEY", "plain-key"], "token_file": "file:`+tokenFile+`"}`)
+
+	cfg, err := LoadConfig(configFile)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"resolved-key", "plain-key"}, cfg.APIKeys)
+	assert.Equal(t, "old-token", cfg.TokenFile)
+
+	// 保存时写回引用，更新后的token写入引用的文件
+	cfg.TokenFile = "new-token"
+	require.NoError(t, cfg.SaveConfig(configFile))
+
+	saved, err := LoadConfig(configFile)
+	require.NoError(t, err)
+	assert.Equal(t, "new-token", saved.TokenFile)
+
+	data, err := os.ReadFile(configFile)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "env:GEMINI_TEST_API_KEY")
+	assert.NotContains(t, string(data), "resolved-key")
+	assert.NotContains(t, string(data), "new-token")
+}
+
+func TestLoadConfig_ExecSecretRequiresOptIn(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), "config.json")
+	writeConfigFile(t, configFile, `{"api_keys": ["exec:echo from-exec"]}`)
+
+	_, err := LoadConfig(configFile)
+	assert.ErrorContains(t, err, "allow_exec_secrets")
+
+	writeConfigFile(t, configFile, `{"allow_exec_secrets": true, "api_keys": ["exec:echo from-exec"]}`)
+	cfg, err := LoadConfig(configFile)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"from-exec"}, cfg.APIKeys)
+}
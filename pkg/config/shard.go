@@ -0,0 +1,109 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// ShardOptions 多实例拆分选项
+type ShardOptions struct {
+	Instances int // 实例数量，默认等于账号数量与代理数量中的较大值
+	BasePort  int // 第一个实例的端口，默认为路由端口+1
+}
+
+// Shard 将包含多个账号/代理的配置拆分为多个单实例配置和一个前置路由配置
+// 每个实例分配一个账号和一部分代理，并生成仅供路由访问的内部API密钥
+func Shard(config *Config, opts ShardOptions) ([]*Config, *Config, error) {
+	// 生成的配置保留密钥引用，不写入明文
+	base, err := config.withSecretRefs()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	accounts := base.AccountTokens()
+
+	instances := opts.Instances
+	if instances <= 0 {
+		instances = len(accounts)
+		if len(base.ProxyURLs) > instances {
+			instances = len(base.ProxyURLs)
+		}
+	}
+	if instances <= 0 {
+		return nil, nil, fmt.Errorf("config has no accounts or proxies to shard")
+	}
+
+	basePort := opts.BasePort
+	if basePort <= 0 {
+		basePort = base.Port + 1
+	}
+
+	router, err := cloneConfig(base)
+	if err != nil {
+		return nil, nil, err
+	}
+	router.TokenFile = ""
+	router.OAuthTokens = nil
+	router.CredentialFiles = nil
+	router.ProxyURLs = []string{}
+	router.Router = &RouterConfig{}
+
+	var shards []*Config
+	for i := 0; i < instances; i++ {
+		shard, err := cloneConfig(base)
+		if err != nil {
+			return nil, nil, err
+		}
+		shard.Port = basePort + i
+		shard.ClientID = ""
+		shard.RedirectURL = fmt.Sprintf("http://%s:%d", shard.Host, shard.Port)
+		shard.Router = nil
+		shard.OAuthTokens = nil
+		shard.TokenFile = ""
+		if len(accounts) > 0 {
+			shard.TokenFile = accounts[i%len(accounts)]
+		}
+		shard.ProxyURLs = shardProxies(base.ProxyURLs, i, instances)
+
+		// 实例只接受路由转发的请求
+		internalKey := GenerateRandomAPIKey()
+		shard.APIKeys = []string{internalKey}
+		shard.AdminAPIKeys = nil
+
+		router.Router.Upstreams = append(router.Router.Upstreams, RouterUpstream{
+			URL:    fmt.Sprintf("http://%s:%d", shard.Host, shard.Port),
+			Weight: 1,
+			APIKey: internalKey,
+		})
+		shards = append(shards, shard)
+	}
+	return shards, router, nil
+}
+
+// shardProxies 为第index个实例分配代理：代理多于实例时按模分组，否则循环复用
+func shardProxies(proxies []string, index, instances int) []string {
+	if len(proxies) == 0 {
+		return []string{}
+	}
+	if len(proxies) < instances {
+		return []string{proxies[index%len(proxies)]}
+	}
+	var assigned []string
+	for j := index; j < len(proxies); j += instances {
+		assigned = append(assigned, proxies[j])
+	}
+	return assigned
+}
+
+// cloneConfig 深拷贝配置
+func cloneConfig(c *Config) (*Config, error) {
+	data, err := json.Marshal(c)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal config: %w", err)
+	}
+	clone := &Config{}
+	if err := json.Unmarshal(data, clone); err != nil {
+		return nil, fmt.Errorf("failed to copy config: %w", err)
+	}
+	return clone, nil
+}
@@ -0,0 +1,44 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShard(t *testing.T) {
+	base := DefaultConfig()
+	base.Port = 8000
+	base.APIKeys = []string{"client-key"}
+	base.OAuthTokens = []string{"token-a", "token-b"}
+	base.ProxyURLs = []string{"http://p1", "http://p2", "http://p3", "http://p4"}
+
+	shards, router, err := Shard(base, ShardOptions{})
+	require.NoError(t, err)
+	require.Len(t, shards, 4)
+
+	assert.Equal(t, 8001, shards[0].Port)
+	assert.Equal(t, "token-a", shards[0].TokenFile)
+	assert.Equal(t, "token-b", shards[1].TokenFile)
+	assert.Equal(t, []string{"http://p1"}, shards[0].ProxyURLs)
+	assert.Nil(t, shards[0].OAuthTokens)
+
+	assert.Equal(t, 8000, router.Port)
+	assert.Equal(t, []string{"client-key"}, router.APIKeys)
+	assert.Empty(t, router.TokenFile)
+	require.Len(t, router.Router.Upstreams, 4)
+	assert.Equal(t, "http://localhost:8002", router.Router.Upstreams[1].URL)
+	assert.Equal(t, shards[1].APIKeys[0], router.Router.Upstreams[1].APIKey)
+
+	// 原配置不受影响
+	assert.Equal(t, []string{"client-key"}, base.APIKeys)
+
+	shards, _, err = Shard(base, ShardOptions{Instances: 2, BasePort: 9000})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"http://p2", "http://p4"}, shards[1].ProxyURLs)
+	assert.Equal(t, 9001, shards[1].Port)
+
+	_, _, err = Shard(DefaultConfig(), ShardOptions{})
+	assert.Error(t, err)
+}
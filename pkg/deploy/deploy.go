@@ -0,0 +1,133 @@
+// Package deploy 生成常见托管平台（Docker、fly.io、Railway、Cloud Run）的部署文件
+package deploy
+
+import (
+	"bytes"
+	"embed"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+//go:embed templates/*.tmpl
+var templateFS embed.FS
+
+var templates = template.Must(template.ParseFS(templateFS, "templates/*.tmpl"))
+
+// 支持的部署目标
+const (
+	TargetDocker   = "docker"
+	TargetFly      = "fly"
+	TargetRailway  = "railway"
+	TargetCloudRun = "cloudrun"
+)
+
+// ErrUnknownTarget 未知的部署目标
+var ErrUnknownTarget = errors.New("unknown deploy target")
+
+// containerFiles 所有目标共用的容器镜像文件：输出文件名 -> 模板名
+var containerFiles = map[string]string{
+	"main.go":       "main.go.tmpl",
+	"Dockerfile":    "Dockerfile.tmpl",
+	".dockerignore": "dockerignore.tmpl",
+}
+
+// targets 各部署目标在容器镜像文件之外生成的平台配置，以及默认区域
+var targets = map[string]struct {
+	files  map[string]string
+	region string
+}{
+	TargetDocker:   {},
+	TargetFly:      {files: map[string]string{"fly.toml": "fly.toml.tmpl"}, region: "iad"},
+	TargetRailway:  {files: map[string]string{"railway.json": "railway.json.tmpl"}},
+	TargetCloudRun: {files: map[string]string{"service.yaml": "service.yaml.tmpl"}, region: "us-central1"},
+}
+
+// Options 部署文件生成选项
+type Options struct {
+	App     string // 应用/服务名称，默认 gemini-proxy
+	Port    int    // 容器监听端口（通过PORT环境变量传入），默认8080
+	Version string // 构建时使用的gemini-go-proxy版本，默认latest
+	Region  string // fly.io/Cloud Run区域，默认使用各平台的常用区域
+	Image   string // Cloud Run镜像地址，默认 gcr.io/PROJECT_ID/<App>
+}
+
+// Targets 返回支持的部署目标
+func Targets() []string {
+	names := make([]string, 0, len(targets))
+	for name := range targets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Generate 生成指定目标的部署文件，返回文件名到内容的映射
+func Generate(target string, opts Options) (map[string][]byte, error) {
+	spec, ok := targets[strings.ToLower(target)]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q (supported: %s)", ErrUnknownTarget, target, strings.Join(Targets(), ", "))
+	}
+
+	if opts.App == "" {
+		opts.App = "gemini-proxy"
+	}
+	if opts.Port <= 0 {
+		opts.Port = 8080
+	}
+	if opts.Version == "" {
+		opts.Version = "latest"
+	}
+	if opts.Region == "" {
+		opts.Region = spec.region
+	}
+	if opts.Image == "" {
+		opts.Image = "gcr.io/PROJECT_ID/" + opts.App
+	}
+
+	files := make(map[string][]byte)
+	for _, set := range []map[string]string{containerFiles, spec.files} {
+		for name, tmpl := range set {
+			var buf bytes.Buffer
+			if err := templates.ExecuteTemplate(&buf, tmpl, opts); err != nil {
+				return nil, fmt.Errorf("failed to render %s: %w", name, err)
+			}
+			files[name] = buf.Bytes()
+		}
+	}
+	return files, nil
+}
+
+// Write 将生成的文件写入dir，已存在的文件只有在overwrite为true时才覆盖；返回写入的文件路径
+func Write(dir string, files map[string][]byte, overwrite bool) ([]string, error) {
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	if !overwrite {
+		for _, name := range names {
+			if _, err := os.Stat(filepath.Join(dir, name)); err == nil {
+				return nil, fmt.Errorf("%s already exists in %s", name, dir)
+			}
+		}
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create %s: %w", dir, err)
+	}
+
+	written := make([]string, 0, len(names))
+	for _, name := range names {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, files[name], 0644); err != nil {
+			return written, fmt.Errorf("failed to write %s: %w", path, err)
+		}
+		written = append(written, path)
+	}
+	return written, nil
+}
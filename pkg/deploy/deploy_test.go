@@ -0,0 +1,66 @@
+package deploy
+
+import (
+	"encoding/json"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerate(t *testing.T) {
+	for _, target := range Targets() {
+		t.Run(target, func(t *testing.T) {
+			files, err := Generate(target, Options{App: "my-proxy", Port: 9090})
+			require.NoError(t, err)
+			require.Contains(t, files, "main.go")
+			require.Contains(t, files, "Dockerfile")
+
+			// 入口文件是合法的Go代码
+			_, err = parser.ParseFile(token.NewFileSet(), "main.go", files["main.go"], 0)
+			require.NoError(t, err)
+			assert.Contains(t, string(files["Dockerfile"]), "EXPOSE 9090")
+			assert.Contains(t, string(files["Dockerfile"]), "gemini-go-proxy@latest")
+		})
+	}
+
+	files, err := Generate(TargetFly, Options{App: "my-proxy"})
+	require.NoError(t, err)
+	assert.Contains(t, string(files["fly.toml"]), `app = "my-proxy"`)
+	assert.Contains(t, string(files["fly.toml"]), "internal_port = 8080")
+
+	files, err = Generate(TargetRailway, Options{})
+	require.NoError(t, err)
+	assert.True(t, json.Valid(files["railway.json"]))
+
+	files, err = Generate(TargetCloudRun, Options{Image: "europe-docker.pkg.dev/p/r/proxy", Region: "europe-west1"})
+	require.NoError(t, err)
+	assert.Contains(t, string(files["service.yaml"]), "image: europe-docker.pkg.dev/p/r/proxy")
+	assert.Contains(t, string(files["service.yaml"]), "--region europe-west1")
+
+	_, err = Generate("heroku", Options{})
+	assert.ErrorIs(t, err, ErrUnknownTarget)
+}
+
+func TestWrite(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "deploy")
+	files, err := Generate(TargetDocker, Options{})
+	require.NoError(t, err)
+
+	written, err := Write(dir, files, false)
+	require.NoError(t, err)
+	assert.Len(t, written, 3)
+	data, err := os.ReadFile(filepath.Join(dir, "Dockerfile"))
+	require.NoError(t, err)
+	assert.Equal(t, files["Dockerfile"], data)
+
+	// 不覆盖已有文件
+	_, err = Write(dir, files, false)
+	assert.Error(t, err)
+	_, err = Write(dir, files, true)
+	assert.NoError(t, err)
+}
@@ -0,0 +1,163 @@
+// Package diagnostics 提供长时间运行实例的运行时诊断
+package diagnostics
+
+import (
+	"context"
+	"os"
+	"runtime"
+	"runtime/metrics"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// DefaultLeakInterval 默认采样间隔
+	DefaultLeakInterval = time.Minute
+	// DefaultLeakWindow 默认连续增长多少次采样后告警
+	DefaultLeakWindow = 5
+)
+
+// liveHeapMetric 上次GC后存活的堆内存，比HeapAlloc更能反映泄漏
+const liveHeapMetric = "/gc/heap/live:bytes"
+
+// Sample 一次资源采样
+type Sample struct {
+	Time       time.Time
+	Goroutines int
+	OpenFDs    int // 打开的文件描述符数，平台不支持时为-1
+	HeapLive   uint64
+}
+
+// TakeSample 采集当前进程的资源使用情况
+func TakeSample() Sample {
+	heap := []metrics.Sample{{Name: liveHeapMetric}}
+	metrics.Read(heap)
+
+	sample := Sample{
+		Time:       time.Now(),
+		Goroutines: runtime.NumGoroutine(),
+		OpenFDs:    openFDs(),
+	}
+	if heap[0].Value.Kind() == metrics.KindUint64 {
+		sample.HeapLive = heap[0].Value.Uint64()
+	}
+	return sample
+}
+
+// openFDs 统计 /proc/self/fd 中的条目数，非Linux平台返回-1
+func openFDs() int {
+	entries, err := os.ReadDir("/proc/self/fd")
+	if err != nil {
+		return -1
+	}
+	return len(entries)
+}
+
+// LeakDetector 定期采样资源使用，某项指标在连续window次采样中持续增长时输出警告
+// 常见原因是每次代理轮换都创建新的传输层，或流式响应体未关闭
+type LeakDetector struct {
+	interval time.Duration
+	window   int
+	logger   *logrus.Logger
+	sample   func() Sample
+
+	history []Sample
+	warned  map[string]bool // 当前增长区间内已告警的指标，增长中断后重置
+}
+
+// NewLeakDetector 创建泄漏检测器，interval和window不大于0时使用默认值
+func NewLeakDetector(interval time.Duration, window int, logger *logrus.Logger) *LeakDetector {
+	if interval <= 0 {
+		interval = DefaultLeakInterval
+	}
+	if window <= 0 {
+		window = DefaultLeakWindow
+	}
+	if logger == nil {
+		logger = logrus.New()
+	}
+	return &LeakDetector{
+		interval: interval,
+		window:   window,
+		logger:   logger,
+		sample:   TakeSample,
+		warned:   make(map[string]bool),
+	}
+}
+
+// Start 在后台定期采样，直到ctx取消
+func (d *LeakDetector) Start(ctx context.Context) {
+	d.logger.Infof("Leak detector enabled: sampling every %s, warning after %d consecutive increases", d.interval, d.window)
+	go func() {
+		ticker := time.NewTicker(d.interval)
+		defer ticker.Stop()
+
+		d.observe(d.sample())
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				d.observe(d.sample())
+			}
+		}
+	}()
+}
+
+// observe 记录一次采样并检查持续增长的指标，返回本次新告警的指标名
+func (d *LeakDetector) observe(sample Sample) []string {
+	d.history = append(d.history, sample)
+	if len(d.history) > d.window+1 {
+		d.history = d.history[len(d.history)-d.window-1:]
+	}
+
+	d.logger.WithFields(logrus.Fields{
+		"goroutines": sample.Goroutines,
+		"open_fds":   sample.OpenFDs,
+		"heap_live":  sample.HeapLive,
+	}).Debug("Resource sample")
+
+	if len(d.history) <= d.window {
+		return nil
+	}
+
+	first := d.history[0]
+	var warned []string
+	for _, metric := range []struct {
+		name  string
+		value func(Sample) int64
+	}{
+		{"goroutines", func(s Sample) int64 { return int64(s.Goroutines) }},
+		{"open_fds", func(s Sample) int64 { return int64(s.OpenFDs) }},
+		{"heap_live", func(s Sample) int64 { return int64(s.HeapLive) }},
+	} {
+		if metric.value(first) < 0 || !d.growing(metric.value) {
+			d.warned[metric.name] = false
+			continue
+		}
+		if d.warned[metric.name] {
+			continue
+		}
+		d.warned[metric.name] = true
+		warned = append(warned, metric.name)
+
+		d.logger.WithFields(logrus.Fields{
+			"metric": metric.name,
+			"from":   metric.value(first),
+			"to":     metric.value(sample),
+			"over":   sample.Time.Sub(first.Time).Round(time.Second).String(),
+		}).Warnf("Possible leak: %s grew in %d consecutive samples (check for unclosed response bodies or transports created per proxy rotation)", metric.name, d.window)
+	}
+	return warned
+}
+
+// growing 采样窗口内指标是否严格递增
+func (d *LeakDetector) growing(value func(Sample) int64) bool {
+	for i := 1; i < len(d.history); i++ {
+		if value(d.history[i]) <= value(d.history[i-1]) {
+			return false
+		}
+	}
+	return true
+}
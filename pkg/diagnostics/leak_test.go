@@ -0,0 +1,55 @@
+package diagnostics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLeakDetector_WarnsOnMonotonicGrowth(t *testing.T) {
+	logger, hook := test.NewNullLogger()
+	d := NewLeakDetector(time.Second, 3, logger)
+
+	start := time.Now()
+	sample := func(i, goroutines, fds int, heap uint64) Sample {
+		return Sample{Time: start.Add(time.Duration(i) * time.Second), Goroutines: goroutines, OpenFDs: fds, HeapLive: heap}
+	}
+
+	// 堆内存波动，goroutine和文件描述符持续增长
+	assert.Empty(t, d.observe(sample(0, 10, 5, 100)))
+	assert.Empty(t, d.observe(sample(1, 11, 6, 90)))
+	assert.Empty(t, d.observe(sample(2, 12, 7, 120)))
+	assert.ElementsMatch(t, []string{"goroutines", "open_fds"}, d.observe(sample(3, 13, 8, 110)))
+
+	warning := hook.LastEntry()
+	assert.Equal(t, logrus.WarnLevel, warning.Level)
+	assert.EqualValues(t, 5, warning.Data["from"])
+	assert.EqualValues(t, 8, warning.Data["to"])
+
+	// 同一增长区间只告警一次
+	assert.Empty(t, d.observe(sample(4, 14, 9, 100)))
+
+	// 增长中断后重新计数
+	assert.Empty(t, d.observe(sample(5, 14, 9, 100)))
+	assert.Empty(t, d.observe(sample(6, 15, 10, 100)))
+	assert.Empty(t, d.observe(sample(7, 16, 11, 100)))
+	assert.ElementsMatch(t, []string{"goroutines", "open_fds"}, d.observe(sample(8, 17, 12, 100)))
+}
+
+func TestLeakDetector_IgnoresUnsupportedFDs(t *testing.T) {
+	logger, _ := test.NewNullLogger()
+	d := NewLeakDetector(time.Second, 2, logger)
+
+	for i := 0; i < 3; i++ {
+		assert.Empty(t, d.observe(Sample{Time: time.Now(), Goroutines: 10, OpenFDs: -1, HeapLive: 100}))
+	}
+}
+
+func TestTakeSample(t *testing.T) {
+	sample := TakeSample()
+	assert.Positive(t, sample.Goroutines)
+	assert.NotZero(t, sample.OpenFDs)
+}
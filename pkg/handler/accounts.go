@@ -0,0 +1,115 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/ba0gu0/gemini-go-proxy/pkg/auth"
+	"github.com/gorilla/mux"
+)
+
+// accountManager 支持运行时增删OAuth账号的认证器（auth.GoogleAuth）
+type accountManager interface {
+	Accounts() []auth.AccountInfo
+	AddAccount(tokenBase64 string) (int, error)
+	StartAddAccountFlow() (string, error)
+	RemoveAccount(id int) error
+}
+
+// adminAccountManager 检查管理员权限并返回账号管理器，失败时已写入错误响应
+func (s *Server) adminAccountManager(w http.ResponseWriter, r *http.Request) (accountManager, bool) {
+	if !s.isAdminRequest(r) {
+		s.writeErrorResponse(w, http.StatusForbidden, "forbidden", "Admin API key required")
+		return nil, false
+	}
+	accounts, ok := s.oauthAuth.(accountManager)
+	if !ok {
+		s.writeErrorResponse(w, http.StatusNotFound, "not_found", "OAuth account pool is not available")
+		return nil, false
+	}
+	return accounts, true
+}
+
+// handleAccounts 列出账号池中的账号及其状态
+func (s *Server) handleAccounts(w http.ResponseWriter, r *http.Request) {
+	accounts, ok := s.adminAccountManager(w, r)
+	if !ok {
+		return
+	}
+	s.writeJSONResponse(w, map[string]any{"object": "list", "data": accounts.Accounts()})
+}
+
+// handleAddAccount 向账号池添加账号：请求体 {"token": "<Base64 token>"} 直接加入并返回201；
+// 不带token时返回202和授权地址，在新标签页完成授权后账号自动加入
+func (s *Server) handleAddAccount(w http.ResponseWriter, r *http.Request) {
+	accounts, ok := s.adminAccountManager(w, r)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Token string `json:"token"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			s.writeErrorResponse(w, http.StatusBadRequest, "invalid_request", "Invalid request body")
+			return
+		}
+	}
+
+	if req.Token == "" {
+		authURL, err := accounts.StartAddAccountFlow()
+		if err != nil {
+			s.writeErrorResponse(w, http.StatusInternalServerError, "api_error", err.Error())
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(map[string]string{
+			"auth_url": authURL,
+			"message":  "Open auth_url in a new browser tab; the account joins the pool once authorization completes",
+		})
+		return
+	}
+
+	id, err := accounts.AddAccount(req.Token)
+	if err != nil && id < 0 {
+		s.writeErrorResponse(w, http.StatusBadRequest, "invalid_request", err.Error())
+		return
+	}
+	if err != nil {
+		// 账号已加入但配置保存失败
+		s.logger.WithError(err).Error("Failed to save added OAuth account")
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]any{"id": id, "accounts": accounts.Accounts()})
+}
+
+// handleRemoveAccount 将账号移出账号池，进行中的请求不受影响
+func (s *Server) handleRemoveAccount(w http.ResponseWriter, r *http.Request) {
+	accounts, ok := s.adminAccountManager(w, r)
+	if !ok {
+		return
+	}
+
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		s.writeErrorResponse(w, http.StatusBadRequest, "invalid_request", "Invalid account id")
+		return
+	}
+	if err := accounts.RemoveAccount(id); err != nil {
+		switch {
+		case errors.Is(err, auth.ErrAccountNotFound):
+			s.writeErrorResponse(w, http.StatusNotFound, "not_found", err.Error())
+			return
+		case errors.Is(err, auth.ErrLastAccount):
+			s.writeErrorResponse(w, http.StatusConflict, "conflict", err.Error())
+			return
+		}
+		s.logger.WithError(err).Error("Failed to save removed OAuth account")
+	}
+	s.writeJSONResponse(w, map[string]any{"object": "list", "data": accounts.Accounts()})
+}
@@ -0,0 +1,139 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/ba0gu0/gemini-go-proxy/pkg/jobs"
+	"github.com/ba0gu0/gemini-go-proxy/pkg/models"
+	"github.com/gorilla/mux"
+)
+
+// KindAsyncChatCompletion 异步OpenAI聊天请求的任务类型
+const KindAsyncChatCompletion = "chat.completion"
+
+// SetJobQueue 设置任务队列并注册异步聊天任务，webhooks用于投递完成结果；
+// webhook地址由调用方提交，webhooks 应为 Restricted 返回的受限投递器，为nil时使用不签名的受限投递器
+func (s *Server) SetJobQueue(queue *jobs.Queue, webhooks *jobs.WebhookSender) {
+	if webhooks == nil {
+		webhooks = jobs.NewWebhookSender("", 0, s.logger).Restricted(nil)
+	}
+	s.jobs = queue
+	s.webhooks = webhooks
+	queue.Register(KindAsyncChatCompletion, s.asyncChatCompletionHandler(webhooks))
+}
+
+// asyncChatCompletionHandler 执行异步聊天任务，配置了webhook时投递结果
+func (s *Server) asyncChatCompletionHandler(webhooks *jobs.WebhookSender) jobs.Handler {
+	return func(ctx context.Context, job *jobs.Job) (any, error) {
+		var req models.OpenAIAsyncRequest
+		if err := json.Unmarshal(job.Payload, &req); err != nil {
+			return nil, fmt.Errorf("invalid async request payload: %w", err)
+		}
+
+		resp, err := s.client.SendOpenAIRequest(ctx, &req.OpenAIRequest)
+
+		if req.Webhook != "" {
+			completed := *job
+			if err != nil {
+				completed.Status = jobs.StatusFailed
+				completed.Error = err.Error()
+			} else {
+				completed.Status = jobs.StatusSucceeded
+			}
+			if deliverErr := webhooks.Deliver(ctx, req.Webhook, asyncJobResponse(&completed, resp)); deliverErr != nil {
+				s.logger.WithError(deliverErr).Warnf("Failed to deliver async job %s to webhook", job.ID)
+			}
+		}
+
+		if err != nil {
+			return nil, err
+		}
+		return resp, nil
+	}
+}
+
+// 提交异步OpenAI聊天请求，立即返回任务ID
+func (s *Server) handleAsyncChatCompletions(w http.ResponseWriter, r *http.Request) {
+	if s.jobs == nil {
+		s.writeErrorResponse(w, http.StatusServiceUnavailable, "unavailable", "Async requests are not enabled")
+		return
+	}
+
+	var req models.OpenAIAsyncRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.writeErrorResponse(w, http.StatusBadRequest, "invalid_request_error", "Invalid request body")
+		return
+	}
+
+	if req.Stream {
+		s.writeErrorResponse(w, http.StatusBadRequest, "invalid_request_error", "Streaming is not supported for async requests")
+		return
+	}
+	if req.Webhook != "" {
+		if err := s.webhooks.CheckURL(r.Context(), req.Webhook); err != nil {
+			s.writeErrorResponse(w, http.StatusBadRequest, "invalid_request_error", err.Error())
+			return
+		}
+	}
+	req.Model = s.mapModel(req.Model)
+
+	job, err := s.jobs.SubmitFor(callerID(requestAPIKey(r)), KindAsyncChatCompletion, &req)
+	if err != nil {
+		s.logger.Errorf("Failed to submit async request: %v", err)
+		s.writeErrorResponse(w, http.StatusInternalServerError, "api_error", err.Error())
+		return
+	}
+
+	w.Header().Set("Location", "/v1/async/"+job.ID)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	if err := json.NewEncoder(w).Encode(asyncJobResponse(job, nil)); err != nil {
+		s.logger.Errorf("Failed to encode JSON response: %v", err)
+	}
+}
+
+// 查询异步任务状态和结果
+func (s *Server) handleAsyncJob(w http.ResponseWriter, r *http.Request) {
+	if s.jobs == nil {
+		s.writeErrorResponse(w, http.StatusServiceUnavailable, "unavailable", "Async requests are not enabled")
+		return
+	}
+
+	id := mux.Vars(r)["id"]
+	job, ok := s.jobs.Get(id)
+	// 只能查询自己提交的任务，其他调用方的任务与不存在的任务一样返回404
+	if !ok || job.Kind != KindAsyncChatCompletion || job.Owner != callerID(requestAPIKey(r)) {
+		s.writeErrorResponse(w, http.StatusNotFound, "not_found", "Async job not found")
+		return
+	}
+
+	var result *models.OpenAIResponse
+	if len(job.Result) > 0 {
+		result = &models.OpenAIResponse{}
+		if err := json.Unmarshal(job.Result, result); err != nil {
+			s.writeErrorResponse(w, http.StatusInternalServerError, "api_error", "Failed to decode job result")
+			return
+		}
+	}
+
+	s.writeJSONResponse(w, asyncJobResponse(job, result))
+}
+
+// asyncJobResponse 将任务转换为接口响应
+func asyncJobResponse(job *jobs.Job, result *models.OpenAIResponse) *models.AsyncJob {
+	resp := &models.AsyncJob{
+		ID:        job.ID,
+		Object:    "async.job",
+		Status:    string(job.Status),
+		Result:    result,
+		CreatedAt: job.CreatedAt.Unix(),
+		UpdatedAt: job.UpdatedAt.Unix(),
+	}
+	if job.Error != "" {
+		resp.Error = &models.ErrorDetail{Type: "api_error", Message: job.Error}
+	}
+	return resp
+}
@@ -0,0 +1,109 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/ba0gu0/gemini-go-proxy/pkg/client"
+	"github.com/ba0gu0/gemini-go-proxy/pkg/models"
+)
+
+// maxTranscriptionFormSize 语音转写表单在内存中解析的上限，超出部分写入临时文件
+const maxTranscriptionFormSize = 32 << 20
+
+// 处理OpenAI语音合成请求
+func (s *Server) handleAudioSpeech(w http.ResponseWriter, r *http.Request) {
+	var req models.OpenAISpeechRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.writeErrorResponse(w, http.StatusBadRequest, "invalid_request_error", "Invalid request body")
+		return
+	}
+
+	if req.Model == "" {
+		s.writeErrorResponse(w, http.StatusBadRequest, "invalid_request_error", "model is required")
+		return
+	}
+	req.Model = s.mapModel(req.Model)
+
+	audio, contentType, err := s.client.SynthesizeSpeech(r.Context(), &req)
+	if err != nil {
+		s.writeAudioError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(audio)))
+	w.Write(audio)
+}
+
+// 处理OpenAI语音转写请求（multipart表单，音频以内联数据发送给模型）
+func (s *Server) handleAudioTranscriptions(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseMultipartForm(maxTranscriptionFormSize); err != nil {
+		s.writeErrorResponse(w, http.StatusBadRequest, "invalid_request_error", "Invalid multipart form")
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		s.writeErrorResponse(w, http.StatusBadRequest, "invalid_request_error", "file is required")
+		return
+	}
+	defer file.Close()
+
+	req := models.OpenAITranscriptionRequest{
+		Model:          s.mapModel(r.FormValue("model")),
+		Filename:       header.Filename,
+		MimeType:       header.Header.Get("Content-Type"),
+		Language:       r.FormValue("language"),
+		Prompt:         r.FormValue("prompt"),
+		ResponseFormat: r.FormValue("response_format"),
+	}
+	if req.Model == "" {
+		s.writeErrorResponse(w, http.StatusBadRequest, "invalid_request_error", "model is required")
+		return
+	}
+	if req.ResponseFormat != "" && req.ResponseFormat != "json" && req.ResponseFormat != "text" {
+		s.writeErrorResponse(w, http.StatusBadRequest, "invalid_request_error", "Unsupported response_format, only json and text are supported")
+		return
+	}
+	if value := r.FormValue("temperature"); value != "" {
+		temperature, err := strconv.ParseFloat(value, 32)
+		if err != nil {
+			s.writeErrorResponse(w, http.StatusBadRequest, "invalid_request_error", "Invalid temperature")
+			return
+		}
+		t := float32(temperature)
+		req.Temperature = &t
+	}
+	if req.File, err = io.ReadAll(file); err != nil {
+		s.writeErrorResponse(w, http.StatusBadRequest, "invalid_request_error", "Failed to read file")
+		return
+	}
+
+	text, err := s.client.TranscribeAudio(r.Context(), &req)
+	if err != nil {
+		s.writeAudioError(w, err)
+		return
+	}
+
+	if req.ResponseFormat == "text" {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		io.WriteString(w, text)
+		return
+	}
+	s.writeJSONResponse(w, models.OpenAITranscriptionResponse{Text: text})
+}
+
+// writeAudioError 输出语音接口错误，参数错误和上游未返回音频时返回400
+func (s *Server) writeAudioError(w http.ResponseWriter, err error) {
+	if errors.Is(err, client.ErrInvalidAudioRequest) || errors.Is(err, client.ErrNoAudio) {
+		s.writeErrorResponse(w, http.StatusBadRequest, "invalid_request_error", err.Error())
+		return
+	}
+	s.logger.Errorf("Audio request failed: %v", err)
+	s.writeRequestError(w, err)
+}
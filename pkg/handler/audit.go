@@ -0,0 +1,71 @@
+package handler
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ba0gu0/gemini-go-proxy/pkg/audit"
+)
+
+// SetAuditLog 设置请求审计日志，nil表示不记录
+func (s *Server) SetAuditLog(logger *audit.Logger) {
+	s.audit = logger
+}
+
+// auditCallerUnverified 未通过认证的请求在审计记录中的调用方
+const auditCallerUnverified = "unverified"
+
+// auditCallerKey 上下文中审计调用方的键，认证中间件在认证通过后写入
+type auditCallerKey struct{}
+
+// 审计中间件，在认证之前记录每个请求（含被拒绝的请求）的调用方、路径和状态码；
+// 调用方取自认证中间件确认的身份，未通过认证的请求记为 unverified
+func (s *Server) auditMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.audit == nil || r.URL.Path == "/health" || r.URL.Path == "/ready" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		start := time.Now()
+		caller := new(string)
+		rw := &responseWriter{ResponseWriter: w, statusCode: http.StatusOK}
+		next.ServeHTTP(rw, r.WithContext(context.WithValue(r.Context(), auditCallerKey{}, caller)))
+
+		if *caller == "" {
+			*caller = auditCallerUnverified
+		}
+		entry := audit.Entry{
+			Key:        *caller,
+			ClientIP:   s.clientIP(r),
+			Method:     r.Method,
+			Path:       r.URL.Path,
+			Status:     rw.statusCode,
+			DurationMs: time.Since(start).Milliseconds(),
+		}
+		if err := s.audit.Log(entry); err != nil {
+			s.logger.WithError(err).Error("Failed to write audit log record")
+		}
+	})
+}
+
+// setAuditCaller 记录通过认证的调用方，供审计中间件写入审计记录
+func setAuditCaller(r *http.Request, apiKey string) {
+	if caller, ok := r.Context().Value(auditCallerKey{}).(*string); ok {
+		*caller = callerID(apiKey)
+	}
+}
+
+// callerID 返回落盘数据中使用的调用方标识，不保存明文密钥：
+// 签名请求为 "hmac:<密钥ID>"，API密钥为 "key:" 加密钥SHA-256的前12位
+func callerID(apiKey string) string {
+	if apiKey == "" || strings.HasPrefix(apiKey, "hmac:") {
+		return apiKey
+	}
+	sum := sha256.Sum256([]byte(apiKey))
+	return "key:" + hex.EncodeToString(sum[:])[:12]
+}
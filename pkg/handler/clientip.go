@@ -0,0 +1,118 @@
+package handler
+
+import (
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// parseTrustedProxies 解析可信代理列表，支持CIDR和单个IP
+func parseTrustedProxies(entries []string, logger *logrus.Logger) []*net.IPNet {
+	var networks []*net.IPNet
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		if !strings.Contains(entry, "/") {
+			ip := net.ParseIP(entry)
+			if ip == nil {
+				logger.Warnf("Ignoring invalid trusted proxy: %s", entry)
+				continue
+			}
+			bits := 128
+			if ip.To4() != nil {
+				ip = ip.To4()
+				bits = 32
+			}
+			networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+
+		_, network, err := net.ParseCIDR(entry)
+		if err != nil {
+			logger.Warnf("Ignoring invalid trusted proxy CIDR %s: %v", entry, err)
+			continue
+		}
+		networks = append(networks, network)
+	}
+	return networks
+}
+
+// isTrustedProxy 判断地址是否属于可信代理
+func (s *Server) isTrustedProxy(ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+	for _, network := range s.trustedProxies {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// remotePeer 返回直连对端的地址（不含端口）
+func remotePeer(r *http.Request) string {
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+	return r.RemoteAddr
+}
+
+// clientIP 返回真实客户端IP：仅当直连对端是可信代理时才采信 X-Forwarded-For / X-Real-IP
+func (s *Server) clientIP(r *http.Request) string {
+	peer := remotePeer(r)
+	if !s.isTrustedProxy(net.ParseIP(peer)) {
+		return peer
+	}
+
+	// 从右向左跳过可信代理，第一个不可信的地址即为客户端
+	if forwarded := r.Header.Values("X-Forwarded-For"); len(forwarded) > 0 {
+		var hops []string
+		for _, value := range forwarded {
+			for _, hop := range strings.Split(value, ",") {
+				if hop = strings.TrimSpace(hop); hop != "" {
+					hops = append(hops, hop)
+				}
+			}
+		}
+		for i := len(hops) - 1; i >= 0; i-- {
+			ip := net.ParseIP(hops[i])
+			if ip == nil {
+				break
+			}
+			if !s.isTrustedProxy(ip) || i == 0 {
+				return ip.String()
+			}
+		}
+	}
+
+	if realIP := net.ParseIP(strings.TrimSpace(r.Header.Get("X-Real-IP"))); realIP != nil {
+		return realIP.String()
+	}
+
+	return peer
+}
+
+// externalBaseURL 返回客户端访问代理使用的地址（scheme://host）：
+// 仅当直连对端是可信代理时才采信 X-Forwarded-Proto / X-Forwarded-Host
+func (s *Server) externalBaseURL(r *http.Request) string {
+	scheme, host := "http", r.Host
+	if r.TLS != nil {
+		scheme = "https"
+	}
+
+	if s.isTrustedProxy(net.ParseIP(remotePeer(r))) {
+		if proto := strings.TrimSpace(strings.Split(r.Header.Get("X-Forwarded-Proto"), ",")[0]); proto == "http" || proto == "https" {
+			scheme = proto
+		}
+		if forwarded := strings.TrimSpace(strings.Split(r.Header.Get("X-Forwarded-Host"), ",")[0]); forwarded != "" {
+			host = forwarded
+		}
+	}
+	return scheme + "://" + host
+}
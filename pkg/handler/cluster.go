@@ -0,0 +1,92 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/ba0gu0/gemini-go-proxy/pkg/cluster"
+	"github.com/sirupsen/logrus"
+)
+
+// NewClusterServer 创建前置路由模式的服务器，除健康检查外的请求通过认证后转发到下游代理实例
+func NewClusterServer(router *cluster.Router, config *ServerConfig, logger *logrus.Logger) *Server {
+	s := newServer(nil, config, logger)
+	s.cluster = router
+
+	// 健康检查端点 - 在中间件之前设置，避免认证问题
+	s.router.HandleFunc("/health", s.handleHealth).Methods("GET")
+	s.router.HandleFunc("/ready", s.handleReady).Methods("GET")
+
+	// 中间件
+	s.router.Use(s.responseHeadersMiddleware)
+	s.router.Use(s.loggingMiddleware)
+	s.router.Use(s.corsMiddleware)
+	s.router.Use(s.authMiddleware)
+
+	// 实例注册使用共享密钥认证，集群管理接口需要管理员密钥
+	s.router.HandleFunc(cluster.RegisterPath, s.handleClusterRegister).Methods("POST")
+	s.router.HandleFunc("/admin/cluster", s.handleClusterStatus).Methods("GET")
+	s.router.HandleFunc("/admin/cluster/drain", s.handleClusterDrain).Methods("POST")
+
+	s.router.PathPrefix("/").Handler(router)
+	return s
+}
+
+// 处理实例注册和心跳
+func (s *Server) handleClusterRegister(w http.ResponseWriter, r *http.Request) {
+	if !s.cluster.CheckSecret(r.Header.Get(cluster.SecretHeader)) {
+		s.writeErrorResponse(w, http.StatusUnauthorized, "unauthorized", "Unauthorized: invalid cluster secret")
+		return
+	}
+
+	var reg cluster.Registration
+	if err := json.NewDecoder(r.Body).Decode(&reg); err != nil {
+		s.writeErrorResponse(w, http.StatusBadRequest, "invalid_request", "Invalid JSON format")
+		return
+	}
+
+	resp, err := s.cluster.Register(reg)
+	if err != nil {
+		s.writeErrorResponse(w, http.StatusBadRequest, "invalid_request", err.Error())
+		return
+	}
+	s.writeJSONResponse(w, resp)
+}
+
+// 处理集群状态查询
+func (s *Server) handleClusterStatus(w http.ResponseWriter, r *http.Request) {
+	if !s.isAdminRequest(r) {
+		s.writeErrorResponse(w, http.StatusForbidden, "forbidden", "Admin API key required")
+		return
+	}
+
+	s.writeJSONResponse(w, map[string]any{
+		"upstreams": s.cluster.Status(),
+		"timestamp": time.Now().Format(time.RFC3339),
+	})
+}
+
+// 处理实例排空/恢复
+func (s *Server) handleClusterDrain(w http.ResponseWriter, r *http.Request) {
+	if !s.isAdminRequest(r) {
+		s.writeErrorResponse(w, http.StatusForbidden, "forbidden", "Admin API key required")
+		return
+	}
+
+	var req struct {
+		URL   string `json:"url"`
+		Drain *bool  `json:"drain"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.URL == "" {
+		s.writeErrorResponse(w, http.StatusBadRequest, "invalid_request", "Request must include the upstream url")
+		return
+	}
+
+	drain := req.Drain == nil || *req.Drain
+	if err := s.cluster.Drain(req.URL, drain); err != nil {
+		s.writeErrorResponse(w, http.StatusNotFound, "not_found", err.Error())
+		return
+	}
+	s.writeJSONResponse(w, map[string]any{"url": req.URL, "draining": drain})
+}
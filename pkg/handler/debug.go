@@ -0,0 +1,84 @@
+package handler
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/ba0gu0/gemini-go-proxy/pkg/client"
+)
+
+const (
+	// DebugRequestHeader 请求调试回显的请求头
+	DebugRequestHeader = "X-Proxy-Debug"
+	// DebugResponseHeader 调试回显信息的响应头（流式响应中以trailer形式发送）
+	DebugResponseHeader = "X-Proxy-Debug-Info"
+)
+
+// 调试回显中间件，仅对管理员密钥生效
+func (s *Server) debugMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.EqualFold(r.Header.Get(DebugRequestHeader), "true") || !s.isAdminRequest(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		trace := &client.RequestTrace{}
+		dw := &debugResponseWriter{ResponseWriter: w, trace: trace}
+		next.ServeHTTP(dw, r.WithContext(client.WithRequestTrace(r.Context(), trace)))
+
+		// 响应头已发送（如流式响应）时，以trailer形式发送调试信息
+		if dw.wroteHeader && !dw.headerSet && trace.Recorded() {
+			w.Header().Set(http.TrailerPrefix+DebugResponseHeader, trace.String())
+		}
+	})
+}
+
+// isAdminRequest 检查请求是否使用了管理员密钥
+func (s *Server) isAdminRequest(r *http.Request) bool {
+	apiKey := requestAPIKey(r)
+	if apiKey == "" {
+		apiKey = s.matchAPIKey(r)
+	}
+	if apiKey == "" {
+		return false
+	}
+	_, adminKeys := s.apiKeys()
+	for _, adminKey := range adminKeys {
+		if apiKey == adminKey {
+			return true
+		}
+	}
+	return false
+}
+
+// debugResponseWriter 在响应头发送前写入调试信息
+type debugResponseWriter struct {
+	http.ResponseWriter
+	trace       *client.RequestTrace
+	wroteHeader bool
+	headerSet   bool
+}
+
+func (dw *debugResponseWriter) WriteHeader(code int) {
+	if !dw.wroteHeader {
+		dw.wroteHeader = true
+		if dw.trace.Recorded() {
+			dw.Header().Set(DebugResponseHeader, dw.trace.String())
+			dw.headerSet = true
+		}
+	}
+	dw.ResponseWriter.WriteHeader(code)
+}
+
+func (dw *debugResponseWriter) Write(data []byte) (int, error) {
+	if !dw.wroteHeader {
+		dw.WriteHeader(http.StatusOK)
+	}
+	return dw.ResponseWriter.Write(data)
+}
+
+func (dw *debugResponseWriter) Flush() {
+	if flusher, ok := dw.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
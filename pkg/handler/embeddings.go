@@ -0,0 +1,88 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/ba0gu0/gemini-go-proxy/pkg/client"
+	"github.com/ba0gu0/gemini-go-proxy/pkg/models"
+	"github.com/gorilla/mux"
+)
+
+// 处理OpenAI嵌入请求
+func (s *Server) handleEmbeddings(w http.ResponseWriter, r *http.Request) {
+	var req models.OpenAIEmbeddingRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.writeErrorResponse(w, http.StatusBadRequest, "invalid_request_error", "Invalid request body")
+		return
+	}
+
+	if req.Model == "" {
+		s.writeErrorResponse(w, http.StatusBadRequest, "invalid_request_error", "model is required")
+		return
+	}
+	req.Model = s.mapModel(req.Model)
+
+	resp, err := s.client.SendOpenAIEmbeddingRequest(r.Context(), &req)
+	if err != nil {
+		s.writeEmbeddingError(w, err)
+		return
+	}
+
+	s.writeJSONResponse(w, resp)
+}
+
+// 处理Gemini单条嵌入请求
+func (s *Server) handleGeminiEmbedContent(w http.ResponseWriter, r *http.Request) {
+	model := mux.Vars(r)["model"]
+
+	var req models.GeminiEmbedContentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.writeErrorResponse(w, http.StatusBadRequest, "invalid_request_error", "Invalid request body")
+		return
+	}
+
+	batch := &models.GeminiBatchEmbedContentsRequest{Requests: []models.GeminiEmbedContentRequest{req}}
+	resp, _, err := s.client.SendEmbeddingRequest(r.Context(), model, batch)
+	if err != nil {
+		s.writeEmbeddingError(w, err)
+		return
+	}
+
+	s.writeJSONResponse(w, &models.GeminiEmbedContentResponse{Embedding: resp.Embeddings[0]})
+}
+
+// 处理Gemini批量嵌入请求
+func (s *Server) handleGeminiBatchEmbedContents(w http.ResponseWriter, r *http.Request) {
+	model := mux.Vars(r)["model"]
+
+	var req models.GeminiBatchEmbedContentsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.writeErrorResponse(w, http.StatusBadRequest, "invalid_request_error", "Invalid request body")
+		return
+	}
+
+	if len(req.Requests) == 0 {
+		s.writeErrorResponse(w, http.StatusBadRequest, "invalid_request_error", "requests must not be empty")
+		return
+	}
+
+	resp, _, err := s.client.SendEmbeddingRequest(r.Context(), model, &req)
+	if err != nil {
+		s.writeEmbeddingError(w, err)
+		return
+	}
+
+	s.writeJSONResponse(w, resp)
+}
+
+// writeEmbeddingError 输出嵌入请求错误，参数错误、当前模式不支持和被拦截器拒绝时返回400
+func (s *Server) writeEmbeddingError(w http.ResponseWriter, err error) {
+	if errors.Is(err, client.ErrInvalidEmbeddingRequest) || errors.Is(err, client.ErrEmbeddingsUnsupported) || errors.Is(err, client.ErrRequestRejected) {
+		s.writeErrorResponse(w, http.StatusBadRequest, "invalid_request_error", err.Error())
+		return
+	}
+	s.logger.Errorf("Embedding request failed: %v", err)
+	s.writeErrorResponse(w, http.StatusInternalServerError, "api_error", err.Error())
+}
@@ -0,0 +1,48 @@
+package handler
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// parseResponseHeaders 解析配置的静态响应头部，忽略名称或值不合法的条目
+func parseResponseHeaders(entries map[string]string, logger *logrus.Logger) http.Header {
+	if len(entries) == 0 {
+		return nil
+	}
+	header := make(http.Header, len(entries))
+	for name, value := range entries {
+		name = strings.TrimSpace(name)
+		if !validHeaderName(name) || strings.ContainsAny(value, "\r\n") {
+			logger.Warnf("Ignoring invalid response header: %q", name)
+			continue
+		}
+		header.Set(name, value)
+	}
+	return header
+}
+
+// validHeaderName 头部名称是否只包含RFC 9110允许的token字符
+func validHeaderName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, r := range name {
+		if r > 0x7e || r <= ' ' || strings.ContainsRune(`"(),/:;<=>?@[\]{}`, r) {
+			return false
+		}
+	}
+	return true
+}
+
+// responseHeadersMiddleware 为每个响应添加配置的静态头部，处理器设置的同名头部优先
+func (s *Server) responseHeadersMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for name, values := range s.responseHeaders {
+			w.Header()[name] = values
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,76 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/ba0gu0/gemini-go-proxy/pkg/client"
+	"github.com/ba0gu0/gemini-go-proxy/pkg/models"
+	"github.com/gorilla/mux"
+)
+
+// 处理OpenAI图像生成请求
+func (s *Server) handleImageGenerations(w http.ResponseWriter, r *http.Request) {
+	var req models.OpenAIImageRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.writeErrorResponse(w, http.StatusBadRequest, "invalid_request_error", "Invalid request body")
+		return
+	}
+
+	if req.Model == "" {
+		s.writeErrorResponse(w, http.StatusBadRequest, "invalid_request_error", "model is required")
+		return
+	}
+	req.Model = s.mapModel(req.Model)
+
+	resp, err := s.client.GenerateImages(r.Context(), &req)
+	if err != nil {
+		s.writeImageError(w, err)
+		return
+	}
+
+	s.writeJSONResponse(w, resp)
+}
+
+// 处理Gemini原生predict请求（Imagen等模型）
+func (s *Server) handleGeminiPredict(w http.ResponseWriter, r *http.Request) {
+	s.forwardPredict(w, r, mux.Vars(r)["model"])
+}
+
+// 处理Vertex AI predict请求
+func (s *Server) handleVertexPredict(w http.ResponseWriter, r *http.Request) {
+	model, err := vertexModelName(r)
+	if err != nil {
+		s.writeErrorResponse(w, http.StatusBadRequest, "invalid_request_error", err.Error())
+		return
+	}
+	s.forwardPredict(w, r, model)
+}
+
+// forwardPredict 将predict请求体原样发送到当前模式下模型的predict接口
+func (s *Server) forwardPredict(w http.ResponseWriter, r *http.Request, model string) {
+	var body json.RawMessage
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		s.writeErrorResponse(w, http.StatusBadRequest, "invalid_request_error", "Invalid request body")
+		return
+	}
+
+	resp, err := s.client.SendPredictRequest(r.Context(), model, body)
+	if err != nil {
+		s.writeImageError(w, err)
+		return
+	}
+
+	s.writeJSONResponse(w, resp)
+}
+
+// writeImageError 输出图像生成错误，参数错误、当前模式不支持和图像被过滤时返回400
+func (s *Server) writeImageError(w http.ResponseWriter, err error) {
+	if errors.Is(err, client.ErrInvalidImageRequest) || errors.Is(err, client.ErrPredictUnsupported) || errors.Is(err, client.ErrNoImages) {
+		s.writeErrorResponse(w, http.StatusBadRequest, "invalid_request_error", err.Error())
+		return
+	}
+	s.logger.Errorf("Image request failed: %v", err)
+	s.writeRequestError(w, err)
+}
@@ -0,0 +1,99 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultMaintenanceRetryAfter 维护模式下建议客户端重试的默认间隔
+const defaultMaintenanceRetryAfter = 60 * time.Second
+
+// MaintenanceState 维护模式状态
+type MaintenanceState struct {
+	Enabled           bool      `json:"enabled"`
+	Message           string    `json:"message,omitempty"`
+	RetryAfterSeconds int       `json:"retry_after_seconds,omitempty"`
+	Since             time.Time `json:"since,omitempty"`
+}
+
+// SetMaintenance 开启或关闭维护模式：开启后只读，拒绝新的生成请求并返回503和Retry-After，
+// 健康检查、模型列表等GET接口和进行中的流式响应不受影响
+func (s *Server) SetMaintenance(enabled bool, retryAfter time.Duration, message string) {
+	if !enabled {
+		s.maintenance.Store(nil)
+		s.logger.Info("Maintenance mode disabled")
+		return
+	}
+	if retryAfter <= 0 {
+		retryAfter = defaultMaintenanceRetryAfter
+	}
+	s.maintenance.Store(&MaintenanceState{
+		Enabled:           true,
+		Message:           message,
+		RetryAfterSeconds: int(retryAfter.Seconds()),
+		Since:             time.Now().UTC(),
+	})
+	s.logger.Warnf("Maintenance mode enabled, rejecting new generations (retry after %s)", retryAfter)
+}
+
+// Maintenance 返回当前的维护模式状态
+func (s *Server) Maintenance() MaintenanceState {
+	if state := s.maintenance.Load(); state != nil {
+		return *state
+	}
+	return MaintenanceState{}
+}
+
+// maintenanceMiddleware 维护模式下拒绝除只读请求、OAuth和管理接口之外的请求
+func (s *Server) maintenanceMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		state := s.maintenance.Load()
+		if state == nil || isReadOnlyRequest(r) ||
+			strings.HasPrefix(r.URL.Path, "/oauth/") || strings.HasPrefix(r.URL.Path, "/admin/") {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		message := state.Message
+		if message == "" {
+			message = "The proxy is in maintenance mode, please retry later"
+		}
+		w.Header().Set("Retry-After", strconv.Itoa(state.RetryAfterSeconds))
+		s.writeErrorResponse(w, http.StatusServiceUnavailable, "maintenance", message)
+	})
+}
+
+// isReadOnlyRequest 请求是否不产生新的生成：GET/HEAD/OPTIONS 和 token 计数
+func isReadOnlyRequest(r *http.Request) bool {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+	return strings.HasSuffix(r.URL.Path, ":countTokens") || r.URL.Path == "/utils/tokenize"
+}
+
+// handleMaintenance 查询（GET）或切换（POST）维护模式
+// POST请求体：{"enabled": true, "retry_after_seconds": 120, "message": "..."}
+func (s *Server) handleMaintenance(w http.ResponseWriter, r *http.Request) {
+	if !s.isAdminRequest(r) {
+		s.writeErrorResponse(w, http.StatusForbidden, "forbidden", "Admin API key required")
+		return
+	}
+
+	if r.Method == http.MethodPost {
+		var req struct {
+			Enabled           *bool  `json:"enabled"`
+			RetryAfterSeconds int    `json:"retry_after_seconds"`
+			Message           string `json:"message"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Enabled == nil {
+			s.writeErrorResponse(w, http.StatusBadRequest, "invalid_request", "Request must include enabled")
+			return
+		}
+		s.SetMaintenance(*req.Enabled, time.Duration(req.RetryAfterSeconds)*time.Second, req.Message)
+	}
+	s.writeJSONResponse(w, s.Maintenance())
+}
@@ -0,0 +1,101 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ba0gu0/gemini-go-proxy/pkg/client"
+	"github.com/ba0gu0/gemini-go-proxy/pkg/models"
+	"github.com/sirupsen/logrus"
+)
+
+// 流式响应结束时发送的trailer
+const (
+	TrailerUsage           = "X-Proxy-Usage"
+	TrailerUpstreamLatency = "X-Proxy-Upstream-Latency-Ms"
+	TrailerDuration        = "X-Proxy-Duration-Ms"
+	TrailerRoute           = "X-Proxy-Route"
+	TrailerTTFT            = "X-Proxy-TTFT-Ms"
+	TrailerModelVersion    = "X-Proxy-Model-Version"
+)
+
+// streamTrailers 可续传的流式响应预先声明的trailer
+var streamTrailers = []string{TrailerUsage, TrailerUpstreamLatency, TrailerDuration, TrailerRoute, TrailerTTFT, TrailerModelVersion}
+
+// streamMetadata 流式响应结束时附带的可观测性元数据
+type streamMetadata struct {
+	Usage             *models.OpenAIUsage `json:"usage,omitempty"`
+	UpstreamLatencyMs int64               `json:"upstream_latency_ms"`
+	TTFTMs            int64               `json:"ttft_ms,omitempty"`
+	DurationMs        int64               `json:"duration_ms"`
+	Route             string              `json:"route,omitempty"`
+	ModelVersion      string              `json:"model_version,omitempty"`
+}
+
+// withStreamTrace 确保请求上下文中带有路由追踪记录（调试中间件可能已创建）
+func (s *Server) withStreamTrace(r *http.Request) (*http.Request, *client.RequestTrace) {
+	if trace := client.RequestTraceFromContext(r.Context()); trace != nil {
+		return r, trace
+	}
+	trace := &client.RequestTrace{}
+	return r.WithContext(client.WithRequestTrace(r.Context(), trace)), trace
+}
+
+// buildStreamMetadata 根据追踪记录汇总流式响应元数据
+func buildStreamMetadata(trace *client.RequestTrace, start time.Time) *streamMetadata {
+	metadata := &streamMetadata{
+		UpstreamLatencyMs: trace.UpstreamLatencySnapshot().Milliseconds(),
+		TTFTMs:            trace.FirstTokenSnapshot().Milliseconds(),
+		DurationMs:        time.Since(start).Milliseconds(),
+		ModelVersion:      trace.ModelVersionSnapshot(),
+	}
+	if trace.Recorded() {
+		metadata.Route = trace.Summary()
+	}
+	if usage := trace.UsageSnapshot(); usage.TotalTokenCount > 0 {
+		metadata.Usage = &models.OpenAIUsage{
+			PromptTokens:     usage.PromptTokenCount,
+			CompletionTokens: usage.CandidatesTokenCount,
+			TotalTokens:      usage.TotalTokenCount,
+		}
+	}
+	return metadata
+}
+
+// writeStreamMetadata 发送SSE元数据事件（如已启用），并设置流式响应的trailer
+func (s *Server) writeStreamMetadata(w http.ResponseWriter, flusher http.Flusher, trace *client.RequestTrace, start time.Time) {
+	metadata := buildStreamMetadata(trace, start)
+	s.logger.WithFields(logrus.Fields{
+		"ttft_ms":             metadata.TTFTMs,
+		"upstream_latency_ms": metadata.UpstreamLatencyMs,
+		"duration_ms":         metadata.DurationMs,
+		"model_version":       metadata.ModelVersion,
+	}).Info("Stream completed")
+
+	if s.config.StreamMetadataEvent {
+		if data, err := json.Marshal(metadata); err == nil {
+			fmt.Fprintf(w, "event: metadata\ndata: %s\n\n", data)
+			flusher.Flush()
+		}
+	}
+
+	header := w.Header()
+	if metadata.Usage != nil {
+		header.Set(http.TrailerPrefix+TrailerUsage, fmt.Sprintf("prompt_tokens=%d; completion_tokens=%d; total_tokens=%d",
+			metadata.Usage.PromptTokens, metadata.Usage.CompletionTokens, metadata.Usage.TotalTokens))
+	}
+	header.Set(http.TrailerPrefix+TrailerUpstreamLatency, strconv.FormatInt(metadata.UpstreamLatencyMs, 10))
+	header.Set(http.TrailerPrefix+TrailerDuration, strconv.FormatInt(metadata.DurationMs, 10))
+	if metadata.TTFTMs > 0 {
+		header.Set(http.TrailerPrefix+TrailerTTFT, strconv.FormatInt(metadata.TTFTMs, 10))
+	}
+	if metadata.Route != "" {
+		header.Set(http.TrailerPrefix+TrailerRoute, metadata.Route)
+	}
+	if metadata.ModelVersion != "" {
+		header.Set(http.TrailerPrefix+TrailerModelVersion, metadata.ModelVersion)
+	}
+}
@@ -0,0 +1,94 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/ba0gu0/gemini-go-proxy/pkg/client"
+	"github.com/ba0gu0/gemini-go-proxy/pkg/config"
+	"github.com/gorilla/mux"
+)
+
+// handlePipelines 列出流水线的版本、稳定版本和灰度流量分配
+func (s *Server) handlePipelines(w http.ResponseWriter, r *http.Request) {
+	if !s.isAdminRequest(r) {
+		s.writeErrorResponse(w, http.StatusForbidden, "forbidden", "Admin API key required")
+		return
+	}
+	s.writeJSONResponse(w, map[string]any{"object": "list", "data": s.client.Pipelines()})
+}
+
+// handlePutPipelineVersion 新增或替换流水线的版本，请求体为版本配置（与配置文件中的 versions 条目相同）
+func (s *Server) handlePutPipelineVersion(w http.ResponseWriter, r *http.Request) {
+	if !s.isAdminRequest(r) {
+		s.writeErrorResponse(w, http.StatusForbidden, "forbidden", "Admin API key required")
+		return
+	}
+
+	var version config.PipelineVersion
+	if err := json.NewDecoder(r.Body).Decode(&version); err != nil {
+		s.writeErrorResponse(w, http.StatusBadRequest, "invalid_request", "Invalid request body")
+		return
+	}
+
+	vars := mux.Vars(r)
+	status, err := s.client.PutPipelineVersion(vars["name"], vars["version"], version)
+	if err != nil {
+		s.writePipelineError(w, err)
+		return
+	}
+	s.writeJSONResponse(w, status)
+}
+
+// handlePipelineRollout 设置灰度流量分配，也可同时切换稳定版本
+// 请求体：{"stable": "v1", "rollout": {"v2": 10}}，stable 省略时保持不变，rollout 为空表示停止灰度
+func (s *Server) handlePipelineRollout(w http.ResponseWriter, r *http.Request) {
+	if !s.isAdminRequest(r) {
+		s.writeErrorResponse(w, http.StatusForbidden, "forbidden", "Admin API key required")
+		return
+	}
+
+	var req struct {
+		Stable  string         `json:"stable"`
+		Rollout map[string]int `json:"rollout"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.writeErrorResponse(w, http.StatusBadRequest, "invalid_request", "Invalid request body")
+		return
+	}
+
+	status, err := s.client.SetPipelineRollout(mux.Vars(r)["name"], req.Stable, req.Rollout)
+	if err != nil {
+		s.writePipelineError(w, err)
+		return
+	}
+	s.writeJSONResponse(w, status)
+}
+
+// handlePipelineRollback 立即停止灰度，全部流量回到稳定版本
+func (s *Server) handlePipelineRollback(w http.ResponseWriter, r *http.Request) {
+	if !s.isAdminRequest(r) {
+		s.writeErrorResponse(w, http.StatusForbidden, "forbidden", "Admin API key required")
+		return
+	}
+
+	status, err := s.client.RollbackPipeline(mux.Vars(r)["name"])
+	if err != nil {
+		s.writePipelineError(w, err)
+		return
+	}
+	s.writeJSONResponse(w, status)
+}
+
+// writePipelineError 输出流水线管理接口的错误
+func (s *Server) writePipelineError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, client.ErrPipelineNotFound):
+		s.writeErrorResponse(w, http.StatusNotFound, "not_found", err.Error())
+	case errors.Is(err, client.ErrPipelineVersionInUse):
+		s.writeErrorResponse(w, http.StatusConflict, "conflict", err.Error())
+	default:
+		s.writeErrorResponse(w, http.StatusBadRequest, "invalid_request", err.Error())
+	}
+}
@@ -0,0 +1,268 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ba0gu0/gemini-go-proxy/pkg/config"
+)
+
+// defaultRateLimitKey 未单独配置限额的密钥使用的默认限额
+const defaultRateLimitKey = "*"
+
+// rateLimiter 按API密钥统计每分钟请求数（滑动窗口）和每天（UTC）token用量
+type rateLimiter struct {
+	mu     sync.Mutex
+	limits map[string]config.RateLimit
+	usage  map[string]*keyUsage
+	now    func() time.Time
+}
+
+// keyUsage 单个密钥的用量
+type keyUsage struct {
+	requests []time.Time // 最近一分钟内的请求时间
+	day      string      // tokens对应的UTC日期
+	tokens   int
+}
+
+// quotaStatus 密钥的限额和剩余用量，由 /v1/usage 返回
+type quotaStatus struct {
+	RequestsPerMinute  int    `json:"requests_per_minute,omitempty"`
+	RemainingRequests  *int   `json:"remaining_requests,omitempty"` // 未限制请求数时为空
+	RequestsResetAt    string `json:"requests_reset_at,omitempty"`
+	TokensPerDay       int    `json:"tokens_per_day,omitempty"`
+	TokensUsedToday    int    `json:"tokens_used_today"`
+	RemainingTokens    *int   `json:"remaining_tokens,omitempty"` // 未限制token时为空
+	TokensResetAt      string `json:"tokens_reset_at"`
+	requestsResetAfter time.Duration
+	tokensResetAfter   time.Duration
+}
+
+// newRateLimiter 创建限流器，未配置任何限额时返回nil
+func newRateLimiter(limits map[string]config.RateLimit) *rateLimiter {
+	if len(limits) == 0 {
+		return nil
+	}
+	return &rateLimiter{
+		limits: limits,
+		usage:  make(map[string]*keyUsage),
+		now:    time.Now,
+	}
+}
+
+// limitFor 获取密钥的限额，没有单独配置时使用默认限额
+func (l *rateLimiter) limitFor(key string) (config.RateLimit, bool) {
+	if limit, ok := l.limits[key]; ok {
+		return limit, true
+	}
+	limit, ok := l.limits[defaultRateLimitKey]
+	return limit, ok
+}
+
+// usageLocked 获取密钥的用量并清理过期记录，调用方需持有mu
+func (l *rateLimiter) usageLocked(key string, now time.Time) *keyUsage {
+	usage, ok := l.usage[key]
+	if !ok {
+		usage = &keyUsage{}
+		l.usage[key] = usage
+	}
+
+	cutoff := now.Add(-time.Minute)
+	expired := 0
+	for expired < len(usage.requests) && !usage.requests[expired].After(cutoff) {
+		expired++
+	}
+	usage.requests = usage.requests[expired:]
+
+	if day := now.UTC().Format(time.DateOnly); usage.day != day {
+		usage.day = day
+		usage.tokens = 0
+	}
+	return usage
+}
+
+// statusLocked 计算密钥当前的剩余用量，调用方需持有mu
+func (l *rateLimiter) statusLocked(limit config.RateLimit, usage *keyUsage, now time.Time) quotaStatus {
+	tomorrow := now.UTC().Truncate(24 * time.Hour).Add(24 * time.Hour)
+	status := quotaStatus{
+		RequestsPerMinute: limit.RequestsPerMinute,
+		TokensPerDay:      limit.TokensPerDay,
+		TokensUsedToday:   usage.tokens,
+		TokensResetAt:     tomorrow.Format(time.RFC3339),
+		tokensResetAfter:  tomorrow.Sub(now),
+	}
+	if limit.RequestsPerMinute > 0 {
+		remaining := max(limit.RequestsPerMinute-len(usage.requests), 0)
+		status.RemainingRequests = &remaining
+		resetAt := now
+		if len(usage.requests) > 0 {
+			resetAt = usage.requests[0].Add(time.Minute)
+		}
+		status.RequestsResetAt = resetAt.UTC().Format(time.RFC3339)
+		status.requestsResetAfter = resetAt.Sub(now)
+	}
+	if limit.TokensPerDay > 0 {
+		remaining := max(limit.TokensPerDay-usage.tokens, 0)
+		status.RemainingTokens = &remaining
+	}
+	return status
+}
+
+// allow 检查并记录一次请求，超出限额时返回超出的限额类型（"requests" 或 "tokens"）
+func (l *rateLimiter) allow(key string) (quotaStatus, string, bool) {
+	limit, ok := l.limitFor(key)
+	if !ok {
+		return quotaStatus{}, "", true
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	usage := l.usageLocked(key, now)
+
+	if limit.TokensPerDay > 0 && usage.tokens >= limit.TokensPerDay {
+		return l.statusLocked(limit, usage, now), "tokens", false
+	}
+	if limit.RequestsPerMinute > 0 && len(usage.requests) >= limit.RequestsPerMinute {
+		return l.statusLocked(limit, usage, now), "requests", false
+	}
+	usage.requests = append(usage.requests, now)
+	return l.statusLocked(limit, usage, now), "", true
+}
+
+// addTokens 记录请求消耗的token
+func (l *rateLimiter) addTokens(key string, tokens int) {
+	if tokens <= 0 {
+		return
+	}
+	if _, ok := l.limitFor(key); !ok {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.usageLocked(key, l.now()).tokens += tokens
+}
+
+// forget 删除密钥的用量记录
+func (l *rateLimiter) forget(key string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	_, ok := l.usage[key]
+	delete(l.usage, key)
+	return ok
+}
+
+// status 获取密钥的限额和剩余用量，密钥没有限额时返回false
+func (l *rateLimiter) status(key string) (quotaStatus, bool) {
+	limit, ok := l.limitFor(key)
+	if !ok {
+		return quotaStatus{}, false
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	return l.statusLocked(limit, l.usageLocked(key, now), now), true
+}
+
+// setRateLimitHeaders 设置OpenAI风格的 x-ratelimit-* 响应头
+func setRateLimitHeaders(header http.Header, status quotaStatus) {
+	if status.RemainingRequests != nil {
+		header.Set("x-ratelimit-limit-requests", strconv.Itoa(status.RequestsPerMinute))
+		header.Set("x-ratelimit-remaining-requests", strconv.Itoa(*status.RemainingRequests))
+		header.Set("x-ratelimit-reset-requests", status.requestsResetAfter.Round(time.Second).String())
+	}
+	if status.RemainingTokens != nil {
+		header.Set("x-ratelimit-limit-tokens", strconv.Itoa(status.TokensPerDay))
+		header.Set("x-ratelimit-remaining-tokens", strconv.Itoa(*status.RemainingTokens))
+		header.Set("x-ratelimit-reset-tokens", status.tokensResetAfter.Round(time.Second).String())
+	}
+}
+
+// setQuotaHeaders 设置 X-Quota-Remaining-* 响应头，告知客户端当前密钥的剩余请求数和今日剩余token
+func setQuotaHeaders(header http.Header, status quotaStatus) {
+	if status.RemainingRequests != nil {
+		header.Set("X-Quota-Remaining-Requests", strconv.Itoa(*status.RemainingRequests))
+	}
+	if status.RemainingTokens != nil {
+		header.Set("X-Quota-Remaining-Tokens", strconv.Itoa(*status.RemainingTokens))
+	}
+}
+
+// 限流中间件，按已认证的API密钥限制推理请求（POST）的速率和每日token用量
+// 有限额的密钥的所有响应都带剩余用量头部，便于客户端主动降速
+func (s *Server) rateLimitMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := requestAPIKey(r)
+		if s.limiter == nil || key == "" || strings.HasPrefix(r.URL.Path, "/admin/") {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if r.Method != http.MethodPost {
+			if status, ok := s.limiter.status(key); ok {
+				setQuotaHeaders(w.Header(), status)
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		status, exceeded, ok := s.limiter.allow(key)
+		setRateLimitHeaders(w.Header(), status)
+		setQuotaHeaders(w.Header(), status)
+		if !ok {
+			s.writeRateLimitError(w, status, exceeded)
+			return
+		}
+
+		r, trace := s.withStreamTrace(r)
+		next.ServeHTTP(w, r)
+		s.limiter.addTokens(key, int(trace.UsageSnapshot().TotalTokenCount))
+	})
+}
+
+// writeRateLimitError 以OpenAI错误格式返回429
+func (s *Server) writeRateLimitError(w http.ResponseWriter, status quotaStatus, exceeded string) {
+	message := fmt.Sprintf("Rate limit reached: %d requests per minute. Please try again in %s.",
+		status.RequestsPerMinute, status.requestsResetAfter.Round(time.Second))
+	retryAfter := status.requestsResetAfter
+	if exceeded == "tokens" {
+		message = fmt.Sprintf("Daily token quota exceeded: used %d of %d tokens. The quota resets at %s.",
+			status.TokensUsedToday, status.TokensPerDay, status.TokensResetAt)
+		retryAfter = status.tokensResetAfter
+	}
+	s.logger.Warnf("Rate limited API key: %s", message)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Retry-After", strconv.Itoa(max(int(retryAfter.Seconds()+0.5), 1)))
+	w.WriteHeader(http.StatusTooManyRequests)
+	json.NewEncoder(w).Encode(map[string]any{
+		"error": map[string]any{
+			"message": message,
+			"type":    exceeded,
+			"param":   nil,
+			"code":    "rate_limit_exceeded",
+		},
+	})
+}
+
+// handleUsage 返回当前API密钥的限额和剩余用量
+func (s *Server) handleUsage(w http.ResponseWriter, r *http.Request) {
+	response := map[string]any{
+		"object":  "usage",
+		"limited": false,
+	}
+	if s.limiter != nil {
+		if status, ok := s.limiter.status(requestAPIKey(r)); ok {
+			setRateLimitHeaders(w.Header(), status)
+			response["limited"] = true
+			response["quota"] = status
+		}
+	}
+	s.writeJSONResponse(w, response)
+}
@@ -0,0 +1,125 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ba0gu0/gemini-go-proxy/pkg/store"
+)
+
+// defaultRequestLogLimit /admin/requests 默认返回的记录数
+const defaultRequestLogLimit = 100
+
+// SetRequestLog 设置请求记录存储，nil表示不记录
+func (s *Server) SetRequestLog(requestLog *store.Store) {
+	s.requests = requestLog
+}
+
+// 请求记录中间件，保存每个请求的调用方、模型、token用量、耗时和状态码，用于按密钥统计用量
+func (s *Server) requestLogMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.requests == nil || r.URL.Path == "/health" || r.URL.Path == "/ready" ||
+			strings.HasPrefix(r.URL.Path, "/admin/") || strings.HasPrefix(r.URL.Path, "/oauth/") {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		start := time.Now()
+		r, trace := s.withStreamTrace(r)
+		rw := &responseWriter{ResponseWriter: w, statusCode: http.StatusOK}
+		next.ServeHTTP(rw, r)
+
+		usage := trace.UsageSnapshot()
+		record := store.Record{
+			Time:             start.UTC(),
+			Key:              callerID(requestAPIKey(r)),
+			Model:            trace.ModelSnapshot(),
+			Method:           r.Method,
+			Path:             r.URL.Path,
+			Status:           rw.statusCode,
+			LatencyMs:        time.Since(start).Milliseconds(),
+			PromptTokens:     usage.PromptTokenCount,
+			CompletionTokens: usage.CandidatesTokenCount,
+			TotalTokens:      usage.TotalTokenCount,
+		}
+		if err := s.requests.Append(record); err != nil {
+			s.logger.WithError(err).Error("Failed to write request record")
+		}
+	})
+}
+
+// requestLogQuery 从查询参数解析请求记录查询条件：key（API密钥或调用方标识）、model、since、until（RFC3339）
+func requestLogQuery(r *http.Request) (store.Query, error) {
+	values := r.URL.Query()
+	query := store.Query{Model: values.Get("model")}
+	if key := values.Get("key"); key != "" {
+		query.Key = key
+		if !strings.HasPrefix(key, "key:") {
+			query.Key = callerID(key)
+		}
+	}
+	for name, target := range map[string]*time.Time{"since": &query.Since, "until": &query.Until} {
+		if value := values.Get(name); value != "" {
+			parsed, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				return query, err
+			}
+			*target = parsed
+		}
+	}
+	return query, nil
+}
+
+// handleRequestLog 查询请求记录，按时间从新到旧返回
+func (s *Server) handleRequestLog(w http.ResponseWriter, r *http.Request) {
+	query, ok := s.adminRequestLogQuery(w, r)
+	if !ok {
+		return
+	}
+	query.Limit = defaultRequestLogLimit
+	if limit, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && limit > 0 {
+		query.Limit = limit
+	}
+
+	records, err := s.requests.Query(query)
+	if err != nil {
+		s.writeErrorResponse(w, http.StatusInternalServerError, "api_error", err.Error())
+		return
+	}
+	s.writeJSONResponse(w, map[string]any{"object": "list", "data": records})
+}
+
+// handleUsageReport 按调用方和模型汇总请求数、错误数、token用量和平均耗时
+func (s *Server) handleUsageReport(w http.ResponseWriter, r *http.Request) {
+	query, ok := s.adminRequestLogQuery(w, r)
+	if !ok {
+		return
+	}
+
+	summary, err := s.requests.Summarize(query)
+	if err != nil {
+		s.writeErrorResponse(w, http.StatusInternalServerError, "api_error", err.Error())
+		return
+	}
+	s.writeJSONResponse(w, map[string]any{"object": "list", "data": summary})
+}
+
+// adminRequestLogQuery 检查管理员权限和请求记录是否启用，并解析查询条件
+func (s *Server) adminRequestLogQuery(w http.ResponseWriter, r *http.Request) (store.Query, bool) {
+	if !s.isAdminRequest(r) {
+		s.writeErrorResponse(w, http.StatusForbidden, "forbidden", "Admin API key required")
+		return store.Query{}, false
+	}
+	if s.requests == nil {
+		s.writeErrorResponse(w, http.StatusNotFound, "not_found", "Request log is not enabled")
+		return store.Query{}, false
+	}
+	query, err := requestLogQuery(r)
+	if err != nil {
+		s.writeErrorResponse(w, http.StatusBadRequest, "invalid_request", "since and until must be RFC3339 timestamps")
+		return store.Query{}, false
+	}
+	return query, true
+}
@@ -0,0 +1,304 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+// StreamResumeTokenHeader 可续传的流式响应通过该响应头返回续传令牌
+const StreamResumeTokenHeader = "X-Stream-Resume-Token"
+
+// defaultStreamResumeTTL 流结束后默认的可续传时间
+const defaultStreamResumeTTL = 5 * time.Minute
+
+// streamRegistry 保存可续传的流式响应，流结束超过ttl后删除
+type streamRegistry struct {
+	mu      sync.Mutex
+	streams map[string]*resumableStream
+	buffer  int
+	ttl     time.Duration
+	now     func() time.Time
+}
+
+// newStreamRegistry 创建流注册表，buffer为每个流保留的事件数，不大于0时返回nil
+func newStreamRegistry(buffer int, ttl time.Duration) *streamRegistry {
+	if buffer <= 0 {
+		return nil
+	}
+	if ttl <= 0 {
+		ttl = defaultStreamResumeTTL
+	}
+	return &streamRegistry{
+		streams: make(map[string]*resumableStream),
+		buffer:  buffer,
+		ttl:     ttl,
+		now:     time.Now,
+	}
+}
+
+// create 为调用方创建新的可续传流，返回续传令牌
+func (sr *streamRegistry) create(owner string) (string, *resumableStream) {
+	sr.mu.Lock()
+	defer sr.mu.Unlock()
+	sr.sweepLocked()
+
+	token := uuid.New().String()
+	stream := &resumableStream{
+		owner:  owner,
+		limit:  sr.buffer,
+		now:    sr.now,
+		header: make(http.Header),
+		notify: make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	stream.drained = sync.NewCond(&stream.mu)
+	sr.streams[token] = stream
+	return token, stream
+}
+
+// get 查找调用方的流，令牌不存在、已过期或属于其他调用方时返回false
+func (sr *streamRegistry) get(token, owner string) (*resumableStream, bool) {
+	sr.mu.Lock()
+	defer sr.mu.Unlock()
+	sr.sweepLocked()
+
+	stream, ok := sr.streams[token]
+	if !ok || stream.owner != owner {
+		return nil, false
+	}
+	return stream, true
+}
+
+// sweepLocked 删除结束超过ttl的流
+func (sr *streamRegistry) sweepLocked() {
+	now := sr.now()
+	for token, stream := range sr.streams {
+		if finished, ok := stream.finishedAt(); ok && now.Sub(finished) > sr.ttl {
+			delete(sr.streams, token)
+		}
+	}
+}
+
+// streamEvent 缓冲的SSE事件，data为一次刷新写入的完整内容
+type streamEvent struct {
+	id   int64
+	data []byte
+}
+
+// resumableStream 与客户端连接解耦的流式响应：生成端把它当作 http.ResponseWriter 写入，
+// 每次 Flush 形成一个事件，只保留最近 limit 个事件；客户端断开后可凭令牌和最后收到的事件ID续传。
+// 有客户端连接时未投递的事件达到 limit 后生成端等待投递，断开后继续生成并丢弃最早的事件
+type resumableStream struct {
+	owner  string
+	limit  int
+	now    func() time.Time
+	header http.Header // 生成端设置的头部（如trailer），不会发送给客户端
+
+	mu        sync.Mutex
+	drained   *sync.Cond // 投递进度变化或客户端断开时通知生成端
+	pending   []byte
+	events    []streamEvent
+	lastID    int64
+	delivered int64 // 已写给客户端的最大事件ID
+	attached  int   // 正在投递的客户端连接数
+	finished  time.Time
+	notify    chan struct{} // 有新事件或流结束时关闭并替换
+	done      chan struct{} // 流结束时关闭
+}
+
+// Header 返回生成端的头部
+func (rs *resumableStream) Header() http.Header {
+	return rs.header
+}
+
+// WriteHeader 状态码由投递端发送，这里忽略
+func (rs *resumableStream) WriteHeader(int) {}
+
+// Write 写入待刷新的数据
+func (rs *resumableStream) Write(p []byte) (int, error) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	rs.pending = append(rs.pending, p...)
+	return len(p), nil
+}
+
+// Flush 将待刷新的数据作为一个事件加入缓冲区，超出上限时丢弃最早的事件
+func (rs *resumableStream) Flush() {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	rs.flushLocked()
+}
+
+// flushLocked 加入事件并唤醒等待的投递端
+func (rs *resumableStream) flushLocked() {
+	if len(rs.pending) == 0 {
+		return
+	}
+	for rs.attached > 0 && rs.lastID-rs.delivered >= int64(rs.limit) {
+		rs.drained.Wait()
+	}
+	rs.lastID++
+	rs.events = append(rs.events, streamEvent{id: rs.lastID, data: rs.pending})
+	rs.pending = nil
+	if len(rs.events) > rs.limit {
+		rs.events = slices.Delete(rs.events, 0, len(rs.events)-rs.limit)
+	}
+	close(rs.notify)
+	rs.notify = make(chan struct{})
+}
+
+// finish 结束流，未刷新的数据作为最后一个事件
+func (rs *resumableStream) finish() {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	rs.flushLocked()
+	rs.finished = rs.now()
+	close(rs.done)
+	close(rs.notify)
+	rs.notify = make(chan struct{})
+}
+
+// attach 登记正在投递的客户端连接，返回的函数在连接结束时调用
+func (rs *resumableStream) attach() func() {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	rs.attached++
+	return func() {
+		rs.mu.Lock()
+		defer rs.mu.Unlock()
+		rs.attached--
+		rs.drained.Broadcast()
+	}
+}
+
+// markDelivered 记录已写给客户端的事件ID
+func (rs *resumableStream) markDelivered(id int64) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	if id > rs.delivered {
+		rs.delivered = id
+		rs.drained.Broadcast()
+	}
+}
+
+// copyTrailers 将生成端设置的trailer写入客户端响应的头部，只在流结束后调用
+func (rs *resumableStream) copyTrailers(dst http.Header) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	for key, values := range rs.header {
+		if name, ok := strings.CutPrefix(key, http.TrailerPrefix); ok {
+			dst[http.CanonicalHeaderKey(name)] = values
+		}
+	}
+}
+
+// finishedAt 返回流的结束时间，仍在生成时返回false
+func (rs *resumableStream) finishedAt() (time.Time, bool) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	return rs.finished, !rs.finished.IsZero()
+}
+
+// since 返回ID大于 after 的已缓冲事件、流是否已结束，以及等待后续事件的通道；
+// after 之后的事件已被丢弃时 ok 为false
+func (rs *resumableStream) since(after int64) (events []streamEvent, finished bool, wait <-chan struct{}, ok bool) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	if after < 0 || after > rs.lastID || (len(rs.events) > 0 && rs.events[0].id > after+1) {
+		return nil, false, nil, false
+	}
+	for _, event := range rs.events {
+		if event.id > after {
+			events = append(events, event)
+		}
+	}
+	return events, !rs.finished.IsZero(), rs.notify, true
+}
+
+// deliverStream 将流中ID大于 after 的事件带上SSE id 字段发送给客户端，直到流结束或客户端断开；
+// 流结束时把生成端设置的trailer发送给客户端。调用方需先通过 attach 登记连接
+func (s *Server) deliverStream(w http.ResponseWriter, r *http.Request, stream *resumableStream, after int64) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		s.writeErrorResponse(w, http.StatusInternalServerError, "streaming_error", "Streaming not supported")
+		return
+	}
+	// 生成端写入的是缓冲区，trailer需要在客户端响应上声明
+	for _, name := range streamTrailers {
+		w.Header().Add("Trailer", name)
+	}
+	w.WriteHeader(http.StatusOK)
+
+	for {
+		events, finished, wait, ok := stream.since(after)
+		if !ok {
+			// 续传的起点在缓冲区之前，未送达的事件已被丢弃
+			fmt.Fprintf(w, "data: {\"error\":{\"type\":\"stream_expired\",\"message\":\"Buffered stream events are no longer available\"}}\n\n")
+			flusher.Flush()
+			return
+		}
+		for _, event := range events {
+			if _, err := fmt.Fprintf(w, "id: %d\n%s", event.id, event.data); err != nil {
+				return
+			}
+			after = event.id
+		}
+		flusher.Flush()
+		stream.markDelivered(after)
+		if finished {
+			stream.copyTrailers(w.Header())
+			return
+		}
+
+		select {
+		case <-wait:
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// 凭续传令牌重新连接OpenAI流式响应，Last-Event-ID（或 last_event_id 参数）为最后收到的事件ID
+func (s *Server) handleStreamResume(w http.ResponseWriter, r *http.Request) {
+	if s.streams == nil {
+		s.writeErrorResponse(w, http.StatusServiceUnavailable, "unavailable", "Stream resumption is not enabled")
+		return
+	}
+
+	stream, ok := s.streams.get(mux.Vars(r)["token"], callerID(requestAPIKey(r)))
+	if !ok {
+		s.writeErrorResponse(w, http.StatusNotFound, "not_found", "Stream not found or expired")
+		return
+	}
+
+	lastEventID := r.Header.Get("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = r.URL.Query().Get("last_event_id")
+	}
+	var after int64
+	if lastEventID != "" {
+		var err error
+		if after, err = strconv.ParseInt(lastEventID, 10, 64); err != nil {
+			s.writeErrorResponse(w, http.StatusBadRequest, "invalid_request_error", "Invalid Last-Event-ID")
+			return
+		}
+	}
+	if _, _, _, ok := stream.since(after); !ok {
+		s.writeErrorResponse(w, http.StatusGone, "stream_expired", "Buffered stream events after Last-Event-ID are no longer available")
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	defer stream.attach()()
+	s.deliverStream(w, r, stream, after)
+}
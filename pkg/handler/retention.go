@@ -0,0 +1,58 @@
+package handler
+
+import (
+	"net/http"
+)
+
+// handleDeleteData 处理按密钥的数据删除请求：删除该密钥的用量记录、请求记录和异步任务，并匿名化审计记录
+// key 为调用方使用的API密钥，HMAC调用方使用 "hmac:<密钥ID>"
+func (s *Server) handleDeleteData(w http.ResponseWriter, r *http.Request) {
+	if !s.isAdminRequest(r) {
+		s.writeErrorResponse(w, http.StatusForbidden, "forbidden", "Admin API key required")
+		return
+	}
+	key := r.URL.Query().Get("key")
+	if key == "" {
+		s.writeErrorResponse(w, http.StatusBadRequest, "invalid_request", "Request must include the key query parameter")
+		return
+	}
+
+	caller := callerID(key)
+	response := map[string]any{
+		"object": "data.deleted",
+		"caller": caller,
+	}
+	if s.limiter != nil {
+		response["usage_deleted"] = s.limiter.forget(key)
+	}
+	if s.jobs != nil {
+		removed, err := s.jobs.DeleteOwner(caller)
+		if err != nil {
+			s.logger.WithError(err).Error("Failed to delete async jobs")
+			s.writeErrorResponse(w, http.StatusInternalServerError, "api_error", err.Error())
+			return
+		}
+		response["jobs_deleted"] = removed
+	}
+	if s.requests != nil {
+		removed, err := s.requests.DeleteKey(caller)
+		if err != nil {
+			s.logger.WithError(err).Error("Failed to delete request records")
+			s.writeErrorResponse(w, http.StatusInternalServerError, "api_error", err.Error())
+			return
+		}
+		response["requests_deleted"] = removed
+	}
+	if s.audit != nil {
+		erased, err := s.audit.Erase(caller)
+		if err != nil {
+			s.logger.WithError(err).Error("Failed to erase audit log records")
+			s.writeErrorResponse(w, http.StatusInternalServerError, "api_error", err.Error())
+			return
+		}
+		response["audit_records_erased"] = erased
+	}
+
+	s.logger.Infof("Deleted stored data for caller %s", caller)
+	s.writeJSONResponse(w, response)
+}
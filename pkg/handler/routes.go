@@ -0,0 +1,58 @@
+package handler
+
+import (
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// 可通过 disabled_routes 整体关闭的路由组
+const (
+	RouteGroupOpenAI   = "openai"   // OpenAI兼容接口：/v1/chat/completions、/v1/embeddings 等
+	RouteGroupGemini   = "gemini"   // Gemini原生接口：/v1beta/... 和 /gemini/v1/...
+	RouteGroupVertex   = "vertex"   // Vertex AI接口及调优任务透传：/vertex/v1/...
+	RouteGroupModels   = "models"   // 模型列表：/v1/models、/v1beta/models、/gemini/v1/models
+	RouteGroupAsync    = "async"    // 异步请求接口：/v1/async/...
+	RouteGroupTokenize = "tokenize" // 工具接口：/utils/tokenize、/utils/estimate
+)
+
+// RouteGroups 返回所有可关闭的路由组
+func RouteGroups() []string {
+	return []string{RouteGroupOpenAI, RouteGroupGemini, RouteGroupVertex, RouteGroupModels, RouteGroupAsync, RouteGroupTokenize}
+}
+
+// parseDisabledRoutes 解析要关闭的路由组，忽略未知的组名
+func parseDisabledRoutes(entries []string, logger *logrus.Logger) map[string]bool {
+	known := make(map[string]bool)
+	for _, group := range RouteGroups() {
+		known[group] = true
+	}
+
+	disabled := make(map[string]bool)
+	var names []string
+	for _, entry := range entries {
+		group := strings.ToLower(strings.TrimSpace(entry))
+		if !known[group] {
+			logger.Warnf("Ignoring unknown route group in disabled_routes: %s (supported: %s)", entry, strings.Join(RouteGroups(), ", "))
+			continue
+		}
+		if !disabled[group] {
+			disabled[group] = true
+			names = append(names, group)
+		}
+	}
+	if len(names) > 0 {
+		logger.Infof("Disabled route groups: %s", strings.Join(names, ", "))
+	}
+	return disabled
+}
+
+// routeEnabled 给定的路由组是否都未被关闭
+func (s *Server) routeEnabled(groups ...string) bool {
+	for _, group := range groups {
+		if s.disabledRoutes[group] {
+			return false
+		}
+	}
+	return true
+}
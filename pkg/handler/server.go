@@ -3,25 +3,56 @@ package handler
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"slices"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/ba0gu0/gemini-go-proxy/pkg/audit"
+	"github.com/ba0gu0/gemini-go-proxy/pkg/auth"
 	"github.com/ba0gu0/gemini-go-proxy/pkg/client"
+	"github.com/ba0gu0/gemini-go-proxy/pkg/cluster"
+	"github.com/ba0gu0/gemini-go-proxy/pkg/config"
+	"github.com/ba0gu0/gemini-go-proxy/pkg/jobs"
 	"github.com/ba0gu0/gemini-go-proxy/pkg/models"
+	"github.com/ba0gu0/gemini-go-proxy/pkg/store"
 	"github.com/gorilla/mux"
 	"github.com/sirupsen/logrus"
 )
 
 // Server Gemini代理服务器
 type Server struct {
-	router     *mux.Router
-	client     *client.GeminiClient
-	logger     *logrus.Logger
-	config     *ServerConfig
-	oauthAuth  any // GoogleAuth 接口，避免循环导入
+	router    *mux.Router
+	client    *client.GeminiClient
+	logger    *logrus.Logger
+	config    *ServerConfig
+	oauthAuth any // GoogleAuth 接口，避免循环导入
+	jobs      *jobs.Queue
+	webhooks  *jobs.WebhookSender    // 投递异步请求结果的受限webhook投递器
+	native    *httputil.ReverseProxy // 原生路由反向代理，未启用时为nil
+	cluster   *cluster.Router        // 前置路由模式的路由器，普通模式为nil
+
+	trustedProxies  []*net.IPNet
+	disabledRoutes  map[string]bool                  // 被关闭的路由组
+	responseHeaders http.Header                      // 添加到每个响应的静态头部
+	signatures      *auth.SignatureVerifier          // 未配置HMAC密钥时为nil
+	limiter         *rateLimiter                     // 未配置限额时为nil
+	audit           *audit.Logger                    // 未启用审计日志时为nil
+	requests        *store.Store                     // 未启用请求记录时为nil
+	streams         *streamRegistry                  // 未启用流式续传时为nil
+	keysMu          sync.RWMutex                     // 保护config中的API密钥列表，支持运行时更新
+	ready           atomic.Bool                      // 预热完成前为false
+	draining        atomic.Bool                      // 正在关闭，不再接收新流量
+	maintenance     atomic.Pointer[MaintenanceState] // 维护模式状态，未开启时为nil
 }
 
 // ServerConfig 服务器配置
@@ -32,10 +63,41 @@ type ServerConfig struct {
 	WriteTimeout time.Duration `json:"write_timeout"`
 	EnableCORS   bool          `json:"enable_cors"`
 	APIKeys      []string      `json:"api_keys,omitempty"`
+	AdminAPIKeys []string      `json:"admin_api_keys,omitempty"` // 管理员密钥，允许使用调试回显
+	// 流式响应结束时额外发送 event: metadata SSE事件
+	StreamMetadataEvent bool `json:"stream_metadata_event,omitempty"`
+	// 原生Gemini路由使用反向代理直接转发，不做完整解码/编码
+	NativeReverseProxy bool `json:"native_reverse_proxy,omitempty"`
+	// 可信代理CIDR列表，来自这些地址的请求才采信 X-Forwarded-For / X-Real-IP
+	TrustedProxies []string `json:"trusted_proxies,omitempty"`
+	// HMAC签名密钥（密钥ID -> 共享密钥）及重放窗口
+	HMACKeys         map[string]string `json:"hmac_keys,omitempty"`
+	HMACReplayWindow time.Duration     `json:"hmac_replay_window,omitempty"`
+	HMACMaxBodyBytes int64             `json:"hmac_max_body_bytes,omitempty"` // 签名请求的请求体上限，默认32MiB
+	// 按API密钥强制的回复语言（密钥 -> 语言标签）
+	KeyResponseLanguages map[string]string `json:"key_response_languages,omitempty"`
+	// 按API密钥的请求速率和每日token限额，"*" 为默认限额
+	KeyRateLimits map[string]config.RateLimit `json:"key_rate_limits,omitempty"`
+	// 整体关闭的路由组（openai、gemini、vertex、models、async、tokenize）
+	DisabledRoutes []string `json:"disabled_routes,omitempty"`
+	// 添加到每个响应的静态头部（头部名称 -> 值）
+	ResponseHeaders map[string]string `json:"response_headers,omitempty"`
+	// OpenAI流式响应断线续传：每个流保留的事件数（0表示不启用）及结束后保留时间（默认5分钟）
+	StreamResumeBuffer int           `json:"stream_resume_buffer,omitempty"`
+	StreamResumeTTL    time.Duration `json:"stream_resume_ttl,omitempty"`
+	// OpenAI兼容接口的模型别名（别名 -> 实际模型名）
+	ModelMappings map[string]string `json:"model_mappings,omitempty"`
 }
 
 // NewServer 创建新的服务器实例
 func NewServer(geminiClient *client.GeminiClient, config *ServerConfig, logger *logrus.Logger) *Server {
+	s := newServer(geminiClient, config, logger)
+	s.setupRoutes()
+	return s
+}
+
+// newServer 创建未注册路由的服务器实例
+func newServer(geminiClient *client.GeminiClient, config *ServerConfig, logger *logrus.Logger) *Server {
 	if config == nil {
 		config = &ServerConfig{
 			Host:         "localhost",
@@ -57,36 +119,146 @@ func NewServer(geminiClient *client.GeminiClient, config *ServerConfig, logger *
 		config: config,
 	}
 
-	s.setupRoutes()
+	s.trustedProxies = parseTrustedProxies(config.TrustedProxies, logger)
+	s.disabledRoutes = parseDisabledRoutes(config.DisabledRoutes, logger)
+	s.responseHeaders = parseResponseHeaders(config.ResponseHeaders, logger)
+	s.limiter = newRateLimiter(config.KeyRateLimits)
+	s.streams = newStreamRegistry(config.StreamResumeBuffer, config.StreamResumeTTL)
+	if len(config.HMACKeys) > 0 {
+		s.signatures = auth.NewSignatureVerifier(config.HMACKeys, config.HMACReplayWindow, config.HMACMaxBodyBytes)
+	}
+
+	if config.NativeReverseProxy && geminiClient != nil {
+		s.native = geminiClient.NativeReverseProxy()
+	}
+
+	s.ready.Store(true)
 	return s
 }
 
+// streamBufferPool 流式转发使用的4KB缓冲区池
+var streamBufferPool = sync.Pool{
+	New: func() any {
+		buffer := make([]byte, 4096)
+		return &buffer
+	},
+}
+
+// modelResourcePattern 匹配调优模型和完整资源路径形式的模型标识
+const modelResourcePattern = `(?:tunedModels|projects)/[^:]+`
+
 // 设置路由
 func (s *Server) setupRoutes() {
 	// 健康检查端点 - 在中间件之前设置，避免认证问题
 	s.router.HandleFunc("/health", s.handleHealth).Methods("GET")
+	s.router.HandleFunc("/ready", s.handleReady).Methods("GET")
 
 	// 中间件
+	s.router.Use(s.responseHeadersMiddleware)
+	if s.responseHeaders != nil {
+		// 未匹配任何路由的请求不经过中间件，404响应单独添加头部
+		s.router.NotFoundHandler = s.responseHeadersMiddleware(http.NotFoundHandler())
+	}
 	s.router.Use(s.loggingMiddleware)
+	s.router.Use(s.auditMiddleware)
 	s.router.Use(s.corsMiddleware)
 	s.router.Use(s.authMiddleware)
+	s.router.Use(s.requestLogMiddleware)
+	s.router.Use(s.maintenanceMiddleware)
+	s.router.Use(s.reauthMiddleware)
+	s.router.Use(s.dailyQuotaMiddleware)
+	s.router.Use(s.responseLanguageMiddleware)
+	s.router.Use(s.debugMiddleware)
+	s.router.Use(s.rateLimitMiddleware)
+	s.router.Use(s.vertexHeadersMiddleware)
+	s.router.Use(s.dataRegionMiddleware)
+	s.router.Use(s.cacheControlMiddleware)
 
 	// OpenAI兼容接口
-	s.router.HandleFunc("/v1/models", s.handleModels).Methods("GET")
-	s.router.HandleFunc("/v1/chat/completions", s.handleChatCompletions).Methods("POST")
+	if s.routeEnabled(RouteGroupOpenAI) {
+		if s.routeEnabled(RouteGroupModels) {
+			s.router.HandleFunc("/v1/models", s.handleModels).Methods("GET")
+		}
+		s.router.HandleFunc("/v1/chat/completions", s.handleChatCompletions).Methods("POST")
+		s.router.HandleFunc("/v1/chat/completions:parallel", s.handleParallelChatCompletions).Methods("POST")
+		s.router.HandleFunc("/v1/chat/completions/streams/{token}", s.handleStreamResume).Methods("GET")
+		s.router.HandleFunc("/v1/embeddings", s.handleEmbeddings).Methods("POST")
+		s.router.HandleFunc("/v1/images/generations", s.handleImageGenerations).Methods("POST")
+		s.router.HandleFunc("/v1/audio/speech", s.handleAudioSpeech).Methods("POST")
+		s.router.HandleFunc("/v1/audio/transcriptions", s.handleAudioTranscriptions).Methods("POST")
+		s.router.HandleFunc("/v1/usage", s.handleUsage).Methods("GET")
+	}
+
+	// 异步请求接口
+	if s.routeEnabled(RouteGroupAsync) {
+		s.router.HandleFunc("/v1/async/chat/completions", s.handleAsyncChatCompletions).Methods("POST")
+		s.router.HandleFunc("/v1/async/{id}", s.handleAsyncJob).Methods("GET")
+	}
+
+	if s.routeEnabled(RouteGroupGemini) {
+		// Gemini原生接口 - v1beta标准路径
+		if s.routeEnabled(RouteGroupModels) {
+			s.router.HandleFunc("/v1beta/models", s.handleGeminiModels).Methods("GET")
+			s.router.HandleFunc("/v1beta/models/{model}", s.handleGeminiModel).Methods("GET")
+			s.router.HandleFunc("/gemini/v1/models", s.handleGeminiModels).Methods("GET")
+			s.router.HandleFunc("/gemini/v1/models/{model}", s.handleGeminiModel).Methods("GET")
+		}
+		s.router.HandleFunc("/v1beta/models/{model}:generateContent", s.handleGeminiGenerate).Methods("POST")
+		s.router.HandleFunc("/v1beta/models/{model}:streamGenerateContent", s.handleGeminiStreamGenerate).Methods("POST")
+		s.router.HandleFunc("/v1beta/models/{model}:embedContent", s.handleGeminiEmbedContent).Methods("POST")
+		s.router.HandleFunc("/v1beta/models/{model}:batchEmbedContents", s.handleGeminiBatchEmbedContents).Methods("POST")
+		s.router.HandleFunc("/v1beta/models/{model}:countTokens", s.handleGeminiCountTokens).Methods("POST")
+		s.router.HandleFunc("/v1beta/models/{model}:predict", s.handleGeminiPredict).Methods("POST")
+
+		// Gemini原生接口 - 调优模型和完整资源路径 (tunedModels/... 或 projects/.../models/...)
+		s.router.HandleFunc("/v1beta/{model:"+modelResourcePattern+"}:generateContent", s.handleGeminiGenerate).Methods("POST")
+		s.router.HandleFunc("/v1beta/{model:"+modelResourcePattern+"}:streamGenerateContent", s.handleGeminiStreamGenerate).Methods("POST")
+
+		// Gemini原生接口 - 自定义路径（保持兼容性）
+		s.router.HandleFunc("/gemini/v1/models/{model}/generateContent", s.handleGeminiGenerate).Methods("POST")
+		s.router.HandleFunc("/gemini/v1/models/{model}/streamGenerateContent", s.handleGeminiStreamGenerate).Methods("POST")
+		s.router.HandleFunc("/gemini/v1/{model:"+modelResourcePattern+"}/generateContent", s.handleGeminiGenerate).Methods("POST")
+		s.router.HandleFunc("/gemini/v1/{model:"+modelResourcePattern+"}/streamGenerateContent", s.handleGeminiStreamGenerate).Methods("POST")
+
+		// Files API文件上传（含可续传上传的后续分块）
+		s.router.PathPrefix("/upload/v1beta/").HandlerFunc(s.handleGeminiUpload)
+
+		// 其他 /v1beta 路径（cachedContents、files、tunedModels等）注入认证后透传到上游，须在具体路由之后注册
+		s.router.PathPrefix("/v1beta/").HandlerFunc(s.handleGeminiPassthrough)
+	}
 
-	// Gemini原生接口 - v1beta标准路径
-	s.router.HandleFunc("/v1beta/models", s.handleGeminiModels).Methods("GET")
-	s.router.HandleFunc("/v1beta/models/{model}:generateContent", s.handleGeminiGenerate).Methods("POST")
-	s.router.HandleFunc("/v1beta/models/{model}:streamGenerateContent", s.handleGeminiStreamGenerate).Methods("POST")
+	// 工具接口
+	if s.routeEnabled(RouteGroupTokenize) {
+		s.router.HandleFunc("/utils/tokenize", s.handleTokenize).Methods("POST")
+		s.router.HandleFunc("/utils/estimate", s.handleEstimate).Methods("POST")
+	}
 
-	// Gemini原生接口 - 自定义路径（保持兼容性）
-	s.router.HandleFunc("/gemini/v1/models", s.handleGeminiModels).Methods("GET")
-	s.router.HandleFunc("/gemini/v1/models/{model}/generateContent", s.handleGeminiGenerate).Methods("POST")
-	s.router.HandleFunc("/gemini/v1/models/{model}/streamGenerateContent", s.handleGeminiStreamGenerate).Methods("POST")
+	if s.routeEnabled(RouteGroupVertex) {
+		// Vertex AI接口
+		s.router.HandleFunc("/vertex/v1/projects/{project}/locations/{location}/publishers/google/models/{model}:generateContent", s.handleVertexGenerate).Methods("POST")
+		s.router.HandleFunc("/vertex/v1/projects/{project}/locations/{location}/models/{model}:generateContent", s.handleVertexGenerate).Methods("POST")
+		s.router.HandleFunc("/vertex/v1/projects/{project}/locations/{location}/publishers/google/models/{model}:predict", s.handleVertexPredict).Methods("POST")
+		s.router.HandleFunc("/vertex/v1/projects/{project}/locations/{location}/models/{model}:predict", s.handleVertexPredict).Methods("POST")
+
+		// Vertex AI调优任务及长时间运行操作透传
+		s.router.HandleFunc("/vertex/v1/projects/{project}/locations/{location}/tuningJobs", s.handleVertexPassthrough).Methods("GET", "POST")
+		s.router.HandleFunc("/vertex/v1/projects/{project}/locations/{location}/tuningJobs/{job}", s.handleVertexPassthrough).Methods("GET", "POST")
+		s.router.HandleFunc("/vertex/v1/projects/{project}/locations/{location}/tuningJobs/{job}/operations/{operation}", s.handleVertexPassthrough).Methods("GET")
+		s.router.HandleFunc("/vertex/v1/projects/{project}/locations/{location}/operations/{operation}", s.handleVertexPassthrough).Methods("GET")
+	}
 
-	// Vertex AI接口
-	s.router.HandleFunc("/vertex/v1/projects/{project}/locations/{location}/publishers/google/models/{model}:generateContent", s.handleVertexGenerate).Methods("POST")
+	// 数据删除和请求记录查询接口，需要管理员密钥
+	s.router.HandleFunc("/admin/data", s.handleDeleteData).Methods("DELETE")
+	s.router.HandleFunc("/admin/requests", s.handleRequestLog).Methods("GET")
+	s.router.HandleFunc("/admin/usage", s.handleUsageReport).Methods("GET")
+	s.router.HandleFunc("/admin/maintenance", s.handleMaintenance).Methods("GET", "POST")
+	s.router.HandleFunc("/admin/pipelines", s.handlePipelines).Methods("GET")
+	s.router.HandleFunc("/admin/pipelines/{name}/versions/{version}", s.handlePutPipelineVersion).Methods("PUT")
+	s.router.HandleFunc("/admin/pipelines/{name}/rollout", s.handlePipelineRollout).Methods("PUT")
+	s.router.HandleFunc("/admin/pipelines/{name}/rollback", s.handlePipelineRollback).Methods("POST")
+	s.router.HandleFunc("/admin/accounts", s.handleAccounts).Methods("GET")
+	s.router.HandleFunc("/admin/accounts", s.handleAddAccount).Methods("POST")
+	s.router.HandleFunc("/admin/accounts/{id}", s.handleRemoveAccount).Methods("DELETE")
 }
 
 // 日志中间件
@@ -111,7 +283,7 @@ func (s *Server) loggingMiddleware(next http.Handler) http.Handler {
 			"url":         r.URL.Path,
 			"status":      rw.statusCode,
 			"duration":    time.Since(start),
-			"remote_addr": r.RemoteAddr,
+			"remote_addr": s.clientIP(r),
 			"user_agent":  r.Header.Get("User-Agent"),
 		}).Info("HTTP request")
 	})
@@ -123,7 +295,8 @@ func (s *Server) corsMiddleware(next http.Handler) http.Handler {
 		if s.config.EnableCORS {
 			w.Header().Set("Access-Control-Allow-Origin", "*")
 			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-API-Key, x-goog-api-key")
+			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-API-Key, x-goog-api-key, X-Proxy-Debug, X-Vertex-AI-LLM-Request-Type, X-Proxy-Key-Id, X-Proxy-Timestamp, X-Proxy-Signature, X-Data-Region, Cache-Control")
+			w.Header().Set("Access-Control-Expose-Headers", "X-Quota-Remaining-Requests, X-Quota-Remaining-Tokens, Retry-After")
 		}
 
 		if r.Method == "OPTIONS" {
@@ -138,58 +311,158 @@ func (s *Server) corsMiddleware(next http.Handler) http.Handler {
 // 认证中间件
 func (s *Server) authMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// 如果没有配置API Keys，跳过验证
-		if len(s.config.APIKeys) == 0 {
+		// 如果没有配置API Keys和HMAC密钥，跳过验证
+		apiKeys, _ := s.apiKeys()
+		if len(apiKeys) == 0 && s.signatures == nil {
 			next.ServeHTTP(w, r)
 			return
 		}
 
-		// 健康检查接口和OAuth回调接口跳过认证
-		if r.URL.Path == "/health" || strings.HasPrefix(r.URL.Path, "/oauth/") {
+		// 健康检查接口、OAuth回调接口和集群注册接口（使用共享密钥）跳过认证
+		if r.URL.Path == "/health" || strings.HasPrefix(r.URL.Path, "/oauth/") || r.URL.Path == cluster.RegisterPath {
 			next.ServeHTTP(w, r)
 			return
 		}
 
-		// 检查Authorization头
-		authHeader := r.Header.Get("Authorization")
-		if authHeader != "" && strings.HasPrefix(authHeader, "Bearer ") {
-			token := strings.TrimPrefix(authHeader, "Bearer ")
-			for _, apiKey := range s.config.APIKeys {
-				if token == apiKey {
-					next.ServeHTTP(w, r)
-					return
-				}
+		// 携带签名头的请求必须通过HMAC校验，不再回退到密钥匹配
+		if s.signatures != nil && auth.IsSigned(r) {
+			keyID, err := s.signatures.Verify(w, r)
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				s.writeErrorResponse(w, http.StatusRequestEntityTooLarge, "invalid_request_error", "Signed request body exceeds the configured hmac_max_body_bytes")
+				return
 			}
-		}
-
-		// 检查X-API-Key头
-		apiKey := r.Header.Get("X-API-Key")
-		for _, configKey := range s.config.APIKeys {
-			if apiKey == configKey {
-				next.ServeHTTP(w, r)
+			if err != nil {
+				s.logger.WithError(err).Warnf("Rejected signed request from %s", s.clientIP(r))
+				s.writeErrorResponse(w, http.StatusUnauthorized, "unauthorized", "Unauthorized: invalid request signature: "+err.Error())
 				return
 			}
+			setAuditCaller(r, "hmac:"+keyID)
+			ctx := context.WithValue(r.Context(), apiKeyContextKey{}, "hmac:"+keyID)
+			next.ServeHTTP(w, r.WithContext(ctx))
+			return
 		}
 
-		// 检查x-goog-api-key头
-		googApiKey := r.Header.Get("x-goog-api-key")
-		for _, configKey := range s.config.APIKeys {
-			if googApiKey == configKey {
-				next.ServeHTTP(w, r)
-				return
-			}
+		if apiKey := s.matchAPIKey(r); apiKey != "" {
+			// 记录通过认证的密钥，供后续中间件使用
+			setAuditCaller(r, apiKey)
+			ctx := context.WithValue(r.Context(), apiKeyContextKey{}, apiKey)
+			next.ServeHTTP(w, r.WithContext(ctx))
+			return
 		}
 
-		// 检查URL查询参数key
-		queryKey := r.URL.Query().Get("key")
-		for _, configKey := range s.config.APIKeys {
-			if queryKey == configKey {
-				next.ServeHTTP(w, r)
-				return
+		s.writeErrorResponse(w, http.StatusUnauthorized, "unauthorized", "Unauthorized: API key is invalid or missing. Provide it in the `Authorization: Bearer <key>` header, as a `key` query parameter, or in the `x-goog-api-key` header.")
+	})
+}
+
+// apiKeyContextKey 上下文中已认证API密钥的键
+type apiKeyContextKey struct{}
+
+// matchAPIKey 依次检查Authorization、X-API-Key、x-goog-api-key头和key查询参数，返回匹配的已配置密钥
+func (s *Server) matchAPIKey(r *http.Request) string {
+	var candidates []string
+
+	// 检查Authorization头
+	authHeader := r.Header.Get("Authorization")
+	if authHeader != "" && strings.HasPrefix(authHeader, "Bearer ") {
+		candidates = append(candidates, strings.TrimPrefix(authHeader, "Bearer "))
+	}
+
+	// 检查X-API-Key头、x-goog-api-key头和URL查询参数key
+	candidates = append(candidates,
+		r.Header.Get("X-API-Key"),
+		r.Header.Get("x-goog-api-key"),
+		r.URL.Query().Get("key"),
+	)
+
+	// 管理员密钥同样可以访问普通接口
+	apiKeys, adminKeys := s.apiKeys()
+	configKeys := append(append([]string{}, apiKeys...), adminKeys...)
+	for _, candidate := range candidates {
+		if candidate == "" {
+			continue
+		}
+		for _, configKey := range configKeys {
+			if candidate == configKey {
+				return configKey
 			}
 		}
+	}
+	return ""
+}
 
-		s.writeErrorResponse(w, http.StatusUnauthorized, "unauthorized", "Unauthorized: API key is invalid or missing. Provide it in the `Authorization: Bearer <key>` header, as a `key` query parameter, or in the `x-goog-api-key` header.")
+// apiKeys 获取当前的普通密钥和管理员密钥列表
+func (s *Server) apiKeys() (apiKeys, adminKeys []string) {
+	s.keysMu.RLock()
+	defer s.keysMu.RUnlock()
+	return s.config.APIKeys, s.config.AdminAPIKeys
+}
+
+// SetAPIKeys 运行时替换API密钥和管理员密钥列表
+func (s *Server) SetAPIKeys(apiKeys, adminKeys []string) {
+	s.keysMu.Lock()
+	defer s.keysMu.Unlock()
+	s.config.APIKeys = apiKeys
+	s.config.AdminAPIKeys = adminKeys
+}
+
+// requestAPIKey 获取当前请求已通过认证的API密钥
+func requestAPIKey(r *http.Request) string {
+	apiKey, _ := r.Context().Value(apiKeyContextKey{}).(string)
+	return apiKey
+}
+
+// 回复语言中间件，按已认证的API密钥设置强制回复语言
+func (s *Server) responseLanguageMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if language := s.config.KeyResponseLanguages[requestAPIKey(r)]; language != "" {
+			r = r.WithContext(client.WithResponseLanguage(r.Context(), language))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Vertex AI请求类型中间件，允许客户端按请求指定预配置吞吐量流量类别
+func (s *Server) vertexHeadersMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requestType := r.Header.Get(client.VertexRequestTypeHeader); requestType != "" {
+			r = r.WithContext(client.WithVertexRequestType(r.Context(), requestType))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// 数据驻留中间件，按 X-Data-Region 将请求路由到对应的Vertex AI location，无法满足时直接拒绝
+func (s *Server) dataRegionMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		region := r.Header.Get(client.DataRegionHeader)
+		if region == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// 异步任务在请求上下文之外执行，无法保证驻留区域
+		if s.client == nil || strings.HasPrefix(r.URL.Path, "/v1/async/") {
+			s.writeErrorResponse(w, http.StatusBadRequest, "data_region_unavailable",
+				fmt.Sprintf("Data region %q is not supported for this endpoint", region))
+			return
+		}
+		location, err := s.client.ResolveDataRegion(region)
+		if err != nil {
+			s.writeErrorResponse(w, http.StatusBadRequest, "data_region_unavailable", err.Error())
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(client.WithDataRegion(r.Context(), location)))
+	})
+}
+
+// 缓存控制中间件，请求头 Cache-Control: no-cache 跳过响应缓存，no-store 同时不缓存本次结果
+func (s *Server) cacheControlMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if header := r.Header.Get("Cache-Control"); header != "" {
+			r = r.WithContext(client.WithCacheControl(r.Context(), client.ParseCacheControl(header)))
+		}
+		next.ServeHTTP(w, r)
 	})
 }
 
@@ -203,7 +476,50 @@ func (s *Server) handleModels(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	s.writeJSONResponse(w, models)
+	s.writeJSONResponse(w, s.withModelAliases(models))
+}
+
+// mapModel 将OpenAI兼容接口中的模型别名映射为实际模型名，未配置别名的模型名原样返回
+func (s *Server) mapModel(model string) string {
+	if mapped, ok := s.config.ModelMappings[model]; ok {
+		return mapped
+	}
+	return model
+}
+
+// withModelAliases 返回追加了模型别名的模型列表副本，与已有模型同名的别名不重复列出
+func (s *Server) withModelAliases(list *models.OpenAIModelsResponse) *models.OpenAIModelsResponse {
+	if len(s.config.ModelMappings) == 0 {
+		return list
+	}
+
+	existing := make(map[string]models.OpenAIModel, len(list.Data))
+	for _, model := range list.Data {
+		existing[model.ID] = model
+	}
+	aliases := make([]string, 0, len(s.config.ModelMappings))
+	for alias := range s.config.ModelMappings {
+		if _, ok := existing[alias]; !ok {
+			aliases = append(aliases, alias)
+		}
+	}
+	slices.Sort(aliases)
+
+	withAliases := &models.OpenAIModelsResponse{Object: list.Object, Data: slices.Clone(list.Data)}
+	for _, alias := range aliases {
+		// 别名沿用目标模型的元数据，目标不在列表中（如流水线名）时使用默认值
+		target, ok := existing[s.config.ModelMappings[alias]]
+		if !ok {
+			target.OwnedBy = "google"
+		}
+		withAliases.Data = append(withAliases.Data, models.OpenAIModel{
+			ID:      alias,
+			Object:  "model",
+			Created: target.Created,
+			OwnedBy: target.OwnedBy,
+		})
+	}
+	return withAliases
 }
 
 // 处理OpenAI聊天完成请求
@@ -213,9 +529,16 @@ func (s *Server) handleChatCompletions(w http.ResponseWriter, r *http.Request) {
 		s.writeErrorResponse(w, http.StatusBadRequest, "invalid_request_error", "Invalid request body")
 		return
 	}
+	req.Model = s.mapModel(req.Model)
 
 	ctx := r.Context()
 
+	// best-of模式需要比较完整结果，不支持流式
+	if req.Stream && req.BestOf != nil && *req.BestOf > 1 {
+		s.writeErrorResponse(w, http.StatusBadRequest, "invalid_request_error", "best_of is not supported for streaming requests")
+		return
+	}
+
 	// 处理流式请求
 	if req.Stream {
 		s.handleOpenAIStreamResponse(w, r, &req)
@@ -226,23 +549,71 @@ func (s *Server) handleChatCompletions(w http.ResponseWriter, r *http.Request) {
 	resp, err := s.client.SendOpenAIRequest(ctx, &req)
 	if err != nil {
 		s.logger.Errorf("OpenAI request failed: %v", err)
-		s.writeErrorResponse(w, http.StatusInternalServerError, "api_error", err.Error())
+		s.writeRequestError(w, err)
 		return
 	}
 
 	s.writeJSONResponse(w, resp)
 }
 
+// 处理OpenAI并行多模型请求
+func (s *Server) handleParallelChatCompletions(w http.ResponseWriter, r *http.Request) {
+	var req models.OpenAIParallelRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.writeErrorResponse(w, http.StatusBadRequest, "invalid_request_error", "Invalid request body")
+		return
+	}
+
+	if req.Stream {
+		s.writeErrorResponse(w, http.StatusBadRequest, "invalid_request_error", "Streaming is not supported for parallel requests")
+		return
+	}
+
+	req.Model = s.mapModel(req.Model)
+	for i, model := range req.Models {
+		req.Models[i] = s.mapModel(model)
+	}
+
+	ctx := r.Context()
+	resp, err := s.client.SendOpenAIParallelRequest(ctx, &req.OpenAIRequest, req.Models)
+	if err != nil {
+		s.logger.Errorf("OpenAI parallel request failed: %v", err)
+		s.writeErrorResponse(w, http.StatusBadRequest, "invalid_request_error", err.Error())
+		return
+	}
+
+	s.writeJSONResponse(w, resp)
+}
 
 // 处理OpenAI流式响应
 func (s *Server) handleOpenAIStreamResponse(w http.ResponseWriter, r *http.Request, req *models.OpenAIRequest) {
+	start := time.Now()
+	r, trace := s.withStreamTrace(r)
+
 	// 设置SSE头
 	w.Header().Set("Content-Type", "text/event-stream")
 	w.Header().Set("Cache-Control", "no-cache")
-	w.Header().Set("Connection", "keep-alive")
-	w.Header().Set("Transfer-Encoding", "chunked")
 
 	ctx := r.Context()
+
+	if s.streams != nil {
+		token, stream := s.streams.create(callerID(requestAPIKey(r)))
+		w.Header().Set(StreamResumeTokenHeader, token)
+
+		// 生成与客户端连接解耦，客户端断开后继续写入缓冲区，可凭令牌续传
+		detach := stream.attach()
+		go func() {
+			defer stream.finish()
+			s.streamOpenAIResponse(context.WithoutCancel(ctx), stream, stream, req, trace, start)
+		}()
+		s.deliverStream(w, r, stream, 0)
+		detach()
+
+		// 客户端断开后等待生成结束，用量统计和优雅关闭仍覆盖后台生成
+		<-stream.done
+		return
+	}
+
 	w.WriteHeader(http.StatusOK)
 
 	// 获取 flusher 用于立即发送数据
@@ -252,6 +623,11 @@ func (s *Server) handleOpenAIStreamResponse(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
+	s.streamOpenAIResponse(ctx, w, flusher, req, trace, start)
+}
+
+// streamOpenAIResponse 将OpenAI流式响应逐块写入w，结束时写入元数据和 [DONE]，出错时写入错误事件
+func (s *Server) streamOpenAIResponse(ctx context.Context, w http.ResponseWriter, flusher http.Flusher, req *models.OpenAIRequest, trace *client.RequestTrace, start time.Time) {
 	// 直接流式处理，避免缓冲
 	err := s.client.SendOpenAIStreamRequest(ctx, req, func(chunk *models.OpenAIStreamChunk) error {
 		// 检查上下文取消
@@ -261,8 +637,10 @@ func (s *Server) handleOpenAIStreamResponse(w http.ResponseWriter, r *http.Reque
 		default:
 		}
 
-		// 过滤掉没有实际内容的空块
-		if len(chunk.Choices) > 0 && chunk.Choices[0].Delta.Content == "" && chunk.Choices[0].FinishReason == nil {
+		// 过滤掉没有实际内容的空块，include_usage的用量块没有choices
+		if len(chunk.Choices) > 0 && !slices.ContainsFunc(chunk.Choices, func(choice models.OpenAIChoice) bool {
+			return choice.Delta.Content != "" || len(choice.Delta.ToolCalls) > 0 || choice.FinishReason != nil
+		}) {
 			return nil
 		}
 
@@ -281,15 +659,22 @@ func (s *Server) handleOpenAIStreamResponse(w http.ResponseWriter, r *http.Reque
 
 	if err != nil {
 		s.logger.Errorf("OpenAI stream request failed: %v", err)
+		errorType := "api_error"
+		if errors.Is(err, client.ErrResponseBlocked) {
+			errorType = "content_filter"
+		} else if errors.Is(err, client.ErrUnsupportedCapability) || errors.Is(err, client.ErrPromptBlocked) || errors.Is(err, client.ErrRequestRejected) {
+			errorType = "invalid_request_error"
+		}
 		errorData, _ := json.Marshal(models.ErrorResponse{
 			Error: models.ErrorDetail{
-				Type:    "api_error",
+				Type:    errorType,
 				Message: err.Error(),
 			},
 		})
 		fmt.Fprintf(w, "data: %s\n\n", errorData)
 		flusher.Flush()
 	} else {
+		s.writeStreamMetadata(w, flusher, trace, start)
 		fmt.Fprintf(w, "data: [DONE]\n\n")
 		flusher.Flush()
 	}
@@ -298,7 +683,7 @@ func (s *Server) handleOpenAIStreamResponse(w http.ResponseWriter, r *http.Reque
 // 处理Gemini原生模型列表
 func (s *Server) handleGeminiModels(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
-	models, err := s.client.ListModels(ctx)
+	models, err := s.client.ListGeminiModels(ctx)
 	if err != nil {
 		s.logger.Errorf("Failed to get Gemini models: %v", err)
 		s.writeErrorResponse(w, http.StatusInternalServerError, "api_error", err.Error())
@@ -308,11 +693,32 @@ func (s *Server) handleGeminiModels(w http.ResponseWriter, r *http.Request) {
 	s.writeJSONResponse(w, models)
 }
 
+// 处理Gemini原生单个模型信息请求，官方SDK的 get_model 在生成前调用
+func (s *Server) handleGeminiModel(w http.ResponseWriter, r *http.Request) {
+	model, err := s.client.GetGeminiModel(r.Context(), mux.Vars(r)["model"])
+	if errors.Is(err, client.ErrModelNotFound) {
+		s.writeErrorResponse(w, http.StatusNotFound, "NOT_FOUND", err.Error())
+		return
+	}
+	if err != nil {
+		s.logger.Errorf("Failed to get Gemini model: %v", err)
+		s.writeErrorResponse(w, http.StatusInternalServerError, "api_error", err.Error())
+		return
+	}
+
+	s.writeJSONResponse(w, model)
+}
+
 // 处理Gemini原生生成请求
 func (s *Server) handleGeminiGenerate(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	model := vars["model"]
 
+	if s.native != nil {
+		s.native.ServeHTTP(w, r.WithContext(client.WithNativeRoute(r.Context(), model, false)))
+		return
+	}
+
 	var req models.GeminiRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		s.writeErrorResponse(w, http.StatusBadRequest, "invalid_request_error", "Invalid request body")
@@ -328,7 +734,7 @@ func (s *Server) handleGeminiGenerate(w http.ResponseWriter, r *http.Request) {
 	resp, err := s.client.SendRequest(ctx, model, &req)
 	if err != nil {
 		s.logger.Errorf("Gemini request failed: %v", err)
-		s.writeErrorResponse(w, http.StatusInternalServerError, "api_error", err.Error())
+		s.writeRequestError(w, err)
 		return
 	}
 
@@ -340,17 +746,23 @@ func (s *Server) handleGeminiStreamGenerate(w http.ResponseWriter, r *http.Reque
 	vars := mux.Vars(r)
 	model := vars["model"]
 
+	if s.native != nil {
+		s.native.ServeHTTP(w, r.WithContext(client.WithNativeRoute(r.Context(), model, true)))
+		return
+	}
+
 	var req models.GeminiRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		s.writeErrorResponse(w, http.StatusBadRequest, "invalid_request_error", "Invalid request body")
 		return
 	}
 
+	start := time.Now()
+	r, trace := s.withStreamTrace(r)
+
 	// 设置SSE头
 	w.Header().Set("Content-Type", "text/event-stream")
-	w.Header().Set("Cache-Control", "no-cache") 
-	w.Header().Set("Connection", "keep-alive")
-	w.Header().Set("Transfer-Encoding", "chunked")
+	w.Header().Set("Cache-Control", "no-cache")
 
 	ctx := r.Context()
 
@@ -358,7 +770,7 @@ func (s *Server) handleGeminiStreamGenerate(w http.ResponseWriter, r *http.Reque
 	resp, err := s.client.SendStreamRequestRaw(ctx, model, &req)
 	if err != nil {
 		s.logger.Errorf("Gemini stream request failed: %v", err)
-		s.writeErrorResponse(w, http.StatusInternalServerError, "api_error", err.Error())
+		s.writeRequestError(w, err)
 		return
 	}
 	defer resp.Body.Close()
@@ -377,31 +789,42 @@ func (s *Server) handleGeminiStreamGenerate(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	// 使用缓冲区进行实时流式传输
-	buffer := make([]byte, 4096)
-	for {
-		n, err := resp.Body.Read(buffer)
-		if n > 0 {
-			if _, writeErr := w.Write(buffer[:n]); writeErr != nil {
-				s.logger.Errorf("Error writing to response: %v", writeErr)
-				return
-			}
-			flusher.Flush() // 立即刷新数据到客户端
-		}
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			s.logger.Errorf("Error reading from upstream: %v", err)
-			return
+	// 使用池化缓冲区直接复制上游流，每次写入后立即刷新到客户端
+	bufferPtr := streamBufferPool.Get().(*[]byte)
+	defer streamBufferPool.Put(bufferPtr)
+	if _, err := io.CopyBuffer(&flushWriter{w: w, flusher: flusher}, resp.Body, *bufferPtr); err != nil {
+		s.logger.Errorf("Error proxying upstream stream: %v", err)
+		// 客户端仍在连接时发送错误事件，而不是直接截断流
+		if ctx.Err() == nil {
+			s.writeStreamError(w, flusher, err)
 		}
+		return
 	}
+	s.writeStreamMetadata(w, flusher, trace, start)
+}
+
+// flushWriter 每次写入后立即刷新的ResponseWriter包装
+type flushWriter struct {
+	w       io.Writer
+	flusher http.Flusher
+}
+
+// Write 写入数据并刷新
+func (f *flushWriter) Write(p []byte) (int, error) {
+	n, err := f.w.Write(p)
+	if n > 0 {
+		f.flusher.Flush()
+	}
+	return n, err
 }
 
 // 处理Vertex AI生成请求
 func (s *Server) handleVertexGenerate(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	model := vars["model"]
+	model, err := vertexModelName(r)
+	if err != nil {
+		s.writeErrorResponse(w, http.StatusBadRequest, "invalid_request_error", err.Error())
+		return
+	}
 
 	var req models.GeminiRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -413,6 +836,237 @@ func (s *Server) handleVertexGenerate(w http.ResponseWriter, r *http.Request) {
 	resp, err := s.client.SendRequest(ctx, model, &req)
 	if err != nil {
 		s.logger.Errorf("Vertex AI request failed: %v", err)
+		s.writeRequestError(w, err)
+		return
+	}
+
+	s.writeJSONResponse(w, resp)
+}
+
+// vertexModelName 从Vertex AI路由中取出模型，项目下的调优模型使用完整资源名；location不合法时返回错误
+func vertexModelName(r *http.Request) (string, error) {
+	vars := mux.Vars(r)
+	if err := client.ValidateLocation(vars["location"]); err != nil {
+		return "", err
+	}
+	if strings.Contains(r.URL.Path, "/publishers/") {
+		return vars["model"], nil
+	}
+	return fmt.Sprintf("projects/%s/locations/%s/models/%s", vars["project"], vars["location"], vars["model"]), nil
+}
+
+// 透传Vertex AI资源请求（调优任务、长时间运行操作），由代理注入认证
+func (s *Server) handleVertexPassthrough(w http.ResponseWriter, r *http.Request) {
+	resourcePath := strings.TrimPrefix(r.URL.Path, "/vertex/v1/")
+
+	var body io.Reader
+	if r.Method == "POST" {
+		body = r.Body
+	}
+
+	resp, err := s.client.ForwardVertexRequest(r.Context(), r.Method, resourcePath, r.URL.RawQuery, body)
+	if errors.Is(err, client.ErrInvalidLocation) {
+		s.writeErrorResponse(w, http.StatusBadRequest, "invalid_request_error", err.Error())
+		return
+	}
+	if err != nil {
+		s.logger.Errorf("Vertex AI passthrough failed: %v", err)
+		s.writeErrorResponse(w, http.StatusBadGateway, "api_error", err.Error())
+		return
+	}
+	defer resp.Body.Close()
+
+	if contentType := resp.Header.Get("Content-Type"); contentType != "" {
+		w.Header().Set("Content-Type", contentType)
+	}
+	w.WriteHeader(resp.StatusCode)
+	if _, err := io.Copy(w, resp.Body); err != nil {
+		s.logger.Errorf("Failed to copy Vertex AI response: %v", err)
+	}
+}
+
+// passthroughSkipHeaders 透传上游响应时不复制的逐跳头部
+var passthroughSkipHeaders = map[string]bool{
+	"Connection":        true,
+	"Keep-Alive":        true,
+	"Transfer-Encoding": true,
+	"Trailer":           true,
+	"Upgrade":           true,
+}
+
+// passthroughBlockedActions 不能透传的生成类方法：透传会绕过过滤、拦截器和用量统计，需使用代理的生成路由
+var passthroughBlockedActions = map[string]bool{
+	"generateContent":        true,
+	"streamGenerateContent":  true,
+	"batchGenerateContent":   true,
+	"generateAnswer":         true,
+	"generateText":           true,
+	"generateMessage":        true,
+	"predict":                true,
+	"predictLongRunning":     true,
+	"embedContent":           true,
+	"batchEmbedContents":     true,
+	"asyncBatchEmbedContent": true,
+	"embedText":              true,
+	"batchEmbedText":         true,
+}
+
+// 处理未单独路由的 /v1beta 请求：注入认证后原样转发到上游，响应状态码、头部和响应体原样返回；
+// 只在AI Studio模式下可用，生成类方法不透传
+func (s *Server) handleGeminiPassthrough(w http.ResponseWriter, r *http.Request) {
+	resourcePath := strings.TrimPrefix(r.URL.Path, "/v1beta/")
+	if !s.routeEnabled(RouteGroupModels) && (resourcePath == "models" || strings.HasPrefix(resourcePath, "models/")) && r.Method == "GET" {
+		http.NotFound(w, r)
+		return
+	}
+	if i := strings.LastIndex(resourcePath, ":"); i >= 0 && passthroughBlockedActions[resourcePath[i+1:]] {
+		s.writeErrorResponse(w, http.StatusNotFound, "not_found", "Method "+resourcePath[i+1:]+" is not supported by this proxy")
+		return
+	}
+
+	resp, err := s.client.ForwardGeminiRequest(r, resourcePath)
+	if errors.Is(err, client.ErrPassthroughUnsupported) {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		s.logger.Errorf("Gemini passthrough failed: %v", err)
+		s.writeErrorResponse(w, http.StatusBadGateway, "api_error", err.Error())
+		return
+	}
+	s.writePassthroughResponse(w, resp)
+}
+
+// 处理Files API文件上传：转发到上游上传接口，并将可续传上传的 X-Goog-Upload-URL 改写为代理地址，
+// 使后续分块同样经过代理
+func (s *Server) handleGeminiUpload(w http.ResponseWriter, r *http.Request) {
+	resp, err := s.client.ForwardGeminiUpload(r, strings.TrimPrefix(r.URL.Path, "/upload/v1beta/"))
+	if errors.Is(err, client.ErrPassthroughUnsupported) {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		s.logger.Errorf("Gemini file upload failed: %v", err)
+		s.writeErrorResponse(w, http.StatusBadGateway, "api_error", err.Error())
+		return
+	}
+	if uploadURL := resp.Header.Get("X-Goog-Upload-URL"); uploadURL != "" {
+		resp.Header.Set("X-Goog-Upload-URL", s.proxyUploadURL(r, uploadURL))
+	}
+	s.writePassthroughResponse(w, resp)
+}
+
+// proxyUploadURL 将指向上游的上传地址改写为代理的对外地址，其他地址原样返回
+func (s *Server) proxyUploadURL(r *http.Request, uploadURL string) string {
+	parsed, err := url.Parse(uploadURL)
+	if err != nil || parsed.Scheme+"://"+parsed.Host != client.DefaultAPIEndpoint {
+		return uploadURL
+	}
+	return s.externalBaseURL(r) + parsed.RequestURI()
+}
+
+// writePassthroughResponse 原样输出上游响应的状态码、头部（逐跳头部除外）和响应体
+func (s *Server) writePassthroughResponse(w http.ResponseWriter, resp *http.Response) {
+	defer resp.Body.Close()
+	for name, values := range resp.Header {
+		if !passthroughSkipHeaders[name] {
+			w.Header()[name] = values
+		}
+	}
+	w.WriteHeader(resp.StatusCode)
+	if _, err := io.Copy(w, resp.Body); err != nil {
+		s.logger.Errorf("Failed to copy Gemini passthrough response: %v", err)
+	}
+}
+
+// 处理分词请求
+func (s *Server) handleTokenize(w http.ResponseWriter, r *http.Request) {
+	var req models.TokenizeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.writeErrorResponse(w, http.StatusBadRequest, "invalid_request_error", "Invalid request body")
+		return
+	}
+
+	if req.Model == "" {
+		s.writeErrorResponse(w, http.StatusBadRequest, "invalid_request_error", "model is required")
+		return
+	}
+
+	s.writeJSONResponse(w, s.client.Tokenize(r.Context(), s.mapModel(req.Model), req.Text))
+}
+
+// 处理请求预估：接受OpenAI格式（messages）或Gemini格式（contents）的请求，
+// 返回估算的提示词token数、剩余上下文和代理将做的参数调整，不发送到上游
+func (s *Server) handleEstimate(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		s.writeErrorResponse(w, http.StatusBadRequest, "invalid_request_error", "Invalid request body")
+		return
+	}
+	var probe struct {
+		Model    string          `json:"model"`
+		Messages json.RawMessage `json:"messages"`
+		Contents json.RawMessage `json:"contents"`
+	}
+	if err := json.Unmarshal(body, &probe); err != nil {
+		s.writeErrorResponse(w, http.StatusBadRequest, "invalid_request_error", "Invalid request body")
+		return
+	}
+
+	// Gemini格式的请求体不含模型名，也可以通过 ?model= 指定
+	model := probe.Model
+	if model == "" {
+		model = r.URL.Query().Get("model")
+	}
+	if model == "" {
+		s.writeErrorResponse(w, http.StatusBadRequest, "invalid_request_error", "model is required")
+		return
+	}
+	model = s.mapModel(model)
+
+	var result *models.EstimateResponse
+	switch {
+	case probe.Messages != nil:
+		var req models.OpenAIRequest
+		if err = json.Unmarshal(body, &req); err == nil {
+			req.Model = model
+			result, err = s.client.EstimateOpenAIRequest(r.Context(), &req)
+		}
+	case probe.Contents != nil:
+		var req models.GeminiRequest
+		if err = json.Unmarshal(body, &req); err == nil {
+			result, err = s.client.EstimateRequest(r.Context(), model, &req)
+		}
+	default:
+		s.writeErrorResponse(w, http.StatusBadRequest, "invalid_request_error", "messages or contents is required")
+		return
+	}
+	if err != nil {
+		s.writeErrorResponse(w, http.StatusBadRequest, "invalid_request_error", err.Error())
+		return
+	}
+
+	s.writeJSONResponse(w, result)
+}
+
+// 处理Gemini token计数请求
+func (s *Server) handleGeminiCountTokens(w http.ResponseWriter, r *http.Request) {
+	model := mux.Vars(r)["model"]
+
+	var req models.GeminiCountTokensRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.writeErrorResponse(w, http.StatusBadRequest, "invalid_request_error", "Invalid request body")
+		return
+	}
+
+	if len(req.Contents) == 0 && req.GenerateContentRequest == nil {
+		s.writeErrorResponse(w, http.StatusBadRequest, "invalid_request_error", "contents or generateContentRequest is required")
+		return
+	}
+
+	resp, err := s.client.SendCountTokensRequest(r.Context(), model, &req)
+	if err != nil {
+		s.logger.Errorf("Count tokens request failed: %v", err)
 		s.writeErrorResponse(w, http.StatusInternalServerError, "api_error", err.Error())
 		return
 	}
@@ -427,6 +1081,25 @@ func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
 		"timestamp": time.Now().Format(time.RFC3339),
 		"version":   "1.0.0",
 	}
+	if maintenance := s.Maintenance(); maintenance.Enabled {
+		health["maintenance"] = maintenance
+	}
+
+	// 请求体大小统计
+	if s.client != nil {
+		health["request_payloads"] = s.client.PayloadStats()
+		health["coalesced_requests"] = s.client.CoalescedRequests()
+		health["cached_responses"] = s.client.CachedResponses()
+		health["time_to_first_token"] = s.client.TTFTStats()
+		if proxies := s.client.ProxyHealth(); len(proxies) > 0 {
+			health["proxies"] = proxies
+		}
+	}
+
+	// 前置路由模式下报告下游实例状态
+	if s.cluster != nil {
+		health["upstreams"] = s.cluster.Status()
+	}
 
 	// 基础健康检查，不依赖客户端连接
 	// 如果需要检查客户端状态，可以在这里添加，但不应该影响基本健康检查
@@ -444,6 +1117,41 @@ func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
 	s.writeJSONResponse(w, health)
 }
 
+// 就绪检查，预热完成前或关闭期间返回503
+func (s *Server) handleReady(w http.ResponseWriter, r *http.Request) {
+	if s.draining.Load() {
+		s.writeErrorResponse(w, http.StatusServiceUnavailable, "unavailable", "Shutting down")
+		return
+	}
+	if s.reauthRequired() {
+		s.writeErrorResponse(w, http.StatusServiceUnavailable, "unavailable", "OAuth re-authorization required")
+		return
+	}
+	if !s.IsReady() {
+		s.writeErrorResponse(w, http.StatusServiceUnavailable, "unavailable", "Warmup in progress")
+		return
+	}
+
+	s.writeJSONResponse(w, map[string]any{
+		"status":    "ready",
+		"timestamp": time.Now().Format(time.RFC3339),
+	})
+}
+
+// SetReady 设置服务就绪状态
+func (s *Server) SetReady(ready bool) {
+	s.ready.Store(ready)
+}
+
+// SetDraining 标记服务正在关闭，/ready 返回503使负载均衡摘除本实例
+func (s *Server) SetDraining(draining bool) {
+	s.draining.Store(draining)
+}
+
+// IsReady 服务是否就绪
+func (s *Server) IsReady() bool {
+	return s.ready.Load()
+}
 
 // 写入JSON响应
 func (s *Server) writeJSONResponse(w http.ResponseWriter, data any) {
@@ -475,6 +1183,36 @@ func (s *Server) writeErrorResponse(w http.ResponseWriter, statusCode int, error
 	}
 }
 
+// writeRequestError 输出生成请求的错误，模型不支持请求的功能或提示词被拦截时返回400，其他错误返回500
+func (s *Server) writeRequestError(w http.ResponseWriter, err error) {
+	if errors.Is(err, client.ErrUnsupportedCapability) || errors.Is(err, client.ErrPromptBlocked) || errors.Is(err, client.ErrRequestRejected) ||
+		errors.Is(err, client.ErrInvalidLocation) {
+		s.writeErrorResponse(w, http.StatusBadRequest, "invalid_request_error", err.Error())
+		return
+	}
+	s.writeErrorResponse(w, http.StatusInternalServerError, "api_error", err.Error())
+}
+
+// writeStreamError 在已开始的SSE流中发送错误事件，上游空闲超时返回DEADLINE_EXCEEDED，内容被屏蔽返回PERMISSION_DENIED
+func (s *Server) writeStreamError(w http.ResponseWriter, flusher http.Flusher, err error) {
+	code, status := http.StatusBadGateway, "UNAVAILABLE"
+	if errors.Is(err, client.ErrStreamIdle) || errors.Is(err, context.DeadlineExceeded) {
+		code, status = http.StatusGatewayTimeout, "DEADLINE_EXCEEDED"
+	} else if errors.Is(err, client.ErrResponseBlocked) {
+		code, status = http.StatusForbidden, "PERMISSION_DENIED"
+	}
+
+	data, _ := json.Marshal(map[string]any{
+		"error": map[string]any{
+			"code":    code,
+			"message": err.Error(),
+			"status":  status,
+		},
+	})
+	fmt.Fprintf(w, "event: error\ndata: %s\n\n", data)
+	flusher.Flush()
+}
+
 // 启动服务器
 func (s *Server) Start() error {
 	addr := fmt.Sprintf("%s:%d", s.config.Host, s.config.Port)
@@ -506,6 +1244,70 @@ func (s *Server) SetOAuthHandler(oauthAuth any) {
 	}
 }
 
+// AdminHandler 返回只包含健康检查和管理接口的处理器，用于独立的管理监听地址，
+// 推理流量占满主监听时监控和运维接口仍可访问
+func (s *Server) AdminHandler() http.Handler {
+	router := mux.NewRouter()
+	router.HandleFunc("/health", s.handleHealth).Methods("GET")
+	router.HandleFunc("/ready", s.handleReady).Methods("GET")
+	if s.cluster != nil {
+		router.HandleFunc("/admin/cluster", s.handleClusterStatus).Methods("GET")
+		router.HandleFunc("/admin/cluster/drain", s.handleClusterDrain).Methods("POST")
+	}
+	router.HandleFunc("/admin/data", s.handleDeleteData).Methods("DELETE")
+	router.HandleFunc("/admin/requests", s.handleRequestLog).Methods("GET")
+	router.HandleFunc("/admin/usage", s.handleUsageReport).Methods("GET")
+	router.HandleFunc("/admin/maintenance", s.handleMaintenance).Methods("GET", "POST")
+	router.HandleFunc("/admin/pipelines", s.handlePipelines).Methods("GET")
+	router.HandleFunc("/admin/pipelines/{name}/versions/{version}", s.handlePutPipelineVersion).Methods("PUT")
+	router.HandleFunc("/admin/pipelines/{name}/rollout", s.handlePipelineRollout).Methods("PUT")
+	router.HandleFunc("/admin/pipelines/{name}/rollback", s.handlePipelineRollback).Methods("POST")
+	router.HandleFunc("/admin/accounts", s.handleAccounts).Methods("GET")
+	router.HandleFunc("/admin/accounts", s.handleAddAccount).Methods("POST")
+	router.HandleFunc("/admin/accounts/{id}", s.handleRemoveAccount).Methods("DELETE")
+	return router
+}
+
+// reauthRequired OAuth刷新令牌是否已失效，等待重新授权
+func (s *Server) reauthRequired() bool {
+	reauth, ok := s.oauthAuth.(interface{ ReauthRequired() bool })
+	return ok && reauth.ReauthRequired()
+}
+
+// reauthMiddleware 刷新令牌失效时直接返回503并提示重新授权，避免每个请求都去刷新token
+// 健康检查、OAuth和管理接口不受影响，重新授权完成后自动恢复
+func (s *Server) reauthMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !s.reauthRequired() || r.URL.Path == "/health" || r.URL.Path == "/ready" ||
+			strings.HasPrefix(r.URL.Path, "/oauth/") || strings.HasPrefix(r.URL.Path, "/admin/") {
+			next.ServeHTTP(w, r)
+			return
+		}
+		w.Header().Set("Retry-After", "60")
+		s.writeErrorResponse(w, http.StatusServiceUnavailable, "unavailable",
+			"OAuth re-authorization required: no usable OAuth account (refresh token revoked/expired or account quarantined). Open /oauth/status for the authorization URL.")
+	})
+}
+
+// dailyQuotaMiddleware 所有账号都达到每日请求上限时直接返回429，配额重置后自动恢复
+func (s *Server) dailyQuotaMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		quota, ok := s.oauthAuth.(interface{ DailyQuotaExhausted() (bool, time.Time) })
+		if !ok || r.Method != http.MethodPost || strings.HasPrefix(r.URL.Path, "/oauth/") || strings.HasPrefix(r.URL.Path, "/admin/") {
+			next.ServeHTTP(w, r)
+			return
+		}
+		exhausted, resetAt := quota.DailyQuotaExhausted()
+		if !exhausted {
+			next.ServeHTTP(w, r)
+			return
+		}
+		w.Header().Set("Retry-After", strconv.Itoa(max(int(time.Until(resetAt).Seconds()), 1)))
+		s.writeErrorResponse(w, http.StatusTooManyRequests, "RESOURCE_EXHAUSTED",
+			"All OAuth accounts reached their daily request cap. The quota resets at "+resetAt.Format(time.RFC3339)+".")
+	})
+}
+
 // GetRouter 获取路由器（用于外部HTTP服务器）
 func (s *Server) GetRouter() http.Handler {
 	return s.router
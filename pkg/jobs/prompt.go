@@ -0,0 +1,98 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ba0gu0/gemini-go-proxy/pkg/models"
+	"github.com/sirupsen/logrus"
+)
+
+// KindPrompt 执行单个提示词生成的任务类型
+const KindPrompt = "prompt"
+
+// PromptSender 发送Gemini生成请求，由 client.GeminiClient 实现
+type PromptSender interface {
+	SendRequest(ctx context.Context, modelID string, req *models.GeminiRequest) (*models.GeminiResponse, error)
+}
+
+// PromptPayload 提示词任务参数
+type PromptPayload struct {
+	Name    string `json:"name,omitempty"`
+	Model   string `json:"model"`
+	Prompt  string `json:"prompt"`
+	Webhook string `json:"webhook,omitempty"` // 完成后接收结果的URL
+}
+
+// PromptResult 提示词任务结果
+type PromptResult struct {
+	JobID       string                      `json:"job_id"`
+	Name        string                      `json:"name,omitempty"`
+	Model       string                      `json:"model"`
+	Text        string                      `json:"text"`
+	Usage       *models.GeminiUsageMetadata `json:"usage,omitempty"`
+	CompletedAt time.Time                   `json:"completed_at"`
+}
+
+// PromptHandler 返回执行提示词任务的处理函数，配置了webhook时投递结果
+func PromptHandler(sender PromptSender, webhooks *WebhookSender, logger *logrus.Logger) Handler {
+	if logger == nil {
+		logger = logrus.New()
+	}
+	if webhooks == nil {
+		webhooks = NewWebhookSender("", 0, logger)
+	}
+
+	return func(ctx context.Context, job *Job) (any, error) {
+		var payload PromptPayload
+		if err := json.Unmarshal(job.Payload, &payload); err != nil {
+			return nil, fmt.Errorf("invalid prompt payload: %w", err)
+		}
+		if payload.Model == "" || payload.Prompt == "" {
+			return nil, fmt.Errorf("prompt job requires model and prompt")
+		}
+
+		req := &models.GeminiRequest{
+			Contents: []models.GeminiContent{
+				{Role: "user", Parts: []models.GeminiPart{{Text: payload.Prompt}}},
+			},
+		}
+		resp, err := sender.SendRequest(ctx, payload.Model, req)
+		if err != nil {
+			return nil, fmt.Errorf("prompt generation failed: %w", err)
+		}
+
+		result := &PromptResult{
+			JobID:       job.ID,
+			Name:        payload.Name,
+			Model:       payload.Model,
+			Text:        responseText(resp),
+			Usage:       resp.UsageMetadata,
+			CompletedAt: time.Now(),
+		}
+
+		if payload.Webhook != "" {
+			if err := webhooks.Deliver(ctx, payload.Webhook, result); err != nil {
+				// 生成结果已保存在任务中，投递失败不影响任务状态
+				logger.WithError(err).Warnf("Failed to deliver result of job %s to webhook", job.ID)
+			}
+		}
+
+		return result, nil
+	}
+}
+
+// responseText 拼接第一个候选的文本内容
+func responseText(resp *models.GeminiResponse) string {
+	if resp == nil || len(resp.Candidates) == 0 {
+		return ""
+	}
+	var builder strings.Builder
+	for _, part := range resp.Candidates[0].Content.Parts {
+		builder.WriteString(part.Text)
+	}
+	return builder.String()
+}
@@ -0,0 +1,418 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+// Status 任务状态
+type Status string
+
+const (
+	StatusQueued    Status = "queued"
+	StatusRunning   Status = "running"
+	StatusSucceeded Status = "succeeded"
+	StatusFailed    Status = "failed"
+)
+
+// DefaultWorkers 默认并发处理的任务数量
+const DefaultWorkers = 2
+
+// finishedJobRetention 已完成任务在存储中保留的默认时长
+const finishedJobRetention = 24 * time.Hour
+
+// Job 排队处理的后台任务
+type Job struct {
+	ID        string          `json:"id"`
+	Kind      string          `json:"kind"`
+	Status    Status          `json:"status"`
+	Payload   json.RawMessage `json:"payload"`
+	Result    json.RawMessage `json:"result,omitempty"`
+	Error     string          `json:"error,omitempty"`
+	Attempts  int             `json:"attempts"`
+	Owner     string          `json:"owner,omitempty"` // 提交任务的调用方标识，用于按密钥删除数据
+	CreatedAt time.Time       `json:"created_at"`
+	UpdatedAt time.Time       `json:"updated_at"`
+}
+
+// Finished 任务是否已结束
+func (j *Job) Finished() bool {
+	return j.Status == StatusSucceeded || j.Status == StatusFailed
+}
+
+// Handler 处理某一类任务，返回值会被序列化为任务结果
+type Handler func(ctx context.Context, job *Job) (any, error)
+
+// Queue 可持久化的任务队列，进程异常退出后重启时会恢复未完成的任务
+type Queue struct {
+	mu        sync.Mutex
+	path      string
+	workers   int
+	jobs      map[string]*Job
+	handlers  map[string]Handler
+	notify    chan struct{}
+	logger    *logrus.Logger
+	retention time.Duration // 已完成任务的保留时长
+	started   bool
+	running   sync.WaitGroup // 运行中的工作协程
+}
+
+// NewQueue 创建任务队列，path为空时仅保存在内存中
+func NewQueue(path string, workers int, logger *logrus.Logger) (*Queue, error) {
+	if workers <= 0 {
+		workers = DefaultWorkers
+	}
+	if logger == nil {
+		logger = logrus.New()
+	}
+
+	q := &Queue{
+		path:      path,
+		workers:   workers,
+		jobs:      make(map[string]*Job),
+		handlers:  make(map[string]Handler),
+		notify:    make(chan struct{}, 1),
+		logger:    logger,
+		retention: finishedJobRetention,
+	}
+
+	if err := q.load(); err != nil {
+		return nil, err
+	}
+	return q, nil
+}
+
+// Register 注册任务类型的处理函数
+func (q *Queue) Register(kind string, handler Handler) {
+	q.mu.Lock()
+	q.handlers[kind] = handler
+	q.mu.Unlock()
+	q.signal()
+}
+
+// SetRetention 设置已完成任务的保留时长，不大于0时使用默认的24小时
+func (q *Queue) SetRetention(retention time.Duration) {
+	if retention <= 0 {
+		retention = finishedJobRetention
+	}
+	q.mu.Lock()
+	q.retention = retention
+	q.mu.Unlock()
+}
+
+// Submit 提交新任务并持久化
+func (q *Queue) Submit(kind string, payload any) (*Job, error) {
+	return q.SubmitFor("", kind, payload)
+}
+
+// SubmitFor 以指定调用方的名义提交新任务并持久化
+func (q *Queue) SubmitFor(owner, kind string, payload any) (*Job, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal job payload: %w", err)
+	}
+
+	now := time.Now()
+	job := &Job{
+		ID:        uuid.New().String(),
+		Kind:      kind,
+		Status:    StatusQueued,
+		Payload:   data,
+		Owner:     owner,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	q.mu.Lock()
+	q.jobs[job.ID] = job
+	err = q.saveLocked()
+	snapshot := *job
+	q.mu.Unlock()
+
+	if err != nil {
+		return nil, err
+	}
+
+	q.signal()
+	return &snapshot, nil
+}
+
+// Get 获取任务快照
+func (q *Queue) Get(id string) (*Job, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	job, ok := q.jobs[id]
+	if !ok {
+		return nil, false
+	}
+	snapshot := *job
+	return &snapshot, true
+}
+
+// Purge 删除超过保留时长的已完成任务，返回删除的数量
+func (q *Queue) Purge() (int, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	removed := q.purgeLocked()
+	if removed == 0 {
+		return 0, nil
+	}
+	return removed, q.saveLocked()
+}
+
+// DeleteOwner 删除指定调用方提交的全部任务（含未完成的任务），返回删除的数量
+func (q *Queue) DeleteOwner(owner string) (int, error) {
+	if owner == "" {
+		return 0, nil
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	removed := 0
+	for id, job := range q.jobs {
+		if job.Owner == owner {
+			delete(q.jobs, id)
+			removed++
+		}
+	}
+	if removed == 0 {
+		return 0, nil
+	}
+	return removed, q.saveLocked()
+}
+
+// Pending 返回尚未完成的任务数量
+func (q *Queue) Pending() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	count := 0
+	for _, job := range q.jobs {
+		if !job.Finished() {
+			count++
+		}
+	}
+	return count
+}
+
+// Start 启动工作协程，恢复并处理存储中未完成的任务，直到ctx取消
+func (q *Queue) Start(ctx context.Context) {
+	q.mu.Lock()
+	if q.started {
+		q.mu.Unlock()
+		return
+	}
+	q.started = true
+	q.mu.Unlock()
+
+	if pending := q.Pending(); pending > 0 {
+		q.logger.Infof("Resuming %d queued job(s)", pending)
+	}
+
+	for i := 0; i < q.workers; i++ {
+		q.running.Add(1)
+		go func() {
+			defer q.running.Done()
+			q.worker(ctx)
+		}()
+	}
+	q.signal()
+}
+
+// Wait 等待Start的ctx取消后工作协程全部退出，并写入最终的任务状态
+// 被中断的任务保持排队状态，下次启动时恢复；ctx先结束时返回其错误
+func (q *Queue) Wait(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		q.running.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		return fmt.Errorf("waiting for job workers: %w", ctx.Err())
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.saveLocked()
+}
+
+// worker 循环领取并执行任务
+func (q *Queue) worker(ctx context.Context) {
+	for {
+		job, handler := q.claim()
+		if job == nil {
+			select {
+			case <-ctx.Done():
+				return
+			case <-q.notify:
+				continue
+			}
+		}
+
+		// 仍有排队任务时唤醒其他空闲协程
+		q.signal()
+
+		result, err := handler(ctx, job)
+		if ctx.Err() != nil {
+			// 关闭期间中断的任务保持排队状态，下次启动时恢复
+			q.finish(job.ID, StatusQueued, nil, nil)
+			return
+		}
+		q.finish(job.ID, "", result, err)
+	}
+}
+
+// claim 领取最早提交且已注册处理函数的排队任务
+func (q *Queue) claim() (*Job, Handler) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	var candidates []*Job
+	for _, job := range q.jobs {
+		if job.Status == StatusQueued && q.handlers[job.Kind] != nil {
+			candidates = append(candidates, job)
+		}
+	}
+	if len(candidates) == 0 {
+		return nil, nil
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].CreatedAt.Before(candidates[j].CreatedAt)
+	})
+
+	job := candidates[0]
+	job.Status = StatusRunning
+	job.Attempts++
+	job.UpdatedAt = time.Now()
+	if err := q.saveLocked(); err != nil {
+		q.logger.WithError(err).Warn("Failed to persist job state")
+	}
+
+	snapshot := *job
+	return &snapshot, q.handlers[job.Kind]
+}
+
+// finish 记录任务执行结果，status非空时直接使用该状态
+func (q *Queue) finish(id string, status Status, result any, jobErr error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	job, ok := q.jobs[id]
+	if !ok {
+		return
+	}
+
+	switch {
+	case status != "":
+		job.Status = status
+	case jobErr != nil:
+		job.Status = StatusFailed
+		job.Error = jobErr.Error()
+	default:
+		data, err := json.Marshal(result)
+		if err != nil {
+			job.Status = StatusFailed
+			job.Error = fmt.Sprintf("failed to marshal job result: %v", err)
+			break
+		}
+		job.Status = StatusSucceeded
+		job.Result = data
+	}
+	job.UpdatedAt = time.Now()
+
+	if err := q.saveLocked(); err != nil {
+		q.logger.WithError(err).Warn("Failed to persist job state")
+	}
+}
+
+// signal 非阻塞地唤醒一个空闲工作协程
+func (q *Queue) signal() {
+	select {
+	case q.notify <- struct{}{}:
+	default:
+	}
+}
+
+// load 从存储文件恢复任务，中断的运行中任务重新排队
+func (q *Queue) load() error {
+	if q.path == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(q.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to read job store: %w", err)
+	}
+
+	var stored []*Job
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return fmt.Errorf("failed to parse job store: %w", err)
+	}
+
+	for _, job := range stored {
+		if job.Status == StatusRunning {
+			job.Status = StatusQueued
+		}
+		q.jobs[job.ID] = job
+	}
+	return nil
+}
+
+// purgeLocked 清理过期的已完成任务，调用方需持有锁
+func (q *Queue) purgeLocked() int {
+	cutoff := time.Now().Add(-q.retention)
+	removed := 0
+	for id, job := range q.jobs {
+		if job.Finished() && job.UpdatedAt.Before(cutoff) {
+			delete(q.jobs, id)
+			removed++
+		}
+	}
+	return removed
+}
+
+// saveLocked 将任务写入存储文件，调用方需持有锁
+func (q *Queue) saveLocked() error {
+	q.purgeLocked()
+
+	if q.path == "" {
+		return nil
+	}
+
+	stored := make([]*Job, 0, len(q.jobs))
+	for _, job := range q.jobs {
+		stored = append(stored, job)
+	}
+
+	data, err := json.MarshalIndent(stored, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal job store: %w", err)
+	}
+
+	// 先写临时文件再重命名，避免写入中途退出损坏存储
+	tmpPath := q.path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0600); err != nil {
+		return fmt.Errorf("failed to write job store: %w", err)
+	}
+	if err := os.Rename(tmpPath, q.path); err != nil {
+		return fmt.Errorf("failed to replace job store: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,201 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func waitForStatus(t *testing.T, q *Queue, id string, status Status) *Job {
+	t.Helper()
+	var job *Job
+	require.Eventually(t, func() bool {
+		var ok bool
+		job, ok = q.Get(id)
+		return ok && job.Status == status
+	}, 2*time.Second, 10*time.Millisecond)
+	return job
+}
+
+func TestQueue_SubmitAndProcess(t *testing.T) {
+	q, err := NewQueue("", 1, nil)
+	require.NoError(t, err)
+
+	q.Register("echo", func(ctx context.Context, job *Job) (any, error) {
+		var payload map[string]string
+		require.NoError(t, json.Unmarshal(job.Payload, &payload))
+		return payload, nil
+	})
+	q.Register("fail", func(ctx context.Context, job *Job) (any, error) {
+		return nil, errors.New("boom")
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	q.Start(ctx)
+
+	ok, err := q.Submit("echo", map[string]string{"hello": "world"})
+	require.NoError(t, err)
+	bad, err := q.Submit("fail", nil)
+	require.NoError(t, err)
+
+	job := waitForStatus(t, q, ok.ID, StatusSucceeded)
+	assert.JSONEq(t, `{"hello":"world"}`, string(job.Result))
+	assert.Equal(t, 1, job.Attempts)
+
+	job = waitForStatus(t, q, bad.ID, StatusFailed)
+	assert.Equal(t, "boom", job.Error)
+	assert.Equal(t, 0, q.Pending())
+}
+
+func TestQueue_ResumeAfterRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "jobs.json")
+
+	// 第一个实例提交任务后未处理即"崩溃"
+	q1, err := NewQueue(path, 1, nil)
+	require.NoError(t, err)
+	queued, err := q1.Submit("echo", "payload")
+	require.NoError(t, err)
+
+	// 模拟运行中被中断的任务
+	q1.mu.Lock()
+	running := &Job{ID: "interrupted", Kind: "echo", Status: StatusRunning, Payload: json.RawMessage(`"x"`), CreatedAt: time.Now(), UpdatedAt: time.Now()}
+	q1.jobs[running.ID] = running
+	require.NoError(t, q1.saveLocked())
+	q1.mu.Unlock()
+
+	_, err = os.Stat(path)
+	require.NoError(t, err)
+
+	// 重启后恢复处理
+	q2, err := NewQueue(path, 1, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 2, q2.Pending())
+
+	job, ok := q2.Get("interrupted")
+	require.True(t, ok)
+	assert.Equal(t, StatusQueued, job.Status)
+
+	q2.Register("echo", func(ctx context.Context, job *Job) (any, error) {
+		return "done", nil
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	q2.Start(ctx)
+
+	waitForStatus(t, q2, queued.ID, StatusSucceeded)
+	waitForStatus(t, q2, "interrupted", StatusSucceeded)
+
+	// 结果已持久化
+	q3, err := NewQueue(path, 1, nil)
+	require.NoError(t, err)
+	job, ok = q3.Get(queued.ID)
+	require.True(t, ok)
+	assert.Equal(t, StatusSucceeded, job.Status)
+	assert.JSONEq(t, `"done"`, string(job.Result))
+}
+
+func TestQueue_UnregisteredKindStaysQueued(t *testing.T) {
+	q, err := NewQueue("", 1, nil)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	q.Start(ctx)
+
+	job, err := q.Submit("unknown", nil)
+	require.NoError(t, err)
+
+	time.Sleep(50 * time.Millisecond)
+	got, ok := q.Get(job.ID)
+	require.True(t, ok)
+	assert.Equal(t, StatusQueued, got.Status)
+}
+
+func TestQueue_WaitRequeuesInterruptedJobs(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "jobs.json")
+	q, err := NewQueue(path, 1, nil)
+	require.NoError(t, err)
+
+	started := make(chan struct{})
+	q.Register("slow", func(ctx context.Context, job *Job) (any, error) {
+		close(started)
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	q.Start(ctx)
+	job, err := q.Submit("slow", nil)
+	require.NoError(t, err)
+	<-started
+
+	// 关闭时中断的任务重新排队并写入存储
+	cancel()
+	waitCtx, waitCancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer waitCancel()
+	require.NoError(t, q.Wait(waitCtx))
+
+	restarted, err := NewQueue(path, 1, nil)
+	require.NoError(t, err)
+	stored, ok := restarted.Get(job.ID)
+	require.True(t, ok)
+	assert.Equal(t, StatusQueued, stored.Status)
+	assert.Equal(t, 1, stored.Attempts)
+}
+
+func TestQueue_RetentionAndDeleteOwner(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "jobs.json")
+	q, err := NewQueue(path, 1, nil)
+	require.NoError(t, err)
+	q.SetRetention(time.Hour)
+
+	q.Register("echo", func(ctx context.Context, job *Job) (any, error) {
+		return "ok", nil
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	q.Start(ctx)
+
+	done, err := q.Submit("echo", nil)
+	require.NoError(t, err)
+	waitForStatus(t, q, done.ID, StatusSucceeded)
+
+	// 未到保留期限的任务不会被清理
+	removed, err := q.Purge()
+	require.NoError(t, err)
+	assert.Zero(t, removed)
+
+	q.mu.Lock()
+	q.jobs[done.ID].UpdatedAt = time.Now().Add(-2 * time.Hour)
+	q.mu.Unlock()
+	removed, err = q.Purge()
+	require.NoError(t, err)
+	assert.Equal(t, 1, removed)
+	_, ok := q.Get(done.ID)
+	assert.False(t, ok)
+
+	// 按调用方删除，未注册处理函数的任务保持排队，同样会被删除
+	owned, err := q.SubmitFor("key:abc", "unregistered", nil)
+	require.NoError(t, err)
+	other, err := q.SubmitFor("key:def", "unregistered", nil)
+	require.NoError(t, err)
+	removed, err = q.DeleteOwner("key:abc")
+	require.NoError(t, err)
+	assert.Equal(t, 1, removed)
+	_, ok = q.Get(owned.ID)
+	assert.False(t, ok)
+	_, ok = q.Get(other.ID)
+	assert.True(t, ok)
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), "key:abc")
+}
@@ -0,0 +1,111 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ba0gu0/gemini-go-proxy/pkg/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSchedule(t *testing.T) {
+	base := time.Date(2025, 1, 15, 10, 7, 30, 0, time.UTC) // 周三
+
+	tests := []struct {
+		spec     string
+		expected time.Time
+	}{
+		{"*/15 * * * *", time.Date(2025, 1, 15, 10, 15, 0, 0, time.UTC)},
+		{"0 9 * * *", time.Date(2025, 1, 16, 9, 0, 0, 0, time.UTC)},
+		{"30 8 * * 1-5", time.Date(2025, 1, 16, 8, 30, 0, 0, time.UTC)},
+		{"0 0 1 * *", time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 12 * * 7", time.Date(2025, 1, 19, 12, 0, 0, 0, time.UTC)},
+		{"@hourly", time.Date(2025, 1, 15, 11, 0, 0, 0, time.UTC)},
+		{"@every 90m", base.Add(90 * time.Minute)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			schedule, err := ParseSchedule(tt.spec)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, schedule.Next(base))
+		})
+	}
+
+	for _, spec := range []string{"", "* * * *", "60 * * * *", "*/0 * * * *", "@every 10ms", "a b c d e"} {
+		_, err := ParseSchedule(spec)
+		assert.Error(t, err, spec)
+	}
+}
+
+type fakeSender struct {
+	model  string
+	prompt string
+}
+
+func (f *fakeSender) SendRequest(ctx context.Context, modelID string, req *models.GeminiRequest) (*models.GeminiResponse, error) {
+	f.model = modelID
+	f.prompt = req.Contents[0].Parts[0].Text
+	return &models.GeminiResponse{
+		Candidates: []models.GeminiCandidate{{Content: models.GeminiContent{Parts: []models.GeminiPart{{Text: "daily summary"}}}}},
+	}, nil
+}
+
+func TestPromptHandler_DeliversWebhook(t *testing.T) {
+	received := make(chan PromptResult, 1)
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var result PromptResult
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&result))
+		received <- result
+	}))
+	defer webhook.Close()
+
+	sender := &fakeSender{}
+	payload, _ := json.Marshal(&PromptPayload{Name: "report", Model: "gemini-2.5-flash", Prompt: "summarize", Webhook: webhook.URL})
+
+	result, err := PromptHandler(sender, nil, nil)(context.Background(), &Job{ID: "job-1", Payload: payload})
+	require.NoError(t, err)
+	assert.Equal(t, "gemini-2.5-flash", sender.model)
+	assert.Equal(t, "summarize", sender.prompt)
+	assert.Equal(t, "daily summary", result.(*PromptResult).Text)
+
+	delivered := <-received
+	assert.Equal(t, "job-1", delivered.JobID)
+	assert.Equal(t, "report", delivered.Name)
+	assert.Equal(t, "daily summary", delivered.Text)
+}
+
+func TestScheduler_SubmitsJobs(t *testing.T) {
+	q, err := NewQueue("", 1, nil)
+	require.NoError(t, err)
+
+	done := make(chan struct{}, 1)
+	q.Register("tick", func(ctx context.Context, job *Job) (any, error) {
+		select {
+		case done <- struct{}{}:
+		default:
+		}
+		return nil, nil
+	})
+
+	s := NewScheduler(q, nil)
+	require.NoError(t, s.Add("ticker", "@every 1s", "tick", nil))
+	assert.Error(t, s.Add("bad", "nope", "tick", nil))
+	assert.Equal(t, 1, s.Len())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	q.Start(ctx)
+	s.Start(ctx)
+
+	select {
+	case <-done:
+	case <-time.After(3 * time.Second):
+		t.Fatal("scheduled job was not executed")
+	}
+}
@@ -0,0 +1,105 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// scheduleEntry 一个定时提交的任务
+type scheduleEntry struct {
+	name     string
+	schedule Schedule
+	kind     string
+	payload  any
+}
+
+// Scheduler 按调度表达式周期性地向队列提交任务
+type Scheduler struct {
+	mu      sync.Mutex
+	queue   *Queue
+	entries []*scheduleEntry
+	logger  *logrus.Logger
+	started bool
+}
+
+// NewScheduler 创建调度器
+func NewScheduler(queue *Queue, logger *logrus.Logger) *Scheduler {
+	if logger == nil {
+		logger = logrus.New()
+	}
+	return &Scheduler{
+		queue:  queue,
+		logger: logger,
+	}
+}
+
+// Add 添加定时任务，必须在Start之前调用
+func (s *Scheduler) Add(name, spec, kind string, payload any) error {
+	schedule, err := ParseSchedule(spec)
+	if err != nil {
+		return fmt.Errorf("invalid schedule for job %s: %w", name, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries = append(s.entries, &scheduleEntry{
+		name:     name,
+		schedule: schedule,
+		kind:     kind,
+		payload:  payload,
+	})
+	return nil
+}
+
+// Len 返回定时任务数量
+func (s *Scheduler) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.entries)
+}
+
+// Start 启动调度，直到ctx取消
+func (s *Scheduler) Start(ctx context.Context) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.started {
+		return
+	}
+	s.started = true
+
+	for _, entry := range s.entries {
+		go s.run(ctx, entry)
+	}
+}
+
+// run 等待到下一次执行时间并提交任务
+func (s *Scheduler) run(ctx context.Context, entry *scheduleEntry) {
+	for {
+		now := time.Now()
+		next := entry.schedule.Next(now)
+		if next.IsZero() {
+			s.logger.Warnf("Scheduled job %s has no future run time, stopping", entry.name)
+			return
+		}
+		s.logger.Debugf("Scheduled job %s next run at %s", entry.name, next.Format(time.RFC3339))
+
+		timer := time.NewTimer(next.Sub(now))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		job, err := s.queue.Submit(entry.kind, entry.payload)
+		if err != nil {
+			s.logger.WithError(err).Errorf("Failed to submit scheduled job %s", entry.name)
+			continue
+		}
+		s.logger.Infof("Scheduled job %s submitted as %s", entry.name, job.ID)
+	}
+}
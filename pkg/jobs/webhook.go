@@ -0,0 +1,243 @@
+package jobs
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// webhook签名相关头部
+const (
+	WebhookTimestampHeader = "X-Proxy-Webhook-Timestamp"
+	WebhookSignatureHeader = "X-Proxy-Webhook-Signature"
+)
+
+const (
+	// DefaultWebhookMaxRetries 默认的webhook最大重试次数
+	DefaultWebhookMaxRetries = 5
+	// webhookTimeout 单次webhook投递的超时时间
+	webhookTimeout = 30 * time.Second
+	// webhookMaxDelay 重试间隔上限
+	webhookMaxDelay = time.Minute
+)
+
+// ErrWebhookDestination webhook地址不是https或指向内网地址
+var ErrWebhookDestination = errors.New("webhook must be an https URL on a public address")
+
+// WebhookSender 投递任务结果到webhook，支持HMAC签名和指数退避重试
+type WebhookSender struct {
+	secret     string
+	maxRetries int
+	baseDelay  time.Duration
+	httpClient *http.Client
+	logger     *logrus.Logger
+
+	// 受限模式只投递到公网https地址，allowedHosts 中的主机不检查地址
+	restricted   bool
+	allowedHosts map[string]bool
+}
+
+// NewWebhookSender 创建webhook投递器，secret为空时不签名，maxRetries<0 时不重试
+func NewWebhookSender(secret string, maxRetries int, logger *logrus.Logger) *WebhookSender {
+	if maxRetries == 0 {
+		maxRetries = DefaultWebhookMaxRetries
+	}
+	if maxRetries < 0 {
+		maxRetries = 0
+	}
+	if logger == nil {
+		logger = logrus.New()
+	}
+	return &WebhookSender{
+		secret:     secret,
+		maxRetries: maxRetries,
+		baseDelay:  time.Second,
+		httpClient: &http.Client{Timeout: webhookTimeout},
+		logger:     logger,
+	}
+}
+
+// Restricted 返回只投递到公网https地址的副本，用于调用方提交的webhook，避免代理被用来请求内网服务；
+// 连接时检查解析后的地址，重定向到内网同样被拒绝，allowedHosts 中的主机（如内部的接收服务）不做地址检查
+func (s *WebhookSender) Restricted(allowedHosts []string) *WebhookSender {
+	restricted := *s
+	restricted.restricted = true
+	restricted.allowedHosts = make(map[string]bool, len(allowedHosts))
+	for _, host := range allowedHosts {
+		restricted.allowedHosts[strings.ToLower(host)] = true
+	}
+
+	dialer := &net.Dialer{Timeout: webhookTimeout}
+	transport := &http.Transport{
+		// 不使用环境变量中的代理，否则检查的是代理地址而不是webhook地址
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			host, port, err := net.SplitHostPort(addr)
+			if err != nil {
+				return nil, err
+			}
+			if restricted.allowedHosts[strings.ToLower(host)] {
+				return dialer.DialContext(ctx, network, addr)
+			}
+			ips, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+			if err != nil {
+				return nil, err
+			}
+			for _, ip := range ips {
+				if !isPublicIP(ip.IP) {
+					return nil, fmt.Errorf("%w: %s resolves to %s", ErrWebhookDestination, host, ip.IP)
+				}
+			}
+			// 连接检查过的地址，避免再次解析得到不同的结果
+			return dialer.DialContext(ctx, network, net.JoinHostPort(ips[0].IP.String(), port))
+		},
+		ForceAttemptHTTP2:   true,
+		TLSHandshakeTimeout: 10 * time.Second,
+	}
+	restricted.httpClient = &http.Client{
+		Timeout:   webhookTimeout,
+		Transport: transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 10 {
+				return errors.New("stopped after 10 redirects")
+			}
+			if req.URL.Scheme != "https" {
+				return ErrWebhookDestination
+			}
+			return nil
+		},
+	}
+	return &restricted
+}
+
+// CheckURL 校验webhook地址，受限模式下要求https且主机解析到公网地址，用于提交时尽早拒绝
+func (s *WebhookSender) CheckURL(ctx context.Context, rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return fmt.Errorf("invalid webhook URL: %s", rawURL)
+	}
+	if !s.restricted {
+		if u.Scheme != "http" && u.Scheme != "https" {
+			return fmt.Errorf("invalid webhook URL: %s", rawURL)
+		}
+		return nil
+	}
+
+	if u.Scheme != "https" {
+		return ErrWebhookDestination
+	}
+	host := strings.ToLower(u.Hostname())
+	if s.allowedHosts[host] {
+		return nil
+	}
+	ips, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return fmt.Errorf("failed to resolve webhook host %s: %w", host, err)
+	}
+	for _, ip := range ips {
+		if !isPublicIP(ip.IP) {
+			return fmt.Errorf("%w: %s resolves to %s", ErrWebhookDestination, host, ip.IP)
+		}
+	}
+	return nil
+}
+
+// isPublicIP 判断地址是否可作为webhook目标：排除回环、私有、链路本地、组播和未指定地址
+func isPublicIP(ip net.IP) bool {
+	return !ip.IsLoopback() && !ip.IsPrivate() && !ip.IsLinkLocalUnicast() && !ip.IsLinkLocalMulticast() &&
+		!ip.IsInterfaceLocalMulticast() && !ip.IsMulticast() && !ip.IsUnspecified()
+}
+
+// SignWebhookPayload 计算webhook签名：HMAC-SHA256(secret, timestamp + "." + body)
+func SignWebhookPayload(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyWebhookSignature 校验webhook签名，供接收方使用
+func VerifyWebhookSignature(secret, timestamp, signature string, body []byte) bool {
+	expected := SignWebhookPayload(secret, timestamp, body)
+	return hmac.Equal([]byte(expected), []byte(signature))
+}
+
+// Deliver 以JSON POST方式投递结果，失败时按指数退避重试
+func (s *WebhookSender) Deliver(ctx context.Context, url string, payload any) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal webhook payload: %w", err)
+	}
+
+	var lastErr error
+	for attempt := 0; attempt <= s.maxRetries; attempt++ {
+		if attempt > 0 {
+			delay := s.baseDelay * time.Duration(1<<uint(attempt-1))
+			if delay > webhookMaxDelay {
+				delay = webhookMaxDelay
+			}
+			s.logger.Warnf("Webhook delivery failed (attempt %d/%d), retrying in %v: %v", attempt, s.maxRetries+1, delay, lastErr)
+
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(delay):
+			}
+		}
+
+		retryable, err := s.send(ctx, url, body)
+		if err == nil {
+			return nil
+		}
+		lastErr = err
+		if !retryable {
+			break
+		}
+	}
+
+	return fmt.Errorf("webhook delivery failed: %w", lastErr)
+}
+
+// send 执行单次投递，返回错误是否可重试
+func (s *WebhookSender) send(ctx context.Context, url string, body []byte) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(body))
+	if err != nil {
+		return false, fmt.Errorf("failed to create webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	if s.secret != "" {
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		req.Header.Set(WebhookTimestampHeader, timestamp)
+		req.Header.Set(WebhookSignatureHeader, SignWebhookPayload(s.secret, timestamp, body))
+	}
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return true, fmt.Errorf("webhook request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+
+	// 限流和服务端错误可重试
+	retryable := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+	return retryable, fmt.Errorf("webhook returned status %d", resp.StatusCode)
+}
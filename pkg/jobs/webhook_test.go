@@ -0,0 +1,88 @@
+package jobs
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWebhookSender_SignsAndRetries(t *testing.T) {
+	var attempts int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		timestamp := r.Header.Get(WebhookTimestampHeader)
+		assert.True(t, VerifyWebhookSignature("secret", timestamp, r.Header.Get(WebhookSignatureHeader), body))
+		assert.False(t, VerifyWebhookSignature("other", timestamp, r.Header.Get(WebhookSignatureHeader), body))
+
+		if atomic.AddInt32(&attempts, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	sender := NewWebhookSender("secret", 3, nil)
+	sender.baseDelay = time.Millisecond
+
+	require.NoError(t, sender.Deliver(context.Background(), server.URL, map[string]string{"text": "ok"}))
+	assert.Equal(t, int32(3), atomic.LoadInt32(&attempts))
+}
+
+func TestWebhookSender_GivesUp(t *testing.T) {
+	var attempts int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&attempts, 1)
+		assert.Empty(t, r.Header.Get(WebhookSignatureHeader))
+		if r.URL.Path == "/bad" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	sender := NewWebhookSender("", 2, nil)
+	sender.baseDelay = time.Millisecond
+
+	// 客户端错误不重试
+	assert.Error(t, sender.Deliver(context.Background(), server.URL+"/bad", nil))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&attempts))
+
+	// 服务端错误重试至上限
+	atomic.StoreInt32(&attempts, 0)
+	assert.Error(t, sender.Deliver(context.Background(), server.URL, nil))
+	assert.Equal(t, int32(3), atomic.LoadInt32(&attempts))
+}
+
+func TestWebhookSender_Restricted(t *testing.T) {
+	var attempts int32
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&attempts, 1)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	sender := NewWebhookSender("", -1, nil).Restricted([]string{"internal.example"})
+	ctx := context.Background()
+	for _, url := range []string{"http://example.com/hook", "https://127.0.0.1/hook", "https://localhost/hook", "https://10.0.0.1/hook", "https://169.254.169.254/latest", "https://[::1]/hook", "ftp://example.com"} {
+		assert.Error(t, sender.CheckURL(ctx, url), url)
+	}
+	assert.ErrorIs(t, sender.CheckURL(ctx, "https://192.168.1.1/hook"), ErrWebhookDestination)
+	assert.NoError(t, sender.CheckURL(ctx, "https://internal.example/hook"))
+
+	// 投递时检查连接的地址，内网地址不会收到请求
+	err := sender.Deliver(ctx, server.URL, map[string]string{"text": "ok"})
+	assert.ErrorIs(t, err, ErrWebhookDestination)
+	assert.Equal(t, int32(0), atomic.LoadInt32(&attempts))
+
+	// 未受限的投递器用于配置中的定时任务
+	assert.NoError(t, NewWebhookSender("", -1, nil).CheckURL(ctx, "http://127.0.0.1/hook"))
+}
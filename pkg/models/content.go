@@ -0,0 +1,53 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// openAIMessage 用于默认序列化，避免递归调用MarshalJSON/UnmarshalJSON
+type openAIMessage OpenAIMessage
+
+// MarshalJSON 序列化消息，多模态消息的content输出为数组
+func (m OpenAIMessage) MarshalJSON() ([]byte, error) {
+	if len(m.ContentParts) == 0 {
+		return json.Marshal(openAIMessage(m))
+	}
+	return json.Marshal(struct {
+		openAIMessage
+		Content []OpenAIContentPart `json:"content"`
+	}{openAIMessage(m), m.ContentParts})
+}
+
+// UnmarshalJSON 解析消息，content可以是字符串或内容部分数组
+func (m *OpenAIMessage) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		openAIMessage
+		Content json.RawMessage `json:"content"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	*m = OpenAIMessage(raw.openAIMessage)
+
+	content := strings.TrimSpace(string(raw.Content))
+	switch {
+	case content == "" || content == "null":
+		return nil
+	case strings.HasPrefix(content, "["):
+		if err := json.Unmarshal(raw.Content, &m.ContentParts); err != nil {
+			return fmt.Errorf("invalid message content: %w", err)
+		}
+		var texts []string
+		for _, part := range m.ContentParts {
+			if part.Type == "text" {
+				texts = append(texts, part.Text)
+			}
+		}
+		m.Content = strings.Join(texts, "\n")
+		return nil
+	default:
+		return json.Unmarshal(raw.Content, &m.Content)
+	}
+}
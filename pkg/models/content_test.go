@@ -0,0 +1,45 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpenAIMessage_UnmarshalContent(t *testing.T) {
+	var msg OpenAIMessage
+	require.NoError(t, json.Unmarshal([]byte(`{"role":"user","content":"hello"}`), &msg))
+	assert.Equal(t, "hello", msg.Content)
+	assert.Empty(t, msg.ContentParts)
+
+	require.NoError(t, json.Unmarshal([]byte(`{"role":"assistant","content":null,"tool_calls":[{"id":"1","type":"function","function":{"name":"f","arguments":"{}"}}]}`), &msg))
+	assert.Empty(t, msg.Content)
+	assert.Len(t, msg.ToolCalls, 1)
+
+	msg = OpenAIMessage{}
+	require.NoError(t, json.Unmarshal([]byte(`{"role":"user","content":[
+		{"type":"text","text":"What is this?"},
+		{"type":"image_url","image_url":{"url":"https://example.com/a.png","detail":"low"}},
+		{"type":"text","text":"Be brief."}
+	]}`), &msg))
+	assert.Equal(t, "What is this?\nBe brief.", msg.Content)
+	require.Len(t, msg.ContentParts, 3)
+	assert.Equal(t, "https://example.com/a.png", msg.ContentParts[1].ImageURL.URL)
+
+	assert.Error(t, json.Unmarshal([]byte(`{"role":"user","content":[1]}`), &msg))
+}
+
+func TestOpenAIMessage_MarshalContent(t *testing.T) {
+	data, err := json.Marshal(OpenAIMessage{Role: "user", Content: "hello"})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"role":"user","content":"hello"}`, string(data))
+
+	data, err = json.Marshal(OpenAIMessage{Role: "user", Content: "hi", ContentParts: []OpenAIContentPart{
+		{Type: "text", Text: "hi"},
+		{Type: "image_url", ImageURL: &OpenAIImageURL{URL: "data:image/png;base64,AAAA"}},
+	}})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"role":"user","content":[{"type":"text","text":"hi"},{"type":"image_url","image_url":{"url":"data:image/png;base64,AAAA"}}]}`, string(data))
+}
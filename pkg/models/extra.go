@@ -0,0 +1,126 @@
+package models
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// generationConfigKeys 可直接写在请求顶层、合并时归入generationConfig的字段
+var generationConfigKeys = map[string]bool{
+	"thinkingConfig":     true,
+	"responseModalities": true,
+	"speechConfig":       true,
+	"mediaResolution":    true,
+}
+
+// geminiRequest 用于默认序列化，避免递归调用MarshalJSON
+type geminiRequest GeminiRequest
+
+// MarshalJSON 序列化请求，存在Extra时将其合并到请求中
+func (r GeminiRequest) MarshalJSON() ([]byte, error) {
+	if len(r.Extra) == 0 {
+		return json.Marshal(geminiRequest(r))
+	}
+
+	data, err := json.Marshal(geminiRequest(r))
+	if err != nil {
+		return nil, err
+	}
+	var base map[string]any
+	if err := json.Unmarshal(data, &base); err != nil {
+		return nil, err
+	}
+	return json.Marshal(MergeExtraFields(base, r.Extra))
+}
+
+// MergeExtraFields 将额外字段合并到Gemini请求对象中
+// 顶层和generationConfig内的字段名统一为camelCase；对象递归合并，tools追加，其他值直接覆盖
+func MergeExtraFields(base, extra map[string]any) map[string]any {
+	merged := normalizeKeys(base)
+	for key, value := range normalizeKeys(extra) {
+		if generationConfigKeys[key] {
+			config, _ := merged["generationConfig"].(map[string]any)
+			merged["generationConfig"] = mergeValue(config, map[string]any{key: value})
+			continue
+		}
+		if key == "tools" {
+			existing, _ := merged[key].([]any)
+			if added, ok := value.([]any); ok {
+				merged[key] = append(existing, added...)
+				continue
+			}
+		}
+		merged[key] = mergeValue(merged[key], value)
+	}
+	return merged
+}
+
+// normalizeKeys 将对象顶层及generationConfig中的snake_case字段名转换为camelCase，返回副本
+func normalizeKeys(fields map[string]any) map[string]any {
+	normalized := make(map[string]any, len(fields))
+	for key, value := range fields {
+		key = snakeToCamel(key)
+		if config, ok := value.(map[string]any); ok && key == "generationConfig" {
+			value = normalizeKeys(config)
+		}
+		normalized[key] = mergeValue(normalized[key], value)
+	}
+	return normalized
+}
+
+// mergeValue 递归合并两个JSON值，两者都是对象时合并字段，否则以override为准
+func mergeValue(base, override any) any {
+	overrideMap, ok := override.(map[string]any)
+	if !ok {
+		return override
+	}
+	baseMap, ok := base.(map[string]any)
+	if !ok {
+		return override
+	}
+	merged := make(map[string]any, len(baseMap)+len(overrideMap))
+	for key, value := range baseMap {
+		merged[key] = value
+	}
+	for key, value := range overrideMap {
+		merged[key] = mergeValue(merged[key], value)
+	}
+	return merged
+}
+
+// snakeToCamel 将snake_case转换为camelCase
+func snakeToCamel(key string) string {
+	if !strings.Contains(key, "_") {
+		return key
+	}
+	words := strings.Split(key, "_")
+	var b strings.Builder
+	b.WriteString(words[0])
+	for _, word := range words[1:] {
+		if word == "" {
+			continue
+		}
+		b.WriteString(strings.ToUpper(word[:1]) + word[1:])
+	}
+	return b.String()
+}
+
+// ExtraFields 收集需要原样合并到Gemini请求中的扩展字段（extra_body、extra_body.google、google）
+func (r *OpenAIRequest) ExtraFields() map[string]any {
+	extra := make(map[string]any)
+	for key, value := range r.ExtraBody {
+		if key != "google" {
+			extra[key] = value
+		}
+	}
+	google, _ := r.ExtraBody["google"].(map[string]any)
+	for _, fields := range []map[string]any{google, r.Google} {
+		for key, value := range fields {
+			extra[key] = mergeValue(extra[key], value)
+		}
+	}
+	if len(extra) == 0 {
+		return nil
+	}
+	return extra
+}
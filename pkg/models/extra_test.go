@@ -0,0 +1,58 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGeminiRequest_MarshalExtra(t *testing.T) {
+	temperature := float32(0.5)
+	req := GeminiRequest{
+		Contents:          []GeminiContent{{Role: "user", Parts: []GeminiPart{{Text: "hi"}}}},
+		SystemInstruction: &GeminiSystemInstruction{Parts: []GeminiPart{{Text: "be brief"}}},
+		GenerationConfig:  &GeminiGenerationConfig{Temperature: &temperature},
+		Tools:             []GeminiTool{{FunctionDeclarations: []GeminiFunctionDeclaration{{Name: "f"}}}},
+		Extra: map[string]any{
+			"safety_settings":   []any{map[string]any{"category": "HARM_CATEGORY_HARASSMENT", "threshold": "BLOCK_NONE"}},
+			"cached_content":    "cachedContents/abc",
+			"thinking_config":   map[string]any{"thinking_budget": 0},
+			"generation_config": map[string]any{"top_k": 3},
+			"tools":             []any{map[string]any{"googleSearch": map[string]any{}}},
+		},
+	}
+
+	data, err := json.Marshal(req)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"contents": [{"role": "user", "parts": [{"text": "hi"}]}],
+		"systemInstruction": {"parts": [{"text": "be brief"}]},
+		"generationConfig": {"temperature": 0.5, "topK": 3, "thinkingConfig": {"thinking_budget": 0}},
+		"tools": [{"functionDeclarations": [{"name": "f"}]}, {"googleSearch": {}}],
+		"safetySettings": [{"category": "HARM_CATEGORY_HARASSMENT", "threshold": "BLOCK_NONE"}],
+		"cachedContent": "cachedContents/abc"
+	}`, string(data))
+
+	// 没有额外字段时保持原有字段名
+	req.Extra = nil
+	data, err = json.Marshal(&req)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"system_instruction"`)
+}
+
+func TestOpenAIRequest_ExtraFields(t *testing.T) {
+	var req OpenAIRequest
+	require.NoError(t, json.Unmarshal([]byte(`{
+		"model": "gemini-2.5-flash",
+		"extra_body": {"cachedContent": "a", "google": {"thinking_config": {"include_thoughts": true}}},
+		"google": {"cachedContent": "b"}
+	}`), &req))
+
+	assert.Equal(t, map[string]any{
+		"cachedContent":   "b",
+		"thinking_config": map[string]any{"include_thoughts": true},
+	}, req.ExtraFields())
+	assert.Nil(t, (&OpenAIRequest{}).ExtraFields())
+}
@@ -1,5 +1,7 @@
 package models
 
+import "encoding/json"
+
 // ErrorDetail 错误详情
 type ErrorDetail struct {
 	Type    string `json:"type"`
@@ -30,6 +32,11 @@ type GoogleAuthConfig struct {
 	Location     string   `json:"location,omitempty"`
 	// OAuth2 Token存储 (Base64编码的token文件内容)
 	OAuthTokens []string `json:"oauth_tokens,omitempty"`
+	// 外部凭据文件：gemini-cli的oauth_creds.json或gcloud应用默认凭据，"gemini-cli" 和 "adc" 表示默认位置
+	CredentialFiles []string `json:"credential_files,omitempty"`
+	// 每个账号每天的请求上限（0表示不限制）及配额重置时区，默认太平洋时间
+	AccountDailyCap int    `json:"account_daily_cap,omitempty"`
+	QuotaTimezone   string `json:"quota_timezone,omitempty"`
 	// Service Account认证相关
 	CredentialsPath      string `json:"credentials_path,omitempty"`
 	CredentialsJSON      string `json:"credentials_json,omitempty"`
@@ -38,19 +45,98 @@ type GoogleAuthConfig struct {
 
 // OpenAI兼容格式
 type OpenAIMessage struct {
-	Role    string `json:"role"`
-	Content string `json:"content"`
+	Role       string           `json:"role"`
+	Content    string           `json:"content"`
+	Name       string           `json:"name,omitempty"`
+	ToolCalls  []OpenAIToolCall `json:"tool_calls,omitempty"`   // assistant消息中的函数调用
+	ToolCallID string           `json:"tool_call_id,omitempty"` // tool消息对应的函数调用ID
+	// ContentParts content为数组（多模态消息）时的各个部分，此时Content为其中文本部分的拼接
+	ContentParts []OpenAIContentPart `json:"-"`
+}
+
+// OpenAIContentPart 多模态消息内容的一个部分
+type OpenAIContentPart struct {
+	Type     string          `json:"type"` // "text"、"image_url" 或 "file"
+	Text     string          `json:"text,omitempty"`
+	ImageURL *OpenAIImageURL `json:"image_url,omitempty"`
+	File     *OpenAIFile     `json:"file,omitempty"`
+}
+
+// OpenAIFile 文件内容部分：file_id 为Files API的文件名（files/...）或文件URI，file_data 为 base64 data URL
+type OpenAIFile struct {
+	FileID   string `json:"file_id,omitempty"`
+	FileData string `json:"file_data,omitempty"`
+	Filename string `json:"filename,omitempty"` // 用于推断MIME类型
+}
+
+// OpenAIImageURL 图片地址，支持 base64 data URL 和远程地址
+type OpenAIImageURL struct {
+	URL    string `json:"url"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// OpenAITool 可供模型调用的工具定义
+type OpenAITool struct {
+	Type     string         `json:"type"`
+	Function OpenAIFunction `json:"function"`
+}
+
+// OpenAIFunction 函数声明，parameters为JSON Schema
+type OpenAIFunction struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description,omitempty"`
+	Parameters  map[string]any `json:"parameters,omitempty"`
+}
+
+// OpenAIToolCall 模型发起的函数调用，流式增量中通过index关联
+type OpenAIToolCall struct {
+	Index    *int               `json:"index,omitempty"`
+	ID       string             `json:"id,omitempty"`
+	Type     string             `json:"type,omitempty"`
+	Function OpenAIFunctionCall `json:"function"`
+}
+
+// OpenAIFunctionCall 函数调用的名称和JSON编码的参数
+type OpenAIFunctionCall struct {
+	Name      string `json:"name,omitempty"`
+	Arguments string `json:"arguments"`
 }
 
 type OpenAIRequest struct {
 	Model             string                   `json:"model"`
 	Messages          []OpenAIMessage          `json:"messages"`
 	Stream            bool                     `json:"stream,omitempty"`
+	StreamOptions     *OpenAIStreamOptions     `json:"stream_options,omitempty"`
 	Temperature       *float32                 `json:"temperature,omitempty"`
 	MaxTokens         *int                     `json:"max_tokens,omitempty"`
 	TopP              *float32                 `json:"top_p,omitempty"`
 	Stop              []string                 `json:"stop,omitempty"`
+	N                 *int                     `json:"n,omitempty"` // 生成的候选数量，对应Gemini的candidateCount
+	Seed              *int                     `json:"seed,omitempty"`
+	PresencePenalty   *float32                 `json:"presence_penalty,omitempty"`
+	FrequencyPenalty  *float32                 `json:"frequency_penalty,omitempty"`
+	Tools             []OpenAITool             `json:"tools,omitempty"`
+	ToolChoice        json.RawMessage          `json:"tool_choice,omitempty"`        // "none"、"auto"、"required" 或 {"type":"function","function":{"name":...}}
+	ResponseFormat    *OpenAIResponseFormat    `json:"response_format,omitempty"`    // 结构化输出：json_object 或 json_schema
 	SystemInstruction *GeminiSystemInstruction `json:"system_instruction,omitempty"` // 支持直接传入system_instruction
+	BestOf            *int                     `json:"best_of,omitempty"`            // 扩展字段：生成N个候选并返回最佳结果
+	BestOfScorer      string                   `json:"best_of_scorer,omitempty"`     // 扩展字段：候选评分方式 "heuristic" 或 "judge"
+	ExtraBody         map[string]any           `json:"extra_body,omitempty"`         // 扩展字段：原样合并到Gemini请求中的字段，支持嵌套的 google 对象
+	Google            map[string]any           `json:"google,omitempty"`             // 扩展字段：同extra_body
+}
+
+// OpenAIResponseFormat 结构化输出格式：text、json_object 或 json_schema
+type OpenAIResponseFormat struct {
+	Type       string            `json:"type"`
+	JSONSchema *OpenAIJSONSchema `json:"json_schema,omitempty"`
+}
+
+// OpenAIJSONSchema json_schema格式的schema定义
+type OpenAIJSONSchema struct {
+	Name        string         `json:"name,omitempty"`
+	Description string         `json:"description,omitempty"`
+	Schema      map[string]any `json:"schema,omitempty"`
+	Strict      *bool          `json:"strict,omitempty"`
 }
 
 type OpenAIChoice struct {
@@ -67,20 +153,76 @@ type OpenAIUsage struct {
 }
 
 type OpenAIResponse struct {
-	ID      string         `json:"id"`
-	Object  string         `json:"object"`
-	Created int64          `json:"created"`
-	Model   string         `json:"model"`
-	Choices []OpenAIChoice `json:"choices"`
-	Usage   *OpenAIUsage   `json:"usage,omitempty"`
+	ID                string                  `json:"id"`
+	Object            string                  `json:"object"`
+	Created           int64                   `json:"created"`
+	Model             string                  `json:"model"`
+	Choices           []OpenAIChoice          `json:"choices"`
+	Usage             *OpenAIUsage            `json:"usage,omitempty"`
+	SystemFingerprint string                  `json:"system_fingerprint,omitempty"` // 上游实际提供服务的模型版本（Gemini modelVersion）
+	BestOfCandidates  []OpenAIBestOfCandidate `json:"best_of_candidates,omitempty"` // 扩展字段：best-of模式下的全部候选
+}
+
+// OpenAIBestOfCandidate best-of模式下的单个候选结果
+type OpenAIBestOfCandidate struct {
+	Index        int     `json:"index"`
+	Content      string  `json:"content"`
+	FinishReason *string `json:"finish_reason"`
+	Score        float64 `json:"score"`
+	Selected     bool    `json:"selected"`
+	Error        string  `json:"error,omitempty"`
 }
 
 type OpenAIStreamChunk struct {
-	ID      string         `json:"id"`
-	Object  string         `json:"object"`
-	Created int64          `json:"created"`
-	Model   string         `json:"model"`
-	Choices []OpenAIChoice `json:"choices"`
+	ID                string         `json:"id"`
+	Object            string         `json:"object"`
+	Created           int64          `json:"created"`
+	Model             string         `json:"model"`
+	Choices           []OpenAIChoice `json:"choices"`
+	SystemFingerprint string         `json:"system_fingerprint,omitempty"` // 上游实际提供服务的模型版本（Gemini modelVersion）
+	Usage             *OpenAIUsage   `json:"usage,omitempty"`              // 仅在 stream_options.include_usage 的最后一个块中出现
+}
+
+// OpenAIStreamOptions 流式请求选项
+type OpenAIStreamOptions struct {
+	IncludeUsage bool `json:"include_usage,omitempty"` // 在[DONE]之前额外发送一个包含用量的块
+}
+
+// OpenAIParallelRequest 并行多模型请求 (扩展接口)
+type OpenAIParallelRequest struct {
+	OpenAIRequest
+	Models []string `json:"models,omitempty"` // 需要并行请求的模型列表，为空时使用配置中的parallel_models
+}
+
+// OpenAIParallelResult 单个模型的并行请求结果
+type OpenAIParallelResult struct {
+	Model    string          `json:"model"`
+	Response *OpenAIResponse `json:"response,omitempty"`
+	Error    *ErrorDetail    `json:"error,omitempty"`
+}
+
+// OpenAIParallelResponse 并行多模型响应
+type OpenAIParallelResponse struct {
+	Object  string                 `json:"object"`
+	Created int64                  `json:"created"`
+	Results []OpenAIParallelResult `json:"results"`
+}
+
+// OpenAIAsyncRequest 异步聊天请求 (扩展接口)
+type OpenAIAsyncRequest struct {
+	OpenAIRequest
+	Webhook string `json:"webhook,omitempty"` // 完成后接收结果的URL
+}
+
+// AsyncJob 异步任务状态
+type AsyncJob struct {
+	ID        string          `json:"id"`
+	Object    string          `json:"object"`
+	Status    string          `json:"status"`
+	Result    *OpenAIResponse `json:"result,omitempty"`
+	Error     *ErrorDetail    `json:"error,omitempty"`
+	CreatedAt int64           `json:"created_at"`
+	UpdatedAt int64           `json:"updated_at"`
 }
 
 type OpenAIModel struct {
@@ -95,9 +237,143 @@ type OpenAIModelsResponse struct {
 	Data   []OpenAIModel `json:"data"`
 }
 
+// OpenAIEmbeddingRequest OpenAI嵌入请求，input为字符串或字符串数组
+type OpenAIEmbeddingRequest struct {
+	Model          string          `json:"model"`
+	Input          json.RawMessage `json:"input"`
+	EncodingFormat string          `json:"encoding_format,omitempty"` // "float"（默认）或 "base64"
+	Dimensions     *int            `json:"dimensions,omitempty"`
+	User           string          `json:"user,omitempty"`
+}
+
+// OpenAIEmbedding 单个输入的嵌入向量，encoding_format为base64时Embedding为小端float32的base64字符串
+type OpenAIEmbedding struct {
+	Object    string `json:"object"`
+	Index     int    `json:"index"`
+	Embedding any    `json:"embedding"`
+}
+
+// OpenAIEmbeddingUsage 嵌入请求用量
+type OpenAIEmbeddingUsage struct {
+	PromptTokens int `json:"prompt_tokens"`
+	TotalTokens  int `json:"total_tokens"`
+}
+
+// OpenAIEmbeddingResponse OpenAI嵌入响应
+type OpenAIEmbeddingResponse struct {
+	Object string               `json:"object"`
+	Data   []OpenAIEmbedding    `json:"data"`
+	Model  string               `json:"model"`
+	Usage  OpenAIEmbeddingUsage `json:"usage"`
+}
+
+// OpenAIImageRequest OpenAI图像生成请求
+type OpenAIImageRequest struct {
+	Model          string `json:"model"`
+	Prompt         string `json:"prompt"`
+	N              *int   `json:"n,omitempty"`
+	Size           string `json:"size,omitempty"`            // 如 "1024x1024"，映射为最接近的宽高比
+	ResponseFormat string `json:"response_format,omitempty"` // "b64_json"（默认）或 "url"（data URL）
+	User           string `json:"user,omitempty"`
+}
+
+// OpenAIImage 生成的单张图像
+type OpenAIImage struct {
+	B64JSON       string `json:"b64_json,omitempty"`
+	URL           string `json:"url,omitempty"`
+	RevisedPrompt string `json:"revised_prompt,omitempty"`
+}
+
+// OpenAIImageResponse OpenAI图像生成响应
+type OpenAIImageResponse struct {
+	Created int64         `json:"created"`
+	Data    []OpenAIImage `json:"data"`
+}
+
+// OpenAISpeechRequest OpenAI语音合成请求
+type OpenAISpeechRequest struct {
+	Model          string   `json:"model"`
+	Input          string   `json:"input"`
+	Voice          string   `json:"voice"`
+	Instructions   string   `json:"instructions,omitempty"`    // 语气、语速等朗读要求
+	ResponseFormat string   `json:"response_format,omitempty"` // "wav"（默认）或 "pcm"
+	Speed          *float32 `json:"speed,omitempty"`
+}
+
+// OpenAITranscriptionRequest OpenAI语音转写请求（multipart表单）
+type OpenAITranscriptionRequest struct {
+	Model          string
+	File           []byte
+	Filename       string
+	MimeType       string // 表单中文件的Content-Type，无法从文件名推断时使用
+	Language       string // ISO-639-1语言代码
+	Prompt         string // 上下文或专有名词拼写提示
+	ResponseFormat string // "json"（默认）或 "text"
+	Temperature    *float32
+}
+
+// OpenAITranscriptionResponse OpenAI语音转写响应
+type OpenAITranscriptionResponse struct {
+	Text string `json:"text"`
+}
+
 // Gemini原生格式
 type GeminiPart struct {
-	Text string `json:"text"`
+	Text             string                  `json:"text,omitempty"`
+	FunctionCall     *GeminiFunctionCall     `json:"functionCall,omitempty"`
+	FunctionResponse *GeminiFunctionResponse `json:"functionResponse,omitempty"`
+	InlineData       *GeminiInlineData       `json:"inlineData,omitempty"`
+	FileData         *GeminiFileData         `json:"fileData,omitempty"`
+}
+
+// GeminiInlineData 内联的二进制数据（base64编码）
+type GeminiInlineData struct {
+	MimeType string `json:"mimeType"`
+	Data     string `json:"data"`
+}
+
+// GeminiFileData 通过URI引用的文件
+type GeminiFileData struct {
+	MimeType string `json:"mimeType,omitempty"`
+	FileURI  string `json:"fileUri"`
+}
+
+// GeminiFunctionCall 模型返回的函数调用
+type GeminiFunctionCall struct {
+	Name string         `json:"name"`
+	Args map[string]any `json:"args,omitempty"`
+}
+
+// GeminiFunctionResponse 客户端回传的函数执行结果
+type GeminiFunctionResponse struct {
+	Name     string         `json:"name"`
+	Response map[string]any `json:"response"`
+}
+
+// GeminiTool 工具定义，内置工具（搜索、代码执行等）原样透传
+type GeminiTool struct {
+	FunctionDeclarations  []GeminiFunctionDeclaration `json:"functionDeclarations,omitempty"`
+	GoogleSearch          json.RawMessage             `json:"googleSearch,omitempty"`
+	GoogleSearchRetrieval json.RawMessage             `json:"googleSearchRetrieval,omitempty"`
+	CodeExecution         json.RawMessage             `json:"codeExecution,omitempty"`
+}
+
+// GeminiFunctionDeclaration 函数声明，parameters为OpenAPI Schema子集
+type GeminiFunctionDeclaration struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description,omitempty"`
+	Parameters  map[string]any `json:"parameters,omitempty"`
+}
+
+// GeminiToolConfig 工具调用配置
+type GeminiToolConfig struct {
+	FunctionCallingConfig *GeminiFunctionCallingConfig `json:"functionCallingConfig,omitempty"`
+}
+
+// GeminiFunctionCallingConfig 函数调用模式：AUTO、ANY、NONE
+type GeminiFunctionCallingConfig struct {
+	Mode                 string   `json:"mode,omitempty"`
+	AllowedFunctionNames []string `json:"allowedFunctionNames,omitempty"`
 }
 
 type GeminiContent struct {
@@ -115,12 +391,27 @@ type GeminiGenerationConfig struct {
 	TopP            *float32 `json:"topP,omitempty"`
 	MaxOutputTokens *int     `json:"maxOutputTokens,omitempty"`
 	StopSequences   []string `json:"stopSequences,omitempty"`
+	CandidateCount  *int     `json:"candidateCount,omitempty"` // 生成的候选数量
+	Seed            *int     `json:"seed,omitempty"`
+	// 重复惩罚，取值范围 [-2.0, 2.0)
+	PresencePenalty  *float32 `json:"presencePenalty,omitempty"`
+	FrequencyPenalty *float32 `json:"frequencyPenalty,omitempty"`
+	// 返回所选token的对数概率，Logprobs为每一步返回的候选token数
+	ResponseLogprobs *bool `json:"responseLogprobs,omitempty"`
+	Logprobs         *int  `json:"logprobs,omitempty"`
+	// 结构化输出配置
+	ResponseMimeType string         `json:"responseMimeType,omitempty"`
+	ResponseSchema   map[string]any `json:"responseSchema,omitempty"`
 }
 
 type GeminiRequest struct {
 	Contents          []GeminiContent          `json:"contents"`
 	SystemInstruction *GeminiSystemInstruction `json:"system_instruction,omitempty"`
 	GenerationConfig  *GeminiGenerationConfig  `json:"generationConfig,omitempty"`
+	Tools             []GeminiTool             `json:"tools,omitempty"`
+	ToolConfig        *GeminiToolConfig        `json:"toolConfig,omitempty"`
+	// Extra 序列化时原样合并到请求中的额外字段（safetySettings、cachedContent、thinkingConfig等）
+	Extra map[string]any `json:"-"`
 }
 
 // CodeAssistRequest Code Assist API请求格式
@@ -140,11 +431,148 @@ type CodeAssistStreamChunk struct {
 	Response *GeminiStreamChunk `json:"response"`
 }
 
+// GeminiCountTokensRequest countTokens请求格式，contents和generateContentRequest二选一
+type GeminiCountTokensRequest struct {
+	Contents []GeminiContent `json:"contents,omitempty"`
+	// GenerateContentRequest 完整的生成请求，统计时包含系统指令和工具定义
+	GenerateContentRequest *GeminiRequest `json:"generateContentRequest,omitempty"`
+}
+
+// GeminiCountTokensResponse countTokens响应格式
+type GeminiCountTokensResponse struct {
+	TotalTokens             int             `json:"totalTokens"`
+	CachedContentTokenCount int             `json:"cachedContentTokenCount,omitempty"`
+	PromptTokensDetails     json.RawMessage `json:"promptTokensDetails,omitempty"`
+}
+
+// CodeAssistCountTokensRequest Code Assist API countTokens请求格式
+type CodeAssistCountTokensRequest struct {
+	Request *CodeAssistCountTokensBody `json:"request"`
+}
+
+// CodeAssistCountTokensBody Code Assist API countTokens请求体
+type CodeAssistCountTokensBody struct {
+	Model    string          `json:"model"`
+	Contents []GeminiContent `json:"contents"`
+}
+
+// GeminiEmbedContentRequest embedContent请求格式，批量请求中需要填写model
+type GeminiEmbedContentRequest struct {
+	Model                string        `json:"model,omitempty"`
+	Content              GeminiContent `json:"content"`
+	TaskType             string        `json:"taskType,omitempty"`
+	Title                string        `json:"title,omitempty"`
+	OutputDimensionality *int          `json:"outputDimensionality,omitempty"`
+}
+
+// GeminiContentEmbedding 嵌入向量
+type GeminiContentEmbedding struct {
+	Values []float32 `json:"values"`
+}
+
+// GeminiEmbedContentResponse embedContent响应格式
+type GeminiEmbedContentResponse struct {
+	Embedding GeminiContentEmbedding `json:"embedding"`
+}
+
+// GeminiBatchEmbedContentsRequest batchEmbedContents请求格式
+type GeminiBatchEmbedContentsRequest struct {
+	Requests []GeminiEmbedContentRequest `json:"requests"`
+}
+
+// GeminiBatchEmbedContentsResponse batchEmbedContents响应格式
+type GeminiBatchEmbedContentsResponse struct {
+	Embeddings []GeminiContentEmbedding `json:"embeddings"`
+}
+
+// TokenizeRequest 分词工具接口请求
+type TokenizeRequest struct {
+	Model string `json:"model"`
+	Text  string `json:"text"`
+}
+
+// TokenSpan 近似的token边界（按字符偏移）
+type TokenSpan struct {
+	Text  string `json:"text"`
+	Start int    `json:"start"`
+	End   int    `json:"end"`
+}
+
+// TokenizeResponse 分词工具接口响应
+type TokenizeResponse struct {
+	Model       string      `json:"model"`
+	TokenCount  int         `json:"token_count"`
+	Approximate bool        `json:"approximate"` // token_count是否为本地估算值
+	Tokens      []TokenSpan `json:"tokens"`      // 本地估算的token边界
+}
+
+// EstimateResponse 请求预估接口响应：本地估算的提示词token数和代理将对请求做的调整
+type EstimateResponse struct {
+	Model            string                `json:"model"`
+	PromptTokens     int                   `json:"prompt_tokens"`               // 估算的提示词token数（含代理注入的系统指令）
+	SystemTokens     int                   `json:"system_tokens"`               // 其中系统指令的token数
+	ContextWindow    int                   `json:"context_window,omitempty"`    // 模型的上下文窗口，未知模型为0
+	RemainingContext *int                  `json:"remaining_context,omitempty"` // 上下文窗口减去提示词token数
+	MaxOutputTokens  *int                  `json:"max_output_tokens,omitempty"` // 发送时使用的最大输出token数
+	Adjustments      []ParameterAdjustment `json:"adjustments"`
+	Error            string                `json:"error,omitempty"` // 请求会被代理拒绝的原因
+}
+
+// ParameterAdjustment 代理发送前对请求参数的一项调整
+type ParameterAdjustment struct {
+	Parameter string `json:"parameter"`
+	From      any    `json:"from,omitempty"`
+	To        any    `json:"to,omitempty"`
+	Reason    string `json:"reason"`
+}
+
 type GeminiCandidate struct {
-	Content       GeminiContent `json:"content"`
-	FinishReason  string        `json:"finishReason,omitempty"`
-	Index         int           `json:"index,omitempty"`
-	SafetyRatings []interface{} `json:"safetyRatings,omitempty"`
+	Content          GeminiContent           `json:"content"`
+	FinishReason     string                  `json:"finishReason,omitempty"`
+	Index            int                     `json:"index,omitempty"`
+	SafetyRatings    []GeminiSafetyRating    `json:"safetyRatings,omitempty"`
+	CitationMetadata *GeminiCitationMetadata `json:"citationMetadata,omitempty"`
+}
+
+// GeminiSafetyRating 候选或提示词在某个安全类别上的评级
+type GeminiSafetyRating struct {
+	Category         string  `json:"category"`                   // 如 HARM_CATEGORY_HARASSMENT
+	Probability      string  `json:"probability,omitempty"`      // NEGLIGIBLE、LOW、MEDIUM、HIGH
+	ProbabilityScore float64 `json:"probabilityScore,omitempty"` // 仅Vertex AI返回
+	Severity         string  `json:"severity,omitempty"`         // 仅Vertex AI返回
+	SeverityScore    float64 `json:"severityScore,omitempty"`    // 仅Vertex AI返回
+	Blocked          bool    `json:"blocked,omitempty"`          // 是否因该类别被拦截
+}
+
+// GeminiCitationMetadata 候选内容的引用来源，AI Studio使用 citationSources，Vertex AI使用 citations
+type GeminiCitationMetadata struct {
+	CitationSources []GeminiCitation `json:"citationSources,omitempty"`
+	Citations       []GeminiCitation `json:"citations,omitempty"`
+}
+
+// Sources 返回引用来源，兼容两种字段名
+func (m *GeminiCitationMetadata) Sources() []GeminiCitation {
+	if m == nil {
+		return nil
+	}
+	return append(append([]GeminiCitation(nil), m.CitationSources...), m.Citations...)
+}
+
+// GeminiCitation 一条引用：被引用的文本区间（按字节）及来源
+type GeminiCitation struct {
+	StartIndex      int         `json:"startIndex,omitempty"`
+	EndIndex        int         `json:"endIndex,omitempty"`
+	URI             string      `json:"uri,omitempty"`
+	Title           string      `json:"title,omitempty"`
+	License         string      `json:"license,omitempty"`
+	PublicationDate *GeminiDate `json:"publicationDate,omitempty"`
+}
+
+// GeminiDate 日期，未知的部分为0
+type GeminiDate struct {
+	Year  int `json:"year,omitempty"`
+	Month int `json:"month,omitempty"`
+	Day   int `json:"day,omitempty"`
 }
 
 type GeminiUsageMetadata struct {
@@ -154,21 +582,57 @@ type GeminiUsageMetadata struct {
 }
 
 type GeminiResponse struct {
-	Candidates     []GeminiCandidate    `json:"candidates"`
-	UsageMetadata  *GeminiUsageMetadata `json:"usageMetadata,omitempty"`
-	PromptFeedback interface{}          `json:"promptFeedback,omitempty"`
+	Candidates     []GeminiCandidate     `json:"candidates"`
+	UsageMetadata  *GeminiUsageMetadata  `json:"usageMetadata,omitempty"`
+	PromptFeedback *GeminiPromptFeedback `json:"promptFeedback,omitempty"`
+	ModelVersion   string                `json:"modelVersion,omitempty"` // 实际提供服务的模型版本
+}
+
+// GeminiPromptFeedback 上游对提示词的反馈，提示词被拦截时 blockReason 非空且没有候选
+type GeminiPromptFeedback struct {
+	BlockReason        string               `json:"blockReason,omitempty"`        // SAFETY、OTHER、BLOCKLIST、PROHIBITED_CONTENT等
+	BlockReasonMessage string               `json:"blockReasonMessage,omitempty"` // 拦截原因的说明（仅部分接口返回）
+	SafetyRatings      []GeminiSafetyRating `json:"safetyRatings,omitempty"`
+}
+
+// Blocked 提示词是否被上游拦截
+func (f *GeminiPromptFeedback) Blocked() bool {
+	return f != nil && f.BlockReason != "" && f.BlockReason != "BLOCK_REASON_UNSPECIFIED"
 }
 
 // 流式响应
 type GeminiStreamCandidate struct {
-	Content      GeminiContent `json:"content,omitempty"`
-	FinishReason string        `json:"finishReason,omitempty"`
-	Index        int           `json:"index,omitempty"`
+	Content          GeminiContent           `json:"content,omitempty"`
+	FinishReason     string                  `json:"finishReason,omitempty"`
+	Index            int                     `json:"index,omitempty"`
+	SafetyRatings    []GeminiSafetyRating    `json:"safetyRatings,omitempty"`
+	CitationMetadata *GeminiCitationMetadata `json:"citationMetadata,omitempty"`
 }
 
 type GeminiStreamChunk struct {
-	Candidates    []GeminiStreamCandidate `json:"candidates,omitempty"`
-	UsageMetadata *GeminiUsageMetadata    `json:"usageMetadata,omitempty"`
+	Candidates     []GeminiStreamCandidate `json:"candidates,omitempty"`
+	UsageMetadata  *GeminiUsageMetadata    `json:"usageMetadata,omitempty"`
+	PromptFeedback *GeminiPromptFeedback   `json:"promptFeedback,omitempty"` // 仅出现在第一个数据块中
+	ModelVersion   string                  `json:"modelVersion,omitempty"`   // 实际提供服务的模型版本
+}
+
+// CandidateIndex 返回块中第position个候选所属的候选序号（candidateCount>1时各候选交替出现在流中），
+// index为0时被序列化省略，此时按位置编号
+func (c *GeminiStreamChunk) CandidateIndex(position int) int {
+	if index := c.Candidates[position].Index; index != 0 {
+		return index
+	}
+	return position
+}
+
+// Candidate 返回块中序号为index的候选，块中没有该候选时返回nil
+func (c *GeminiStreamChunk) Candidate(index int) *GeminiStreamCandidate {
+	for position := range c.Candidates {
+		if c.CandidateIndex(position) == index {
+			return &c.Candidates[position]
+		}
+	}
+	return nil
 }
 
 // 模型信息
@@ -187,5 +651,6 @@ type GeminiModel struct {
 }
 
 type GeminiModelsResponse struct {
-	Models []GeminiModel `json:"models"`
+	Models        []GeminiModel `json:"models"`
+	NextPageToken string        `json:"nextPageToken,omitempty"`
 }
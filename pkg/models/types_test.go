@@ -361,4 +361,46 @@ func TestOpenAIModelsResponse_JSON(t *testing.T) {
 	assert.Equal(t, modelsResp.Data[0].Created, unmarshaledResp.Data[0].Created)
 	assert.Equal(t, modelsResp.Data[0].OwnedBy, unmarshaledResp.Data[0].OwnedBy)
 	assert.Equal(t, modelsResp.Data[1].ID, unmarshaledResp.Data[1].ID)
-}
\ No newline at end of file
+}
+func TestGeminiStreamChunk_Candidate(t *testing.T) {
+	var chunk GeminiStreamChunk
+	require.NoError(t, json.Unmarshal([]byte(`{"candidates":[{"content":{"parts":[{"text":"b"}]},"index":1}]}`), &chunk))
+	assert.Nil(t, chunk.Candidate(0))
+	require.NotNil(t, chunk.Candidate(1))
+	assert.Equal(t, "b", chunk.Candidate(1).Content.Parts[0].Text)
+
+	// 序号0被省略，按位置编号
+	chunk = GeminiStreamChunk{}
+	require.NoError(t, json.Unmarshal([]byte(`{"candidates":[{"content":{"parts":[{"text":"a"}]}}]}`), &chunk))
+	require.NotNil(t, chunk.Candidate(0))
+	assert.Equal(t, 0, chunk.CandidateIndex(0))
+}
+
+func TestGeminiCandidate_SafetyAndCitations(t *testing.T) {
+	// AI Studio 和 Vertex AI 的响应经过解码再编码后保持不变
+	payloads := []string{
+		`{"candidates":[{"content":{"role":"model","parts":[{"text":"cited"}]},"finishReason":"STOP",
+			"safetyRatings":[{"category":"HARM_CATEGORY_HARASSMENT","probability":"NEGLIGIBLE"},{"category":"HARM_CATEGORY_HATE_SPEECH","probability":"HIGH","blocked":true}],
+			"citationMetadata":{"citationSources":[{"startIndex":1,"endIndex":5,"uri":"https://example.com","license":"mit"}]}}],
+			"promptFeedback":{"safetyRatings":[{"category":"HARM_CATEGORY_DANGEROUS_CONTENT","probability":"LOW"}]}}`,
+		`{"candidates":[{"content":{"role":"model","parts":[{"text":"cited"}]},"finishReason":"STOP",
+			"safetyRatings":[{"category":"HARM_CATEGORY_HARASSMENT","probability":"NEGLIGIBLE","probabilityScore":0.05,"severity":"HARM_SEVERITY_NEGLIGIBLE","severityScore":0.02}],
+			"citationMetadata":{"citations":[{"endIndex":5,"uri":"https://example.org","title":"Example","publicationDate":{"year":2024,"month":3}}]}}]}`,
+	}
+	for _, payload := range payloads {
+		var resp GeminiResponse
+		require.NoError(t, json.Unmarshal([]byte(payload), &resp))
+		data, err := json.Marshal(&resp)
+		require.NoError(t, err)
+		assert.JSONEq(t, payload, string(data))
+
+		candidate := resp.Candidates[0]
+		assert.Equal(t, "HARM_CATEGORY_HARASSMENT", candidate.SafetyRatings[0].Category)
+		sources := candidate.CitationMetadata.Sources()
+		require.Len(t, sources, 1)
+		assert.Equal(t, 5, sources[0].EndIndex)
+	}
+
+	var missing *GeminiCitationMetadata
+	assert.Nil(t, missing.Sources())
+}
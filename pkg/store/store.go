@@ -0,0 +1,279 @@
+// Package store 提供按请求记录用量的嵌入式存储，用于按API密钥统计和计费
+// 记录以JSON行追加写入单个文件，不依赖外部数据库；查询时顺序扫描文件
+package store
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Record 一次请求的记录
+type Record struct {
+	Time             time.Time `json:"time"`
+	Key              string    `json:"key,omitempty"` // 调用方标识：API密钥指纹或 hmac:<密钥ID>
+	Model            string    `json:"model,omitempty"`
+	Method           string    `json:"method"`
+	Path             string    `json:"path"`
+	Status           int       `json:"status"`
+	LatencyMs        int64     `json:"latency_ms"`
+	PromptTokens     int       `json:"prompt_tokens,omitempty"`
+	CompletionTokens int       `json:"completion_tokens,omitempty"`
+	TotalTokens      int       `json:"total_tokens,omitempty"`
+}
+
+// Query 查询条件，零值字段不参与过滤
+type Query struct {
+	Key   string
+	Model string
+	Since time.Time // 包含
+	Until time.Time // 不包含
+	Limit int       // 最多返回的记录数（最新的记录优先），0表示不限制
+}
+
+// match 记录是否满足查询条件
+func (q Query) match(record *Record) bool {
+	if q.Key != "" && record.Key != q.Key {
+		return false
+	}
+	if q.Model != "" && record.Model != q.Model {
+		return false
+	}
+	if !q.Since.IsZero() && record.Time.Before(q.Since) {
+		return false
+	}
+	if !q.Until.IsZero() && !record.Time.Before(q.Until) {
+		return false
+	}
+	return true
+}
+
+// Usage 按调用方和模型汇总的用量
+type Usage struct {
+	Key              string `json:"key"`
+	Model            string `json:"model"`
+	Requests         int    `json:"requests"`
+	Errors           int    `json:"errors"` // 状态码不小于400的请求数
+	PromptTokens     int    `json:"prompt_tokens"`
+	CompletionTokens int    `json:"completion_tokens"`
+	TotalTokens      int    `json:"total_tokens"`
+	AvgLatencyMs     int64  `json:"avg_latency_ms"`
+	totalLatencyMs   int64
+}
+
+// Store 请求记录存储
+type Store struct {
+	mu     sync.Mutex
+	path   string
+	file   *os.File
+	closed bool
+}
+
+// Open 打开（不存在时创建）请求记录文件
+func Open(path string) (*Store, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open request log: %w", err)
+	}
+	return &Store{path: path, file: file}, nil
+}
+
+// Append 追加一条记录
+func (s *Store) Append(record Record) error {
+	if record.Time.IsZero() {
+		record.Time = time.Now().UTC()
+	}
+	data, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to marshal request record: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return fmt.Errorf("request log is closed")
+	}
+	if _, err := s.file.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write request record: %w", err)
+	}
+	return nil
+}
+
+// Query 返回满足条件的记录，按时间从新到旧排列
+func (s *Store) Query(q Query) ([]Record, error) {
+	var records []Record
+	err := s.scan(func(record *Record) {
+		if q.match(record) {
+			records = append(records, *record)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(records, func(i, j int) bool {
+		return records[i].Time.After(records[j].Time)
+	})
+	if q.Limit > 0 && len(records) > q.Limit {
+		records = records[:q.Limit]
+	}
+	return records, nil
+}
+
+// Summarize 按调用方和模型汇总满足条件的记录（忽略Limit）
+func (s *Store) Summarize(q Query) ([]Usage, error) {
+	groups := make(map[[2]string]*Usage)
+	err := s.scan(func(record *Record) {
+		if !q.match(record) {
+			return
+		}
+		id := [2]string{record.Key, record.Model}
+		usage, ok := groups[id]
+		if !ok {
+			usage = &Usage{Key: record.Key, Model: record.Model}
+			groups[id] = usage
+		}
+		usage.Requests++
+		if record.Status >= 400 {
+			usage.Errors++
+		}
+		usage.PromptTokens += record.PromptTokens
+		usage.CompletionTokens += record.CompletionTokens
+		usage.TotalTokens += record.TotalTokens
+		usage.totalLatencyMs += record.LatencyMs
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	summary := make([]Usage, 0, len(groups))
+	for _, usage := range groups {
+		usage.AvgLatencyMs = usage.totalLatencyMs / int64(usage.Requests)
+		summary = append(summary, *usage)
+	}
+	sort.Slice(summary, func(i, j int) bool {
+		if summary[i].Key != summary[j].Key {
+			return summary[i].Key < summary[j].Key
+		}
+		return summary[i].Model < summary[j].Model
+	})
+	return summary, nil
+}
+
+// Purge 删除早于before的记录，返回删除的数量
+func (s *Store) Purge(before time.Time) (int, error) {
+	return s.remove(func(record *Record) bool {
+		return record.Time.Before(before)
+	})
+}
+
+// DeleteKey 删除指定调用方的全部记录，返回删除的数量
+func (s *Store) DeleteKey(key string) (int, error) {
+	if key == "" {
+		return 0, nil
+	}
+	return s.remove(func(record *Record) bool {
+		return record.Key == key
+	})
+}
+
+// Close 关闭存储
+func (s *Store) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	return s.file.Close()
+}
+
+// scan 顺序读取全部记录，跳过无法解析的行
+func (s *Store) scan(fn func(record *Record)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.scanLocked(fn)
+}
+
+// scanLocked 顺序读取全部记录，调用方需持有mu
+func (s *Store) scanLocked(fn func(record *Record)) error {
+	if s.closed {
+		return fmt.Errorf("request log is closed")
+	}
+	file, err := os.Open(s.path)
+	if err != nil {
+		return fmt.Errorf("failed to read request log: %w", err)
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(line) > 1 {
+			var record Record
+			if json.Unmarshal(line, &record) == nil {
+				fn(&record)
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read request log: %w", err)
+		}
+	}
+}
+
+// remove 删除满足条件的记录并重写文件（先写临时文件再重命名）
+func (s *Store) remove(drop func(record *Record) bool) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var kept []Record
+	removed := 0
+	err := s.scanLocked(func(record *Record) {
+		if drop(record) {
+			removed++
+			return
+		}
+		kept = append(kept, *record)
+	})
+	if err != nil || removed == 0 {
+		return 0, err
+	}
+
+	tmpPath := s.path + ".tmp"
+	tmp, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return 0, fmt.Errorf("failed to rewrite request log: %w", err)
+	}
+	writer := bufio.NewWriter(tmp)
+	encoder := json.NewEncoder(writer)
+	for i := range kept {
+		if err := encoder.Encode(&kept[i]); err != nil {
+			tmp.Close()
+			return 0, fmt.Errorf("failed to rewrite request log: %w", err)
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		tmp.Close()
+		return 0, fmt.Errorf("failed to rewrite request log: %w", err)
+	}
+	tmp.Close()
+
+	if err := os.Rename(tmpPath, s.path); err != nil {
+		return 0, fmt.Errorf("failed to replace request log: %w", err)
+	}
+	file, err := os.OpenFile(s.path, os.O_RDWR|os.O_APPEND, 0600)
+	if err != nil {
+		return removed, fmt.Errorf("failed to reopen request log: %w", err)
+	}
+	s.file.Close()
+	s.file = file
+	return removed, nil
+}
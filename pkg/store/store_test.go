@@ -0,0 +1,74 @@
+package store
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStore_AppendQuerySummarize(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "requests.log")
+	s, err := Open(path)
+	require.NoError(t, err)
+
+	start := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	records := []Record{
+		{Time: start, Key: "key:a", Model: "gemini-2.5-flash", Status: 200, LatencyMs: 100, PromptTokens: 10, CompletionTokens: 5, TotalTokens: 15},
+		{Time: start.Add(time.Minute), Key: "key:a", Model: "gemini-2.5-flash", Status: 500, LatencyMs: 300},
+		{Time: start.Add(2 * time.Minute), Key: "key:a", Model: "gemini-2.5-pro", Status: 200, LatencyMs: 200, TotalTokens: 40},
+		{Time: start.Add(3 * time.Minute), Key: "key:b", Model: "gemini-2.5-flash", Status: 200, LatencyMs: 50, TotalTokens: 7},
+	}
+	for _, record := range records {
+		require.NoError(t, s.Append(record))
+	}
+	require.NoError(t, s.Close())
+
+	// 重新打开后记录仍在
+	s, err = Open(path)
+	require.NoError(t, err)
+	defer s.Close()
+
+	result, err := s.Query(Query{Key: "key:a", Limit: 2})
+	require.NoError(t, err)
+	require.Len(t, result, 2)
+	assert.Equal(t, "gemini-2.5-pro", result[0].Model)
+
+	result, err = s.Query(Query{Since: start.Add(time.Minute), Until: start.Add(3 * time.Minute)})
+	require.NoError(t, err)
+	assert.Len(t, result, 2)
+
+	summary, err := s.Summarize(Query{})
+	require.NoError(t, err)
+	require.Len(t, summary, 3)
+	assert.Equal(t, Usage{Key: "key:a", Model: "gemini-2.5-flash", Requests: 2, Errors: 1, PromptTokens: 10, CompletionTokens: 5, TotalTokens: 15, AvgLatencyMs: 200, totalLatencyMs: 400}, summary[0])
+	assert.Equal(t, "key:b", summary[2].Key)
+}
+
+func TestStore_PurgeAndDeleteKey(t *testing.T) {
+	s, err := Open(filepath.Join(t.TempDir(), "requests.log"))
+	require.NoError(t, err)
+	defer s.Close()
+
+	now := time.Now()
+	require.NoError(t, s.Append(Record{Time: now.Add(-48 * time.Hour), Key: "key:a"}))
+	require.NoError(t, s.Append(Record{Time: now, Key: "key:a"}))
+	require.NoError(t, s.Append(Record{Time: now, Key: "key:b"}))
+
+	removed, err := s.Purge(now.Add(-24 * time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, 1, removed)
+
+	removed, err = s.DeleteKey("key:a")
+	require.NoError(t, err)
+	assert.Equal(t, 1, removed)
+
+	// 重写后仍可继续追加
+	require.NoError(t, s.Append(Record{Time: now, Key: "key:c"}))
+	result, err := s.Query(Query{})
+	require.NoError(t, err)
+	require.Len(t, result, 2)
+	assert.ElementsMatch(t, []string{"key:b", "key:c"}, []string{result[0].Key, result[1].Key})
+}
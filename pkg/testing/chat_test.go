@@ -0,0 +1,72 @@
+package testing_test
+
+import (
+	"context"
+	"testing"
+
+	gemini "github.com/ba0gu0/gemini-go-proxy"
+	"github.com/ba0gu0/gemini-go-proxy/pkg/models"
+	proxytest "github.com/ba0gu0/gemini-go-proxy/pkg/testing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChat(t *testing.T) {
+	upstream := proxytest.NewUpstream()
+	defer upstream.Close()
+	proxy := proxytest.NewProxy(upstream, nil, nil)
+	defer proxy.Close()
+
+	chat := gemini.NewChat(proxy.Client, "gemini-2.5-flash", &gemini.ChatOptions{SystemInstruction: "Be brief."})
+	ctx := context.Background()
+
+	upstream.Reply("Hello there")
+	reply, err := chat.Send(ctx, "Hi")
+	require.NoError(t, err)
+	assert.Equal(t, "Hello there", reply)
+
+	upstream.Reply("Second ", "answer")
+	var deltas []string
+	reply, err = chat.Stream(ctx, "And again?", func(text string) error {
+		deltas = append(deltas, text)
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "Second answer", reply)
+	assert.Equal(t, []string{"Second ", "answer"}, deltas)
+
+	// 第二轮请求携带完整的对话历史和系统指令
+	last, ok := upstream.LastRequest()
+	require.True(t, ok)
+	req, err := last.GeminiRequest()
+	require.NoError(t, err)
+	require.Len(t, req.Contents, 3)
+	assert.Equal(t, "model", req.Contents[1].Role)
+	assert.Equal(t, "Hello there", req.Contents[1].Parts[0].Text)
+	require.NotNil(t, req.SystemInstruction)
+	assert.Equal(t, "Be brief.", req.SystemInstruction.Parts[0].Text)
+
+	history := chat.History()
+	require.Len(t, history, 4)
+	assert.Equal(t, "Second answer", history[3].Parts[0].Text)
+	assert.Positive(t, chat.Usage().TotalTokenCount)
+
+	// 失败的请求不写入历史
+	upstream.FailNext(400, 1)
+	_, err = chat.Send(ctx, "Fail")
+	require.Error(t, err)
+	assert.Len(t, chat.History(), 4)
+
+	chat.Reset()
+	assert.Empty(t, chat.History())
+
+	// 请求多个候选时只使用第一个候选
+	candidates := 2
+	multi := gemini.NewChat(proxy.Client, "gemini-2.5-flash", &gemini.ChatOptions{
+		GenerationConfig: &models.GeminiGenerationConfig{CandidateCount: &candidates},
+	})
+	upstream.Reply("First ", "candidate")
+	reply, err = multi.Stream(ctx, "Hi", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "First candidate", reply)
+}
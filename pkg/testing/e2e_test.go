@@ -0,0 +1,1498 @@
+package testing_test
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/ba0gu0/gemini-go-proxy/pkg/audit"
+	"github.com/ba0gu0/gemini-go-proxy/pkg/auth"
+	"github.com/ba0gu0/gemini-go-proxy/pkg/client"
+	"github.com/ba0gu0/gemini-go-proxy/pkg/config"
+	"github.com/ba0gu0/gemini-go-proxy/pkg/jobs"
+	"github.com/ba0gu0/gemini-go-proxy/pkg/models"
+	"github.com/ba0gu0/gemini-go-proxy/pkg/store"
+	proxytest "github.com/ba0gu0/gemini-go-proxy/pkg/testing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var apiModes = []config.APIMode{config.AIStudio, config.VertexAI, config.CodeAssist}
+
+func newProxy(t *testing.T, mode config.APIMode) (*proxytest.Upstream, *proxytest.Proxy) {
+	t.Helper()
+	upstream := proxytest.NewUpstream()
+	t.Cleanup(upstream.Close)
+
+	cfg := config.DefaultConfig()
+	cfg.APIMode = mode
+	proxy := proxytest.NewProxy(upstream, cfg, nil)
+	t.Cleanup(proxy.Close)
+	return upstream, proxy
+}
+
+func chatRequest(stream bool) map[string]any {
+	return map[string]any{
+		"model":  "gemini-2.5-flash",
+		"stream": stream,
+		"messages": []map[string]any{
+			{"role": "system", "content": "Be brief."},
+			{"role": "user", "content": "Hi there"},
+		},
+	}
+}
+
+func TestE2E_ChatCompletions(t *testing.T) {
+	for _, mode := range apiModes {
+		t.Run(string(mode), func(t *testing.T) {
+			upstream, proxy := newProxy(t, mode)
+
+			resp, err := proxy.Post("/v1/chat/completions", chatRequest(false))
+			require.NoError(t, err)
+			defer resp.Body.Close()
+			require.Equal(t, http.StatusOK, resp.StatusCode)
+
+			var chat models.OpenAIResponse
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&chat))
+			require.Len(t, chat.Choices, 1)
+			assert.Equal(t, "Hello from the fake upstream", chat.Choices[0].Message.Content)
+			require.NotNil(t, chat.Choices[0].FinishReason)
+			assert.Equal(t, "stop", *chat.Choices[0].FinishReason)
+
+			// 上游收到转换后的请求
+			req, ok := upstream.LastRequest()
+			require.True(t, ok)
+			assert.Equal(t, mode, req.API)
+			assert.Equal(t, "generateContent", req.Action)
+			assert.Equal(t, "gemini-2.5-flash", req.Model)
+
+			gemini, err := req.GeminiRequest()
+			require.NoError(t, err)
+			require.NotNil(t, gemini.SystemInstruction)
+			assert.Equal(t, "Be brief.", gemini.SystemInstruction.Parts[0].Text)
+			require.Len(t, gemini.Contents, 1)
+			assert.Equal(t, "Hi there", gemini.Contents[0].Parts[0].Text)
+		})
+	}
+}
+
+func TestE2E_ChatCompletionsStream(t *testing.T) {
+	for _, mode := range apiModes {
+		t.Run(string(mode), func(t *testing.T) {
+			upstream, proxy := newProxy(t, mode)
+			upstream.Reply("one", " two", " three")
+
+			resp, err := proxy.Post("/v1/chat/completions", chatRequest(true))
+			require.NoError(t, err)
+			defer resp.Body.Close()
+			require.Equal(t, http.StatusOK, resp.StatusCode)
+			assert.Contains(t, resp.Header.Get("Content-Type"), "text/event-stream")
+
+			var text strings.Builder
+			done := false
+			scanner := bufio.NewScanner(resp.Body)
+			for scanner.Scan() {
+				data, ok := strings.CutPrefix(scanner.Text(), "data: ")
+				if !ok {
+					continue
+				}
+				if data == "[DONE]" {
+					done = true
+					break
+				}
+				var chunk models.OpenAIStreamChunk
+				require.NoError(t, json.Unmarshal([]byte(data), &chunk))
+				for _, choice := range chunk.Choices {
+					text.WriteString(choice.Delta.Content)
+				}
+			}
+			assert.True(t, done, "stream should end with [DONE]")
+			assert.Equal(t, "one two three", text.String())
+
+			req, ok := upstream.LastRequest()
+			require.True(t, ok)
+			assert.Equal(t, "streamGenerateContent", req.Action)
+		})
+	}
+}
+
+func TestE2E_ChatCompletionsStreamUsage(t *testing.T) {
+	_, proxy := newProxy(t, config.AIStudio)
+
+	req := chatRequest(true)
+	req["stream_options"] = map[string]any{"include_usage": true}
+	resp, err := proxy.Post("/v1/chat/completions", req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var chunks []models.OpenAIStreamChunk
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data: ")
+		if !ok || data == "[DONE]" {
+			continue
+		}
+		var chunk models.OpenAIStreamChunk
+		require.NoError(t, json.Unmarshal([]byte(data), &chunk))
+		chunks = append(chunks, chunk)
+	}
+	require.NotEmpty(t, chunks)
+
+	// 最后一个块只包含用量，之前的块不包含
+	last := chunks[len(chunks)-1]
+	assert.Empty(t, last.Choices)
+	require.NotNil(t, last.Usage)
+	assert.Positive(t, last.Usage.TotalTokens)
+	for _, chunk := range chunks[:len(chunks)-1] {
+		assert.Nil(t, chunk.Usage)
+	}
+}
+
+func TestE2E_NativeGenerateContent(t *testing.T) {
+	upstream, proxy := newProxy(t, config.AIStudio)
+	upstream.SetModelVersion("gemini-2.5-flash-001")
+
+	resp, err := proxy.Post("/v1beta/models/gemini-2.5-flash:generateContent", map[string]any{
+		"contents": []map[string]any{{"role": "user", "parts": []map[string]any{{"text": "Hi"}}}},
+	})
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var gemini models.GeminiResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&gemini))
+	require.Len(t, gemini.Candidates, 1)
+	assert.Equal(t, "Hello from the fake upstream", gemini.Candidates[0].Content.Parts[0].Text)
+	assert.Equal(t, "gemini-2.5-flash-001", gemini.ModelVersion)
+}
+
+func TestE2E_RetriesUpstreamErrors(t *testing.T) {
+	upstream := proxytest.NewUpstream()
+	defer upstream.Close()
+
+	// 多个代理时5xx会换用下一个代理重试
+	cfg := config.DefaultConfig()
+	cfg.APIMode = config.AIStudio
+	cfg.ProxyURLs = []string{"http://127.0.0.1:1", "http://127.0.0.1:2"}
+	proxy := proxytest.NewProxy(upstream, cfg, nil)
+	defer proxy.Close()
+
+	upstream.FailNext(http.StatusServiceUnavailable, 1)
+	resp, err := proxy.Post("/v1/chat/completions", chatRequest(false))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Len(t, upstream.Requests(), 2)
+
+	// 重试次数用尽后返回错误
+	upstream.Reset()
+	upstream.FailNext(http.StatusServiceUnavailable, cfg.MaxRetries)
+	resp, err = proxy.Post("/v1/chat/completions", chatRequest(false))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	assert.NotEqual(t, http.StatusOK, resp.StatusCode)
+	assert.Len(t, upstream.Requests(), cfg.MaxRetries)
+}
+
+func TestE2E_Embeddings(t *testing.T) {
+	for _, mode := range []config.APIMode{config.AIStudio, config.VertexAI} {
+		t.Run(string(mode), func(t *testing.T) {
+			_, proxy := newProxy(t, mode)
+
+			resp, err := proxy.Post("/v1/embeddings", map[string]any{
+				"model":      "text-embedding-004",
+				"input":      []string{"first", "second"},
+				"dimensions": 4,
+			})
+			require.NoError(t, err)
+			defer resp.Body.Close()
+			require.Equal(t, http.StatusOK, resp.StatusCode)
+
+			var embeddings struct {
+				Data []struct {
+					Index     int       `json:"index"`
+					Embedding []float32 `json:"embedding"`
+				} `json:"data"`
+			}
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&embeddings))
+			require.Len(t, embeddings.Data, 2)
+			assert.Equal(t, 1, embeddings.Data[1].Index)
+			assert.Len(t, embeddings.Data[0].Embedding, 4)
+		})
+	}
+}
+
+func TestE2E_KeyRateLimits(t *testing.T) {
+	upstream := proxytest.NewUpstream()
+	defer upstream.Close()
+
+	cfg := config.DefaultConfig()
+	cfg.APIMode = config.AIStudio
+	cfg.KeyRateLimits = map[string]config.RateLimit{"*": {RequestsPerMinute: 2, TokensPerDay: 1000}}
+	proxy := proxytest.NewProxy(upstream, cfg, nil)
+	defer proxy.Close()
+
+	for i := 0; i < 2; i++ {
+		resp, err := proxy.Post("/v1/chat/completions", chatRequest(false))
+		require.NoError(t, err)
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "2", resp.Header.Get("x-ratelimit-limit-requests"))
+		assert.Equal(t, strconv.Itoa(1-i), resp.Header.Get("X-Quota-Remaining-Requests"))
+		assert.NotEmpty(t, resp.Header.Get("X-Quota-Remaining-Tokens"))
+	}
+
+	// 超出每分钟请求数时返回OpenAI格式的429
+	resp, err := proxy.Post("/v1/chat/completions", chatRequest(false))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+	assert.NotEmpty(t, resp.Header.Get("Retry-After"))
+
+	var rateLimited struct {
+		Error struct {
+			Type string `json:"type"`
+			Code string `json:"code"`
+		} `json:"error"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&rateLimited))
+	assert.Equal(t, "requests", rateLimited.Error.Type)
+	assert.Equal(t, "rate_limit_exceeded", rateLimited.Error.Code)
+	assert.Len(t, upstream.Requests(), 2)
+
+	// 查询剩余配额
+	req, err := http.NewRequest(http.MethodGet, proxy.URL+"/v1/usage", nil)
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer "+proxy.APIKey)
+	usageResp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer usageResp.Body.Close()
+	require.Equal(t, http.StatusOK, usageResp.StatusCode)
+
+	var usage struct {
+		Limited bool `json:"limited"`
+		Quota   struct {
+			RemainingRequests int `json:"remaining_requests"`
+			TokensUsedToday   int `json:"tokens_used_today"`
+			RemainingTokens   int `json:"remaining_tokens"`
+		} `json:"quota"`
+	}
+	require.NoError(t, json.NewDecoder(usageResp.Body).Decode(&usage))
+	assert.True(t, usage.Limited)
+	assert.Equal(t, 0, usage.Quota.RemainingRequests)
+	assert.Positive(t, usage.Quota.TokensUsedToday)
+	assert.Equal(t, 1000-usage.Quota.TokensUsedToday, usage.Quota.RemainingTokens)
+
+	// GET请求也带剩余用量头部
+	assert.Equal(t, "0", usageResp.Header.Get("X-Quota-Remaining-Requests"))
+	assert.Equal(t, strconv.Itoa(usage.Quota.RemainingTokens), usageResp.Header.Get("X-Quota-Remaining-Tokens"))
+}
+
+func TestE2E_RequestLog(t *testing.T) {
+	upstream := proxytest.NewUpstream()
+	defer upstream.Close()
+
+	cfg := config.DefaultConfig()
+	cfg.APIMode = config.AIStudio
+	cfg.AdminAPIKeys = []string{"admin-key"}
+	proxy := proxytest.NewProxy(upstream, cfg, nil)
+	defer proxy.Close()
+
+	requestLog, err := store.Open(filepath.Join(t.TempDir(), "requests.log"))
+	require.NoError(t, err)
+	defer requestLog.Close()
+	proxy.Server.SetRequestLog(requestLog)
+
+	resp, err := proxy.Post("/v1/chat/completions", chatRequest(false))
+	require.NoError(t, err)
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	adminRequest := func(method, path string) *http.Response {
+		req, err := http.NewRequest(method, proxy.URL+path, nil)
+		require.NoError(t, err)
+		req.Header.Set("Authorization", "Bearer admin-key")
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		return resp
+	}
+
+	// 普通密钥不能查询
+	req, err := http.NewRequest(http.MethodGet, proxy.URL+"/admin/usage", nil)
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer "+proxy.APIKey)
+	forbidden, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	forbidden.Body.Close()
+	assert.Equal(t, http.StatusForbidden, forbidden.StatusCode)
+
+	usageResp := adminRequest(http.MethodGet, "/admin/usage?key="+proxy.APIKey)
+	defer usageResp.Body.Close()
+	require.Equal(t, http.StatusOK, usageResp.StatusCode)
+	var usage struct {
+		Data []store.Usage `json:"data"`
+	}
+	require.NoError(t, json.NewDecoder(usageResp.Body).Decode(&usage))
+	require.Len(t, usage.Data, 1)
+	assert.Equal(t, 1, usage.Data[0].Requests)
+	assert.NotEmpty(t, usage.Data[0].Model)
+	assert.Positive(t, usage.Data[0].TotalTokens)
+
+	// 按密钥删除数据
+	deleteResp := adminRequest(http.MethodDelete, "/admin/data?key="+proxy.APIKey)
+	defer deleteResp.Body.Close()
+	require.Equal(t, http.StatusOK, deleteResp.StatusCode)
+	var deleted struct {
+		RequestsDeleted int `json:"requests_deleted"`
+	}
+	require.NoError(t, json.NewDecoder(deleteResp.Body).Decode(&deleted))
+	assert.Equal(t, 1, deleted.RequestsDeleted)
+}
+
+func TestE2E_MaintenanceMode(t *testing.T) {
+	upstream := proxytest.NewUpstream()
+	defer upstream.Close()
+
+	cfg := config.DefaultConfig()
+	cfg.APIMode = config.AIStudio
+	cfg.AdminAPIKeys = []string{"admin-key"}
+	proxy := proxytest.NewProxy(upstream, cfg, nil)
+	defer proxy.Close()
+
+	setMaintenance := func(body string) {
+		req, err := http.NewRequest(http.MethodPost, proxy.URL+"/admin/maintenance", strings.NewReader(body))
+		require.NoError(t, err)
+		req.Header.Set("Authorization", "Bearer admin-key")
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+	}
+
+	setMaintenance(`{"enabled": true, "retry_after_seconds": 120}`)
+
+	resp, err := proxy.Post("/v1/chat/completions", chatRequest(false))
+	require.NoError(t, err)
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	assert.Equal(t, "120", resp.Header.Get("Retry-After"))
+
+	// 只读接口不受影响
+	req, err := http.NewRequest(http.MethodGet, proxy.URL+"/v1/models", nil)
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer "+proxy.APIKey)
+	modelsResp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	modelsResp.Body.Close()
+	assert.Equal(t, http.StatusOK, modelsResp.StatusCode)
+
+	setMaintenance(`{"enabled": false}`)
+
+	resp, err = proxy.Post("/v1/chat/completions", chatRequest(false))
+	require.NoError(t, err)
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestE2E_DisabledRoutes(t *testing.T) {
+	upstream := proxytest.NewUpstream()
+	defer upstream.Close()
+
+	cfg := config.DefaultConfig()
+	cfg.APIMode = config.AIStudio
+	cfg.DisabledRoutes = []string{"gemini", "models"}
+	proxy := proxytest.NewProxy(upstream, cfg, nil)
+	defer proxy.Close()
+
+	resp, err := proxy.Post("/v1/chat/completions", chatRequest(false))
+	require.NoError(t, err)
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	native, err := proxy.Post("/v1beta/models/gemini-2.5-flash:generateContent", map[string]any{
+		"contents": []map[string]any{{"role": "user", "parts": []map[string]any{{"text": "hi"}}}},
+	})
+	require.NoError(t, err)
+	native.Body.Close()
+	assert.Equal(t, http.StatusNotFound, native.StatusCode)
+
+	req, err := http.NewRequest(http.MethodGet, proxy.URL+"/v1/models", nil)
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer "+proxy.APIKey)
+	modelsResp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	modelsResp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, modelsResp.StatusCode)
+}
+
+func TestE2E_Estimate(t *testing.T) {
+	upstream, proxy := newProxy(t, config.AIStudio)
+
+	req := chatRequest(false)
+	req["max_tokens"] = 10000000
+	req["temperature"] = 5
+	resp, err := proxy.Post("/utils/estimate", req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var estimate models.EstimateResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&estimate))
+	assert.Positive(t, estimate.PromptTokens)
+	assert.Positive(t, estimate.SystemTokens)
+	assert.Equal(t, 1048576, estimate.ContextWindow)
+	require.NotNil(t, estimate.RemainingContext)
+	assert.Equal(t, estimate.ContextWindow-estimate.PromptTokens, *estimate.RemainingContext)
+	require.NotNil(t, estimate.MaxOutputTokens)
+	assert.Equal(t, 65536, *estimate.MaxOutputTokens)
+	var adjusted []string
+	for _, adjustment := range estimate.Adjustments {
+		adjusted = append(adjusted, adjustment.Parameter)
+	}
+	assert.ElementsMatch(t, []string{"generationConfig.maxOutputTokens", "generationConfig.temperature"}, adjusted)
+
+	// Gemini格式通过查询参数指定模型，超出上下文窗口时报告拒绝原因
+	resp, err = proxy.Post("/utils/estimate?model=gemini-pro", map[string]any{
+		"contents": []map[string]any{{"role": "user", "parts": []map[string]any{{"text": strings.Repeat("word ", 40000)}}}},
+	})
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	estimate = models.EstimateResponse{}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&estimate))
+	assert.Negative(t, *estimate.RemainingContext)
+	assert.Contains(t, estimate.Error, "context window")
+
+	// 预估不访问上游
+	assert.Empty(t, upstream.Requests())
+}
+
+func TestE2E_NativeStreamMultipleCandidates(t *testing.T) {
+	// Code Assist 的原生流带有上游的 response 包装，这里只校验 AI Studio 和 Vertex AI
+	for _, mode := range []config.APIMode{config.AIStudio, config.VertexAI} {
+		t.Run(string(mode), func(t *testing.T) {
+			upstream, proxy := newProxy(t, mode)
+			upstream.Reply("one", " two")
+
+			resp, err := proxy.Post("/v1beta/models/gemini-2.5-flash:streamGenerateContent?alt=sse", map[string]any{
+				"contents":         []map[string]any{{"role": "user", "parts": []map[string]any{{"text": "hi"}}}},
+				"generationConfig": map[string]any{"candidateCount": 2},
+			})
+			require.NoError(t, err)
+			defer resp.Body.Close()
+			require.Equal(t, http.StatusOK, resp.StatusCode)
+
+			// 按候选序号分别拼接文本
+			texts := map[int]string{}
+			finished := map[int]string{}
+			scanner := bufio.NewScanner(resp.Body)
+			for scanner.Scan() {
+				data, ok := strings.CutPrefix(scanner.Text(), "data: ")
+				if !ok {
+					continue
+				}
+				var chunk models.GeminiStreamChunk
+				require.NoError(t, json.Unmarshal([]byte(data), &chunk))
+				for position, candidate := range chunk.Candidates {
+					index := chunk.CandidateIndex(position)
+					for _, part := range candidate.Content.Parts {
+						texts[index] += part.Text
+					}
+					if candidate.FinishReason != "" {
+						finished[index] = candidate.FinishReason
+					}
+				}
+			}
+			assert.Equal(t, map[int]string{0: "one two", 1: "[1] one two"}, texts)
+			assert.Equal(t, map[int]string{0: "STOP", 1: "STOP"}, finished)
+		})
+	}
+}
+
+func TestE2E_PromptBlocked(t *testing.T) {
+	body := map[string]any{"contents": []map[string]any{{"role": "user", "parts": []map[string]any{{"text": "hi"}}}}}
+	for _, mode := range apiModes {
+		for _, reverse := range []bool{false, true} {
+			t.Run(fmt.Sprintf("%s/reverse=%v", mode, reverse), func(t *testing.T) {
+				upstream := proxytest.NewUpstream()
+				defer upstream.Close()
+				upstream.BlockPrompt("SAFETY")
+
+				// 默认原样返回 promptFeedback
+				cfg := config.DefaultConfig()
+				cfg.APIMode = mode
+				cfg.NativeReverseProxy = reverse
+				proxy := proxytest.NewProxy(upstream, cfg, nil)
+				defer proxy.Close()
+
+				resp, err := proxy.Post("/v1beta/models/gemini-2.5-flash:generateContent", body)
+				require.NoError(t, err)
+				var generated models.GeminiResponse
+				require.NoError(t, json.NewDecoder(resp.Body).Decode(&generated))
+				resp.Body.Close()
+				assert.Equal(t, http.StatusOK, resp.StatusCode)
+				require.NotNil(t, generated.PromptFeedback)
+				assert.Equal(t, "SAFETY", generated.PromptFeedback.BlockReason)
+				assert.Empty(t, generated.Candidates)
+
+				// 开启 prompt_blocked_as_error 后返回400
+				cfg = config.DefaultConfig()
+				cfg.APIMode = mode
+				cfg.NativeReverseProxy = reverse
+				cfg.PromptBlockedAsError = true
+				strict := proxytest.NewProxy(upstream, cfg, nil)
+				defer strict.Close()
+
+				for _, action := range []string{"generateContent", "streamGenerateContent?alt=sse"} {
+					resp, err := strict.Post("/v1beta/models/gemini-2.5-flash:"+action, body)
+					require.NoError(t, err)
+					data, _ := io.ReadAll(resp.Body)
+					resp.Body.Close()
+					assert.Equal(t, http.StatusBadRequest, resp.StatusCode, action)
+					assert.Contains(t, string(data), "SAFETY", action)
+				}
+
+				// 未被拦截的流式响应不受影响
+				upstream.Reply("one", " two")
+				resp, err = strict.Post("/v1beta/models/gemini-2.5-flash:streamGenerateContent?alt=sse", body)
+				require.NoError(t, err)
+				data, _ := io.ReadAll(resp.Body)
+				resp.Body.Close()
+				assert.Equal(t, http.StatusOK, resp.StatusCode)
+				assert.Contains(t, string(data), " two")
+			})
+		}
+	}
+}
+
+func TestE2E_GeminiPassthrough(t *testing.T) {
+	upstream, proxy := newProxy(t, config.AIStudio)
+
+	req, err := http.NewRequest(http.MethodPost, proxy.URL+"/v1beta/cachedContents?key="+proxy.APIKey+"&alt=json",
+		strings.NewReader(`{"model":"models/gemini-2.5-flash","ttl":"300s"}`))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Goog-Upload-Protocol", "raw")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	data, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.JSONEq(t, `{"name":"cachedContents/fake-cache","model":"models/gemini-2.5-flash","ttl":"300s"}`, string(data))
+
+	// 请求原样转发，客户端密钥被替换为上游认证
+	last, ok := upstream.LastRequest()
+	require.True(t, ok)
+	assert.Equal(t, "/v1beta/cachedContents", last.Path)
+	assert.Equal(t, "json", last.Query.Get("alt"))
+	assert.Empty(t, last.Query.Get("key"))
+	assert.Equal(t, "raw", last.Header.Get("X-Goog-Upload-Protocol"))
+	assert.NotContains(t, last.Header.Get("Authorization"), proxy.APIKey)
+
+	// 上游错误的状态码和响应体原样返回
+	req, err = http.NewRequest(http.MethodGet, proxy.URL+"/v1beta/tunedModels/missing", nil)
+	require.NoError(t, err)
+	req.Header.Set("x-goog-api-key", proxy.APIKey)
+	resp, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	last, _ = upstream.LastRequest()
+	assert.Equal(t, "/v1beta/tunedModels/missing", last.Path)
+
+	// 未携带密钥的请求不会被转发
+	resp, err = http.Get(proxy.URL + "/v1beta/cachedContents")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	// 生成类方法不透传
+	for _, path := range []string{"/v1beta/models/gemini-2.5-flash:batchGenerateContent", "/v1beta/models/aqa:generateAnswer", "/v1beta/tunedModels/my-model:generateText"} {
+		resp, err = proxy.Post(path, map[string]any{})
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode, path)
+	}
+	last, _ = upstream.LastRequest()
+	assert.Equal(t, "/v1beta/tunedModels/missing", last.Path)
+}
+
+func TestE2E_GeminiPassthroughAIStudioOnly(t *testing.T) {
+	for _, mode := range []config.APIMode{config.VertexAI, config.CodeAssist} {
+		upstream, proxy := newProxy(t, mode)
+		resp, err := proxy.Post("/v1beta/cachedContents", map[string]any{"model": "models/gemini-2.5-flash"})
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode, mode)
+		_, ok := upstream.LastRequest()
+		assert.False(t, ok, mode)
+	}
+}
+
+func TestE2E_ResponseHeaders(t *testing.T) {
+	upstream := proxytest.NewUpstream()
+	defer upstream.Close()
+	cfg := config.DefaultConfig()
+	cfg.APIMode = config.AIStudio
+	cfg.ResponseHeaders = map[string]string{
+		"strict-transport-security": "max-age=63072000",
+		"Cache-Control":             "no-store",
+		"Bad Header":                "ignored",
+	}
+	proxy := proxytest.NewProxy(upstream, cfg, nil)
+	defer proxy.Close()
+
+	for _, path := range []string{"/health", "/no-such-route"} {
+		resp, err := http.Get(proxy.URL + path)
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, "max-age=63072000", resp.Header.Get("Strict-Transport-Security"), path)
+		assert.Equal(t, "no-store", resp.Header.Get("Cache-Control"), path)
+		assert.Empty(t, resp.Header.Get("Bad Header"), path)
+	}
+
+	// 处理器设置的同名头部优先
+	resp, err := proxy.Post("/v1/chat/completions", chatRequest(true))
+	require.NoError(t, err)
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	assert.Equal(t, "max-age=63072000", resp.Header.Get("Strict-Transport-Security"))
+	assert.Equal(t, "no-cache", resp.Header.Get("Cache-Control"))
+}
+
+func TestE2E_FileUpload(t *testing.T) {
+	upstream, proxy := newProxy(t, config.AIStudio)
+
+	// 开始可续传上传，上传地址被改写为代理地址
+	req, err := http.NewRequest(http.MethodPost, proxy.URL+"/upload/v1beta/files", strings.NewReader(`{"file":{"display_name":"report"}}`))
+	require.NoError(t, err)
+	req.Header.Set("x-goog-api-key", proxy.APIKey)
+	req.Header.Set("X-Goog-Upload-Protocol", "resumable")
+	req.Header.Set("X-Goog-Upload-Command", "start")
+	req.Header.Set("X-Goog-Upload-Header-Content-Type", "application/pdf")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	uploadURL := resp.Header.Get("X-Goog-Upload-URL")
+	assert.Equal(t, proxy.URL+"/upload/v1beta/files?upload_id=fake-upload&upload_protocol=resumable", uploadURL)
+
+	// 上传文件内容
+	req, err = http.NewRequest(http.MethodPost, uploadURL, strings.NewReader("%PDF-1.7 content"))
+	require.NoError(t, err)
+	req.Header.Set("x-goog-api-key", proxy.APIKey)
+	req.Header.Set("X-Goog-Upload-Command", "upload, finalize")
+	req.Header.Set("X-Goog-Upload-Offset", "0")
+	resp, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	var uploaded struct {
+		File struct {
+			Name      string `json:"name"`
+			SizeBytes string `json:"sizeBytes"`
+		} `json:"file"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&uploaded))
+	resp.Body.Close()
+	assert.Equal(t, "files/fake-file", uploaded.File.Name)
+	assert.Equal(t, "16", uploaded.File.SizeBytes)
+	assert.Equal(t, "final", resp.Header.Get("X-Goog-Upload-Status"))
+
+	last, _ := upstream.LastRequest()
+	assert.Equal(t, "/upload/v1beta/files", last.Path)
+	assert.Equal(t, "%PDF-1.7 content", string(last.Body))
+
+	// 在OpenAI格式的消息中引用已上传的文件
+	resp, err = proxy.Post("/v1/chat/completions", map[string]any{
+		"model": "gemini-2.5-flash",
+		"messages": []map[string]any{{"role": "user", "content": []map[string]any{
+			{"type": "text", "text": "Summarize"},
+			{"type": "file", "file": map[string]any{"file_id": uploaded.File.Name, "filename": "report.pdf"}},
+		}}},
+	})
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	last, _ = upstream.LastRequest()
+	generate, err := last.GeminiRequest()
+	require.NoError(t, err)
+	parts := generate.Contents[0].Parts
+	require.Len(t, parts, 2)
+	assert.Equal(t, &models.GeminiFileData{MimeType: "application/pdf", FileURI: "https://generativelanguage.googleapis.com/v1beta/files/fake-file"}, parts[1].FileData)
+}
+
+func TestE2E_ImageGeneration(t *testing.T) {
+	for _, mode := range apiModes {
+		t.Run(string(mode), func(t *testing.T) {
+			upstream, proxy := newProxy(t, mode)
+
+			// Gemini图像输出模型通过 responseModalities 生成图像
+			resp, err := proxy.Post("/v1/images/generations", map[string]any{
+				"model":  "gemini-2.5-flash-image",
+				"prompt": "a lighthouse",
+				"n":      2,
+				"size":   "1024x1792",
+			})
+			require.NoError(t, err)
+			defer resp.Body.Close()
+			require.Equal(t, http.StatusOK, resp.StatusCode)
+
+			var images models.OpenAIImageResponse
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&images))
+			require.Len(t, images.Data, 2)
+			assert.Equal(t, base64.StdEncoding.EncodeToString(proxytest.FakeImage), images.Data[0].B64JSON)
+			assert.Len(t, upstream.Requests(), 2)
+
+			last, _ := upstream.LastRequest()
+			generate := string(last.Body)
+			assert.Contains(t, generate, `"responseModalities":["TEXT","IMAGE"]`)
+			assert.Contains(t, generate, `"aspectRatio":"9:16"`)
+
+			// Imagen模型使用predict接口，Code Assist不支持
+			resp, err = proxy.Post("/v1/images/generations", map[string]any{
+				"model":  "imagen-3.0-generate-002",
+				"prompt": "a lighthouse",
+				"n":      3,
+			})
+			require.NoError(t, err)
+			defer resp.Body.Close()
+			if mode == config.CodeAssist {
+				assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+				return
+			}
+			require.Equal(t, http.StatusOK, resp.StatusCode)
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&images))
+			assert.Len(t, images.Data, 3)
+
+			last, _ = upstream.LastRequest()
+			assert.Equal(t, "predict", last.Action)
+			assert.Equal(t, "imagen-3.0-generate-002", last.Model)
+		})
+	}
+}
+
+func TestE2E_NativePredict(t *testing.T) {
+	paths := map[config.APIMode]string{
+		config.AIStudio: "/v1beta/models/imagen-3.0-generate-002:predict",
+		config.VertexAI: "/vertex/v1/projects/p/locations/us-central1/publishers/google/models/imagen-3.0-generate-002:predict",
+	}
+	for mode, path := range paths {
+		t.Run(string(mode), func(t *testing.T) {
+			upstream, proxy := newProxy(t, mode)
+
+			resp, err := proxy.Post(path, map[string]any{
+				"instances":  []map[string]any{{"prompt": "a lighthouse"}},
+				"parameters": map[string]any{"sampleCount": 2},
+			})
+			require.NoError(t, err)
+			defer resp.Body.Close()
+			require.Equal(t, http.StatusOK, resp.StatusCode)
+
+			var predict struct {
+				Predictions []struct {
+					BytesBase64Encoded string `json:"bytesBase64Encoded"`
+				} `json:"predictions"`
+			}
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&predict))
+			assert.Len(t, predict.Predictions, 2)
+
+			last, _ := upstream.LastRequest()
+			assert.Equal(t, mode, last.API)
+			assert.Equal(t, "predict", last.Action)
+		})
+	}
+}
+
+func TestE2E_StreamResume(t *testing.T) {
+	upstream := proxytest.NewUpstream()
+	defer upstream.Close()
+
+	cfg := config.DefaultConfig()
+	cfg.APIMode = config.AIStudio
+	cfg.APIKeys = []string{"gp-first", "gp-second"}
+	cfg.StreamResumeBuffer = 3
+	proxy := proxytest.NewProxy(upstream, cfg, nil)
+	defer proxy.Close()
+
+	resume := func(token, apiKey, lastEventID string) *http.Response {
+		req, err := http.NewRequest(http.MethodGet, proxy.URL+"/v1/chat/completions/streams/"+token, nil)
+		require.NoError(t, err)
+		req.Header.Set("Authorization", "Bearer "+apiKey)
+		req.Header.Set("Last-Event-ID", lastEventID)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		return resp
+	}
+
+	upstream.Reply("one ", "two ", "three ", "four")
+	resp, err := proxy.Post("/v1/chat/completions", chatRequest(true))
+	require.NoError(t, err)
+	token := resp.Header.Get(proxytest.StreamResumeTokenHeader)
+	require.NotEmpty(t, token)
+
+	// 读取第一个事件后断开连接
+	reader := bufio.NewReader(resp.Body)
+	line, err := reader.ReadString('\n')
+	require.NoError(t, err)
+	assert.Equal(t, "id: 1\n", line)
+	resp.Body.Close()
+
+	// 续传只保留最近3个事件，第一个事件之后的部分已被丢弃
+	gone := resume(token, "gp-first", "1")
+	gone.Body.Close()
+	assert.Equal(t, http.StatusGone, gone.StatusCode)
+
+	// 其他密钥不能续传
+	other := resume(token, "gp-second", "")
+	other.Body.Close()
+	assert.Equal(t, http.StatusNotFound, other.StatusCode)
+
+	// 从仍在缓冲区内的事件之后续传，直到 [DONE]
+	resumed := resume(token, "gp-first", "3")
+	defer resumed.Body.Close()
+	require.Equal(t, http.StatusOK, resumed.StatusCode)
+	body, err := io.ReadAll(resumed.Body)
+	require.NoError(t, err)
+	assert.NotContains(t, string(body), "id: 3\n")
+	assert.Contains(t, string(body), "id: 4\n")
+	assert.Contains(t, string(body), "four")
+	assert.True(t, strings.HasSuffix(string(body), "data: [DONE]\n\n"))
+	// 续传的连接读完后带有用量等trailer
+	assert.NotEmpty(t, resumed.Trailer.Get("X-Proxy-Duration-Ms"))
+	assert.NotEmpty(t, resumed.Trailer.Get("X-Proxy-Upstream-Latency-Ms"))
+}
+
+func TestE2E_StreamResumeTrailers(t *testing.T) {
+	upstream := proxytest.NewUpstream()
+	defer upstream.Close()
+
+	cfg := config.DefaultConfig()
+	cfg.APIMode = config.AIStudio
+	cfg.StreamResumeBuffer = 8
+	proxy := proxytest.NewProxy(upstream, cfg, nil)
+	defer proxy.Close()
+
+	upstream.Reply("Hello", " world")
+	resp, err := proxy.Post("/v1/chat/completions", chatRequest(true))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.NotEmpty(t, resp.Header.Get(proxytest.StreamResumeTokenHeader))
+
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.True(t, strings.HasSuffix(string(body), "data: [DONE]\n\n"))
+	// 生成端写入缓冲区，trailer仍需发送到客户端连接上
+	assert.NotEmpty(t, resp.Trailer.Get("X-Proxy-Duration-Ms"))
+	assert.NotEmpty(t, resp.Trailer.Get("X-Proxy-Upstream-Latency-Ms"))
+}
+
+func TestE2E_Pipelines(t *testing.T) {
+	upstream := proxytest.NewUpstream()
+	defer upstream.Close()
+
+	temperature := float32(0.1)
+	cfg := config.DefaultConfig()
+	cfg.APIMode = config.AIStudio
+	cfg.MaxRetries = 1
+	cfg.Pipelines = map[string]config.Pipeline{
+		"support-bot": {PipelineVersion: config.PipelineVersion{
+			Model:        "gemini-2.5-pro",
+			Fallbacks:    []string{"gemini-2.5-flash"},
+			SystemPrompt: "You are a support agent.",
+			Temperature:  &temperature,
+		}},
+	}
+	proxy := proxytest.NewProxy(upstream, cfg, nil)
+	defer proxy.Close()
+
+	request := chatRequest(false)
+	request["model"] = "support-bot"
+	resp, err := proxy.Post("/v1/chat/completions", request)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var completion models.OpenAIResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&completion))
+	assert.Equal(t, "support-bot", completion.Model)
+
+	last, _ := upstream.LastRequest()
+	assert.Equal(t, "gemini-2.5-pro", last.Model)
+	geminiReq, err := last.GeminiRequest()
+	require.NoError(t, err)
+	require.NotNil(t, geminiReq.SystemInstruction)
+	assert.Equal(t, "You are a support agent.", geminiReq.SystemInstruction.Parts[0].Text)
+	assert.Equal(t, float32(0.1), *geminiReq.GenerationConfig.Temperature)
+
+	// 主模型失败时使用候补模型
+	upstream.FailNext(http.StatusServiceUnavailable, 1)
+	resp, err = proxy.Post("/v1/chat/completions", request)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	requests := upstream.Requests()
+	assert.Equal(t, "gemini-2.5-pro", requests[len(requests)-2].Model)
+	assert.Equal(t, "gemini-2.5-flash", requests[len(requests)-1].Model)
+}
+
+func TestE2E_AudioSpeech(t *testing.T) {
+	for _, mode := range apiModes {
+		t.Run(string(mode), func(t *testing.T) {
+			upstream, proxy := newProxy(t, mode)
+
+			resp, err := proxy.Post("/v1/audio/speech", map[string]any{
+				"model":        "tts-1",
+				"input":        "Hello there",
+				"voice":        "echo",
+				"instructions": "Say cheerfully",
+			})
+			require.NoError(t, err)
+			defer resp.Body.Close()
+			require.Equal(t, http.StatusOK, resp.StatusCode)
+			assert.Equal(t, "audio/wav", resp.Header.Get("Content-Type"))
+
+			audio, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+			assert.Equal(t, "RIFF", string(audio[:4]))
+			assert.Equal(t, proxytest.FakeAudio, audio[44:])
+
+			last, _ := upstream.LastRequest()
+			assert.Equal(t, "gemini-2.5-flash-preview-tts", last.Model)
+			body := string(last.Body)
+			assert.Contains(t, body, `"responseModalities":["AUDIO"]`)
+			assert.Contains(t, body, `"voiceName":"Puck"`)
+			assert.Contains(t, body, `Say cheerfully:\nHello there`)
+
+			// 无法在代理中编码的格式返回400
+			resp, err = proxy.Post("/v1/audio/speech", map[string]any{"model": "tts-1", "input": "Hi", "response_format": "mp3"})
+			require.NoError(t, err)
+			resp.Body.Close()
+			assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+		})
+	}
+}
+
+func TestE2E_AudioTranscription(t *testing.T) {
+	upstream, proxy := newProxy(t, config.AIStudio)
+	upstream.Reply("  Hello from the recording.  ")
+
+	transcribe := func(fields map[string]string, filename string) *http.Response {
+		var body bytes.Buffer
+		writer := multipart.NewWriter(&body)
+		for name, value := range fields {
+			writer.WriteField(name, value)
+		}
+		part, err := writer.CreateFormFile("file", filename)
+		require.NoError(t, err)
+		part.Write([]byte("ID3 fake mp3"))
+		require.NoError(t, writer.Close())
+
+		req, err := http.NewRequest(http.MethodPost, proxy.URL+"/v1/audio/transcriptions", &body)
+		require.NoError(t, err)
+		req.Header.Set("Authorization", "Bearer "+proxy.APIKey)
+		req.Header.Set("Content-Type", writer.FormDataContentType())
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		return resp
+	}
+
+	resp := transcribe(map[string]string{"model": "whisper-1", "language": "en", "temperature": "0"}, "meeting.mp3")
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var transcription models.OpenAITranscriptionResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&transcription))
+	assert.Equal(t, "Hello from the recording.", transcription.Text)
+
+	last, _ := upstream.LastRequest()
+	assert.Equal(t, "gemini-2.5-flash", last.Model)
+	geminiReq, err := last.GeminiRequest()
+	require.NoError(t, err)
+	parts := geminiReq.Contents[0].Parts
+	require.Len(t, parts, 2)
+	assert.Equal(t, "audio/mp3", parts[0].InlineData.MimeType)
+	assert.Equal(t, base64.StdEncoding.EncodeToString([]byte("ID3 fake mp3")), parts[0].InlineData.Data)
+	assert.Contains(t, parts[1].Text, `"en"`)
+
+	upstream.Reply("plain transcript")
+	resp = transcribe(map[string]string{"model": "whisper-1", "response_format": "text"}, "meeting.wav")
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	text, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, "plain transcript", string(text))
+
+	// 无法识别的文件类型
+	resp = transcribe(map[string]string{"model": "whisper-1"}, "notes.txt")
+	resp.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func TestE2E_ModelMappings(t *testing.T) {
+	upstream := proxytest.NewUpstream()
+	defer upstream.Close()
+
+	cfg := config.DefaultConfig()
+	cfg.APIMode = config.VertexAI
+	cfg.ModelMappings = map[string]string{
+		"gpt-4o":                 "gemini-2.5-pro",
+		"text-embedding-3-small": "text-embedding-004",
+	}
+	proxy := proxytest.NewProxy(upstream, cfg, nil)
+	defer proxy.Close()
+
+	for _, stream := range []bool{false, true} {
+		request := chatRequest(stream)
+		request["model"] = "gpt-4o"
+		resp, err := proxy.Post("/v1/chat/completions", request)
+		require.NoError(t, err)
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		last, _ := upstream.LastRequest()
+		assert.Equal(t, "gemini-2.5-pro", last.Model)
+	}
+
+	resp, err := proxy.Post("/v1/embeddings", map[string]any{"model": "text-embedding-3-small", "input": "hello"})
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	last, _ := upstream.LastRequest()
+	assert.Equal(t, "text-embedding-004", last.Model)
+
+	// 别名出现在模型列表中
+	req, err := http.NewRequest(http.MethodGet, proxy.URL+"/v1/models", nil)
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer "+proxy.APIKey)
+	resp, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	var list models.OpenAIModelsResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&list))
+	var ids []string
+	for _, model := range list.Data {
+		ids = append(ids, model.ID)
+	}
+	assert.Contains(t, ids, "gpt-4o")
+	assert.Contains(t, ids, "text-embedding-3-small")
+	assert.Contains(t, ids, "gemini-2.5-flash")
+}
+
+func TestE2E_PipelineRollout(t *testing.T) {
+	upstream := proxytest.NewUpstream()
+	defer upstream.Close()
+
+	cfg := config.DefaultConfig()
+	cfg.APIMode = config.AIStudio
+	cfg.AdminAPIKeys = []string{"admin-key"}
+	cfg.Pipelines = map[string]config.Pipeline{
+		"support-bot": {PipelineVersion: config.PipelineVersion{Model: "gemini-2.5-flash", SystemPrompt: "v1 prompt"}},
+	}
+	proxy := proxytest.NewProxy(upstream, cfg, nil)
+	defer proxy.Close()
+
+	admin := func(method, path, body string) int {
+		req, err := http.NewRequest(method, proxy.URL+path, strings.NewReader(body))
+		require.NoError(t, err)
+		req.Header.Set("Authorization", "Bearer admin-key")
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	systemPrompt := func() string {
+		request := chatRequest(false)
+		request["model"] = "support-bot"
+		resp, err := proxy.Post("/v1/chat/completions", request)
+		require.NoError(t, err)
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		last, _ := upstream.LastRequest()
+		geminiReq, err := last.GeminiRequest()
+		require.NoError(t, err)
+		return geminiReq.SystemInstruction.Parts[0].Text
+	}
+
+	assert.Equal(t, http.StatusOK, admin(http.MethodPut, "/admin/pipelines/support-bot/versions/v2", `{"model": "gemini-2.5-pro", "system_prompt": "v2 prompt"}`))
+	assert.Equal(t, http.StatusConflict, admin(http.MethodPut, "/admin/pipelines/support-bot/versions/default", `{"model": "gemini-2.5-pro"}`))
+	assert.Equal(t, http.StatusNotFound, admin(http.MethodPut, "/admin/pipelines/other/rollout", `{"rollout": {"v2": 10}}`))
+	assert.Equal(t, http.StatusBadRequest, admin(http.MethodPut, "/admin/pipelines/support-bot/rollout", `{"rollout": {"v3": 10}}`))
+	assert.Equal(t, "v1 prompt", systemPrompt())
+
+	// 全部流量切到新版本
+	assert.Equal(t, http.StatusOK, admin(http.MethodPut, "/admin/pipelines/support-bot/rollout", `{"rollout": {"v2": 100}}`))
+	assert.Equal(t, "v2 prompt", systemPrompt())
+	last, _ := upstream.LastRequest()
+	assert.Equal(t, "gemini-2.5-pro", last.Model)
+
+	// 回滚后立即恢复稳定版本
+	assert.Equal(t, http.StatusOK, admin(http.MethodPost, "/admin/pipelines/support-bot/rollback", ""))
+	assert.Equal(t, "v1 prompt", systemPrompt())
+
+	req, err := http.NewRequest(http.MethodGet, proxy.URL+"/admin/pipelines", nil)
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer "+proxy.APIKey)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+}
+
+func TestE2E_AdminAccounts(t *testing.T) {
+	upstream := proxytest.NewUpstream()
+	defer upstream.Close()
+
+	cfg := config.DefaultConfig()
+	cfg.APIMode = config.AIStudio
+	cfg.AdminAPIKeys = []string{"admin-key"}
+	proxy := proxytest.NewProxy(upstream, cfg, nil)
+	defer proxy.Close()
+	proxy.Server.SetOAuthHandler(auth.NewGoogleAuth(&models.GoogleAuthConfig{
+		RedirectURL: proxy.URL + "/oauth/callback",
+		ProjectID:   proxytest.DefaultProjectID,
+	}, nil))
+
+	admin := func(method, path, body, key string) (int, map[string]any) {
+		req, err := http.NewRequest(method, proxy.URL+path, strings.NewReader(body))
+		require.NoError(t, err)
+		req.Header.Set("Authorization", "Bearer "+key)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		var result map[string]any
+		json.NewDecoder(resp.Body).Decode(&result)
+		return resp.StatusCode, result
+	}
+	token := func(accessToken string) string {
+		return base64.StdEncoding.EncodeToString([]byte(`{"access_token": "` + accessToken + `", "refresh_token": "refresh"}`))
+	}
+
+	status, _ := admin(http.MethodGet, "/admin/accounts", "", proxy.APIKey)
+	assert.Equal(t, http.StatusForbidden, status)
+
+	status, result := admin(http.MethodPost, "/admin/accounts", `{"token": "`+token("first")+`"}`, "admin-key")
+	require.Equal(t, http.StatusCreated, status)
+	assert.EqualValues(t, 0, result["id"])
+
+	status, _ = admin(http.MethodPost, "/admin/accounts", `{"token": "not base64"}`, "admin-key")
+	assert.Equal(t, http.StatusBadRequest, status)
+
+	// 不带token时返回在新标签页打开的授权地址
+	status, result = admin(http.MethodPost, "/admin/accounts", "", "admin-key")
+	require.Equal(t, http.StatusAccepted, status)
+	assert.Contains(t, result["auth_url"], "state=")
+
+	status, _ = admin(http.MethodDelete, "/admin/accounts/0", "", "admin-key")
+	assert.Equal(t, http.StatusConflict, status)
+
+	status, result = admin(http.MethodPost, "/admin/accounts", `{"token": "`+token("second")+`"}`, "admin-key")
+	require.Equal(t, http.StatusCreated, status)
+	assert.EqualValues(t, 1, result["id"])
+
+	status, _ = admin(http.MethodDelete, "/admin/accounts/0", "", "admin-key")
+	assert.Equal(t, http.StatusOK, status)
+	status, _ = admin(http.MethodDelete, "/admin/accounts/0", "", "admin-key")
+	assert.Equal(t, http.StatusNotFound, status)
+
+	status, result = admin(http.MethodGet, "/admin/accounts", "", "admin-key")
+	require.Equal(t, http.StatusOK, status)
+	data := result["data"].([]any)
+	require.Len(t, data, 2)
+	assert.Equal(t, auth.AccountStatusRemoved, data[0].(map[string]any)["status"])
+	assert.Equal(t, auth.AccountStatusActive, data[1].(map[string]any)["status"])
+}
+
+func TestE2E_ModelLists(t *testing.T) {
+	for _, mode := range apiModes {
+		t.Run(string(mode), func(t *testing.T) {
+			upstream, proxy := newProxy(t, mode)
+
+			get := func(path string, out any) {
+				req, err := http.NewRequest(http.MethodGet, proxy.URL+path, nil)
+				require.NoError(t, err)
+				req.Header.Set("Authorization", "Bearer "+proxy.APIKey)
+				resp, err := http.DefaultClient.Do(req)
+				require.NoError(t, err)
+				defer resp.Body.Close()
+				require.Equal(t, http.StatusOK, resp.StatusCode)
+				require.NoError(t, json.NewDecoder(resp.Body).Decode(out))
+			}
+
+			var native models.GeminiModelsResponse
+			get("/v1beta/models", &native)
+			require.Len(t, native.Models, 1)
+			assert.Equal(t, "models/gemini-2.5-flash", native.Models[0].Name)
+			assert.Equal(t, 1048576, native.Models[0].InputTokenLimit)
+			assert.Contains(t, native.Models[0].SupportedMethods, "generateContent")
+
+			var openai models.OpenAIModelsResponse
+			get("/v1/models", &openai)
+			require.Len(t, openai.Data, 1)
+			assert.Equal(t, "gemini-2.5-flash", openai.Data[0].ID)
+
+			// 两种格式共用按模式缓存的模型目录
+			modelRequests := 0
+			for _, request := range upstream.Requests() {
+				if request.Action == "models" {
+					modelRequests++
+				}
+			}
+			assert.Equal(t, 1, modelRequests)
+		})
+	}
+}
+
+func TestE2E_GeminiModelDetail(t *testing.T) {
+	for _, mode := range apiModes {
+		t.Run(string(mode), func(t *testing.T) {
+			_, proxy := newProxy(t, mode)
+
+			get := func(path string) *http.Response {
+				resp, err := http.Get(proxy.URL + path + "?key=" + proxy.APIKey)
+				require.NoError(t, err)
+				t.Cleanup(func() { resp.Body.Close() })
+				return resp
+			}
+
+			for _, path := range []string{"/v1beta/models/gemini-2.5-flash", "/gemini/v1/models/gemini-2.5-flash"} {
+				resp := get(path)
+				require.Equal(t, http.StatusOK, resp.StatusCode, path)
+				var model models.GeminiModel
+				require.NoError(t, json.NewDecoder(resp.Body).Decode(&model))
+				assert.Equal(t, "models/gemini-2.5-flash", model.Name)
+				assert.Equal(t, 65536, model.OutputTokenLimit)
+				assert.Contains(t, model.SupportedMethods, "generateContent")
+			}
+
+			assert.Equal(t, http.StatusNotFound, get("/v1beta/models/no-such-model").StatusCode)
+		})
+	}
+}
+
+func TestE2E_Interceptors(t *testing.T) {
+	for _, mode := range apiModes {
+		t.Run(string(mode), func(t *testing.T) {
+			upstream, proxy := newProxy(t, mode)
+			upstream.Reply("secret", " reply")
+
+			// 请求和响应中的 secret 替换为 ***
+			redact := func(parts []models.GeminiPart) {
+				for i := range parts {
+					parts[i].Text = strings.ReplaceAll(parts[i].Text, "secret", "***")
+				}
+			}
+			proxy.Client.AddInterceptor(client.InterceptorFuncs{
+				Request: func(ctx context.Context, modelID string, req *models.GeminiRequest) error {
+					if strings.Contains(req.Contents[len(req.Contents)-1].Parts[0].Text, "forbidden") {
+						return errors.New("forbidden topic")
+					}
+					for i := range req.Contents {
+						redact(req.Contents[i].Parts)
+					}
+					return nil
+				},
+				Response: func(ctx context.Context, modelID string, resp *models.GeminiResponse) error {
+					for i := range resp.Candidates {
+						redact(resp.Candidates[i].Content.Parts)
+					}
+					return nil
+				},
+				StreamChunk: func(ctx context.Context, modelID string, chunk *models.GeminiStreamChunk) error {
+					for i := range chunk.Candidates {
+						redact(chunk.Candidates[i].Content.Parts)
+					}
+					return nil
+				},
+			})
+
+			chat := func(stream bool, content string) map[string]any {
+				return map[string]any{
+					"model":    "gemini-2.5-flash",
+					"stream":   stream,
+					"messages": []map[string]any{{"role": "user", "content": content}},
+				}
+			}
+
+			resp, err := proxy.Post("/v1/chat/completions", chat(false, "my secret plan"))
+			require.NoError(t, err)
+			defer resp.Body.Close()
+			require.Equal(t, http.StatusOK, resp.StatusCode)
+			var completion models.OpenAIResponse
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&completion))
+			assert.Equal(t, "*** reply", completion.Choices[0].Message.Content)
+
+			req, ok := upstream.LastRequest()
+			require.True(t, ok)
+			sent, err := req.GeminiRequest()
+			require.NoError(t, err)
+			assert.Equal(t, "my *** plan", sent.Contents[0].Parts[0].Text)
+
+			resp, err = proxy.Post("/v1/chat/completions", chat(true, "hi"))
+			require.NoError(t, err)
+			defer resp.Body.Close()
+			var text strings.Builder
+			scanner := bufio.NewScanner(resp.Body)
+			for scanner.Scan() {
+				data, ok := strings.CutPrefix(scanner.Text(), "data: ")
+				if !ok || data == "[DONE]" {
+					continue
+				}
+				var chunk models.OpenAIStreamChunk
+				require.NoError(t, json.Unmarshal([]byte(data), &chunk))
+				for _, choice := range chunk.Choices {
+					text.WriteString(choice.Delta.Content)
+				}
+			}
+			assert.Equal(t, "*** reply", text.String())
+
+			// 拦截器拒绝的请求返回400，不发往上游
+			count := len(upstream.Requests())
+			resp, err = proxy.Post("/v1/chat/completions", chat(false, "a forbidden question"))
+			require.NoError(t, err)
+			defer resp.Body.Close()
+			assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+			assert.Len(t, upstream.Requests(), count)
+		})
+	}
+}
+
+func TestE2E_VertexInvalidLocation(t *testing.T) {
+	paths := []string{
+		"/v1beta/projects/p/locations/evil.example%23/models/x:generateContent",
+		"/vertex/v1/projects/p/locations/evil.example%23/models/x:generateContent",
+		"/vertex/v1/projects/p/locations/evil.example%23/models/x:predict",
+		"/vertex/v1/projects/p/locations/evil.example%23/tuningJobs",
+	}
+	for _, reverse := range []bool{false, true} {
+		t.Run(fmt.Sprintf("reverse=%v", reverse), func(t *testing.T) {
+			upstream := proxytest.NewUpstream()
+			t.Cleanup(upstream.Close)
+			cfg := config.DefaultConfig()
+			cfg.APIMode = config.VertexAI
+			cfg.NativeReverseProxy = reverse
+			proxy := proxytest.NewProxy(upstream, cfg, nil)
+			t.Cleanup(proxy.Close)
+
+			// location会成为上游主机名的一部分，不合法的location不能带着认证信息发往其他主机
+			for _, path := range paths {
+				resp, err := proxy.Post(path, map[string]any{
+					"contents": []map[string]any{{"role": "user", "parts": []map[string]any{{"text": "Hi"}}}},
+				})
+				require.NoError(t, err)
+				resp.Body.Close()
+				assert.Equal(t, http.StatusBadRequest, resp.StatusCode, path)
+			}
+			assert.Empty(t, upstream.Requests())
+		})
+	}
+}
+
+func TestE2E_AuditCaller(t *testing.T) {
+	_, proxy := newProxy(t, config.AIStudio)
+	path := filepath.Join(t.TempDir(), "audit.log")
+	auditLog, err := audit.Open(path, audit.Options{}, nil)
+	require.NoError(t, err)
+	proxy.Server.SetAuditLog(auditLog)
+
+	send := func(apiKey string) {
+		req, err := http.NewRequest(http.MethodGet, proxy.URL+"/v1/models", nil)
+		require.NoError(t, err)
+		// 未经签名校验的密钥ID头不能决定审计记录中的调用方
+		req.Header.Set("X-Proxy-Key-Id", "victim")
+		if apiKey != "" {
+			req.Header.Set("Authorization", "Bearer "+apiKey)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+	}
+	send("")
+	send("wrong-key")
+	send(proxy.APIKey)
+	require.NoError(t, auditLog.Close())
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	var entries []audit.Entry
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		var entry audit.Entry
+		require.NoError(t, json.Unmarshal([]byte(line), &entry))
+		if entry.Path == "/v1/models" {
+			entries = append(entries, entry)
+		}
+	}
+	require.Len(t, entries, 3)
+	assert.Equal(t, "unverified", entries[0].Key)
+	assert.Equal(t, http.StatusUnauthorized, entries[0].Status)
+	assert.Equal(t, "unverified", entries[1].Key)
+	assert.True(t, strings.HasPrefix(entries[2].Key, "key:"), entries[2].Key)
+	assert.Equal(t, http.StatusOK, entries[2].Status)
+}
+
+func TestE2E_AsyncJobs(t *testing.T) {
+	upstream := proxytest.NewUpstream()
+	t.Cleanup(upstream.Close)
+	cfg := config.DefaultConfig()
+	cfg.APIMode = config.AIStudio
+	cfg.APIKeys = []string{"key-a", "key-b"}
+	proxy := proxytest.NewProxy(upstream, cfg, nil)
+	t.Cleanup(proxy.Close)
+	queue, err := jobs.NewQueue("", 1, nil)
+	require.NoError(t, err)
+	proxy.Server.SetJobQueue(queue, nil)
+
+	send := func(method, path, apiKey string, body any) *http.Response {
+		var reader io.Reader
+		if body != nil {
+			data, err := json.Marshal(body)
+			require.NoError(t, err)
+			reader = bytes.NewReader(data)
+		}
+		req, err := http.NewRequest(method, proxy.URL+path, reader)
+		require.NoError(t, err)
+		req.Header.Set("Authorization", "Bearer "+apiKey)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		t.Cleanup(func() { resp.Body.Close() })
+		return resp
+	}
+
+	// webhook只能是公网https地址
+	for _, webhook := range []string{"http://example.com/hook", "https://127.0.0.1/hook", "https://localhost/hook", "https://169.254.169.254/latest"} {
+		body := map[string]any{"model": "gemini-2.5-flash", "messages": []map[string]string{{"role": "user", "content": "hi"}}, "webhook": webhook}
+		resp := send(http.MethodPost, "/v1/async/chat/completions", "key-a", body)
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode, webhook)
+	}
+
+	body := map[string]any{"model": "gemini-2.5-flash", "messages": []map[string]string{{"role": "user", "content": "hi"}}}
+	resp := send(http.MethodPost, "/v1/async/chat/completions", "key-a", body)
+	require.Equal(t, http.StatusAccepted, resp.StatusCode)
+	var job models.AsyncJob
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&job))
+
+	// 只有提交任务的密钥可以查询
+	assert.Equal(t, http.StatusOK, send(http.MethodGet, "/v1/async/"+job.ID, "key-a", nil).StatusCode)
+	assert.Equal(t, http.StatusNotFound, send(http.MethodGet, "/v1/async/"+job.ID, "key-b", nil).StatusCode)
+}
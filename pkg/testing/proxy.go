@@ -0,0 +1,107 @@
+package testing
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/ba0gu0/gemini-go-proxy/pkg/auth"
+	"github.com/ba0gu0/gemini-go-proxy/pkg/client"
+	"github.com/ba0gu0/gemini-go-proxy/pkg/config"
+	"github.com/ba0gu0/gemini-go-proxy/pkg/handler"
+	"github.com/ba0gu0/gemini-go-proxy/pkg/models"
+	"github.com/sirupsen/logrus"
+)
+
+// DefaultAPIKey 未配置 api_keys 时代理使用的客户端密钥
+const DefaultAPIKey = "test-api-key"
+
+// StreamResumeTokenHeader 启用流式续传时返回续传令牌的响应头
+const StreamResumeTokenHeader = handler.StreamResumeTokenHeader
+
+// Proxy 连接到模拟上游的代理实例，经过完整的路由、认证、格式转换和重试流程
+type Proxy struct {
+	URL    string // 代理地址
+	APIKey string // 请求代理时使用的客户端密钥
+
+	Client *client.GeminiClient
+	Server *handler.Server
+
+	server *httptest.Server
+}
+
+// NewProxy 启动连接到模拟上游的代理，cfg为nil时使用AI Studio模式的默认配置
+// 代理不进行OAuth认证，Vertex AI和Code Assist请求使用cfg.ProjectID（为空时为 DefaultProjectID）
+func NewProxy(upstream *Upstream, cfg *config.Config, logger *logrus.Logger) *Proxy {
+	if cfg == nil {
+		cfg = config.DefaultConfig()
+		cfg.APIMode = config.AIStudio
+	}
+	if len(cfg.APIKeys) == 0 {
+		cfg.APIKeys = []string{DefaultAPIKey}
+	}
+	if cfg.ProjectID == "" {
+		cfg.ProjectID = DefaultProjectID
+	}
+	if logger == nil {
+		logger = logrus.New()
+		logger.SetOutput(io.Discard)
+	}
+
+	googleAuth := auth.NewGoogleAuth(&models.GoogleAuthConfig{
+		ProjectID: cfg.ProjectID,
+		Location:  cfg.Location,
+	}, logger)
+
+	geminiClient := client.NewGeminiClient(cfg, googleAuth, logger)
+	geminiClient.SetTransport(upstream.Transport())
+
+	server := handler.NewServer(geminiClient, &handler.ServerConfig{
+		EnableCORS:           cfg.EnableCORS,
+		APIKeys:              cfg.APIKeys,
+		AdminAPIKeys:         cfg.AdminAPIKeys,
+		StreamMetadataEvent:  cfg.StreamMetadataEvent,
+		NativeReverseProxy:   cfg.NativeReverseProxy,
+		KeyResponseLanguages: cfg.KeyResponseLanguages,
+		KeyRateLimits:        cfg.KeyRateLimits,
+		DisabledRoutes:       cfg.DisabledRoutes,
+		ResponseHeaders:      cfg.ResponseHeaders,
+		StreamResumeBuffer:   cfg.StreamResumeBuffer,
+		StreamResumeTTL:      time.Duration(cfg.StreamResumeTTLSeconds) * time.Second,
+		ModelMappings:        cfg.ModelMappings,
+	}, logger)
+
+	httpServer := httptest.NewServer(server.GetRouter())
+	return &Proxy{
+		URL:    httpServer.URL,
+		APIKey: cfg.APIKeys[0],
+		Client: geminiClient,
+		Server: server,
+		server: httpServer,
+	}
+}
+
+// Close 关闭代理
+func (p *Proxy) Close() {
+	p.server.Close()
+}
+
+// Post 以JSON格式向代理发送带密钥的POST请求
+func (p *Proxy) Post(path string, body any) (*http.Response, error) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request body: %w", err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, p.URL+path, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+p.APIKey)
+	return http.DefaultClient.Do(req)
+}
@@ -0,0 +1,582 @@
+// Package testing 提供模拟Google上游的测试服务器，用于在没有真实凭据的情况下对代理做端到端测试
+package testing
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/ba0gu0/gemini-go-proxy/pkg/config"
+	"github.com/ba0gu0/gemini-go-proxy/pkg/models"
+)
+
+const (
+	// DefaultProjectID loadCodeAssist/onboardUser返回的项目ID
+	DefaultProjectID = "fake-project"
+	// DefaultModelVersion 响应中的 modelVersion
+	DefaultModelVersion = "fake-model-001"
+	// DefaultEmbeddingDimensions 未指定 outputDimensionality 时嵌入向量的维度
+	DefaultEmbeddingDimensions = 8
+)
+
+// FakeImage Imagen predict和图像输出模式下返回的图像数据（PNG文件头）
+var FakeImage = []byte("\x89PNG\r\n\x1a\n")
+
+// FakeAudio 音频输出模式（TTS）下返回的16位PCM音频数据
+var FakeAudio = []byte{0x00, 0x00, 0xff, 0x7f, 0x00, 0x00, 0x01, 0x80}
+
+// Request 上游收到的一次请求
+type Request struct {
+	API    config.APIMode // 请求的接口类型：ai_studio、vertex_ai 或 code_assist
+	Method string
+	Path   string
+	Query  url.Values
+	Model  string // 路径或Code Assist请求体中的模型ID，不含 models/ 前缀
+	Action string // 冒号后的方法名，如 generateContent、streamGenerateContent
+	Header http.Header
+	Body   []byte
+}
+
+// GeminiRequest 解析请求体中的生成请求，Code Assist请求会去掉 request 包装
+func (r Request) GeminiRequest() (*models.GeminiRequest, error) {
+	if r.API == config.CodeAssist {
+		var wrapped models.CodeAssistRequest
+		if err := json.Unmarshal(r.Body, &wrapped); err != nil {
+			return nil, err
+		}
+		if wrapped.Request == nil {
+			return nil, fmt.Errorf("code assist request has no request body")
+		}
+		return wrapped.Request, nil
+	}
+
+	var req models.GeminiRequest
+	if err := json.Unmarshal(r.Body, &req); err != nil {
+		return nil, err
+	}
+	return &req, nil
+}
+
+// failure 预设的失败响应
+type failure struct {
+	status int
+	count  int
+}
+
+// Upstream 模拟Google上游的测试服务器，同时提供AI Studio、Vertex AI和Code Assist接口
+//
+// 生成接口默认返回 Reply 设置的文本，流式接口按文本块逐条发送SSE事件；
+// 收到的请求按顺序记录，可通过 Requests 检查代理转换后的请求
+type Upstream struct {
+	server *httptest.Server
+
+	mu           sync.Mutex
+	requests     []Request
+	chunks       []string
+	modelVersion string
+	failures     []failure
+	blockReason  string
+}
+
+// NewUpstream 启动模拟上游，使用完毕后调用 Close
+func NewUpstream() *Upstream {
+	u := &Upstream{
+		chunks:       []string{"Hello", " from", " the fake upstream"},
+		modelVersion: DefaultModelVersion,
+	}
+	u.server = httptest.NewServer(http.HandlerFunc(u.serveHTTP))
+	return u
+}
+
+// URL 返回模拟上游的地址
+func (u *Upstream) URL() string {
+	return u.server.URL
+}
+
+// Close 关闭模拟上游
+func (u *Upstream) Close() {
+	u.server.Close()
+}
+
+// Transport 返回将 *.googleapis.com 请求转发到模拟上游的传输层，
+// 通过 GeminiClient.SetTransport 或 GeminiProxy.SetTransport 注入
+func (u *Upstream) Transport() http.RoundTripper {
+	target, _ := url.Parse(u.server.URL)
+	return &rewriteTransport{target: target, base: u.server.Client().Transport}
+}
+
+// Reply 设置生成接口返回的文本，流式响应中每个参数作为一个数据块发送
+func (u *Upstream) Reply(chunks ...string) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.chunks = append([]string(nil), chunks...)
+	u.blockReason = ""
+}
+
+// BlockPrompt 使之后的生成请求像提示词被拦截一样，只返回带有 blockReason 的 promptFeedback 而没有候选，
+// 直到下一次调用 Reply 或 Reset
+func (u *Upstream) BlockPrompt(reason string) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.blockReason = reason
+}
+
+// SetModelVersion 设置响应中的 modelVersion
+func (u *Upstream) SetModelVersion(version string) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.modelVersion = version
+}
+
+// FailNext 使接下来count次生成、计数或嵌入请求返回指定状态码的Google格式错误
+func (u *Upstream) FailNext(status, count int) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.failures = append(u.failures, failure{status: status, count: count})
+}
+
+// Requests 返回已收到的请求副本
+func (u *Upstream) Requests() []Request {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return append([]Request(nil), u.requests...)
+}
+
+// LastRequest 返回最近一次请求，尚未收到请求时第二个返回值为false
+func (u *Upstream) LastRequest() (Request, bool) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if len(u.requests) == 0 {
+		return Request{}, false
+	}
+	return u.requests[len(u.requests)-1], true
+}
+
+// Reset 清空请求记录和预设的失败
+func (u *Upstream) Reset() {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.requests = nil
+	u.failures = nil
+	u.blockReason = ""
+}
+
+// serveHTTP 按路径分发到对应接口
+func (u *Upstream) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	req := parseRequest(r, body)
+
+	u.mu.Lock()
+	u.requests = append(u.requests, req)
+	chunks := append([]string(nil), u.chunks...)
+	modelVersion := u.modelVersion
+	blockReason := u.blockReason
+	u.mu.Unlock()
+
+	switch req.Action {
+	case "token":
+		writeJSON(w, map[string]any{"access_token": "fake-access-token", "token_type": "Bearer", "expires_in": 3600})
+		return
+	case "loadCodeAssist":
+		writeJSON(w, map[string]any{"cloudaicompanionProject": DefaultProjectID})
+		return
+	case "onboardUser":
+		writeJSON(w, map[string]any{"done": true, "response": map[string]any{"cloudaicompanionProject": map[string]any{"id": DefaultProjectID}}})
+		return
+	case "models":
+		if strings.Contains(req.Path, "/publishers/") {
+			// Vertex AI发布方模型列表
+			writeJSON(w, map[string]any{"publisherModels": []map[string]any{
+				{"name": "publishers/google/models/gemini-2.5-flash", "versionId": "001"},
+				{"name": "publishers/google/models/imagen-4.0-generate-001", "versionId": "001"},
+			}})
+			return
+		}
+		writeJSON(w, &models.GeminiModelsResponse{Models: []models.GeminiModel{{
+			Name:             "models/gemini-2.5-flash",
+			DisplayName:      "Gemini 2.5 Flash",
+			InputTokenLimit:  1048576,
+			OutputTokenLimit: 65536,
+			SupportedMethods: []string{"generateContent", "countTokens"},
+		}}})
+		return
+	}
+
+	if status := u.nextFailure(); status != 0 {
+		writeError(w, status)
+		return
+	}
+
+	if blockReason != "" && (req.Action == "generateContent" || req.Action == "streamGenerateContent") {
+		writeBlocked(w, req, blockReason, modelVersion)
+		return
+	}
+
+	switch req.Action {
+	case "generateContent":
+		u.writeGenerate(w, req, strings.Join(chunks, ""), modelVersion)
+	case "streamGenerateContent":
+		u.writeStream(w, req, chunks, modelVersion)
+	case "countTokens":
+		writeJSON(w, &models.GeminiCountTokensResponse{TotalTokens: len(strings.Fields(string(body)))})
+	case "embedContent":
+		var embed models.GeminiEmbedContentRequest
+		json.Unmarshal(body, &embed)
+		writeJSON(w, &models.GeminiEmbedContentResponse{Embedding: fakeEmbedding(embed.OutputDimensionality)})
+	case "batchEmbedContents":
+		var batch models.GeminiBatchEmbedContentsRequest
+		json.Unmarshal(body, &batch)
+		resp := &models.GeminiBatchEmbedContentsResponse{}
+		for _, embed := range batch.Requests {
+			resp.Embeddings = append(resp.Embeddings, fakeEmbedding(embed.OutputDimensionality))
+		}
+		writeJSON(w, resp)
+	case "predict":
+		if strings.HasPrefix(req.Model, "imagen") {
+			writeImagePredict(w, body)
+			return
+		}
+		u.writePredict(w, body)
+	case "upload":
+		writeUpload(w, req)
+	case "cachedContents":
+		// 创建上下文缓存：原样返回请求内容并附带资源名
+		cached := map[string]any{}
+		json.Unmarshal(body, &cached)
+		cached["name"] = "cachedContents/fake-cache"
+		writeJSON(w, cached)
+	default:
+		writeError(w, http.StatusNotFound)
+	}
+}
+
+// nextFailure 消耗一次预设的失败，没有时返回0
+func (u *Upstream) nextFailure() int {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	for len(u.failures) > 0 {
+		if u.failures[0].count > 0 {
+			u.failures[0].count--
+			return u.failures[0].status
+		}
+		u.failures = u.failures[1:]
+	}
+	return 0
+}
+
+// writeGenerate 返回非流式生成响应
+func (u *Upstream) writeGenerate(w http.ResponseWriter, req Request, text, modelVersion string) {
+	parts := []models.GeminiPart{{Text: text}}
+	if wantsModality(req, "AUDIO") {
+		// TTS模型只返回音频
+		parts = []models.GeminiPart{{InlineData: &models.GeminiInlineData{
+			MimeType: "audio/L16;codec=pcm;rate=24000",
+			Data:     base64.StdEncoding.EncodeToString(FakeAudio),
+		}}}
+	}
+	if wantsModality(req, "IMAGE") {
+		parts = append(parts, models.GeminiPart{InlineData: &models.GeminiInlineData{
+			MimeType: "image/png",
+			Data:     base64.StdEncoding.EncodeToString(FakeImage),
+		}})
+	}
+	resp := &models.GeminiResponse{
+		Candidates: []models.GeminiCandidate{{
+			Content:      models.GeminiContent{Role: "model", Parts: parts},
+			FinishReason: "STOP",
+		}},
+		UsageMetadata: usage(req, text),
+		ModelVersion:  modelVersion,
+	}
+	if req.API == config.CodeAssist {
+		writeJSON(w, &models.CodeAssistResponse{Response: resp})
+		return
+	}
+	writeJSON(w, resp)
+}
+
+// writeUpload 模拟Files API的可续传上传：start 返回上传地址，upload, finalize 返回文件资源
+func writeUpload(w http.ResponseWriter, req Request) {
+	switch req.Header.Get("X-Goog-Upload-Command") {
+	case "start":
+		w.Header().Set("X-Goog-Upload-URL", "https://generativelanguage.googleapis.com/upload/v1beta/files?upload_id=fake-upload&upload_protocol=resumable")
+		w.Header().Set("X-Goog-Upload-Status", "active")
+		writeJSON(w, map[string]any{})
+	case "upload, finalize":
+		if req.Query.Get("upload_id") != "fake-upload" {
+			writeError(w, http.StatusNotFound)
+			return
+		}
+		w.Header().Set("X-Goog-Upload-Status", "final")
+		writeJSON(w, map[string]any{"file": map[string]any{
+			"name":      "files/fake-file",
+			"uri":       "https://generativelanguage.googleapis.com/v1beta/files/fake-file",
+			"sizeBytes": strconv.Itoa(len(req.Body)),
+			"state":     "ACTIVE",
+		}})
+	default:
+		writeError(w, http.StatusBadRequest)
+	}
+}
+
+// writeBlocked 返回提示词被拦截的响应，流式请求只发送一个数据块
+func writeBlocked(w http.ResponseWriter, req Request, reason, modelVersion string) {
+	resp := &models.GeminiResponse{
+		PromptFeedback: &models.GeminiPromptFeedback{BlockReason: reason},
+		UsageMetadata:  usage(req, ""),
+		ModelVersion:   modelVersion,
+	}
+	var payload any = resp
+	if req.API == config.CodeAssist {
+		payload = &models.CodeAssistResponse{Response: resp}
+	}
+	if req.Action == "generateContent" {
+		writeJSON(w, payload)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.WriteHeader(http.StatusOK)
+	data, _ := json.Marshal(payload)
+	fmt.Fprintf(w, "data: %s\r\n\r\n", data)
+}
+
+// writeStream 按文本块发送SSE事件，最后一块带有 finishReason 和用量；
+// 请求多个候选时每个文本块按候选依次发送，第i个候选（i>0）的文本以 "[i] " 开头
+func (u *Upstream) writeStream(w http.ResponseWriter, req Request, chunks []string, modelVersion string) {
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.WriteHeader(http.StatusOK)
+	flusher, _ := w.(http.Flusher)
+
+	candidates := 1
+	if parsed, err := req.GeminiRequest(); err == nil && parsed.GenerationConfig != nil && parsed.GenerationConfig.CandidateCount != nil {
+		candidates = max(*parsed.GenerationConfig.CandidateCount, 1)
+	}
+
+	for i, text := range chunks {
+		for index := 0; index < candidates; index++ {
+			candidateText := text
+			if index > 0 && i == 0 {
+				candidateText = fmt.Sprintf("[%d] %s", index, text)
+			}
+			chunk := &models.GeminiStreamChunk{
+				Candidates: []models.GeminiStreamCandidate{{
+					Content: models.GeminiContent{Role: "model", Parts: []models.GeminiPart{{Text: candidateText}}},
+					Index:   index,
+				}},
+				ModelVersion: modelVersion,
+			}
+			if i == len(chunks)-1 {
+				chunk.Candidates[0].FinishReason = "STOP"
+				if index == candidates-1 {
+					chunk.UsageMetadata = usage(req, strings.Join(chunks, ""))
+				}
+			}
+
+			var payload any = chunk
+			if req.API == config.CodeAssist {
+				payload = &models.CodeAssistStreamChunk{Response: chunk}
+			}
+			data, _ := json.Marshal(payload)
+			fmt.Fprintf(w, "data: %s\r\n\r\n", data)
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+	}
+}
+
+// writePredict 返回Vertex AI文本嵌入predict响应
+func (u *Upstream) writePredict(w http.ResponseWriter, body []byte) {
+	var predict struct {
+		Instances []struct {
+			Content string `json:"content"`
+		} `json:"instances"`
+		Parameters struct {
+			OutputDimensionality *int `json:"outputDimensionality"`
+		} `json:"parameters"`
+	}
+	json.Unmarshal(body, &predict)
+
+	predictions := make([]any, len(predict.Instances))
+	for i, instance := range predict.Instances {
+		predictions[i] = map[string]any{
+			"embeddings": map[string]any{
+				"values":     fakeEmbedding(predict.Parameters.OutputDimensionality).Values,
+				"statistics": map[string]any{"token_count": len(strings.Fields(instance.Content))},
+			},
+		}
+	}
+	writeJSON(w, map[string]any{"predictions": predictions})
+}
+
+// writeImagePredict 返回Imagen predict响应，按 sampleCount 生成 FakeImage
+func writeImagePredict(w http.ResponseWriter, body []byte) {
+	var predict struct {
+		Parameters struct {
+			SampleCount int `json:"sampleCount"`
+		} `json:"parameters"`
+	}
+	json.Unmarshal(body, &predict)
+
+	predictions := make([]any, max(predict.Parameters.SampleCount, 1))
+	for i := range predictions {
+		predictions[i] = map[string]any{
+			"bytesBase64Encoded": base64.StdEncoding.EncodeToString(FakeImage),
+			"mimeType":           "image/png",
+		}
+	}
+	writeJSON(w, map[string]any{"predictions": predictions})
+}
+
+// wantsModality 判断生成请求的 responseModalities 是否包含指定的输出类型
+func wantsModality(req Request, modality string) bool {
+	body := req.Body
+	if req.API == config.CodeAssist {
+		var wrapped struct {
+			Request json.RawMessage `json:"request"`
+		}
+		json.Unmarshal(body, &wrapped)
+		body = wrapped.Request
+	}
+	var generate struct {
+		GenerationConfig struct {
+			ResponseModalities []string `json:"responseModalities"`
+		} `json:"generationConfig"`
+	}
+	json.Unmarshal(body, &generate)
+	return slices.Contains(generate.GenerationConfig.ResponseModalities, modality)
+}
+
+// parseRequest 从路径识别接口类型、模型和方法
+//
+//	AI Studio:   /v1beta/models/{model}:{action}
+//	Vertex AI:   /v1/projects/{project}/locations/{location}/publishers/google/models/{model}:{action}
+//	Code Assist: /v1internal:{action}（模型在请求体的 model 字段）
+func parseRequest(r *http.Request, body []byte) Request {
+	req := Request{
+		API:    config.AIStudio,
+		Method: r.Method,
+		Path:   r.URL.Path,
+		Query:  r.URL.Query(),
+		Header: r.Header.Clone(),
+		Body:   body,
+	}
+
+	resource, action, _ := strings.Cut(r.URL.Path, ":")
+	req.Action = action
+	switch {
+	case strings.HasPrefix(resource, "/v1internal"):
+		req.API = config.CodeAssist
+		var wrapped struct {
+			Model   string `json:"model"`
+			Request *struct {
+				Model string `json:"model"`
+			} `json:"request"`
+		}
+		json.Unmarshal(body, &wrapped)
+		req.Model = wrapped.Model
+		if req.Model == "" && wrapped.Request != nil {
+			req.Model = strings.TrimPrefix(wrapped.Request.Model, "models/")
+		}
+	case strings.Contains(resource, "/projects/"):
+		req.API = config.VertexAI
+	}
+
+	if req.API != config.CodeAssist {
+		for _, prefix := range []string{"/models/", "/endpoints/", "/tunedModels/"} {
+			if i := strings.LastIndex(resource, prefix); i >= 0 {
+				req.Model = resource[i+len(prefix):]
+				break
+			}
+		}
+	}
+
+	// 没有冒号的路径：令牌接口、模型列表、上下文缓存和文件上传
+	if action == "" {
+		switch {
+		case strings.HasPrefix(resource, "/upload/") && strings.HasSuffix(resource, "/files"):
+			req.Action = "upload"
+		case strings.HasSuffix(resource, "/token"):
+			req.Action = "token"
+		case strings.HasSuffix(resource, "/models"):
+			req.Action = "models"
+		case strings.HasSuffix(resource, "/cachedContents"):
+			req.Action = "cachedContents"
+		}
+	}
+	return req
+}
+
+// usage 按空白分词近似统计用量
+func usage(req Request, text string) *models.GeminiUsageMetadata {
+	prompt := len(strings.Fields(string(req.Body)))
+	candidates := len(strings.Fields(text))
+	return &models.GeminiUsageMetadata{
+		PromptTokenCount:     prompt,
+		CandidatesTokenCount: candidates,
+		TotalTokenCount:      prompt + candidates,
+	}
+}
+
+// fakeEmbedding 生成固定的嵌入向量
+func fakeEmbedding(dimensions *int) models.GeminiContentEmbedding {
+	size := DefaultEmbeddingDimensions
+	if dimensions != nil && *dimensions > 0 {
+		size = *dimensions
+	}
+	values := make([]float32, size)
+	for i := range values {
+		values[i] = float32(i+1) / float32(size)
+	}
+	return models.GeminiContentEmbedding{Values: values}
+}
+
+// writeJSON 输出JSON响应
+func writeJSON(w http.ResponseWriter, data any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(data)
+}
+
+// writeError 输出Google格式的错误响应，429时附带 Retry-After
+func writeError(w http.ResponseWriter, status int) {
+	if status == http.StatusTooManyRequests {
+		w.Header().Set("Retry-After", "1")
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]any{
+		"error": map[string]any{
+			"code":    status,
+			"message": http.StatusText(status),
+			"status":  strings.ToUpper(strings.ReplaceAll(http.StatusText(status), " ", "_")),
+		},
+	})
+}
+
+// rewriteTransport 将发往Google域名的请求改写到模拟上游
+type rewriteTransport struct {
+	target *url.URL
+	base   http.RoundTripper
+}
+
+// RoundTrip 改写请求地址后发送
+func (t *rewriteTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !strings.HasSuffix(req.URL.Hostname(), "googleapis.com") {
+		return t.base.RoundTrip(req)
+	}
+
+	rewritten := req.Clone(req.Context())
+	rewritten.URL.Scheme = t.target.Scheme
+	rewritten.URL.Host = t.target.Host
+	rewritten.Host = t.target.Host
+	return t.base.RoundTrip(rewritten)
+}
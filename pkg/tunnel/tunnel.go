@@ -0,0 +1,152 @@
+// Package tunnel 在OAuth授权期间临时建立公网隧道（cloudflared快速隧道或ngrok），
+// 供无法从外部访问的机器接收授权回调
+package tunnel
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// 支持的隧道工具
+const (
+	Cloudflared = "cloudflared"
+	Ngrok       = "ngrok"
+)
+
+// ErrUnsupportedProvider 未知的隧道工具
+var ErrUnsupportedProvider = errors.New("unsupported tunnel provider")
+
+// StartTimeout 等待隧道工具输出公网地址的超时时间
+const StartTimeout = 30 * time.Second
+
+// provider 隧道工具的启动命令和从输出中提取公网地址的规则，第一个分组为地址
+type provider struct {
+	command func(localURL string) []string
+	pattern *regexp.Regexp
+}
+
+var providers = map[string]provider{
+	// 免登录的快速隧道，地址输出到stderr
+	Cloudflared: {
+		command: func(localURL string) []string {
+			return []string{"cloudflared", "tunnel", "--no-autoupdate", "--url", localURL}
+		},
+		pattern: regexp.MustCompile(`(https://[a-z0-9-]+\.trycloudflare\.com)`),
+	},
+	// 需要事先执行 ngrok config add-authtoken
+	Ngrok: {
+		command: func(localURL string) []string {
+			return []string{"ngrok", "http", localURL, "--log", "stdout", "--log-format", "logfmt"}
+		},
+		pattern: regexp.MustCompile(`msg="started tunnel".*\burl=(https://[^\s"]+)`),
+	},
+}
+
+// Providers 返回支持的隧道工具名称
+func Providers() []string {
+	names := make([]string, 0, len(providers))
+	for name := range providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Tunnel 运行中的隧道进程
+type Tunnel struct {
+	URL string // 隧道的公网地址
+
+	name      string
+	cmd       *exec.Cmd
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// Start 启动隧道工具将localURL暴露到公网，等待其输出公网地址后返回
+func Start(ctx context.Context, name, localURL string, logger *logrus.Logger) (*Tunnel, error) {
+	p, ok := providers[strings.ToLower(name)]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q (supported: %s)", ErrUnsupportedProvider, name, strings.Join(Providers(), ", "))
+	}
+	if logger == nil {
+		logger = logrus.New()
+	}
+
+	args := p.command(localURL)
+	pr, pw := io.Pipe()
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdout = pw
+	cmd.Stderr = pw
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start %s (is it installed and on PATH?): %w", args[0], err)
+	}
+
+	t := &Tunnel{name: name, cmd: cmd, done: make(chan struct{})}
+	go func() {
+		cmd.Wait()
+		pw.Close()
+		close(t.done)
+	}()
+
+	// 持续读取输出，避免隧道进程因管道写满而阻塞
+	found := make(chan string, 1)
+	go func() {
+		scanner := bufio.NewScanner(pr)
+		sent := false
+		for scanner.Scan() {
+			line := scanner.Text()
+			logger.Debugf("%s: %s", name, line)
+			if !sent {
+				if match := p.pattern.FindStringSubmatch(line); match != nil {
+					found <- match[1]
+					sent = true
+				}
+			}
+		}
+		io.Copy(io.Discard, pr)
+		if !sent {
+			close(found)
+		}
+	}()
+
+	timer := time.NewTimer(StartTimeout)
+	defer timer.Stop()
+	select {
+	case url, ok := <-found:
+		if !ok {
+			t.Close()
+			return nil, fmt.Errorf("%s exited before reporting a public URL", name)
+		}
+		t.URL = url
+		logger.Infof("Started %s tunnel %s -> %s", name, url, localURL)
+		return t, nil
+	case <-timer.C:
+		t.Close()
+		return nil, fmt.Errorf("%s did not report a public URL within %s", name, StartTimeout)
+	case <-ctx.Done():
+		t.Close()
+		return nil, ctx.Err()
+	}
+}
+
+// Close 停止隧道进程，重复调用是安全的
+func (t *Tunnel) Close() error {
+	t.closeOnce.Do(func() {
+		if t.cmd.Process != nil {
+			t.cmd.Process.Kill()
+		}
+	})
+	<-t.done
+	return nil
+}
@@ -0,0 +1,63 @@
+package tunnel
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeProvider 使用shell脚本模拟隧道工具的输出
+func fakeProvider(t *testing.T, script string) {
+	t.Helper()
+	providers["fake"] = provider{
+		command: func(localURL string) []string { return []string{"sh", "-c", script, "fake", localURL} },
+		pattern: regexp.MustCompile(`url=(https://\S+)`),
+	}
+	t.Cleanup(func() { delete(providers, "fake") })
+}
+
+func TestStart(t *testing.T) {
+	fakeProvider(t, `echo "connecting to $1"; echo "ready url=https://abc.example.com"; exec sleep 30`)
+
+	tunnel, err := Start(context.Background(), "fake", "http://127.0.0.1:8081", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "https://abc.example.com", tunnel.URL)
+
+	closed := make(chan struct{})
+	go func() {
+		tunnel.Close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Close did not stop the tunnel process")
+	}
+	assert.NoError(t, tunnel.Close())
+}
+
+func TestStart_Errors(t *testing.T) {
+	_, err := Start(context.Background(), "localtunnel", "http://127.0.0.1:8081", nil)
+	assert.ErrorIs(t, err, ErrUnsupportedProvider)
+
+	// 进程退出前没有输出地址
+	fakeProvider(t, `echo "authentication failed"; exit 1`)
+	_, err = Start(context.Background(), "fake", "http://127.0.0.1:8081", nil)
+	assert.ErrorContains(t, err, "exited before reporting a public URL")
+}
+
+func TestProviderPatterns(t *testing.T) {
+	cloudflared := providers[Cloudflared].pattern.FindStringSubmatch(
+		"2024-01-01T00:00:00Z INF |  https://quiet-river-1234.trycloudflare.com                                     |")
+	require.NotNil(t, cloudflared)
+	assert.Equal(t, "https://quiet-river-1234.trycloudflare.com", cloudflared[1])
+
+	ngrok := providers[Ngrok].pattern.FindStringSubmatch(
+		`t=2024-01-01T00:00:00+0000 lvl=info msg="started tunnel" obj=tunnels name=command_line addr=http://127.0.0.1:8081 url=https://1a2b.ngrok-free.app`)
+	require.NotNil(t, ngrok)
+	assert.Equal(t, "https://1a2b.ngrok-free.app", ngrok[1])
+}